@@ -0,0 +1,6 @@
+// gobgpを外部から操作するためのgRPC API
+//
+// gobgp.protoを変更した場合は、go generateでコードを生成し直す。
+package api
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative gobgp.proto
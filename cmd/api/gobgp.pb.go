@@ -0,0 +1,2688 @@
+// gobgpを外部から操作するためのgRPC API
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.34.2
+// 	protoc        v27.3.0
+// source: gobgp.proto
+
+package api
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type SessionState int32
+
+const (
+	SessionState_IDLE         SessionState = 0
+	SessionState_CONNECT      SessionState = 1
+	SessionState_OPEN_SENT    SessionState = 2
+	SessionState_OPEN_CONFIRM SessionState = 3
+	SessionState_ESTABLISHED  SessionState = 4
+)
+
+// Enum value maps for SessionState.
+var (
+	SessionState_name = map[int32]string{
+		0: "IDLE",
+		1: "CONNECT",
+		2: "OPEN_SENT",
+		3: "OPEN_CONFIRM",
+		4: "ESTABLISHED",
+	}
+	SessionState_value = map[string]int32{
+		"IDLE":         0,
+		"CONNECT":      1,
+		"OPEN_SENT":    2,
+		"OPEN_CONFIRM": 3,
+		"ESTABLISHED":  4,
+	}
+)
+
+func (x SessionState) Enum() *SessionState {
+	p := new(SessionState)
+	*p = x
+	return p
+}
+
+func (x SessionState) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (SessionState) Descriptor() protoreflect.EnumDescriptor {
+	return file_gobgp_proto_enumTypes[0].Descriptor()
+}
+
+func (SessionState) Type() protoreflect.EnumType {
+	return &file_gobgp_proto_enumTypes[0]
+}
+
+func (x SessionState) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use SessionState.Descriptor instead.
+func (SessionState) EnumDescriptor() ([]byte, []int) {
+	return file_gobgp_proto_rawDescGZIP(), []int{0}
+}
+
+type Mode int32
+
+const (
+	Mode_PASSIVE Mode = 0
+	Mode_ACTIVE  Mode = 1
+)
+
+// Enum value maps for Mode.
+var (
+	Mode_name = map[int32]string{
+		0: "PASSIVE",
+		1: "ACTIVE",
+	}
+	Mode_value = map[string]int32{
+		"PASSIVE": 0,
+		"ACTIVE":  1,
+	}
+)
+
+func (x Mode) Enum() *Mode {
+	p := new(Mode)
+	*p = x
+	return p
+}
+
+func (x Mode) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Mode) Descriptor() protoreflect.EnumDescriptor {
+	return file_gobgp_proto_enumTypes[1].Descriptor()
+}
+
+func (Mode) Type() protoreflect.EnumType {
+	return &file_gobgp_proto_enumTypes[1]
+}
+
+func (x Mode) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Mode.Descriptor instead.
+func (Mode) EnumDescriptor() ([]byte, []int) {
+	return file_gobgp_proto_rawDescGZIP(), []int{1}
+}
+
+type TableType int32
+
+const (
+	TableType_LOC_RIB     TableType = 0
+	TableType_ADJ_RIB_IN  TableType = 1
+	TableType_ADJ_RIB_OUT TableType = 2
+)
+
+// Enum value maps for TableType.
+var (
+	TableType_name = map[int32]string{
+		0: "LOC_RIB",
+		1: "ADJ_RIB_IN",
+		2: "ADJ_RIB_OUT",
+	}
+	TableType_value = map[string]int32{
+		"LOC_RIB":     0,
+		"ADJ_RIB_IN":  1,
+		"ADJ_RIB_OUT": 2,
+	}
+)
+
+func (x TableType) Enum() *TableType {
+	p := new(TableType)
+	*p = x
+	return p
+}
+
+func (x TableType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (TableType) Descriptor() protoreflect.EnumDescriptor {
+	return file_gobgp_proto_enumTypes[2].Descriptor()
+}
+
+func (TableType) Type() protoreflect.EnumType {
+	return &file_gobgp_proto_enumTypes[2]
+}
+
+func (x TableType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use TableType.Descriptor instead.
+func (TableType) EnumDescriptor() ([]byte, []int) {
+	return file_gobgp_proto_rawDescGZIP(), []int{2}
+}
+
+// 本実装では "ipv4-unicast" のみ扱う
+type Family struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Afi  uint32 `protobuf:"varint,1,opt,name=afi,proto3" json:"afi,omitempty"`
+	Safi uint32 `protobuf:"varint,2,opt,name=safi,proto3" json:"safi,omitempty"`
+}
+
+func (x *Family) Reset() {
+	*x = Family{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_gobgp_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Family) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Family) ProtoMessage() {}
+
+func (x *Family) ProtoReflect() protoreflect.Message {
+	mi := &file_gobgp_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Family.ProtoReflect.Descriptor instead.
+func (*Family) Descriptor() ([]byte, []int) {
+	return file_gobgp_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Family) GetAfi() uint32 {
+	if x != nil {
+		return x.Afi
+	}
+	return 0
+}
+
+func (x *Family) GetSafi() uint32 {
+	if x != nil {
+		return x.Safi
+	}
+	return 0
+}
+
+type NeighborConfig struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Address  string `protobuf:"bytes,1,opt,name=address,proto3" json:"address,omitempty"`
+	RemoteAs uint32 `protobuf:"varint,2,opt,name=remote_as,json=remoteAs,proto3" json:"remote_as,omitempty"`
+	// 指定しない場合はrouter-idを使う
+	LocalAddress string `protobuf:"bytes,3,opt,name=local_address,json=localAddress,proto3" json:"local_address,omitempty"`
+	Mode         Mode   `protobuf:"varint,4,opt,name=mode,proto3,enum=gobgpapi.Mode" json:"mode,omitempty"`
+	// Activeモードで接続するPeerのポート。0の場合は179番ポートを使う
+	Port uint32 `protobuf:"varint,5,opt,name=port,proto3" json:"port,omitempty"`
+	// 0の場合はHold Timerを使用しない
+	HoldTime          uint32 `protobuf:"varint,6,opt,name=hold_time,json=holdTime,proto3" json:"hold_time,omitempty"`
+	KeepaliveInterval uint32 `protobuf:"varint,7,opt,name=keepalive_interval,json=keepaliveInterval,proto3" json:"keepalive_interval,omitempty"`
+	ConnectRetry      uint32 `protobuf:"varint,8,opt,name=connect_retry,json=connectRetry,proto3" json:"connect_retry,omitempty"`
+}
+
+func (x *NeighborConfig) Reset() {
+	*x = NeighborConfig{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_gobgp_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *NeighborConfig) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NeighborConfig) ProtoMessage() {}
+
+func (x *NeighborConfig) ProtoReflect() protoreflect.Message {
+	mi := &file_gobgp_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NeighborConfig.ProtoReflect.Descriptor instead.
+func (*NeighborConfig) Descriptor() ([]byte, []int) {
+	return file_gobgp_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *NeighborConfig) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+func (x *NeighborConfig) GetRemoteAs() uint32 {
+	if x != nil {
+		return x.RemoteAs
+	}
+	return 0
+}
+
+func (x *NeighborConfig) GetLocalAddress() string {
+	if x != nil {
+		return x.LocalAddress
+	}
+	return ""
+}
+
+func (x *NeighborConfig) GetMode() Mode {
+	if x != nil {
+		return x.Mode
+	}
+	return Mode_PASSIVE
+}
+
+func (x *NeighborConfig) GetPort() uint32 {
+	if x != nil {
+		return x.Port
+	}
+	return 0
+}
+
+func (x *NeighborConfig) GetHoldTime() uint32 {
+	if x != nil {
+		return x.HoldTime
+	}
+	return 0
+}
+
+func (x *NeighborConfig) GetKeepaliveInterval() uint32 {
+	if x != nil {
+		return x.KeepaliveInterval
+	}
+	return 0
+}
+
+func (x *NeighborConfig) GetConnectRetry() uint32 {
+	if x != nil {
+		return x.ConnectRetry
+	}
+	return 0
+}
+
+type MessageCounters struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Open         uint64 `protobuf:"varint,1,opt,name=open,proto3" json:"open,omitempty"`
+	Update       uint64 `protobuf:"varint,2,opt,name=update,proto3" json:"update,omitempty"`
+	Notification uint64 `protobuf:"varint,3,opt,name=notification,proto3" json:"notification,omitempty"`
+	Keepalive    uint64 `protobuf:"varint,4,opt,name=keepalive,proto3" json:"keepalive,omitempty"`
+	Total        uint64 `protobuf:"varint,5,opt,name=total,proto3" json:"total,omitempty"`
+}
+
+func (x *MessageCounters) Reset() {
+	*x = MessageCounters{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_gobgp_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MessageCounters) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MessageCounters) ProtoMessage() {}
+
+func (x *MessageCounters) ProtoReflect() protoreflect.Message {
+	mi := &file_gobgp_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MessageCounters.ProtoReflect.Descriptor instead.
+func (*MessageCounters) Descriptor() ([]byte, []int) {
+	return file_gobgp_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *MessageCounters) GetOpen() uint64 {
+	if x != nil {
+		return x.Open
+	}
+	return 0
+}
+
+func (x *MessageCounters) GetUpdate() uint64 {
+	if x != nil {
+		return x.Update
+	}
+	return 0
+}
+
+func (x *MessageCounters) GetNotification() uint64 {
+	if x != nil {
+		return x.Notification
+	}
+	return 0
+}
+
+func (x *MessageCounters) GetKeepalive() uint64 {
+	if x != nil {
+		return x.Keepalive
+	}
+	return 0
+}
+
+func (x *MessageCounters) GetTotal() uint64 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+type NeighborState struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	SessionState SessionState `protobuf:"varint,1,opt,name=session_state,json=sessionState,proto3,enum=gobgpapi.SessionState" json:"session_state,omitempty"`
+	// 管理者によって無効にされているかどうか
+	AdminDown bool `protobuf:"varint,2,opt,name=admin_down,json=adminDown,proto3" json:"admin_down,omitempty"`
+	// Establishedに遷移した時刻(Unix時間)。Establishedでない場合は0
+	Uptime int64 `protobuf:"varint,3,opt,name=uptime,proto3" json:"uptime,omitempty"`
+	// ネゴシエーションしたHold Time(秒)
+	NegotiatedHoldTime uint32           `protobuf:"varint,4,opt,name=negotiated_hold_time,json=negotiatedHoldTime,proto3" json:"negotiated_hold_time,omitempty"`
+	Sent               *MessageCounters `protobuf:"bytes,5,opt,name=sent,proto3" json:"sent,omitempty"`
+	Received           *MessageCounters `protobuf:"bytes,6,opt,name=received,proto3" json:"received,omitempty"`
+	// AdjRibInの経路数と、そのうちLocRibで最適経路として選択された経路数
+	ReceivedPrefixes   uint64 `protobuf:"varint,7,opt,name=received_prefixes,json=receivedPrefixes,proto3" json:"received_prefixes,omitempty"`
+	AcceptedPrefixes   uint64 `protobuf:"varint,8,opt,name=accepted_prefixes,json=acceptedPrefixes,proto3" json:"accepted_prefixes,omitempty"`
+	AdvertisedPrefixes uint64 `protobuf:"varint,9,opt,name=advertised_prefixes,json=advertisedPrefixes,proto3" json:"advertised_prefixes,omitempty"`
+}
+
+func (x *NeighborState) Reset() {
+	*x = NeighborState{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_gobgp_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *NeighborState) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NeighborState) ProtoMessage() {}
+
+func (x *NeighborState) ProtoReflect() protoreflect.Message {
+	mi := &file_gobgp_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NeighborState.ProtoReflect.Descriptor instead.
+func (*NeighborState) Descriptor() ([]byte, []int) {
+	return file_gobgp_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *NeighborState) GetSessionState() SessionState {
+	if x != nil {
+		return x.SessionState
+	}
+	return SessionState_IDLE
+}
+
+func (x *NeighborState) GetAdminDown() bool {
+	if x != nil {
+		return x.AdminDown
+	}
+	return false
+}
+
+func (x *NeighborState) GetUptime() int64 {
+	if x != nil {
+		return x.Uptime
+	}
+	return 0
+}
+
+func (x *NeighborState) GetNegotiatedHoldTime() uint32 {
+	if x != nil {
+		return x.NegotiatedHoldTime
+	}
+	return 0
+}
+
+func (x *NeighborState) GetSent() *MessageCounters {
+	if x != nil {
+		return x.Sent
+	}
+	return nil
+}
+
+func (x *NeighborState) GetReceived() *MessageCounters {
+	if x != nil {
+		return x.Received
+	}
+	return nil
+}
+
+func (x *NeighborState) GetReceivedPrefixes() uint64 {
+	if x != nil {
+		return x.ReceivedPrefixes
+	}
+	return 0
+}
+
+func (x *NeighborState) GetAcceptedPrefixes() uint64 {
+	if x != nil {
+		return x.AcceptedPrefixes
+	}
+	return 0
+}
+
+func (x *NeighborState) GetAdvertisedPrefixes() uint64 {
+	if x != nil {
+		return x.AdvertisedPrefixes
+	}
+	return 0
+}
+
+type Neighbor struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Config *NeighborConfig `protobuf:"bytes,1,opt,name=config,proto3" json:"config,omitempty"`
+	State  *NeighborState  `protobuf:"bytes,2,opt,name=state,proto3" json:"state,omitempty"`
+}
+
+func (x *Neighbor) Reset() {
+	*x = Neighbor{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_gobgp_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Neighbor) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Neighbor) ProtoMessage() {}
+
+func (x *Neighbor) ProtoReflect() protoreflect.Message {
+	mi := &file_gobgp_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Neighbor.ProtoReflect.Descriptor instead.
+func (*Neighbor) Descriptor() ([]byte, []int) {
+	return file_gobgp_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Neighbor) GetConfig() *NeighborConfig {
+	if x != nil {
+		return x.Config
+	}
+	return nil
+}
+
+func (x *Neighbor) GetState() *NeighborState {
+	if x != nil {
+		return x.State
+	}
+	return nil
+}
+
+type ListNeighborsRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *ListNeighborsRequest) Reset() {
+	*x = ListNeighborsRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_gobgp_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ListNeighborsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListNeighborsRequest) ProtoMessage() {}
+
+func (x *ListNeighborsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_gobgp_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListNeighborsRequest.ProtoReflect.Descriptor instead.
+func (*ListNeighborsRequest) Descriptor() ([]byte, []int) {
+	return file_gobgp_proto_rawDescGZIP(), []int{5}
+}
+
+type ListNeighborsResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Neighbors []*Neighbor `protobuf:"bytes,1,rep,name=neighbors,proto3" json:"neighbors,omitempty"`
+}
+
+func (x *ListNeighborsResponse) Reset() {
+	*x = ListNeighborsResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_gobgp_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ListNeighborsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListNeighborsResponse) ProtoMessage() {}
+
+func (x *ListNeighborsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_gobgp_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListNeighborsResponse.ProtoReflect.Descriptor instead.
+func (*ListNeighborsResponse) Descriptor() ([]byte, []int) {
+	return file_gobgp_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ListNeighborsResponse) GetNeighbors() []*Neighbor {
+	if x != nil {
+		return x.Neighbors
+	}
+	return nil
+}
+
+type GetNeighborRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Address string `protobuf:"bytes,1,opt,name=address,proto3" json:"address,omitempty"`
+}
+
+func (x *GetNeighborRequest) Reset() {
+	*x = GetNeighborRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_gobgp_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetNeighborRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetNeighborRequest) ProtoMessage() {}
+
+func (x *GetNeighborRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_gobgp_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetNeighborRequest.ProtoReflect.Descriptor instead.
+func (*GetNeighborRequest) Descriptor() ([]byte, []int) {
+	return file_gobgp_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *GetNeighborRequest) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+type AddNeighborRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Neighbor *NeighborConfig `protobuf:"bytes,1,opt,name=neighbor,proto3" json:"neighbor,omitempty"`
+}
+
+func (x *AddNeighborRequest) Reset() {
+	*x = AddNeighborRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_gobgp_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AddNeighborRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AddNeighborRequest) ProtoMessage() {}
+
+func (x *AddNeighborRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_gobgp_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AddNeighborRequest.ProtoReflect.Descriptor instead.
+func (*AddNeighborRequest) Descriptor() ([]byte, []int) {
+	return file_gobgp_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *AddNeighborRequest) GetNeighbor() *NeighborConfig {
+	if x != nil {
+		return x.Neighbor
+	}
+	return nil
+}
+
+type AddNeighborResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *AddNeighborResponse) Reset() {
+	*x = AddNeighborResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_gobgp_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AddNeighborResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AddNeighborResponse) ProtoMessage() {}
+
+func (x *AddNeighborResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_gobgp_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AddNeighborResponse.ProtoReflect.Descriptor instead.
+func (*AddNeighborResponse) Descriptor() ([]byte, []int) {
+	return file_gobgp_proto_rawDescGZIP(), []int{9}
+}
+
+type DeleteNeighborRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Address string `protobuf:"bytes,1,opt,name=address,proto3" json:"address,omitempty"`
+}
+
+func (x *DeleteNeighborRequest) Reset() {
+	*x = DeleteNeighborRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_gobgp_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DeleteNeighborRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteNeighborRequest) ProtoMessage() {}
+
+func (x *DeleteNeighborRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_gobgp_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteNeighborRequest.ProtoReflect.Descriptor instead.
+func (*DeleteNeighborRequest) Descriptor() ([]byte, []int) {
+	return file_gobgp_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *DeleteNeighborRequest) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+type DeleteNeighborResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *DeleteNeighborResponse) Reset() {
+	*x = DeleteNeighborResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_gobgp_proto_msgTypes[11]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DeleteNeighborResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteNeighborResponse) ProtoMessage() {}
+
+func (x *DeleteNeighborResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_gobgp_proto_msgTypes[11]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteNeighborResponse.ProtoReflect.Descriptor instead.
+func (*DeleteNeighborResponse) Descriptor() ([]byte, []int) {
+	return file_gobgp_proto_rawDescGZIP(), []int{11}
+}
+
+type EnableNeighborRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Address string `protobuf:"bytes,1,opt,name=address,proto3" json:"address,omitempty"`
+}
+
+func (x *EnableNeighborRequest) Reset() {
+	*x = EnableNeighborRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_gobgp_proto_msgTypes[12]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *EnableNeighborRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EnableNeighborRequest) ProtoMessage() {}
+
+func (x *EnableNeighborRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_gobgp_proto_msgTypes[12]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EnableNeighborRequest.ProtoReflect.Descriptor instead.
+func (*EnableNeighborRequest) Descriptor() ([]byte, []int) {
+	return file_gobgp_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *EnableNeighborRequest) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+type EnableNeighborResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *EnableNeighborResponse) Reset() {
+	*x = EnableNeighborResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_gobgp_proto_msgTypes[13]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *EnableNeighborResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EnableNeighborResponse) ProtoMessage() {}
+
+func (x *EnableNeighborResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_gobgp_proto_msgTypes[13]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EnableNeighborResponse.ProtoReflect.Descriptor instead.
+func (*EnableNeighborResponse) Descriptor() ([]byte, []int) {
+	return file_gobgp_proto_rawDescGZIP(), []int{13}
+}
+
+type DisableNeighborRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Address string `protobuf:"bytes,1,opt,name=address,proto3" json:"address,omitempty"`
+}
+
+func (x *DisableNeighborRequest) Reset() {
+	*x = DisableNeighborRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_gobgp_proto_msgTypes[14]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DisableNeighborRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DisableNeighborRequest) ProtoMessage() {}
+
+func (x *DisableNeighborRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_gobgp_proto_msgTypes[14]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DisableNeighborRequest.ProtoReflect.Descriptor instead.
+func (*DisableNeighborRequest) Descriptor() ([]byte, []int) {
+	return file_gobgp_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *DisableNeighborRequest) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+type DisableNeighborResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *DisableNeighborResponse) Reset() {
+	*x = DisableNeighborResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_gobgp_proto_msgTypes[15]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DisableNeighborResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DisableNeighborResponse) ProtoMessage() {}
+
+func (x *DisableNeighborResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_gobgp_proto_msgTypes[15]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DisableNeighborResponse.ProtoReflect.Descriptor instead.
+func (*DisableNeighborResponse) Descriptor() ([]byte, []int) {
+	return file_gobgp_proto_rawDescGZIP(), []int{15}
+}
+
+type ResetNeighborRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Address string `protobuf:"bytes,1,opt,name=address,proto3" json:"address,omitempty"`
+	// trueの場合はCease NotificationMessageを送信せず、
+	// AdjRibInの経路にImportPolicyを適用し直し、AdjRibOutを作り直す
+	Soft bool `protobuf:"varint,2,opt,name=soft,proto3" json:"soft,omitempty"`
+}
+
+func (x *ResetNeighborRequest) Reset() {
+	*x = ResetNeighborRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_gobgp_proto_msgTypes[16]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ResetNeighborRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResetNeighborRequest) ProtoMessage() {}
+
+func (x *ResetNeighborRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_gobgp_proto_msgTypes[16]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResetNeighborRequest.ProtoReflect.Descriptor instead.
+func (*ResetNeighborRequest) Descriptor() ([]byte, []int) {
+	return file_gobgp_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *ResetNeighborRequest) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+func (x *ResetNeighborRequest) GetSoft() bool {
+	if x != nil {
+		return x.Soft
+	}
+	return false
+}
+
+type ResetNeighborResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *ResetNeighborResponse) Reset() {
+	*x = ResetNeighborResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_gobgp_proto_msgTypes[17]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ResetNeighborResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResetNeighborResponse) ProtoMessage() {}
+
+func (x *ResetNeighborResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_gobgp_proto_msgTypes[17]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResetNeighborResponse.ProtoReflect.Descriptor instead.
+func (*ResetNeighborResponse) Descriptor() ([]byte, []int) {
+	return file_gobgp_proto_rawDescGZIP(), []int{17}
+}
+
+type Path struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Prefix  string   `protobuf:"bytes,1,opt,name=prefix,proto3" json:"prefix,omitempty"`
+	NextHop string   `protobuf:"bytes,2,opt,name=next_hop,json=nextHop,proto3" json:"next_hop,omitempty"`
+	AsPath  []uint32 `protobuf:"varint,3,rep,packed,name=as_path,json=asPath,proto3" json:"as_path,omitempty"`
+	// "IGP", "EGP", "INCOMPLETE"
+	Origin string `protobuf:"bytes,4,opt,name=origin,proto3" json:"origin,omitempty"`
+	// 受信したPeerのアドレス。自身で生成した経路は空
+	Neighbor string `protobuf:"bytes,5,opt,name=neighbor,proto3" json:"neighbor,omitempty"`
+	PathId   uint32 `protobuf:"varint,6,opt,name=path_id,json=pathId,proto3" json:"path_id,omitempty"`
+	// 最適経路かどうか。LocRibの場合のみ設定する
+	Best bool `protobuf:"varint,7,opt,name=best,proto3" json:"best,omitempty"`
+	// RPKIの検証結果。検証しない場合は空
+	Validation string `protobuf:"bytes,8,opt,name=validation,proto3" json:"validation,omitempty"`
+}
+
+func (x *Path) Reset() {
+	*x = Path{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_gobgp_proto_msgTypes[18]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Path) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Path) ProtoMessage() {}
+
+func (x *Path) ProtoReflect() protoreflect.Message {
+	mi := &file_gobgp_proto_msgTypes[18]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Path.ProtoReflect.Descriptor instead.
+func (*Path) Descriptor() ([]byte, []int) {
+	return file_gobgp_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *Path) GetPrefix() string {
+	if x != nil {
+		return x.Prefix
+	}
+	return ""
+}
+
+func (x *Path) GetNextHop() string {
+	if x != nil {
+		return x.NextHop
+	}
+	return ""
+}
+
+func (x *Path) GetAsPath() []uint32 {
+	if x != nil {
+		return x.AsPath
+	}
+	return nil
+}
+
+func (x *Path) GetOrigin() string {
+	if x != nil {
+		return x.Origin
+	}
+	return ""
+}
+
+func (x *Path) GetNeighbor() string {
+	if x != nil {
+		return x.Neighbor
+	}
+	return ""
+}
+
+func (x *Path) GetPathId() uint32 {
+	if x != nil {
+		return x.PathId
+	}
+	return 0
+}
+
+func (x *Path) GetBest() bool {
+	if x != nil {
+		return x.Best
+	}
+	return false
+}
+
+func (x *Path) GetValidation() string {
+	if x != nil {
+		return x.Validation
+	}
+	return ""
+}
+
+type ListPathRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	TableType TableType `protobuf:"varint,1,opt,name=table_type,json=tableType,proto3,enum=gobgpapi.TableType" json:"table_type,omitempty"`
+	// AdjRibIn / AdjRibOut の場合に指定する
+	Neighbor string `protobuf:"bytes,2,opt,name=neighbor,proto3" json:"neighbor,omitempty"`
+	// 指定しない場合はIPv4 Unicast
+	Family *Family `protobuf:"bytes,3,opt,name=family,proto3" json:"family,omitempty"`
+	// 指定したプレフィックスに一致する経路に絞り込む
+	Prefixes []string `protobuf:"bytes,4,rep,name=prefixes,proto3" json:"prefixes,omitempty"`
+	// prefixesに含まれるより長いプレフィックスの経路も対象にする
+	OrLonger bool `protobuf:"varint,5,opt,name=or_longer,json=orLonger,proto3" json:"or_longer,omitempty"`
+	// AS Pathの正規表現で絞り込む
+	AsPath string `protobuf:"bytes,6,opt,name=as_path,json=asPath,proto3" json:"as_path,omitempty"`
+}
+
+func (x *ListPathRequest) Reset() {
+	*x = ListPathRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_gobgp_proto_msgTypes[19]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ListPathRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListPathRequest) ProtoMessage() {}
+
+func (x *ListPathRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_gobgp_proto_msgTypes[19]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListPathRequest.ProtoReflect.Descriptor instead.
+func (*ListPathRequest) Descriptor() ([]byte, []int) {
+	return file_gobgp_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *ListPathRequest) GetTableType() TableType {
+	if x != nil {
+		return x.TableType
+	}
+	return TableType_LOC_RIB
+}
+
+func (x *ListPathRequest) GetNeighbor() string {
+	if x != nil {
+		return x.Neighbor
+	}
+	return ""
+}
+
+func (x *ListPathRequest) GetFamily() *Family {
+	if x != nil {
+		return x.Family
+	}
+	return nil
+}
+
+func (x *ListPathRequest) GetPrefixes() []string {
+	if x != nil {
+		return x.Prefixes
+	}
+	return nil
+}
+
+func (x *ListPathRequest) GetOrLonger() bool {
+	if x != nil {
+		return x.OrLonger
+	}
+	return false
+}
+
+func (x *ListPathRequest) GetAsPath() string {
+	if x != nil {
+		return x.AsPath
+	}
+	return ""
+}
+
+type ListPathResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Paths []*Path `protobuf:"bytes,1,rep,name=paths,proto3" json:"paths,omitempty"`
+}
+
+func (x *ListPathResponse) Reset() {
+	*x = ListPathResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_gobgp_proto_msgTypes[20]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ListPathResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListPathResponse) ProtoMessage() {}
+
+func (x *ListPathResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_gobgp_proto_msgTypes[20]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListPathResponse.ProtoReflect.Descriptor instead.
+func (*ListPathResponse) Descriptor() ([]byte, []int) {
+	return file_gobgp_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *ListPathResponse) GetPaths() []*Path {
+	if x != nil {
+		return x.Paths
+	}
+	return nil
+}
+
+type AddPathRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Prefix string `protobuf:"bytes,1,opt,name=prefix,proto3" json:"prefix,omitempty"`
+}
+
+func (x *AddPathRequest) Reset() {
+	*x = AddPathRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_gobgp_proto_msgTypes[21]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AddPathRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AddPathRequest) ProtoMessage() {}
+
+func (x *AddPathRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_gobgp_proto_msgTypes[21]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AddPathRequest.ProtoReflect.Descriptor instead.
+func (*AddPathRequest) Descriptor() ([]byte, []int) {
+	return file_gobgp_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *AddPathRequest) GetPrefix() string {
+	if x != nil {
+		return x.Prefix
+	}
+	return ""
+}
+
+type AddPathResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *AddPathResponse) Reset() {
+	*x = AddPathResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_gobgp_proto_msgTypes[22]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AddPathResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AddPathResponse) ProtoMessage() {}
+
+func (x *AddPathResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_gobgp_proto_msgTypes[22]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AddPathResponse.ProtoReflect.Descriptor instead.
+func (*AddPathResponse) Descriptor() ([]byte, []int) {
+	return file_gobgp_proto_rawDescGZIP(), []int{22}
+}
+
+type DeletePathRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Prefix string `protobuf:"bytes,1,opt,name=prefix,proto3" json:"prefix,omitempty"`
+}
+
+func (x *DeletePathRequest) Reset() {
+	*x = DeletePathRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_gobgp_proto_msgTypes[23]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DeletePathRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeletePathRequest) ProtoMessage() {}
+
+func (x *DeletePathRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_gobgp_proto_msgTypes[23]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeletePathRequest.ProtoReflect.Descriptor instead.
+func (*DeletePathRequest) Descriptor() ([]byte, []int) {
+	return file_gobgp_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *DeletePathRequest) GetPrefix() string {
+	if x != nil {
+		return x.Prefix
+	}
+	return ""
+}
+
+type DeletePathResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *DeletePathResponse) Reset() {
+	*x = DeletePathResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_gobgp_proto_msgTypes[24]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DeletePathResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeletePathResponse) ProtoMessage() {}
+
+func (x *DeletePathResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_gobgp_proto_msgTypes[24]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeletePathResponse.ProtoReflect.Descriptor instead.
+func (*DeletePathResponse) Descriptor() ([]byte, []int) {
+	return file_gobgp_proto_rawDescGZIP(), []int{24}
+}
+
+type ReloadConfigRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *ReloadConfigRequest) Reset() {
+	*x = ReloadConfigRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_gobgp_proto_msgTypes[25]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ReloadConfigRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReloadConfigRequest) ProtoMessage() {}
+
+func (x *ReloadConfigRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_gobgp_proto_msgTypes[25]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReloadConfigRequest.ProtoReflect.Descriptor instead.
+func (*ReloadConfigRequest) Descriptor() ([]byte, []int) {
+	return file_gobgp_proto_rawDescGZIP(), []int{25}
+}
+
+type ReloadConfigResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// 反映した差分
+	Added   []string `protobuf:"bytes,1,rep,name=added,proto3" json:"added,omitempty"`
+	Removed []string `protobuf:"bytes,2,rep,name=removed,proto3" json:"removed,omitempty"`
+	Changed []string `protobuf:"bytes,3,rep,name=changed,proto3" json:"changed,omitempty"`
+	Global  bool     `protobuf:"varint,4,opt,name=global,proto3" json:"global,omitempty"`
+}
+
+func (x *ReloadConfigResponse) Reset() {
+	*x = ReloadConfigResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_gobgp_proto_msgTypes[26]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ReloadConfigResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReloadConfigResponse) ProtoMessage() {}
+
+func (x *ReloadConfigResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_gobgp_proto_msgTypes[26]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReloadConfigResponse.ProtoReflect.Descriptor instead.
+func (*ReloadConfigResponse) Descriptor() ([]byte, []int) {
+	return file_gobgp_proto_rawDescGZIP(), []int{26}
+}
+
+func (x *ReloadConfigResponse) GetAdded() []string {
+	if x != nil {
+		return x.Added
+	}
+	return nil
+}
+
+func (x *ReloadConfigResponse) GetRemoved() []string {
+	if x != nil {
+		return x.Removed
+	}
+	return nil
+}
+
+func (x *ReloadConfigResponse) GetChanged() []string {
+	if x != nil {
+		return x.Changed
+	}
+	return nil
+}
+
+func (x *ReloadConfigResponse) GetGlobal() bool {
+	if x != nil {
+		return x.Global
+	}
+	return false
+}
+
+type WatchEventRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// trueにしたイベントだけを通知する
+	Peer     bool `protobuf:"varint,1,opt,name=peer,proto3" json:"peer,omitempty"`
+	BestPath bool `protobuf:"varint,2,opt,name=best_path,json=bestPath,proto3" json:"best_path,omitempty"`
+}
+
+func (x *WatchEventRequest) Reset() {
+	*x = WatchEventRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_gobgp_proto_msgTypes[27]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *WatchEventRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchEventRequest) ProtoMessage() {}
+
+func (x *WatchEventRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_gobgp_proto_msgTypes[27]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchEventRequest.ProtoReflect.Descriptor instead.
+func (*WatchEventRequest) Descriptor() ([]byte, []int) {
+	return file_gobgp_proto_rawDescGZIP(), []int{27}
+}
+
+func (x *WatchEventRequest) GetPeer() bool {
+	if x != nil {
+		return x.Peer
+	}
+	return false
+}
+
+func (x *WatchEventRequest) GetBestPath() bool {
+	if x != nil {
+		return x.BestPath
+	}
+	return false
+}
+
+type PeerEvent struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Address  string       `protobuf:"bytes,1,opt,name=address,proto3" json:"address,omitempty"`
+	OldState SessionState `protobuf:"varint,2,opt,name=old_state,json=oldState,proto3,enum=gobgpapi.SessionState" json:"old_state,omitempty"`
+	NewState SessionState `protobuf:"varint,3,opt,name=new_state,json=newState,proto3,enum=gobgpapi.SessionState" json:"new_state,omitempty"`
+}
+
+func (x *PeerEvent) Reset() {
+	*x = PeerEvent{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_gobgp_proto_msgTypes[28]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PeerEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PeerEvent) ProtoMessage() {}
+
+func (x *PeerEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_gobgp_proto_msgTypes[28]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PeerEvent.ProtoReflect.Descriptor instead.
+func (*PeerEvent) Descriptor() ([]byte, []int) {
+	return file_gobgp_proto_rawDescGZIP(), []int{28}
+}
+
+func (x *PeerEvent) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+func (x *PeerEvent) GetOldState() SessionState {
+	if x != nil {
+		return x.OldState
+	}
+	return SessionState_IDLE
+}
+
+func (x *PeerEvent) GetNewState() SessionState {
+	if x != nil {
+		return x.NewState
+	}
+	return SessionState_IDLE
+}
+
+type BestPathEvent struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// 最適経路。最適経路がなくなった場合はwithdrawnがtrueで、pathにはプレフィックスのみ設定する
+	Path      *Path `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	Withdrawn bool  `protobuf:"varint,2,opt,name=withdrawn,proto3" json:"withdrawn,omitempty"`
+}
+
+func (x *BestPathEvent) Reset() {
+	*x = BestPathEvent{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_gobgp_proto_msgTypes[29]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BestPathEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BestPathEvent) ProtoMessage() {}
+
+func (x *BestPathEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_gobgp_proto_msgTypes[29]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BestPathEvent.ProtoReflect.Descriptor instead.
+func (*BestPathEvent) Descriptor() ([]byte, []int) {
+	return file_gobgp_proto_rawDescGZIP(), []int{29}
+}
+
+func (x *BestPathEvent) GetPath() *Path {
+	if x != nil {
+		return x.Path
+	}
+	return nil
+}
+
+func (x *BestPathEvent) GetWithdrawn() bool {
+	if x != nil {
+		return x.Withdrawn
+	}
+	return false
+}
+
+type WatchEventResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Types that are assignable to Event:
+	//	*WatchEventResponse_Peer
+	//	*WatchEventResponse_BestPath
+	Event isWatchEventResponse_Event `protobuf_oneof:"event"`
+}
+
+func (x *WatchEventResponse) Reset() {
+	*x = WatchEventResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_gobgp_proto_msgTypes[30]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *WatchEventResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchEventResponse) ProtoMessage() {}
+
+func (x *WatchEventResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_gobgp_proto_msgTypes[30]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchEventResponse.ProtoReflect.Descriptor instead.
+func (*WatchEventResponse) Descriptor() ([]byte, []int) {
+	return file_gobgp_proto_rawDescGZIP(), []int{30}
+}
+
+func (m *WatchEventResponse) GetEvent() isWatchEventResponse_Event {
+	if m != nil {
+		return m.Event
+	}
+	return nil
+}
+
+func (x *WatchEventResponse) GetPeer() *PeerEvent {
+	if x, ok := x.GetEvent().(*WatchEventResponse_Peer); ok {
+		return x.Peer
+	}
+	return nil
+}
+
+func (x *WatchEventResponse) GetBestPath() *BestPathEvent {
+	if x, ok := x.GetEvent().(*WatchEventResponse_BestPath); ok {
+		return x.BestPath
+	}
+	return nil
+}
+
+type isWatchEventResponse_Event interface {
+	isWatchEventResponse_Event()
+}
+
+type WatchEventResponse_Peer struct {
+	Peer *PeerEvent `protobuf:"bytes,1,opt,name=peer,proto3,oneof"`
+}
+
+type WatchEventResponse_BestPath struct {
+	BestPath *BestPathEvent `protobuf:"bytes,2,opt,name=best_path,json=bestPath,proto3,oneof"`
+}
+
+func (*WatchEventResponse_Peer) isWatchEventResponse_Event() {}
+
+func (*WatchEventResponse_BestPath) isWatchEventResponse_Event() {}
+
+var File_gobgp_proto protoreflect.FileDescriptor
+
+var file_gobgp_proto_rawDesc = []byte{
+	0x0a, 0x0b, 0x67, 0x6f, 0x62, 0x67, 0x70, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x08, 0x67,
+	0x6f, 0x62, 0x67, 0x70, 0x61, 0x70, 0x69, 0x22, 0x2e, 0x0a, 0x06, 0x46, 0x61, 0x6d, 0x69, 0x6c,
+	0x79, 0x12, 0x10, 0x0a, 0x03, 0x61, 0x66, 0x69, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x03,
+	0x61, 0x66, 0x69, 0x12, 0x12, 0x0a, 0x04, 0x73, 0x61, 0x66, 0x69, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x0d, 0x52, 0x04, 0x73, 0x61, 0x66, 0x69, 0x22, 0x95, 0x02, 0x0a, 0x0e, 0x4e, 0x65, 0x69, 0x67,
+	0x68, 0x62, 0x6f, 0x72, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x12, 0x18, 0x0a, 0x07, 0x61, 0x64,
+	0x64, 0x72, 0x65, 0x73, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x61, 0x64, 0x64,
+	0x72, 0x65, 0x73, 0x73, 0x12, 0x1b, 0x0a, 0x09, 0x72, 0x65, 0x6d, 0x6f, 0x74, 0x65, 0x5f, 0x61,
+	0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x08, 0x72, 0x65, 0x6d, 0x6f, 0x74, 0x65, 0x41,
+	0x73, 0x12, 0x23, 0x0a, 0x0d, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x5f, 0x61, 0x64, 0x64, 0x72, 0x65,
+	0x73, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0c, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x41,
+	0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x12, 0x22, 0x0a, 0x04, 0x6d, 0x6f, 0x64, 0x65, 0x18, 0x04,
+	0x20, 0x01, 0x28, 0x0e, 0x32, 0x0e, 0x2e, 0x67, 0x6f, 0x62, 0x67, 0x70, 0x61, 0x70, 0x69, 0x2e,
+	0x4d, 0x6f, 0x64, 0x65, 0x52, 0x04, 0x6d, 0x6f, 0x64, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x70, 0x6f,
+	0x72, 0x74, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x04, 0x70, 0x6f, 0x72, 0x74, 0x12, 0x1b,
+	0x0a, 0x09, 0x68, 0x6f, 0x6c, 0x64, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x06, 0x20, 0x01, 0x28,
+	0x0d, 0x52, 0x08, 0x68, 0x6f, 0x6c, 0x64, 0x54, 0x69, 0x6d, 0x65, 0x12, 0x2d, 0x0a, 0x12, 0x6b,
+	0x65, 0x65, 0x70, 0x61, 0x6c, 0x69, 0x76, 0x65, 0x5f, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x76, 0x61,
+	0x6c, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x11, 0x6b, 0x65, 0x65, 0x70, 0x61, 0x6c, 0x69,
+	0x76, 0x65, 0x49, 0x6e, 0x74, 0x65, 0x72, 0x76, 0x61, 0x6c, 0x12, 0x23, 0x0a, 0x0d, 0x63, 0x6f,
+	0x6e, 0x6e, 0x65, 0x63, 0x74, 0x5f, 0x72, 0x65, 0x74, 0x72, 0x79, 0x18, 0x08, 0x20, 0x01, 0x28,
+	0x0d, 0x52, 0x0c, 0x63, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x52, 0x65, 0x74, 0x72, 0x79, 0x22,
+	0x95, 0x01, 0x0a, 0x0f, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x43, 0x6f, 0x75, 0x6e, 0x74,
+	0x65, 0x72, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x6f, 0x70, 0x65, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x04, 0x52, 0x04, 0x6f, 0x70, 0x65, 0x6e, 0x12, 0x16, 0x0a, 0x06, 0x75, 0x70, 0x64, 0x61, 0x74,
+	0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x06, 0x75, 0x70, 0x64, 0x61, 0x74, 0x65, 0x12,
+	0x22, 0x0a, 0x0c, 0x6e, 0x6f, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18,
+	0x03, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0c, 0x6e, 0x6f, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74,
+	0x69, 0x6f, 0x6e, 0x12, 0x1c, 0x0a, 0x09, 0x6b, 0x65, 0x65, 0x70, 0x61, 0x6c, 0x69, 0x76, 0x65,
+	0x18, 0x04, 0x20, 0x01, 0x28, 0x04, 0x52, 0x09, 0x6b, 0x65, 0x65, 0x70, 0x61, 0x6c, 0x69, 0x76,
+	0x65, 0x12, 0x14, 0x0a, 0x05, 0x74, 0x6f, 0x74, 0x61, 0x6c, 0x18, 0x05, 0x20, 0x01, 0x28, 0x04,
+	0x52, 0x05, 0x74, 0x6f, 0x74, 0x61, 0x6c, 0x22, 0xa6, 0x03, 0x0a, 0x0d, 0x4e, 0x65, 0x69, 0x67,
+	0x68, 0x62, 0x6f, 0x72, 0x53, 0x74, 0x61, 0x74, 0x65, 0x12, 0x3b, 0x0a, 0x0d, 0x73, 0x65, 0x73,
+	0x73, 0x69, 0x6f, 0x6e, 0x5f, 0x73, 0x74, 0x61, 0x74, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e,
+	0x32, 0x16, 0x2e, 0x67, 0x6f, 0x62, 0x67, 0x70, 0x61, 0x70, 0x69, 0x2e, 0x53, 0x65, 0x73, 0x73,
+	0x69, 0x6f, 0x6e, 0x53, 0x74, 0x61, 0x74, 0x65, 0x52, 0x0c, 0x73, 0x65, 0x73, 0x73, 0x69, 0x6f,
+	0x6e, 0x53, 0x74, 0x61, 0x74, 0x65, 0x12, 0x1d, 0x0a, 0x0a, 0x61, 0x64, 0x6d, 0x69, 0x6e, 0x5f,
+	0x64, 0x6f, 0x77, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x08, 0x52, 0x09, 0x61, 0x64, 0x6d, 0x69,
+	0x6e, 0x44, 0x6f, 0x77, 0x6e, 0x12, 0x16, 0x0a, 0x06, 0x75, 0x70, 0x74, 0x69, 0x6d, 0x65, 0x18,
+	0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x06, 0x75, 0x70, 0x74, 0x69, 0x6d, 0x65, 0x12, 0x30, 0x0a,
+	0x14, 0x6e, 0x65, 0x67, 0x6f, 0x74, 0x69, 0x61, 0x74, 0x65, 0x64, 0x5f, 0x68, 0x6f, 0x6c, 0x64,
+	0x5f, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x12, 0x6e, 0x65, 0x67,
+	0x6f, 0x74, 0x69, 0x61, 0x74, 0x65, 0x64, 0x48, 0x6f, 0x6c, 0x64, 0x54, 0x69, 0x6d, 0x65, 0x12,
+	0x2d, 0x0a, 0x04, 0x73, 0x65, 0x6e, 0x74, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e,
+	0x67, 0x6f, 0x62, 0x67, 0x70, 0x61, 0x70, 0x69, 0x2e, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65,
+	0x43, 0x6f, 0x75, 0x6e, 0x74, 0x65, 0x72, 0x73, 0x52, 0x04, 0x73, 0x65, 0x6e, 0x74, 0x12, 0x35,
+	0x0a, 0x08, 0x72, 0x65, 0x63, 0x65, 0x69, 0x76, 0x65, 0x64, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x19, 0x2e, 0x67, 0x6f, 0x62, 0x67, 0x70, 0x61, 0x70, 0x69, 0x2e, 0x4d, 0x65, 0x73, 0x73,
+	0x61, 0x67, 0x65, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x65, 0x72, 0x73, 0x52, 0x08, 0x72, 0x65, 0x63,
+	0x65, 0x69, 0x76, 0x65, 0x64, 0x12, 0x2b, 0x0a, 0x11, 0x72, 0x65, 0x63, 0x65, 0x69, 0x76, 0x65,
+	0x64, 0x5f, 0x70, 0x72, 0x65, 0x66, 0x69, 0x78, 0x65, 0x73, 0x18, 0x07, 0x20, 0x01, 0x28, 0x04,
+	0x52, 0x10, 0x72, 0x65, 0x63, 0x65, 0x69, 0x76, 0x65, 0x64, 0x50, 0x72, 0x65, 0x66, 0x69, 0x78,
+	0x65, 0x73, 0x12, 0x2b, 0x0a, 0x11, 0x61, 0x63, 0x63, 0x65, 0x70, 0x74, 0x65, 0x64, 0x5f, 0x70,
+	0x72, 0x65, 0x66, 0x69, 0x78, 0x65, 0x73, 0x18, 0x08, 0x20, 0x01, 0x28, 0x04, 0x52, 0x10, 0x61,
+	0x63, 0x63, 0x65, 0x70, 0x74, 0x65, 0x64, 0x50, 0x72, 0x65, 0x66, 0x69, 0x78, 0x65, 0x73, 0x12,
+	0x2f, 0x0a, 0x13, 0x61, 0x64, 0x76, 0x65, 0x72, 0x74, 0x69, 0x73, 0x65, 0x64, 0x5f, 0x70, 0x72,
+	0x65, 0x66, 0x69, 0x78, 0x65, 0x73, 0x18, 0x09, 0x20, 0x01, 0x28, 0x04, 0x52, 0x12, 0x61, 0x64,
+	0x76, 0x65, 0x72, 0x74, 0x69, 0x73, 0x65, 0x64, 0x50, 0x72, 0x65, 0x66, 0x69, 0x78, 0x65, 0x73,
+	0x22, 0x6b, 0x0a, 0x08, 0x4e, 0x65, 0x69, 0x67, 0x68, 0x62, 0x6f, 0x72, 0x12, 0x30, 0x0a, 0x06,
+	0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x67,
+	0x6f, 0x62, 0x67, 0x70, 0x61, 0x70, 0x69, 0x2e, 0x4e, 0x65, 0x69, 0x67, 0x68, 0x62, 0x6f, 0x72,
+	0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x52, 0x06, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x12, 0x2d,
+	0x0a, 0x05, 0x73, 0x74, 0x61, 0x74, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x17, 0x2e,
+	0x67, 0x6f, 0x62, 0x67, 0x70, 0x61, 0x70, 0x69, 0x2e, 0x4e, 0x65, 0x69, 0x67, 0x68, 0x62, 0x6f,
+	0x72, 0x53, 0x74, 0x61, 0x74, 0x65, 0x52, 0x05, 0x73, 0x74, 0x61, 0x74, 0x65, 0x22, 0x16, 0x0a,
+	0x14, 0x4c, 0x69, 0x73, 0x74, 0x4e, 0x65, 0x69, 0x67, 0x68, 0x62, 0x6f, 0x72, 0x73, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x22, 0x49, 0x0a, 0x15, 0x4c, 0x69, 0x73, 0x74, 0x4e, 0x65, 0x69,
+	0x67, 0x68, 0x62, 0x6f, 0x72, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x30,
+	0x0a, 0x09, 0x6e, 0x65, 0x69, 0x67, 0x68, 0x62, 0x6f, 0x72, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28,
+	0x0b, 0x32, 0x12, 0x2e, 0x67, 0x6f, 0x62, 0x67, 0x70, 0x61, 0x70, 0x69, 0x2e, 0x4e, 0x65, 0x69,
+	0x67, 0x68, 0x62, 0x6f, 0x72, 0x52, 0x09, 0x6e, 0x65, 0x69, 0x67, 0x68, 0x62, 0x6f, 0x72, 0x73,
+	0x22, 0x2e, 0x0a, 0x12, 0x47, 0x65, 0x74, 0x4e, 0x65, 0x69, 0x67, 0x68, 0x62, 0x6f, 0x72, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x18, 0x0a, 0x07, 0x61, 0x64, 0x64, 0x72, 0x65, 0x73,
+	0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x61, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73,
+	0x22, 0x4a, 0x0a, 0x12, 0x41, 0x64, 0x64, 0x4e, 0x65, 0x69, 0x67, 0x68, 0x62, 0x6f, 0x72, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x34, 0x0a, 0x08, 0x6e, 0x65, 0x69, 0x67, 0x68, 0x62,
+	0x6f, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x67, 0x6f, 0x62, 0x67, 0x70,
+	0x61, 0x70, 0x69, 0x2e, 0x4e, 0x65, 0x69, 0x67, 0x68, 0x62, 0x6f, 0x72, 0x43, 0x6f, 0x6e, 0x66,
+	0x69, 0x67, 0x52, 0x08, 0x6e, 0x65, 0x69, 0x67, 0x68, 0x62, 0x6f, 0x72, 0x22, 0x15, 0x0a, 0x13,
+	0x41, 0x64, 0x64, 0x4e, 0x65, 0x69, 0x67, 0x68, 0x62, 0x6f, 0x72, 0x52, 0x65, 0x73, 0x70, 0x6f,
+	0x6e, 0x73, 0x65, 0x22, 0x31, 0x0a, 0x15, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x4e, 0x65, 0x69,
+	0x67, 0x68, 0x62, 0x6f, 0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x18, 0x0a, 0x07,
+	0x61, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x61,
+	0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x22, 0x18, 0x0a, 0x16, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65,
+	0x4e, 0x65, 0x69, 0x67, 0x68, 0x62, 0x6f, 0x72, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
+	0x22, 0x31, 0x0a, 0x15, 0x45, 0x6e, 0x61, 0x62, 0x6c, 0x65, 0x4e, 0x65, 0x69, 0x67, 0x68, 0x62,
+	0x6f, 0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x18, 0x0a, 0x07, 0x61, 0x64, 0x64,
+	0x72, 0x65, 0x73, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x61, 0x64, 0x64, 0x72,
+	0x65, 0x73, 0x73, 0x22, 0x18, 0x0a, 0x16, 0x45, 0x6e, 0x61, 0x62, 0x6c, 0x65, 0x4e, 0x65, 0x69,
+	0x67, 0x68, 0x62, 0x6f, 0x72, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x32, 0x0a,
+	0x16, 0x44, 0x69, 0x73, 0x61, 0x62, 0x6c, 0x65, 0x4e, 0x65, 0x69, 0x67, 0x68, 0x62, 0x6f, 0x72,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x18, 0x0a, 0x07, 0x61, 0x64, 0x64, 0x72, 0x65,
+	0x73, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x61, 0x64, 0x64, 0x72, 0x65, 0x73,
+	0x73, 0x22, 0x19, 0x0a, 0x17, 0x44, 0x69, 0x73, 0x61, 0x62, 0x6c, 0x65, 0x4e, 0x65, 0x69, 0x67,
+	0x68, 0x62, 0x6f, 0x72, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x44, 0x0a, 0x14,
+	0x52, 0x65, 0x73, 0x65, 0x74, 0x4e, 0x65, 0x69, 0x67, 0x68, 0x62, 0x6f, 0x72, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x12, 0x18, 0x0a, 0x07, 0x61, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x61, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x12, 0x12,
+	0x0a, 0x04, 0x73, 0x6f, 0x66, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x08, 0x52, 0x04, 0x73, 0x6f,
+	0x66, 0x74, 0x22, 0x17, 0x0a, 0x15, 0x52, 0x65, 0x73, 0x65, 0x74, 0x4e, 0x65, 0x69, 0x67, 0x68,
+	0x62, 0x6f, 0x72, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0xd3, 0x01, 0x0a, 0x04,
+	0x50, 0x61, 0x74, 0x68, 0x12, 0x16, 0x0a, 0x06, 0x70, 0x72, 0x65, 0x66, 0x69, 0x78, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x70, 0x72, 0x65, 0x66, 0x69, 0x78, 0x12, 0x19, 0x0a, 0x08,
+	0x6e, 0x65, 0x78, 0x74, 0x5f, 0x68, 0x6f, 0x70, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07,
+	0x6e, 0x65, 0x78, 0x74, 0x48, 0x6f, 0x70, 0x12, 0x17, 0x0a, 0x07, 0x61, 0x73, 0x5f, 0x70, 0x61,
+	0x74, 0x68, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0d, 0x52, 0x06, 0x61, 0x73, 0x50, 0x61, 0x74, 0x68,
+	0x12, 0x16, 0x0a, 0x06, 0x6f, 0x72, 0x69, 0x67, 0x69, 0x6e, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x06, 0x6f, 0x72, 0x69, 0x67, 0x69, 0x6e, 0x12, 0x1a, 0x0a, 0x08, 0x6e, 0x65, 0x69, 0x67,
+	0x68, 0x62, 0x6f, 0x72, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x6e, 0x65, 0x69, 0x67,
+	0x68, 0x62, 0x6f, 0x72, 0x12, 0x17, 0x0a, 0x07, 0x70, 0x61, 0x74, 0x68, 0x5f, 0x69, 0x64, 0x18,
+	0x06, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x06, 0x70, 0x61, 0x74, 0x68, 0x49, 0x64, 0x12, 0x12, 0x0a,
+	0x04, 0x62, 0x65, 0x73, 0x74, 0x18, 0x07, 0x20, 0x01, 0x28, 0x08, 0x52, 0x04, 0x62, 0x65, 0x73,
+	0x74, 0x12, 0x1e, 0x0a, 0x0a, 0x76, 0x61, 0x6c, 0x69, 0x64, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18,
+	0x08, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x76, 0x61, 0x6c, 0x69, 0x64, 0x61, 0x74, 0x69, 0x6f,
+	0x6e, 0x22, 0xdd, 0x01, 0x0a, 0x0f, 0x4c, 0x69, 0x73, 0x74, 0x50, 0x61, 0x74, 0x68, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x32, 0x0a, 0x0a, 0x74, 0x61, 0x62, 0x6c, 0x65, 0x5f, 0x74,
+	0x79, 0x70, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x13, 0x2e, 0x67, 0x6f, 0x62, 0x67,
+	0x70, 0x61, 0x70, 0x69, 0x2e, 0x54, 0x61, 0x62, 0x6c, 0x65, 0x54, 0x79, 0x70, 0x65, 0x52, 0x09,
+	0x74, 0x61, 0x62, 0x6c, 0x65, 0x54, 0x79, 0x70, 0x65, 0x12, 0x1a, 0x0a, 0x08, 0x6e, 0x65, 0x69,
+	0x67, 0x68, 0x62, 0x6f, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x6e, 0x65, 0x69,
+	0x67, 0x68, 0x62, 0x6f, 0x72, 0x12, 0x28, 0x0a, 0x06, 0x66, 0x61, 0x6d, 0x69, 0x6c, 0x79, 0x18,
+	0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x67, 0x6f, 0x62, 0x67, 0x70, 0x61, 0x70, 0x69,
+	0x2e, 0x46, 0x61, 0x6d, 0x69, 0x6c, 0x79, 0x52, 0x06, 0x66, 0x61, 0x6d, 0x69, 0x6c, 0x79, 0x12,
+	0x1a, 0x0a, 0x08, 0x70, 0x72, 0x65, 0x66, 0x69, 0x78, 0x65, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28,
+	0x09, 0x52, 0x08, 0x70, 0x72, 0x65, 0x66, 0x69, 0x78, 0x65, 0x73, 0x12, 0x1b, 0x0a, 0x09, 0x6f,
+	0x72, 0x5f, 0x6c, 0x6f, 0x6e, 0x67, 0x65, 0x72, 0x18, 0x05, 0x20, 0x01, 0x28, 0x08, 0x52, 0x08,
+	0x6f, 0x72, 0x4c, 0x6f, 0x6e, 0x67, 0x65, 0x72, 0x12, 0x17, 0x0a, 0x07, 0x61, 0x73, 0x5f, 0x70,
+	0x61, 0x74, 0x68, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x61, 0x73, 0x50, 0x61, 0x74,
+	0x68, 0x22, 0x38, 0x0a, 0x10, 0x4c, 0x69, 0x73, 0x74, 0x50, 0x61, 0x74, 0x68, 0x52, 0x65, 0x73,
+	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x24, 0x0a, 0x05, 0x70, 0x61, 0x74, 0x68, 0x73, 0x18, 0x01,
+	0x20, 0x03, 0x28, 0x0b, 0x32, 0x0e, 0x2e, 0x67, 0x6f, 0x62, 0x67, 0x70, 0x61, 0x70, 0x69, 0x2e,
+	0x50, 0x61, 0x74, 0x68, 0x52, 0x05, 0x70, 0x61, 0x74, 0x68, 0x73, 0x22, 0x28, 0x0a, 0x0e, 0x41,
+	0x64, 0x64, 0x50, 0x61, 0x74, 0x68, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x16, 0x0a,
+	0x06, 0x70, 0x72, 0x65, 0x66, 0x69, 0x78, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x70,
+	0x72, 0x65, 0x66, 0x69, 0x78, 0x22, 0x11, 0x0a, 0x0f, 0x41, 0x64, 0x64, 0x50, 0x61, 0x74, 0x68,
+	0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x2b, 0x0a, 0x11, 0x44, 0x65, 0x6c, 0x65,
+	0x74, 0x65, 0x50, 0x61, 0x74, 0x68, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x16, 0x0a,
+	0x06, 0x70, 0x72, 0x65, 0x66, 0x69, 0x78, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x70,
+	0x72, 0x65, 0x66, 0x69, 0x78, 0x22, 0x14, 0x0a, 0x12, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x50,
+	0x61, 0x74, 0x68, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x15, 0x0a, 0x13, 0x52,
+	0x65, 0x6c, 0x6f, 0x61, 0x64, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x22, 0x78, 0x0a, 0x14, 0x52, 0x65, 0x6c, 0x6f, 0x61, 0x64, 0x43, 0x6f, 0x6e, 0x66,
+	0x69, 0x67, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x61, 0x64,
+	0x64, 0x65, 0x64, 0x18, 0x01, 0x20, 0x03, 0x28, 0x09, 0x52, 0x05, 0x61, 0x64, 0x64, 0x65, 0x64,
+	0x12, 0x18, 0x0a, 0x07, 0x72, 0x65, 0x6d, 0x6f, 0x76, 0x65, 0x64, 0x18, 0x02, 0x20, 0x03, 0x28,
+	0x09, 0x52, 0x07, 0x72, 0x65, 0x6d, 0x6f, 0x76, 0x65, 0x64, 0x12, 0x18, 0x0a, 0x07, 0x63, 0x68,
+	0x61, 0x6e, 0x67, 0x65, 0x64, 0x18, 0x03, 0x20, 0x03, 0x28, 0x09, 0x52, 0x07, 0x63, 0x68, 0x61,
+	0x6e, 0x67, 0x65, 0x64, 0x12, 0x16, 0x0a, 0x06, 0x67, 0x6c, 0x6f, 0x62, 0x61, 0x6c, 0x18, 0x04,
+	0x20, 0x01, 0x28, 0x08, 0x52, 0x06, 0x67, 0x6c, 0x6f, 0x62, 0x61, 0x6c, 0x22, 0x44, 0x0a, 0x11,
+	0x57, 0x61, 0x74, 0x63, 0x68, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x12, 0x12, 0x0a, 0x04, 0x70, 0x65, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08, 0x52,
+	0x04, 0x70, 0x65, 0x65, 0x72, 0x12, 0x1b, 0x0a, 0x09, 0x62, 0x65, 0x73, 0x74, 0x5f, 0x70, 0x61,
+	0x74, 0x68, 0x18, 0x02, 0x20, 0x01, 0x28, 0x08, 0x52, 0x08, 0x62, 0x65, 0x73, 0x74, 0x50, 0x61,
+	0x74, 0x68, 0x22, 0x8f, 0x01, 0x0a, 0x09, 0x50, 0x65, 0x65, 0x72, 0x45, 0x76, 0x65, 0x6e, 0x74,
+	0x12, 0x18, 0x0a, 0x07, 0x61, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x07, 0x61, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x12, 0x33, 0x0a, 0x09, 0x6f, 0x6c,
+	0x64, 0x5f, 0x73, 0x74, 0x61, 0x74, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x16, 0x2e,
+	0x67, 0x6f, 0x62, 0x67, 0x70, 0x61, 0x70, 0x69, 0x2e, 0x53, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e,
+	0x53, 0x74, 0x61, 0x74, 0x65, 0x52, 0x08, 0x6f, 0x6c, 0x64, 0x53, 0x74, 0x61, 0x74, 0x65, 0x12,
+	0x33, 0x0a, 0x09, 0x6e, 0x65, 0x77, 0x5f, 0x73, 0x74, 0x61, 0x74, 0x65, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x0e, 0x32, 0x16, 0x2e, 0x67, 0x6f, 0x62, 0x67, 0x70, 0x61, 0x70, 0x69, 0x2e, 0x53, 0x65,
+	0x73, 0x73, 0x69, 0x6f, 0x6e, 0x53, 0x74, 0x61, 0x74, 0x65, 0x52, 0x08, 0x6e, 0x65, 0x77, 0x53,
+	0x74, 0x61, 0x74, 0x65, 0x22, 0x51, 0x0a, 0x0d, 0x42, 0x65, 0x73, 0x74, 0x50, 0x61, 0x74, 0x68,
+	0x45, 0x76, 0x65, 0x6e, 0x74, 0x12, 0x22, 0x0a, 0x04, 0x70, 0x61, 0x74, 0x68, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x0e, 0x2e, 0x67, 0x6f, 0x62, 0x67, 0x70, 0x61, 0x70, 0x69, 0x2e, 0x50,
+	0x61, 0x74, 0x68, 0x52, 0x04, 0x70, 0x61, 0x74, 0x68, 0x12, 0x1c, 0x0a, 0x09, 0x77, 0x69, 0x74,
+	0x68, 0x64, 0x72, 0x61, 0x77, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x08, 0x52, 0x09, 0x77, 0x69,
+	0x74, 0x68, 0x64, 0x72, 0x61, 0x77, 0x6e, 0x22, 0x80, 0x01, 0x0a, 0x12, 0x57, 0x61, 0x74, 0x63,
+	0x68, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x29,
+	0x0a, 0x04, 0x70, 0x65, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x13, 0x2e, 0x67,
+	0x6f, 0x62, 0x67, 0x70, 0x61, 0x70, 0x69, 0x2e, 0x50, 0x65, 0x65, 0x72, 0x45, 0x76, 0x65, 0x6e,
+	0x74, 0x48, 0x00, 0x52, 0x04, 0x70, 0x65, 0x65, 0x72, 0x12, 0x36, 0x0a, 0x09, 0x62, 0x65, 0x73,
+	0x74, 0x5f, 0x70, 0x61, 0x74, 0x68, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x67,
+	0x6f, 0x62, 0x67, 0x70, 0x61, 0x70, 0x69, 0x2e, 0x42, 0x65, 0x73, 0x74, 0x50, 0x61, 0x74, 0x68,
+	0x45, 0x76, 0x65, 0x6e, 0x74, 0x48, 0x00, 0x52, 0x08, 0x62, 0x65, 0x73, 0x74, 0x50, 0x61, 0x74,
+	0x68, 0x42, 0x07, 0x0a, 0x05, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x2a, 0x57, 0x0a, 0x0c, 0x53, 0x65,
+	0x73, 0x73, 0x69, 0x6f, 0x6e, 0x53, 0x74, 0x61, 0x74, 0x65, 0x12, 0x08, 0x0a, 0x04, 0x49, 0x44,
+	0x4c, 0x45, 0x10, 0x00, 0x12, 0x0b, 0x0a, 0x07, 0x43, 0x4f, 0x4e, 0x4e, 0x45, 0x43, 0x54, 0x10,
+	0x01, 0x12, 0x0d, 0x0a, 0x09, 0x4f, 0x50, 0x45, 0x4e, 0x5f, 0x53, 0x45, 0x4e, 0x54, 0x10, 0x02,
+	0x12, 0x10, 0x0a, 0x0c, 0x4f, 0x50, 0x45, 0x4e, 0x5f, 0x43, 0x4f, 0x4e, 0x46, 0x49, 0x52, 0x4d,
+	0x10, 0x03, 0x12, 0x0f, 0x0a, 0x0b, 0x45, 0x53, 0x54, 0x41, 0x42, 0x4c, 0x49, 0x53, 0x48, 0x45,
+	0x44, 0x10, 0x04, 0x2a, 0x1f, 0x0a, 0x04, 0x4d, 0x6f, 0x64, 0x65, 0x12, 0x0b, 0x0a, 0x07, 0x50,
+	0x41, 0x53, 0x53, 0x49, 0x56, 0x45, 0x10, 0x00, 0x12, 0x0a, 0x0a, 0x06, 0x41, 0x43, 0x54, 0x49,
+	0x56, 0x45, 0x10, 0x01, 0x2a, 0x39, 0x0a, 0x09, 0x54, 0x61, 0x62, 0x6c, 0x65, 0x54, 0x79, 0x70,
+	0x65, 0x12, 0x0b, 0x0a, 0x07, 0x4c, 0x4f, 0x43, 0x5f, 0x52, 0x49, 0x42, 0x10, 0x00, 0x12, 0x0e,
+	0x0a, 0x0a, 0x41, 0x44, 0x4a, 0x5f, 0x52, 0x49, 0x42, 0x5f, 0x49, 0x4e, 0x10, 0x01, 0x12, 0x0f,
+	0x0a, 0x0b, 0x41, 0x44, 0x4a, 0x5f, 0x52, 0x49, 0x42, 0x5f, 0x4f, 0x55, 0x54, 0x10, 0x02, 0x32,
+	0xa3, 0x07, 0x0a, 0x08, 0x47, 0x6f, 0x62, 0x67, 0x70, 0x41, 0x70, 0x69, 0x12, 0x50, 0x0a, 0x0d,
+	0x4c, 0x69, 0x73, 0x74, 0x4e, 0x65, 0x69, 0x67, 0x68, 0x62, 0x6f, 0x72, 0x73, 0x12, 0x1e, 0x2e,
+	0x67, 0x6f, 0x62, 0x67, 0x70, 0x61, 0x70, 0x69, 0x2e, 0x4c, 0x69, 0x73, 0x74, 0x4e, 0x65, 0x69,
+	0x67, 0x68, 0x62, 0x6f, 0x72, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1f, 0x2e,
+	0x67, 0x6f, 0x62, 0x67, 0x70, 0x61, 0x70, 0x69, 0x2e, 0x4c, 0x69, 0x73, 0x74, 0x4e, 0x65, 0x69,
+	0x67, 0x68, 0x62, 0x6f, 0x72, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x3f,
+	0x0a, 0x0b, 0x47, 0x65, 0x74, 0x4e, 0x65, 0x69, 0x67, 0x68, 0x62, 0x6f, 0x72, 0x12, 0x1c, 0x2e,
+	0x67, 0x6f, 0x62, 0x67, 0x70, 0x61, 0x70, 0x69, 0x2e, 0x47, 0x65, 0x74, 0x4e, 0x65, 0x69, 0x67,
+	0x68, 0x62, 0x6f, 0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x12, 0x2e, 0x67, 0x6f,
+	0x62, 0x67, 0x70, 0x61, 0x70, 0x69, 0x2e, 0x4e, 0x65, 0x69, 0x67, 0x68, 0x62, 0x6f, 0x72, 0x12,
+	0x4a, 0x0a, 0x0b, 0x41, 0x64, 0x64, 0x4e, 0x65, 0x69, 0x67, 0x68, 0x62, 0x6f, 0x72, 0x12, 0x1c,
+	0x2e, 0x67, 0x6f, 0x62, 0x67, 0x70, 0x61, 0x70, 0x69, 0x2e, 0x41, 0x64, 0x64, 0x4e, 0x65, 0x69,
+	0x67, 0x68, 0x62, 0x6f, 0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1d, 0x2e, 0x67,
+	0x6f, 0x62, 0x67, 0x70, 0x61, 0x70, 0x69, 0x2e, 0x41, 0x64, 0x64, 0x4e, 0x65, 0x69, 0x67, 0x68,
+	0x62, 0x6f, 0x72, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x53, 0x0a, 0x0e, 0x44,
+	0x65, 0x6c, 0x65, 0x74, 0x65, 0x4e, 0x65, 0x69, 0x67, 0x68, 0x62, 0x6f, 0x72, 0x12, 0x1f, 0x2e,
+	0x67, 0x6f, 0x62, 0x67, 0x70, 0x61, 0x70, 0x69, 0x2e, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x4e,
+	0x65, 0x69, 0x67, 0x68, 0x62, 0x6f, 0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x20,
+	0x2e, 0x67, 0x6f, 0x62, 0x67, 0x70, 0x61, 0x70, 0x69, 0x2e, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65,
+	0x4e, 0x65, 0x69, 0x67, 0x68, 0x62, 0x6f, 0x72, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
+	0x12, 0x53, 0x0a, 0x0e, 0x45, 0x6e, 0x61, 0x62, 0x6c, 0x65, 0x4e, 0x65, 0x69, 0x67, 0x68, 0x62,
+	0x6f, 0x72, 0x12, 0x1f, 0x2e, 0x67, 0x6f, 0x62, 0x67, 0x70, 0x61, 0x70, 0x69, 0x2e, 0x45, 0x6e,
+	0x61, 0x62, 0x6c, 0x65, 0x4e, 0x65, 0x69, 0x67, 0x68, 0x62, 0x6f, 0x72, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x1a, 0x20, 0x2e, 0x67, 0x6f, 0x62, 0x67, 0x70, 0x61, 0x70, 0x69, 0x2e, 0x45,
+	0x6e, 0x61, 0x62, 0x6c, 0x65, 0x4e, 0x65, 0x69, 0x67, 0x68, 0x62, 0x6f, 0x72, 0x52, 0x65, 0x73,
+	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x56, 0x0a, 0x0f, 0x44, 0x69, 0x73, 0x61, 0x62, 0x6c, 0x65,
+	0x4e, 0x65, 0x69, 0x67, 0x68, 0x62, 0x6f, 0x72, 0x12, 0x20, 0x2e, 0x67, 0x6f, 0x62, 0x67, 0x70,
+	0x61, 0x70, 0x69, 0x2e, 0x44, 0x69, 0x73, 0x61, 0x62, 0x6c, 0x65, 0x4e, 0x65, 0x69, 0x67, 0x68,
+	0x62, 0x6f, 0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x21, 0x2e, 0x67, 0x6f, 0x62,
+	0x67, 0x70, 0x61, 0x70, 0x69, 0x2e, 0x44, 0x69, 0x73, 0x61, 0x62, 0x6c, 0x65, 0x4e, 0x65, 0x69,
+	0x67, 0x68, 0x62, 0x6f, 0x72, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x50, 0x0a,
+	0x0d, 0x52, 0x65, 0x73, 0x65, 0x74, 0x4e, 0x65, 0x69, 0x67, 0x68, 0x62, 0x6f, 0x72, 0x12, 0x1e,
+	0x2e, 0x67, 0x6f, 0x62, 0x67, 0x70, 0x61, 0x70, 0x69, 0x2e, 0x52, 0x65, 0x73, 0x65, 0x74, 0x4e,
+	0x65, 0x69, 0x67, 0x68, 0x62, 0x6f, 0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1f,
+	0x2e, 0x67, 0x6f, 0x62, 0x67, 0x70, 0x61, 0x70, 0x69, 0x2e, 0x52, 0x65, 0x73, 0x65, 0x74, 0x4e,
+	0x65, 0x69, 0x67, 0x68, 0x62, 0x6f, 0x72, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12,
+	0x41, 0x0a, 0x08, 0x4c, 0x69, 0x73, 0x74, 0x50, 0x61, 0x74, 0x68, 0x12, 0x19, 0x2e, 0x67, 0x6f,
+	0x62, 0x67, 0x70, 0x61, 0x70, 0x69, 0x2e, 0x4c, 0x69, 0x73, 0x74, 0x50, 0x61, 0x74, 0x68, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1a, 0x2e, 0x67, 0x6f, 0x62, 0x67, 0x70, 0x61, 0x70,
+	0x69, 0x2e, 0x4c, 0x69, 0x73, 0x74, 0x50, 0x61, 0x74, 0x68, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
+	0x73, 0x65, 0x12, 0x3e, 0x0a, 0x07, 0x41, 0x64, 0x64, 0x50, 0x61, 0x74, 0x68, 0x12, 0x18, 0x2e,
+	0x67, 0x6f, 0x62, 0x67, 0x70, 0x61, 0x70, 0x69, 0x2e, 0x41, 0x64, 0x64, 0x50, 0x61, 0x74, 0x68,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x19, 0x2e, 0x67, 0x6f, 0x62, 0x67, 0x70, 0x61,
+	0x70, 0x69, 0x2e, 0x41, 0x64, 0x64, 0x50, 0x61, 0x74, 0x68, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
+	0x73, 0x65, 0x12, 0x47, 0x0a, 0x0a, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x50, 0x61, 0x74, 0x68,
+	0x12, 0x1b, 0x2e, 0x67, 0x6f, 0x62, 0x67, 0x70, 0x61, 0x70, 0x69, 0x2e, 0x44, 0x65, 0x6c, 0x65,
+	0x74, 0x65, 0x50, 0x61, 0x74, 0x68, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1c, 0x2e,
+	0x67, 0x6f, 0x62, 0x67, 0x70, 0x61, 0x70, 0x69, 0x2e, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x50,
+	0x61, 0x74, 0x68, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x4d, 0x0a, 0x0c, 0x52,
+	0x65, 0x6c, 0x6f, 0x61, 0x64, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x12, 0x1d, 0x2e, 0x67, 0x6f,
+	0x62, 0x67, 0x70, 0x61, 0x70, 0x69, 0x2e, 0x52, 0x65, 0x6c, 0x6f, 0x61, 0x64, 0x43, 0x6f, 0x6e,
+	0x66, 0x69, 0x67, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1e, 0x2e, 0x67, 0x6f, 0x62,
+	0x67, 0x70, 0x61, 0x70, 0x69, 0x2e, 0x52, 0x65, 0x6c, 0x6f, 0x61, 0x64, 0x43, 0x6f, 0x6e, 0x66,
+	0x69, 0x67, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x49, 0x0a, 0x0a, 0x57, 0x61,
+	0x74, 0x63, 0x68, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x12, 0x1b, 0x2e, 0x67, 0x6f, 0x62, 0x67, 0x70,
+	0x61, 0x70, 0x69, 0x2e, 0x57, 0x61, 0x74, 0x63, 0x68, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1c, 0x2e, 0x67, 0x6f, 0x62, 0x67, 0x70, 0x61, 0x70, 0x69,
+	0x2e, 0x57, 0x61, 0x74, 0x63, 0x68, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x52, 0x65, 0x73, 0x70, 0x6f,
+	0x6e, 0x73, 0x65, 0x30, 0x01, 0x42, 0x23, 0x5a, 0x21, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e,
+	0x63, 0x6f, 0x6d, 0x2f, 0x53, 0x6f, 0x74, 0x61, 0x55, 0x65, 0x64, 0x61, 0x2f, 0x67, 0x6f, 0x62,
+	0x67, 0x70, 0x2f, 0x61, 0x70, 0x69, 0x3b, 0x61, 0x70, 0x69, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x33,
+}
+
+var (
+	file_gobgp_proto_rawDescOnce sync.Once
+	file_gobgp_proto_rawDescData = file_gobgp_proto_rawDesc
+)
+
+func file_gobgp_proto_rawDescGZIP() []byte {
+	file_gobgp_proto_rawDescOnce.Do(func() {
+		file_gobgp_proto_rawDescData = protoimpl.X.CompressGZIP(file_gobgp_proto_rawDescData)
+	})
+	return file_gobgp_proto_rawDescData
+}
+
+var file_gobgp_proto_enumTypes = make([]protoimpl.EnumInfo, 3)
+var file_gobgp_proto_msgTypes = make([]protoimpl.MessageInfo, 31)
+var file_gobgp_proto_goTypes = []any{
+	(SessionState)(0),               // 0: gobgpapi.SessionState
+	(Mode)(0),                       // 1: gobgpapi.Mode
+	(TableType)(0),                  // 2: gobgpapi.TableType
+	(*Family)(nil),                  // 3: gobgpapi.Family
+	(*NeighborConfig)(nil),          // 4: gobgpapi.NeighborConfig
+	(*MessageCounters)(nil),         // 5: gobgpapi.MessageCounters
+	(*NeighborState)(nil),           // 6: gobgpapi.NeighborState
+	(*Neighbor)(nil),                // 7: gobgpapi.Neighbor
+	(*ListNeighborsRequest)(nil),    // 8: gobgpapi.ListNeighborsRequest
+	(*ListNeighborsResponse)(nil),   // 9: gobgpapi.ListNeighborsResponse
+	(*GetNeighborRequest)(nil),      // 10: gobgpapi.GetNeighborRequest
+	(*AddNeighborRequest)(nil),      // 11: gobgpapi.AddNeighborRequest
+	(*AddNeighborResponse)(nil),     // 12: gobgpapi.AddNeighborResponse
+	(*DeleteNeighborRequest)(nil),   // 13: gobgpapi.DeleteNeighborRequest
+	(*DeleteNeighborResponse)(nil),  // 14: gobgpapi.DeleteNeighborResponse
+	(*EnableNeighborRequest)(nil),   // 15: gobgpapi.EnableNeighborRequest
+	(*EnableNeighborResponse)(nil),  // 16: gobgpapi.EnableNeighborResponse
+	(*DisableNeighborRequest)(nil),  // 17: gobgpapi.DisableNeighborRequest
+	(*DisableNeighborResponse)(nil), // 18: gobgpapi.DisableNeighborResponse
+	(*ResetNeighborRequest)(nil),    // 19: gobgpapi.ResetNeighborRequest
+	(*ResetNeighborResponse)(nil),   // 20: gobgpapi.ResetNeighborResponse
+	(*Path)(nil),                    // 21: gobgpapi.Path
+	(*ListPathRequest)(nil),         // 22: gobgpapi.ListPathRequest
+	(*ListPathResponse)(nil),        // 23: gobgpapi.ListPathResponse
+	(*AddPathRequest)(nil),          // 24: gobgpapi.AddPathRequest
+	(*AddPathResponse)(nil),         // 25: gobgpapi.AddPathResponse
+	(*DeletePathRequest)(nil),       // 26: gobgpapi.DeletePathRequest
+	(*DeletePathResponse)(nil),      // 27: gobgpapi.DeletePathResponse
+	(*ReloadConfigRequest)(nil),     // 28: gobgpapi.ReloadConfigRequest
+	(*ReloadConfigResponse)(nil),    // 29: gobgpapi.ReloadConfigResponse
+	(*WatchEventRequest)(nil),       // 30: gobgpapi.WatchEventRequest
+	(*PeerEvent)(nil),               // 31: gobgpapi.PeerEvent
+	(*BestPathEvent)(nil),           // 32: gobgpapi.BestPathEvent
+	(*WatchEventResponse)(nil),      // 33: gobgpapi.WatchEventResponse
+}
+var file_gobgp_proto_depIdxs = []int32{
+	1,  // 0: gobgpapi.NeighborConfig.mode:type_name -> gobgpapi.Mode
+	0,  // 1: gobgpapi.NeighborState.session_state:type_name -> gobgpapi.SessionState
+	5,  // 2: gobgpapi.NeighborState.sent:type_name -> gobgpapi.MessageCounters
+	5,  // 3: gobgpapi.NeighborState.received:type_name -> gobgpapi.MessageCounters
+	4,  // 4: gobgpapi.Neighbor.config:type_name -> gobgpapi.NeighborConfig
+	6,  // 5: gobgpapi.Neighbor.state:type_name -> gobgpapi.NeighborState
+	7,  // 6: gobgpapi.ListNeighborsResponse.neighbors:type_name -> gobgpapi.Neighbor
+	4,  // 7: gobgpapi.AddNeighborRequest.neighbor:type_name -> gobgpapi.NeighborConfig
+	2,  // 8: gobgpapi.ListPathRequest.table_type:type_name -> gobgpapi.TableType
+	3,  // 9: gobgpapi.ListPathRequest.family:type_name -> gobgpapi.Family
+	21, // 10: gobgpapi.ListPathResponse.paths:type_name -> gobgpapi.Path
+	0,  // 11: gobgpapi.PeerEvent.old_state:type_name -> gobgpapi.SessionState
+	0,  // 12: gobgpapi.PeerEvent.new_state:type_name -> gobgpapi.SessionState
+	21, // 13: gobgpapi.BestPathEvent.path:type_name -> gobgpapi.Path
+	31, // 14: gobgpapi.WatchEventResponse.peer:type_name -> gobgpapi.PeerEvent
+	32, // 15: gobgpapi.WatchEventResponse.best_path:type_name -> gobgpapi.BestPathEvent
+	8,  // 16: gobgpapi.GobgpApi.ListNeighbors:input_type -> gobgpapi.ListNeighborsRequest
+	10, // 17: gobgpapi.GobgpApi.GetNeighbor:input_type -> gobgpapi.GetNeighborRequest
+	11, // 18: gobgpapi.GobgpApi.AddNeighbor:input_type -> gobgpapi.AddNeighborRequest
+	13, // 19: gobgpapi.GobgpApi.DeleteNeighbor:input_type -> gobgpapi.DeleteNeighborRequest
+	15, // 20: gobgpapi.GobgpApi.EnableNeighbor:input_type -> gobgpapi.EnableNeighborRequest
+	17, // 21: gobgpapi.GobgpApi.DisableNeighbor:input_type -> gobgpapi.DisableNeighborRequest
+	19, // 22: gobgpapi.GobgpApi.ResetNeighbor:input_type -> gobgpapi.ResetNeighborRequest
+	22, // 23: gobgpapi.GobgpApi.ListPath:input_type -> gobgpapi.ListPathRequest
+	24, // 24: gobgpapi.GobgpApi.AddPath:input_type -> gobgpapi.AddPathRequest
+	26, // 25: gobgpapi.GobgpApi.DeletePath:input_type -> gobgpapi.DeletePathRequest
+	28, // 26: gobgpapi.GobgpApi.ReloadConfig:input_type -> gobgpapi.ReloadConfigRequest
+	30, // 27: gobgpapi.GobgpApi.WatchEvent:input_type -> gobgpapi.WatchEventRequest
+	9,  // 28: gobgpapi.GobgpApi.ListNeighbors:output_type -> gobgpapi.ListNeighborsResponse
+	7,  // 29: gobgpapi.GobgpApi.GetNeighbor:output_type -> gobgpapi.Neighbor
+	12, // 30: gobgpapi.GobgpApi.AddNeighbor:output_type -> gobgpapi.AddNeighborResponse
+	14, // 31: gobgpapi.GobgpApi.DeleteNeighbor:output_type -> gobgpapi.DeleteNeighborResponse
+	16, // 32: gobgpapi.GobgpApi.EnableNeighbor:output_type -> gobgpapi.EnableNeighborResponse
+	18, // 33: gobgpapi.GobgpApi.DisableNeighbor:output_type -> gobgpapi.DisableNeighborResponse
+	20, // 34: gobgpapi.GobgpApi.ResetNeighbor:output_type -> gobgpapi.ResetNeighborResponse
+	23, // 35: gobgpapi.GobgpApi.ListPath:output_type -> gobgpapi.ListPathResponse
+	25, // 36: gobgpapi.GobgpApi.AddPath:output_type -> gobgpapi.AddPathResponse
+	27, // 37: gobgpapi.GobgpApi.DeletePath:output_type -> gobgpapi.DeletePathResponse
+	29, // 38: gobgpapi.GobgpApi.ReloadConfig:output_type -> gobgpapi.ReloadConfigResponse
+	33, // 39: gobgpapi.GobgpApi.WatchEvent:output_type -> gobgpapi.WatchEventResponse
+	28, // [28:40] is the sub-list for method output_type
+	16, // [16:28] is the sub-list for method input_type
+	16, // [16:16] is the sub-list for extension type_name
+	16, // [16:16] is the sub-list for extension extendee
+	0,  // [0:16] is the sub-list for field type_name
+}
+
+func init() { file_gobgp_proto_init() }
+func file_gobgp_proto_init() {
+	if File_gobgp_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_gobgp_proto_msgTypes[0].Exporter = func(v any, i int) any {
+			switch v := v.(*Family); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_gobgp_proto_msgTypes[1].Exporter = func(v any, i int) any {
+			switch v := v.(*NeighborConfig); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_gobgp_proto_msgTypes[2].Exporter = func(v any, i int) any {
+			switch v := v.(*MessageCounters); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_gobgp_proto_msgTypes[3].Exporter = func(v any, i int) any {
+			switch v := v.(*NeighborState); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_gobgp_proto_msgTypes[4].Exporter = func(v any, i int) any {
+			switch v := v.(*Neighbor); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_gobgp_proto_msgTypes[5].Exporter = func(v any, i int) any {
+			switch v := v.(*ListNeighborsRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_gobgp_proto_msgTypes[6].Exporter = func(v any, i int) any {
+			switch v := v.(*ListNeighborsResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_gobgp_proto_msgTypes[7].Exporter = func(v any, i int) any {
+			switch v := v.(*GetNeighborRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_gobgp_proto_msgTypes[8].Exporter = func(v any, i int) any {
+			switch v := v.(*AddNeighborRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_gobgp_proto_msgTypes[9].Exporter = func(v any, i int) any {
+			switch v := v.(*AddNeighborResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_gobgp_proto_msgTypes[10].Exporter = func(v any, i int) any {
+			switch v := v.(*DeleteNeighborRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_gobgp_proto_msgTypes[11].Exporter = func(v any, i int) any {
+			switch v := v.(*DeleteNeighborResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_gobgp_proto_msgTypes[12].Exporter = func(v any, i int) any {
+			switch v := v.(*EnableNeighborRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_gobgp_proto_msgTypes[13].Exporter = func(v any, i int) any {
+			switch v := v.(*EnableNeighborResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_gobgp_proto_msgTypes[14].Exporter = func(v any, i int) any {
+			switch v := v.(*DisableNeighborRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_gobgp_proto_msgTypes[15].Exporter = func(v any, i int) any {
+			switch v := v.(*DisableNeighborResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_gobgp_proto_msgTypes[16].Exporter = func(v any, i int) any {
+			switch v := v.(*ResetNeighborRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_gobgp_proto_msgTypes[17].Exporter = func(v any, i int) any {
+			switch v := v.(*ResetNeighborResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_gobgp_proto_msgTypes[18].Exporter = func(v any, i int) any {
+			switch v := v.(*Path); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_gobgp_proto_msgTypes[19].Exporter = func(v any, i int) any {
+			switch v := v.(*ListPathRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_gobgp_proto_msgTypes[20].Exporter = func(v any, i int) any {
+			switch v := v.(*ListPathResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_gobgp_proto_msgTypes[21].Exporter = func(v any, i int) any {
+			switch v := v.(*AddPathRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_gobgp_proto_msgTypes[22].Exporter = func(v any, i int) any {
+			switch v := v.(*AddPathResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_gobgp_proto_msgTypes[23].Exporter = func(v any, i int) any {
+			switch v := v.(*DeletePathRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_gobgp_proto_msgTypes[24].Exporter = func(v any, i int) any {
+			switch v := v.(*DeletePathResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_gobgp_proto_msgTypes[25].Exporter = func(v any, i int) any {
+			switch v := v.(*ReloadConfigRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_gobgp_proto_msgTypes[26].Exporter = func(v any, i int) any {
+			switch v := v.(*ReloadConfigResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_gobgp_proto_msgTypes[27].Exporter = func(v any, i int) any {
+			switch v := v.(*WatchEventRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_gobgp_proto_msgTypes[28].Exporter = func(v any, i int) any {
+			switch v := v.(*PeerEvent); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_gobgp_proto_msgTypes[29].Exporter = func(v any, i int) any {
+			switch v := v.(*BestPathEvent); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_gobgp_proto_msgTypes[30].Exporter = func(v any, i int) any {
+			switch v := v.(*WatchEventResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	file_gobgp_proto_msgTypes[30].OneofWrappers = []any{
+		(*WatchEventResponse_Peer)(nil),
+		(*WatchEventResponse_BestPath)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_gobgp_proto_rawDesc,
+			NumEnums:      3,
+			NumMessages:   31,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_gobgp_proto_goTypes,
+		DependencyIndexes: file_gobgp_proto_depIdxs,
+		EnumInfos:         file_gobgp_proto_enumTypes,
+		MessageInfos:      file_gobgp_proto_msgTypes,
+	}.Build()
+	File_gobgp_proto = out.File
+	file_gobgp_proto_rawDesc = nil
+	file_gobgp_proto_goTypes = nil
+	file_gobgp_proto_depIdxs = nil
+}
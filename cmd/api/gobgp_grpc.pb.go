@@ -0,0 +1,565 @@
+// gobgpを外部から操作するためのgRPC API
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v27.3.0
+// source: gobgp.proto
+
+package api
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	GobgpApi_ListNeighbors_FullMethodName   = "/gobgpapi.GobgpApi/ListNeighbors"
+	GobgpApi_GetNeighbor_FullMethodName     = "/gobgpapi.GobgpApi/GetNeighbor"
+	GobgpApi_AddNeighbor_FullMethodName     = "/gobgpapi.GobgpApi/AddNeighbor"
+	GobgpApi_DeleteNeighbor_FullMethodName  = "/gobgpapi.GobgpApi/DeleteNeighbor"
+	GobgpApi_EnableNeighbor_FullMethodName  = "/gobgpapi.GobgpApi/EnableNeighbor"
+	GobgpApi_DisableNeighbor_FullMethodName = "/gobgpapi.GobgpApi/DisableNeighbor"
+	GobgpApi_ResetNeighbor_FullMethodName   = "/gobgpapi.GobgpApi/ResetNeighbor"
+	GobgpApi_ListPath_FullMethodName        = "/gobgpapi.GobgpApi/ListPath"
+	GobgpApi_AddPath_FullMethodName         = "/gobgpapi.GobgpApi/AddPath"
+	GobgpApi_DeletePath_FullMethodName      = "/gobgpapi.GobgpApi/DeletePath"
+	GobgpApi_ReloadConfig_FullMethodName    = "/gobgpapi.GobgpApi/ReloadConfig"
+	GobgpApi_WatchEvent_FullMethodName      = "/gobgpapi.GobgpApi/WatchEvent"
+)
+
+// GobgpApiClient is the client API for GobgpApi service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type GobgpApiClient interface {
+	// Peerの一覧と、それぞれの状態・統計情報を返す
+	ListNeighbors(ctx context.Context, in *ListNeighborsRequest, opts ...grpc.CallOption) (*ListNeighborsResponse, error)
+	// 指定したPeerの状態・統計情報を返す
+	GetNeighbor(ctx context.Context, in *GetNeighborRequest, opts ...grpc.CallOption) (*Neighbor, error)
+	AddNeighbor(ctx context.Context, in *AddNeighborRequest, opts ...grpc.CallOption) (*AddNeighborResponse, error)
+	// Cease NotificationMessageを送信してセッションを切断し、Peerを削除する
+	DeleteNeighbor(ctx context.Context, in *DeleteNeighborRequest, opts ...grpc.CallOption) (*DeleteNeighborResponse, error)
+	// 無効にしたPeerを再び接続する
+	EnableNeighbor(ctx context.Context, in *EnableNeighborRequest, opts ...grpc.CallOption) (*EnableNeighborResponse, error)
+	// Cease NotificationMessageを送信してセッションを切断し、有効にするまで再接続しない
+	DisableNeighbor(ctx context.Context, in *DisableNeighborRequest, opts ...grpc.CallOption) (*DisableNeighborResponse, error)
+	// セッションを張り直す。softの場合はセッションを維持したまま経路を評価し直す
+	ResetNeighbor(ctx context.Context, in *ResetNeighborRequest, opts ...grpc.CallOption) (*ResetNeighborResponse, error)
+	// LocRib / AdjRibIn / AdjRibOut の経路を返す
+	ListPath(ctx context.Context, in *ListPathRequest, opts ...grpc.CallOption) (*ListPathResponse, error)
+	// 自身で生成する経路を追加・削除する
+	AddPath(ctx context.Context, in *AddPathRequest, opts ...grpc.CallOption) (*AddPathResponse, error)
+	DeletePath(ctx context.Context, in *DeletePathRequest, opts ...grpc.CallOption) (*DeletePathResponse, error)
+	// 設定ファイルを読み込み直す
+	ReloadConfig(ctx context.Context, in *ReloadConfigRequest, opts ...grpc.CallOption) (*ReloadConfigResponse, error)
+	// Peerの状態の変化と、最適経路の変化を通知する
+	WatchEvent(ctx context.Context, in *WatchEventRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[WatchEventResponse], error)
+}
+
+type gobgpApiClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewGobgpApiClient(cc grpc.ClientConnInterface) GobgpApiClient {
+	return &gobgpApiClient{cc}
+}
+
+func (c *gobgpApiClient) ListNeighbors(ctx context.Context, in *ListNeighborsRequest, opts ...grpc.CallOption) (*ListNeighborsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListNeighborsResponse)
+	err := c.cc.Invoke(ctx, GobgpApi_ListNeighbors_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gobgpApiClient) GetNeighbor(ctx context.Context, in *GetNeighborRequest, opts ...grpc.CallOption) (*Neighbor, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Neighbor)
+	err := c.cc.Invoke(ctx, GobgpApi_GetNeighbor_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gobgpApiClient) AddNeighbor(ctx context.Context, in *AddNeighborRequest, opts ...grpc.CallOption) (*AddNeighborResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AddNeighborResponse)
+	err := c.cc.Invoke(ctx, GobgpApi_AddNeighbor_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gobgpApiClient) DeleteNeighbor(ctx context.Context, in *DeleteNeighborRequest, opts ...grpc.CallOption) (*DeleteNeighborResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteNeighborResponse)
+	err := c.cc.Invoke(ctx, GobgpApi_DeleteNeighbor_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gobgpApiClient) EnableNeighbor(ctx context.Context, in *EnableNeighborRequest, opts ...grpc.CallOption) (*EnableNeighborResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(EnableNeighborResponse)
+	err := c.cc.Invoke(ctx, GobgpApi_EnableNeighbor_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gobgpApiClient) DisableNeighbor(ctx context.Context, in *DisableNeighborRequest, opts ...grpc.CallOption) (*DisableNeighborResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DisableNeighborResponse)
+	err := c.cc.Invoke(ctx, GobgpApi_DisableNeighbor_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gobgpApiClient) ResetNeighbor(ctx context.Context, in *ResetNeighborRequest, opts ...grpc.CallOption) (*ResetNeighborResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ResetNeighborResponse)
+	err := c.cc.Invoke(ctx, GobgpApi_ResetNeighbor_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gobgpApiClient) ListPath(ctx context.Context, in *ListPathRequest, opts ...grpc.CallOption) (*ListPathResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListPathResponse)
+	err := c.cc.Invoke(ctx, GobgpApi_ListPath_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gobgpApiClient) AddPath(ctx context.Context, in *AddPathRequest, opts ...grpc.CallOption) (*AddPathResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AddPathResponse)
+	err := c.cc.Invoke(ctx, GobgpApi_AddPath_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gobgpApiClient) DeletePath(ctx context.Context, in *DeletePathRequest, opts ...grpc.CallOption) (*DeletePathResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeletePathResponse)
+	err := c.cc.Invoke(ctx, GobgpApi_DeletePath_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gobgpApiClient) ReloadConfig(ctx context.Context, in *ReloadConfigRequest, opts ...grpc.CallOption) (*ReloadConfigResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ReloadConfigResponse)
+	err := c.cc.Invoke(ctx, GobgpApi_ReloadConfig_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gobgpApiClient) WatchEvent(ctx context.Context, in *WatchEventRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[WatchEventResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &GobgpApi_ServiceDesc.Streams[0], GobgpApi_WatchEvent_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchEventRequest, WatchEventResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type GobgpApi_WatchEventClient = grpc.ServerStreamingClient[WatchEventResponse]
+
+// GobgpApiServer is the server API for GobgpApi service.
+// All implementations must embed UnimplementedGobgpApiServer
+// for forward compatibility.
+type GobgpApiServer interface {
+	// Peerの一覧と、それぞれの状態・統計情報を返す
+	ListNeighbors(context.Context, *ListNeighborsRequest) (*ListNeighborsResponse, error)
+	// 指定したPeerの状態・統計情報を返す
+	GetNeighbor(context.Context, *GetNeighborRequest) (*Neighbor, error)
+	AddNeighbor(context.Context, *AddNeighborRequest) (*AddNeighborResponse, error)
+	// Cease NotificationMessageを送信してセッションを切断し、Peerを削除する
+	DeleteNeighbor(context.Context, *DeleteNeighborRequest) (*DeleteNeighborResponse, error)
+	// 無効にしたPeerを再び接続する
+	EnableNeighbor(context.Context, *EnableNeighborRequest) (*EnableNeighborResponse, error)
+	// Cease NotificationMessageを送信してセッションを切断し、有効にするまで再接続しない
+	DisableNeighbor(context.Context, *DisableNeighborRequest) (*DisableNeighborResponse, error)
+	// セッションを張り直す。softの場合はセッションを維持したまま経路を評価し直す
+	ResetNeighbor(context.Context, *ResetNeighborRequest) (*ResetNeighborResponse, error)
+	// LocRib / AdjRibIn / AdjRibOut の経路を返す
+	ListPath(context.Context, *ListPathRequest) (*ListPathResponse, error)
+	// 自身で生成する経路を追加・削除する
+	AddPath(context.Context, *AddPathRequest) (*AddPathResponse, error)
+	DeletePath(context.Context, *DeletePathRequest) (*DeletePathResponse, error)
+	// 設定ファイルを読み込み直す
+	ReloadConfig(context.Context, *ReloadConfigRequest) (*ReloadConfigResponse, error)
+	// Peerの状態の変化と、最適経路の変化を通知する
+	WatchEvent(*WatchEventRequest, grpc.ServerStreamingServer[WatchEventResponse]) error
+	mustEmbedUnimplementedGobgpApiServer()
+}
+
+// UnimplementedGobgpApiServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedGobgpApiServer struct{}
+
+func (UnimplementedGobgpApiServer) ListNeighbors(context.Context, *ListNeighborsRequest) (*ListNeighborsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListNeighbors not implemented")
+}
+func (UnimplementedGobgpApiServer) GetNeighbor(context.Context, *GetNeighborRequest) (*Neighbor, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetNeighbor not implemented")
+}
+func (UnimplementedGobgpApiServer) AddNeighbor(context.Context, *AddNeighborRequest) (*AddNeighborResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddNeighbor not implemented")
+}
+func (UnimplementedGobgpApiServer) DeleteNeighbor(context.Context, *DeleteNeighborRequest) (*DeleteNeighborResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteNeighbor not implemented")
+}
+func (UnimplementedGobgpApiServer) EnableNeighbor(context.Context, *EnableNeighborRequest) (*EnableNeighborResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method EnableNeighbor not implemented")
+}
+func (UnimplementedGobgpApiServer) DisableNeighbor(context.Context, *DisableNeighborRequest) (*DisableNeighborResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DisableNeighbor not implemented")
+}
+func (UnimplementedGobgpApiServer) ResetNeighbor(context.Context, *ResetNeighborRequest) (*ResetNeighborResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ResetNeighbor not implemented")
+}
+func (UnimplementedGobgpApiServer) ListPath(context.Context, *ListPathRequest) (*ListPathResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListPath not implemented")
+}
+func (UnimplementedGobgpApiServer) AddPath(context.Context, *AddPathRequest) (*AddPathResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddPath not implemented")
+}
+func (UnimplementedGobgpApiServer) DeletePath(context.Context, *DeletePathRequest) (*DeletePathResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeletePath not implemented")
+}
+func (UnimplementedGobgpApiServer) ReloadConfig(context.Context, *ReloadConfigRequest) (*ReloadConfigResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReloadConfig not implemented")
+}
+func (UnimplementedGobgpApiServer) WatchEvent(*WatchEventRequest, grpc.ServerStreamingServer[WatchEventResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchEvent not implemented")
+}
+func (UnimplementedGobgpApiServer) mustEmbedUnimplementedGobgpApiServer() {}
+func (UnimplementedGobgpApiServer) testEmbeddedByValue()                  {}
+
+// UnsafeGobgpApiServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to GobgpApiServer will
+// result in compilation errors.
+type UnsafeGobgpApiServer interface {
+	mustEmbedUnimplementedGobgpApiServer()
+}
+
+func RegisterGobgpApiServer(s grpc.ServiceRegistrar, srv GobgpApiServer) {
+	// If the following call pancis, it indicates UnimplementedGobgpApiServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&GobgpApi_ServiceDesc, srv)
+}
+
+func _GobgpApi_ListNeighbors_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListNeighborsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GobgpApiServer).ListNeighbors(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GobgpApi_ListNeighbors_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GobgpApiServer).ListNeighbors(ctx, req.(*ListNeighborsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GobgpApi_GetNeighbor_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetNeighborRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GobgpApiServer).GetNeighbor(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GobgpApi_GetNeighbor_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GobgpApiServer).GetNeighbor(ctx, req.(*GetNeighborRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GobgpApi_AddNeighbor_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddNeighborRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GobgpApiServer).AddNeighbor(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GobgpApi_AddNeighbor_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GobgpApiServer).AddNeighbor(ctx, req.(*AddNeighborRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GobgpApi_DeleteNeighbor_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteNeighborRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GobgpApiServer).DeleteNeighbor(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GobgpApi_DeleteNeighbor_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GobgpApiServer).DeleteNeighbor(ctx, req.(*DeleteNeighborRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GobgpApi_EnableNeighbor_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EnableNeighborRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GobgpApiServer).EnableNeighbor(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GobgpApi_EnableNeighbor_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GobgpApiServer).EnableNeighbor(ctx, req.(*EnableNeighborRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GobgpApi_DisableNeighbor_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DisableNeighborRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GobgpApiServer).DisableNeighbor(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GobgpApi_DisableNeighbor_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GobgpApiServer).DisableNeighbor(ctx, req.(*DisableNeighborRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GobgpApi_ResetNeighbor_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ResetNeighborRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GobgpApiServer).ResetNeighbor(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GobgpApi_ResetNeighbor_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GobgpApiServer).ResetNeighbor(ctx, req.(*ResetNeighborRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GobgpApi_ListPath_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListPathRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GobgpApiServer).ListPath(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GobgpApi_ListPath_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GobgpApiServer).ListPath(ctx, req.(*ListPathRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GobgpApi_AddPath_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddPathRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GobgpApiServer).AddPath(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GobgpApi_AddPath_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GobgpApiServer).AddPath(ctx, req.(*AddPathRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GobgpApi_DeletePath_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeletePathRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GobgpApiServer).DeletePath(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GobgpApi_DeletePath_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GobgpApiServer).DeletePath(ctx, req.(*DeletePathRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GobgpApi_ReloadConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReloadConfigRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GobgpApiServer).ReloadConfig(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GobgpApi_ReloadConfig_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GobgpApiServer).ReloadConfig(ctx, req.(*ReloadConfigRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GobgpApi_WatchEvent_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchEventRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(GobgpApiServer).WatchEvent(m, &grpc.GenericServerStream[WatchEventRequest, WatchEventResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type GobgpApi_WatchEventServer = grpc.ServerStreamingServer[WatchEventResponse]
+
+// GobgpApi_ServiceDesc is the grpc.ServiceDesc for GobgpApi service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var GobgpApi_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "gobgpapi.GobgpApi",
+	HandlerType: (*GobgpApiServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListNeighbors",
+			Handler:    _GobgpApi_ListNeighbors_Handler,
+		},
+		{
+			MethodName: "GetNeighbor",
+			Handler:    _GobgpApi_GetNeighbor_Handler,
+		},
+		{
+			MethodName: "AddNeighbor",
+			Handler:    _GobgpApi_AddNeighbor_Handler,
+		},
+		{
+			MethodName: "DeleteNeighbor",
+			Handler:    _GobgpApi_DeleteNeighbor_Handler,
+		},
+		{
+			MethodName: "EnableNeighbor",
+			Handler:    _GobgpApi_EnableNeighbor_Handler,
+		},
+		{
+			MethodName: "DisableNeighbor",
+			Handler:    _GobgpApi_DisableNeighbor_Handler,
+		},
+		{
+			MethodName: "ResetNeighbor",
+			Handler:    _GobgpApi_ResetNeighbor_Handler,
+		},
+		{
+			MethodName: "ListPath",
+			Handler:    _GobgpApi_ListPath_Handler,
+		},
+		{
+			MethodName: "AddPath",
+			Handler:    _GobgpApi_AddPath_Handler,
+		},
+		{
+			MethodName: "DeletePath",
+			Handler:    _GobgpApi_DeletePath_Handler,
+		},
+		{
+			MethodName: "ReloadConfig",
+			Handler:    _GobgpApi_ReloadConfig_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchEvent",
+			Handler:       _GobgpApi_WatchEvent_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "gobgp.proto",
+}
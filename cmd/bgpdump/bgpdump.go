@@ -0,0 +1,398 @@
+// キャプチャしたBGP Messageを読み込み、テキストかJSONで出力するツール
+//
+// 使い方:
+//
+//	bgpdump [-j] [-port 179] [file]
+//
+// fileはpcap, pcapngのキャプチャファイルか、BGP Messageのバイト列を16進数で書いたテキスト。
+// 指定しないか"-"の場合は標準入力から読み込む。
+// キャプチャファイルの場合は、どちらかのポートが-portのTCPのストリームを組み立て直し、
+// BGP Messageに切り出して出力する。
+// 16進数のテキストでは、空白、改行、":"と"0x"を無視し、"#"から行末まではコメントとする。
+//
+// 不正なMessageは、ストリームの先頭からの不正な位置のオフセットと共にMALFORMEDとして出力する。
+// 不正なMessageがあった場合は終了ステータスを2にする。
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/SotaUeda/gobgp/packets"
+)
+
+func main() {
+	asJSON := flag.Bool("j", false, "output in JSON")
+	port := flag.Uint("port", 179, "TCP port of BGP sessions in pcap files")
+	flag.Parse()
+
+	in := io.Reader(os.Stdin)
+	if name := flag.Arg(0); name != "" && name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		in = f
+	}
+	d := &Dumper{Out: os.Stdout, JSON: *asJSON, Port: uint16(*port)}
+	if err := d.Dump(in); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if d.Malformed > 0 {
+		os.Exit(2)
+	}
+}
+
+// 読み込んだBGP Messageを出力する
+type Dumper struct {
+	Out  io.Writer
+	JSON bool
+	// キャプチャファイルで、どちらかのポートがこの番号のTCPのストリームを読み込む
+	Port uint16
+	// 出力した不正なMessageの数
+	Malformed int
+
+	// 読み込んだ順のストリーム
+	streams  []*stream
+	flows    map[flow]*stream
+	sessions map[flow]*session
+	err      error
+}
+
+// TCPのストリームの送信元と宛先
+type flow struct {
+	src, dst netip.AddrPort
+}
+
+// 1つのBGPのセッションの、両方向のOpenMessageのCapability
+// UpdateMessageを変換するときのDecodeOptionsを決めるために使う。
+type session struct {
+	caps map[netip.AddrPort][]packets.Capability
+}
+
+// 入力の先頭のバイト列で、キャプチャファイルか16進数のテキストかを判断して読み込む
+func (d *Dumper) Dump(r io.Reader) error {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(4)
+	var err error
+	if isPcap(magic) {
+		err = d.dumpPcap(br)
+	} else {
+		err = d.dumpHex(br)
+	}
+	if err != nil {
+		return err
+	}
+	return d.err
+}
+
+func (d *Dumper) dumpPcap(r *bufio.Reader) error {
+	pr, err := newPacketReader(r)
+	if err != nil {
+		return err
+	}
+	d.flows = make(map[flow]*stream)
+	d.sessions = make(map[flow]*session)
+	for {
+		p, err := pr.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			d.finish()
+			return err
+		}
+		seg, ok := decodePacket(p)
+		if !ok || (seg.src.Port() != d.Port && seg.dst.Port() != d.Port) {
+			continue
+		}
+		s := d.stream(seg)
+		s.add(seg, p.time)
+		if s.pendingBytes > MAX_PENDING_BYTES {
+			d.skipGap(s)
+		}
+		d.frame(s)
+	}
+	d.finish()
+	return nil
+}
+
+func (d *Dumper) stream(seg *segment) *stream {
+	f := flow{seg.src, seg.dst}
+	s, ok := d.flows[f]
+	if !ok {
+		s = newStream(seg.src, seg.dst)
+		d.flows[f] = s
+		d.streams = append(d.streams, s)
+	}
+	if seg.syn {
+		// 新しいコネクションでは、OpenMessageからCapabilityを読み込み直す
+		delete(d.session(s).caps, seg.src)
+	}
+	return s
+}
+
+// ストリームが属するセッション
+// 両方向のストリームで同じsessionになるように、アドレスとポートの小さい方をsrcにする
+func (d *Dumper) session(s *stream) *session {
+	f := flow{s.src, s.dst}
+	if f.dst.Addr().Less(f.src.Addr()) || (f.dst.Addr() == f.src.Addr() && f.dst.Port() < f.src.Port()) {
+		f.src, f.dst = f.dst, f.src
+	}
+	ss, ok := d.sessions[f]
+	if !ok {
+		ss = &session{caps: make(map[netip.AddrPort][]packets.Capability)}
+		d.sessions[f] = ss
+	}
+	return ss
+}
+
+// 16進数のテキストを1つのストリームとして読み込む
+func (d *Dumper) dumpHex(r io.Reader) error {
+	text, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	var digits strings.Builder
+	for _, line := range strings.Split(string(text), "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		for _, f := range strings.FieldsFunc(line, func(r rune) bool {
+			return r == ' ' || r == '\t' || r == '\r' || r == ':' || r == ','
+		}) {
+			digits.WriteString(strings.TrimPrefix(strings.TrimPrefix(f, "0x"), "0X"))
+		}
+	}
+	b, err := hex.DecodeString(digits.String())
+	if err != nil {
+		return fmt.Errorf("invalid hex input: %w", err)
+	}
+	s := newStream(netip.AddrPort{}, netip.AddrPort{})
+	s.buf = b
+	d.streams = []*stream{s}
+	d.frame(s)
+	d.finish()
+	return nil
+}
+
+// ストリームのbufからBGP Messageを切り出して出力する
+func (d *Dumper) frame(s *stream) {
+	for {
+		if s.resync && !d.resync(s) {
+			return
+		}
+		msg, rest, err := packets.SplitMessage(s.buf)
+		if err != nil {
+			var fe *packets.FormatError
+			errors.As(err, &fe)
+			d.malformed(s, s.offset, s.offset+fe.Offset, fe.Reason, s.buf[:min(len(s.buf), packets.HEADER_LENGTH)])
+			// 壊れたMessageの次のMarkerから読み込みを続ける
+			s.buf = s.buf[1:]
+			s.offset++
+			s.resync = true
+			continue
+		}
+		if msg == nil {
+			return
+		}
+		d.message(s, msg)
+		s.offset += len(msg)
+		s.buf = rest
+	}
+}
+
+// bufの中で、Markerに続いて正しいLengthとTypeがある位置まで読み飛ばす
+// 見つからない場合は、次のセグメントで続きのMarkerが届く可能性がある末尾だけを残してfalseを返す
+func (d *Dumper) resync(s *stream) bool {
+	marker := bytes.Repeat([]byte{0xff}, 16)
+	for i := 0; i+packets.HEADER_LENGTH <= len(s.buf); i++ {
+		h := s.buf[i:]
+		if !bytes.Equal(h[:16], marker) {
+			continue
+		}
+		l := int(h[16])<<8 | int(h[17])
+		if l < packets.HEADER_LENGTH || h[18] < byte(packets.Open) || h[18] > byte(packets.Keepalive) {
+			continue
+		}
+		s.buf, s.offset, s.resync = h, s.offset+i, false
+		return true
+	}
+	if n := len(s.buf) - (packets.HEADER_LENGTH - 1); n > 0 {
+		s.buf, s.offset = s.buf[n:], s.offset+n
+	}
+	return false
+}
+
+// 1つのMessageを検査し、変換して出力する
+func (d *Dumper) message(s *stream, b []byte) {
+	m, err := d.decode(s, b)
+	if err != nil {
+		var fe *packets.FormatError
+		if errors.As(err, &fe) {
+			d.malformed(s, s.offset, s.offset+fe.Offset, fe.Reason, b)
+		} else {
+			d.malformed(s, s.offset, s.offset, err.Error(), b)
+		}
+		return
+	}
+	if om, ok := m.(*packets.OpenMessage); ok && d.sessions != nil {
+		caps, err := om.Capabilities()
+		if err == nil {
+			d.session(s).caps[s.src] = caps
+		}
+	}
+	d.emit(s, dumpRecord{Offset: s.offset, Message: m})
+}
+
+// セッションのOpenMessageからDecodeOptionsを決めて検査し、Messageに変換する
+// 両方向のOpenMessageを読み込んでいない場合は、検査に通るDecodeOptionsを順に試す。
+func (d *Dumper) decode(s *stream, b []byte) (m packets.Message, err error) {
+	candidates := []packets.DecodeOptions{{}, {AS4: true}, {AddPath: true}, {AddPath: true, AS4: true}}
+	if opts, ok := d.options(s); ok {
+		candidates = []packets.DecodeOptions{opts}
+	}
+	for _, opts := range candidates {
+		if err = packets.ValidateMessage(b, opts); err == nil {
+			return packets.BytesToMessageWithOptions(b, opts)
+		}
+	}
+	// 最初の候補で見つかった不正な位置を返す
+	return nil, packets.ValidateMessage(b, candidates[0])
+}
+
+// 両方向のOpenMessageのCapabilityから、s.srcが送信したUpdateMessageのDecodeOptionsを求める
+func (d *Dumper) options(s *stream) (packets.DecodeOptions, bool) {
+	if d.sessions == nil {
+		return packets.DecodeOptions{}, false
+	}
+	caps := d.session(s).caps
+	local, ok1 := caps[s.src]
+	remote, ok2 := caps[s.dst]
+	if !ok1 || !ok2 {
+		return packets.DecodeOptions{}, false
+	}
+	as4 := func(caps []packets.Capability) bool {
+		for _, c := range caps {
+			if c.Code() == packets.CapFourOctetAS {
+				return true
+			}
+		}
+		return false
+	}
+	addPath := func(caps []packets.Capability) packets.AddPathMode {
+		for _, c := range caps {
+			if ap, ok := c.(*packets.AddPathCapability); ok {
+				return ap.Mode(packets.IPv4Unicast)
+			}
+		}
+		return 0
+	}
+	return packets.DecodeOptions{
+		AddPath: addPath(local).CanSend() && addPath(remote).CanReceive(),
+		AS4:     as4(local) && as4(remote),
+	}, true
+}
+
+// 欠けているセグメントを読み飛ばす
+// 途中まで読み込んでいたMessageは不正なMessageとして出力する
+func (d *Dumper) skipGap(s *stream) {
+	if len(s.buf) > 0 && !s.resync {
+		d.truncated(s)
+	}
+	at := s.offset + len(s.buf)
+	missing := s.skipGap()
+	d.emit(s, dumpRecord{Offset: at, Error: fmt.Sprintf("%d bytes are missing in capture", missing)})
+}
+
+// すべてのストリームの残りのデータを出力する
+func (d *Dumper) finish() {
+	for _, s := range d.streams {
+		for len(s.pending) > 0 {
+			d.skipGap(s)
+			d.frame(s)
+		}
+		if len(s.buf) > 0 && !s.resync {
+			d.truncated(s)
+		}
+		s.buf = nil
+	}
+}
+
+// bufに残っている、途中で終わったMessageを出力する
+func (d *Dumper) truncated(s *stream) {
+	opts, _ := d.options(s)
+	var fe *packets.FormatError
+	if errors.As(packets.ValidateMessage(s.buf, opts), &fe) {
+		d.malformed(s, s.offset, s.offset+fe.Offset, fe.Reason, s.buf)
+	}
+}
+
+func (d *Dumper) malformed(s *stream, offset, errOffset int, reason string, b []byte) {
+	d.Malformed++
+	d.emit(s, dumpRecord{Offset: offset, ErrorOffset: &errOffset, Error: reason, Bytes: hex.EncodeToString(b)})
+}
+
+// 出力する1行
+type dumpRecord struct {
+	Time string `json:"time,omitempty"`
+	Src  string `json:"src,omitempty"`
+	Dst  string `json:"dst,omitempty"`
+	// ストリームの先頭からのMessageのオフセット
+	Offset  int             `json:"offset"`
+	Message packets.Message `json:"message,omitempty"`
+	Error   string          `json:"error,omitempty"`
+	// ストリームの先頭からの不正な位置のオフセット
+	ErrorOffset *int   `json:"error_offset,omitempty"`
+	Bytes       string `json:"bytes,omitempty"`
+}
+
+func (d *Dumper) emit(s *stream, r dumpRecord) {
+	if d.err != nil {
+		return
+	}
+	if s.src.IsValid() {
+		r.Time = s.time.Format(time.RFC3339Nano)
+		r.Src, r.Dst = s.src.String(), s.dst.String()
+	}
+	var line string
+	if d.JSON {
+		b, err := json.Marshal(r)
+		if err != nil {
+			d.err = err
+			return
+		}
+		line = string(b)
+	} else {
+		line = r.String()
+	}
+	_, d.err = fmt.Fprintln(d.Out, line)
+}
+
+func (r *dumpRecord) String() string {
+	var sb strings.Builder
+	if r.Src != "" {
+		fmt.Fprintf(&sb, "%s %s > %s ", r.Time, r.Src, r.Dst)
+	}
+	fmt.Fprintf(&sb, "[offset %d] ", r.Offset)
+	switch {
+	case r.Message != nil:
+		sb.WriteString(r.Message.String())
+	case r.ErrorOffset != nil:
+		fmt.Fprintf(&sb, "MALFORMED at offset %d: %s: %s", *r.ErrorOffset, r.Error, r.Bytes)
+	default:
+		sb.WriteString(r.Error)
+	}
+	return sb.String()
+}
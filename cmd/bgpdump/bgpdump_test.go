@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SotaUeda/gobgp/bgptype"
+	"github.com/SotaUeda/gobgp/packets"
+)
+
+// キャプチャファイルに書き込むTCPのセグメント
+type testSegment struct {
+	src, dst string
+	seq      uint32
+	syn      bool
+	payload  []byte
+}
+
+// Ethernet, IPv4, TCPのヘッダを付けたフレーム
+func (s testSegment) frame() []byte {
+	src, dst := netip.MustParseAddrPort(s.src), netip.MustParseAddrPort(s.dst)
+	tcp := make([]byte, 20, 20+len(s.payload))
+	binary.BigEndian.PutUint16(tcp[0:2], src.Port())
+	binary.BigEndian.PutUint16(tcp[2:4], dst.Port())
+	binary.BigEndian.PutUint32(tcp[4:8], s.seq)
+	tcp[12] = 5 << 4
+	tcp[13] = 0x10
+	if s.syn {
+		tcp[13] |= 0x02
+	}
+	tcp = append(tcp, s.payload...)
+	ip := make([]byte, 20, 20+len(tcp))
+	ip[0] = 0x45
+	binary.BigEndian.PutUint16(ip[2:4], uint16(20+len(tcp)))
+	ip[8], ip[9] = 64, IPPROTO_TCP
+	copy(ip[12:16], src.Addr().AsSlice())
+	copy(ip[16:20], dst.Addr().AsSlice())
+	ip = append(ip, tcp...)
+	eth := make([]byte, 14, 14+len(ip))
+	binary.BigEndian.PutUint16(eth[12:14], ETHERTYPE_IPV4)
+	return append(eth, ip...)
+}
+
+var testStart = time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+
+// i番目のセグメントの時刻
+func testTime(i int) time.Time {
+	return testStart.Add(time.Duration(i) * time.Millisecond)
+}
+
+// リトルエンディアン、マイクロ秒のpcapファイル
+func pcapFile(segs []testSegment) []byte {
+	le := binary.LittleEndian
+	b := le.AppendUint32(nil, PCAP_MAGIC)
+	b = le.AppendUint16(b, 2)
+	b = le.AppendUint16(b, 4)
+	b = append(b, make([]byte, 8)...)
+	b = le.AppendUint32(b, 65535)
+	b = le.AppendUint32(b, LINKTYPE_ETHERNET)
+	for i, s := range segs {
+		f, t := s.frame(), testTime(i)
+		b = le.AppendUint32(b, uint32(t.Unix()))
+		b = le.AppendUint32(b, uint32(t.Nanosecond()/1000))
+		b = le.AppendUint32(b, uint32(len(f)))
+		b = le.AppendUint32(b, uint32(len(f)))
+		b = append(b, f...)
+	}
+	return b
+}
+
+// ビッグエンディアン、ナノ秒 (if_tsresol = 9) のpcapngファイル
+func pcapngFile(segs []testSegment) []byte {
+	be := binary.BigEndian
+	block := func(b []byte, t uint32, body []byte) []byte {
+		for len(body)%4 != 0 {
+			body = append(body, 0)
+		}
+		b = be.AppendUint32(b, t)
+		b = be.AppendUint32(b, uint32(12+len(body)))
+		b = append(b, body...)
+		return be.AppendUint32(b, uint32(12+len(body)))
+	}
+	shb := be.AppendUint32(nil, 0x1a2b3c4d)
+	shb = be.AppendUint16(shb, 1)
+	shb = be.AppendUint16(shb, 0)
+	shb = be.AppendUint64(shb, ^uint64(0))
+	b := block(nil, PCAPNG_SHB, shb)
+	idb := be.AppendUint16(nil, LINKTYPE_ETHERNET)
+	idb = be.AppendUint16(idb, 0)
+	idb = be.AppendUint32(idb, 0)
+	idb = be.AppendUint16(idb, PCAPNG_IF_TSRESOL)
+	idb = be.AppendUint16(idb, 1)
+	idb = append(idb, 9, 0, 0, 0)
+	idb = append(idb, 0, 0, 0, 0)
+	b = block(b, PCAPNG_IDB, idb)
+	for i, s := range segs {
+		f, ts := s.frame(), uint64(testTime(i).UnixNano())
+		epb := be.AppendUint32(nil, 0)
+		epb = be.AppendUint32(epb, uint32(ts>>32))
+		epb = be.AppendUint32(epb, uint32(ts))
+		epb = be.AppendUint32(epb, uint32(len(f)))
+		epb = be.AppendUint32(epb, uint32(len(f)))
+		b = block(b, PCAPNG_EPB, append(epb, f...))
+	}
+	return b
+}
+
+func toBytes(t *testing.T, m packets.Message) []byte {
+	b, err := m.ToBytes()
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	return b
+}
+
+// 10.0.0.2:40000から10.0.0.1:179へ接続したセッションのキャプチャ
+// 10.0.0.1はADD-PATHで経路を送信し、10.0.0.2は受信する。
+// OpenMessageは分割して順序を入れ替え、KeepaliveMessageは再送し、
+// 最後にORIGINのAttribute Lengthが不正なUpdateMessageを送信する。
+// 期待する出力と、不正な位置のストリームの先頭からのオフセットを返す
+func testCapture(t *testing.T) ([]testSegment, []string, int) {
+	const speaker, client = "10.0.0.1:179", "10.0.0.2:40000"
+	clientOpen := packets.NewOpenMessage(65002, net.ParseIP("10.0.0.2"),
+		&packets.AddPathCapability{Families: []packets.AddPathFamily{{Family: packets.IPv4Unicast, Mode: packets.AddPathReceive}}})
+	speakerOpen := packets.NewOpenMessage(65001, net.ParseIP("10.0.0.1"),
+		&packets.AddPathCapability{Families: []packets.AddPathFamily{{Family: packets.IPv4Unicast, Mode: packets.AddPathSend}}})
+	origin := bgptype.IGP
+	nh := bgptype.NextHop(net.ParseIP("10.0.0.1").To4())
+	_, nw, _ := net.ParseCIDR("10.1.0.0/16")
+	um, err := packets.NewAddPathUpdateMessage(
+		[]bgptype.PathAttribute{&origin, bgptype.NewAsPath(true, 65001), &nh},
+		[]*net.IPNet{nw}, []uint32{7}, []*net.IPNet{}, []uint32{})
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	co, so, ub := toBytes(t, clientOpen), toBytes(t, speakerOpen), toBytes(t, um)
+	ka := toBytes(t, packets.NewKeepaliveMessage())
+	bad := bytes.Clone(ub)
+	// Header(19byte), Withdrawn Routes Length(2byte), Total Path Attribute Length(2byte)の次の
+	// ORIGINのAttribute Length
+	bad[25] = 2
+
+	segs := []testSegment{
+		{src: client, dst: speaker, seq: 1000, syn: true},
+		{src: speaker, dst: client, seq: 5000, syn: true},
+		{src: client, dst: speaker, seq: 1001 + 10, payload: co[10:]},
+		{src: client, dst: speaker, seq: 1001, payload: co[:10]},
+		{src: speaker, dst: client, seq: 5001, payload: so},
+		{src: speaker, dst: client, seq: 5001 + uint32(len(so)), payload: append(bytes.Clone(ub), ka...)},
+		{src: speaker, dst: client, seq: 5001 + uint32(len(so)+len(ub)), payload: ka},
+		{src: speaker, dst: client, seq: 5001 + uint32(len(so)+len(ub)+len(ka)), payload: bad},
+	}
+	prefix := func(i int, src, dst string) string {
+		return fmt.Sprintf("%s %s > %s ", testTime(i).Format(time.RFC3339Nano), src, dst)
+	}
+	badOffset := len(so) + len(ub) + len(ka)
+	want := []string{
+		prefix(3, client, speaker) + "[offset 0] " + clientOpen.String(),
+		prefix(4, speaker, client) + "[offset 0] " + speakerOpen.String(),
+		prefix(5, speaker, client) + fmt.Sprintf("[offset %d] ", len(so)) + um.String(),
+		prefix(5, speaker, client) + fmt.Sprintf("[offset %d] ", len(so)+len(ub)) + "KEEPALIVE",
+		prefix(7, speaker, client) + fmt.Sprintf("[offset %d] MALFORMED at offset %d: ", badOffset, badOffset+25) +
+			"Attribute Lengthが不正です。Type: 1, Length: 2: " + fmt.Sprintf("%x", bad),
+	}
+	return segs, want, badOffset + 25
+}
+
+// pcap, pcapngのキャプチャファイルから、TCPのストリームを組み立て直して
+// BGP Messageを出力し、不正なMessageの位置をストリームの先頭からのオフセットで出力することを確認する
+func TestDumpPcap(t *testing.T) {
+	segs, want, _ := testCapture(t)
+	for name, file := range map[string][]byte{"pcap": pcapFile(segs), "pcapng": pcapngFile(segs)} {
+		var out bytes.Buffer
+		d := &Dumper{Out: &out, Port: 179}
+		if err := d.Dump(bytes.NewReader(file)); err != nil {
+			t.Fatalf("%s: Error: %v", name, err)
+		}
+		got := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+		if len(got) != len(want) {
+			t.Fatalf("%s: Want: %d lines, Got: %q", name, len(want), got)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("%s: Want: %v, Got: %v", name, want[i], got[i])
+			}
+		}
+		if d.Malformed != 1 {
+			t.Errorf("%s: Want: %v, Got: %v", name, 1, d.Malformed)
+		}
+	}
+}
+
+// JSONで出力した場合に、各行がMessageか不正な位置を持つことを確認する
+func TestDumpPcapJSON(t *testing.T) {
+	segs, _, errOffset := testCapture(t)
+	var out bytes.Buffer
+	d := &Dumper{Out: &out, JSON: true, Port: 179}
+	if err := d.Dump(bytes.NewReader(pcapFile(segs))); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("Want: %v, Got: %q", 5, lines)
+	}
+	var update struct {
+		Src     string          `json:"src"`
+		Message json.RawMessage `json:"message"`
+	}
+	if err := json.Unmarshal([]byte(lines[2]), &update); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	m, err := packets.JSONToMessage(update.Message)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if um, ok := m.(*packets.UpdateMessage); !ok || update.Src != "10.0.0.1:179" ||
+		!um.AddPath || len(um.NLRIPathIDs) != 1 || um.NLRIPathIDs[0] != 7 {
+		t.Errorf("Want: add-path update from 10.0.0.1:179, Got: %s", lines[2])
+	}
+	var malformed struct {
+		ErrorOffset *int   `json:"error_offset"`
+		Error       string `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(lines[4]), &malformed); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if malformed.ErrorOffset == nil || *malformed.ErrorOffset != errOffset || malformed.Error == "" {
+		t.Errorf("Want: %v, Got: %s", errOffset, lines[4])
+	}
+}
+
+// 16進数のテキストを読み込み、Markerが壊れた部分を飛ばして次のMessageから出力することを確認する
+func TestDumpHex(t *testing.T) {
+	in := "# keepalive\n" +
+		"0xff 0xff 0xff 0xff 0xff 0xff 0xff 0xff 0xff 0xff 0xff 0xff 0xff 0xff 0xff 0xff 0x00 0x13 0x04\n" +
+		"ffffffff:ffffffff:ffff0000:ffffffff:00130400\n" +
+		"ffffffffffffffffffffffffffffffff001304\n"
+	var out bytes.Buffer
+	d := &Dumper{Out: &out}
+	if err := d.Dump(strings.NewReader(in)); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	want := "[offset 0] KEEPALIVE\n" +
+		"[offset 19] MALFORMED at offset 29: Markerがすべて1ではありません。: ffffffffffffffffffff0000ffffffff001304\n" +
+		"[offset 39] KEEPALIVE\n"
+	if out.String() != want {
+		t.Errorf("Want: %v, Got: %v", want, out.String())
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// キャプチャファイルから読み込んだ1つのパケット
+type packet struct {
+	time time.Time
+	// リンク層の種類 (LINKTYPE_*)
+	linkType uint32
+	data     []byte
+}
+
+// pcapかpcapngのファイルからパケットを順に読み込む
+// すべてのパケットを読み込んだ場合はio.EOFを返す
+type packetReader interface {
+	next() (*packet, error)
+}
+
+const (
+	// pcapのMagic Number。マイクロ秒とナノ秒の時刻がある
+	PCAP_MAGIC      = 0xa1b2c3d4
+	PCAP_MAGIC_NANO = 0xa1b23c4d
+	// pcapngのSection Header BlockのBlock Type
+	PCAPNG_SHB = 0x0a0d0d0a
+)
+
+// 先頭のバイト列がpcapかpcapngのMagic Numberであるか
+func isPcap(magic []byte) bool {
+	if len(magic) < 4 {
+		return false
+	}
+	for _, order := range []binary.ByteOrder{binary.BigEndian, binary.LittleEndian} {
+		switch order.Uint32(magic) {
+		case PCAP_MAGIC, PCAP_MAGIC_NANO, PCAPNG_SHB:
+			return true
+		}
+	}
+	return false
+}
+
+func newPacketReader(r *bufio.Reader) (packetReader, error) {
+	magic, err := r.Peek(4)
+	if err != nil {
+		return nil, err
+	}
+	if binary.BigEndian.Uint32(magic) == PCAPNG_SHB {
+		return &pcapngReader{r: r}, nil
+	}
+	return newPcapReader(r)
+}
+
+// pcap形式
+//
+// Global Header: Magic Number(4byte), Version(2byte, 2byte), Timezone(4byte),
+// Sigfigs(4byte), Snaplen(4byte), Link Type(4byte)
+// Record: Timestamp(4byte, 4byte), Captured Length(4byte), Original Length(4byte), Packet Data
+type pcapReader struct {
+	r        io.Reader
+	order    binary.ByteOrder
+	nano     bool
+	linkType uint32
+}
+
+func newPcapReader(r io.Reader) (*pcapReader, error) {
+	h := make([]byte, 24)
+	if _, err := io.ReadFull(r, h); err != nil {
+		return nil, err
+	}
+	p := &pcapReader{r: r}
+	for _, order := range []binary.ByteOrder{binary.BigEndian, binary.LittleEndian} {
+		switch order.Uint32(h[0:4]) {
+		case PCAP_MAGIC:
+			p.order = order
+		case PCAP_MAGIC_NANO:
+			p.order, p.nano = order, true
+		}
+	}
+	if p.order == nil {
+		return nil, fmt.Errorf("not a pcap file: magic %x", h[0:4])
+	}
+	p.linkType = p.order.Uint32(h[20:24])
+	return p, nil
+}
+
+func (p *pcapReader) next() (*packet, error) {
+	h := make([]byte, 16)
+	if _, err := io.ReadFull(p.r, h); err != nil {
+		return nil, err
+	}
+	sec, frac := int64(p.order.Uint32(h[0:4])), int64(p.order.Uint32(h[4:8]))
+	if !p.nano {
+		frac *= 1000
+	}
+	data := make([]byte, p.order.Uint32(h[8:12]))
+	if _, err := io.ReadFull(p.r, data); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	return &packet{time: time.Unix(sec, frac).UTC(), linkType: p.linkType, data: data}, nil
+}
+
+// pcapng形式
+//
+// Block: Block Type(4byte), Block Total Length(4byte), Block Body, Block Total Length(4byte)
+// Section Header Blockのバイトオーダーで、以降のBlockを読み込む。
+// Enhanced Packet Block, Simple Packet Blockのパケットを返し、それ以外のBlockは読み飛ばす。
+type pcapngReader struct {
+	r     io.Reader
+	order binary.ByteOrder
+	// Sectionに含まれるInterface Description Blockの順
+	ifaces []pcapngInterface
+}
+
+type pcapngInterface struct {
+	linkType uint32
+	// Timestampの単位の秒数
+	resolution float64
+}
+
+const (
+	PCAPNG_IDB = 1
+	PCAPNG_SPB = 3
+	PCAPNG_EPB = 6
+	// Interface Description BlockのTimestampの単位を表すOption
+	PCAPNG_IF_TSRESOL = 9
+)
+
+func (p *pcapngReader) next() (*packet, error) {
+	for {
+		h := make([]byte, 8)
+		if _, err := io.ReadFull(p.r, h); err != nil {
+			return nil, err
+		}
+		if binary.BigEndian.Uint32(h[0:4]) == PCAPNG_SHB {
+			if err := p.readSectionHeader(h); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if p.order == nil {
+			return nil, errors.New("pcapng file does not start with section header block")
+		}
+		l := p.order.Uint32(h[4:8])
+		if l < 12 || l%4 != 0 {
+			return nil, fmt.Errorf("invalid pcapng block length %d", l)
+		}
+		body := make([]byte, l-8)
+		if _, err := io.ReadFull(p.r, body); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		body = body[:len(body)-4]
+		switch p.order.Uint32(h[0:4]) {
+		case PCAPNG_IDB:
+			if len(body) < 8 {
+				return nil, errors.New("pcapng interface description block is too short")
+			}
+			p.ifaces = append(p.ifaces, pcapngInterface{
+				linkType:   uint32(p.order.Uint16(body[0:2])),
+				resolution: p.resolution(body[8:]),
+			})
+		case PCAPNG_EPB:
+			if len(body) < 20 {
+				return nil, errors.New("pcapng enhanced packet block is too short")
+			}
+			id := p.order.Uint32(body[0:4])
+			if int(id) >= len(p.ifaces) {
+				return nil, fmt.Errorf("pcapng interface %d is not described", id)
+			}
+			iface := p.ifaces[id]
+			ts := uint64(p.order.Uint32(body[4:8]))<<32 | uint64(p.order.Uint32(body[8:12]))
+			caplen := p.order.Uint32(body[12:16])
+			if int(caplen) > len(body)-20 {
+				return nil, fmt.Errorf("invalid pcapng captured length %d", caplen)
+			}
+			return &packet{
+				time:     timestamp(ts, iface.resolution),
+				linkType: iface.linkType,
+				data:     body[20 : 20+caplen],
+			}, nil
+		case PCAPNG_SPB:
+			if len(body) < 4 || len(p.ifaces) == 0 {
+				return nil, errors.New("invalid pcapng simple packet block")
+			}
+			caplen := min(int(p.order.Uint32(body[0:4])), len(body)-4)
+			return &packet{linkType: p.ifaces[0].linkType, data: body[4 : 4+caplen]}, nil
+		}
+	}
+}
+
+// Section Header Blockを読み込み、バイトオーダーとInterfaceを初期化する
+func (p *pcapngReader) readSectionHeader(h []byte) error {
+	bom := make([]byte, 4)
+	if _, err := io.ReadFull(p.r, bom); err != nil {
+		return unexpectedEOF(err)
+	}
+	switch {
+	case binary.BigEndian.Uint32(bom) == 0x1a2b3c4d:
+		p.order = binary.BigEndian
+	case binary.LittleEndian.Uint32(bom) == 0x1a2b3c4d:
+		p.order = binary.LittleEndian
+	default:
+		return fmt.Errorf("invalid pcapng byte-order magic %x", bom)
+	}
+	l := p.order.Uint32(h[4:8])
+	if l < 16 || l%4 != 0 {
+		return fmt.Errorf("invalid pcapng block length %d", l)
+	}
+	if _, err := io.CopyN(io.Discard, p.r, int64(l-12)); err != nil {
+		return unexpectedEOF(err)
+	}
+	p.ifaces = nil
+	return nil
+}
+
+// Interface Description BlockのOptionからTimestampの単位を求める
+// 指定されていない場合はマイクロ秒
+func (p *pcapngReader) resolution(opts []byte) float64 {
+	for i := 0; i+4 <= len(opts); {
+		code, l := p.order.Uint16(opts[i:i+2]), int(p.order.Uint16(opts[i+2:i+4]))
+		if code == 0 || i+4+l > len(opts) {
+			break
+		}
+		if code == PCAPNG_IF_TSRESOL && l >= 1 {
+			v := opts[i+4]
+			if v&0x80 != 0 {
+				return math.Pow(2, -float64(v&0x7f))
+			}
+			return math.Pow(10, -float64(v))
+		}
+		i += 4 + (l+3)/4*4
+	}
+	return 1e-6
+}
+
+func timestamp(ts uint64, resolution float64) time.Time {
+	if resolution == 1e-6 {
+		return time.UnixMicro(int64(ts)).UTC()
+	}
+	if resolution == 1e-9 {
+		return time.Unix(0, int64(ts)).UTC()
+	}
+	sec := float64(ts) * resolution
+	whole := math.Floor(sec)
+	return time.Unix(int64(whole), int64((sec-whole)*1e9)).UTC()
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"time"
+)
+
+// リンク層の種類 (https://www.tcpdump.org/linktypes.html)
+const (
+	LINKTYPE_NULL        = 0
+	LINKTYPE_ETHERNET    = 1
+	LINKTYPE_RAW_BSD     = 12
+	LINKTYPE_RAW_OPENBSD = 14
+	LINKTYPE_RAW         = 101
+	LINKTYPE_LOOP        = 108
+	LINKTYPE_LINUX_SLL   = 113
+	LINKTYPE_LINUX_SLL2  = 276
+)
+
+const (
+	ETHERTYPE_IPV4 = 0x0800
+	ETHERTYPE_IPV6 = 0x86dd
+	ETHERTYPE_VLAN = 0x8100
+	ETHERTYPE_QINQ = 0x88a8
+	IPPROTO_TCP    = 6
+)
+
+// パケットに含まれるTCPのセグメント
+type segment struct {
+	src, dst netip.AddrPort
+	seq      uint32
+	syn      bool
+	payload  []byte
+}
+
+// パケットからTCPのセグメントを取り出す
+// IPv4, IPv6のTCP以外のパケットと、フラグメントされたパケットはfalseを返す
+func decodePacket(p *packet) (*segment, bool) {
+	b, ok := linkPayload(p.linkType, p.data)
+	if !ok || len(b) == 0 {
+		return nil, false
+	}
+	var src, dst netip.Addr
+	switch b[0] >> 4 {
+	case 4:
+		src, dst, b, ok = ipv4Payload(b)
+	case 6:
+		src, dst, b, ok = ipv6Payload(b)
+	default:
+		return nil, false
+	}
+	if !ok || len(b) < 20 {
+		return nil, false
+	}
+	off := int(b[12]>>4) * 4
+	if off < 20 || len(b) < off {
+		return nil, false
+	}
+	return &segment{
+		src:     netip.AddrPortFrom(src, binary.BigEndian.Uint16(b[0:2])),
+		dst:     netip.AddrPortFrom(dst, binary.BigEndian.Uint16(b[2:4])),
+		seq:     binary.BigEndian.Uint32(b[4:8]),
+		syn:     b[13]&0x02 != 0,
+		payload: b[off:],
+	}, true
+}
+
+// リンク層のヘッダを取り除き、IPパケットを返す
+func linkPayload(linkType uint32, b []byte) ([]byte, bool) {
+	var etherType uint16
+	switch linkType {
+	case LINKTYPE_RAW, LINKTYPE_RAW_BSD, LINKTYPE_RAW_OPENBSD:
+		return b, true
+	case LINKTYPE_NULL, LINKTYPE_LOOP:
+		// Address Familyの値はOSごとに異なるため、IPのバージョンで判断する
+		if len(b) < 4 {
+			return nil, false
+		}
+		return b[4:], true
+	case LINKTYPE_ETHERNET:
+		if len(b) < 14 {
+			return nil, false
+		}
+		etherType, b = binary.BigEndian.Uint16(b[12:14]), b[14:]
+		for (etherType == ETHERTYPE_VLAN || etherType == ETHERTYPE_QINQ) && len(b) >= 4 {
+			etherType, b = binary.BigEndian.Uint16(b[2:4]), b[4:]
+		}
+	case LINKTYPE_LINUX_SLL:
+		if len(b) < 16 {
+			return nil, false
+		}
+		etherType, b = binary.BigEndian.Uint16(b[14:16]), b[16:]
+	case LINKTYPE_LINUX_SLL2:
+		if len(b) < 20 {
+			return nil, false
+		}
+		etherType, b = binary.BigEndian.Uint16(b[0:2]), b[20:]
+	default:
+		return nil, false
+	}
+	if etherType != ETHERTYPE_IPV4 && etherType != ETHERTYPE_IPV6 {
+		return nil, false
+	}
+	return b, true
+}
+
+func ipv4Payload(b []byte) (src, dst netip.Addr, payload []byte, ok bool) {
+	if len(b) < 20 {
+		return
+	}
+	ihl := int(b[0]&0x0f) * 4
+	total := int(binary.BigEndian.Uint16(b[2:4]))
+	if ihl < 20 || total < ihl || len(b) < ihl {
+		return
+	}
+	// More Fragmentsが立っているか、Fragment Offsetが0でない
+	if binary.BigEndian.Uint16(b[6:8])&0x3fff != 0 || b[9] != IPPROTO_TCP {
+		return
+	}
+	// Ethernetのパディングを取り除く
+	if total < len(b) {
+		b = b[:total]
+	}
+	src, _ = netip.AddrFromSlice(b[12:16])
+	dst, _ = netip.AddrFromSlice(b[16:20])
+	return src, dst, b[ihl:], true
+}
+
+func ipv6Payload(b []byte) (src, dst netip.Addr, payload []byte, ok bool) {
+	if len(b) < 40 {
+		return
+	}
+	if l := 40 + int(binary.BigEndian.Uint16(b[4:6])); l < len(b) {
+		b = b[:l]
+	}
+	src, _ = netip.AddrFromSlice(b[8:24])
+	dst, _ = netip.AddrFromSlice(b[24:40])
+	next, rest := b[6], b[40:]
+	for {
+		switch next {
+		case IPPROTO_TCP:
+			return src, dst, rest, true
+		case 0, 43, 60: // Hop-by-Hop Options, Routing, Destination Options
+			if len(rest) < 8 || len(rest) < (int(rest[1])+1)*8 {
+				return
+			}
+			next, rest = rest[0], rest[(int(rest[1])+1)*8:]
+		default: // Fragment (44) などは読み込まない
+			return
+		}
+	}
+}
+
+// 順序の入れ替わったセグメントを保留しておく最大の大きさ
+// これを超えた場合は、キャプチャでセグメントが欠けたとみなして読み飛ばす
+const MAX_PENDING_BYTES = 1 << 20
+
+// 一方向のTCPのストリーム
+// シーケンス番号の順にセグメントを並べ直し、重複した部分を取り除いてbufに追加する。
+type stream struct {
+	src, dst netip.AddrPort
+	// 次に期待するシーケンス番号
+	next    uint32
+	started bool
+	// 先に届いたセグメント
+	pending      map[uint32][]byte
+	pendingBytes int
+	// まだMessageとして切り出していないバイト列
+	buf []byte
+	// buf[0]のストリームの先頭からのオフセット
+	offset int
+	// 最後にbufに追加したセグメントの時刻
+	time time.Time
+	// Messageの境界が分からず、次のMarkerを探す必要があるか
+	resync bool
+}
+
+func newStream(src, dst netip.AddrPort) *stream {
+	return &stream{src: src, dst: dst, pending: make(map[uint32][]byte)}
+}
+
+// セグメントをストリームに追加する
+func (s *stream) add(seg *segment, t time.Time) {
+	seq := seg.seq
+	if seg.syn {
+		// 同じアドレスとポートで新しいコネクションが始まった
+		*s = *newStream(s.src, s.dst)
+		seq++
+		s.started, s.next = true, seq
+	}
+	if !s.started {
+		// SYNより後からキャプチャされたストリームは、最初のMarkerから読み込む
+		s.started, s.next, s.resync = true, seq, true
+	}
+	if len(seg.payload) == 0 {
+		return
+	}
+	s.time = t
+	if int32(seq-s.next) > 0 {
+		if len(seg.payload) > len(s.pending[seq]) {
+			s.pendingBytes += len(seg.payload) - len(s.pending[seq])
+			s.pending[seq] = seg.payload
+		}
+		return
+	}
+	s.append(seq, seg.payload)
+	s.drain()
+}
+
+// seqから始まるデータのうち、まだ受け取っていない部分をbufに追加する
+func (s *stream) append(seq uint32, data []byte) {
+	dup := int(int32(s.next - seq))
+	if dup >= len(data) {
+		return
+	}
+	s.buf = append(s.buf, data[dup:]...)
+	s.next += uint32(len(data) - dup)
+}
+
+// 続きのデータになった保留中のセグメントをbufに追加する
+func (s *stream) drain() {
+	for progress := true; progress; {
+		progress = false
+		for seq, data := range s.pending {
+			if int32(seq-s.next) > 0 {
+				continue
+			}
+			delete(s.pending, seq)
+			s.pendingBytes -= len(data)
+			s.append(seq, data)
+			progress = true
+		}
+	}
+}
+
+// 欠けているデータを読み飛ばし、保留中の最も前のセグメントから読み込みを続ける
+// bufに残っていたデータは破棄し、欠けていたバイト数を返す
+func (s *stream) skipGap() int {
+	first, ok := uint32(0), false
+	for seq := range s.pending {
+		if !ok || int32(seq-first) < 0 {
+			first, ok = seq, true
+		}
+	}
+	missing := int(first - s.next)
+	s.offset += len(s.buf) + missing
+	s.buf, s.next, s.resync = nil, first, true
+	s.drain()
+	return missing
+}
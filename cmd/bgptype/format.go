@@ -0,0 +1,404 @@
+package bgptype
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// PathAttributeの文字列表現とJSON表現
+//
+// 文字列は "ORIGIN: IGP", "AS_PATH: 65001 {65002 65003}", "NEXT_HOP: 10.0.0.1" のように
+// 属性名と値を並べる。
+// JSONは {"type": "ORIGIN", "value": "IGP"} のように属性名と値を持つオブジェクトにする。
+// AS_PATHの値は文字列と同じく、AsSequenceのAS番号を空白区切りで、AsSetのAS番号を{}で囲んで表す。
+
+// PathAttributeのJSON表現
+type pathAttributeJSON struct {
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+func marshalPathAttribute(t string, v any) ([]byte, error) {
+	pa := pathAttributeJSON{Type: t}
+	if v != nil {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		pa.Value = b
+	}
+	return json.Marshal(pa)
+}
+
+// JSONのtypeがtであることを確認し、valueをvに変換する
+func unmarshalPathAttribute(b []byte, t string, v any) error {
+	var pa pathAttributeJSON
+	if err := json.Unmarshal(b, &pa); err != nil {
+		return err
+	}
+	if pa.Type != t {
+		return fmt.Errorf("path attribute type is %q, not %q", pa.Type, t)
+	}
+	if v == nil {
+		return nil
+	}
+	if len(pa.Value) == 0 {
+		return fmt.Errorf("%s has no value", t)
+	}
+	return json.Unmarshal(pa.Value, v)
+}
+
+func (o Origin) Show() string {
+	switch o {
+	case IGP:
+		return "IGP"
+	case EGP:
+		return "EGP"
+	case INCOMPLETE:
+		return "INCOMPLETE"
+	default:
+		return fmt.Sprintf("%d", int(o))
+	}
+}
+
+func (o *Origin) String() string {
+	return "ORIGIN: " + o.Show()
+}
+
+func (o *Origin) MarshalJSON() ([]byte, error) {
+	return marshalPathAttribute("ORIGIN", o.Show())
+}
+
+func (o *Origin) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := unmarshalPathAttribute(b, "ORIGIN", &s); err != nil {
+		return err
+	}
+	for _, v := range []Origin{IGP, EGP, INCOMPLETE} {
+		if v.Show() == s {
+			*o = v
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown origin %q", s)
+}
+
+// AS番号を空白区切りにする
+func showASes(ases []AutonomousSystemNumber) string {
+	ss := make([]string, 0, len(ases))
+	for _, as := range ases {
+		ss = append(ss, strconv.Itoa(int(as)))
+	}
+	return strings.Join(ss, " ")
+}
+
+// AsSequenceとAsSetを続けて並べたAS_PATHの値の文字列
+func showAsPath(aps []AsPath) string {
+	ss := make([]string, 0, len(aps))
+	for _, ap := range aps {
+		switch ap.(type) {
+		case *AsSet:
+			ss = append(ss, "{"+showASes(ap.Get())+"}")
+		default:
+			if len(ap.Get()) > 0 {
+				ss = append(ss, showASes(ap.Get()))
+			}
+		}
+	}
+	return strings.Join(ss, " ")
+}
+
+// "65001 {65002 65003}" のようなAS_PATHの値をセグメントごとのAsSequence, AsSetにする
+// 空の場合は空のAsSequenceにする
+func parseAsPath(s string) ([]AsPath, error) {
+	aps := []AsPath{}
+	var seq *AsSequence
+	for rest := strings.TrimSpace(s); rest != ""; rest = strings.TrimSpace(rest) {
+		if rest[0] == '{' {
+			end := strings.IndexByte(rest, '}')
+			if end < 0 {
+				return nil, fmt.Errorf("as set is not closed: %q", s)
+			}
+			set := &AsSet{}
+			for _, f := range strings.Fields(rest[1:end]) {
+				as, err := parseAS(f)
+				if err != nil {
+					return nil, err
+				}
+				if err := set.Add(as); err != nil {
+					return nil, err
+				}
+			}
+			aps = append(aps, set)
+			seq = nil
+			rest = rest[end+1:]
+			continue
+		}
+		f, r, _ := strings.Cut(rest, " ")
+		if i := strings.IndexByte(f, '{'); i > 0 {
+			f, r = f[:i], f[i:]+" "+r
+		}
+		as, err := parseAS(f)
+		if err != nil {
+			return nil, err
+		}
+		if seq == nil {
+			seq = &AsSequence{}
+			aps = append(aps, seq)
+		}
+		seq.Add(as)
+		rest = r
+	}
+	if len(aps) == 0 {
+		aps = append(aps, &AsSequence{})
+	}
+	return aps, nil
+}
+
+func parseAS(s string) (AutonomousSystemNumber, error) {
+	as, err := strconv.ParseUint(s, 10, 16)
+	if err != nil {
+		return 0, fmt.Errorf("invalid as number %q", s)
+	}
+	return AutonomousSystemNumber(as), nil
+}
+
+func (seq *AsSequence) String() string {
+	return "AS_PATH: " + showAsPath([]AsPath{seq})
+}
+
+func (seq *AsSequence) MarshalJSON() ([]byte, error) {
+	return marshalPathAttribute("AS_PATH", showAsPath([]AsPath{seq}))
+}
+
+func (seq *AsSequence) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := unmarshalPathAttribute(b, "AS_PATH", &s); err != nil {
+		return err
+	}
+	aps, err := parseAsPath(s)
+	if err != nil {
+		return err
+	}
+	v, ok := aps[0].(*AsSequence)
+	if len(aps) != 1 || !ok {
+		return fmt.Errorf("as path %q is not a single as sequence", s)
+	}
+	*seq = *v
+	return nil
+}
+
+func (set *AsSet) String() string {
+	return "AS_PATH: " + showAsPath([]AsPath{set})
+}
+
+func (set *AsSet) MarshalJSON() ([]byte, error) {
+	return marshalPathAttribute("AS_PATH", showAsPath([]AsPath{set}))
+}
+
+func (set *AsSet) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := unmarshalPathAttribute(b, "AS_PATH", &s); err != nil {
+		return err
+	}
+	aps, err := parseAsPath(s)
+	if err != nil {
+		return err
+	}
+	v, ok := aps[0].(*AsSet)
+	if len(aps) != 1 || !ok {
+		return fmt.Errorf("as path %q is not a single as set", s)
+	}
+	*set = *v
+	return nil
+}
+
+func (n *NextHop) String() string {
+	return "NEXT_HOP: " + net.IP(*n).String()
+}
+
+func (n *NextHop) MarshalJSON() ([]byte, error) {
+	return marshalPathAttribute("NEXT_HOP", net.IP(*n).String())
+}
+
+func (n *NextHop) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := unmarshalPathAttribute(b, "NEXT_HOP", &s); err != nil {
+		return err
+	}
+	ip := net.ParseIP(s).To4()
+	if ip == nil {
+		return fmt.Errorf("invalid next hop %q", s)
+	}
+	*n = NextHop(ip)
+	return nil
+}
+
+func (a *AtomicAggregate) String() string {
+	return "ATOMIC_AGGREGATE"
+}
+
+func (a *AtomicAggregate) MarshalJSON() ([]byte, error) {
+	return marshalPathAttribute("ATOMIC_AGGREGATE", nil)
+}
+
+func (a *AtomicAggregate) UnmarshalJSON(b []byte) error {
+	return unmarshalPathAttribute(b, "ATOMIC_AGGREGATE", nil)
+}
+
+type aggregatorJSON struct {
+	AS      AutonomousSystemNumber `json:"as"`
+	Address string                 `json:"address"`
+}
+
+func (a *Aggregator) String() string {
+	return fmt.Sprintf("AGGREGATOR: %d %s", a.AS, a.Address)
+}
+
+func (a *Aggregator) MarshalJSON() ([]byte, error) {
+	return marshalPathAttribute("AGGREGATOR", aggregatorJSON{a.AS, a.Address.String()})
+}
+
+func (a *Aggregator) UnmarshalJSON(b []byte) error {
+	var v aggregatorJSON
+	if err := unmarshalPathAttribute(b, "AGGREGATOR", &v); err != nil {
+		return err
+	}
+	ip := net.ParseIP(v.Address).To4()
+	if ip == nil {
+		return fmt.Errorf("invalid aggregator address %q", v.Address)
+	}
+	a.AS, a.Address = v.AS, ip
+	return nil
+}
+
+// Attr Type Codeと、Attribute Flagsから始まる属性全体のbytesを16進数で表す
+func (d *DontKnow) String() string {
+	code := -1
+	if len(*d) >= 2 {
+		code = int((*d)[1])
+	}
+	return fmt.Sprintf("UNKNOWN(%d): %s", code, hex.EncodeToString(*d))
+}
+
+func (d *DontKnow) MarshalJSON() ([]byte, error) {
+	return marshalPathAttribute("UNKNOWN", hex.EncodeToString(*d))
+}
+
+func (d *DontKnow) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := unmarshalPathAttribute(b, "UNKNOWN", &s); err != nil {
+		return err
+	}
+	v, err := hex.DecodeString(s)
+	if err != nil {
+		return fmt.Errorf("invalid unknown attribute %q: %w", s, err)
+	}
+	*d = v
+	return nil
+}
+
+// UpdateMessageなどに含まれるPathAttributeの並び
+// 続けて並んだAsSequenceとAsSetは、1つのAS_PATH属性として表す。
+type PathAttributes []PathAttribute
+
+func (pas PathAttributes) String() string {
+	ss := []string{}
+	for _, f := range pas.group() {
+		ss = append(ss, f.String())
+	}
+	return strings.Join(ss, ", ")
+}
+
+func (pas PathAttributes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(pas.group())
+}
+
+type formatter interface {
+	fmt.Stringer
+	json.Marshaler
+}
+
+// 1つのAS_PATH属性に含まれるAsSequenceとAsSetのセグメント
+type asPathSegments []AsPath
+
+func (aps asPathSegments) String() string {
+	return "AS_PATH: " + showAsPath(aps)
+}
+
+func (aps asPathSegments) MarshalJSON() ([]byte, error) {
+	return marshalPathAttribute("AS_PATH", showAsPath(aps))
+}
+
+// 続けて並んだAsSequenceとAsSetをasPathSegmentsにまとめる
+func (pas PathAttributes) group() []formatter {
+	fs := []formatter{}
+	var aps asPathSegments
+	for _, pa := range pas {
+		if ap, ok := pa.(AsPath); ok {
+			aps = append(aps, ap)
+			continue
+		}
+		if aps != nil {
+			fs = append(fs, aps)
+			aps = nil
+		}
+		fs = append(fs, pa)
+	}
+	if aps != nil {
+		fs = append(fs, aps)
+	}
+	return fs
+}
+
+func (pas *PathAttributes) UnmarshalJSON(b []byte) error {
+	var raws []json.RawMessage
+	if err := json.Unmarshal(b, &raws); err != nil {
+		return err
+	}
+	v := PathAttributes{}
+	for _, raw := range raws {
+		var pa pathAttributeJSON
+		if err := json.Unmarshal(raw, &pa); err != nil {
+			return err
+		}
+		var a PathAttribute
+		switch pa.Type {
+		case "ORIGIN":
+			a = new(Origin)
+		case "AS_PATH":
+			var s string
+			if err := json.Unmarshal(pa.Value, &s); err != nil {
+				return err
+			}
+			aps, err := parseAsPath(s)
+			if err != nil {
+				return err
+			}
+			for _, ap := range aps {
+				v = append(v, ap)
+			}
+			continue
+		case "NEXT_HOP":
+			a = new(NextHop)
+		case "ATOMIC_AGGREGATE":
+			a = new(AtomicAggregate)
+		case "AGGREGATOR":
+			a = new(Aggregator)
+		case "UNKNOWN":
+			a = new(DontKnow)
+		default:
+			return fmt.Errorf("unknown path attribute type %q", pa.Type)
+		}
+		if err := json.Unmarshal(raw, a); err != nil {
+			return err
+		}
+		v = append(v, a)
+	}
+	*pas = v
+	return nil
+}
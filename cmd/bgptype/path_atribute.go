@@ -3,12 +3,15 @@ package bgptype
 import (
 	"fmt"
 	"net"
+	"sort"
 )
 
 // PathAttributeの種類
 // Origin
 // AsPathAttribute
 // NextHop
+// AtomicAggregate
+// Aggregator
 // DontKnow	対応していないPathAtribute用
 //
 // PathAtributeのBytes表現は関数として用意する
@@ -16,6 +19,9 @@ type PathAttribute interface {
 	BytesLen() uint16
 	ToBytes() []byte
 	ToPA([]byte) error
+	// "ORIGIN: IGP" のような属性名と値の文字列
+	String() string
+	MarshalJSON() ([]byte, error)
 }
 
 // PathAttributeのフォーマット
@@ -25,7 +31,7 @@ type PathAttribute interface {
 // Partial Bit (1 bit): Partial(1), Complete(0) ※Well-known Attributeの場合は(0)
 // Extended Length Bit (1 bit): PathAttributeのオクテット数が1のとき(0), 2のとき(1)
 // Reserved (4 bit): 用途はない。すべて0
-// Attr Type Code (8 bit): Origin(1), AS_PATH(2), NEXT_HOP(3), その他(4-255). ここではOrigin, AS_PATH, NEXT_HOPのみ実装
+// Attr Type Code (8 bit): Origin(1), AS_PATH(2), NEXT_HOP(3), その他(4-255). ここではOrigin, AS_PATH, NEXT_HOP, ATOMIC_AGGREGATE(6), AGGREGATOR(7)のみ実装
 // Attribute Length (8 or 16 bit): Attribute Valueのオクテット数を表す符号なし整数
 // Attribute Value (variable): Attr Type Codeによって異なる
 
@@ -91,6 +97,8 @@ type AsPath interface {
 	Add(AutonomousSystemNumber) error
 	Get() []AutonomousSystemNumber
 	Contains(AutonomousSystemNumber) bool
+	String() string
+	MarshalJSON() ([]byte, error)
 }
 
 func NewAsPath(isSeq bool, as ...AutonomousSystemNumber) AsPath {
@@ -122,32 +130,17 @@ func (seq *AsSequence) BytesLen() uint16 {
 // Segment Type, Path Segment Length, Path Segment Value
 // 3つから構成される
 func (seq *AsSequence) ToBytes() []byte {
-	attF := byte(0b01000000)
-	attTC := byte(2)
-	bLen := len(*seq) * 2
-	bLen += 2 // Segment TypeとSegment Lengthの2オクテット
-	var attL []byte
-	if bLen < 256 {
-		attL = []byte{byte(bLen)}
-	} else {
-		attF += 0b00010000 // Attribute Lengthがtwo octetsなので4bit目を1にする
-		attL = []byte{byte(bLen >> 8), byte(bLen)}
-	}
-	attV := make([]byte, 0)
-	// Segment Type
-	st := byte(2)
-	// Segment Length
-	sl := byte(len(*seq))
-	attV = append(attV, st, sl)
-	// Segment Value
+	return asPathToBytes(seq.segmentBytes(false))
+}
+
+// AS_PATHのセグメント部分(Segment Type, Segment Length, Segment Value)
+// as4がtrueの場合はASを4byteでエンコードする
+func (seq *AsSequence) segmentBytes(as4 bool) []byte {
+	b := []byte{2, byte(len(*seq))}
 	for _, as := range *seq {
-		attV = append(attV, byte(as>>8), byte(as))
+		b = appendAS(b, as, as4)
 	}
-	bytes := make([]byte, 0)
-	bytes = append(bytes, attF, attTC)
-	bytes = append(bytes, attL...)
-	bytes = append(bytes, attV...)
-	return bytes
+	return b
 }
 
 func (seq *AsSequence) ToPA(b []byte) error {
@@ -213,31 +206,41 @@ func (set *AsSet) BytesLen() uint16 {
 // Segment Type, Path Segment Length, Path Segment Value
 // 3つから構成される
 func (set *AsSet) ToBytes() []byte {
+	return asPathToBytes(set.segmentBytes(false))
+}
+
+// AS_PATHのセグメント部分(Segment Type, Segment Length, Segment Value)
+// 同じ集合が常に同じbytesになるように、ASは昇順に並べる
+func (set *AsSet) segmentBytes(as4 bool) []byte {
+	b := []byte{1, byte(len(*set))}
+	for _, as := range set.Get() {
+		b = appendAS(b, as, as4)
+	}
+	return b
+}
+
+func appendAS(b []byte, as AutonomousSystemNumber, as4 bool) []byte {
+	if as4 {
+		b = append(b, 0, 0)
+	}
+	return append(b, byte(as>>8), byte(as))
+}
+
+// AS_PATHのセグメントをAS_PATH属性のbytesにする
+func asPathToBytes(segs []byte) []byte {
 	attF := byte(0b01000000)
-	attTC := byte(1)
-	bLen := 2 * len(*set)
-	bLen += 2 // Segment TypeとSegment Lengthの2オクテット
+	attTC := byte(2)
 	var attL []byte
-	if bLen < 256 {
-		attL = []byte{byte(bLen)}
+	if len(segs) < 256 {
+		attL = []byte{byte(len(segs))}
 	} else {
 		attF += 0b00010000 // Attribute Lengthがtwo octetsなので4bit目を1にする
-		attL = []byte{byte(bLen >> 8), byte(bLen)}
-	}
-	attV := make([]byte, 0)
-	// Segment Type
-	st := byte(1)
-	// Segment Length
-	sl := byte(len(*set))
-	attV = append(attV, st, sl)
-	// Segment Value
-	for as := range *set {
-		attV = append(attV, byte(as>>8), byte(as))
+		attL = []byte{byte(len(segs) >> 8), byte(len(segs))}
 	}
 	bytes := make([]byte, 0)
 	bytes = append(bytes, attF, attTC)
 	bytes = append(bytes, attL...)
-	bytes = append(bytes, attV...)
+	bytes = append(bytes, segs...)
 	return bytes
 }
 
@@ -259,6 +262,7 @@ func (set *AsSet) ToPA(b []byte) error {
 	if len(b) < 2+int(sl)*2 {
 		return fmt.Errorf("AS Path Attribute Length is too short")
 	}
+	*set = AsSet{}
 	for i := 0; i < int(sl); i++ {
 		as := AutonomousSystemNumber(b[2+i*2])<<8 + AutonomousSystemNumber(b[2+i*2+1])
 		err := set.Add(as)
@@ -270,6 +274,9 @@ func (set *AsSet) ToPA(b []byte) error {
 }
 
 func (set *AsSet) Add(as AutonomousSystemNumber) error {
+	if *set == nil {
+		*set = AsSet{}
+	}
 	if _, exists := (*set)[as]; exists {
 		return fmt.Errorf("AS %d already exists", as)
 	}
@@ -282,6 +289,7 @@ func (set *AsSet) Get() []AutonomousSystemNumber {
 	for key := range *set {
 		keys = append(keys, key)
 	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
 	return keys
 }
 
@@ -315,6 +323,65 @@ func (n *NextHop) ToPA(b []byte) error {
 	return nil
 }
 
+// 経路集約によってAS Pathの情報が失われていることを示す (Attr Type Code 6)
+// Well-known Discretionary Attributeで、値を持たない
+type AtomicAggregate struct{}
+
+func (a *AtomicAggregate) BytesLen() uint16 {
+	return 3
+}
+
+func (a *AtomicAggregate) ToBytes() []byte {
+	attF := byte(0b01000000)
+	attTC := byte(6)
+	attL := byte(0)
+	return []byte{attF, attTC, attL}
+}
+
+func (a *AtomicAggregate) ToPA(b []byte) error {
+	if len(b) != 0 {
+		return fmt.Errorf("Atomic Aggregate Attribute Length is not 0")
+	}
+	return nil
+}
+
+// 経路を集約したルーターのAS番号とIPアドレス (Attr Type Code 7)
+// Optional Transitive Attribute
+type Aggregator struct {
+	AS      AutonomousSystemNumber
+	Address net.IP
+}
+
+func (a *Aggregator) BytesLen() uint16 {
+	return 9
+}
+
+func (a *Aggregator) ToBytes() []byte {
+	attF := byte(0b11000000)
+	attTC := byte(7)
+	attL := byte(6)
+	bytes := []byte{attF, attTC, attL, byte(a.AS >> 8), byte(a.AS)}
+	bytes = append(bytes, a.Address.To4()...)
+	return bytes
+}
+
+// AS番号を4byteでエンコードしたbytes
+func (a *Aggregator) toBytesAS4() []byte {
+	bytes := []byte{0b11000000, 7, 8}
+	bytes = appendAS(bytes, a.AS, true)
+	bytes = append(bytes, a.Address.To4()...)
+	return bytes
+}
+
+func (a *Aggregator) ToPA(b []byte) error {
+	if len(b) != 6 {
+		return fmt.Errorf("Aggregator Attribute Length is not 6")
+	}
+	a.AS = AutonomousSystemNumber(b[0])<<8 + AutonomousSystemNumber(b[1])
+	a.Address = net.IP(append([]byte{}, b[2:6]...))
+	return nil
+}
+
 type DontKnow []byte // 対応していないPathAtribute用
 
 func (d *DontKnow) BytesLen() uint16 {
@@ -334,6 +401,17 @@ func (d *DontKnow) ToPA(b []byte) error {
 }
 
 func BytesToPathAttributes(b []byte) ([]PathAttribute, error) {
+	return bytesToPathAttributes(b, false)
+}
+
+// AS_PATHとAGGREGATORのAS番号が4byteでエンコードされたbytesをPathAttributeにする
+// MRTのTABLE_DUMP_V2の経路や、4byteのAS番号をネゴシエーションしたPeerのMessageで使う。
+// 2byteで表せないAS番号はAS_TRANSに置き換える (RFC6793)
+func BytesToPathAttributesAS4(b []byte) ([]PathAttribute, error) {
+	return bytesToPathAttributes(b, true)
+}
+
+func bytesToPathAttributes(b []byte, as4 bool) ([]PathAttribute, error) {
 	pas := make([]PathAttribute, 0)
 	i := 0
 	for len(b) > i {
@@ -362,21 +440,17 @@ func BytesToPathAttributes(b []byte) ([]PathAttribute, error) {
 			}
 			pas = append(pas, o)
 		case 2:
-			if attV[0] == 1 {
-				set := new(AsSet)
-				err := set.ToPA(attV)
-				if err != nil {
-					return nil, err
-				}
-				pas = append(pas, set)
-			} else {
-				seq := new(AsSequence)
-				err := seq.ToPA(attV)
-				if err != nil {
+			if as4 {
+				var err error
+				if attV, err = asPathToAS2(attV); err != nil {
 					return nil, err
 				}
-				pas = append(pas, seq)
 			}
+			aps, err := bytesToAsPath(attV)
+			if err != nil {
+				return nil, err
+			}
+			pas = append(pas, aps...)
 		case 3:
 			n := new(NextHop)
 			err := n.ToPA(attV)
@@ -384,6 +458,24 @@ func BytesToPathAttributes(b []byte) ([]PathAttribute, error) {
 				return nil, err
 			}
 			pas = append(pas, n)
+		case 6:
+			a := new(AtomicAggregate)
+			if err := a.ToPA(attV); err != nil {
+				return nil, err
+			}
+			pas = append(pas, a)
+		case 7:
+			if as4 {
+				if len(attV) != 8 {
+					return nil, fmt.Errorf("Aggregator Attribute Length is not 8")
+				}
+				attV = append(appendAS(nil, as4To2(attV[0:4]), false), attV[4:8]...)
+			}
+			a := new(Aggregator)
+			if err := a.ToPA(attV); err != nil {
+				return nil, err
+			}
+			pas = append(pas, a)
 		default:
 			d := DontKnow(b[i:attEndIdx])
 			pas = append(pas, &d)
@@ -392,3 +484,110 @@ func BytesToPathAttributes(b []byte) ([]PathAttribute, error) {
 	}
 	return pas, nil
 }
+
+// 2byteで表せないAS番号の代わりに使うAS番号 (RFC6793)
+const AS_TRANS AutonomousSystemNumber = 23456
+
+func as4To2(b []byte) AutonomousSystemNumber {
+	as := uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+	if as > 0xffff {
+		return AS_TRANS
+	}
+	return AutonomousSystemNumber(as)
+}
+
+// AS番号が4byteのAS_PATH属性の値を、2byteのAS番号の値に変換する
+func asPathToAS2(b []byte) ([]byte, error) {
+	segs := make([]byte, 0, len(b)/2+2)
+	for i := 0; i < len(b); {
+		if len(b) < i+2 {
+			return nil, fmt.Errorf("AS Path Attribute Length is too short")
+		}
+		end := i + 2 + int(b[i+1])*4
+		if len(b) < end {
+			return nil, fmt.Errorf("AS Path Attribute Length is too short")
+		}
+		segs = append(segs, b[i], b[i+1])
+		for j := i + 2; j < end; j += 4 {
+			segs = appendAS(segs, as4To2(b[j:j+4]), false)
+		}
+		i = end
+	}
+	return segs, nil
+}
+
+// AS_PATH属性の値をセグメントごとにAsSequence, AsSetに変換する
+// 経路集約した経路はAsSequenceのセグメントの後にAsSetのセグメントを持つ
+func bytesToAsPath(b []byte) ([]PathAttribute, error) {
+	if len(b) == 0 {
+		return []PathAttribute{&AsSequence{}}, nil
+	}
+	pas := make([]PathAttribute, 0)
+	for i := 0; i < len(b); {
+		if len(b) < i+2 {
+			return nil, fmt.Errorf("AS Path Attribute Length is too short")
+		}
+		end := i + 2 + int(b[i+1])*2
+		if len(b) < end {
+			return nil, fmt.Errorf("AS Path Attribute Length is too short")
+		}
+		var ap AsPath
+		if b[i] == 1 {
+			ap = new(AsSet)
+		} else {
+			ap = new(AsSequence)
+		}
+		if err := ap.ToPA(b[i:end]); err != nil {
+			return nil, err
+		}
+		pas = append(pas, ap)
+		i = end
+	}
+	return pas, nil
+}
+
+// PathAttributeをUpdateMessageに含めるbytesにする
+// AsSequenceとAsSetは、1つのAS_PATH属性の複数のセグメントとしてまとめる。
+func PathAttributesToBytes(pas []PathAttribute) []byte {
+	return pathAttributesToBytes(pas, false)
+}
+
+// AS_PATHとAGGREGATORのAS番号を4byteでエンコードしたbytesにする
+// 4byteのAS番号を前提とする、MRTのTABLE_DUMP_V2の経路で使う (RFC6396 4.3.4)
+func PathAttributesToBytesAS4(pas []PathAttribute) []byte {
+	return pathAttributesToBytes(pas, true)
+}
+
+func pathAttributesToBytes(pas []PathAttribute, as4 bool) []byte {
+	bytes := make([]byte, 0)
+	segs := make([]byte, 0)
+	// AS_PATH属性を挿入する位置
+	asPathAt := -1
+	for _, pa := range pas {
+		switch t := pa.(type) {
+		case *AsSequence:
+			if asPathAt < 0 {
+				asPathAt = len(bytes)
+			}
+			segs = append(segs, t.segmentBytes(as4)...)
+		case *AsSet:
+			if asPathAt < 0 {
+				asPathAt = len(bytes)
+			}
+			segs = append(segs, t.segmentBytes(as4)...)
+		case *Aggregator:
+			if as4 {
+				bytes = append(bytes, t.toBytesAS4()...)
+			} else {
+				bytes = append(bytes, t.ToBytes()...)
+			}
+		default:
+			bytes = append(bytes, pa.ToBytes()...)
+		}
+	}
+	if asPathAt < 0 {
+		return bytes
+	}
+	ap := asPathToBytes(segs)
+	return append(bytes[:asPathAt:asPathAt], append(ap, bytes[asPathAt:]...)...)
+}
@@ -0,0 +1,287 @@
+package bmp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/SotaUeda/gobgp/bgptype"
+	"github.com/SotaUeda/gobgp/config"
+	"github.com/SotaUeda/gobgp/fib"
+	"github.com/SotaUeda/gobgp/packets"
+	"github.com/SotaUeda/gobgp/peer"
+	"github.com/SotaUeda/gobgp/server"
+)
+
+// 各Messageをバイト列に変換し、元に戻せることを確認するテスト
+func TestMessageRoundTrip(t *testing.T) {
+	open, err := packets.NewOpenMessage(64513, net.ParseIP("10.200.100.3")).ToBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ph := PeerHeader{
+		Type:      GlobalInstancePeer,
+		Flags:     PEER_FLAG_LEGACY_AS_PATH | PEER_FLAG_POST_POLICY,
+		Address:   net.ParseIP("10.200.100.3").To4(),
+		AS:        64513,
+		BGPID:     net.ParseIP("10.200.100.3").To4(),
+		Timestamp: time.Unix(1700000000, 123000),
+	}
+	ms := []Message{
+		&InitiationMessage{Info: []TLV{{Type: INFO_SYS_NAME, Value: []byte("router1")}}},
+		&TerminationMessage{Info: []TLV{{Type: TERMINATION_REASON, Value: []byte{0, 0}}}},
+		&RouteMonitoringMessage{Peer: ph, Update: []byte{1, 2, 3}},
+		&StatisticsReportMessage{Peer: ph, Stats: []Stat{
+			{Type: STAT_REJECTED_PREFIXES, Value: 3},
+			{Type: STAT_ADJ_RIB_IN_ROUTES, Value: 1 << 40},
+		}},
+		&PeerDownMessage{Peer: ph, Reason: PEER_DOWN_REMOTE_NO_NOTIFICATION, Data: []byte{}},
+		&PeerUpMessage{
+			Peer:         ph,
+			LocalAddress: net.ParseIP("10.200.100.2").To4(),
+			LocalPort:    179,
+			RemotePort:   50000,
+			SentOpen:     open,
+			ReceivedOpen: open,
+			Info:         []TLV{},
+		},
+	}
+	for _, want := range ms {
+		got, err := ReadMessage(bytes.NewReader(want.ToBytes()))
+		if err != nil {
+			t.Errorf("Want: %v, Got: %v", want, err)
+			continue
+		}
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("Want: %+v, Got: %+v", want, got)
+		}
+	}
+}
+
+func newServer(t *testing.T) *server.Server {
+	t.Helper()
+	c, err := config.Parse("test.toml", []byte(`
+[global]
+as = 64512
+router-id = "127.0.0.1"
+
+[[neighbors]]
+address = "127.0.0.2"
+remote-as = 64513
+mode = "active"
+port = 1
+
+[neighbors.timers]
+connect-retry = "1h"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lr, err := peer.NewLocRib(c.LocRibConfig(), fib.NewMemory())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := server.New(lr, c.LocRibConfig())
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	s.Start(ctx, c.PeerConfigs())
+	return s
+}
+
+func readMessages(t *testing.T, b []byte) []Message {
+	t.Helper()
+	ms := []Message{}
+	r := bytes.NewReader(b)
+	for r.Len() > 0 {
+		m, err := ReadMessage(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ms = append(ms, m)
+	}
+	return ms
+}
+
+// Peerのイベントから、Peer Up、Route Monitoring、Peer Downを送信することを確認するテスト
+func TestSessionHandlePeerEvents(t *testing.T) {
+	buf := &bytes.Buffer{}
+	s := &session{c: &Client{Server: newServer(t)}, w: bufio.NewWriter(buf), peers: make(map[string]*peerState)}
+	addr := net.ParseIP("127.0.0.2").To4()
+	info := &peer.PeerInfo{
+		Config:        &peer.Config{RemoteIP: addr, RemoteAS: 64513},
+		State:         peer.ESTABLISHED,
+		EstablishedAt: time.Unix(1700000000, 0),
+		LocalAddr:     &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 40000},
+		RemoteAddr:    &net.TCPAddr{IP: addr, Port: 179},
+		SentOpen:      packets.NewOpenMessage(64512, net.ParseIP("127.0.0.1")),
+		ReceivedOpen:  packets.NewOpenMessage(64513, addr),
+	}
+	_, nw, _ := net.ParseCIDR("10.100.0.0/24")
+	origin := bgptype.IGP
+	nh := bgptype.NextHop(addr)
+	um, err := packets.NewUpdateMessage(
+		[]bgptype.PathAttribute{&origin, bgptype.NewAsPath(true, 64513), &nh},
+		[]*net.IPNet{nw}, []*net.IPNet{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := um.ToBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	nm := packets.NewNotificationMessage(packets.Cease, packets.AdministrativeReset, nil)
+	nraw, err := nm.ToBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := []*server.Event{
+		{Type: server.PEER_STATE_EVENT, Address: addr, OldState: peer.OPEN_CONFIRM, NewState: peer.ESTABLISHED, Info: info},
+		// 同じセッションのPeer Upは重複して送信しない
+		{Type: server.PEER_STATE_EVENT, Address: addr, OldState: peer.OPEN_CONFIRM, NewState: peer.ESTABLISHED, Info: info},
+		{Type: server.MESSAGE_EVENT, Address: addr, Message: um, Raw: raw},
+		{Type: server.MESSAGE_EVENT, Address: addr, Message: um, Raw: raw, Sent: true},
+		{Type: server.MESSAGE_EVENT, Address: addr, Message: nm, Raw: nraw},
+		{Type: server.PEER_STATE_EVENT, Address: addr, OldState: peer.ESTABLISHED, NewState: peer.IDLE},
+		// Peer Downを送信した後のUpdateMessageは送信しない
+		{Type: server.MESSAGE_EVENT, Address: addr, Message: um, Raw: raw},
+	}
+	for _, ev := range events {
+		if err := s.handle(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.w.Flush()
+
+	ms := readMessages(t, buf.Bytes())
+	if len(ms) != 4 {
+		t.Fatalf("Want: %v, Got: %v", 4, len(ms))
+	}
+	up, ok := ms[0].(*PeerUpMessage)
+	if !ok || up.RemotePort != 179 || up.LocalPort != 40000 || !up.Peer.BGPID.Equal(addr) || up.Peer.AS != 64513 {
+		t.Errorf("Want: peer up, Got: %+v", ms[0])
+	}
+	pre, ok := ms[1].(*RouteMonitoringMessage)
+	if !ok || pre.Peer.Flags != PEER_FLAG_LEGACY_AS_PATH || !bytes.Equal(pre.Update, raw) {
+		t.Errorf("Want: pre-policy route monitoring, Got: %+v", ms[1])
+	}
+	out, ok := ms[2].(*RouteMonitoringMessage)
+	if want := PEER_FLAG_LEGACY_AS_PATH | PEER_FLAG_POST_POLICY | PEER_FLAG_ADJ_RIB_OUT; !ok || out.Peer.Flags != want {
+		t.Errorf("Want: adj-rib-out route monitoring, Got: %+v", ms[2])
+	}
+	down, ok := ms[3].(*PeerDownMessage)
+	if !ok || down.Reason != PEER_DOWN_REMOTE_NOTIFICATION || !bytes.Equal(down.Data, nraw) {
+		t.Errorf("Want: peer down with notification, Got: %+v", ms[3])
+	}
+}
+
+// 代わりのステーションに接続し、LocRibの経路とStatistics Reportを送信し、
+// 切断された後に再接続することを確認するテスト
+func TestClientSession(t *testing.T) {
+	s := newServer(t)
+	_, nw1, _ := net.ParseCIDR("10.100.1.0/24")
+	if err := s.AddLocalPath(nw1); err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	c := NewClient(ln.Addr().String(), s)
+	c.StatisticsInterval = 50 * time.Millisecond
+	c.RetryInterval = 50 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		c.Run(ctx)
+		close(done)
+	}()
+
+	accept := func() (net.Conn, func() Message) {
+		conn, err := ln.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return conn, func() Message {
+			conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			m, err := ReadMessage(conn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return m
+		}
+	}
+
+	conn, read := accept()
+	if _, ok := read().(*InitiationMessage); !ok {
+		t.Fatal("Want: initiation")
+	}
+	up, ok := read().(*PeerUpMessage)
+	if !ok || up.Peer.Type != LocRibInstancePeer || up.Peer.AS != 64512 {
+		t.Fatalf("Want: loc-rib peer up, Got: %+v", up)
+	}
+	if want := []TLV{{Type: INFO_TABLE_NAME, Value: []byte(LOC_RIB_TABLE_NAME)}}; !reflect.DeepEqual(want, up.Info) {
+		t.Errorf("Want: %v, Got: %v", want, up.Info)
+	}
+	prefixes := func(m Message) []string {
+		rm, ok := m.(*RouteMonitoringMessage)
+		if !ok || rm.Peer.Type != LocRibInstancePeer {
+			return nil
+		}
+		pm, err := packets.BytesToMessage(rm.Update)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ps := []string{}
+		for _, nw := range pm.(*packets.UpdateMessage).NetworkLayerReachabilityInformation {
+			ps = append(ps, nw.String())
+		}
+		return ps
+	}
+	if got := prefixes(read()); !reflect.DeepEqual([]string{"10.100.1.0/24"}, got) {
+		t.Errorf("Want: %v, Got: %v", []string{"10.100.1.0/24"}, got)
+	}
+
+	// 最適経路が変わった場合はRoute Monitoringを送信する
+	_, nw2, _ := net.ParseCIDR("10.100.2.0/24")
+	if err := s.AddLocalPath(nw2); err != nil {
+		t.Fatal(err)
+	}
+	gotRoute, gotStats := false, false
+	for !gotRoute || !gotStats {
+		switch m := read().(type) {
+		case *RouteMonitoringMessage:
+			if reflect.DeepEqual([]string{"10.100.2.0/24"}, prefixes(m)) {
+				gotRoute = true
+			}
+		case *StatisticsReportMessage:
+			if m.Peer.Type == LocRibInstancePeer && len(m.Stats) == 1 && m.Stats[0].Value >= 1 {
+				gotStats = true
+			}
+		}
+	}
+
+	// 切断された場合は再接続し、Initiationから送信し直す
+	conn.Close()
+	conn, read = accept()
+	defer conn.Close()
+	if _, ok := read().(*InitiationMessage); !ok {
+		t.Fatal("Want: initiation")
+	}
+
+	// 停止する場合はTerminationを送信する
+	cancel()
+	for {
+		if _, ok := read().(*TerminationMessage); ok {
+			break
+		}
+	}
+	<-done
+}
@@ -0,0 +1,432 @@
+package bmp
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"time"
+
+	"github.com/SotaUeda/gobgp/bgptype"
+	"github.com/SotaUeda/gobgp/logging"
+	"github.com/SotaUeda/gobgp/packets"
+	"github.com/SotaUeda/gobgp/peer"
+	"github.com/SotaUeda/gobgp/server"
+)
+
+var log = logging.Logger(logging.BMP)
+
+const (
+	DEFAULT_STATISTICS_INTERVAL = 60 * time.Second
+	DEFAULT_RETRY_INTERVAL      = 30 * time.Second
+	// Serverから受け取るイベントのバッファ
+	// 送受信したUpdateMessageをすべて受け取るため、Watchの既定値より大きくする
+	EVENT_BUFFER_SIZE = 64 * 1024
+)
+
+// LocRibのPeer Upで通知するテーブル名
+const LOC_RIB_TABLE_NAME = "global"
+
+// BMPステーション(コレクター)に接続し、Peerの状態と経路を送信するクライアント
+//
+// 接続後はInitiationを送信し、Establishedの各PeerのPeer Upと
+// Policyを適用する前のAdjRibIn、LocRib(RFC9069)の経路をRoute Monitoringで送信する。
+// 以降はServerのイベントに従って、Peer Up/Down、受信したUpdateMessage(Pre-Policy)、
+// 送信したUpdateMessage(RFC8671 Post-Policy Adj-RIB-Out)、最適経路の変化を送信する。
+// Adj-RIB-Outは接続した後に送信したUpdateMessageだけを送信する。
+//
+// イベントの受信が追いつかずに破棄された場合は、
+// ステーションの状態と食い違わないように接続し直して送信し直す。
+type Client struct {
+	Addr   string
+	Server *server.Server
+	// Statistics Reportを送信する間隔。0の場合は送信しない
+	StatisticsInterval time.Duration
+	// 接続に失敗したり、切断されたりした後に再接続するまでの時間
+	RetryInterval time.Duration
+	// Initiationで送信するsysNameとsysDescr
+	SysName  string
+	SysDescr string
+}
+
+func NewClient(addr string, s *server.Server) *Client {
+	name, err := os.Hostname()
+	if err != nil {
+		name = "gobgp"
+	}
+	return &Client{
+		Addr:               addr,
+		Server:             s,
+		StatisticsInterval: DEFAULT_STATISTICS_INTERVAL,
+		RetryInterval:      DEFAULT_RETRY_INTERVAL,
+		SysName:            name,
+		SysDescr:           "gobgp",
+	}
+}
+
+// ctxがキャンセルされるまでステーションとの接続を維持する。
+// 接続が切れた場合はRetryIntervalの経過後に再接続する。
+func (c *Client) Run(ctx context.Context) error {
+	for {
+		err := c.session(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		log.Warn("bmp session is closed", "station", c.Addr, "error", err, "retry", c.RetryInterval)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(c.RetryInterval):
+		}
+	}
+}
+
+// 1つの接続で送信した内容
+type session struct {
+	c *Client
+	w *bufio.Writer
+	// RemoteIPをKeyにした、Peer Upを送信してまだPeer Downを送信していないPeer
+	peers map[string]*peerState
+	// 最適経路を送信するLocRib Instance PeerのPer-Peer Header
+	locRib PeerHeader
+}
+
+type peerState struct {
+	header PeerHeader
+	// Peer Upで送信したセッションが確立した時刻
+	// 接続時に送信したPeer Upと同じセッションのイベントを受け取った場合に、重複して送信しないようにする
+	establishedAt time.Time
+	// ADD-PATHでUpdateMessageを受信している場合はtrue
+	addPath bool
+	// 直前に送受信したNotificationMessage
+	// Peer Downの理由として送信する
+	notification     *packets.NotificationMessage
+	notificationRaw  []byte
+	notificationSent bool
+}
+
+func (c *Client) session(ctx context.Context) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", c.Addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	log.Info("bmp session is established", "station", c.Addr)
+
+	sctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// 経路を送信している間に発生したイベントを取りこぼさないように、先にWatchする
+	events := c.Server.WatchWithBuffer(sctx, EVENT_BUFFER_SIZE)
+
+	// ステーションはMessageを送信しないため、切断されたことを検知するためだけに読み込む
+	errs := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(io.Discard, conn)
+		if err == nil {
+			err = io.EOF
+		}
+		errs <- err
+	}()
+
+	s := &session{c: c, w: bufio.NewWriter(conn), peers: make(map[string]*peerState)}
+	if err := s.start(); err != nil {
+		return err
+	}
+
+	var stats <-chan time.Time
+	if c.StatisticsInterval > 0 {
+		t := time.NewTicker(c.StatisticsInterval)
+		defer t.Stop()
+		stats = t.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			conn.SetWriteDeadline(time.Now().Add(time.Second))
+			s.send(&TerminationMessage{Info: []TLV{
+				{Type: TERMINATION_REASON, Value: []byte{0, byte(TERMINATION_ADMINISTRATIVELY_CLOSED)}},
+			}})
+			s.w.Flush()
+			return ctx.Err()
+		case err := <-errs:
+			return err
+		case <-stats:
+			if err := s.sendStatistics(); err != nil {
+				return err
+			}
+		case ev, ok := <-events:
+			if !ok {
+				return ctx.Err()
+			}
+			if ev.Dropped > 0 {
+				return fmt.Errorf("%d events are dropped, bmp session is restarted to resync", ev.Dropped)
+			}
+			if err := s.handle(ev); err != nil {
+				return err
+			}
+		}
+		// 続けて処理するイベントがなければ、溜めたMessageを送信する
+		if len(events) == 0 {
+			if err := s.w.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *session) send(m Message) error {
+	_, err := s.w.Write(m.ToBytes())
+	return err
+}
+
+// Initiationを送信し、現在のPeerと経路を送信する
+func (s *session) start() error {
+	if err := s.send(&InitiationMessage{Info: []TLV{
+		{Type: INFO_SYS_DESCR, Value: []byte(s.c.SysDescr)},
+		{Type: INFO_SYS_NAME, Value: []byte(s.c.SysName)},
+	}}); err != nil {
+		return err
+	}
+	if err := s.locRibUp(); err != nil {
+		return err
+	}
+	for _, p := range s.c.Server.Peers() {
+		info := p.Info()
+		if info.State != peer.ESTABLISHED {
+			continue
+		}
+		if err := s.peerUp(&info); err != nil {
+			return err
+		}
+		ps, ok := s.peers[info.Config.RemoteIP.String()]
+		if !ok {
+			continue
+		}
+		for _, re := range p.AdjRibInRoutes() {
+			if err := s.routeMonitoring(ps.header, re, ps.addPath); err != nil {
+				return err
+			}
+		}
+	}
+	for _, re := range s.c.Server.LocRib.Rib.Routes() {
+		if err := s.routeMonitoring(s.locRib, re, false); err != nil {
+			return err
+		}
+	}
+	return s.w.Flush()
+}
+
+// LocRib Instance PeerのPeer Upを送信する (RFC9069 5.3)
+// LocRibにはセッションがないため、アドレスとポートは0で、
+// 送受信したOpenMessageには同じ自身のOpenMessageを入れる。
+func (s *session) locRibUp() error {
+	lc := s.c.Server.LocRibConfig()
+	id := lc.RouterID
+	if id == nil {
+		id = lc.LocalIP
+	}
+	s.locRib = PeerHeader{
+		Type:  LocRibInstancePeer,
+		AS:    uint32(lc.LocalAS),
+		BGPID: id,
+	}
+	open, err := packets.NewOpenMessage(lc.LocalAS, id).ToBytes()
+	if err != nil {
+		return err
+	}
+	h := s.locRib
+	h.Timestamp = time.Now()
+	return s.send(&PeerUpMessage{
+		Peer:         h,
+		LocalAddress: net.IPv4zero,
+		SentOpen:     open,
+		ReceivedOpen: open,
+		Info:         []TLV{{Type: INFO_TABLE_NAME, Value: []byte(LOC_RIB_TABLE_NAME)}},
+	})
+}
+
+func (s *session) handle(ev *server.Event) error {
+	switch ev.Type {
+	case server.PEER_STATE_EVENT:
+		if ev.NewState == peer.ESTABLISHED && ev.Info != nil {
+			return s.peerUp(ev.Info)
+		}
+		if ev.OldState == peer.ESTABLISHED {
+			return s.peerDown(ev.Address)
+		}
+	case server.MESSAGE_EVENT:
+		ps, ok := s.peers[ev.Address.String()]
+		if !ok {
+			return nil
+		}
+		switch m := ev.Message.(type) {
+		case *packets.NotificationMessage:
+			ps.notification, ps.notificationRaw, ps.notificationSent = m, ev.Raw, ev.Sent
+		case *packets.UpdateMessage:
+			h := ps.header
+			h.Timestamp = time.Now()
+			if ev.Sent {
+				h.Flags |= PEER_FLAG_POST_POLICY | PEER_FLAG_ADJ_RIB_OUT
+			}
+			return s.send(&RouteMonitoringMessage{Peer: h, Update: ev.Raw})
+		}
+	case server.BEST_PATH_EVENT:
+		if ev.Best == nil {
+			return s.withdraw(s.locRib, ev.Prefix)
+		}
+		return s.routeMonitoring(s.locRib, ev.Best, false)
+	}
+	return nil
+}
+
+func (s *session) peerUp(info *peer.PeerInfo) error {
+	if info.SentOpen == nil || info.ReceivedOpen == nil || info.LocalAddr == nil || info.RemoteAddr == nil {
+		log.Warn("peer up is not sent: session information is missing", "peer", info.Config.RemoteIP.String())
+		return nil
+	}
+	sent, err := info.SentOpen.ToBytes()
+	if err != nil {
+		return err
+	}
+	received, err := info.ReceivedOpen.ToBytes()
+	if err != nil {
+		return err
+	}
+	if ps, ok := s.peers[info.Config.RemoteIP.String()]; ok && ps.establishedAt.Equal(info.EstablishedAt) {
+		return nil
+	}
+	ps := &peerState{
+		establishedAt: info.EstablishedAt,
+		header: PeerHeader{
+			Type: GlobalInstancePeer,
+			// 本実装ではAS番号を2byteで扱う
+			Flags:   PEER_FLAG_LEGACY_AS_PATH,
+			Address: info.Config.RemoteIP,
+			AS:      uint32(info.Config.RemoteAS),
+			BGPID:   info.ReceivedOpen.BGPIdentifier,
+		},
+		addPath: addPathReceive(info),
+	}
+	s.peers[info.Config.RemoteIP.String()] = ps
+	h := ps.header
+	h.Timestamp = info.EstablishedAt
+	return s.send(&PeerUpMessage{
+		Peer:         h,
+		LocalAddress: info.LocalAddr.IP,
+		LocalPort:    uint16(info.LocalAddr.Port),
+		RemotePort:   uint16(info.RemoteAddr.Port),
+		SentOpen:     sent,
+		ReceivedOpen: received,
+	})
+}
+
+func (s *session) peerDown(addr net.IP) error {
+	ps, ok := s.peers[addr.String()]
+	if !ok {
+		return nil
+	}
+	delete(s.peers, addr.String())
+	m := &PeerDownMessage{Peer: ps.header, Reason: PEER_DOWN_REMOTE_NO_NOTIFICATION}
+	m.Peer.Timestamp = time.Now()
+	switch nm := ps.notification; {
+	case nm != nil && ps.notificationSent &&
+		nm.ErrorCode == packets.Cease && nm.ErrorSubcode == packets.PeerDeConfigured:
+		m.Reason = PEER_DOWN_PEER_DECONFIGURED
+	case nm != nil && ps.notificationSent:
+		m.Reason, m.Data = PEER_DOWN_LOCAL_NOTIFICATION, ps.notificationRaw
+	case nm != nil:
+		m.Reason, m.Data = PEER_DOWN_REMOTE_NOTIFICATION, ps.notificationRaw
+	}
+	return s.send(m)
+}
+
+// 経路をUpdateMessageにしてRoute Monitoringで送信する
+func (s *session) routeMonitoring(h PeerHeader, re *peer.RibEntry, addPath bool) error {
+	var (
+		um  *packets.UpdateMessage
+		err error
+	)
+	pas := re.GetPathAttributes()
+	nlri := []*net.IPNet{re.NwAddr}
+	if addPath {
+		um, err = packets.NewAddPathUpdateMessage(pas, nlri, []uint32{re.PathID}, []*net.IPNet{}, []uint32{})
+	} else {
+		um, err = packets.NewUpdateMessage(pas, nlri, []*net.IPNet{})
+	}
+	if err != nil {
+		return err
+	}
+	return s.sendUpdate(h, um)
+}
+
+// 経路を取り消すUpdateMessageをRoute Monitoringで送信する
+func (s *session) withdraw(h PeerHeader, nw *net.IPNet) error {
+	um, err := packets.NewUpdateMessage([]bgptype.PathAttribute{}, []*net.IPNet{}, []*net.IPNet{nw})
+	if err != nil {
+		return err
+	}
+	return s.sendUpdate(h, um)
+}
+
+func (s *session) sendUpdate(h PeerHeader, um *packets.UpdateMessage) error {
+	b, err := um.ToBytes()
+	if err != nil {
+		return err
+	}
+	h.Timestamp = time.Now()
+	return s.send(&RouteMonitoringMessage{Peer: h, Update: b})
+}
+
+// Peer Upを送信した各PeerとLocRibのStatistics Reportを送信する
+func (s *session) sendStatistics() error {
+	now := time.Now()
+	for addr, ps := range s.peers {
+		p, err := s.c.Server.Peer(net.ParseIP(addr))
+		if err != nil {
+			continue
+		}
+		info := p.Info()
+		h := ps.header
+		h.Timestamp = now
+		if err := s.send(&StatisticsReportMessage{Peer: h, Stats: []Stat{
+			{Type: STAT_ADJ_RIB_IN_ROUTES, Value: uint64(info.ReceivedPrefixes)},
+			{Type: STAT_LOC_RIB_ROUTES, Value: uint64(info.AcceptedPrefixes)},
+			{Type: STAT_ADJ_RIB_OUT_POST_POLICY_ROUTES, Value: uint64(info.AdvertisedPrefixes)},
+		}}); err != nil {
+			return err
+		}
+	}
+	prefixes, _ := s.c.Server.LocRib.Size()
+	h := s.locRib
+	h.Timestamp = now
+	return s.send(&StatisticsReportMessage{Peer: h, Stats: []Stat{
+		{Type: STAT_LOC_RIB_ROUTES, Value: uint64(prefixes)},
+	}})
+}
+
+// Peerから受信するUpdateMessageにPath Identifierが付いている場合はtrueを返す
+// 本実装ではIPv4 Unicastのみ扱う
+func addPathReceive(info *peer.PeerInfo) bool {
+	local := false
+	for _, ap := range info.Config.AddPath {
+		if ap.Family == packets.IPv4Unicast && ap.Receive {
+			local = true
+		}
+	}
+	if !local {
+		return false
+	}
+	caps, err := info.ReceivedOpen.Capabilities()
+	if err != nil {
+		return false
+	}
+	for _, c := range caps {
+		if ap, ok := c.(*packets.AddPathCapability); ok && ap.Mode(packets.IPv4Unicast).CanSend() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,455 @@
+package bmp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// BGP Monitoring ProtocolのMessage (RFC7854)
+//
+// すべてのMessageは次のCommon Headerから始まる
+// Version: 1byte: 3
+// Message Length: 4byte: Common Headerを含めたMessage全体のバイト数
+// Message Type: 1byte
+const HEADER_LENGTH = 6
+
+// Route Monitoring, Statistics Report, Peer Down, Peer UpはCommon Headerの後に
+// 次のPer-Peer Headerが続く
+// Peer Type: 1byte
+// Peer Flags: 1byte
+// Peer Distinguisher: 8byte
+// Peer Address: 16byte: IPv4の場合は末尾の4byteに入れる
+// Peer AS: 4byte
+// Peer BGP ID: 4byte
+// Timestamp (seconds): 4byte
+// Timestamp (microseconds): 4byte
+const PER_PEER_HEADER_LENGTH = 42
+
+const VERSION uint8 = 3
+
+// 1つのMessageとして受け入れる最大のバイト数
+// Route MonitoringにはBGPの最大長(4096byte)のUpdateMessageが入るため、余裕を持たせておく
+const MAX_MESSAGE_LENGTH = 64 * 1024
+
+type MessageType uint8
+
+const (
+	RouteMonitoring      MessageType = 0
+	StatisticsReport     MessageType = 1
+	PeerDownNotification MessageType = 2
+	PeerUpNotification   MessageType = 3
+	Initiation           MessageType = 4
+	Termination          MessageType = 5
+)
+
+type PeerType uint8
+
+const (
+	GlobalInstancePeer PeerType = 0
+	// RFC9069 LocRibの経路を監視するためのPeer
+	LocRibInstancePeer PeerType = 3
+)
+
+// Per-Peer HeaderのPeer Flags
+const (
+	// Peer AddressがIPv6
+	PEER_FLAG_IPV6 uint8 = 0x80
+	// Policyを適用した後の経路
+	PEER_FLAG_POST_POLICY uint8 = 0x40
+	// AS Pathが2byteのAS番号でエンコードされている
+	PEER_FLAG_LEGACY_AS_PATH uint8 = 0x20
+	// RFC8671 Adj-RIB-Outの経路
+	PEER_FLAG_ADJ_RIB_OUT uint8 = 0x10
+	// RFC9069 LocRibの経路の一部だけを送信している
+	PEER_FLAG_LOC_RIB_FILTERED uint8 = 0x80
+)
+
+// Initiation, Peer Up, TerminationのInformation TLVのType
+const (
+	INFO_STRING    uint16 = 0
+	INFO_SYS_DESCR uint16 = 1
+	INFO_SYS_NAME  uint16 = 2
+	// RFC9069 Peer UpでLocRibのテーブル名を表す
+	INFO_TABLE_NAME uint16 = 3
+
+	TERMINATION_STRING uint16 = 0
+	TERMINATION_REASON uint16 = 1
+)
+
+// TerminationのReason
+const (
+	TERMINATION_ADMINISTRATIVELY_CLOSED uint16 = 0
+	TERMINATION_UNSPECIFIED             uint16 = 1
+	TERMINATION_OUT_OF_RESOURCES        uint16 = 2
+)
+
+// Peer DownのReason
+const (
+	// 自身がNotificationMessageを送信して切断した。DataはNotificationMessage
+	PEER_DOWN_LOCAL_NOTIFICATION uint8 = 1
+	// 自身がNotificationMessageを送信せずに切断した。DataはFSMのイベントコード(2byte)
+	PEER_DOWN_LOCAL_NO_NOTIFICATION uint8 = 2
+	// PeerからNotificationMessageを受信して切断した。DataはNotificationMessage
+	PEER_DOWN_REMOTE_NOTIFICATION uint8 = 3
+	// PeerがNotificationMessageを送信せずに切断した
+	PEER_DOWN_REMOTE_NO_NOTIFICATION uint8 = 4
+	// Peerの設定が削除され、監視しなくなった
+	PEER_DOWN_PEER_DECONFIGURED uint8 = 5
+)
+
+// Statistics ReportのStat Type
+// 0から6は4byteのカウンター、それ以外は8byteのゲージ
+type StatType uint16
+
+const (
+	STAT_REJECTED_PREFIXES StatType = 0
+	// Policyを適用する前のAdj-RIB-Inの経路数
+	STAT_ADJ_RIB_IN_ROUTES StatType = 7
+	// LocRibの経路数。Global Instance Peerの場合は、そのPeerから受信してLocRibに選ばれた経路数
+	STAT_LOC_RIB_ROUTES StatType = 8
+	// RFC8671 Policyを適用した後のAdj-RIB-Outの経路数
+	STAT_ADJ_RIB_OUT_POST_POLICY_ROUTES StatType = 15
+)
+
+type Message interface {
+	ToBytes() []byte
+}
+
+type PeerHeader struct {
+	Type          PeerType
+	Flags         uint8
+	Distinguisher uint64
+	Address       net.IP
+	AS            uint32
+	BGPID         net.IP
+	Timestamp     time.Time
+}
+
+type TLV struct {
+	Type  uint16
+	Value []byte
+}
+
+type RouteMonitoringMessage struct {
+	Peer PeerHeader
+	// BGPのUpdateMessage
+	Update []byte
+}
+
+type Stat struct {
+	Type  StatType
+	Value uint64
+}
+
+type StatisticsReportMessage struct {
+	Peer  PeerHeader
+	Stats []Stat
+}
+
+type PeerDownMessage struct {
+	Peer   PeerHeader
+	Reason uint8
+	Data   []byte
+}
+
+type PeerUpMessage struct {
+	Peer         PeerHeader
+	LocalAddress net.IP
+	LocalPort    uint16
+	RemotePort   uint16
+	// 送受信したBGPのOpenMessage
+	SentOpen     []byte
+	ReceivedOpen []byte
+	Info         []TLV
+}
+
+type InitiationMessage struct {
+	Info []TLV
+}
+
+type TerminationMessage struct {
+	Info []TLV
+}
+
+func header(t MessageType, length int) []byte {
+	b := make([]byte, HEADER_LENGTH, length)
+	b[0] = VERSION
+	binary.BigEndian.PutUint32(b[1:5], uint32(length))
+	b[5] = byte(t)
+	return b
+}
+
+// IPv4の場合は末尾の4byteに入れた16byteを返す
+func addressBytes(ip net.IP) []byte {
+	b := make([]byte, 16)
+	if ip4 := ip.To4(); ip4 != nil {
+		copy(b[12:], ip4)
+	} else {
+		copy(b, ip.To16())
+	}
+	return b
+}
+
+func (h *PeerHeader) appendTo(b []byte) []byte {
+	b = append(b, byte(h.Type), h.Flags)
+	b = binary.BigEndian.AppendUint64(b, h.Distinguisher)
+	b = append(b, addressBytes(h.Address)...)
+	b = binary.BigEndian.AppendUint32(b, h.AS)
+	id := make([]byte, 4)
+	copy(id, h.BGPID.To4())
+	b = append(b, id...)
+	var sec, usec uint32
+	if !h.Timestamp.IsZero() {
+		sec = uint32(h.Timestamp.Unix())
+		usec = uint32(h.Timestamp.Nanosecond() / 1000)
+	}
+	b = binary.BigEndian.AppendUint32(b, sec)
+	return binary.BigEndian.AppendUint32(b, usec)
+}
+
+func appendTLVs(b []byte, tlvs []TLV) []byte {
+	for _, t := range tlvs {
+		b = binary.BigEndian.AppendUint16(b, t.Type)
+		b = binary.BigEndian.AppendUint16(b, uint16(len(t.Value)))
+		b = append(b, t.Value...)
+	}
+	return b
+}
+
+func tlvsLen(tlvs []TLV) int {
+	l := 0
+	for _, t := range tlvs {
+		l += 4 + len(t.Value)
+	}
+	return l
+}
+
+// Stat Typeごとの値のバイト数
+func (t StatType) size() int {
+	if t <= 6 {
+		return 4
+	}
+	return 8
+}
+
+func (m *RouteMonitoringMessage) ToBytes() []byte {
+	b := header(RouteMonitoring, HEADER_LENGTH+PER_PEER_HEADER_LENGTH+len(m.Update))
+	b = m.Peer.appendTo(b)
+	return append(b, m.Update...)
+}
+
+func (m *StatisticsReportMessage) ToBytes() []byte {
+	l := HEADER_LENGTH + PER_PEER_HEADER_LENGTH + 4
+	for _, s := range m.Stats {
+		l += 4 + s.Type.size()
+	}
+	b := header(StatisticsReport, l)
+	b = m.Peer.appendTo(b)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(m.Stats)))
+	for _, s := range m.Stats {
+		b = binary.BigEndian.AppendUint16(b, uint16(s.Type))
+		b = binary.BigEndian.AppendUint16(b, uint16(s.Type.size()))
+		if s.Type.size() == 4 {
+			b = binary.BigEndian.AppendUint32(b, uint32(s.Value))
+		} else {
+			b = binary.BigEndian.AppendUint64(b, s.Value)
+		}
+	}
+	return b
+}
+
+func (m *PeerDownMessage) ToBytes() []byte {
+	b := header(PeerDownNotification, HEADER_LENGTH+PER_PEER_HEADER_LENGTH+1+len(m.Data))
+	b = m.Peer.appendTo(b)
+	b = append(b, m.Reason)
+	return append(b, m.Data...)
+}
+
+func (m *PeerUpMessage) ToBytes() []byte {
+	l := HEADER_LENGTH + PER_PEER_HEADER_LENGTH + 20 + len(m.SentOpen) + len(m.ReceivedOpen) + tlvsLen(m.Info)
+	b := header(PeerUpNotification, l)
+	b = m.Peer.appendTo(b)
+	b = append(b, addressBytes(m.LocalAddress)...)
+	b = binary.BigEndian.AppendUint16(b, m.LocalPort)
+	b = binary.BigEndian.AppendUint16(b, m.RemotePort)
+	b = append(b, m.SentOpen...)
+	b = append(b, m.ReceivedOpen...)
+	return appendTLVs(b, m.Info)
+}
+
+func (m *InitiationMessage) ToBytes() []byte {
+	b := header(Initiation, HEADER_LENGTH+tlvsLen(m.Info))
+	return appendTLVs(b, m.Info)
+}
+
+func (m *TerminationMessage) ToBytes() []byte {
+	b := header(Termination, HEADER_LENGTH+tlvsLen(m.Info))
+	return appendTLVs(b, m.Info)
+}
+
+// 1つのMessageを読み込む
+func ReadMessage(r io.Reader) (Message, error) {
+	h := make([]byte, HEADER_LENGTH)
+	if _, err := io.ReadFull(r, h); err != nil {
+		return nil, err
+	}
+	l := binary.BigEndian.Uint32(h[1:5])
+	if l < HEADER_LENGTH || l > MAX_MESSAGE_LENGTH {
+		return nil, fmt.Errorf("invalid message length: %d", l)
+	}
+	b := make([]byte, l)
+	copy(b, h)
+	if _, err := io.ReadFull(r, b[HEADER_LENGTH:]); err != nil {
+		return nil, err
+	}
+	return BytesToMessage(b)
+}
+
+// 16byteのアドレスをIPv4またはIPv6のアドレスに変換する
+func bytesToAddress(b []byte, ipv6 bool) net.IP {
+	if ipv6 {
+		return net.IP(append([]byte{}, b...))
+	}
+	return net.IP(append([]byte{}, b[12:16]...))
+}
+
+func bytesToPeerHeader(b []byte) PeerHeader {
+	h := PeerHeader{
+		Type:          PeerType(b[0]),
+		Flags:         b[1],
+		Distinguisher: binary.BigEndian.Uint64(b[2:10]),
+		AS:            binary.BigEndian.Uint32(b[26:30]),
+		BGPID:         net.IP(append([]byte{}, b[30:34]...)),
+	}
+	h.Address = bytesToAddress(b[10:26], h.Type != LocRibInstancePeer && h.Flags&PEER_FLAG_IPV6 != 0)
+	sec := binary.BigEndian.Uint32(b[34:38])
+	usec := binary.BigEndian.Uint32(b[38:42])
+	if sec != 0 || usec != 0 {
+		h.Timestamp = time.Unix(int64(sec), int64(usec)*1000)
+	}
+	return h
+}
+
+func bytesToTLVs(b []byte) ([]TLV, error) {
+	tlvs := []TLV{}
+	for len(b) > 0 {
+		if len(b) < 4 {
+			return nil, fmt.Errorf("tlv is too short: %d", len(b))
+		}
+		l := int(binary.BigEndian.Uint16(b[2:4]))
+		if len(b) < 4+l {
+			return nil, fmt.Errorf("invalid tlv length: %d", l)
+		}
+		tlvs = append(tlvs, TLV{Type: binary.BigEndian.Uint16(b[0:2]), Value: b[4 : 4+l]})
+		b = b[4+l:]
+	}
+	return tlvs, nil
+}
+
+// BGP Messageのヘッダーから、1つのBGP Messageのバイト数を返す
+func bgpMessageLen(b []byte) (int, error) {
+	if len(b) < 19 {
+		return 0, fmt.Errorf("bgp message is too short: %d", len(b))
+	}
+	l := int(binary.BigEndian.Uint16(b[16:18]))
+	if l < 19 || len(b) < l {
+		return 0, fmt.Errorf("invalid bgp message length: %d", l)
+	}
+	return l, nil
+}
+
+func BytesToMessage(b []byte) (Message, error) {
+	if len(b) < HEADER_LENGTH {
+		return nil, fmt.Errorf("message is too short: %d", len(b))
+	}
+	if b[0] != VERSION {
+		return nil, fmt.Errorf("unsupported version: %d", b[0])
+	}
+	t := MessageType(b[5])
+	body := b[HEADER_LENGTH:]
+	switch t {
+	case Initiation:
+		tlvs, err := bytesToTLVs(body)
+		if err != nil {
+			return nil, err
+		}
+		return &InitiationMessage{Info: tlvs}, nil
+	case Termination:
+		tlvs, err := bytesToTLVs(body)
+		if err != nil {
+			return nil, err
+		}
+		return &TerminationMessage{Info: tlvs}, nil
+	}
+
+	if len(body) < PER_PEER_HEADER_LENGTH {
+		return nil, fmt.Errorf("per-peer header of message type %d is too short: %d", t, len(body))
+	}
+	ph := bytesToPeerHeader(body)
+	body = body[PER_PEER_HEADER_LENGTH:]
+	switch t {
+	case RouteMonitoring:
+		return &RouteMonitoringMessage{Peer: ph, Update: body}, nil
+	case StatisticsReport:
+		if len(body) < 4 {
+			return nil, fmt.Errorf("statistics report is too short: %d", len(b))
+		}
+		n := binary.BigEndian.Uint32(body[0:4])
+		body = body[4:]
+		m := &StatisticsReportMessage{Peer: ph}
+		for i := uint32(0); i < n; i++ {
+			if len(body) < 4 {
+				return nil, fmt.Errorf("statistics report is too short: %d", len(b))
+			}
+			st := StatType(binary.BigEndian.Uint16(body[0:2]))
+			l := int(binary.BigEndian.Uint16(body[2:4]))
+			if len(body) < 4+l || (l != 4 && l != 8) {
+				return nil, fmt.Errorf("invalid stat length: type %d, length %d", st, l)
+			}
+			var v uint64
+			if l == 4 {
+				v = uint64(binary.BigEndian.Uint32(body[4:8]))
+			} else {
+				v = binary.BigEndian.Uint64(body[4:12])
+			}
+			m.Stats = append(m.Stats, Stat{Type: st, Value: v})
+			body = body[4+l:]
+		}
+		return m, nil
+	case PeerDownNotification:
+		if len(body) < 1 {
+			return nil, fmt.Errorf("peer down notification is too short: %d", len(b))
+		}
+		return &PeerDownMessage{Peer: ph, Reason: body[0], Data: body[1:]}, nil
+	case PeerUpNotification:
+		if len(body) < 20 {
+			return nil, fmt.Errorf("peer up notification is too short: %d", len(b))
+		}
+		m := &PeerUpMessage{
+			Peer:         ph,
+			LocalAddress: bytesToAddress(body[0:16], ph.Type != LocRibInstancePeer && ph.Flags&PEER_FLAG_IPV6 != 0),
+			LocalPort:    binary.BigEndian.Uint16(body[16:18]),
+			RemotePort:   binary.BigEndian.Uint16(body[18:20]),
+		}
+		body = body[20:]
+		l, err := bgpMessageLen(body)
+		if err != nil {
+			return nil, err
+		}
+		m.SentOpen, body = body[:l], body[l:]
+		l, err = bgpMessageLen(body)
+		if err != nil {
+			return nil, err
+		}
+		m.ReceivedOpen, body = body[:l], body[l:]
+		if m.Info, err = bytesToTLVs(body); err != nil {
+			return nil, err
+		}
+		return m, nil
+	default:
+		return nil, fmt.Errorf("unknown message type: %d", t)
+	}
+}
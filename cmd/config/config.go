@@ -0,0 +1,737 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/SotaUeda/gobgp/bgptype"
+	"github.com/SotaUeda/gobgp/fib"
+	"github.com/SotaUeda/gobgp/packets"
+	"github.com/SotaUeda/gobgp/peer"
+	"github.com/SotaUeda/gobgp/policy"
+	"github.com/SotaUeda/gobgp/rpki"
+)
+
+// 設定ファイル(TOML)の内容
+//
+// 例:
+//
+//	[global]
+//	as = 64512
+//	router-id = "10.200.100.2"
+//
+//	[[global.networks]]
+//	prefix = "10.100.210.0/24"
+//
+//	[[neighbors]]
+//	address = "10.200.100.3"
+//	remote-as = 64513
+//	mode = "passive"
+type Config struct {
+	Global    GlobalConfig     `toml:"global"`
+	Neighbors []NeighborConfig `toml:"neighbors"`
+	Policies  []PolicyConfig   `toml:"policies"`
+	// 経路を送信するBMPステーション
+	// 起動時にだけ読み込み、設定ファイルを読み込み直しても反映しない
+	BMPServers []BMPServerConfig `toml:"bmp-servers"`
+	// MRT形式で書き込む経路とMessage
+	// 起動時にだけ読み込み、設定ファイルを読み込み直しても反映しない
+	MRT []MRTDumpConfig `toml:"mrt-dumps"`
+
+	// 検証した結果作成した、LocRibとPeerの設定
+	locRib *peer.Config
+	peers  []*peer.Config
+	bmp    []*BMPStation
+	mrt    []*MRTDump
+}
+
+type GlobalConfig struct {
+	AS       uint32 `toml:"as"`
+	RouterID string `toml:"router-id"`
+	// PassiveモードのPeerがListenするアドレスとポート
+	// 指定しない場合は各Peerのlocal-addressと179番ポートを使う
+	ListenAddress string              `toml:"listen-address"`
+	ListenPort    int                 `toml:"listen-port"`
+	Networks      []NetworkConfig     `toml:"networks"`
+	Aggregates    []AggregateConfig   `toml:"aggregates"`
+	Redistribute  *RedistributeConfig `toml:"redistribute"`
+	Multipath     *MultipathConfig    `toml:"multipath"`
+	RPKI          *RPKIConfig         `toml:"rpki"`
+}
+
+type NetworkConfig struct {
+	Prefix string `toml:"prefix"`
+	// "exact-match"(デフォルト) または "unconditional"
+	Mode string `toml:"mode"`
+}
+
+type AggregateConfig struct {
+	Prefix      string `toml:"prefix"`
+	AsSet       bool   `toml:"as-set"`
+	SummaryOnly bool   `toml:"summary-only"`
+}
+
+type RedistributeConfig struct {
+	// "connected", "static", "kernel"
+	Sources []string `toml:"sources"`
+	Policy  string   `toml:"policy"`
+}
+
+// 指定しない値は1で、マルチパスにしない
+type MultipathConfig struct {
+	MaximumPaths     int  `toml:"maximum-paths"`
+	MaximumPathsIBGP int  `toml:"maximum-paths-ibgp"`
+	AsPathRelax      bool `toml:"as-path-multipath-relax"`
+}
+
+// RPKIキャッシュサーバー(RTR)の設定
+type RPKIConfig struct {
+	Address string `toml:"address"`
+	// 指定しない場合は323番ポートを使う
+	Port int `toml:"port"`
+}
+
+// RTRのキャッシュサーバーの既定のポート
+const DEFAULT_RPKI_PORT = 323
+
+type NeighborConfig struct {
+	Address  string `toml:"address"`
+	RemoteAS uint32 `toml:"remote-as"`
+	// 指定しない場合はrouter-idを使う
+	LocalAddress string `toml:"local-address"`
+	// "active" または "passive"
+	Mode string `toml:"mode"`
+	// Activeモードで接続するPeerのポート
+	Port         int            `toml:"port"`
+	Timers       TimersConfig   `toml:"timers"`
+	ImportPolicy string         `toml:"import-policy"`
+	ExportPolicy string         `toml:"export-policy"`
+	Damping      *DampingConfig `toml:"damping"`
+	Families     []FamilyConfig `toml:"families"`
+}
+
+// 時間は "90s" や "5m" のように、time.ParseDurationで解釈できる文字列で指定する
+// 指定しない値はDEFAULT_HOLD_TIMEとDEFAULT_CONNECT_RETRYを使う
+type TimersConfig struct {
+	HoldTime          string `toml:"hold-time"`
+	KeepaliveInterval string `toml:"keepalive-interval"`
+	ConnectRetry      string `toml:"connect-retry"`
+}
+
+// 指定しない値はpeer.DefaultDampingConfigの値を使う
+type DampingConfig struct {
+	HalfLife        string  `toml:"half-life"`
+	Reuse           float64 `toml:"reuse"`
+	Suppress        float64 `toml:"suppress"`
+	MaxSuppressTime string  `toml:"max-suppress-time"`
+}
+
+// AFI/SAFIごとの設定
+type FamilyConfig struct {
+	// 本実装では "ipv4-unicast" のみ扱う
+	Name      string           `toml:"name"`
+	AddPath   *AddPathConfig   `toml:"add-path"`
+	MaxPrefix *MaxPrefixConfig `toml:"max-prefix"`
+}
+
+type AddPathConfig struct {
+	Receive bool `toml:"receive"`
+	Send    bool `toml:"send"`
+	// 送信する経路の数。0の場合はすべての経路を送信する
+	SendMax int `toml:"send-max"`
+}
+
+type MaxPrefixConfig struct {
+	Limit            uint32 `toml:"limit"`
+	WarningThreshold uint8  `toml:"warning-threshold"`
+	DropOnly         bool   `toml:"drop-only"`
+	RestartTime      string `toml:"restart-time"`
+}
+
+type BMPServerConfig struct {
+	Address string `toml:"address"`
+	// 指定しない場合は11019番ポートを使う
+	Port int `toml:"port"`
+	// Statistics Reportを送信する間隔。指定しない場合は60秒で、"0s"の場合は送信しない
+	StatisticsInterval string `toml:"statistics-interval"`
+	// 切断された後に再接続するまでの時間。指定しない場合は30秒
+	RetryInterval string `toml:"retry-interval"`
+}
+
+// 検証した結果作成した、BMPステーションの設定
+type BMPStation struct {
+	// host:port
+	Addr               string
+	StatisticsInterval time.Duration
+	RetryInterval      time.Duration
+}
+
+// Peerのタイマーの既定値 (RFC4271 10.)
+const (
+	DEFAULT_HOLD_TIME     = 90 * time.Second
+	DEFAULT_CONNECT_RETRY = 120 * time.Second
+)
+
+// BMPステーションの既定値
+const (
+	DEFAULT_BMP_PORT                = 11019
+	DEFAULT_BMP_STATISTICS_INTERVAL = 60 * time.Second
+	DEFAULT_BMP_RETRY_INTERVAL      = 30 * time.Second
+)
+
+type MRTDumpConfig struct {
+	// "updates": 送受信したMessage(BGP4MP), "table": 経路(TABLE_DUMP_V2)
+	DumpType string `toml:"dump-type"`
+	// 書き込むファイルの名前。%Y, %m, %d, %H, %M, %Sはファイルを作成した時刻で展開する
+	FileName string `toml:"file-name"`
+	// "table"の場合に経路を書き込む間隔。指定しない場合は1時間
+	DumpInterval string `toml:"dump-interval"`
+	// "table"の場合に書き込む経路。"loc-rib"(デフォルト) または "adj-rib-in"
+	Table string `toml:"table"`
+	// "updates"の場合にファイルを切り替える間隔と、圧縮する前のバイト数
+	// 指定しない場合は切り替えない
+	RotationInterval string `toml:"rotation-interval"`
+	RotationSize     int64  `toml:"rotation-size"`
+	Gzip             bool   `toml:"gzip"`
+}
+
+type MRTDumpType string
+
+const (
+	MRT_DUMP_UPDATES MRTDumpType = "updates"
+	MRT_DUMP_TABLE   MRTDumpType = "table"
+)
+
+// 検証した結果作成した、MRT形式で書き込む設定
+type MRTDump struct {
+	Type     MRTDumpType
+	FileName string
+	// MRT_DUMP_TABLEの場合に設定する
+	DumpInterval time.Duration
+	// LocRibの最適経路ではなく、各PeerのAdjRibInの経路を書き込む場合はtrue
+	AdjRibIn bool
+	// MRT_DUMP_UPDATESの場合に設定する
+	RotationInterval time.Duration
+	RotationSize     int64
+	Gzip             bool
+}
+
+const DEFAULT_MRT_DUMP_INTERVAL = time.Hour
+
+type PolicyConfig struct {
+	Name string `toml:"name"`
+	// "accept"(デフォルト) または "reject"
+	DefaultAction string            `toml:"default-action"`
+	Statements    []StatementConfig `toml:"statements"`
+}
+
+// 指定した条件がすべてマッチした場合にActionを適用する
+type StatementConfig struct {
+	Name   string `toml:"name"`
+	Action string `toml:"action"`
+	// AS Pathの正規表現
+	AsPath   string   `toml:"as-path"`
+	Prefixes []string `toml:"prefixes"`
+	OrLonger bool     `toml:"or-longer"`
+	// RPKIの検証結果 "valid", "not-found", "invalid"
+	Validation string `toml:"validation"`
+}
+
+// 設定ファイルの誤り
+// Lineは誤りのある行番号で、特定できない場合は0
+type Error struct {
+	File string
+	Line int
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("%s: %s", e.File, e.Msg)
+	}
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Msg)
+}
+
+// 設定ファイルを読み込んで検証する
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(path, data)
+}
+
+// TOMLの設定を検証し、LocRibとPeerの設定を作成する
+// 誤りがある場合は、見つかったすべての誤りを行番号付きで返す。
+func Parse(name string, data []byte) (*Config, error) {
+	c := &Config{}
+	md, err := toml.Decode(string(data), c)
+	if err != nil {
+		var pe toml.ParseError
+		if errors.As(err, &pe) {
+			return nil, &Error{File: name, Line: pe.Position.Line, Msg: pe.Message}
+		}
+		return nil, &Error{File: name, Msg: err.Error()}
+	}
+	v := &validator{name: name, loc: newLocator(data)}
+	// 存在しないキーはタイプミスの可能性が高いため誤りとして扱う
+	for _, k := range md.Undecoded() {
+		v.errs = append(v.errs, &Error{File: name, Line: v.loc.plainLines[k.String()], Msg: fmt.Sprintf("unknown key %q", k.String())})
+	}
+	c.build(v)
+	if len(v.errs) > 0 {
+		return nil, errors.Join(v.errs...)
+	}
+	return c, nil
+}
+
+// 共有するLocRibの設定
+func (c *Config) LocRibConfig() *peer.Config {
+	return c.locRib
+}
+
+// neighborsに対応するPeerの設定
+func (c *Config) PeerConfigs() []*peer.Config {
+	return c.peers
+}
+
+// bmp-serversに対応するBMPステーションの設定
+func (c *Config) BMPStations() []*BMPStation {
+	return c.bmp
+}
+
+// mrt-dumpsに対応する、MRT形式で書き込む設定
+func (c *Config) MRTDumps() []*MRTDump {
+	return c.mrt
+}
+
+type validator struct {
+	name string
+	loc  *locator
+	errs []error
+}
+
+// pathの行番号とともに誤りを記録する
+func (v *validator) errorf(path, format string, args ...any) {
+	v.errs = append(v.errs, &Error{File: v.name, Line: v.loc.line(path), Msg: path + ": " + fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) as(path string, as uint32) uint16 {
+	if as == 0 || as > 65535 {
+		v.errorf(path, "as number must be 1-65535, got %d", as)
+	}
+	return uint16(as)
+}
+
+func (v *validator) ip(path, s string) net.IP {
+	ip := net.ParseIP(s).To4()
+	if ip == nil {
+		v.errorf(path, "invalid ipv4 address %q", s)
+	}
+	return ip
+}
+
+func (v *validator) prefix(path, s string) *net.IPNet {
+	_, nw, err := net.ParseCIDR(s)
+	if err != nil || nw.IP.To4() == nil {
+		v.errorf(path, "invalid ipv4 prefix %q", s)
+		return nil
+	}
+	return nw
+}
+
+func (v *validator) port(path string, p int) int {
+	if p < 0 || p > 65535 {
+		v.errorf(path, "port must be 0-65535, got %d", p)
+	}
+	return p
+}
+
+// 0の場合は1を返す
+func (v *validator) maximumPaths(path string, n int) int {
+	if n < 0 {
+		v.errorf(path, "maximum paths must not be negative, got %d", n)
+	}
+	if n == 0 {
+		return 1
+	}
+	return n
+}
+
+// 空文字列の場合は0を返す
+func (v *validator) duration(path, s string) time.Duration {
+	if s == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		v.errorf(path, "invalid duration %q", s)
+	}
+	return d
+}
+
+func (c *Config) build(v *validator) {
+	pols := c.buildPolicies(v)
+	lookupPolicy := func(path, name string) *policy.Policy {
+		if name == "" {
+			return nil
+		}
+		pol, ok := pols[name]
+		if !ok {
+			v.errorf(path, "policy %q is not defined", name)
+		}
+		return pol
+	}
+
+	g := c.Global
+	as := v.as("global.as", g.AS)
+	var routerID net.IP
+	if g.RouterID == "" {
+		v.errorf("global.router-id", "router-id is required")
+	} else {
+		routerID = v.ip("global.router-id", g.RouterID)
+	}
+	var listenAddr net.IP
+	if g.ListenAddress != "" {
+		listenAddr = v.ip("global.listen-address", g.ListenAddress)
+	}
+	listenPort := v.port("global.listen-port", g.ListenPort)
+
+	// LocRibの設定は、APIからPeerを追加する場合のデフォルト値としても使う
+	lr := &peer.Config{
+		LocalAS:    bgptype.AutonomousSystemNumber(as),
+		LocalIP:    routerID,
+		RouterID:   routerID,
+		ListenAddr: listenAddr,
+		ListenPort: listenPort,
+	}
+	for i, n := range g.Networks {
+		path := fmt.Sprintf("global.networks[%d]", i)
+		nc := &peer.NetworkConfig{Prefix: v.prefix(path+".prefix", n.Prefix)}
+		switch n.Mode {
+		case "", "exact-match":
+			nc.Mode = peer.NETWORK_EXACT_MATCH
+		case "unconditional":
+			nc.Mode = peer.NETWORK_UNCONDITIONAL
+		default:
+			v.errorf(path+".mode", "mode must be exact-match or unconditional, got %q", n.Mode)
+		}
+		lr.Networks = append(lr.Networks, nc)
+	}
+	for i, a := range g.Aggregates {
+		path := fmt.Sprintf("global.aggregates[%d]", i)
+		lr.Aggregates = append(lr.Aggregates, &peer.AggregateConfig{
+			Prefix:      v.prefix(path+".prefix", a.Prefix),
+			AsSet:       a.AsSet,
+			SummaryOnly: a.SummaryOnly,
+		})
+	}
+	if r := g.Redistribute; r != nil {
+		rc := &peer.RedistributeConfig{Policy: lookupPolicy("global.redistribute.policy", r.Policy)}
+		for _, s := range r.Sources {
+			src, err := fib.ParseRouteSource(s)
+			if err != nil {
+				v.errorf("global.redistribute.sources", "%v", err)
+			}
+			rc.Sources = append(rc.Sources, src)
+		}
+		lr.Redistribute = rc
+	}
+	if m := g.Multipath; m != nil {
+		lr.Multipath = &peer.MultipathConfig{
+			MaximumPaths:     v.maximumPaths("global.multipath.maximum-paths", m.MaximumPaths),
+			MaximumPathsIBGP: v.maximumPaths("global.multipath.maximum-paths-ibgp", m.MaximumPathsIBGP),
+			AsPathRelax:      m.AsPathRelax,
+		}
+	}
+	if r := g.RPKI; r != nil {
+		var addr net.IP
+		if r.Address == "" {
+			v.errorf("global.rpki.address", "address is required")
+		} else {
+			addr = v.ip("global.rpki.address", r.Address)
+		}
+		port := v.port("global.rpki.port", r.Port)
+		if port == 0 {
+			port = DEFAULT_RPKI_PORT
+		}
+		lr.RPKIServer = net.JoinHostPort(addr.String(), fmt.Sprint(port))
+	}
+	c.locRib = lr
+
+	if len(c.Neighbors) == 0 {
+		v.errs = append(v.errs, &Error{File: v.name, Msg: "at least one neighbor is required"})
+	}
+	addrs := make(map[string]string)
+	for i, n := range c.Neighbors {
+		path := fmt.Sprintf("neighbors[%d]", i)
+		pc := &peer.Config{
+			LocalAS:    lr.LocalAS,
+			RouterID:   routerID,
+			LocalIP:    routerID,
+			ListenAddr: listenAddr,
+			ListenPort: listenPort,
+		}
+		if n.Address == "" {
+			v.errorf(path+".address", "address is required")
+		} else {
+			pc.RemoteIP = v.ip(path+".address", n.Address)
+			if prev, ok := addrs[pc.RemoteIP.String()]; ok {
+				v.errorf(path+".address", "address %v is already used by %s", pc.RemoteIP, prev)
+			}
+			addrs[pc.RemoteIP.String()] = path
+		}
+		pc.RemoteAS = bgptype.AutonomousSystemNumber(v.as(path+".remote-as", n.RemoteAS))
+		if n.LocalAddress != "" {
+			pc.LocalIP = v.ip(path+".local-address", n.LocalAddress)
+		}
+		m, err := peer.ParseMode(n.Mode)
+		if err != nil {
+			v.errorf(path+".mode", "mode must be active or passive, got %q", n.Mode)
+		}
+		pc.Mode = m
+		pc.RemotePort = v.port(path+".port", n.Port)
+		pc.Timers = peer.Timers{
+			HoldTime:          v.duration(path+".timers.hold-time", n.Timers.HoldTime),
+			KeepaliveInterval: v.duration(path+".timers.keepalive-interval", n.Timers.KeepaliveInterval),
+			ConnectRetryTime:  v.duration(path+".timers.connect-retry", n.Timers.ConnectRetry),
+		}
+		// 0を指定するとKeepaliveMessageを送らなくなるため、指定しない場合は既定値を使う
+		if n.Timers.HoldTime == "" {
+			pc.Timers.HoldTime = DEFAULT_HOLD_TIME
+		}
+		// RFC4271 4.2 Hold Timeは0か3秒以上
+		if ht := pc.Timers.HoldTime; ht != 0 && ht < 3*time.Second {
+			v.errorf(path+".timers.hold-time", "hold-time must be 0 or at least 3s, got %v", ht)
+		}
+		// 0の場合は接続に失敗したPeerが再接続しなくなるため、0は指定できない
+		if n.Timers.ConnectRetry == "" {
+			pc.Timers.ConnectRetryTime = DEFAULT_CONNECT_RETRY
+		} else if pc.Timers.ConnectRetryTime == 0 {
+			v.errorf(path+".timers.connect-retry", "connect-retry must be positive")
+		}
+		pc.ImportPolicy = lookupPolicy(path+".import-policy", n.ImportPolicy)
+		pc.ExportPolicy = lookupPolicy(path+".export-policy", n.ExportPolicy)
+		if n.Damping != nil {
+			pc.Damping = v.damping(path+".damping", n.Damping)
+		}
+		c.buildFamilies(v, path, n.Families, pc)
+		pc.ConfStr = path
+		c.peers = append(c.peers, pc)
+	}
+	c.buildBMP(v)
+	c.buildMRT(v)
+}
+
+func (c *Config) buildMRT(v *validator) {
+	seen := make(map[string]string)
+	for i, m := range c.MRT {
+		path := fmt.Sprintf("mrt-dumps[%d]", i)
+		d := &MRTDump{Type: MRTDumpType(m.DumpType), FileName: m.FileName, Gzip: m.Gzip}
+		if m.FileName == "" {
+			v.errorf(path+".file-name", "file-name is required")
+		} else if prev, ok := seen[m.FileName]; ok {
+			v.errorf(path+".file-name", "file %s is already used by %s", m.FileName, prev)
+		}
+		seen[m.FileName] = path
+		switch d.Type {
+		case MRT_DUMP_UPDATES:
+			if m.DumpInterval != "" || m.Table != "" {
+				v.errorf(path, "dump-interval and table are only for dump-type \"table\"")
+			}
+			if m.RotationInterval != "" {
+				d.RotationInterval = v.duration(path+".rotation-interval", m.RotationInterval)
+			}
+			if m.RotationSize < 0 {
+				v.errorf(path+".rotation-size", "rotation-size must not be negative")
+			}
+			d.RotationSize = m.RotationSize
+		case MRT_DUMP_TABLE:
+			if m.RotationInterval != "" || m.RotationSize != 0 {
+				v.errorf(path, "rotation-interval and rotation-size are only for dump-type \"updates\"")
+			}
+			d.DumpInterval = DEFAULT_MRT_DUMP_INTERVAL
+			if m.DumpInterval != "" {
+				d.DumpInterval = v.duration(path+".dump-interval", m.DumpInterval)
+				if d.DumpInterval == 0 {
+					v.errorf(path+".dump-interval", "dump-interval must be greater than 0")
+				}
+			}
+			switch m.Table {
+			case "", "loc-rib":
+			case "adj-rib-in":
+				d.AdjRibIn = true
+			default:
+				v.errorf(path+".table", "unknown table %q", m.Table)
+			}
+		default:
+			v.errorf(path+".dump-type", "unknown dump-type %q", m.DumpType)
+		}
+		c.mrt = append(c.mrt, d)
+	}
+}
+
+func (c *Config) buildBMP(v *validator) {
+	seen := make(map[string]string)
+	for i, b := range c.BMPServers {
+		path := fmt.Sprintf("bmp-servers[%d]", i)
+		st := &BMPStation{
+			StatisticsInterval: DEFAULT_BMP_STATISTICS_INTERVAL,
+			RetryInterval:      DEFAULT_BMP_RETRY_INTERVAL,
+		}
+		var addr net.IP
+		if b.Address == "" {
+			v.errorf(path+".address", "address is required")
+		} else {
+			addr = v.ip(path+".address", b.Address)
+		}
+		port := v.port(path+".port", b.Port)
+		if port == 0 {
+			port = DEFAULT_BMP_PORT
+		}
+		st.Addr = net.JoinHostPort(addr.String(), fmt.Sprint(port))
+		if prev, ok := seen[st.Addr]; ok && addr != nil {
+			v.errorf(path+".address", "bmp server %s is already configured by %s", st.Addr, prev)
+		}
+		seen[st.Addr] = path
+		if b.StatisticsInterval != "" {
+			st.StatisticsInterval = v.duration(path+".statistics-interval", b.StatisticsInterval)
+		}
+		if b.RetryInterval != "" {
+			st.RetryInterval = v.duration(path+".retry-interval", b.RetryInterval)
+			if st.RetryInterval == 0 {
+				v.errorf(path+".retry-interval", "retry-interval must be greater than 0")
+			}
+		}
+		c.bmp = append(c.bmp, st)
+	}
+}
+
+func (v *validator) damping(path string, d *DampingConfig) *peer.DampingConfig {
+	dc := peer.DefaultDampingConfig()
+	if d.HalfLife != "" {
+		dc.HalfLife = v.duration(path+".half-life", d.HalfLife)
+	}
+	if d.MaxSuppressTime != "" {
+		dc.MaxSuppressTime = v.duration(path+".max-suppress-time", d.MaxSuppressTime)
+	}
+	if d.Reuse != 0 {
+		dc.ReuseThreshold = d.Reuse
+	}
+	if d.Suppress != 0 {
+		dc.SuppressThreshold = d.Suppress
+	}
+	if dc.ReuseThreshold >= dc.SuppressThreshold {
+		v.errorf(path+".reuse", "reuse must be less than suppress, got %v >= %v", dc.ReuseThreshold, dc.SuppressThreshold)
+	}
+	return dc
+}
+
+func (c *Config) buildFamilies(v *validator, parent string, fs []FamilyConfig, pc *peer.Config) {
+	seen := make(map[string]bool)
+	for i, f := range fs {
+		path := fmt.Sprintf("%s.families[%d]", parent, i)
+		if f.Name != "ipv4-unicast" {
+			v.errorf(path+".name", "unsupported family %q, only ipv4-unicast is supported", f.Name)
+			continue
+		}
+		if seen[f.Name] {
+			v.errorf(path+".name", "family %q is already configured", f.Name)
+			continue
+		}
+		seen[f.Name] = true
+		if ap := f.AddPath; ap != nil {
+			if ap.SendMax < 0 {
+				v.errorf(path+".add-path.send-max", "send-max must not be negative, got %d", ap.SendMax)
+			}
+			apc := &peer.AddPathConfig{
+				Family:   packets.IPv4Unicast,
+				Receive:  ap.Receive,
+				Send:     ap.Send,
+				SendMode: peer.ADD_PATH_SEND_ALL,
+			}
+			if ap.SendMax > 0 {
+				apc.SendMode = peer.ADD_PATH_SEND_BEST_N
+				apc.SendMax = ap.SendMax
+			}
+			pc.AddPath = append(pc.AddPath, apc)
+		}
+		if mp := f.MaxPrefix; mp != nil {
+			if mp.Limit == 0 {
+				v.errorf(path+".max-prefix.limit", "limit must be greater than 0")
+			}
+			if mp.WarningThreshold > 100 {
+				v.errorf(path+".max-prefix.warning-threshold", "warning-threshold must be 0-100, got %d", mp.WarningThreshold)
+			}
+			pc.MaxPrefix = &peer.MaxPrefixConfig{
+				Limit:            mp.Limit,
+				WarningThreshold: mp.WarningThreshold,
+				DropOnly:         mp.DropOnly,
+				RestartTime:      v.duration(path+".max-prefix.restart-time", mp.RestartTime),
+			}
+		}
+	}
+}
+
+func (c *Config) buildPolicies(v *validator) map[string]*policy.Policy {
+	pols := make(map[string]*policy.Policy)
+	for i, p := range c.Policies {
+		path := fmt.Sprintf("policies[%d]", i)
+		if p.Name == "" {
+			v.errorf(path+".name", "name is required")
+		} else if _, ok := pols[p.Name]; ok {
+			v.errorf(path+".name", "policy %q is already defined", p.Name)
+		}
+		pol := &policy.Policy{Name: p.Name, DefaultAction: policy.Accept}
+		if p.DefaultAction != "" {
+			a, err := policy.ParseAction(p.DefaultAction)
+			if err != nil {
+				v.errorf(path+".default-action", "%v", err)
+			}
+			pol.DefaultAction = a
+		}
+		for j, s := range p.Statements {
+			pol.Statements = append(pol.Statements, v.statement(fmt.Sprintf("%s.statements[%d]", path, j), &s))
+		}
+		pols[p.Name] = pol
+	}
+	return pols
+}
+
+func (v *validator) statement(path string, s *StatementConfig) *policy.Statement {
+	st := &policy.Statement{Name: s.Name}
+	a, err := policy.ParseAction(s.Action)
+	if err != nil {
+		v.errorf(path+".action", "%v", err)
+	}
+	st.Action = a
+	if s.AsPath != "" {
+		c, err := policy.NewAsPathCondition(s.AsPath)
+		if err != nil {
+			v.errorf(path+".as-path", "%v", err)
+		} else {
+			st.Conditions = append(st.Conditions, c)
+		}
+	}
+	if len(s.Prefixes) > 0 {
+		pc := &policy.PrefixCondition{OrLonger: s.OrLonger}
+		for _, p := range s.Prefixes {
+			if nw := v.prefix(path+".prefixes", p); nw != nil {
+				pc.Prefixes = append(pc.Prefixes, nw)
+			}
+		}
+		st.Conditions = append(st.Conditions, pc)
+	}
+	if s.Validation != "" {
+		vs, err := rpki.ParseValidationState(s.Validation)
+		if err != nil {
+			v.errorf(path+".validation", "%v", err)
+		}
+		st.Conditions = append(st.Conditions, &policy.ValidationCondition{State: vs})
+	}
+	return st
+}
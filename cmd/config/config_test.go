@@ -0,0 +1,302 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SotaUeda/gobgp/peer"
+)
+
+const validConfig = `
+[global]
+as = 64512
+router-id = "10.200.100.2"
+
+[[global.networks]]
+prefix = "10.100.210.0/24"
+
+[[global.networks]]
+prefix = "10.100.220.0/24"
+mode = "unconditional"
+
+[[neighbors]]
+address = "10.200.100.3"
+remote-as = 64513
+mode = "passive"
+import-policy = "reject-private"
+
+[neighbors.timers]
+hold-time = "90s"
+connect-retry = "30s"
+
+[[neighbors.families]]
+name = "ipv4-unicast"
+
+[neighbors.families.max-prefix]
+limit = 1000
+warning-threshold = 80
+
+[[neighbors]]
+address = "10.200.101.3"
+remote-as = 64514
+local-address = "10.200.101.2"
+mode = "active"
+port = 1179
+
+[[policies]]
+name = "reject-private"
+
+[[policies.statements]]
+action = "reject"
+prefixes = ["192.168.0.0/16"]
+or-longer = true
+`
+
+// 正しい設定ファイルから、PeerとLocRibの設定が作成されることを確認するテスト
+func TestParse(t *testing.T) {
+	c, err := Parse("test.toml", []byte(validConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pcs := c.PeerConfigs()
+	if len(pcs) != 2 {
+		t.Fatalf("Want: 2, Got: %d", len(pcs))
+	}
+	p0 := pcs[0]
+	if p0.LocalAS != 64512 || p0.RemoteAS != 64513 || p0.Mode != peer.Passive {
+		t.Errorf("Want: 64512 64513 passive, Got: %v %v %v", p0.LocalAS, p0.RemoteAS, p0.Mode)
+	}
+	if p0.LocalIP.String() != c.Global.RouterID {
+		t.Errorf("Want: %v, Got: %v", c.Global.RouterID, p0.LocalIP)
+	}
+	if p0.Timers.HoldTime != 90*time.Second || p0.Timers.ConnectRetryTime != 30*time.Second {
+		t.Errorf("Want: 90s 30s, Got: %v %v", p0.Timers.HoldTime, p0.Timers.ConnectRetryTime)
+	}
+	if p0.MaxPrefix == nil || p0.MaxPrefix.Limit != 1000 {
+		t.Errorf("Want: 1000, Got: %v", p0.MaxPrefix)
+	}
+	if p0.ImportPolicy == nil || p0.ImportPolicy.Name != "reject-private" {
+		t.Errorf("Want: reject-private, Got: %v", p0.ImportPolicy)
+	}
+	p1 := pcs[1]
+	if p1.LocalIP.String() != "10.200.101.2" || p1.RemotePort != 1179 || p1.Mode != peer.Active {
+		t.Errorf("Want: 10.200.101.2 1179 active, Got: %v %v %v", p1.LocalIP, p1.RemotePort, p1.Mode)
+	}
+	// timersを指定しない場合は既定値を使う
+	if p1.Timers.HoldTime != DEFAULT_HOLD_TIME || p1.Timers.ConnectRetryTime != DEFAULT_CONNECT_RETRY {
+		t.Errorf("Want: %v %v, Got: %v %v",
+			DEFAULT_HOLD_TIME, DEFAULT_CONNECT_RETRY, p1.Timers.HoldTime, p1.Timers.ConnectRetryTime)
+	}
+	lr := c.LocRibConfig()
+	if len(lr.Networks) != 2 || lr.Networks[1].Mode != peer.NETWORK_UNCONDITIONAL {
+		t.Errorf("Want: 2 networks, Got: %v", lr.Networks)
+	}
+}
+
+// 設定の誤りが、行番号付きですべて報告されることを確認するテスト
+func TestParseErrors(t *testing.T) {
+	data := `[global]
+as = 64512
+router-id = "10.200.100.2"
+
+[[neighbors]]
+address = "10.200.100.3"
+remote-as = 64513
+mode = "passive"
+
+[[neighbors]]
+address = "10.200.100.300"
+remote-as = 70000
+mode = "passive"
+export-policy = "undefined"
+
+[neighbors.timers]
+hold-time = "1s"
+connect-retry = "0s"
+`
+	_, err := Parse("test.toml", []byte(data))
+	if err == nil {
+		t.Fatal("Want: error, Got: nil")
+	}
+	want := []string{
+		"test.toml:11: neighbors[1].address",
+		"test.toml:12: neighbors[1].remote-as",
+		"test.toml:14: neighbors[1].export-policy",
+		"test.toml:17: neighbors[1].timers.hold-time",
+		"test.toml:18: neighbors[1].timers.connect-retry",
+	}
+	for _, w := range want {
+		if !strings.Contains(err.Error(), w) {
+			t.Errorf("Want: %q, Got: %v", w, err)
+		}
+	}
+}
+
+// 存在しないキーと構文の誤りが、行番号付きで報告されることを確認するテスト
+func TestParseUnknownKeyAndSyntaxError(t *testing.T) {
+	data := `[global]
+as = 64512
+router-id = "10.200.100.2"
+
+[[neighbors]]
+address = "10.200.100.3"
+remote-as = 64513
+hold-time = "90s"
+`
+	_, err := Parse("test.toml", []byte(data))
+	if err == nil || !strings.Contains(err.Error(), `test.toml:8: unknown key "neighbors.hold-time"`) {
+		t.Errorf("Want: unknown key error, Got: %v", err)
+	}
+
+	_, err = Parse("test.toml", []byte("[global]\nas = \n"))
+	var ce *Error
+	if !errors.As(err, &ce) || ce.Line != 2 {
+		t.Errorf("Want: line 2, Got: %v", err)
+	}
+}
+
+// BMPステーションの設定に既定値が使われ、誤りが報告されることを確認するテスト
+func TestParseBMPServers(t *testing.T) {
+	data := validConfig + `
+[[bmp-servers]]
+address = "10.200.100.10"
+
+[[bmp-servers]]
+address = "10.200.100.11"
+port = 5000
+statistics-interval = "0s"
+`
+	c, err := Parse("test.toml", []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sts := c.BMPStations()
+	if len(sts) != 2 {
+		t.Fatalf("Want: 2, Got: %d", len(sts))
+	}
+	if sts[0].Addr != "10.200.100.10:11019" || sts[0].StatisticsInterval != DEFAULT_BMP_STATISTICS_INTERVAL {
+		t.Errorf("Want: 10.200.100.10:11019 %v, Got: %v %v", DEFAULT_BMP_STATISTICS_INTERVAL, sts[0].Addr, sts[0].StatisticsInterval)
+	}
+	if sts[1].Addr != "10.200.100.11:5000" || sts[1].StatisticsInterval != 0 {
+		t.Errorf("Want: 10.200.100.11:5000 0s, Got: %v %v", sts[1].Addr, sts[1].StatisticsInterval)
+	}
+
+	data = validConfig + `
+[[bmp-servers]]
+address = "10.200.100.10"
+
+[[bmp-servers]]
+address = "10.200.100.10"
+retry-interval = "0s"
+`
+	_, err = Parse("test.toml", []byte(data))
+	if err == nil {
+		t.Fatal("Want: error, Got: nil")
+	}
+	for _, want := range []string{"bmp-servers[1].address: bmp server 10.200.100.10:11019 is already configured", "bmp-servers[1].retry-interval"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Want: %v, Got: %v", want, err)
+		}
+	}
+}
+
+// mrt-dumpsの既定値と、dump-typeに合わない設定を誤りとして扱うことを確認するテスト
+func TestParseMRTDumps(t *testing.T) {
+	data := validConfig + `
+[[mrt-dumps]]
+dump-type = "updates"
+file-name = "/var/log/gobgp/updates.%Y%m%d.%H%M.mrt"
+rotation-interval = "15m"
+gzip = true
+
+[[mrt-dumps]]
+dump-type = "table"
+file-name = "/var/log/gobgp/rib.%Y%m%d.%H%M.mrt"
+table = "adj-rib-in"
+`
+	c, err := Parse("test.toml", []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds := c.MRTDumps()
+	if len(ds) != 2 {
+		t.Fatalf("Want: 2, Got: %d", len(ds))
+	}
+	if ds[0].Type != MRT_DUMP_UPDATES || ds[0].RotationInterval != 15*time.Minute || !ds[0].Gzip {
+		t.Errorf("Want: updates 15m gzip, Got: %+v", ds[0])
+	}
+	if ds[1].Type != MRT_DUMP_TABLE || ds[1].DumpInterval != DEFAULT_MRT_DUMP_INTERVAL || !ds[1].AdjRibIn {
+		t.Errorf("Want: table %v adj-rib-in, Got: %+v", DEFAULT_MRT_DUMP_INTERVAL, ds[1])
+	}
+
+	data = validConfig + `
+[[mrt-dumps]]
+dump-type = "updates"
+file-name = "updates.mrt"
+dump-interval = "1h"
+
+[[mrt-dumps]]
+dump-type = "table"
+file-name = "updates.mrt"
+
+[[mrt-dumps]]
+dump-type = "rib"
+file-name = "rib.mrt"
+`
+	_, err = Parse("test.toml", []byte(data))
+	if err == nil {
+		t.Fatal("Want: error, Got: nil")
+	}
+	for _, want := range []string{
+		`mrt-dumps[0]: dump-interval and table are only for dump-type "table"`,
+		"mrt-dumps[1].file-name: file updates.mrt is already used by mrt-dumps[0]",
+		`mrt-dumps[2].dump-type: unknown dump-type "rib"`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Want: %v, Got: %v", want, err)
+		}
+	}
+}
+
+// マルチパスとRPKIキャッシュサーバーの設定が、LocRibの設定に反映されることを確認するテスト
+func TestParseMultipathAndRPKI(t *testing.T) {
+	data := validConfig + `
+[global.multipath]
+maximum-paths = 4
+as-path-multipath-relax = true
+
+[global.rpki]
+address = "10.200.100.20"
+`
+	c, err := Parse("test.toml", []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lr := c.LocRibConfig()
+	want := &peer.MultipathConfig{MaximumPaths: 4, MaximumPathsIBGP: 1, AsPathRelax: true}
+	if lr.Multipath == nil || *lr.Multipath != *want {
+		t.Errorf("Want: %+v, Got: %+v", want, lr.Multipath)
+	}
+	if lr.RPKIServer != "10.200.100.20:323" {
+		t.Errorf("Want: 10.200.100.20:323, Got: %v", lr.RPKIServer)
+	}
+
+	data = validConfig + `
+[global.multipath]
+maximum-paths-ibgp = -1
+
+[global.rpki]
+port = 3323
+`
+	_, err = Parse("test.toml", []byte(data))
+	if err == nil {
+		t.Fatal("Want: error, Got: nil")
+	}
+	for _, want := range []string{"global.multipath.maximum-paths-ibgp", "global.rpki.address: address is required"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Want: %v, Got: %v", want, err)
+		}
+	}
+}
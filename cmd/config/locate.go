@@ -0,0 +1,114 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	arrayTableRe = regexp.MustCompile(`^\[\[\s*([A-Za-z0-9_.-]+)\s*\]\]`)
+	tableRe      = regexp.MustCompile(`^\[\s*([A-Za-z0-9_.-]+)\s*\]`)
+	keyRe        = regexp.MustCompile(`^([A-Za-z0-9_-]+)\s*=`)
+)
+
+// 設定ファイル内のテーブルとキーが定義されている行番号
+//
+// TOMLのデコード結果には行番号が残らないため、ファイルを行ごとに読み、
+// "neighbors[1].address" のように配列の添字を含むパスと行番号を対応付ける。
+// 本実装で使用する範囲のTOMLだけを扱い、複数行にわたる値の途中の行は無視する。
+type locator struct {
+	lines map[string]int
+	// 添字を含まないパスで最初に定義された行
+	// toml.MetaData.Undecodedが返すキーの行を探すために使用する
+	plainLines map[string]int
+}
+
+func newLocator(data []byte) *locator {
+	l := &locator{
+		lines:      make(map[string]int),
+		plainLines: make(map[string]int),
+	}
+	// 添字を含まないテーブル名から、現在の添字を含むパスへの対応
+	current := make(map[string]string)
+	// 配列のテーブルごとの要素数
+	counts := make(map[string]int)
+	table, plainTable := "", ""
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if m := arrayTableRe.FindStringSubmatch(line); m != nil {
+			parent, name := resolve(current, m[1])
+			key := join(parent, name)
+			table = fmt.Sprintf("%s[%d]", key, counts[key])
+			counts[key]++
+			plainTable = m[1]
+			current[plainTable] = table
+			// 新しい要素の子テーブルは、前の要素のものを引き継がない
+			for k := range current {
+				if strings.HasPrefix(k, plainTable+".") {
+					delete(current, k)
+				}
+			}
+		} else if m := tableRe.FindStringSubmatch(line); m != nil {
+			parent, name := resolve(current, m[1])
+			table = join(parent, name)
+			plainTable = m[1]
+			current[plainTable] = table
+		} else if m := keyRe.FindStringSubmatch(line); m != nil {
+			l.set(join(table, m[1]), join(plainTable, m[1]), n)
+			continue
+		} else {
+			continue
+		}
+		l.set(table, plainTable, n)
+	}
+	return l
+}
+
+// テーブル名の親を、現在の添字を含むパスに置き換える
+func resolve(current map[string]string, name string) (string, string) {
+	i := strings.LastIndex(name, ".")
+	if i < 0 {
+		return "", name
+	}
+	parent := name[:i]
+	if p, ok := current[parent]; ok {
+		return p, name[i+1:]
+	}
+	return parent, name[i+1:]
+}
+
+func join(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
+
+func (l *locator) set(path, plain string, n int) {
+	if _, ok := l.lines[path]; !ok {
+		l.lines[path] = n
+	}
+	if _, ok := l.plainLines[plain]; !ok {
+		l.plainLines[plain] = n
+	}
+}
+
+// pathが定義されている行番号を返す
+// キーが定義されていない場合は、そのキーを含むテーブルの行番号を返す
+func (l *locator) line(path string) int {
+	for p := path; p != ""; {
+		if n, ok := l.lines[p]; ok {
+			return n
+		}
+		i := strings.LastIndex(p, ".")
+		if i < 0 {
+			break
+		}
+		p = p[:i]
+	}
+	return 0
+}
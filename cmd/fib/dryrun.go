@@ -0,0 +1,59 @@
+package fib
+
+import (
+	"net"
+	"sync"
+)
+
+// 変更を書き込まず、書き込む予定の変更をログに出力するだけのFIB
+// ルーティングテーブルの参照はLookupに渡したFIBで行う。
+type DryRun struct {
+	mu     sync.Mutex
+	lookup FIB
+	// 書き込んだものとして扱うルート
+	installed map[string]*Route
+}
+
+func NewDryRun(lookup FIB) *DryRun {
+	return &DryRun{
+		lookup:    lookup,
+		installed: make(map[string]*Route),
+	}
+}
+
+func (d *DryRun) Lookup(dst *net.IPNet) ([]*net.IPNet, error) {
+	return d.lookup.Lookup(dst)
+}
+
+func (d *DryRun) Routes() ([]*TableRoute, error) {
+	return d.lookup.Routes()
+}
+
+func (d *DryRun) ResolveNextHop(nh net.IP) (*NextHopResolution, error) {
+	return d.lookup.ResolveNextHop(nh)
+}
+
+func (d *DryRun) Watch(done <-chan struct{}) (<-chan struct{}, error) {
+	return d.lookup.Watch(done)
+}
+
+func (d *DryRun) Apply(changes []*Change) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, c := range diff(d.installed, changes) {
+		if c.Route == nil {
+			delete(d.installed, c.Dst.String())
+			log.Info("route is deleted", "dry_run", true, "prefix", c.Dst.String())
+			continue
+		}
+		d.installed[c.Dst.String()] = c.Route
+		log.Info("route is replaced", "dry_run", true, "route", c.Route.Show())
+	}
+	return nil
+}
+
+func (d *DryRun) Installed() []*Route {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return routes(d.installed)
+}
@@ -0,0 +1,6 @@
+package fib
+
+import "syscall"
+
+// 存在しないルートを削除しようとした場合にnetlinkが返すエラー
+const errNoSuchProcess = syscall.ESRCH
@@ -0,0 +1,222 @@
+package fib
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+
+	"github.com/SotaUeda/gobgp/logging"
+)
+
+var log = logging.Logger(logging.FIB)
+
+// 経路を書き込む転送プレーン(FIB)
+//
+// LocRibはこのインターフェースを通してルーティングテーブルを参照し、
+// 最適経路の変更を書き込む。
+// 同じ変更を繰り返し渡しても結果が変わらないように実装する。
+type FIB interface {
+	// dstのアドレスを含むネットワークをルーティングテーブルから探す
+	Lookup(dst *net.IPNet) ([]*net.IPNet, error)
+	// 変更を反映する
+	Apply(changes []*Change) error
+	// 書き込み済みのルートを返す
+	Installed() []*Route
+	// 自身が書き込んだルートを除いた、ルーティングテーブル上のルートを返す
+	// connected / static / kernelのルートの再配布に使用する
+	Routes() ([]*TableRoute, error)
+	// NextHopのアドレスに到達するためのルートを探す
+	ResolveNextHop(nh net.IP) (*NextHopResolution, error)
+	// ルーティングテーブルが変わるたびに値を送るチャネルを返す
+	// 自身が書き込んだルートの変更は通知しない。doneが閉じられると監視をやめる
+	Watch(done <-chan struct{}) (<-chan struct{}, error)
+}
+
+// ルーティングテーブル上のルートの生成元
+type RouteSource int
+
+const (
+	// インターフェースに付与されたアドレスから生成されたルート
+	SOURCE_CONNECTED RouteSource = iota
+	// 手動で設定されたルート
+	SOURCE_STATIC
+	// 他のルーティングデーモンなどが書き込んだルート
+	SOURCE_KERNEL
+)
+
+func (s RouteSource) Show() string {
+	switch s {
+	case SOURCE_CONNECTED:
+		return "connected"
+	case SOURCE_STATIC:
+		return "static"
+	case SOURCE_KERNEL:
+		return "kernel"
+	default:
+		return fmt.Sprintf("%d", s)
+	}
+}
+
+func ParseRouteSource(s string) (RouteSource, error) {
+	switch s {
+	case "connected":
+		return SOURCE_CONNECTED, nil
+	case "static":
+		return SOURCE_STATIC, nil
+	case "kernel":
+		return SOURCE_KERNEL, nil
+	default:
+		return 0, fmt.Errorf("string is not route source: %s", s)
+	}
+}
+
+// ルーティングテーブル上のルート
+type TableRoute struct {
+	Dst    *net.IPNet
+	Source RouteSource
+	// ゲートウェイ。connectedのルートの場合はnil
+	Gateway net.IP
+	// ルートのメトリック(IGPのコスト)
+	Metric int
+}
+
+// NextHopの解決結果
+type NextHopResolution struct {
+	// NextHopに到達できるルートがある
+	Reachable bool
+	// NextHopを含むルート
+	Route *net.IPNet
+	// NextHopに到達するために実際にパケットを送るゲートウェイ
+	// NextHopが直接接続されたネットワークにある場合はNextHop自身
+	Gateway net.IP
+	// NextHopまでのIGPのコスト
+	Metric int
+}
+
+func (r *NextHopResolution) Equal(o *NextHopResolution) bool {
+	if r.Reachable != o.Reachable {
+		return false
+	}
+	if !r.Reachable {
+		return true
+	}
+	return r.Route.String() == o.Route.String() && r.Gateway.Equal(o.Gateway) && r.Metric == o.Metric
+}
+
+// ルーティングテーブルのルートtrsからNextHopを再帰的に解決する
+// NextHopを含むルートのうち最長一致するルートを選び、同じ長さの場合はメトリックの小さいルートを選ぶ。
+// デフォルトルートはNextHopの解決には使用しないため、trsに含めない。
+func ResolveNextHop(trs []*TableRoute, nh net.IP) *NextHopResolution {
+	var best *TableRoute
+	bestOnes := -1
+	for _, tr := range trs {
+		if !tr.Dst.Contains(nh) {
+			continue
+		}
+		ones, _ := tr.Dst.Mask.Size()
+		if ones > bestOnes || (ones == bestOnes && tr.Metric < best.Metric) {
+			best, bestOnes = tr, ones
+		}
+	}
+	if best == nil {
+		return &NextHopResolution{}
+	}
+	gw := best.Gateway
+	if gw == nil {
+		gw = nh
+	}
+	return &NextHopResolution{Reachable: true, Route: best.Dst, Gateway: gw, Metric: best.Metric}
+}
+
+// カーネルのルーティングテーブルに書き込むルート
+// NextHopsが複数ある場合はマルチパスのルートとして書き込む
+type Route struct {
+	Dst      *net.IPNet
+	NextHops []net.IP
+}
+
+func (r *Route) Equal(o *Route) bool {
+	if r == nil || o == nil {
+		return r == o
+	}
+	if r.Dst.String() != o.Dst.String() || len(r.NextHops) != len(o.NextHops) {
+		return false
+	}
+	for i := range r.NextHops {
+		if !r.NextHops[i].Equal(o.NextHops[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Route) Show() string {
+	return fmt.Sprintf("%v via %v", r.Dst, r.NextHops)
+}
+
+// プレフィックスごとのルートの変更
+// Routeがnilの場合はルートを削除する
+type Change struct {
+	Dst   *net.IPNet
+	Route *Route
+}
+
+// プレフィックスの変更を反映できなかったときのエラー
+// Applyは失敗した変更ごとにこのエラーを作り、errors.Joinでまとめて返す
+type ChangeError struct {
+	Dst *net.IPNet
+	Err error
+}
+
+func (e *ChangeError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ChangeError) Unwrap() error {
+	return e.Err
+}
+
+// Applyが返したエラーから、反映できなかった変更のプレフィックスを取り出す
+func FailedPrefixes(err error) []*net.IPNet {
+	if err == nil {
+		return nil
+	}
+	var ce *ChangeError
+	if errors.As(err, &ce) {
+		return []*net.IPNet{ce.Dst}
+	}
+	j, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return nil
+	}
+	var nws []*net.IPNet
+	for _, e := range j.Unwrap() {
+		nws = append(nws, FailedPrefixes(e)...)
+	}
+	return nws
+}
+
+// 書き込み済みのルートと比較し、反映が必要な変更だけを返す
+// 書き込み済みのルートと同じ場合や、書き込んでいないルートの削除は除く。
+func diff(installed map[string]*Route, changes []*Change) []*Change {
+	ops := make([]*Change, 0, len(changes))
+	for _, c := range changes {
+		cur, ok := installed[c.Dst.String()]
+		if (c.Route == nil && !ok) || (ok && cur.Equal(c.Route)) {
+			continue
+		}
+		ops = append(ops, c)
+	}
+	return ops
+}
+
+// プレフィックス順に並べたルートのスライスを返す
+func routes(installed map[string]*Route) []*Route {
+	rs := make([]*Route, 0, len(installed))
+	for _, r := range installed {
+		rs = append(rs, r)
+	}
+	sort.Slice(rs, func(i, j int) bool { return rs[i].Dst.String() < rs[j].Dst.String() })
+	return rs
+}
@@ -0,0 +1,73 @@
+package fib
+
+import (
+	"net"
+	"testing"
+)
+
+// NextHopの数や順番が異なるルートを別のルートとして扱うことを確認するテスト
+func TestRouteEqual(t *testing.T) {
+	_, nw, _ := net.ParseCIDR("10.1.0.0/16")
+	nh1 := net.ParseIP("10.0.0.1")
+	nh2 := net.ParseIP("10.0.0.2")
+	tests := []struct {
+		name string
+		a, b *Route
+		want bool
+	}{
+		{"same", &Route{nw, []net.IP{nh1, nh2}}, &Route{nw, []net.IP{nh1, nh2}}, true},
+		{"different nexthop", &Route{nw, []net.IP{nh1}}, &Route{nw, []net.IP{nh2}}, false},
+		{"multipath", &Route{nw, []net.IP{nh1}}, &Route{nw, []net.IP{nh1, nh2}}, false},
+		{"order", &Route{nw, []net.IP{nh1, nh2}}, &Route{nw, []net.IP{nh2, nh1}}, false},
+		{"nil", &Route{nw, []net.IP{nh1}}, nil, false},
+	}
+	for _, tt := range tests {
+		if got := tt.a.Equal(tt.b); got != tt.want {
+			t.Errorf("%s: Want: %v, Got: %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+// 書き込み済みのルートと同じ変更や、書き込んでいないルートの削除は何もしないことを確認するテスト
+func TestManagerApplySkipsUnchanged(t *testing.T) {
+	_, nw, _ := net.ParseCIDR("10.1.0.0/16")
+	r := &Route{nw, []net.IP{net.ParseIP("10.0.0.1")}}
+	m := &Netlink{installed: map[string]*Route{nw.String(): r}}
+	// handleがnilのため、netlinkの操作が行われるとpanicする
+	err := m.Apply([]*Change{
+		{Dst: nw, Route: &Route{nw, []net.IP{net.ParseIP("10.0.0.1")}}},
+		{Dst: &net.IPNet{IP: net.IPv4(10, 2, 0, 0), Mask: net.CIDRMask(16, 32)}},
+	})
+	if err != nil {
+		t.Errorf("Want: nil, Got: %v", err)
+	}
+	if len(m.Installed()) != 1 {
+		t.Errorf("Want: 1, Got: %d", len(m.Installed()))
+	}
+}
+
+// メモリ上のFIBで、ルートの書き込みと削除、ルーティングテーブルの参照ができることを確認するテスト
+func TestMemoryApplyAndLookup(t *testing.T) {
+	_, connected, _ := net.ParseCIDR("10.200.100.0/24")
+	_, nw, _ := net.ParseCIDR("10.1.0.0/16")
+	m := NewMemory(connected)
+
+	dsts, _ := m.Lookup(connected)
+	if len(dsts) != 1 || dsts[0].String() != connected.String() {
+		t.Errorf("Want: %v, Got: %v", connected, dsts)
+	}
+
+	r := &Route{nw, []net.IP{net.ParseIP("10.0.0.1")}}
+	m.Apply([]*Change{{Dst: nw, Route: r}})
+	if rs := m.Installed(); len(rs) != 1 || !rs[0].Equal(r) {
+		t.Errorf("Want: %v, Got: %v", r.Show(), rs)
+	}
+	if dsts, _ := m.Lookup(nw); len(dsts) != 1 {
+		t.Errorf("Want: 1, Got: %d", len(dsts))
+	}
+
+	m.Apply([]*Change{{Dst: nw}})
+	if rs := m.Installed(); len(rs) != 0 {
+		t.Errorf("Want: 0, Got: %d", len(rs))
+	}
+}
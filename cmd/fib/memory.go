@@ -0,0 +1,126 @@
+package fib
+
+import (
+	"net"
+	"sync"
+)
+
+// メモリ上のルーティングテーブルを操作するFIB
+// 権限やLinuxのルーティングテーブルがなくてもRibの動作を確認できるように、
+// テストやカーネルに書き込まない環境で使用する。
+type Memory struct {
+	mu sync.Mutex
+	// カーネルのルーティングテーブルにあらかじめ存在するルート
+	tableRoutes []*TableRoute
+	// 書き込んだルート
+	installed map[string]*Route
+	// ルーティングテーブルの変更を通知するチャネル
+	watchers []chan struct{}
+}
+
+// networksはconnectedのルートとして扱う
+func NewMemory(networks ...*net.IPNet) *Memory {
+	m := &Memory{installed: make(map[string]*Route)}
+	for _, nw := range networks {
+		m.AddTableRoute(&TableRoute{Dst: nw, Source: SOURCE_CONNECTED})
+	}
+	return m
+}
+
+// カーネルのルーティングテーブルにルートを追加する
+func (m *Memory) AddTableRoute(tr *TableRoute) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tableRoutes = append(m.tableRoutes, tr)
+	m.notify()
+}
+
+// カーネルのルーティングテーブルからルートを削除する
+func (m *Memory) RemoveTableRoute(dst *net.IPNet) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	trs := m.tableRoutes[:0]
+	for _, tr := range m.tableRoutes {
+		if tr.Dst.String() != dst.String() {
+			trs = append(trs, tr)
+		}
+	}
+	m.tableRoutes = trs
+	m.notify()
+}
+
+func (m *Memory) notify() {
+	for _, w := range m.watchers {
+		select {
+		case w <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (m *Memory) Lookup(dst *net.IPNet) ([]*net.IPNet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	dsts := []*net.IPNet{}
+	for _, tr := range m.tableRoutes {
+		if tr.Dst.Contains(dst.IP) {
+			dsts = append(dsts, tr.Dst)
+		}
+	}
+	for _, r := range m.installed {
+		if r.Dst.Contains(dst.IP) {
+			dsts = append(dsts, r.Dst)
+		}
+	}
+	return dsts, nil
+}
+
+func (m *Memory) Apply(changes []*Change) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, c := range diff(m.installed, changes) {
+		if c.Route == nil {
+			delete(m.installed, c.Dst.String())
+			continue
+		}
+		m.installed[c.Dst.String()] = c.Route
+	}
+	return nil
+}
+
+func (m *Memory) Routes() ([]*TableRoute, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*TableRoute{}, m.tableRoutes...), nil
+}
+
+func (m *Memory) ResolveNextHop(nh net.IP) (*NextHopResolution, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return ResolveNextHop(m.tableRoutes, nh), nil
+}
+
+func (m *Memory) Watch(done <-chan struct{}) (<-chan struct{}, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w := make(chan struct{}, 1)
+	m.watchers = append(m.watchers, w)
+	go func() {
+		<-done
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		for i, x := range m.watchers {
+			if x == w {
+				m.watchers = append(m.watchers[:i], m.watchers[i+1:]...)
+				break
+			}
+		}
+	}()
+	return w, nil
+}
+
+func (m *Memory) Installed() []*Route {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return routes(m.installed)
+}
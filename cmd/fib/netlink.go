@@ -0,0 +1,214 @@
+package fib
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/vishvananda/netlink"
+)
+
+const (
+	// /etc/iproute2/rt_protos で bgp として定義されているプロトコル番号
+	// 自身が書き込んだルートを他のルートと区別するために使用する
+	RTPROT_BGP = 186
+	// 書き込むルートのメトリック
+	DEFAULT_METRIC = 20
+)
+
+// ルートのプロトコル番号 (linux/rtnetlink.h)
+const (
+	rtprotBoot   = 3
+	rtprotKernel = 2
+	rtprotStatic = 4
+)
+
+// netlinkでLinuxカーネルのルーティングテーブルを操作するFIB
+//
+// 書き込んだルートを記録しておき、LocRibの変更との差分だけを
+// ルートごとにRouteReplace / RouteDel で反映する。
+// 1つのルートごとにカーネルの応答を待つため、フルテーブルの書き込みには時間がかかる。
+// 複数のリクエストを応答を待たずに送るバッチ処理は、vishvananda/netlinkのHandleでは
+// 行えないため実装していない。
+type Netlink struct {
+	mu       sync.Mutex
+	handle   *netlink.Handle
+	Protocol int
+	Metric   int
+	// 書き込んだルート
+	installed map[string]*Route
+}
+
+func NewNetlink() (*Netlink, error) {
+	// netlinkのソケットを使い回すため、Handleを作成しておく
+	h, err := netlink.NewHandle(netlink.FAMILY_V4)
+	if err != nil {
+		return nil, err
+	}
+	return &Netlink{
+		handle:    h,
+		Protocol:  RTPROT_BGP,
+		Metric:    DEFAULT_METRIC,
+		installed: make(map[string]*Route),
+	}, nil
+}
+
+func (m *Netlink) Close() {
+	m.handle.Delete()
+}
+
+// 以前の起動時に書き込まれ、残ったままになっているルートを削除する
+// 起動時に1度だけ呼び出す
+func (m *Netlink) CleanupStale() error {
+	routes, err := m.handle.RouteListFiltered(
+		netlink.FAMILY_V4,
+		&netlink.Route{Protocol: m.Protocol},
+		netlink.RT_FILTER_PROTOCOL,
+	)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for i := range routes {
+		if err := m.handle.RouteDel(&routes[i]); err != nil {
+			errs = append(errs, fmt.Errorf("cannot delete stale route %v: %w", routes[i].Dst, err))
+			continue
+		}
+		log.Info("stale route is deleted", "prefix", routes[i].Dst.String())
+	}
+	return errors.Join(errs...)
+}
+
+// 変更をカーネルのルーティングテーブルに反映する
+// 一部の変更に失敗しても残りの変更は反映し、失敗した変更の*ChangeErrorをまとめたエラーを返す。
+func (m *Netlink) Apply(changes []*Change) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var errs []error
+	for _, c := range diff(m.installed, changes) {
+		key := c.Dst.String()
+		if c.Route == nil {
+			err := m.handle.RouteDel(m.netlinkRoute(m.installed[key]))
+			// 既に存在しない場合も削除できたものとして扱う
+			if err != nil && !isNotExist(err) {
+				errs = append(errs, &ChangeError{c.Dst, fmt.Errorf("cannot delete route %v: %w", c.Dst, err)})
+				continue
+			}
+			delete(m.installed, key)
+			log.Debug("route is deleted", "prefix", c.Dst.String())
+			continue
+		}
+		if err := m.handle.RouteReplace(m.netlinkRoute(c.Route)); err != nil {
+			errs = append(errs, &ChangeError{c.Dst, fmt.Errorf("cannot replace route %v: %w", c.Route.Show(), err)})
+			continue
+		}
+		m.installed[key] = c.Route
+		log.Debug("route is replaced", "route", c.Route.Show())
+	}
+	return errors.Join(errs...)
+}
+
+func (m *Netlink) netlinkRoute(r *Route) *netlink.Route {
+	nr := &netlink.Route{
+		Dst:      r.Dst,
+		Protocol: m.Protocol,
+		Priority: m.Metric,
+	}
+	if len(r.NextHops) == 1 {
+		nr.Gw = r.NextHops[0]
+		return nr
+	}
+	for _, nh := range r.NextHops {
+		nr.MultiPath = append(nr.MultiPath, &netlink.NexthopInfo{Gw: nh})
+	}
+	return nr
+}
+
+func (m *Netlink) Lookup(dst *net.IPNet) ([]*net.IPNet, error) {
+	routes, err := m.handle.RouteList(nil, netlink.FAMILY_V4)
+	if err != nil {
+		return nil, err
+	}
+	dsts := []*net.IPNet{}
+	for _, route := range routes {
+		if route.Dst != nil && route.Dst.Contains(dst.IP) {
+			dsts = append(dsts, route.Dst)
+		}
+	}
+	return dsts, nil
+}
+
+func (m *Netlink) Routes() ([]*TableRoute, error) {
+	rs, err := m.handle.RouteList(nil, netlink.FAMILY_V4)
+	if err != nil {
+		return nil, err
+	}
+	trs := []*TableRoute{}
+	for _, r := range rs {
+		// デフォルトルートと自身が書き込んだルートは再配布しない
+		if r.Dst == nil || r.Protocol == m.Protocol {
+			continue
+		}
+		tr := &TableRoute{Dst: r.Dst, Source: routeSource(r), Gateway: r.Gw, Metric: r.Priority}
+		if tr.Gateway == nil && len(r.MultiPath) > 0 {
+			tr.Gateway = r.MultiPath[0].Gw
+		}
+		trs = append(trs, tr)
+	}
+	return trs, nil
+}
+
+func (m *Netlink) ResolveNextHop(nh net.IP) (*NextHopResolution, error) {
+	trs, err := m.Routes()
+	if err != nil {
+		return nil, err
+	}
+	return ResolveNextHop(trs, nh), nil
+}
+
+func (m *Netlink) Watch(done <-chan struct{}) (<-chan struct{}, error) {
+	updates := make(chan netlink.RouteUpdate)
+	if err := netlink.RouteSubscribe(updates, done); err != nil {
+		return nil, err
+	}
+	ch := make(chan struct{}, 1)
+	go func() {
+		for u := range updates {
+			// 自身が書き込んだルートの変更は通知しない
+			if u.Route.Protocol == m.Protocol {
+				continue
+			}
+			select {
+			case ch <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return ch, nil
+}
+
+func routeSource(r netlink.Route) RouteSource {
+	switch r.Protocol {
+	case rtprotKernel:
+		if r.Scope == netlink.SCOPE_LINK {
+			return SOURCE_CONNECTED
+		}
+		return SOURCE_KERNEL
+	// ip route addで追加したルートはbootになる
+	case rtprotBoot, rtprotStatic:
+		return SOURCE_STATIC
+	default:
+		return SOURCE_KERNEL
+	}
+}
+
+func (m *Netlink) Installed() []*Route {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return routes(m.installed)
+}
+
+func isNotExist(err error) bool {
+	return errors.Is(err, errNoSuchProcess)
+}
@@ -2,58 +2,243 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
+	"net"
 	"os"
 	"os/signal"
 	"sync"
 	"syscall"
-	"time"
 
+	"github.com/SotaUeda/gobgp/bmp"
+	"github.com/SotaUeda/gobgp/config"
+	"github.com/SotaUeda/gobgp/fib"
+	"github.com/SotaUeda/gobgp/logging"
+	"github.com/SotaUeda/gobgp/metrics"
+	"github.com/SotaUeda/gobgp/mrt"
 	"github.com/SotaUeda/gobgp/peer"
+	"github.com/SotaUeda/gobgp/rpki"
+	"github.com/SotaUeda/gobgp/server"
 )
 
 func main() {
-	// 引数で与えられた文字列を順に結合してconfig文字列を作成
-	config := os.Args[1]
-	confStrs := []string{
-		config,
-	}
-	var peers []peer.Peer
-	for _, s := range confStrs {
-		c, err := peer.ParseConfig(s)
+	// RPKIキャッシュサーバー(RTR)のアドレス。指定しない場合は経路を検証しない
+	// 設定ファイルを使う場合は、設定ファイルのglobal.rpkiで指定する
+	rpkiAddr := flag.String("rpki", "", "address of RPKI-to-Router cache server (host:port)")
+	// BGP Multipathの設定
+	// 設定ファイルを使う場合は、設定ファイルのglobal.multipathで指定する
+	maxPaths := flag.Int("maximum-paths", 1, "maximum number of eBGP paths installed to kernel")
+	maxPathsIBGP := flag.Int("maximum-paths-ibgp", 1, "maximum number of iBGP paths installed to kernel")
+	asPathRelax := flag.Bool("as-path-multipath-relax", false, "treat paths with different as-path of the same length as equal")
+	// カーネルのルーティングテーブルに書き込まず、書き込む予定の変更をログに出力する
+	dryRun := flag.Bool("dry-run", false, "log FIB changes instead of writing them to kernel")
+	// 設定ファイル。指定しない場合は引数のconfig文字列を使う
+	confFile := flag.String("f", "", "path to configuration file (TOML)")
+	// gRPC APIのアドレス。"unix:/path/to/sock" でUnixドメインソケットを使う。空の場合はAPIを提供しない
+	apiAddr := flag.String("api", "127.0.0.1:50051", "address of gRPC API (host:port or unix:/path)")
+	metricsAddr := flag.String("metrics", "", "address to expose Prometheus metrics on /metrics (e.g. :9179)")
+	// ログの形式とレベル、Debugログを出力するサブシステム
+	logFormat := flag.String("log-format", "text", "log format (text or json)")
+	logLevel := flag.String("log-level", "info", "log level (debug, info, warn or error)")
+	// MRTのダンプファイルから経路を読み込む
+	mrtInject := flag.String("mrt-inject", "", "inject routes of TABLE_DUMP_V2 file into LocRib as local paths")
+	mrtReplay := flag.String("mrt-replay", "", "replay received updates of BGP4MP file as paths from the recorded peers")
+	mrtReplaySpeed := flag.Float64("mrt-replay-speed", 1, "speed of replaying updates (1 is original pace, 0 is as fast as possible)")
+	mrtPeer := flag.String("mrt-peer", "", "use only routes received from this peer address in MRT file")
+	debugSubs := flag.String("debug", "", "comma-separated subsystems to output debug logs (fsm,packet,rib,fib,server,rpki,bmp,mrt or all)")
+	flag.Parse()
+
+	if err := setupLogging(*logFormat, *logLevel, *debugSubs); err != nil {
+		fmt.Fprintf(os.Stderr, "Log Error: %v\n", err)
+		os.Exit(1)
+	}
+	log := logging.Logger(logging.SERVER)
+	var mrtPeerIP net.IP
+	if *mrtPeer != "" {
+		if mrtPeerIP = net.ParseIP(*mrtPeer); mrtPeerIP == nil {
+			log.Error("invalid mrt peer address", "address", *mrtPeer)
+			os.Exit(1)
+		}
+	}
+
+	// LocRibの設定と、Peerごとの設定
+	var lrConf *peer.Config
+	var peerConfs []*peer.Config
+	var bmpStations []*config.BMPStation
+	var mrtDumps []*config.MRTDump
+	if *confFile != "" {
+		conf, err := config.Load(*confFile)
 		if err != nil {
-			fmt.Printf("Config Error: %v\n", err)
+			log.Error("cannot load config", "file", *confFile, "error", err)
 			os.Exit(1)
 		}
-		// LocRibはすべてのPeerで共有する
-		// 排他制御のためにsync.Mutexを使う
-		locRib, err := peer.NewLocRib(c)
+		lrConf = conf.LocRibConfig()
+		peerConfs = conf.PeerConfigs()
+		bmpStations = conf.BMPStations()
+		mrtDumps = conf.MRTDumps()
+		// 読み込み直した設定と食い違わないように、設定ファイルの値だけを使う
+		flag.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "rpki", "maximum-paths", "maximum-paths-ibgp", "as-path-multipath-relax":
+				log.Warn("flag is ignored when config file is specified", "flag", f.Name)
+			}
+		})
+	} else {
+		c, err := peer.ParseConfig(flag.Arg(0))
 		if err != nil {
-			fmt.Printf("LocRib Error: %v\n", err)
+			log.Error("cannot parse config", "error", err)
 			os.Exit(1)
 		}
-		var mu sync.Mutex
-		mu.Lock()
-		peers = append(peers, *peer.NewPeer(c, locRib))
-		mu.Unlock()
-	}
-	for _, p := range peers {
-		p.Start()
+		c.Multipath = &peer.MultipathConfig{
+			MaximumPaths:     *maxPaths,
+			MaximumPathsIBGP: *maxPathsIBGP,
+			AsPathRelax:      *asPathRelax,
+		}
+		c.RPKIServer = *rpkiAddr
+		lrConf = c
+		peerConfs = []*peer.Config{c}
 	}
 
 	ctx, cansel := context.WithCancel(context.Background())
-	for _, p := range peers {
+
+	nl, err := fib.NewNetlink()
+	if err != nil {
+		log.Error("cannot open netlink", "error", err)
+		os.Exit(1)
+	}
+	defer nl.Close()
+	var fm fib.FIB = nl
+	if *dryRun {
+		fm = fib.NewDryRun(nl)
+	} else if err := nl.CleanupStale(); err != nil {
+		// 前回の起動時に書き込んだルートが残っていれば削除する
+		log.Warn("cannot clean up stale routes", "error", err)
+	}
+
+	// 受信した経路のNextHopをルーティングテーブルから解決する
+	nht := peer.NewNextHopTracker(fm)
+
+	// LocRibはすべてのPeerで共有する
+	locRib, err := peer.NewLocRib(lrConf, fm)
+	if err != nil {
+		log.Error("cannot create loc_rib", "error", err)
+		os.Exit(1)
+	}
+	// 設定を読み込み直してRPKIキャッシュサーバーを指定できるように、VRPのTableは常に作成する
+	// キャッシュサーバーへの接続はServerが管理する
+	locRib.RPKI = rpki.NewTable()
+	locRib.NextHops = nht
+
+	s := server.New(locRib, lrConf)
+	s.ConfigPath = *confFile
+	// 送受信したMessageを書き込むUpdateDumperは、Peerを起動する前に設定する
+	var recorders multiRecorder
+	// 終了する前にファイルを閉じ終えるまで待つ
+	var mrtWG sync.WaitGroup
+	var tableDumpers []*mrt.TableDumper
+	for _, d := range mrtDumps {
+		w := mrt.NewWriter(d.FileName)
+		w.Gzip = d.Gzip
+		switch d.Type {
+		case config.MRT_DUMP_UPDATES:
+			w.RotateInterval = d.RotationInterval
+			w.RotateSize = d.RotationSize
+			ud := mrt.NewUpdateDumper(w)
+			recorders = append(recorders, ud)
+			mrtWG.Add(1)
+			go func() {
+				defer mrtWG.Done()
+				ud.Run(ctx)
+			}()
+		case config.MRT_DUMP_TABLE:
+			td := &mrt.TableDumper{Writer: w, Interval: d.DumpInterval, Table: mrt.LOC_RIB}
+			if d.AdjRibIn {
+				td.Table = mrt.ADJ_RIB_IN
+			}
+			tableDumpers = append(tableDumpers, td)
+		}
+	}
+	if len(recorders) > 0 {
+		s.Recorder = recorders
+	}
+	s.Start(ctx, peerConfs)
+	for _, td := range tableDumpers {
+		td.Server = s
+		mrtWG.Add(1)
+		go func() {
+			defer mrtWG.Done()
+			td.Run(ctx)
+		}()
+	}
+
+	if *mrtInject != "" {
+		if err := injectMRT(s, *mrtInject, mrtPeerIP); err != nil {
+			log.Error("cannot inject mrt routes", "file", *mrtInject, "error", err)
+		}
+	}
+	if *mrtReplay != "" {
 		go func() {
-			for {
-				if err := p.Next(ctx); err != nil {
-					fmt.Printf("Error: %v\n", err)
-					return
-				}
-				time.Sleep(100 * time.Millisecond)
+			rp := &mrt.Replayer{Server: s, Peer: mrtPeerIP, Speed: *mrtReplaySpeed}
+			if err := replayMRT(ctx, rp, *mrtReplay); err != nil && ctx.Err() == nil {
+				log.Error("cannot replay mrt updates", "file", *mrtReplay, "error", err)
 			}
 		}()
 	}
 
+	nht.OnChange = func() {
+		for _, p := range s.Peers() {
+			p.NotifyNextHopChanged()
+		}
+	}
+	go func() {
+		if err := nht.Run(ctx); err != nil {
+			log.Error("next hop tracker is stopped", "error", err)
+		}
+	}()
+	// connected / static / kernelのルートが変わったら、自身で生成する経路を作り直す
+	go func() {
+		if err := locRib.WatchRoutingTable(ctx); err != nil {
+			log.Error("routing table watcher is stopped", "error", err)
+		}
+	}()
+
+	if *apiAddr != "" {
+		go func() {
+			if err := s.Serve(ctx, *apiAddr); err != nil {
+				log.Error("api server is stopped", "address", *apiAddr, "error", err)
+			}
+		}()
+	}
+
+	for _, st := range bmpStations {
+		client := bmp.NewClient(st.Addr, s)
+		client.StatisticsInterval = st.StatisticsInterval
+		client.RetryInterval = st.RetryInterval
+		go client.Run(ctx)
+	}
+
+	if *metricsAddr != "" {
+		go func() {
+			if err := metrics.New(s).Serve(ctx, *metricsAddr); err != nil {
+				log.Error("metrics server is stopped", "address", *metricsAddr, "error", err)
+			}
+		}()
+	}
+
+	// SIGHUPを受信したら設定ファイルを読み込み直す
+	hups := make(chan os.Signal, 1)
+	signal.Notify(hups, syscall.SIGHUP)
+	go func() {
+		for range hups {
+			d, err := s.ReloadFile()
+			if err != nil {
+				log.Error("cannot reload config", "file", *confFile, "error", err)
+				continue
+			}
+			log.Info("config is reloaded", "file", *confFile, "diff", d.Show())
+		}
+	}()
+
 	// Ctrl-c入力時にプログラムを停止
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
@@ -62,4 +247,56 @@ func main() {
 		cansel()
 	}()
 	<-ctx.Done()
+	mrtWG.Wait()
+}
+
+// TABLE_DUMP_V2のファイルの経路をLocRibに追加する
+func injectMRT(s *server.Server, name string, peerIP net.IP) error {
+	r, err := mrt.Open(name)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	in := &mrt.Injector{Server: s, Peer: peerIP}
+	_, err = in.Inject(r)
+	return err
+}
+
+// BGP4MPのファイルのUpdateMessageを再生する
+func replayMRT(ctx context.Context, rp *mrt.Replayer, name string) error {
+	r, err := mrt.Open(name)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	_, err = rp.Run(ctx, r)
+	return err
+}
+
+// 送受信したMessageを複数のRecorderに渡す
+type multiRecorder []peer.MessageRecorder
+
+func (rs multiRecorder) RecordMessage(m *peer.RawMessage) {
+	for _, r := range rs {
+		r.RecordMessage(m)
+	}
+}
+
+func setupLogging(format, level, debug string) error {
+	f, err := logging.ParseFormat(format)
+	if err != nil {
+		return err
+	}
+	l, err := logging.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	subs, err := logging.ParseSubsystems(debug)
+	if err != nil {
+		return err
+	}
+	logging.Setup(os.Stderr, f)
+	logging.SetLevel(l)
+	logging.SetDebug(subs...)
+	return nil
 }
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/SotaUeda/gobgp/api"
+)
+
+// JSONが指定されている場合はmをJSONで出力し、それ以外はtableで表を出力する
+func (c *CLI) print(m proto.Message, table func()) error {
+	if !c.JSON {
+		table()
+		return nil
+	}
+	b, err := protojson.MarshalOptions{EmitUnpopulated: true}.Marshal(m)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(c.Out, "%s\n", b)
+	return err
+}
+
+var stateNames = map[api.SessionState]string{
+	api.SessionState_IDLE:         "Idle",
+	api.SessionState_CONNECT:      "Connect",
+	api.SessionState_OPEN_SENT:    "OpenSent",
+	api.SessionState_OPEN_CONFIRM: "OpenConfirm",
+	api.SessionState_ESTABLISHED:  "Establ",
+}
+
+func stateName(n *api.Neighbor) string {
+	if n.State.AdminDown {
+		return "Idle(Admin)"
+	}
+	return stateNames[n.State.SessionState]
+}
+
+// Establishedに遷移してからの時間を "1d 02:03:04" の形式で返す
+func uptime(n *api.Neighbor, now time.Time) string {
+	if n.State.Uptime == 0 {
+		return "never"
+	}
+	return formatDuration(now.Sub(time.Unix(n.State.Uptime, 0)))
+}
+
+func formatDuration(d time.Duration) string {
+	d = d.Truncate(time.Second)
+	days := d / (24 * time.Hour)
+	d -= days * 24 * time.Hour
+	h, m, s := d/time.Hour, (d%time.Hour)/time.Minute, (d%time.Minute)/time.Second
+	if days > 0 {
+		return fmt.Sprintf("%dd %02d:%02d:%02d", days, h, m, s)
+	}
+	return fmt.Sprintf("%02d:%02d:%02d", h, m, s)
+}
+
+func printNeighbors(out io.Writer, ns []*api.Neighbor) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Peer\tAS\tUp/Down\tState\t#Received\tAccepted")
+	now := time.Now()
+	for _, n := range ns {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%d\t%d\n",
+			n.Config.Address, n.Config.RemoteAs, uptime(n, now), stateName(n),
+			n.State.ReceivedPrefixes, n.State.AcceptedPrefixes)
+	}
+	w.Flush()
+}
+
+func printNeighbor(out io.Writer, n *api.Neighbor) {
+	c, s := n.Config, n.State
+	fmt.Fprintf(out, "BGP neighbor is %s, remote AS %d\n", c.Address, c.RemoteAs)
+	fmt.Fprintf(out, "  Local address: %s, mode: %s\n", c.LocalAddress, strings.ToLower(c.Mode.String()))
+	fmt.Fprintf(out, "  BGP state = %s, up for %s\n", stateName(n), uptime(n, time.Now()))
+	fmt.Fprintf(out, "  Hold time is %d, configured hold time is %d, keepalive interval is %d\n",
+		s.NegotiatedHoldTime, c.HoldTime, c.KeepaliveInterval)
+	fmt.Fprintln(out, "  Message statistics:")
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\t\tSent\tRcvd")
+	for _, row := range []struct {
+		name       string
+		sent, rcvd uint64
+	}{
+		{"Opens", s.Sent.GetOpen(), s.Received.GetOpen()},
+		{"Notifications", s.Sent.GetNotification(), s.Received.GetNotification()},
+		{"Updates", s.Sent.GetUpdate(), s.Received.GetUpdate()},
+		{"Keepalives", s.Sent.GetKeepalive(), s.Received.GetKeepalive()},
+		{"Total", s.Sent.GetTotal(), s.Received.GetTotal()},
+	} {
+		fmt.Fprintf(w, "\t%s:\t%d\t%d\n", row.name, row.sent, row.rcvd)
+	}
+	w.Flush()
+	fmt.Fprintln(out, "  Route statistics:")
+	fmt.Fprintf(out, "    Advertised: %d\n", s.AdvertisedPrefixes)
+	fmt.Fprintf(out, "    Received:   %d\n", s.ReceivedPrefixes)
+	fmt.Fprintf(out, "    Accepted:   %d\n", s.AcceptedPrefixes)
+}
+
+func asPath(p *api.Path) string {
+	as := make([]string, 0, len(p.AsPath))
+	for _, a := range p.AsPath {
+		as = append(as, fmt.Sprint(a))
+	}
+	return strings.Join(as, " ")
+}
+
+func printPaths(out io.Writer, ps []*api.Path, showBest bool) {
+	if len(ps) == 0 {
+		fmt.Fprintln(out, "Network not in table")
+		return
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "   Network\tNext Hop\tAS_PATH\tOrigin\tNeighbor")
+	for _, p := range ps {
+		mark := "  "
+		if showBest && p.Best {
+			mark = "*>"
+		} else if showBest {
+			mark = "* "
+		}
+		neighbor := p.Neighbor
+		if neighbor == "" {
+			neighbor = "local"
+		}
+		fmt.Fprintf(w, "%s %s\t%s\t%s\t%s\t%s\n", mark, p.Prefix, p.NextHop, asPath(p), p.Origin, neighbor)
+	}
+	w.Flush()
+}
+
+func printEvent(out io.Writer, ev *api.WatchEventResponse) {
+	now := time.Now().Format(time.TimeOnly)
+	switch e := ev.Event.(type) {
+	case *api.WatchEventResponse_Peer:
+		fmt.Fprintf(out, "[%s] neighbor %s: %s -> %s\n", now, e.Peer.Address,
+			stateNames[e.Peer.OldState], stateNames[e.Peer.NewState])
+	case *api.WatchEventResponse_BestPath:
+		p := e.BestPath.Path
+		if e.BestPath.Withdrawn {
+			fmt.Fprintf(out, "[%s] withdraw %s\n", now, p.Prefix)
+			return
+		}
+		fmt.Fprintf(out, "[%s] best %s via %s [%s] %s\n", now, p.Prefix, p.NextHop, asPath(p), p.Origin)
+	}
+}
+
+func printReload(out io.Writer, res *api.ReloadConfigResponse) {
+	if !res.Global && len(res.Added)+len(res.Removed)+len(res.Changed) == 0 {
+		fmt.Fprintln(out, "no changes")
+		return
+	}
+	if res.Global {
+		fmt.Fprintln(out, "global: changed")
+	}
+	for _, set := range []struct {
+		name  string
+		addrs []string
+	}{{"added", res.Added}, {"removed", res.Removed}, {"changed", res.Changed}} {
+		if len(set.addrs) > 0 {
+			fmt.Fprintf(out, "%s: %s\n", set.name, strings.Join(set.addrs, ", "))
+		}
+	}
+}
@@ -0,0 +1,193 @@
+// gobgpのデーモンをgRPC APIで操作するCLI
+//
+// 使い方:
+//
+//	gobgpcli [-api addr] [-j] neighbor
+//	gobgpcli neighbor <ip> [adj-in|adj-out [prefix] [longer-prefixes]]
+//	gobgpcli neighbor <ip> reset|softreset|shutdown|enable
+//	gobgpcli global rib [prefix] [longer-prefixes]
+//	gobgpcli global rib add|del <prefix>
+//	gobgpcli monitor [neighbor|rib]
+//	gobgpcli reload
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/SotaUeda/gobgp/api"
+)
+
+func main() {
+	apiAddr := flag.String("api", "127.0.0.1:50051", "address of gRPC API (host:port or unix:/path)")
+	asJSON := flag.Bool("j", false, "output in JSON")
+	flag.Parse()
+
+	conn, err := grpc.NewClient(*apiAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer conn.Close()
+
+	// monitorはCtrl-cで終了する
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	cli := &CLI{Client: api.NewGobgpApiClient(conn), Out: os.Stdout, JSON: *asJSON}
+	if err := cli.Run(ctx, flag.Args()); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+type CLI struct {
+	Client api.GobgpApiClient
+	Out    io.Writer
+	// trueの場合は表ではなくJSONで出力する
+	JSON bool
+}
+
+const usage = `usage:
+  neighbor
+  neighbor <ip> [adj-in|adj-out [prefix] [longer-prefixes]]
+  neighbor <ip> reset|softreset|shutdown|enable
+  global rib [prefix] [longer-prefixes]
+  global rib add|del <prefix>
+  monitor [neighbor|rib]
+  reload`
+
+func (c *CLI) Run(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("no command is specified\n%s", usage)
+	}
+	switch args[0] {
+	case "neighbor":
+		return c.neighbor(ctx, args[1:])
+	case "global":
+		return c.global(ctx, args[1:])
+	case "monitor":
+		return c.monitor(ctx, args[1:])
+	case "reload":
+		res, err := c.Client.ReloadConfig(ctx, &api.ReloadConfigRequest{})
+		if err != nil {
+			return err
+		}
+		return c.print(res, func() { printReload(c.Out, res) })
+	default:
+		return fmt.Errorf("unknown command %q\n%s", args[0], usage)
+	}
+}
+
+func (c *CLI) neighbor(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		res, err := c.Client.ListNeighbors(ctx, &api.ListNeighborsRequest{})
+		if err != nil {
+			return err
+		}
+		return c.print(res, func() { printNeighbors(c.Out, res.Neighbors) })
+	}
+	addr := args[0]
+	if len(args) == 1 {
+		n, err := c.Client.GetNeighbor(ctx, &api.GetNeighborRequest{Address: addr})
+		if err != nil {
+			return err
+		}
+		return c.print(n, func() { printNeighbor(c.Out, n) })
+	}
+	var err error
+	switch args[1] {
+	case "adj-in":
+		return c.rib(ctx, api.TableType_ADJ_RIB_IN, addr, args[2:])
+	case "adj-out":
+		return c.rib(ctx, api.TableType_ADJ_RIB_OUT, addr, args[2:])
+	case "reset":
+		_, err = c.Client.ResetNeighbor(ctx, &api.ResetNeighborRequest{Address: addr})
+	case "softreset":
+		_, err = c.Client.ResetNeighbor(ctx, &api.ResetNeighborRequest{Address: addr, Soft: true})
+	case "shutdown":
+		_, err = c.Client.DisableNeighbor(ctx, &api.DisableNeighborRequest{Address: addr})
+	case "enable":
+		_, err = c.Client.EnableNeighbor(ctx, &api.EnableNeighborRequest{Address: addr})
+	default:
+		return fmt.Errorf("unknown neighbor command %q\n%s", args[1], usage)
+	}
+	return err
+}
+
+func (c *CLI) global(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] != "rib" {
+		return fmt.Errorf("unknown global command\n%s", usage)
+	}
+	args = args[1:]
+	if len(args) > 0 && (args[0] == "add" || args[0] == "del") {
+		if len(args) != 2 {
+			return fmt.Errorf("prefix is required\n%s", usage)
+		}
+		var err error
+		if args[0] == "add" {
+			_, err = c.Client.AddPath(ctx, &api.AddPathRequest{Prefix: args[1]})
+		} else {
+			_, err = c.Client.DeletePath(ctx, &api.DeletePathRequest{Prefix: args[1]})
+		}
+		return err
+	}
+	return c.rib(ctx, api.TableType_LOC_RIB, "", args)
+}
+
+// args: [prefix] [longer-prefixes]
+func (c *CLI) rib(ctx context.Context, table api.TableType, neighbor string, args []string) error {
+	req := &api.ListPathRequest{TableType: table, Neighbor: neighbor}
+	if len(args) > 0 {
+		req.Prefixes = []string{args[0]}
+	}
+	if len(args) > 1 {
+		if args[1] != "longer-prefixes" {
+			return fmt.Errorf("unknown option %q\n%s", args[1], usage)
+		}
+		req.OrLonger = true
+	}
+	res, err := c.Client.ListPath(ctx, req)
+	if err != nil {
+		return err
+	}
+	return c.print(res, func() { printPaths(c.Out, res.Paths, table == api.TableType_LOC_RIB) })
+}
+
+// args: [neighbor|rib]
+func (c *CLI) monitor(ctx context.Context, args []string) error {
+	req := &api.WatchEventRequest{Peer: true, BestPath: true}
+	if len(args) > 0 {
+		switch args[0] {
+		case "neighbor":
+			req.BestPath = false
+		case "rib":
+			req.Peer = false
+		default:
+			return fmt.Errorf("unknown monitor target %q\n%s", args[0], usage)
+		}
+	}
+	stream, err := c.Client.WatchEvent(ctx, req)
+	if err != nil {
+		return err
+	}
+	for {
+		ev, err := stream.Recv()
+		if err == io.EOF || ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := c.print(ev, func() { printEvent(c.Out, ev) }); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"github.com/SotaUeda/gobgp/api"
+)
+
+// テストで使うメソッドだけを実装したクライアント
+type fakeClient struct {
+	api.GobgpApiClient
+	neighbors []*api.Neighbor
+	paths     []*api.Path
+	// 最後に受け取ったリクエスト
+	listPath *api.ListPathRequest
+	reset    *api.ResetNeighborRequest
+}
+
+func (f *fakeClient) ListNeighbors(ctx context.Context, in *api.ListNeighborsRequest, opts ...grpc.CallOption) (*api.ListNeighborsResponse, error) {
+	return &api.ListNeighborsResponse{Neighbors: f.neighbors}, nil
+}
+
+func (f *fakeClient) ListPath(ctx context.Context, in *api.ListPathRequest, opts ...grpc.CallOption) (*api.ListPathResponse, error) {
+	f.listPath = in
+	return &api.ListPathResponse{Paths: f.paths}, nil
+}
+
+func (f *fakeClient) ResetNeighbor(ctx context.Context, in *api.ResetNeighborRequest, opts ...grpc.CallOption) (*api.ResetNeighborResponse, error) {
+	f.reset = in
+	return &api.ResetNeighborResponse{}, nil
+}
+
+func newNeighbor(addr string, as uint32, state api.SessionState, up time.Duration) *api.Neighbor {
+	n := &api.Neighbor{
+		Config: &api.NeighborConfig{Address: addr, RemoteAs: as},
+		State:  &api.NeighborState{SessionState: state, ReceivedPrefixes: 3, AcceptedPrefixes: 2},
+	}
+	if up > 0 {
+		n.State.Uptime = time.Now().Add(-up).Unix()
+	}
+	return n
+}
+
+// neighborコマンドでPeerの一覧を表とJSONで出力できることを確認するテスト
+func TestCLINeighborSummary(t *testing.T) {
+	f := &fakeClient{neighbors: []*api.Neighbor{
+		newNeighbor("10.200.100.3", 64513, api.SessionState_ESTABLISHED, 26*time.Hour+3*time.Minute),
+		newNeighbor("10.200.101.3", 64514, api.SessionState_IDLE, 0),
+	}}
+	out := &bytes.Buffer{}
+	cli := &CLI{Client: f, Out: out}
+	if err := cli.Run(context.Background(), []string{"neighbor"}); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Want: 3 lines, Got: %q", out.String())
+	}
+	for i, want := range [][]string{
+		{"Peer", "AS", "Up/Down", "State", "#Received", "Accepted"},
+		{"10.200.100.3", "64513", "1d", "02:03:00", "Establ", "3", "2"},
+		{"10.200.101.3", "64514", "never", "Idle", "3", "2"},
+	} {
+		if got := strings.Fields(lines[i]); strings.Join(got, " ") != strings.Join(want, " ") {
+			t.Errorf("Want: %v, Got: %v", want, got)
+		}
+	}
+
+	out.Reset()
+	cli.JSON = true
+	if err := cli.Run(context.Background(), []string{"neighbor"}); err != nil {
+		t.Fatal(err)
+	}
+	var res struct {
+		Neighbors []struct {
+			Config struct{ Address string }
+		}
+	}
+	if err := json.Unmarshal(out.Bytes(), &res); err != nil {
+		t.Fatalf("invalid json %q: %v", out.String(), err)
+	}
+	if len(res.Neighbors) != 2 || res.Neighbors[0].Config.Address != "10.200.100.3" {
+		t.Errorf("Want: 2 neighbors, Got: %v", res)
+	}
+}
+
+// global ribとneighborの各コマンドが、対応するリクエストを送信することを確認するテスト
+func TestCLIRibAndReset(t *testing.T) {
+	f := &fakeClient{paths: []*api.Path{
+		{Prefix: "10.100.220.0/24", NextHop: "10.200.100.3", AsPath: []uint32{64513}, Origin: "IGP", Neighbor: "10.200.100.3", Best: true},
+	}}
+	out := &bytes.Buffer{}
+	cli := &CLI{Client: f, Out: out}
+	if err := cli.Run(context.Background(), []string{"global", "rib", "10.100.0.0/16", "longer-prefixes"}); err != nil {
+		t.Fatal(err)
+	}
+	if r := f.listPath; r.TableType != api.TableType_LOC_RIB || r.Prefixes[0] != "10.100.0.0/16" || !r.OrLonger {
+		t.Errorf("Want: LOC_RIB 10.100.0.0/16 longer, Got: %v", r)
+	}
+	if !strings.Contains(out.String(), "*> 10.100.220.0/24") {
+		t.Errorf("Want: best path mark, Got: %q", out.String())
+	}
+
+	if err := cli.Run(context.Background(), []string{"neighbor", "10.200.100.3", "adj-in"}); err != nil {
+		t.Fatal(err)
+	}
+	if r := f.listPath; r.TableType != api.TableType_ADJ_RIB_IN || r.Neighbor != "10.200.100.3" {
+		t.Errorf("Want: ADJ_RIB_IN 10.200.100.3, Got: %v", r)
+	}
+
+	if err := cli.Run(context.Background(), []string{"neighbor", "10.200.100.3", "softreset"}); err != nil {
+		t.Fatal(err)
+	}
+	if f.reset == nil || !f.reset.Soft {
+		t.Errorf("Want: soft reset, Got: %v", f.reset)
+	}
+
+	if err := cli.Run(context.Background(), []string{"neighbor", "10.200.100.3", "unknown"}); err == nil {
+		t.Errorf("Want: error, Got: nil")
+	}
+}
@@ -0,0 +1,206 @@
+// サブシステムごとにDebugログの出力を切り替えられる構造化ログ
+//
+// 各パッケージはLoggerで取得した*slog.Loggerを使ってログを出力する。
+// 出力先と形式はSetupで、全体のログレベルはSetLevelで、
+// サブシステムごとのDebugログの出力はSetDebugで変更できる。
+// Loggerを取得した後に変更しても反映される。
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+type Subsystem string
+
+const (
+	// PeerのStateの遷移とイベント
+	FSM Subsystem = "fsm"
+	// 送受信したMessage
+	PACKET Subsystem = "packet"
+	// AdjRibIn, LocRib, AdjRibOutの経路とNextHopの解決
+	RIB Subsystem = "rib"
+	// カーネルのルーティングテーブルへの書き込み
+	FIB Subsystem = "fib"
+	// Peerの管理、設定の再読み込み、API
+	SERVER Subsystem = "server"
+	// RPKIキャッシュサーバーとのセッション
+	RPKI Subsystem = "rpki"
+	// BMPステーションとのセッション
+	BMP Subsystem = "bmp"
+	// MRT形式のファイルへの書き込み
+	MRT Subsystem = "mrt"
+)
+
+var Subsystems = []Subsystem{FSM, PACKET, RIB, FIB, SERVER, RPKI, BMP, MRT}
+
+type Format string
+
+const (
+	TEXT Format = "text"
+	JSON Format = "json"
+)
+
+var (
+	mu sync.RWMutex
+	// 出力先のHandler
+	// レベルによる絞り込みはhandlerで行うため、すべてのレベルを出力する
+	base slog.Handler = newBase(os.Stderr, TEXT)
+	// Debugログを出力するサブシステム
+	debug = map[Subsystem]bool{}
+	// 全体のログレベル
+	level = new(slog.LevelVar)
+)
+
+func newBase(w io.Writer, f Format) slog.Handler {
+	opts := &slog.HandlerOptions{Level: slog.LevelDebug}
+	if f == JSON {
+		return slog.NewJSONHandler(w, opts)
+	}
+	return slog.NewTextHandler(w, opts)
+}
+
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case TEXT, JSON:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown log format: %s", s)
+	}
+}
+
+func ParseLevel(s string) (slog.Level, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("unknown log level: %s", s)
+	}
+	return l, nil
+}
+
+// カンマ区切りのサブシステムの一覧を変換する
+// "all"の場合はすべてのサブシステムを返す
+func ParseSubsystems(s string) ([]Subsystem, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if s == "all" {
+		return Subsystems, nil
+	}
+	subs := []Subsystem{}
+	for _, name := range strings.Split(s, ",") {
+		sub := Subsystem(strings.TrimSpace(name))
+		if !sub.valid() {
+			return nil, fmt.Errorf("unknown subsystem: %s", sub)
+		}
+		subs = append(subs, sub)
+	}
+	return subs, nil
+}
+
+func (s Subsystem) valid() bool {
+	for _, sub := range Subsystems {
+		if s == sub {
+			return true
+		}
+	}
+	return false
+}
+
+// ログの出力先と形式を変更する
+func Setup(w io.Writer, f Format) {
+	mu.Lock()
+	defer mu.Unlock()
+	base = newBase(w, f)
+}
+
+// 全体のログレベルを変更する
+// Debugにした場合はすべてのサブシステムのDebugログを出力する
+func SetLevel(l slog.Level) {
+	level.Set(l)
+}
+
+// subsのDebugログだけを出力するように変更する
+func SetDebug(subs ...Subsystem) {
+	mu.Lock()
+	defer mu.Unlock()
+	debug = map[Subsystem]bool{}
+	for _, s := range subs {
+		debug[s] = true
+	}
+}
+
+func current() slog.Handler {
+	mu.RLock()
+	defer mu.RUnlock()
+	return base
+}
+
+func debugEnabled(s Subsystem) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return debug[s]
+}
+
+// subのログを出力する*slog.Loggerを返す
+// ログにはsubsystemの属性が付く。
+func Logger(sub Subsystem) *slog.Logger {
+	return slog.New(&handler{sub: sub})
+}
+
+// レベルとサブシステムで絞り込み、その時点のbaseに出力するHandler
+type handler struct {
+	sub Subsystem
+	// WithAttrs, WithGroupで追加された属性
+	// baseが変わっても反映できるように、出力するときにbaseに適用する
+	ops []func(slog.Handler) slog.Handler
+	// opsを適用したbaseのキャッシュ
+	cache atomic.Pointer[resolved]
+}
+
+type resolved struct {
+	base    slog.Handler
+	handler slog.Handler
+}
+
+func (h *handler) Enabled(_ context.Context, l slog.Level) bool {
+	if l >= level.Level() {
+		return true
+	}
+	return l >= slog.LevelDebug && debugEnabled(h.sub)
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	return h.resolve().Handle(ctx, r)
+}
+
+func (h *handler) resolve() slog.Handler {
+	b := current()
+	if c := h.cache.Load(); c != nil && c.base == b {
+		return c.handler
+	}
+	rh := b.WithAttrs([]slog.Attr{slog.String("subsystem", string(h.sub))})
+	for _, op := range h.ops {
+		rh = op(rh)
+	}
+	h.cache.Store(&resolved{base: b, handler: rh})
+	return rh
+}
+
+func (h *handler) with(op func(slog.Handler) slog.Handler) *handler {
+	ops := append(append([]func(slog.Handler) slog.Handler{}, h.ops...), op)
+	return &handler{sub: h.sub, ops: ops}
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(b slog.Handler) slog.Handler { return b.WithAttrs(attrs) })
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	return h.with(func(b slog.Handler) slog.Handler { return b.WithGroup(name) })
+}
@@ -0,0 +1,108 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func setup(t *testing.T, f Format) *bytes.Buffer {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	Setup(buf, f)
+	SetLevel(slog.LevelInfo)
+	SetDebug()
+	t.Cleanup(func() {
+		Setup(os.Stderr, TEXT)
+		SetLevel(slog.LevelInfo)
+		SetDebug()
+	})
+	return buf
+}
+
+func records(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	rs := []map[string]any{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		r := map[string]any{}
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatal(err)
+		}
+		rs = append(rs, r)
+	}
+	return rs
+}
+
+// SetDebugで指定したサブシステムのDebugログだけが出力されることを確認するテスト
+func TestSetDebug(t *testing.T) {
+	buf := setup(t, JSON)
+	SetDebug(RIB)
+	Logger(RIB).Debug("rib debug")
+	Logger(FSM).Debug("fsm debug")
+	Logger(FSM).Info("fsm info")
+
+	rs := records(t, buf)
+	got := []string{}
+	for _, r := range rs {
+		got = append(got, r["subsystem"].(string)+":"+r["msg"].(string))
+	}
+	want := []string{"rib:rib debug", "fsm:fsm info"}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("Want: %v, Got: %v", want, got)
+	}
+
+	// 全体のログレベルをDebugにした場合はすべてのサブシステムのDebugログを出力する
+	buf.Reset()
+	SetDebug()
+	SetLevel(slog.LevelDebug)
+	Logger(FSM).Debug("fsm debug")
+	if got := len(records(t, buf)); got != 1 {
+		t.Errorf("Want: %v, Got: %v", 1, got)
+	}
+}
+
+// Loggerを取得した後にSetupで出力先を変更しても、属性を保ったまま反映されることを確認するテスト
+func TestLoggerFollowsSetup(t *testing.T) {
+	l := Logger(FSM).With("peer", "10.200.100.3")
+	buf := setup(t, JSON)
+	l.Info("state is changed", "new", "Established")
+
+	rs := records(t, buf)
+	if len(rs) != 1 {
+		t.Fatalf("Want: %v, Got: %v", 1, len(rs))
+	}
+	want := map[string]any{"subsystem": "fsm", "peer": "10.200.100.3", "new": "Established"}
+	for k, v := range want {
+		if rs[0][k] != v {
+			t.Errorf("Want: %v=%v, Got: %v", k, v, rs[0][k])
+		}
+	}
+}
+
+// サブシステムの一覧を変換できることを確認するテスト
+func TestParseSubsystems(t *testing.T) {
+	got, err := ParseSubsystems("fsm, rib")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []Subsystem{FSM, RIB}; !reflect.DeepEqual(want, got) {
+		t.Errorf("Want: %v, Got: %v", want, got)
+	}
+	got, err = ParseSubsystems("all")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(Subsystems, got) {
+		t.Errorf("Want: %v, Got: %v", Subsystems, got)
+	}
+	if _, err := ParseSubsystems("fsm,bgp"); err == nil {
+		t.Errorf("Want: error, Got: nil")
+	}
+}
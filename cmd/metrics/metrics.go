@@ -0,0 +1,219 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/SotaUeda/gobgp/packets"
+	"github.com/SotaUeda/gobgp/peer"
+	"github.com/SotaUeda/gobgp/server"
+)
+
+const namespace = "gobgp"
+
+// 本実装ではIPv4 Unicastのみ扱う
+const familyIPv4Unicast = "ipv4-unicast"
+
+var (
+	neighborLabels = []string{"neighbor", "remote_as"}
+
+	stateDesc = prometheus.NewDesc(
+		namespace+"_neighbor_session_state",
+		"BGP session state (0=Idle, 1=Connect, 2=OpenSent, 3=OpenConfirm, 4=Established).",
+		neighborLabels, nil,
+	)
+	adminDownDesc = prometheus.NewDesc(
+		namespace+"_neighbor_admin_down",
+		"Whether the neighbor is administratively disabled.",
+		neighborLabels, nil,
+	)
+	uptimeDesc = prometheus.NewDesc(
+		namespace+"_neighbor_uptime_seconds",
+		"Seconds since the session became Established. 0 if not Established.",
+		neighborLabels, nil,
+	)
+	sentDesc = prometheus.NewDesc(
+		namespace+"_neighbor_messages_sent_total",
+		"BGP messages sent to the neighbor by message type.",
+		append(neighborLabels, "type"), nil,
+	)
+	receivedDesc = prometheus.NewDesc(
+		namespace+"_neighbor_messages_received_total",
+		"BGP messages received from the neighbor by message type.",
+		append(neighborLabels, "type"), nil,
+	)
+	notificationsDesc = prometheus.NewDesc(
+		namespace+"_neighbor_notifications_total",
+		"NOTIFICATION messages by direction, error code and subcode.",
+		append(neighborLabels, "direction", "code", "subcode"), nil,
+	)
+	transitionsDesc = prometheus.NewDesc(
+		namespace+"_neighbor_fsm_transitions_total",
+		"FSM transitions into each state.",
+		append(neighborLabels, "state"), nil,
+	)
+	prefixesDesc = prometheus.NewDesc(
+		namespace+"_neighbor_prefixes",
+		"Prefixes received from (AdjRibIn), accepted as best path from, and advertised to (AdjRibOut) the neighbor.",
+		append(neighborLabels, "family", "kind"), nil,
+	)
+	locRibDesc = prometheus.NewDesc(
+		namespace+"_locrib_prefixes",
+		"Prefixes with a best path in the LocRib.",
+		[]string{"family"}, nil,
+	)
+	locRibPathsDesc = prometheus.NewDesc(
+		namespace+"_locrib_paths",
+		"Candidate paths in the LocRib.",
+		[]string{"family"}, nil,
+	)
+)
+
+var messageTypes = []packets.MessageType{
+	packets.Open, packets.Update, packets.Notification, packets.Keepalive,
+}
+
+var states = []peer.State{
+	peer.IDLE, peer.CONNECT, peer.OPEN_SENT, peer.OPEN_CONFIRM, peer.ESTABLISHED,
+}
+
+// Serverの状態をPrometheusのメトリクスとして公開する
+// Peerの状態はスクレイプのたびにPeer.Infoから取得し、
+// カーネルへの書き込みはLocRib.OnFIBApplyで観測する。
+type Collector struct {
+	s *server.Server
+
+	fibDuration prometheus.Histogram
+	fibChanges  prometheus.Counter
+	fibErrors   prometheus.Counter
+}
+
+func New(s *server.Server) *Collector {
+	c := &Collector{
+		s: s,
+		fibDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "fib_install_duration_seconds",
+			Help:      "Time taken to apply a batch of route changes to the kernel FIB.",
+			Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 14),
+		}),
+		fibChanges: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "fib_route_changes_total",
+			Help:      "Route changes applied to the kernel FIB.",
+		}),
+		fibErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "fib_errors_total",
+			Help:      "Errors returned while writing routes to the kernel FIB.",
+		}),
+	}
+	s.LocRib.OnFIBApply = c.observeFIBApply
+	return c
+}
+
+func (c *Collector) observeFIBApply(changes int, d time.Duration, err error) {
+	c.fibDuration.Observe(d.Seconds())
+	c.fibChanges.Add(float64(changes))
+	if err != nil {
+		c.fibErrors.Inc()
+	}
+}
+
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{
+		stateDesc, adminDownDesc, uptimeDesc, sentDesc, receivedDesc,
+		notificationsDesc, transitionsDesc, prefixesDesc, locRibDesc, locRibPathsDesc,
+	} {
+		ch <- d
+	}
+	c.fibDuration.Describe(ch)
+	c.fibChanges.Describe(ch)
+	c.fibErrors.Describe(ch)
+}
+
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	now := time.Now()
+	for _, p := range c.s.Peers() {
+		c.collectNeighbor(ch, p.Info(), now)
+	}
+	prefixes, paths := c.s.LocRib.Size()
+	ch <- prometheus.MustNewConstMetric(locRibDesc, prometheus.GaugeValue, float64(prefixes), familyIPv4Unicast)
+	ch <- prometheus.MustNewConstMetric(locRibPathsDesc, prometheus.GaugeValue, float64(paths), familyIPv4Unicast)
+	c.fibDuration.Collect(ch)
+	c.fibChanges.Collect(ch)
+	c.fibErrors.Collect(ch)
+}
+
+func (c *Collector) collectNeighbor(ch chan<- prometheus.Metric, info peer.PeerInfo, now time.Time) {
+	labels := []string{info.Config.RemoteIP.String(), fmt.Sprint(info.Config.RemoteAS)}
+	with := func(extra ...string) []string {
+		return append(append([]string{}, labels...), extra...)
+	}
+	gauge := func(d *prometheus.Desc, v float64, extra ...string) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.GaugeValue, v, with(extra...)...)
+	}
+	counter := func(d *prometheus.Desc, v uint64, extra ...string) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.CounterValue, float64(v), with(extra...)...)
+	}
+
+	gauge(stateDesc, float64(info.State))
+	adminDown := 0.0
+	if info.AdminDown {
+		adminDown = 1
+	}
+	gauge(adminDownDesc, adminDown)
+	uptime := 0.0
+	if !info.EstablishedAt.IsZero() {
+		uptime = now.Sub(info.EstablishedAt).Seconds()
+	}
+	gauge(uptimeDesc, uptime)
+
+	for _, t := range messageTypes {
+		name := strings.ToLower(t.Show())
+		counter(sentDesc, info.Sent.Get(t), name)
+		counter(receivedDesc, info.Received.Get(t), name)
+	}
+	for dir, mc := range map[string]peer.MessageCounters{"sent": info.Sent, "received": info.Received} {
+		for nc, v := range mc.Notifications {
+			counter(notificationsDesc, v, dir, fmt.Sprint(uint8(nc.Code)), fmt.Sprint(nc.Subcode))
+		}
+	}
+	for _, s := range states {
+		counter(transitionsDesc, info.Transitions[s], s.Show())
+	}
+
+	gauge(prefixesDesc, float64(info.ReceivedPrefixes), familyIPv4Unicast, "received")
+	gauge(prefixesDesc, float64(info.AcceptedPrefixes), familyIPv4Unicast, "accepted")
+	gauge(prefixesDesc, float64(info.AdvertisedPrefixes), familyIPv4Unicast, "advertised")
+}
+
+// addrで/metricsを公開する
+// ctxがキャンセルされるまで処理を続ける。
+func (c *Collector) Serve(ctx context.Context, addr string) error {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		c,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+	srv := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
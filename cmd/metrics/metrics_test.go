@@ -0,0 +1,101 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/SotaUeda/gobgp/config"
+	"github.com/SotaUeda/gobgp/fib"
+	"github.com/SotaUeda/gobgp/peer"
+	"github.com/SotaUeda/gobgp/server"
+)
+
+const testConfig = `
+[global]
+as = 64512
+router-id = "127.0.0.1"
+
+[[neighbors]]
+address = "127.0.0.2"
+remote-as = 64513
+mode = "active"
+port = 1
+
+[neighbors.timers]
+connect-retry = "1h"
+`
+
+func newCollector(t *testing.T) (*server.Server, *Collector) {
+	t.Helper()
+	c, err := config.Parse("test.toml", []byte(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lr, err := peer.NewLocRib(c.LocRibConfig(), fib.NewMemory())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := server.New(lr, c.LocRibConfig())
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	s.Start(ctx, c.PeerConfigs())
+	return s, New(s)
+}
+
+// Peerの状態とLocRibの経路数がメトリクスとして取得できることを確認するテスト
+func TestCollectNeighborAndLocRib(t *testing.T) {
+	s, c := newCollector(t)
+	_, nw, _ := net.ParseCIDR("10.100.0.0/24")
+	if err := s.AddLocalPath(nw); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `
+# HELP gobgp_neighbor_session_state BGP session state (0=Idle, 1=Connect, 2=OpenSent, 3=OpenConfirm, 4=Established).
+# TYPE gobgp_neighbor_session_state gauge
+gobgp_neighbor_session_state{neighbor="127.0.0.2",remote_as="64513"} 0
+# HELP gobgp_locrib_prefixes Prefixes with a best path in the LocRib.
+# TYPE gobgp_locrib_prefixes gauge
+gobgp_locrib_prefixes{family="ipv4-unicast"} 1
+# HELP gobgp_locrib_paths Candidate paths in the LocRib.
+# TYPE gobgp_locrib_paths gauge
+gobgp_locrib_paths{family="ipv4-unicast"} 1
+`
+	err := testutil.CollectAndCompare(c, strings.NewReader(want),
+		"gobgp_neighbor_session_state", "gobgp_locrib_prefixes", "gobgp_locrib_paths")
+	if err != nil {
+		t.Error(err)
+	}
+
+	// メッセージの種類4つ x 送受信 の系列がある
+	if got := testutil.CollectAndCount(c, "gobgp_neighbor_messages_sent_total", "gobgp_neighbor_messages_received_total"); got != 8 {
+		t.Errorf("Want: %v, Got: %v", 8, got)
+	}
+	// 状態ごとの遷移回数の系列がある
+	if got := testutil.CollectAndCount(c, "gobgp_neighbor_fsm_transitions_total"); got != 5 {
+		t.Errorf("Want: %v, Got: %v", 5, got)
+	}
+}
+
+// FIBへの書き込みの所要時間とエラーが記録されることを確認するテスト
+func TestObserveFIBApply(t *testing.T) {
+	s, c := newCollector(t)
+	s.LocRib.OnFIBApply(3, 2*time.Millisecond, nil)
+	s.LocRib.OnFIBApply(1, time.Millisecond, errors.New("permission denied"))
+
+	if got := testutil.ToFloat64(c.fibChanges); got != 4 {
+		t.Errorf("Want: %v, Got: %v", 4, got)
+	}
+	if got := testutil.ToFloat64(c.fibErrors); got != 1 {
+		t.Errorf("Want: %v, Got: %v", 1, got)
+	}
+	if got := testutil.CollectAndCount(c.fibDuration); got != 1 {
+		t.Errorf("Want: %v, Got: %v", 1, got)
+	}
+}
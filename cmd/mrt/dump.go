@@ -0,0 +1,244 @@
+package mrt
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/SotaUeda/gobgp/bgptype"
+	"github.com/SotaUeda/gobgp/logging"
+	"github.com/SotaUeda/gobgp/peer"
+	"github.com/SotaUeda/gobgp/server"
+)
+
+var log = logging.Logger(logging.MRT)
+
+// UpdateDumperがバッファに溜めたレコードをファイルに書き出す間隔
+const FLUSH_INTERVAL = time.Second
+
+// Peerが送受信したすべてのMessageを、BGP4MPのレコードとして書き込む
+// Server.Recorderに設定して使う。
+// Messageは送受信したバイト列をそのまま書き込むため、AS_PATHは2byteのAS番号のままになる。
+type UpdateDumper struct {
+	Writer *Writer
+
+	mu sync.Mutex
+	// 書き込みに失敗している間は、警告を繰り返し出力しない
+	failing bool
+}
+
+func NewUpdateDumper(w *Writer) *UpdateDumper {
+	return &UpdateDumper{Writer: w}
+}
+
+func (d *UpdateDumper) RecordMessage(m *peer.RawMessage) {
+	err := d.Writer.Write(&BGP4MPMessage{
+		Timestamp: m.Time,
+		PeerAS:    uint32(m.RemoteAS),
+		LocalAS:   uint32(m.LocalAS),
+		PeerIP:    m.RemoteAddr.IP,
+		LocalIP:   m.LocalAddr.IP,
+		Local:     m.Sent,
+		AddPath:   m.AddPath,
+		Message:   m.Bytes,
+	})
+	// 停止した後にPeerが送信したMessageは書き込まない
+	if errors.Is(err, os.ErrClosed) {
+		return
+	}
+	d.report(err)
+}
+
+func (d *UpdateDumper) report(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err != nil && !d.failing {
+		log.Error("cannot write bgp4mp record", "file", d.Writer.Path, "error", err)
+	}
+	d.failing = err != nil
+}
+
+// ctxがキャンセルされるまで、書き込んだレコードを定期的にファイルに書き出す
+// 終了するときはファイルを閉じる。
+func (d *UpdateDumper) Run(ctx context.Context) error {
+	t := time.NewTicker(FLUSH_INTERVAL)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return d.Writer.Close()
+		case <-t.C:
+			d.report(d.Writer.Flush())
+		}
+	}
+}
+
+type Table int
+
+const (
+	// LocRibの最適経路
+	LOC_RIB Table = iota
+	// 各PeerのAdjRibInの経路と、自身で生成した経路
+	ADJ_RIB_IN
+)
+
+// 経路をTABLE_DUMP_V2のレコードとして定期的に書き込む
+// Dumpするたびに新しいファイルに書き込む。
+type TableDumper struct {
+	Server *server.Server
+	Writer *Writer
+	// Dumpする間隔
+	Interval time.Duration
+	Table    Table
+}
+
+// ctxがキャンセルされるまで、起動した直後とIntervalごとに経路を書き込む
+func (d *TableDumper) Run(ctx context.Context) error {
+	t := time.NewTicker(d.Interval)
+	defer t.Stop()
+	for {
+		if err := d.Dump(); err != nil {
+			log.Error("cannot dump table", "file", d.Writer.Path, "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+		}
+	}
+}
+
+// PEER_INDEX_TABLEと、プレフィックスごとのRIBを新しいファイルに書き込む
+func (d *TableDumper) Dump() error {
+	now := time.Now()
+	table, res := d.collect(now)
+	index := make(map[string]uint16, len(table.Peers))
+	for i, p := range table.Peers {
+		index[p.Address.String()] = uint16(i)
+	}
+
+	// プレフィックスとADD-PATHの有無ごとにまとめる
+	type ribKey struct {
+		prefix  string
+		addPath bool
+	}
+	ribs := make(map[ribKey]*RIB)
+	for _, re := range res {
+		// 自身で生成した経路は、先頭の自身のPeerEntryを指す
+		var i uint16
+		if re.PeerAddr != nil {
+			var ok bool
+			if i, ok = index[re.PeerAddr.String()]; !ok {
+				// Dumpしている間に削除されたPeerの経路
+				continue
+			}
+		}
+		pathID := uint32(0)
+		if d.Table == ADJ_RIB_IN {
+			pathID = re.PathID
+		}
+		k := ribKey{re.NwAddr.String(), pathID != 0}
+		r, ok := ribs[k]
+		if !ok {
+			r = &RIB{Timestamp: now, Prefix: re.NwAddr, AddPath: k.addPath}
+			ribs[k] = r
+		}
+		ot := re.CreatedAt
+		if ot.IsZero() {
+			ot = now
+		}
+		r.Entries = append(r.Entries, RIBEntry{
+			PeerIndex:      i,
+			OriginatedTime: ot,
+			PathID:         pathID,
+			Attributes:     bgptype.PathAttributesToBytesAS4(re.GetPathAttributes()),
+		})
+	}
+	rs := make([]*RIB, 0, len(ribs))
+	for _, r := range ribs {
+		sort.Slice(r.Entries, func(i, j int) bool {
+			if r.Entries[i].PeerIndex != r.Entries[j].PeerIndex {
+				return r.Entries[i].PeerIndex < r.Entries[j].PeerIndex
+			}
+			return r.Entries[i].PathID < r.Entries[j].PathID
+		})
+		rs = append(rs, r)
+	}
+	sort.Slice(rs, func(i, j int) bool {
+		if c := comparePrefix(rs[i].Prefix, rs[j].Prefix); c != 0 {
+			return c < 0
+		}
+		return !rs[i].AddPath && rs[j].AddPath
+	})
+
+	records := make([]Record, 0, len(rs)+1)
+	records = append(records, table)
+	for i, r := range rs {
+		r.Sequence = uint32(i)
+		records = append(records, r)
+	}
+	if err := d.Writer.Rotate(); err != nil {
+		return err
+	}
+	if err := d.Writer.Write(records...); err != nil {
+		return err
+	}
+	log.Info("table is dumped", "file", d.Writer.Name(), "prefixes", len(rs), "peers", len(table.Peers))
+	return d.Writer.Rotate()
+}
+
+// Dumpする経路と、経路を受信したPeerのPEER_INDEX_TABLEを作成する
+// PEER_INDEX_TABLEの先頭は、自身で生成した経路のための自身のPeerEntryにする。
+func (d *TableDumper) collect(now time.Time) (*PeerIndexTable, []*peer.RibEntry) {
+	lc := d.Server.LocRibConfig()
+	id := lc.RouterID
+	if id == nil {
+		id = lc.LocalIP
+	}
+	table := &PeerIndexTable{
+		Timestamp:      now,
+		CollectorBGPID: id,
+		Peers:          []PeerEntry{{BGPID: id, Address: net.IPv4zero, AS: uint32(lc.LocalAS)}},
+	}
+	ps := d.Server.Peers()
+	for _, p := range ps {
+		info := p.Info()
+		e := PeerEntry{Address: info.Config.RemoteIP, AS: uint32(info.Config.RemoteAS), BGPID: net.IPv4zero}
+		if info.ReceivedOpen != nil {
+			e.BGPID = info.ReceivedOpen.BGPIdentifier
+		}
+		table.Peers = append(table.Peers, e)
+	}
+
+	lr := d.Server.LocRib
+	if d.Table == LOC_RIB {
+		return table, lr.Rib.Routes()
+	}
+	res := []*peer.RibEntry{}
+	for _, ps := range lr.RankedPaths() {
+		for _, re := range ps {
+			if re.PeerAddr == nil {
+				res = append(res, re)
+			}
+		}
+	}
+	for _, p := range ps {
+		res = append(res, p.AdjRibInRoutes()...)
+	}
+	return table, res
+}
+
+// プレフィックスをアドレス、プレフィックス長の順に比較する
+func comparePrefix(a, b *net.IPNet) int {
+	if c := bytes.Compare(a.IP.To16(), b.IP.To16()); c != 0 {
+		return c
+	}
+	ao, _ := a.Mask.Size()
+	bo, _ := b.Mask.Size()
+	return ao - bo
+}
@@ -0,0 +1,251 @@
+package mrt
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"time"
+
+	"github.com/SotaUeda/gobgp/bgptype"
+	"github.com/SotaUeda/gobgp/packets"
+	"github.com/SotaUeda/gobgp/peer"
+	"github.com/SotaUeda/gobgp/server"
+)
+
+// LocRibにまとめて追加、削除する経路の最大数
+const INJECT_BATCH_SIZE = 65536
+
+// TABLE_DUMP_V2のRIBの経路を、自身で生成した経路としてLocRibに追加する
+// PathAttributeはダンプのものをそのまま使い、Peerに広告するときに
+// NextHopとAS_PATHを書き換える。
+type Injector struct {
+	Server *server.Server
+	// 指定した場合は、このアドレスのPeerから受信した経路だけを追加する
+	// 指定しない場合は、プレフィックスごとに最初のRIB Entryの経路を追加する
+	Peer net.IP
+}
+
+// 追加した経路の数と、追加できなかった経路の数
+// IPv6の経路と、PathAttributeを変換できなかった経路は追加しない
+type InjectStats struct {
+	Injected int
+	Skipped  int
+}
+
+// すべてのレコードを読み込み、RIBの経路をLocRibに追加する
+func (in *Injector) Inject(r *Reader) (InjectStats, error) {
+	var stats InjectStats
+	var table *PeerIndexTable
+	batch := make([]*peer.RibEntry, 0, INJECT_BATCH_SIZE)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		in.Server.AddLocalPaths(batch)
+		stats.Injected += len(batch)
+		batch = make([]*peer.RibEntry, 0, INJECT_BATCH_SIZE)
+	}
+	for {
+		rec, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			flush()
+			return stats, err
+		}
+		switch rec := rec.(type) {
+		case *PeerIndexTable:
+			table = rec
+		case *RIB:
+			e, ok := in.selectEntry(table, rec)
+			if !ok {
+				continue
+			}
+			if rec.Prefix.IP.To4() == nil {
+				stats.Skipped++
+				continue
+			}
+			pas, err := bgptype.BytesToPathAttributesAS4(e.Attributes)
+			if err != nil {
+				log.Debug("cannot decode path attributes", "prefix", rec.Prefix, "error", err)
+				stats.Skipped++
+				continue
+			}
+			re := peer.NewRibEntry(rec.Prefix, pas...)
+			re.CreatedAt = e.OriginatedTime
+			batch = append(batch, re)
+			if len(batch) == INJECT_BATCH_SIZE {
+				flush()
+			}
+		}
+	}
+	flush()
+	log.Info("routes are injected", "injected", stats.Injected, "skipped", stats.Skipped)
+	return stats, nil
+}
+
+// RIBからLocRibに追加するEntryを選ぶ
+func (in *Injector) selectEntry(table *PeerIndexTable, r *RIB) (RIBEntry, bool) {
+	if in.Peer == nil {
+		if len(r.Entries) == 0 {
+			return RIBEntry{}, false
+		}
+		return r.Entries[0], true
+	}
+	if table == nil {
+		return RIBEntry{}, false
+	}
+	for _, e := range r.Entries {
+		if int(e.PeerIndex) < len(table.Peers) && table.Peers[e.PeerIndex].Address.Equal(in.Peer) {
+			return e, true
+		}
+	}
+	return RIBEntry{}, false
+}
+
+// BGP4MPでPeerから受信したUpdateMessageを、そのPeerから受信した経路として再生する
+// MRTのPeerごとにAdjRibInを持ち、Peerから受信した経路と同じようにLocRibにインストールする。
+// そのため、経路にはPeerのアドレスが設定され、自ASを含む経路は除外される。
+// 自身が送信したMessageは再生しない。
+type Replayer struct {
+	Server *server.Server
+	// 指定した場合は、このアドレスのPeerから受信したMessageだけを再生する
+	Peer net.IP
+	// 再生する速さ。1の場合はダンプの時刻の間隔どおりに、2の場合は2倍の速さで再生する
+	// 0の場合は待たずに再生する
+	Speed float64
+
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// 再生したUpdateMessageの数と、追加、削除した経路の数
+type ReplayStats struct {
+	Updates   int
+	Announced int
+	Withdrawn int
+}
+
+// 再生するMessageを受信したMRTのPeer
+type replayPeer struct {
+	ari    *peer.AdjRibIn
+	config *peer.Config
+}
+
+// mを受信したPeerを返す。初めてのPeerの場合は作成する
+// PeerのConfigは、LocRibの設定の自AS、アドレスと、mのPeerのAS、アドレスから作成する。
+func (rp *Replayer) peer(peers map[string]*replayPeer, m *BGP4MPMessage) *replayPeer {
+	key := m.PeerIP.String()
+	if p, ok := peers[key]; ok {
+		return p
+	}
+	lc := rp.Server.LocRibConfig()
+	as := bgptype.AS_TRANS
+	if m.PeerAS <= 0xffff {
+		as = bgptype.AutonomousSystemNumber(m.PeerAS)
+	}
+	p := &replayPeer{
+		ari: peer.NewAdjRibIn(peer.NewRib()),
+		config: &peer.Config{
+			ConfStr:  "mrt replay " + key,
+			LocalAS:  lc.LocalAS,
+			LocalIP:  lc.LocalIP,
+			RemoteAS: as,
+			RemoteIP: m.PeerIP,
+		},
+	}
+	peers[key] = p
+	return p
+}
+
+// すべてのレコードを読み込むか、ctxがキャンセルされるまで再生する
+func (rp *Replayer) Run(ctx context.Context, r *Reader) (ReplayStats, error) {
+	now, sleep := rp.now, rp.sleep
+	if now == nil {
+		now = time.Now
+	}
+	if sleep == nil {
+		sleep = sleepContext
+	}
+	var stats ReplayStats
+	peers := make(map[string]*replayPeer)
+	// 予定の時刻を過ぎたMessageの経路は、Peerごとにまとめて反映する
+	pending := make(map[*replayPeer]struct{})
+	routes := 0
+	flush := func() {
+		for p := range pending {
+			rp.Server.LocRib.InstallFromAdjRibIn(p.ari, p.config)
+		}
+		pending = make(map[*replayPeer]struct{})
+		routes = 0
+	}
+	var first, start time.Time
+	for {
+		if err := ctx.Err(); err != nil {
+			flush()
+			return stats, err
+		}
+		rec, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			flush()
+			return stats, err
+		}
+		m, ok := rec.(*BGP4MPMessage)
+		if !ok || m.Local || (rp.Peer != nil && !m.PeerIP.Equal(rp.Peer)) {
+			continue
+		}
+		msg, err := r.Message(m)
+		if err != nil {
+			log.Debug("cannot decode bgp4mp message", "peer", m.PeerIP, "error", err)
+			continue
+		}
+		um, ok := msg.(*packets.UpdateMessage)
+		if !ok {
+			continue
+		}
+		if first.IsZero() {
+			first, start = m.Timestamp, now()
+		}
+		if rp.Speed > 0 {
+			due := start.Add(time.Duration(float64(m.Timestamp.Sub(first)) / rp.Speed))
+			if d := due.Sub(now()); d > 0 {
+				flush()
+				if err := sleep(ctx, d); err != nil {
+					return stats, err
+				}
+			}
+		}
+		p := rp.peer(peers, m)
+		if err := p.ari.InstallFromUpdate(um, p.config); err != nil {
+			log.Debug("cannot install update", "peer", m.PeerIP, "error", err)
+			continue
+		}
+		pending[p] = struct{}{}
+		stats.Updates++
+		stats.Withdrawn += len(um.WithdrawnRoutes)
+		stats.Announced += len(um.NetworkLayerReachabilityInformation)
+		routes += len(um.WithdrawnRoutes) + len(um.NetworkLayerReachabilityInformation)
+		if routes >= INJECT_BATCH_SIZE {
+			flush()
+		}
+	}
+	flush()
+	log.Info("updates are replayed", "updates", stats.Updates, "announced", stats.Announced, "withdrawn", stats.Withdrawn)
+	return stats, nil
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,646 @@
+package mrt
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/SotaUeda/gobgp/bgptype"
+	"github.com/SotaUeda/gobgp/config"
+	"github.com/SotaUeda/gobgp/fib"
+	"github.com/SotaUeda/gobgp/packets"
+	"github.com/SotaUeda/gobgp/peer"
+	"github.com/SotaUeda/gobgp/server"
+)
+
+type record struct {
+	Type    RecordType
+	Subtype uint16
+	Body    []byte
+}
+
+func readRecords(t *testing.T, b []byte) []record {
+	t.Helper()
+	rs := []record{}
+	for len(b) > 0 {
+		if len(b) < HEADER_LENGTH {
+			t.Fatalf("Want: header, Got: %v", b)
+		}
+		l := int(binary.BigEndian.Uint32(b[8:12]))
+		if len(b) < HEADER_LENGTH+l {
+			t.Fatalf("Want: %d bytes, Got: %d", HEADER_LENGTH+l, len(b))
+		}
+		rs = append(rs, record{
+			Type:    RecordType(binary.BigEndian.Uint16(b[4:6])),
+			Subtype: binary.BigEndian.Uint16(b[6:8]),
+			Body:    b[HEADER_LENGTH : HEADER_LENGTH+l],
+		})
+		b = b[HEADER_LENGTH+l:]
+	}
+	return rs
+}
+
+// Peerに接続しないServerを起動する
+func newServer(t *testing.T) *server.Server {
+	t.Helper()
+	c, err := config.Parse("test.toml", []byte(`
+[global]
+as = 64512
+router-id = "127.0.0.1"
+
+[[neighbors]]
+address = "127.0.0.2"
+remote-as = 64513
+mode = "active"
+port = 1
+
+[neighbors.timers]
+connect-retry = "1h"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lr, err := peer.NewLocRib(c.LocRibConfig(), fib.NewMemory())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := server.New(lr, c.LocRibConfig())
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	s.Start(ctx, c.PeerConfigs())
+	return s
+}
+
+func readFile(t *testing.T, name string) []byte {
+	t.Helper()
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if filepath.Ext(name) == ".gz" {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = gz
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// TABLE_DUMP_V2のRIBが、AS番号を4byteでエンコードしたPathAttributeを持つことを確認するテスト
+func TestRIBToBytes(t *testing.T) {
+	origin := bgptype.IGP
+	nh := bgptype.NextHop(net.ParseIP("10.200.100.3").To4())
+	pas := []bgptype.PathAttribute{
+		&origin,
+		bgptype.NewAsPath(true, 64513, 65001),
+		&nh,
+		&bgptype.Aggregator{AS: 64513, Address: net.ParseIP("10.200.100.3").To4()},
+	}
+	_, nw, _ := net.ParseCIDR("10.100.0.0/17")
+	r := &RIB{
+		Timestamp: time.Unix(1700000000, 0),
+		Sequence:  7,
+		Prefix:    nw,
+		AddPath:   true,
+		Entries: []RIBEntry{{
+			PeerIndex:      1,
+			OriginatedTime: time.Unix(1600000000, 0),
+			PathID:         3,
+			Attributes:     bgptype.PathAttributesToBytesAS4(pas),
+		}},
+	}
+	attrs := []byte{
+		0x40, 1, 1, 0,
+		0x40, 2, 10, 2, 2, 0, 0, 0xfc, 0x01, 0, 0, 0xfd, 0xe9,
+		0x40, 3, 4, 10, 200, 100, 3,
+		0xc0, 7, 8, 0, 0, 0xfc, 0x01, 10, 200, 100, 3,
+	}
+	want := []byte{
+		0x65, 0x53, 0xf1, 0x00, 0, 13, 0, 8, 0, 0, 0, byte(4 + 1 + 3 + 2 + 12 + len(attrs)),
+		0, 0, 0, 7,
+		17, 10, 100, 0,
+		0, 1,
+		0, 1, 0x5f, 0x5e, 0x10, 0x00, 0, 0, 0, 3, 0, byte(len(attrs)),
+	}
+	want = append(want, attrs...)
+	if got := r.ToBytes(); !bytes.Equal(want, got) {
+		t.Errorf("Want: %v, Got: %v", want, got)
+	}
+}
+
+// 送受信の向きとADD-PATHによってBGP4MPのSubtypeが変わることを確認するテスト
+func TestBGP4MPMessageToBytes(t *testing.T) {
+	ka, err := packets.NewKeepaliveMessage().ToBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &BGP4MPMessage{
+		Timestamp: time.Unix(1700000000, 0),
+		PeerAS:    64513,
+		LocalAS:   64512,
+		PeerIP:    net.ParseIP("10.200.100.3"),
+		LocalIP:   net.ParseIP("10.200.100.2"),
+		Message:   ka,
+	}
+	want := []byte{0, 0, 0xfc, 0x01, 0, 0, 0xfc, 0x00, 0, 0, 0, 1, 10, 200, 100, 3, 10, 200, 100, 2}
+	want = append(want, ka...)
+	rs := readRecords(t, m.ToBytes())
+	if len(rs) != 1 || rs[0].Type != BGP4MP || rs[0].Subtype != BGP4MP_MESSAGE_AS4 || !bytes.Equal(want, rs[0].Body) {
+		t.Errorf("Want: %v, Got: %v", want, rs)
+	}
+
+	for _, c := range []struct {
+		local, addPath bool
+		want           uint16
+	}{
+		{true, false, BGP4MP_MESSAGE_AS4_LOCAL},
+		{false, true, BGP4MP_MESSAGE_AS4_ADDPATH},
+		{true, true, BGP4MP_MESSAGE_AS4_LOCAL_ADDPATH},
+	} {
+		m.Local, m.AddPath = c.local, c.addPath
+		if got := m.Subtype(); got != c.want {
+			t.Errorf("Want: %v, Got: %v", c.want, got)
+		}
+	}
+}
+
+// 大きさと時間でファイルを切り替え、同じ名前のファイルを上書きしないことを確認するテスト
+func TestWriterRotate(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	w := NewWriter(filepath.Join(dir, "updates.%Y%m%d.%H%M.mrt"))
+	w.now = func() time.Time { return now }
+	w.RotateSize = 100
+	w.RotateInterval = time.Minute
+	w.Gzip = true
+	m := &BGP4MPMessage{PeerIP: net.ParseIP("10.200.100.3"), LocalIP: net.ParseIP("10.200.100.2"), Message: make([]byte, 60)}
+
+	// 1つ目のレコードでは大きさを超えないため、同じファイルに書き込む
+	for i := 0; i < 2; i++ {
+		if err := w.Write(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	first := w.Name()
+	// 大きさを超えたため、同じ時刻の名前に番号を付けたファイルに切り替える
+	if err := w.Write(m); err != nil {
+		t.Fatal(err)
+	}
+	second := w.Name()
+	now = now.Add(time.Minute)
+	if err := w.Write(m); err != nil {
+		t.Fatal(err)
+	}
+	third := w.Name()
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(m); err != os.ErrClosed {
+		t.Errorf("Want: %v, Got: %v", os.ErrClosed, err)
+	}
+
+	want := []string{
+		filepath.Join(dir, "updates.20240506.0708.mrt.gz"),
+		filepath.Join(dir, "updates.20240506.0708.mrt.1.gz"),
+		filepath.Join(dir, "updates.20240506.0709.mrt.gz"),
+	}
+	if got := []string{first, second, third}; !reflect.DeepEqual(want, got) {
+		t.Errorf("Want: %v, Got: %v", want, got)
+	}
+	for i, n := range []int{2, 1, 1} {
+		if got := len(readRecords(t, readFile(t, want[i]))); got != n {
+			t.Errorf("Want: %v, Got: %v", n, got)
+		}
+	}
+}
+
+// 送受信したMessageを、向きとアドレスとともに書き込むことを確認するテスト
+func TestUpdateDumper(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "updates.mrt")
+	d := NewUpdateDumper(NewWriter(name))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		d.Run(ctx)
+		close(done)
+	}()
+	open, err := packets.NewOpenMessage(64512, net.ParseIP("10.200.100.2")).ToBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &peer.RawMessage{
+		Time:       time.Now(),
+		LocalAS:    64512,
+		RemoteAS:   64513,
+		LocalAddr:  &net.TCPAddr{IP: net.ParseIP("10.200.100.2"), Port: 40000},
+		RemoteAddr: &net.TCPAddr{IP: net.ParseIP("10.200.100.3"), Port: 179},
+		Sent:       true,
+		Bytes:      open,
+	}
+	d.RecordMessage(m)
+	m.Sent, m.AddPath = false, true
+	d.RecordMessage(m)
+	cancel()
+	<-done
+
+	rs := readRecords(t, readFile(t, name))
+	if len(rs) != 2 {
+		t.Fatalf("Want: %v, Got: %v", 2, len(rs))
+	}
+	if rs[0].Subtype != BGP4MP_MESSAGE_AS4_LOCAL || rs[1].Subtype != BGP4MP_MESSAGE_AS4_ADDPATH {
+		t.Errorf("Want: %v %v, Got: %v %v", BGP4MP_MESSAGE_AS4_LOCAL, BGP4MP_MESSAGE_AS4_ADDPATH, rs[0].Subtype, rs[1].Subtype)
+	}
+	if got := rs[0].Body[20:]; !bytes.Equal(open, got) {
+		t.Errorf("Want: %v, Got: %v", open, got)
+	}
+	// 停止した後に送受信したMessageは書き込まない
+	d.RecordMessage(m)
+	if got := len(readRecords(t, readFile(t, name))); got != 2 {
+		t.Errorf("Want: %v, Got: %v", 2, got)
+	}
+}
+
+// LocRibの経路を、PEER_INDEX_TABLEとプレフィックス順のRIBとして書き込むことを確認するテスト
+func TestTableDump(t *testing.T) {
+	s := newServer(t)
+	for _, p := range []string{"10.100.2.0/24", "10.100.1.0/24"} {
+		_, nw, _ := net.ParseCIDR(p)
+		if err := s.AddLocalPath(nw); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dir := t.TempDir()
+	d := &TableDumper{Server: s, Writer: NewWriter(filepath.Join(dir, "rib.mrt")), Table: ADJ_RIB_IN}
+	for i := 0; i < 2; i++ {
+		if err := d.Dump(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Dumpするたびに新しいファイルに書き込む
+	names, err := filepath.Glob(filepath.Join(dir, "rib.mrt*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(names)
+	if want := []string{filepath.Join(dir, "rib.mrt"), filepath.Join(dir, "rib.mrt.1")}; !reflect.DeepEqual(want, names) {
+		t.Fatalf("Want: %v, Got: %v", want, names)
+	}
+
+	rs := readRecords(t, readFile(t, names[0]))
+	if len(rs) != 3 {
+		t.Fatalf("Want: %v, Got: %v", 3, len(rs))
+	}
+	if rs[0].Type != TABLE_DUMP_V2 || rs[0].Subtype != PEER_INDEX_TABLE {
+		t.Errorf("Want: peer index table, Got: %v", rs[0])
+	}
+	// Collector BGP ID, View Name Length, Peer Count
+	if want := []byte{127, 0, 0, 1, 0, 0, 0, 2}; !bytes.Equal(want, rs[0].Body[:8]) {
+		t.Errorf("Want: %v, Got: %v", want, rs[0].Body[:8])
+	}
+	for i, p := range [][]byte{{24, 10, 100, 1}, {24, 10, 100, 2}} {
+		r := rs[i+1]
+		if r.Subtype != RIB_IPV4_UNICAST || binary.BigEndian.Uint32(r.Body[:4]) != uint32(i) || !bytes.Equal(p, r.Body[4:8]) {
+			t.Errorf("Want: %v, Got: %v", p, r)
+		}
+		// 自身で生成した経路は、先頭の自身のPeerEntryを指す
+		if n, idx := binary.BigEndian.Uint16(r.Body[8:10]), binary.BigEndian.Uint16(r.Body[10:12]); n != 1 || idx != 0 {
+			t.Errorf("Want: 1 entry of peer 0, Got: %v entries of peer %v", n, idx)
+		}
+	}
+}
+
+// ヘッダーを含むUpdateMessageのバイト列を作成する
+func updateBytes(wr, pas, nlri []byte) []byte {
+	l := packets.HEADER_LENGTH + 4 + len(wr) + len(pas) + len(nlri)
+	b := bytes.Repeat([]byte{0xff}, 16)
+	b = binary.BigEndian.AppendUint16(b, uint16(l))
+	b = append(b, byte(packets.Update))
+	b = binary.BigEndian.AppendUint16(b, uint16(len(wr)))
+	b = append(b, wr...)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(pas)))
+	b = append(b, pas...)
+	return append(b, nlri...)
+}
+
+// 書き込んだレコードを、圧縮されたファイルからそのまま読み込めることを確認するテスト
+func TestReadRecords(t *testing.T) {
+	ka, err := packets.NewKeepaliveMessage().ToBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := time.Unix(1700000000, 0)
+	_, nw, _ := net.ParseCIDR("10.100.0.0/17")
+	_, nw6, _ := net.ParseCIDR("2001:db8::/32")
+	want := []Record{
+		&PeerIndexTable{
+			Timestamp:      ts,
+			CollectorBGPID: net.IP{127, 0, 0, 1},
+			ViewName:       "view",
+			Peers: []PeerEntry{
+				{BGPID: net.IP{10, 200, 100, 3}, Address: net.IP{10, 200, 100, 3}, AS: 4200000000},
+				{BGPID: net.IP{10, 200, 100, 4}, Address: net.ParseIP("2001:db8::4"), AS: 64514},
+			},
+		},
+		&RIB{Timestamp: ts, Sequence: 1, Prefix: nw, AddPath: true, Entries: []RIBEntry{
+			{PeerIndex: 0, OriginatedTime: ts, PathID: 3, Attributes: []byte{0x40, 1, 1, 0}},
+			{PeerIndex: 1, OriginatedTime: ts, PathID: 4, Attributes: []byte{0x40, 1, 1, 2}},
+		}},
+		&RIB{Timestamp: ts, Sequence: 2, Prefix: nw6, Entries: []RIBEntry{
+			{PeerIndex: 1, OriginatedTime: ts, Attributes: []byte{0x40, 1, 1, 1}},
+		}},
+		&BGP4MPMessage{
+			Timestamp: ts, PeerAS: 64513, LocalAS: 64512,
+			PeerIP: net.IP{10, 200, 100, 3}, LocalIP: net.IP{10, 200, 100, 2},
+			Local: true, AddPath: true, Message: ka,
+		},
+		&BGP4MPMessage{
+			Timestamp: ts, PeerAS: 64513, LocalAS: 64512,
+			PeerIP: net.ParseIP("2001:db8::3"), LocalIP: net.ParseIP("2001:db8::2"),
+			AS2: true, Message: ka,
+		},
+		&BGP4MPStateChange{
+			Timestamp: ts, PeerAS: 64513, LocalAS: 64512,
+			PeerIP: net.IP{10, 200, 100, 3}, LocalIP: net.IP{10, 200, 100, 2},
+			OldState: 5, NewState: 6,
+		},
+		&UnknownRecord{Timestamp: ts, Type: 12, Subtype: 1, Body: []byte{1, 2, 3}},
+	}
+	name := filepath.Join(t.TempDir(), "records.mrt")
+	w := NewWriter(name)
+	w.Gzip = true
+	if err := w.Write(want...); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Open(name + ".gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	for _, rec := range want {
+		got, err := r.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(rec, got) {
+			t.Errorf("Want: %#v, Got: %#v", rec, got)
+		}
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("Want: %v, Got: %v", io.EOF, err)
+	}
+
+	// レコードの途中で終わっている
+	b := want[1].ToBytes()
+	if _, err := ReadRecord(bytes.NewReader(b[:len(b)-1])); err != io.ErrUnexpectedEOF {
+		t.Errorf("Want: %v, Got: %v", io.ErrUnexpectedEOF, err)
+	}
+	// BGP4MP_ETはマイクロ秒の時刻を持つ
+	b = want[3].ToBytes()
+	et := append([]byte{}, b[:HEADER_LENGTH]...)
+	binary.BigEndian.PutUint16(et[4:6], uint16(BGP4MP_ET))
+	binary.BigEndian.PutUint32(et[8:12], uint32(len(b)-HEADER_LENGTH+4))
+	et = append(et, 0, 0, 0x03, 0xe8)
+	et = append(et, b[HEADER_LENGTH:]...)
+	got, err := BytesToRecord(et)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := ts.Add(time.Millisecond); !got.(*BGP4MPMessage).Timestamp.Equal(want) {
+		t.Errorf("Want: %v, Got: %v", want, got.(*BGP4MPMessage).Timestamp)
+	}
+}
+
+// BGP4MPのUpdateMessageのAS_PATHを、OpenMessageのCapabilityか
+// AS_PATHの値から判断したAS番号の長さで変換することを確認するテスト
+func TestReaderMessage(t *testing.T) {
+	origin := []byte{0x40, 1, 1, 0}
+	nh := []byte{0x40, 3, 4, 10, 200, 100, 3}
+	nlri := []byte{24, 10, 100, 220}
+	update := func(asPath ...byte) []byte {
+		pas := append(append([]byte{}, origin...), 0x40, 2, byte(len(asPath)))
+		pas = append(append(pas, asPath...), nh...)
+		return updateBytes(nil, pas, nlri)
+	}
+	// 4byteのAS番号でしか解釈できない: 4200000000 64513
+	as4 := update(2, 2, 0xfa, 0x56, 0xea, 0x00, 0, 0, 0xfc, 0x01)
+	// 2byteのAS番号でしか解釈できない: 64514 64513
+	as2 := update(2, 2, 0xfc, 0x02, 0xfc, 0x01)
+	// どちらでも解釈できる: 4byteでは16777728(AS_TRANS)、2byteでは256と空のAS_SEQUENCE
+	both := update(2, 1, 0x01, 0x00, 0x02, 0x00)
+	open := func(caps ...packets.Capability) []byte {
+		b, err := packets.NewOpenMessage(64512, net.IP{10, 200, 100, 2}, caps...).ToBytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	msg := func(peerIP net.IP, local bool, b []byte) []byte {
+		m := &BGP4MPMessage{
+			Timestamp: time.Unix(1700000000, 0), PeerAS: 64513, LocalAS: 64512,
+			PeerIP: peerIP, LocalIP: net.IP{10, 200, 100, 2},
+			Local: local, Message: b,
+		}
+		return m.ToBytes()
+	}
+
+	var buf bytes.Buffer
+	// OpenMessageを読み込んでいないセッションは、AS_PATHの値から判断する
+	p1 := net.IP{10, 200, 100, 3}
+	for _, b := range [][]byte{as4, as2, both} {
+		buf.Write(msg(p1, false, b))
+	}
+	// 両方が4-octet AS Number Capabilityを広告したセッション
+	p2 := net.IP{10, 200, 100, 4}
+	buf.Write(msg(p2, true, open(&packets.FourOctetASCapability{AS: 64512})))
+	buf.Write(msg(p2, false, open(&packets.FourOctetASCapability{AS: 64513})))
+	buf.Write(msg(p2, false, both))
+	// 片方だけが広告したセッション
+	p3 := net.IP{10, 200, 100, 5}
+	buf.Write(msg(p3, true, open(&packets.FourOctetASCapability{AS: 64512})))
+	buf.Write(msg(p3, false, open()))
+	buf.Write(msg(p3, false, as2))
+
+	r, err := NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"[23456 64513]", "[64514 64513]", "[256][]", "[23456]", "[64514 64513]"}
+	got := []string{}
+	for {
+		rec, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := r.Message(rec.(*BGP4MPMessage))
+		if err != nil {
+			t.Fatal(err)
+		}
+		um, ok := m.(*packets.UpdateMessage)
+		if !ok {
+			continue
+		}
+		path := ""
+		for _, pa := range um.PathAttributes {
+			if seq, ok := pa.(*bgptype.AsSequence); ok {
+				path += fmt.Sprint([]bgptype.AutonomousSystemNumber(*seq))
+			}
+		}
+		got = append(got, path)
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("Want: %v, Got: %v", want, got)
+	}
+}
+
+// TABLE_DUMP_V2のRIBの経路を、指定したPeerのものだけLocRibに追加することを確認するテスト
+func TestInject(t *testing.T) {
+	s := newServer(t)
+	origin := bgptype.IGP
+	nh := bgptype.NextHop(net.IP{10, 200, 100, 3})
+	attrs := func(as ...bgptype.AutonomousSystemNumber) []byte {
+		return bgptype.PathAttributesToBytesAS4([]bgptype.PathAttribute{&origin, bgptype.NewAsPath(true, as...), &nh})
+	}
+	ts := time.Unix(1700000000, 0)
+	_, nw1, _ := net.ParseCIDR("10.100.1.0/24")
+	_, nw2, _ := net.ParseCIDR("10.100.2.0/24")
+	_, nw6, _ := net.ParseCIDR("2001:db8::/32")
+	var buf bytes.Buffer
+	for _, r := range []Record{
+		&PeerIndexTable{Timestamp: ts, CollectorBGPID: net.IP{127, 0, 0, 1}, Peers: []PeerEntry{
+			{BGPID: net.IP{10, 200, 100, 3}, Address: net.IP{10, 200, 100, 3}, AS: 64513},
+			{BGPID: net.IP{10, 200, 100, 4}, Address: net.IP{10, 200, 100, 4}, AS: 64514},
+		}},
+		&RIB{Timestamp: ts, Prefix: nw1, Entries: []RIBEntry{
+			{PeerIndex: 0, OriginatedTime: ts, Attributes: attrs(64513)},
+			{PeerIndex: 1, OriginatedTime: ts, Attributes: attrs(64514, 65001)},
+		}},
+		&RIB{Timestamp: ts, Sequence: 1, Prefix: nw2, Entries: []RIBEntry{
+			{PeerIndex: 0, OriginatedTime: ts, Attributes: attrs(64513)},
+		}},
+		&RIB{Timestamp: ts, Sequence: 2, Prefix: nw6, Entries: []RIBEntry{
+			{PeerIndex: 1, OriginatedTime: ts, Attributes: attrs(64514)},
+		}},
+	} {
+		buf.Write(r.ToBytes())
+	}
+	r, err := NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := &Injector{Server: s, Peer: net.IP{10, 200, 100, 4}}
+	stats, err := in.Inject(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (InjectStats{Injected: 1, Skipped: 1}); stats != want {
+		t.Errorf("Want: %v, Got: %v", want, stats)
+	}
+	ps := s.LocRib.Paths(nw1)
+	if len(ps) != 1 || ps[0].PeerAddr != nil || !ps[0].CreatedAt.Equal(ts) {
+		t.Fatalf("Want: 1 local path, Got: %v", ps)
+	}
+	var seq *bgptype.AsSequence
+	for _, pa := range ps[0].GetPathAttributes() {
+		if s, ok := pa.(*bgptype.AsSequence); ok {
+			seq = s
+		}
+	}
+	if want := "[64514 65001]"; seq == nil || fmt.Sprint([]bgptype.AutonomousSystemNumber(*seq)) != want {
+		t.Errorf("Want: %v, Got: %v", want, seq)
+	}
+	if ps := s.LocRib.Paths(nw2); len(ps) != 0 {
+		t.Errorf("Want: no path, Got: %v", ps)
+	}
+}
+
+// BGP4MPで受信したUpdateMessageを、元の間隔を速さで割った時間だけ待って
+// 受信したPeerの経路として再生することを確認するテスト
+func TestReplay(t *testing.T) {
+	s := newServer(t)
+	pas := []byte{
+		0x40, 1, 1, 0,
+		0x40, 2, 4, 2, 1, 0xfc, 0x01,
+		0x40, 3, 4, 10, 200, 100, 3,
+	}
+	ts := time.Unix(1700000000, 0)
+	var buf bytes.Buffer
+	for i, m := range []struct {
+		local bool
+		b     []byte
+	}{
+		{false, updateBytes(nil, pas, []byte{24, 10, 100, 1, 24, 10, 100, 2})},
+		// 自身が送信したMessageは再生しない
+		{true, updateBytes(nil, pas, []byte{24, 10, 100, 3})},
+		{false, updateBytes([]byte{24, 10, 100, 1}, nil, nil)},
+	} {
+		r := &BGP4MPMessage{
+			Timestamp: ts.Add(time.Duration(i) * 10 * time.Second), PeerAS: 64513, LocalAS: 64512,
+			PeerIP: net.IP{10, 200, 100, 3}, LocalIP: net.IP{10, 200, 100, 2},
+			Local: m.local, Message: m.b,
+		}
+		buf.Write(r.ToBytes())
+	}
+	r, err := NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Unix(0, 0)
+	sleeps := []time.Duration{}
+	rp := &Replayer{
+		Server: s,
+		Speed:  4,
+		now:    func() time.Time { return now },
+		sleep: func(ctx context.Context, d time.Duration) error {
+			sleeps = append(sleeps, d)
+			now = now.Add(d)
+			return nil
+		},
+	}
+	stats, err := rp.Run(context.Background(), r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (ReplayStats{Updates: 2, Announced: 2, Withdrawn: 1}); stats != want {
+		t.Errorf("Want: %v, Got: %v", want, stats)
+	}
+	if want := []time.Duration{5 * time.Second}; !reflect.DeepEqual(want, sleeps) {
+		t.Errorf("Want: %v, Got: %v", want, sleeps)
+	}
+	for p, want := range map[string]int{"10.100.1.0/24": 0, "10.100.2.0/24": 1, "10.100.3.0/24": 0} {
+		_, nw, _ := net.ParseCIDR(p)
+		if got := len(s.LocRib.Paths(nw)); got != want {
+			t.Errorf("%v Want: %v, Got: %v", p, want, got)
+		}
+	}
+	// 再生した経路は、MRTのPeerから受信した経路としてインストールされる
+	_, nw, _ := net.ParseCIDR("10.100.2.0/24")
+	if ps := s.LocRib.Paths(nw); len(ps) != 1 || !ps[0].PeerAddr.Equal(net.IP{10, 200, 100, 3}) {
+		t.Errorf("Want: path from %v, Got: %v", net.IP{10, 200, 100, 3}, ps)
+	}
+}
@@ -0,0 +1,429 @@
+package mrt
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"time"
+
+	"github.com/SotaUeda/gobgp/packets"
+)
+
+// MRTのレコードを読み込む
+//
+// gzip, bzip2で圧縮されたファイルは、先頭のバイト列から判別して展開する。
+// BGP4MPのMessageは、同じセッションで送受信したOpenMessageを覚えておき、
+// 4byteのAS番号のネゴシエーションに応じて変換する。
+type Reader struct {
+	r      *bufio.Reader
+	closer io.Closer
+	// セッションごとに、OpenMessageで4byteのAS番号を広告したか
+	sessions map[sessionKey]*session
+}
+
+type sessionKey struct {
+	peer, local string
+}
+
+type session struct {
+	// 自身、Peerが送信したOpenMessageの4-octet AS Number Capability
+	localAS4, peerAS4 *bool
+}
+
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(3)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	var closer io.Closer
+	switch {
+	case len(magic) >= 2 && magic[0] == 0x1f && magic[1] == 0x8b:
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		br, closer = bufio.NewReader(gz), gz
+	case bytes.Equal(magic, []byte("BZh")):
+		br = bufio.NewReader(bzip2.NewReader(br))
+	}
+	return &Reader{r: br, closer: closer, sessions: make(map[sessionKey]*session)}, nil
+}
+
+// ファイルを開き、レコードを読み込むReaderを作成する
+func Open(name string) (*Reader, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	r, err := NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if r.closer != nil {
+		r.closer = multiCloser{r.closer, f}
+	} else {
+		r.closer = f
+	}
+	return r, nil
+}
+
+type multiCloser []io.Closer
+
+func (cs multiCloser) Close() error {
+	var err error
+	for _, c := range cs {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// 次のレコードを読み込む
+// すべてのレコードを読み込んだ場合はio.EOFを返す
+func (r *Reader) Next() (Record, error) {
+	rec, err := ReadRecord(r.r)
+	if err != nil {
+		return nil, err
+	}
+	if m, ok := rec.(*BGP4MPMessage); ok {
+		r.observe(m)
+	}
+	return rec, nil
+}
+
+func (r *Reader) Close() error {
+	if r.closer == nil {
+		return nil
+	}
+	return r.closer.Close()
+}
+
+// OpenMessageの4-octet AS Number Capabilityを覚えておく
+func (r *Reader) observe(m *BGP4MPMessage) {
+	if len(m.Message) <= packets.HEADER_LENGTH || packets.MessageType(m.Message[18]) != packets.Open {
+		return
+	}
+	msg, err := packets.BytesToMessage(m.Message)
+	if err != nil {
+		return
+	}
+	as4 := false
+	caps, err := packets.OptionalParametersToCapabilities(msg.(*packets.OpenMessage).OptionalParameters)
+	if err != nil {
+		return
+	}
+	for _, c := range caps {
+		if c.Code() == packets.CapFourOctetAS {
+			as4 = true
+		}
+	}
+	k := sessionKey{m.PeerIP.String(), m.LocalIP.String()}
+	s, ok := r.sessions[k]
+	if !ok {
+		s = &session{}
+		r.sessions[k] = s
+	}
+	if m.Local {
+		s.localAS4 = &as4
+	} else {
+		s.peerAS4 = &as4
+	}
+}
+
+// BGP4MPのMessageを変換する
+// 両方向のOpenMessageを読み込んでいる場合は、どちらも4-octet AS Number Capabilityを
+// 広告したときに、AS_PATHのAS番号を4byteとして変換する。
+// OpenMessageを読み込んでいない場合は、AS_PATHの値から推定する。
+func (r *Reader) Message(m *BGP4MPMessage) (packets.Message, error) {
+	var as4 bool
+	s, ok := r.sessions[sessionKey{m.PeerIP.String(), m.LocalIP.String()}]
+	if ok && s.localAS4 != nil && s.peerAS4 != nil {
+		as4 = *s.localAS4 && *s.peerAS4
+	} else {
+		as4 = isAS4Update(m.Message)
+	}
+	return m.DecodeMessage(as4)
+}
+
+// Messageを変換する
+// as4がtrueの場合は、UpdateMessageのAS_PATHのAS番号を4byteとして変換する
+func (m *BGP4MPMessage) DecodeMessage(as4 bool) (packets.Message, error) {
+	if len(m.Message) < packets.HEADER_LENGTH {
+		return nil, fmt.Errorf("bgp message is too short: %d bytes", len(m.Message))
+	}
+	return packets.BytesToMessageWithOptions(m.Message, packets.DecodeOptions{AddPath: m.AddPath, AS4: as4})
+}
+
+// UpdateMessageのAS_PATHが、2byteのAS番号では解釈できず、
+// 4byteのAS番号で解釈できる場合にtrueを返す
+func isAS4Update(b []byte) bool {
+	if len(b) < 23 || packets.MessageType(b[18]) != packets.Update {
+		return false
+	}
+	wrLen := int(binary.BigEndian.Uint16(b[19:21]))
+	if len(b) < 23+wrLen {
+		return false
+	}
+	paLen := int(binary.BigEndian.Uint16(b[21+wrLen : 23+wrLen]))
+	pas := b[23+wrLen:]
+	if len(pas) < paLen {
+		return false
+	}
+	pas = pas[:paLen]
+	for i := 0; i+3 <= len(pas); {
+		flags, typ := pas[i], pas[i+1]
+		var l, start int
+		if flags&0x10 != 0 {
+			if i+4 > len(pas) {
+				return false
+			}
+			l, start = int(binary.BigEndian.Uint16(pas[i+2:i+4])), i+4
+		} else {
+			l, start = int(pas[i+2]), i+3
+		}
+		if start+l > len(pas) {
+			return false
+		}
+		if typ == 2 {
+			v := pas[start : start+l]
+			return !asPathTiles(v, 2) && asPathTiles(v, 4)
+		}
+		i = start + l
+	}
+	return false
+}
+
+// AS_PATHの値が、size byteのAS番号のセグメントでちょうど区切れるか
+func asPathTiles(b []byte, size int) bool {
+	for i := 0; i < len(b); {
+		if i+2 > len(b) || b[i] < 1 || b[i] > 4 {
+			return false
+		}
+		i += 2 + int(b[i+1])*size
+		if i > len(b) {
+			return false
+		}
+	}
+	return true
+}
+
+// レコードを1つ読み込む
+// レコードの途中で終わっている場合はio.ErrUnexpectedEOFを返す
+func ReadRecord(r io.Reader) (Record, error) {
+	h := make([]byte, HEADER_LENGTH)
+	if _, err := io.ReadFull(r, h); err != nil {
+		return nil, err
+	}
+	b := make([]byte, HEADER_LENGTH+int(binary.BigEndian.Uint32(h[8:12])))
+	copy(b, h)
+	if _, err := io.ReadFull(r, b[HEADER_LENGTH:]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return BytesToRecord(b)
+}
+
+// Common Headerを含む1つのレコードを変換する
+// 対応していないTypeとSubtypeのレコードはUnknownRecordにする
+func BytesToRecord(b []byte) (Record, error) {
+	if len(b) < HEADER_LENGTH {
+		return nil, fmt.Errorf("mrt record is too short: %d bytes", len(b))
+	}
+	ts := time.Unix(int64(binary.BigEndian.Uint32(b[0:4])), 0)
+	t := RecordType(binary.BigEndian.Uint16(b[4:6]))
+	subtype := binary.BigEndian.Uint16(b[6:8])
+	l := int(binary.BigEndian.Uint32(b[8:12]))
+	if len(b) != HEADER_LENGTH+l {
+		return nil, fmt.Errorf("mrt record length is %d, but body is %d bytes", l, len(b)-HEADER_LENGTH)
+	}
+	body := b[HEADER_LENGTH:]
+	d := &decoder{b: body}
+	var rec Record
+	switch {
+	case t == TABLE_DUMP_V2 && subtype == PEER_INDEX_TABLE:
+		rec = d.peerIndexTable(ts)
+	case t == TABLE_DUMP_V2 && (subtype == RIB_IPV4_UNICAST || subtype == RIB_IPV4_UNICAST_ADDPATH):
+		rec = d.rib(ts, net.IPv4len, subtype == RIB_IPV4_UNICAST_ADDPATH)
+	case t == TABLE_DUMP_V2 && (subtype == RIB_IPV6_UNICAST || subtype == RIB_IPV6_UNICAST_ADDPATH):
+		rec = d.rib(ts, net.IPv6len, subtype == RIB_IPV6_UNICAST_ADDPATH)
+	case t == BGP4MP || t == BGP4MP_ET:
+		if t == BGP4MP_ET {
+			ts = ts.Add(time.Duration(d.uint32()) * time.Microsecond)
+		}
+		rec = d.bgp4mp(ts, subtype)
+	}
+	if d.err != nil {
+		return nil, fmt.Errorf("cannot decode mrt record type %d subtype %d: %w", t, subtype, d.err)
+	}
+	if rec == nil {
+		return &UnknownRecord{Timestamp: ts, Type: t, Subtype: subtype, Body: body}, nil
+	}
+	return rec, nil
+}
+
+// レコードの本体を先頭から読み込む
+// 足りない場合はerrを設定し、以降はゼロ値を返す
+type decoder struct {
+	b   []byte
+	err error
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil {
+		return make([]byte, n)
+	}
+	if len(d.b) < n {
+		d.err = io.ErrUnexpectedEOF
+		return make([]byte, n)
+	}
+	v := d.b[:n]
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *decoder) uint8() uint8 {
+	return d.next(1)[0]
+}
+
+func (d *decoder) uint16() uint16 {
+	return binary.BigEndian.Uint16(d.next(2))
+}
+
+func (d *decoder) uint32() uint32 {
+	return binary.BigEndian.Uint32(d.next(4))
+}
+
+func (d *decoder) ip(n int) net.IP {
+	return net.IP(bytes.Clone(d.next(n)))
+}
+
+func (d *decoder) peerIndexTable(ts time.Time) *PeerIndexTable {
+	t := &PeerIndexTable{Timestamp: ts}
+	t.CollectorBGPID = d.ip(4)
+	t.ViewName = string(d.next(int(d.uint16())))
+	n := int(d.uint16())
+	for i := 0; i < n && d.err == nil; i++ {
+		pt := d.uint8()
+		e := PeerEntry{BGPID: d.ip(4)}
+		if pt&PEER_TYPE_IPV6 != 0 {
+			e.Address = d.ip(net.IPv6len)
+		} else {
+			e.Address = d.ip(net.IPv4len)
+		}
+		if pt&PEER_TYPE_AS4 != 0 {
+			e.AS = d.uint32()
+		} else {
+			e.AS = uint32(d.uint16())
+		}
+		t.Peers = append(t.Peers, e)
+	}
+	return t
+}
+
+func (d *decoder) rib(ts time.Time, ipLen int, addPath bool) *RIB {
+	r := &RIB{Timestamp: ts, AddPath: addPath}
+	r.Sequence = d.uint32()
+	ones := int(d.uint8())
+	if ones > ipLen*8 {
+		d.err = fmt.Errorf("invalid prefix length %d", ones)
+		return r
+	}
+	ip := make(net.IP, ipLen)
+	copy(ip, d.next((ones+7)/8))
+	r.Prefix = &net.IPNet{IP: ip, Mask: net.CIDRMask(ones, ipLen*8)}
+	n := int(d.uint16())
+	for i := 0; i < n && d.err == nil; i++ {
+		e := RIBEntry{PeerIndex: d.uint16()}
+		e.OriginatedTime = time.Unix(int64(d.uint32()), 0)
+		if addPath {
+			e.PathID = d.uint32()
+		}
+		e.Attributes = bytes.Clone(d.next(int(d.uint16())))
+		r.Entries = append(r.Entries, e)
+	}
+	return r
+}
+
+// 対応していないSubtypeの場合はnilを返す
+func (d *decoder) bgp4mp(ts time.Time, subtype uint16) Record {
+	var as2, local, addPath, stateChange bool
+	switch subtype {
+	case BGP4MP_STATE_CHANGE:
+		as2, stateChange = true, true
+	case BGP4MP_STATE_CHANGE_AS4:
+		stateChange = true
+	case BGP4MP_MESSAGE:
+		as2 = true
+	case BGP4MP_MESSAGE_AS4:
+	case BGP4MP_MESSAGE_LOCAL:
+		as2, local = true, true
+	case BGP4MP_MESSAGE_AS4_LOCAL:
+		local = true
+	case BGP4MP_MESSAGE_ADDPATH:
+		as2, addPath = true, true
+	case BGP4MP_MESSAGE_AS4_ADDPATH:
+		addPath = true
+	case BGP4MP_MESSAGE_LOCAL_ADDPATH:
+		as2, local, addPath = true, true, true
+	case BGP4MP_MESSAGE_AS4_LOCAL_ADDPATH:
+		local, addPath = true, true
+	default:
+		return nil
+	}
+	var peerAS, localAS uint32
+	if as2 {
+		peerAS, localAS = uint32(d.uint16()), uint32(d.uint16())
+	} else {
+		peerAS, localAS = d.uint32(), d.uint32()
+	}
+	ifIndex := d.uint16()
+	ipLen := net.IPv4len
+	switch afi := d.uint16(); afi {
+	case AFI_IPV4:
+	case AFI_IPV6:
+		ipLen = net.IPv6len
+	default:
+		if d.err == nil {
+			d.err = fmt.Errorf("unknown address family %d", afi)
+		}
+	}
+	peerIP, localIP := d.ip(ipLen), d.ip(ipLen)
+	if stateChange {
+		return &BGP4MPStateChange{
+			Timestamp:      ts,
+			PeerAS:         peerAS,
+			LocalAS:        localAS,
+			InterfaceIndex: ifIndex,
+			PeerIP:         peerIP,
+			LocalIP:        localIP,
+			OldState:       d.uint16(),
+			NewState:       d.uint16(),
+			AS2:            as2,
+		}
+	}
+	m := &BGP4MPMessage{
+		Timestamp:      ts,
+		PeerAS:         peerAS,
+		LocalAS:        localAS,
+		InterfaceIndex: ifIndex,
+		PeerIP:         peerIP,
+		LocalIP:        localIP,
+		Local:          local,
+		AddPath:        addPath,
+		AS2:            as2,
+		Message:        bytes.Clone(d.b),
+	}
+	d.b = nil
+	return m
+}
@@ -0,0 +1,335 @@
+package mrt
+
+import (
+	"encoding/binary"
+	"net"
+	"time"
+)
+
+// MRT形式のレコード (RFC6396)
+//
+// すべてのレコードは次のCommon Headerから始まる
+// Timestamp: 4byte: 秒
+// Type: 2byte
+// Subtype: 2byte
+// Length: 4byte: Common Headerを含まないMessageのバイト数
+const HEADER_LENGTH = 12
+
+type RecordType uint16
+
+const (
+	TABLE_DUMP_V2 RecordType = 13
+	BGP4MP        RecordType = 16
+	// BGP4MPのCommon Headerの後に、4byteのマイクロ秒が続く
+	BGP4MP_ET RecordType = 17
+)
+
+// TABLE_DUMP_V2のSubtype
+const (
+	PEER_INDEX_TABLE uint16 = 1
+	RIB_IPV4_UNICAST uint16 = 2
+	RIB_IPV6_UNICAST uint16 = 4
+	// RFC8050 各RIB EntryにPath Identifierを含む
+	RIB_IPV4_UNICAST_ADDPATH uint16 = 8
+	RIB_IPV6_UNICAST_ADDPATH uint16 = 10
+)
+
+// BGP4MPのSubtype
+// AS4はPeer ASとLocal ASを4byteで表す。
+// LOCALは自身が送信したMessage、それ以外はPeerから受信したMessageを表す。
+const (
+	BGP4MP_STATE_CHANGE              uint16 = 0
+	BGP4MP_MESSAGE                   uint16 = 1
+	BGP4MP_MESSAGE_AS4               uint16 = 4
+	BGP4MP_STATE_CHANGE_AS4          uint16 = 5
+	BGP4MP_MESSAGE_LOCAL             uint16 = 6
+	BGP4MP_MESSAGE_AS4_LOCAL         uint16 = 7
+	BGP4MP_MESSAGE_ADDPATH           uint16 = 8
+	BGP4MP_MESSAGE_AS4_ADDPATH       uint16 = 9
+	BGP4MP_MESSAGE_LOCAL_ADDPATH     uint16 = 10
+	BGP4MP_MESSAGE_AS4_LOCAL_ADDPATH uint16 = 11
+)
+
+// PEER_INDEX_TABLEのPeer Type
+const (
+	// Peer IP AddressがIPv6
+	PEER_TYPE_IPV6 uint8 = 0x01
+	// Peer ASが4byte
+	PEER_TYPE_AS4 uint8 = 0x02
+)
+
+// BGP4MPのAddress Family
+const (
+	AFI_IPV4 uint16 = 1
+	AFI_IPV6 uint16 = 2
+)
+
+type Record interface {
+	ToBytes() []byte
+}
+
+// TABLE_DUMP_V2の最初に書き込み、RIBのPeer Indexが指すPeerを表す
+//
+// Collector BGP ID: 4byte
+// View Name Length: 2byte
+// View Name: 可変長
+// Peer Count: 2byte
+// Peer Entries: Peer Type(1byte), Peer BGP ID(4byte),
+// Peer IP Address(4byte or 16byte), Peer AS(2byte or 4byte)の繰り返し
+type PeerIndexTable struct {
+	Timestamp      time.Time
+	CollectorBGPID net.IP
+	ViewName       string
+	Peers          []PeerEntry
+}
+
+type PeerEntry struct {
+	BGPID   net.IP
+	Address net.IP
+	AS      uint32
+}
+
+// TABLE_DUMP_V2の1つのプレフィックスの経路
+//
+// Sequence Number: 4byte
+// Prefix Length: 1byte
+// Prefix: 可変長: Prefix Lengthを表すのに必要なバイト数
+// Entry Count: 2byte
+// RIB Entries: Peer Index(2byte), Originated Time(4byte),
+// Path Identifier(4byte, ADD-PATHの場合のみ), Attribute Length(2byte), BGP Attributesの繰り返し
+type RIB struct {
+	Timestamp time.Time
+	Sequence  uint32
+	Prefix    *net.IPNet
+	// RFC8050 各EntryがPath Identifierを持つ
+	AddPath bool
+	Entries []RIBEntry
+}
+
+type RIBEntry struct {
+	// PeerIndexTableのPeersの添字
+	PeerIndex      uint16
+	OriginatedTime time.Time
+	PathID         uint32
+	// AS番号を4byteでエンコードしたPathAttribute
+	Attributes []byte
+}
+
+// 送受信したBGPのMessage
+//
+// Peer AS: 4byte
+// Local AS: 4byte
+// Interface Index: 2byte
+// Address Family: 2byte
+// Peer IP Address: 4byte or 16byte
+// Local IP Address: 4byte or 16byte
+// BGP Message: 可変長: ヘッダーを含むMessage全体
+type BGP4MPMessage struct {
+	Timestamp      time.Time
+	PeerAS         uint32
+	LocalAS        uint32
+	InterfaceIndex uint16
+	PeerIP         net.IP
+	LocalIP        net.IP
+	// 自身が送信したMessageの場合はtrue
+	Local bool
+	// RFC8050 UpdateMessageの経路にPath Identifierが付いている
+	AddPath bool
+	// Peer AS, Local ASを2byteで表すSubtype
+	AS2     bool
+	Message []byte
+}
+
+// PeerとのセッションのFSMの状態の変化
+//
+// Peer AS: 2byte or 4byte
+// Local AS: 2byte or 4byte
+// Interface Index: 2byte
+// Address Family: 2byte
+// Peer IP Address: 4byte or 16byte
+// Local IP Address: 4byte or 16byte
+// Old State: 2byte
+// New State: 2byte: 1(Idle)から6(Established)の値
+type BGP4MPStateChange struct {
+	Timestamp      time.Time
+	PeerAS         uint32
+	LocalAS        uint32
+	InterfaceIndex uint16
+	PeerIP         net.IP
+	LocalIP        net.IP
+	OldState       uint16
+	NewState       uint16
+	// Peer AS, Local ASを2byteで表すSubtype
+	AS2 bool
+}
+
+// 対応していないTypeかSubtypeのレコード
+type UnknownRecord struct {
+	Timestamp time.Time
+	Type      RecordType
+	Subtype   uint16
+	Body      []byte
+}
+
+func header(ts time.Time, t RecordType, subtype uint16, length int) []byte {
+	b := make([]byte, HEADER_LENGTH, HEADER_LENGTH+length)
+	binary.BigEndian.PutUint32(b[0:4], uint32(ts.Unix()))
+	binary.BigEndian.PutUint16(b[4:6], uint16(t))
+	binary.BigEndian.PutUint16(b[6:8], subtype)
+	binary.BigEndian.PutUint32(b[8:12], uint32(length))
+	return b
+}
+
+// IPv4の場合は4byte、IPv6の場合は16byteのアドレス
+func addressBytes(ip net.IP) []byte {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4
+	}
+	if ip16 := ip.To16(); ip16 != nil {
+		return ip16
+	}
+	return net.IPv4zero.To4()
+}
+
+func to16(ip net.IP) []byte {
+	if ip16 := ip.To16(); ip16 != nil {
+		return ip16
+	}
+	return net.IPv6zero
+}
+
+func isIPv6(ip net.IP) bool {
+	return ip.To4() == nil && ip.To16() != nil
+}
+
+func (t *PeerIndexTable) ToBytes() []byte {
+	body := make([]byte, 0)
+	id := make([]byte, 4)
+	copy(id, t.CollectorBGPID.To4())
+	body = append(body, id...)
+	body = binary.BigEndian.AppendUint16(body, uint16(len(t.ViewName)))
+	body = append(body, t.ViewName...)
+	body = binary.BigEndian.AppendUint16(body, uint16(len(t.Peers)))
+	for _, p := range t.Peers {
+		pt := PEER_TYPE_AS4
+		if isIPv6(p.Address) {
+			pt |= PEER_TYPE_IPV6
+		}
+		body = append(body, pt)
+		id := make([]byte, 4)
+		copy(id, p.BGPID.To4())
+		body = append(body, id...)
+		body = append(body, addressBytes(p.Address)...)
+		body = binary.BigEndian.AppendUint32(body, p.AS)
+	}
+	b := header(t.Timestamp, TABLE_DUMP_V2, PEER_INDEX_TABLE, len(body))
+	return append(b, body...)
+}
+
+// プレフィックスのアドレスファミリーとADD-PATHに応じたSubtype
+func (r *RIB) Subtype() uint16 {
+	switch {
+	case isIPv6(r.Prefix.IP) && r.AddPath:
+		return RIB_IPV6_UNICAST_ADDPATH
+	case isIPv6(r.Prefix.IP):
+		return RIB_IPV6_UNICAST
+	case r.AddPath:
+		return RIB_IPV4_UNICAST_ADDPATH
+	default:
+		return RIB_IPV4_UNICAST
+	}
+}
+
+func (r *RIB) ToBytes() []byte {
+	body := binary.BigEndian.AppendUint32(nil, r.Sequence)
+	ones, _ := r.Prefix.Mask.Size()
+	body = append(body, byte(ones))
+	body = append(body, addressBytes(r.Prefix.IP)[:(ones+7)/8]...)
+	body = binary.BigEndian.AppendUint16(body, uint16(len(r.Entries)))
+	for _, e := range r.Entries {
+		body = binary.BigEndian.AppendUint16(body, e.PeerIndex)
+		body = binary.BigEndian.AppendUint32(body, uint32(e.OriginatedTime.Unix()))
+		if r.AddPath {
+			body = binary.BigEndian.AppendUint32(body, e.PathID)
+		}
+		body = binary.BigEndian.AppendUint16(body, uint16(len(e.Attributes)))
+		body = append(body, e.Attributes...)
+	}
+	b := header(r.Timestamp, TABLE_DUMP_V2, r.Subtype(), len(body))
+	return append(b, body...)
+}
+
+// 送受信の向きとADD-PATHに応じたSubtype
+func (m *BGP4MPMessage) Subtype() uint16 {
+	switch {
+	case m.AS2 && m.Local && m.AddPath:
+		return BGP4MP_MESSAGE_LOCAL_ADDPATH
+	case m.AS2 && m.Local:
+		return BGP4MP_MESSAGE_LOCAL
+	case m.AS2 && m.AddPath:
+		return BGP4MP_MESSAGE_ADDPATH
+	case m.AS2:
+		return BGP4MP_MESSAGE
+	case m.Local && m.AddPath:
+		return BGP4MP_MESSAGE_AS4_LOCAL_ADDPATH
+	case m.Local:
+		return BGP4MP_MESSAGE_AS4_LOCAL
+	case m.AddPath:
+		return BGP4MP_MESSAGE_AS4_ADDPATH
+	default:
+		return BGP4MP_MESSAGE_AS4
+	}
+}
+
+// マイクロ秒の時刻はBGP4MP_ETではなく、秒に切り捨ててBGP4MPで書き込む
+func (m *BGP4MPMessage) ToBytes() []byte {
+	body := peerHeaderBytes(m.PeerAS, m.LocalAS, m.InterfaceIndex, m.PeerIP, m.LocalIP, m.AS2)
+	body = append(body, m.Message...)
+	b := header(m.Timestamp, BGP4MP, m.Subtype(), len(body))
+	return append(b, body...)
+}
+
+func (c *BGP4MPStateChange) Subtype() uint16 {
+	if c.AS2 {
+		return BGP4MP_STATE_CHANGE
+	}
+	return BGP4MP_STATE_CHANGE_AS4
+}
+
+func (c *BGP4MPStateChange) ToBytes() []byte {
+	body := peerHeaderBytes(c.PeerAS, c.LocalAS, c.InterfaceIndex, c.PeerIP, c.LocalIP, c.AS2)
+	body = binary.BigEndian.AppendUint16(body, c.OldState)
+	body = binary.BigEndian.AppendUint16(body, c.NewState)
+	b := header(c.Timestamp, BGP4MP, c.Subtype(), len(body))
+	return append(b, body...)
+}
+
+// BGP4MPのMessage, State Changeに共通するPeer AS からLocal IP Addressまで
+func peerHeaderBytes(peerAS, localAS uint32, ifIndex uint16, peerIP, localIP net.IP, as2 bool) []byte {
+	var body []byte
+	if as2 {
+		body = binary.BigEndian.AppendUint16(body, uint16(peerAS))
+		body = binary.BigEndian.AppendUint16(body, uint16(localAS))
+	} else {
+		body = binary.BigEndian.AppendUint32(body, peerAS)
+		body = binary.BigEndian.AppendUint32(body, localAS)
+	}
+	body = binary.BigEndian.AppendUint16(body, ifIndex)
+	// Peer IP AddressとLocal IP Addressは同じアドレスファミリーでエンコードする
+	pb, lb := addressBytes(peerIP), addressBytes(localIP)
+	if len(pb) != len(lb) {
+		pb, lb = to16(peerIP), to16(localIP)
+	}
+	if len(pb) == net.IPv6len {
+		body = binary.BigEndian.AppendUint16(body, AFI_IPV6)
+	} else {
+		body = binary.BigEndian.AppendUint16(body, AFI_IPV4)
+	}
+	body = append(body, pb...)
+	return append(body, lb...)
+}
+
+func (r *UnknownRecord) ToBytes() []byte {
+	b := header(r.Timestamp, r.Type, r.Subtype, len(r.Body))
+	return append(b, r.Body...)
+}
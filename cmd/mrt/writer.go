@@ -0,0 +1,189 @@
+package mrt
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MRTのレコードをファイルに書き込む
+//
+// 書き込むファイルの名前は、Pathの%Y, %m, %d, %H, %M, %Sを
+// ファイルを作成した時刻で展開して決める。
+// 同じ名前のファイルがすでにある場合は上書きせず、".1", ".2"のように番号を付ける。
+// RotateIntervalが経過するか、RotateSizeを超えて書き込んだ場合は、
+// 次のレコードから新しいファイルに書き込む。
+// 複数のgoroutineから呼び出してもよい。
+type Writer struct {
+	Path string
+	// ファイルを切り替える間隔。0の場合は時間では切り替えない
+	RotateInterval time.Duration
+	// ファイルを切り替える、圧縮する前のバイト数。0の場合は大きさでは切り替えない
+	RotateSize int64
+	// gzipで圧縮する。ファイル名には".gz"を付ける
+	Gzip bool
+
+	mu     sync.Mutex
+	now    func() time.Time
+	file   *os.File
+	gz     *gzip.Writer
+	buf    *bufio.Writer
+	name   string
+	size   int64
+	opened time.Time
+	// Closeした後はファイルを開き直さない
+	closed bool
+}
+
+func NewWriter(path string) *Writer {
+	return &Writer{Path: path, now: time.Now}
+}
+
+// レコードを書き込む
+// 書き込んだ内容はバッファに溜め、Flush, Rotate, Closeでファイルに書き出す
+// Closeした後はos.ErrClosedを返す
+func (w *Writer) Write(rs ...Record) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return os.ErrClosed
+	}
+	if w.file != nil && w.expired() {
+		if err := w.close(); err != nil {
+			return err
+		}
+	}
+	if w.file == nil {
+		if err := w.open(); err != nil {
+			return err
+		}
+	}
+	for _, r := range rs {
+		n, err := w.buf.Write(r.ToBytes())
+		w.size += int64(n)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 今のファイルを閉じ、次のレコードから新しいファイルに書き込む
+func (w *Writer) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.close()
+}
+
+// バッファに溜めたレコードをファイルに書き出す
+func (w *Writer) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	if err := w.buf.Flush(); err != nil {
+		return err
+	}
+	if w.gz != nil {
+		return w.gz.Flush()
+	}
+	return nil
+}
+
+// ファイルを閉じ、以降は書き込まない
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+	return w.close()
+}
+
+// 書き込み中のファイルの名前。ファイルを開いていない場合は空
+func (w *Writer) Name() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.name
+}
+
+// w.muをロックした状態で呼び出す
+func (w *Writer) expired() bool {
+	if w.RotateSize > 0 && w.size >= w.RotateSize {
+		return true
+	}
+	return w.RotateInterval > 0 && w.now().Sub(w.opened) >= w.RotateInterval
+}
+
+// w.muをロックした状態で呼び出す
+func (w *Writer) open() error {
+	now := w.now()
+	base := expandPath(w.Path, now)
+	if err := os.MkdirAll(filepath.Dir(base), 0o755); err != nil {
+		return err
+	}
+	var f *os.File
+	var name string
+	for i := 0; ; i++ {
+		name = base
+		if i > 0 {
+			name = fmt.Sprintf("%s.%d", base, i)
+		}
+		if w.Gzip && !strings.HasSuffix(name, ".gz") {
+			name += ".gz"
+		}
+		var err error
+		f, err = os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return err
+		}
+	}
+	var out io.Writer = f
+	w.gz = nil
+	if w.Gzip {
+		w.gz = gzip.NewWriter(f)
+		out = w.gz
+	}
+	w.file = f
+	w.buf = bufio.NewWriter(out)
+	w.name = name
+	w.size = 0
+	w.opened = now
+	log.Info("mrt file is opened", "file", name)
+	return nil
+}
+
+// w.muをロックした状態で呼び出す
+func (w *Writer) close() error {
+	if w.file == nil {
+		return nil
+	}
+	err := w.buf.Flush()
+	if w.gz != nil {
+		err = errors.Join(err, w.gz.Close())
+	}
+	err = errors.Join(err, w.file.Close())
+	w.file, w.gz, w.buf, w.name = nil, nil, nil, ""
+	return err
+}
+
+// パスに含まれる日時の書式を展開する
+func expandPath(path string, t time.Time) string {
+	return strings.NewReplacer(
+		"%Y", t.Format("2006"),
+		"%m", t.Format("01"),
+		"%d", t.Format("02"),
+		"%H", t.Format("15"),
+		"%M", t.Format("04"),
+		"%S", t.Format("05"),
+	).Replace(path)
+}
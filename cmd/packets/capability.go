@@ -0,0 +1,224 @@
+package packets
+
+import (
+	"fmt"
+)
+
+// OpenMessageのOptional Parameterのフォーマット
+// Parameter Type: 1byte: Capabilities(2)のみ扱う
+// Parameter Length: 1byte: Parameter Valueのオクテット数
+// Parameter Value: 可変長
+//
+// Capabilities OptionalParameterのParameter Valueには
+// 次のCapabilityが1つ以上並ぶ (RFC5492)
+// Capability Code: 1byte
+// Capability Length: 1byte
+// Capability Value: 可変長
+const CAPABILITIES_OPTIONAL_PARAMETER = 2
+
+type CapabilityCode uint8
+
+const (
+	CapMultiprotocol CapabilityCode = 1
+	CapRouteRefresh  CapabilityCode = 2
+	CapFourOctetAS   CapabilityCode = 65
+	CapAddPath       CapabilityCode = 69
+)
+
+// Address Family Identifier, Subsequent Address Family Identifier
+const (
+	AFI_IPV4     uint16 = 1
+	AFI_IPV6     uint16 = 2
+	SAFI_UNICAST uint8  = 1
+)
+
+// AFIとSAFIの組
+type Family struct {
+	AFI  uint16
+	SAFI uint8
+}
+
+var IPv4Unicast = Family{AFI_IPV4, SAFI_UNICAST}
+
+func (f Family) Show() string {
+	switch f {
+	case IPv4Unicast:
+		return "ipv4-unicast"
+	case Family{AFI_IPV6, SAFI_UNICAST}:
+		return "ipv6-unicast"
+	default:
+		return fmt.Sprintf("afi=%d,safi=%d", f.AFI, f.SAFI)
+	}
+}
+
+type Capability interface {
+	Code() CapabilityCode
+	// Capability Code, Capability Lengthを含むバイト列
+	ToBytes() []byte
+}
+
+// ADD-PATH Capability (RFC7911 4.)
+// Familyごとに、Path Identifier付きの経路を受信できるか、送信したいかを表す
+type AddPathCapability struct {
+	Families []AddPathFamily
+}
+
+type AddPathFamily struct {
+	Family Family
+	Mode   AddPathMode
+}
+
+type AddPathMode uint8
+
+const (
+	AddPathReceive AddPathMode = 1
+	AddPathSend    AddPathMode = 2
+	AddPathBoth    AddPathMode = 3
+)
+
+func (m AddPathMode) CanReceive() bool {
+	return m&AddPathReceive != 0
+}
+
+func (m AddPathMode) CanSend() bool {
+	return m&AddPathSend != 0
+}
+
+func (c *AddPathCapability) Code() CapabilityCode {
+	return CapAddPath
+}
+
+func (c *AddPathCapability) ToBytes() []byte {
+	b := []byte{byte(CapAddPath), byte(4 * len(c.Families))}
+	for _, f := range c.Families {
+		b = append(b, byte(f.Family.AFI>>8), byte(f.Family.AFI), f.Family.SAFI, byte(f.Mode))
+	}
+	return b
+}
+
+// Familyに対するModeを返す。含まれていない場合は0を返す
+func (c *AddPathCapability) Mode(f Family) AddPathMode {
+	for _, af := range c.Families {
+		if af.Family == f {
+			return af.Mode
+		}
+	}
+	return 0
+}
+
+// Route Refresh Capability (RFC2918)
+type RouteRefreshCapability struct{}
+
+func (c *RouteRefreshCapability) Code() CapabilityCode {
+	return CapRouteRefresh
+}
+
+func (c *RouteRefreshCapability) ToBytes() []byte {
+	return []byte{byte(CapRouteRefresh), 0}
+}
+
+// 4-octet AS Number Capability (RFC6793)
+type FourOctetASCapability struct {
+	AS uint32
+}
+
+func (c *FourOctetASCapability) Code() CapabilityCode {
+	return CapFourOctetAS
+}
+
+func (c *FourOctetASCapability) ToBytes() []byte {
+	return []byte{byte(CapFourOctetAS), 4, byte(c.AS >> 24), byte(c.AS >> 16), byte(c.AS >> 8), byte(c.AS)}
+}
+
+// 対応していないCapability
+type UnknownCapability struct {
+	code  CapabilityCode
+	Value []byte
+}
+
+func (c *UnknownCapability) Code() CapabilityCode {
+	return c.code
+}
+
+func (c *UnknownCapability) ToBytes() []byte {
+	return append([]byte{byte(c.code), byte(len(c.Value))}, c.Value...)
+}
+
+// CapabilityをCapabilities Optional Parameterのバイト列に変換する
+func CapabilitiesToOptionalParameters(caps []Capability) []byte {
+	if len(caps) == 0 {
+		return nil
+	}
+	v := make([]byte, 0)
+	for _, c := range caps {
+		v = append(v, c.ToBytes()...)
+	}
+	return append([]byte{CAPABILITIES_OPTIONAL_PARAMETER, byte(len(v))}, v...)
+}
+
+// Optional ParametersからCapabilityを取り出す
+// Capabilities以外のOptional Parameterは無視する
+func OptionalParametersToCapabilities(b []byte) ([]Capability, error) {
+	caps := make([]Capability, 0)
+	for i := 0; i < len(b); {
+		if len(b) < i+2 {
+			return nil, fmt.Errorf("Optional Parameterの長さが不正です。")
+		}
+		pt, pl := b[i], int(b[i+1])
+		end := i + 2 + pl
+		if len(b) < end {
+			return nil, fmt.Errorf("Optional Parameterの長さが不正です。")
+		}
+		if pt == CAPABILITIES_OPTIONAL_PARAMETER {
+			cs, err := bytesToCapabilities(b[i+2 : end])
+			if err != nil {
+				return nil, err
+			}
+			caps = append(caps, cs...)
+		}
+		i = end
+	}
+	return caps, nil
+}
+
+func bytesToCapabilities(b []byte) ([]Capability, error) {
+	caps := make([]Capability, 0)
+	for i := 0; i < len(b); {
+		if len(b) < i+2 {
+			return nil, fmt.Errorf("Capabilityの長さが不正です。")
+		}
+		code, l := CapabilityCode(b[i]), int(b[i+1])
+		end := i + 2 + l
+		if len(b) < end {
+			return nil, fmt.Errorf("Capabilityの長さが不正です。")
+		}
+		v := b[i+2 : end]
+		switch code {
+		case CapAddPath:
+			if l%4 != 0 {
+				return nil, fmt.Errorf("ADD-PATH Capabilityの長さが不正です。Length: %d", l)
+			}
+			c := &AddPathCapability{}
+			for j := 0; j < l; j += 4 {
+				c.Families = append(c.Families, AddPathFamily{
+					Family: Family{uint16(v[j])<<8 | uint16(v[j+1]), v[j+2]},
+					Mode:   AddPathMode(v[j+3]),
+				})
+			}
+			caps = append(caps, c)
+		case CapRouteRefresh:
+			caps = append(caps, &RouteRefreshCapability{})
+		case CapFourOctetAS:
+			if l != 4 {
+				return nil, fmt.Errorf("4-octet AS Number Capabilityの長さが不正です。Length: %d", l)
+			}
+			caps = append(caps, &FourOctetASCapability{
+				AS: uint32(v[0])<<24 | uint32(v[1])<<16 | uint32(v[2])<<8 | uint32(v[3]),
+			})
+		default:
+			caps = append(caps, &UnknownCapability{code: code, Value: v})
+		}
+		i = end
+	}
+	return caps, nil
+}
@@ -0,0 +1,436 @@
+package packets
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/SotaUeda/gobgp/bgptype"
+)
+
+// Messageの文字列表現とJSON表現
+//
+// 文字列は "UPDATE: ORIGIN: IGP, AS_PATH: 65001, NEXT_HOP: 10.0.0.1, NLRI: [10.1.0.0/16]" のように、
+// Messageの種類と主な値を並べる。
+// JSONは {"type": "UPDATE", ...} のように種類を持つオブジェクトにし、JSONToMessageで元に戻せる。
+// Headerの長さは値から計算できるため、JSONには含めない。
+
+func (h *Header) String() string {
+	return fmt.Sprintf("Type: %s, Length: %d", h.Type.Show(), h.length)
+}
+
+// JSONのtypeからMessageを作成する
+func JSONToMessage(b []byte) (Message, error) {
+	var v struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+	var m interface {
+		Message
+		json.Unmarshaler
+	}
+	switch v.Type {
+	case "OPEN":
+		m = &OpenMessage{}
+	case "UPDATE":
+		m = &UpdateMessage{}
+	case "NOTIFICATION":
+		m = &NotificationMessage{}
+	case "KEEPALIVE":
+		m = &KeepaliveMessage{}
+	default:
+		return nil, fmt.Errorf("JSONからMessageに変換できませんでした。未知のTypeです。Type: %q", v.Type)
+	}
+	if err := m.UnmarshalJSON(b); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// JSONのtypeがtであることを確認する
+func checkMessageType(b []byte, t string) error {
+	var v struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	if v.Type != t {
+		return fmt.Errorf("Typeが%sではありません。Type: %q", t, v.Type)
+	}
+	return nil
+}
+
+type openMessageJSON struct {
+	Type          string                         `json:"type"`
+	Version       bgptype.Version                `json:"version"`
+	MyAS          bgptype.AutonomousSystemNumber `json:"my_as"`
+	HoldTime      bgptype.HoldTime               `json:"hold_time"`
+	BGPIdentifier string                         `json:"bgp_identifier"`
+	Capabilities  []json.RawMessage              `json:"capabilities,omitempty"`
+}
+
+// Capabilitiesとして解釈できないOptional Parametersは16進数で表す
+func (m *OpenMessage) String() string {
+	s := fmt.Sprintf(
+		"OPEN: Version: %d, MyAS: %d, HoldTime: %d, BGPIdentifier: %s",
+		m.Version, m.MyAS, m.HoldTime, m.BGPIdentifier,
+	)
+	caps, err := m.Capabilities()
+	if err != nil {
+		return s + ", OptionalParameters: " + hex.EncodeToString(m.OptionalParameters)
+	}
+	if len(caps) > 0 {
+		ss := make([]string, 0, len(caps))
+		for _, c := range caps {
+			ss = append(ss, fmt.Sprint(c))
+		}
+		s += ", Capabilities: [" + strings.Join(ss, " ") + "]"
+	}
+	return s
+}
+
+// Capabilities以外のOptional Parameterは含めない
+func (m *OpenMessage) MarshalJSON() ([]byte, error) {
+	caps, err := m.Capabilities()
+	if err != nil {
+		return nil, err
+	}
+	v := openMessageJSON{
+		Type:          "OPEN",
+		Version:       m.Version,
+		MyAS:          m.MyAS,
+		HoldTime:      m.HoldTime,
+		BGPIdentifier: m.BGPIdentifier.String(),
+	}
+	for _, c := range caps {
+		b, err := capabilityToJSON(c)
+		if err != nil {
+			return nil, err
+		}
+		v.Capabilities = append(v.Capabilities, b)
+	}
+	return json.Marshal(v)
+}
+
+func (m *OpenMessage) UnmarshalJSON(b []byte) error {
+	if err := checkMessageType(b, "OPEN"); err != nil {
+		return err
+	}
+	var v openMessageJSON
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	ip := net.ParseIP(v.BGPIdentifier).To4()
+	if ip == nil {
+		return fmt.Errorf("BGPIdentifierはIPv4アドレスである必要があります。BGPIdentifier: %q", v.BGPIdentifier)
+	}
+	caps := make([]Capability, 0, len(v.Capabilities))
+	for _, raw := range v.Capabilities {
+		c, err := jsonToCapability(raw)
+		if err != nil {
+			return err
+		}
+		caps = append(caps, c)
+	}
+	*m = *NewOpenMessage(v.MyAS, ip, caps...)
+	m.Version = v.Version
+	m.HoldTime = v.HoldTime
+	return nil
+}
+
+type updateMessageJSON struct {
+	Type             string                 `json:"type"`
+	WithdrawnRoutes  []string               `json:"withdrawn_routes,omitempty"`
+	PathAttributes   bgptype.PathAttributes `json:"path_attributes,omitempty"`
+	NLRI             []string               `json:"nlri,omitempty"`
+	AddPath          bool                   `json:"add_path,omitempty"`
+	WithdrawnPathIDs []uint32               `json:"withdrawn_path_ids,omitempty"`
+	NLRIPathIDs      []uint32               `json:"nlri_path_ids,omitempty"`
+}
+
+// ADD-PATHの経路は "10.1.0.0/16(path-id=1)" のようにPath Identifierを付ける
+func (u *UpdateMessage) String() string {
+	ss := []string{}
+	if len(u.WithdrawnRoutes) > 0 {
+		ss = append(ss, "WITHDRAWN: "+u.showRoutes(u.WithdrawnRoutes, u.WithdrawnPathIDs))
+	}
+	if len(u.PathAttributes) > 0 {
+		ss = append(ss, bgptype.PathAttributes(u.PathAttributes).String())
+	}
+	if len(u.NetworkLayerReachabilityInformation) > 0 {
+		ss = append(ss, "NLRI: "+u.showRoutes(u.NetworkLayerReachabilityInformation, u.NLRIPathIDs))
+	}
+	if len(ss) == 0 {
+		// End-of-RIB
+		return "UPDATE"
+	}
+	return "UPDATE: " + strings.Join(ss, ", ")
+}
+
+func (u *UpdateMessage) showRoutes(nws []*net.IPNet, ids []uint32) string {
+	ss := make([]string, 0, len(nws))
+	for i, nw := range nws {
+		if u.AddPath && i < len(ids) {
+			ss = append(ss, fmt.Sprintf("%s(path-id=%d)", nw, ids[i]))
+		} else {
+			ss = append(ss, nw.String())
+		}
+	}
+	return "[" + strings.Join(ss, " ") + "]"
+}
+
+func (u *UpdateMessage) MarshalJSON() ([]byte, error) {
+	v := updateMessageJSON{
+		Type:           "UPDATE",
+		PathAttributes: u.PathAttributes,
+		AddPath:        u.AddPath,
+	}
+	for _, wr := range u.WithdrawnRoutes {
+		v.WithdrawnRoutes = append(v.WithdrawnRoutes, wr.String())
+	}
+	for _, nw := range u.NetworkLayerReachabilityInformation {
+		v.NLRI = append(v.NLRI, nw.String())
+	}
+	if u.AddPath {
+		v.WithdrawnPathIDs, v.NLRIPathIDs = u.WithdrawnPathIDs, u.NLRIPathIDs
+	}
+	return json.Marshal(v)
+}
+
+func (u *UpdateMessage) UnmarshalJSON(b []byte) error {
+	if err := checkMessageType(b, "UPDATE"); err != nil {
+		return err
+	}
+	var v updateMessageJSON
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	wrs, err := parseRoutes(v.WithdrawnRoutes)
+	if err != nil {
+		return err
+	}
+	nlri, err := parseRoutes(v.NLRI)
+	if err != nil {
+		return err
+	}
+	pas := []bgptype.PathAttribute(v.PathAttributes)
+	if pas == nil {
+		pas = []bgptype.PathAttribute{}
+	}
+	var um *UpdateMessage
+	if v.AddPath {
+		um, err = NewAddPathUpdateMessage(pas, nlri, v.NLRIPathIDs, wrs, v.WithdrawnPathIDs)
+	} else {
+		um, err = NewUpdateMessage(pas, nlri, wrs)
+	}
+	if err != nil {
+		return err
+	}
+	*u = *um
+	return nil
+}
+
+func parseRoutes(ss []string) ([]*net.IPNet, error) {
+	nws := make([]*net.IPNet, 0, len(ss))
+	for _, s := range ss {
+		_, nw, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("経路のプレフィックスが不正です。Route: %q", s)
+		}
+		nws = append(nws, nw)
+	}
+	return nws, nil
+}
+
+type notificationMessageJSON struct {
+	Type         string    `json:"type"`
+	ErrorCode    ErrorCode `json:"error_code"`
+	ErrorSubcode uint8     `json:"error_subcode"`
+	// 読みやすさのためのErrorCodeの名前。UnmarshalJSONでは使わない
+	Error string `json:"error,omitempty"`
+	Data  string `json:"data,omitempty"`
+}
+
+func (m *NotificationMessage) String() string {
+	s := fmt.Sprintf("NOTIFICATION: %s, Subcode: %d", m.ErrorCode.Show(), m.ErrorSubcode)
+	if len(m.Data) > 0 {
+		s += ", Data: " + hex.EncodeToString(m.Data)
+	}
+	return s
+}
+
+func (m *NotificationMessage) MarshalJSON() ([]byte, error) {
+	return json.Marshal(notificationMessageJSON{
+		Type:         "NOTIFICATION",
+		ErrorCode:    m.ErrorCode,
+		ErrorSubcode: m.ErrorSubcode,
+		Error:        m.ErrorCode.Show(),
+		Data:         hex.EncodeToString(m.Data),
+	})
+}
+
+func (m *NotificationMessage) UnmarshalJSON(b []byte) error {
+	if err := checkMessageType(b, "NOTIFICATION"); err != nil {
+		return err
+	}
+	var v notificationMessageJSON
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	data, err := hex.DecodeString(v.Data)
+	if err != nil {
+		return fmt.Errorf("NotificationMessageのDataが16進数ではありません。Data: %q", v.Data)
+	}
+	if len(data) == 0 {
+		data = nil
+	}
+	*m = *NewNotificationMessage(v.ErrorCode, v.ErrorSubcode, data)
+	return nil
+}
+
+func (m *KeepaliveMessage) String() string {
+	return "KEEPALIVE"
+}
+
+func (m *KeepaliveMessage) MarshalJSON() ([]byte, error) {
+	return []byte(`{"type":"KEEPALIVE"}`), nil
+}
+
+func (m *KeepaliveMessage) UnmarshalJSON(b []byte) error {
+	if err := checkMessageType(b, "KEEPALIVE"); err != nil {
+		return err
+	}
+	*m = *NewKeepaliveMessage()
+	return nil
+}
+
+func (m AddPathMode) Show() string {
+	switch m {
+	case AddPathReceive:
+		return "receive"
+	case AddPathSend:
+		return "send"
+	case AddPathBoth:
+		return "both"
+	default:
+		return fmt.Sprintf("%d", uint8(m))
+	}
+}
+
+func (c *AddPathCapability) String() string {
+	ss := make([]string, 0, len(c.Families))
+	for _, f := range c.Families {
+		ss = append(ss, f.Family.Show()+": "+f.Mode.Show())
+	}
+	return "ADD-PATH(" + strings.Join(ss, ", ") + ")"
+}
+
+func (c *RouteRefreshCapability) String() string {
+	return "ROUTE-REFRESH"
+}
+
+func (c *FourOctetASCapability) String() string {
+	return fmt.Sprintf("4-OCTET-AS(%d)", c.AS)
+}
+
+func (c *UnknownCapability) String() string {
+	return fmt.Sprintf("UNKNOWN(%d): %s", c.code, hex.EncodeToString(c.Value))
+}
+
+// CapabilityのJSON表現
+type capabilityJSON struct {
+	Code     string              `json:"code"`
+	Families []addPathFamilyJSON `json:"families,omitempty"`
+	AS       uint32              `json:"as,omitempty"`
+	// 対応していないCapabilityのCapability CodeとCapability Value
+	Value string `json:"value,omitempty"`
+}
+
+type addPathFamilyJSON struct {
+	Family string `json:"family"`
+	Mode   string `json:"mode"`
+}
+
+func capabilityToJSON(c Capability) ([]byte, error) {
+	var v capabilityJSON
+	switch c := c.(type) {
+	case *AddPathCapability:
+		v.Code = "ADD-PATH"
+		for _, f := range c.Families {
+			v.Families = append(v.Families, addPathFamilyJSON{f.Family.Show(), f.Mode.Show()})
+		}
+	case *RouteRefreshCapability:
+		v.Code = "ROUTE-REFRESH"
+	case *FourOctetASCapability:
+		v.Code, v.AS = "4-OCTET-AS", c.AS
+	default:
+		b := c.ToBytes()
+		v.Code, v.Value = fmt.Sprintf("%d", c.Code()), hex.EncodeToString(b[2:])
+	}
+	return json.Marshal(v)
+}
+
+// codeが数値の場合は、対応していないCapabilityとしてValueをそのまま使う
+func jsonToCapability(b []byte) (Capability, error) {
+	var v capabilityJSON
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+	switch v.Code {
+	case "ADD-PATH":
+		c := &AddPathCapability{}
+		for _, f := range v.Families {
+			fam, err := parseFamily(f.Family)
+			if err != nil {
+				return nil, err
+			}
+			mode, err := parseAddPathMode(f.Mode)
+			if err != nil {
+				return nil, err
+			}
+			c.Families = append(c.Families, AddPathFamily{fam, mode})
+		}
+		return c, nil
+	case "ROUTE-REFRESH":
+		return &RouteRefreshCapability{}, nil
+	case "4-OCTET-AS":
+		return &FourOctetASCapability{AS: v.AS}, nil
+	}
+	var code uint8
+	if _, err := fmt.Sscanf(v.Code, "%d", &code); err != nil {
+		return nil, fmt.Errorf("未知のCapabilityです。Code: %q", v.Code)
+	}
+	value, err := hex.DecodeString(v.Value)
+	if err != nil {
+		return nil, fmt.Errorf("CapabilityのValueが16進数ではありません。Value: %q", v.Value)
+	}
+	return &UnknownCapability{code: CapabilityCode(code), Value: value}, nil
+}
+
+// Family.Showの文字列をFamilyにする
+func parseFamily(s string) (Family, error) {
+	for _, f := range []Family{IPv4Unicast, {AFI_IPV6, SAFI_UNICAST}} {
+		if f.Show() == s {
+			return f, nil
+		}
+	}
+	var f Family
+	if _, err := fmt.Sscanf(s, "afi=%d,safi=%d", &f.AFI, &f.SAFI); err != nil {
+		return Family{}, fmt.Errorf("未知のAddress Familyです。Family: %q", s)
+	}
+	return f, nil
+}
+
+func parseAddPathMode(s string) (AddPathMode, error) {
+	for _, m := range []AddPathMode{AddPathReceive, AddPathSend, AddPathBoth} {
+		if m.Show() == s {
+			return m, nil
+		}
+	}
+	return 0, fmt.Errorf("未知のADD-PATHのModeです。Mode: %q", s)
+}
@@ -57,18 +57,35 @@ func (h *Header) ToBytes() ([]byte, error) {
 type MessageType uint8
 
 const (
-	Open      MessageType = iota + 1 // 1
-	Update                           // 2
-	_                                // 3
-	Keepalive                        // 4
+	Open         MessageType = iota + 1 // 1
+	Update                              // 2
+	Notification                        // 3
+	Keepalive                           // 4
 )
 
+func (t MessageType) Show() string {
+	switch t {
+	case Open:
+		return "Open"
+	case Update:
+		return "Update"
+	case Notification:
+		return "Notification"
+	case Keepalive:
+		return "Keepalive"
+	default:
+		return fmt.Sprintf("Unknown(%d)", t)
+	}
+}
+
 func BytesToMessageType(b byte) (MessageType, error) {
 	switch b {
 	case 1:
 		return Open, nil
 	case 2:
 		return Update, nil
+	case 3:
+		return Notification, nil
 	case 4:
 		return Keepalive, nil
 	default:
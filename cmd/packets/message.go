@@ -8,10 +8,29 @@ type Message interface {
 	ToMessage([]byte) error
 	ToBytes() ([]byte, error)
 	Show() string
+	// "UPDATE: ORIGIN: IGP, ..." のようなMessageの種類と値の文字列
+	String() string
+	MarshalJSON() ([]byte, error)
+}
+
+// Peerとのネゴシエーションによって変わるMessageの解釈
+type DecodeOptions struct {
+	// UpdateMessageの経路にPath Identifierが付いているか (ADD-PATH)
+	AddPath bool
+	// UpdateMessageのAS_PATHのAS番号が4byteか (RFC6793)
+	AS4 bool
 }
 
 // Goでは、インターフェース型を返す関数で具体的な型のポインタを返すことができる
 func BytesToMessage(b []byte) (Message, error) {
+	return BytesToMessageWithOptions(b, DecodeOptions{})
+}
+
+func BytesToMessageWithOptions(b []byte, opts DecodeOptions) (Message, error) {
+	// Peerから受信したバイト列は壊れている場合があるため、変換する前に長さを検査する
+	if err := ValidateMessage(b, opts); err != nil {
+		return nil, err
+	}
 	h := &Header{}
 	hErr := h.ToHeader(b[0:HEADER_LENGTH])
 	if hErr != nil {
@@ -24,7 +43,9 @@ func BytesToMessage(b []byte) (Message, error) {
 	case Keepalive:
 		m = &KeepaliveMessage{}
 	case Update:
-		m = &UpdateMessage{}
+		m = &UpdateMessage{AddPath: opts.AddPath, AS4: opts.AS4}
+	case Notification:
+		m = &NotificationMessage{}
 	default:
 		return nil, fmt.Errorf(
 			"BytesからMessageに変換できませんでした。"+
@@ -0,0 +1,157 @@
+package packets
+
+import "fmt"
+
+// NotificationMessageのフォーマット
+// Error code: 1byte: エラーの種類
+// Error subcode: 1byte: Error codeごとのより詳細なエラーの種類
+// Data: 可変長: エラーの原因となったデータなど
+//
+// NotificationMessageを送信した後はコネクションを切断する。
+type NotificationMessage struct {
+	Header       *Header
+	ErrorCode    ErrorCode
+	ErrorSubcode uint8
+	Data         []byte
+}
+
+const NOTIFICATION_MESSAGE_MIN_LENGTH = HEADER_LENGTH + 2
+
+// RFC4271 4.5で定義されているError code
+type ErrorCode uint8
+
+const (
+	MessageHeaderError      ErrorCode = iota + 1 // 1
+	OpenMessageError                             // 2
+	UpdateMessageError                           // 3
+	HoldTimerExpired                             // 4
+	FiniteStateMachineError                      // 5
+	Cease                                        // 6
+)
+
+// RFC4271 6.1で定義されているMessage Header ErrorのError subcode
+const (
+	ConnectionNotSynchronized uint8 = iota + 1 // 1
+	BadMessageLength                           // 2
+	BadMessageType                             // 3
+)
+
+// RFC4271 6.2で定義されているOPEN Message ErrorのError subcode
+const (
+	UnsupportedVersionNumber     uint8 = iota + 1 // 1
+	BadPeerAS                                     // 2
+	BadBGPIdentifier                              // 3
+	UnsupportedOptionalParameter                  // 4
+	_                                             // 5: Deprecated
+	UnacceptableHoldTime                          // 6
+)
+
+// RFC4271 6.3で定義されているUPDATE Message ErrorのError subcode
+const (
+	MalformedAttributeList         uint8 = iota + 1 // 1
+	UnrecognizedWellKnownAttribute                  // 2
+	MissingWellKnownAttribute                       // 3
+	AttributeFlagsError                             // 4
+	AttributeLengthError                            // 5
+	InvalidOriginAttribute                          // 6
+	_                                               // 7: Deprecated
+	InvalidNextHopAttribute                         // 8
+	OptionalAttributeError                          // 9
+	InvalidNetworkField                             // 10
+	MalformedASPath                                 // 11
+)
+
+// RFC4486で定義されているCeaseのError subcode
+const (
+	MaximumNumberOfPrefixesReached uint8 = iota + 1 // 1
+	AdministrativeShutdown                          // 2
+	PeerDeConfigured                                // 3
+	AdministrativeReset                             // 4
+	ConnectionRejected                              // 5
+	OtherConfigurationChange                        // 6
+	ConnectionCollisionResolution                   // 7
+	OutOfResources                                  // 8
+)
+
+func (c ErrorCode) Show() string {
+	switch c {
+	case MessageHeaderError:
+		return "Message Header Error"
+	case OpenMessageError:
+		return "OPEN Message Error"
+	case UpdateMessageError:
+		return "UPDATE Message Error"
+	case HoldTimerExpired:
+		return "Hold Timer Expired"
+	case FiniteStateMachineError:
+		return "Finite State Machine Error"
+	case Cease:
+		return "Cease"
+	default:
+		return fmt.Sprintf("Unknown(%d)", c)
+	}
+}
+
+func NewNotificationMessage(code ErrorCode, subcode uint8, data []byte) *NotificationMessage {
+	h := NewHeader(uint16(NOTIFICATION_MESSAGE_MIN_LENGTH+len(data)), Notification)
+	return &NotificationMessage{
+		Header:       h,
+		ErrorCode:    code,
+		ErrorSubcode: subcode,
+		Data:         data,
+	}
+}
+
+// Cease/Maximum Number of Prefixes Reachedを表すNotificationMessageを作成する。
+// RFC4486 4.ではDataにAFI(2byte), SAFI(1byte), 上限のプレフィックス数(4byte)を入れる。
+func NewMaxPrefixNotificationMessage(afi uint16, safi uint8, limit uint32) *NotificationMessage {
+	data := []byte{
+		byte(afi >> 8), byte(afi),
+		safi,
+		byte(limit >> 24), byte(limit >> 16), byte(limit >> 8), byte(limit),
+	}
+	return NewNotificationMessage(Cease, MaximumNumberOfPrefixesReached, data)
+}
+
+func (m *NotificationMessage) Show() string {
+	return fmt.Sprintf(
+		"Header: %v, ErrorCode: %s, ErrorSubcode: %d, Data: %v",
+		m.Header,
+		m.ErrorCode.Show(),
+		m.ErrorSubcode,
+		m.Data,
+	)
+}
+
+func (m *NotificationMessage) ToMessage(b []byte) error {
+	if len(b) < NOTIFICATION_MESSAGE_MIN_LENGTH {
+		return fmt.Errorf(
+			"NotificationMessageに変換できませんでした。Bytesの長さが最小の長さより短いです。最小: %d, Bytes: %d",
+			NOTIFICATION_MESSAGE_MIN_LENGTH, len(b),
+		)
+	}
+	h := &Header{}
+	if err := h.ToHeader(b[0:HEADER_LENGTH]); err != nil {
+		return err
+	}
+	if h.Type != Notification {
+		return fmt.Errorf("TypeがNotificationではありません。Type: %d", h.Type)
+	}
+	m.Header = h
+	m.ErrorCode = ErrorCode(b[19])
+	m.ErrorSubcode = b[20]
+	m.Data = b[21:]
+	return nil
+}
+
+func (m *NotificationMessage) ToBytes() ([]byte, error) {
+	hb, err := m.Header.ToBytes()
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, 0, NOTIFICATION_MESSAGE_MIN_LENGTH+len(m.Data))
+	b = append(b, hb...)
+	b = append(b, byte(m.ErrorCode), m.ErrorSubcode)
+	b = append(b, m.Data...)
+	return b, nil
+}
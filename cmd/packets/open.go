@@ -14,26 +14,36 @@ type OpenMessage struct {
 	HoldTime      bgptype.HoldTime // 正常系のみ実装するので一旦実質的に使用しない
 	BGPIdentifier net.IP
 
-	// 使用しないが、相手から受信したときに一応保存しておくためにプロパティとして定義
+	// Capabilitiesのみ扱い、それ以外のOptional Parameterは保存するだけにする
 	OptionalParameterLength uint8
 	OptionalParameters      []byte
 }
 
-const OPEN_MESSAGE_LENGTH = 29 // 自発的にOpenMessageを送信する場合の固定の長さ
+const OPEN_MESSAGE_LENGTH = 29 // Optional Parametersを含まないOpenMessageの長さ
 
-func NewOpenMessage(as bgptype.AutonomousSystemNumber, ip net.IP) *OpenMessage {
-	h := NewHeader(OPEN_MESSAGE_LENGTH, Open)
+func NewOpenMessage(
+	as bgptype.AutonomousSystemNumber,
+	ip net.IP,
+	caps ...Capability,
+) *OpenMessage {
+	op := CapabilitiesToOptionalParameters(caps)
+	h := NewHeader(uint16(OPEN_MESSAGE_LENGTH+len(op)), Open)
 	return &OpenMessage{
 		Header:                  h,
 		Version:                 bgptype.NewVersion(),
 		MyAS:                    as,
 		HoldTime:                bgptype.NewHoldTime(),
 		BGPIdentifier:           ip.To4(),
-		OptionalParameterLength: 0,
-		OptionalParameters:      nil,
+		OptionalParameterLength: uint8(len(op)),
+		OptionalParameters:      op,
 	}
 }
 
+// Optional Parametersに含まれるCapabilityを返す
+func (m *OpenMessage) Capabilities() ([]Capability, error) {
+	return OptionalParametersToCapabilities(m.OptionalParameters)
+}
+
 func (m *OpenMessage) Show() string {
 	return fmt.Sprintf(
 		"Header: %v, Version: %d, MyAS: %d, HoldTime: %d, BGPIdentifier: %s, OptionalParameterLength: %d, OptionalParameters: %v",
@@ -1,7 +1,12 @@
 package packets
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net"
+	"strings"
 	"testing"
 
 	"github.com/SotaUeda/gobgp/bgptype"
@@ -103,3 +108,365 @@ func TestConvertBytesToUpdateMessageAndUpdateMessageToBytes(t *testing.T) {
 		t.Errorf("Want: %v, \nGot: %v", want, get)
 	}
 }
+
+// NotificationMessageのToMessageメソッドとToBytesメソッドをテストする
+func TestConvertBytesToNotificationMessageAndNotificationMessageToBytes(t *testing.T) {
+	m := NewMaxPrefixNotificationMessage(1, 1, 1000)
+	b, err := m.ToBytes()
+	if err != nil {
+		t.Errorf("Error: %v", err)
+	}
+	if len(b) != NOTIFICATION_MESSAGE_MIN_LENGTH+7 {
+		t.Errorf("Want: %d, Got: %d", NOTIFICATION_MESSAGE_MIN_LENGTH+7, len(b))
+	}
+	got, err := BytesToMessage(b)
+	if err != nil {
+		t.Errorf("Error: %v", err)
+	}
+	if want, get := m.Show(), got.Show(); want != get {
+		t.Errorf("Want: %v, \nGot: %v", want, get)
+	}
+}
+
+// ADD-PATH Capabilityを含むOpenMessageを変換し、Capabilityを取り出せることを確認するテスト
+func TestOpenMessageWithCapabilities(t *testing.T) {
+	ip := net.ParseIP("127.0.0.1").To4()
+	caps := []Capability{
+		&AddPathCapability{Families: []AddPathFamily{{IPv4Unicast, AddPathBoth}}},
+		&RouteRefreshCapability{},
+	}
+	b, err := NewOpenMessage(64512, ip, caps...).ToBytes()
+	if err != nil {
+		t.Errorf("Error: %v", err)
+	}
+	m, err := BytesToMessage(b)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	got, err := m.(*OpenMessage).Capabilities()
+	if err != nil {
+		t.Errorf("Error: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("Want: 2, Got: %d", len(got))
+	}
+	ap, ok := got[0].(*AddPathCapability)
+	if !ok || ap.Mode(IPv4Unicast) != AddPathBoth {
+		t.Errorf("Want: %v, Got: %v", caps[0], got[0])
+	}
+	if _, ok := got[1].(*RouteRefreshCapability); !ok {
+		t.Errorf("Want: %v, Got: %v", caps[1], got[1])
+	}
+}
+
+// Path Identifier付きのUpdateMessageを変換できることを確認するテスト
+func TestConvertAddPathUpdateMessage(t *testing.T) {
+	originIGP := bgptype.IGP
+	nh := bgptype.NextHop(net.ParseIP("10.200.100.3").To4())
+	pas := []bgptype.PathAttribute{&originIGP, bgptype.NewAsPath(true, 64513), &nh}
+	rt := &net.IPNet{IP: net.ParseIP("10.100.220.0").To4(), Mask: net.CIDRMask(24, 32)}
+	wr := &net.IPNet{IP: net.ParseIP("10.100.0.0").To4(), Mask: net.CIDRMask(16, 32)}
+	um, err := NewAddPathUpdateMessage(
+		pas,
+		[]*net.IPNet{rt, rt},
+		[]uint32{1, 2},
+		[]*net.IPNet{wr},
+		[]uint32{3},
+	)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	b, err := um.ToBytes()
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	m, err := BytesToMessageWithOptions(b, DecodeOptions{AddPath: true})
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	got := m.(*UpdateMessage)
+	if want, get := um.Show(), got.Show(); want != get {
+		t.Errorf("Want: %v, \nGot: %v", want, get)
+	}
+	if fmt.Sprint(got.NLRIPathIDs) != "[1 2]" || fmt.Sprint(got.WithdrawnPathIDs) != "[3]" {
+		t.Errorf("Want: [1 2] [3], Got: %v %v", got.NLRIPathIDs, got.WithdrawnPathIDs)
+	}
+}
+
+// 集約した経路のUpdateMessageで、AsSequenceとAsSetが1つのAS_PATH属性にまとめられ、
+// ATOMIC_AGGREGATEとAGGREGATORとともに変換できることを確認するテスト
+func TestConvertAggregateUpdateMessage(t *testing.T) {
+	originIGP := bgptype.IGP
+	nh := bgptype.NextHop(net.ParseIP("10.200.100.3").To4())
+	pas := []bgptype.PathAttribute{
+		&originIGP,
+		bgptype.NewAsPath(true, 64513),
+		bgptype.NewAsPath(false, 65002, 65001),
+		&nh,
+		&bgptype.AtomicAggregate{},
+		&bgptype.Aggregator{AS: 64513, Address: net.ParseIP("10.200.100.3").To4()},
+	}
+	rt := &net.IPNet{IP: net.ParseIP("10.0.0.0").To4(), Mask: net.CIDRMask(16, 32)}
+	um, err := NewUpdateMessage(pas, []*net.IPNet{rt}, []*net.IPNet{})
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	b, err := um.ToBytes()
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if int(um.Header.length) != len(b) {
+		t.Errorf("Want: %v, Got: %v", len(b), um.Header.length)
+	}
+	// AS_PATH属性(Attr Type Code 2)は1つだけ含まれる
+	pab := bgptype.PathAttributesToBytes(pas)
+	want := []byte{0b01000000, 2, 10, 2, 1, 0xfc, 0x01, 1, 2, 0xfd, 0xe9, 0xfd, 0xea}
+	if !bytes.Contains(pab, want) {
+		t.Errorf("Want: %v in %v", want, pab)
+	}
+	m, err := BytesToMessage(b)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	got := m.(*UpdateMessage)
+	if want, get := um.Show(), got.Show(); want != get {
+		t.Errorf("Want: %v, \nGot: %v", want, get)
+	}
+}
+
+// AS番号が4byteのAS_PATHを持つUpdateMessageを変換できることを確認するテスト
+// 2byteで表せないAS番号はAS_TRANSになる
+func TestConvertAS4UpdateMessage(t *testing.T) {
+	pas := []byte{
+		0x40, 1, 1, 0, // ORIGIN: IGP
+		0x40, 2, 10, 2, 2, 0xfa, 0x56, 0xea, 0x00, 0, 0, 0xfc, 0x01, // AS_PATH: 4200000000 64513
+		0x40, 3, 4, 10, 200, 100, 3, // NEXT_HOP
+	}
+	b := []byte{
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+		0, byte(23 + len(pas) + 4), byte(Update),
+		0, 0, 0, byte(len(pas)),
+	}
+	b = append(b, pas...)
+	b = append(b, 24, 10, 100, 220)
+	m, err := BytesToMessageWithOptions(b, DecodeOptions{AS4: true})
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	um := m.(*UpdateMessage)
+	var seq *bgptype.AsSequence
+	for _, pa := range um.PathAttributes {
+		if s, ok := pa.(*bgptype.AsSequence); ok {
+			seq = s
+		}
+	}
+	want := bgptype.AsSequence{bgptype.AS_TRANS, 64513}
+	if seq == nil || fmt.Sprint(*seq) != fmt.Sprint(want) {
+		t.Errorf("Want: %v, Got: %v", want, seq)
+	}
+	got, err := um.ToBytes()
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	// AS_TRANS以外はそのまま4byteでエンコードする
+	if !bytes.Equal(got[23+9:23+13], []byte{0, 0, 0x5b, 0xa0}) || !bytes.Equal(got[23+13:23+17], pas[13:17]) {
+		t.Errorf("Want: %v, Got: %v", pas, got[23:])
+	}
+	// 2byteのAS番号として変換するとAS_PATHの長さが合わない
+	if _, err := BytesToMessage(b); err == nil {
+		t.Errorf("Want: error, Got: nil")
+	}
+}
+
+// MessageとPathAttributeを読みやすい文字列にできることを確認するテスト
+func TestMessageString(t *testing.T) {
+	origin := bgptype.IGP
+	nh := bgptype.NextHop(net.ParseIP("10.0.0.1").To4())
+	pas := []bgptype.PathAttribute{
+		&origin,
+		bgptype.NewAsPath(true, 65001),
+		bgptype.NewAsPath(false, 65003, 65002),
+		&nh,
+		&bgptype.AtomicAggregate{},
+		&bgptype.Aggregator{AS: 65001, Address: net.ParseIP("10.0.0.1").To4()},
+	}
+	_, nw, _ := net.ParseCIDR("10.1.0.0/16")
+	_, wr, _ := net.ParseCIDR("10.2.0.0/16")
+	um, err := NewUpdateMessage(pas, []*net.IPNet{nw}, []*net.IPNet{wr})
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	aum, err := NewAddPathUpdateMessage(pas[:1], []*net.IPNet{nw}, []uint32{2}, []*net.IPNet{}, []uint32{})
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	open := NewOpenMessage(64512, net.ParseIP("10.0.0.1"),
+		&AddPathCapability{Families: []AddPathFamily{{IPv4Unicast, AddPathBoth}}},
+		&RouteRefreshCapability{},
+	)
+	tests := []struct {
+		m    fmt.Stringer
+		want string
+	}{
+		{&origin, "ORIGIN: IGP"},
+		{bgptype.PathAttributes(pas[1:3]), "AS_PATH: 65001 {65002 65003}"},
+		{&nh, "NEXT_HOP: 10.0.0.1"},
+		{um, "UPDATE: WITHDRAWN: [10.2.0.0/16], ORIGIN: IGP, AS_PATH: 65001 {65002 65003}, NEXT_HOP: 10.0.0.1, " +
+			"ATOMIC_AGGREGATE, AGGREGATOR: 65001 10.0.0.1, NLRI: [10.1.0.0/16]"},
+		{aum, "UPDATE: ORIGIN: IGP, NLRI: [10.1.0.0/16(path-id=2)]"},
+		{open, "OPEN: Version: 4, MyAS: 64512, HoldTime: 0, BGPIdentifier: 10.0.0.1, " +
+			"Capabilities: [ADD-PATH(ipv4-unicast: both) ROUTE-REFRESH]"},
+		{NewNotificationMessage(Cease, AdministrativeShutdown, []byte{1, 2}), "NOTIFICATION: Cease, Subcode: 2, Data: 0102"},
+		{NewKeepaliveMessage(), "KEEPALIVE"},
+		{NewKeepaliveMessage().Header, "Type: Keepalive, Length: 19"},
+	}
+	for _, tt := range tests {
+		if got := tt.m.String(); got != tt.want {
+			t.Errorf("Want: %v, Got: %v", tt.want, got)
+		}
+	}
+}
+
+// JSONから作成したMessageが、JSONにしたMessageと同じbytesになることを確認するテスト
+func TestMessageJSON(t *testing.T) {
+	fixtures := []string{
+		`{"type":"OPEN","version":4,"my_as":64512,"hold_time":90,"bgp_identifier":"10.0.0.1",` +
+			`"capabilities":[{"code":"ADD-PATH","families":[{"family":"ipv4-unicast","mode":"both"}]},` +
+			`{"code":"ROUTE-REFRESH"},{"code":"4-OCTET-AS","as":64512},{"code":"128","value":"0102"}]}`,
+		`{"type":"UPDATE","withdrawn_routes":["10.2.0.0/16"],"path_attributes":[{"type":"ORIGIN","value":"EGP"},` +
+			`{"type":"AS_PATH","value":"65001 {65002 65003}"},{"type":"NEXT_HOP","value":"10.0.0.1"},` +
+			`{"type":"ATOMIC_AGGREGATE"},{"type":"AGGREGATOR","value":{"as":65001,"address":"10.0.0.1"}},` +
+			`{"type":"UNKNOWN","value":"c00804fde80001"}],"nlri":["10.1.0.0/16","10.3.0.0/24"]}`,
+		`{"type":"UPDATE","path_attributes":[{"type":"ORIGIN","value":"IGP"},{"type":"AS_PATH","value":""}],` +
+			`"nlri":["10.1.0.0/16"],"add_path":true,"withdrawn_path_ids":[],"nlri_path_ids":[7]}`,
+		`{"type":"UPDATE"}`,
+		`{"type":"NOTIFICATION","error_code":6,"error_subcode":2,"error":"Cease","data":"0102"}`,
+		`{"type":"KEEPALIVE"}`,
+	}
+	for _, f := range fixtures {
+		m, err := JSONToMessage([]byte(f))
+		if err != nil {
+			t.Fatalf("Error: %v: %s", err, f)
+		}
+		b, err := m.ToBytes()
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		got, err := BytesToMessageWithOptions(b, DecodeOptions{AddPath: strings.Contains(f, "add_path")})
+		if err != nil {
+			t.Fatalf("Error: %v: %s", err, m)
+		}
+		if want, get := m.Show(), got.Show(); want != get {
+			t.Errorf("Want: %v, \nGot: %v", want, get)
+		}
+		j, err := json.Marshal(got)
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		// 空のPath Identifierは省略する
+		want := strings.Replace(f, `"withdrawn_path_ids":[],`, "", 1)
+		if string(j) != want {
+			t.Errorf("Want: %v, \nGot: %v", want, string(j))
+		}
+	}
+
+	// typeが一致しない
+	if err := (&UpdateMessage{}).UnmarshalJSON([]byte(`{"type":"KEEPALIVE"}`)); err == nil {
+		t.Errorf("Want: error, Got: nil")
+	}
+	if _, err := JSONToMessage([]byte(`{"type":"UPDATE","path_attributes":[{"type":"AS_PATH","value":"65001 {65002"}]}`)); err == nil {
+		t.Errorf("Want: error, Got: nil")
+	}
+}
+
+// SplitMessageでバッファからMessageを切り出し、
+// ValidateMessageで不正なMessageの不正な位置をオフセットで返すことを確認する
+func TestValidateMessage(t *testing.T) {
+	ka, _ := NewKeepaliveMessage().ToBytes()
+	origin := bgptype.IGP
+	_, nw, _ := net.ParseCIDR("10.1.0.0/16")
+	um, err := NewUpdateMessage([]bgptype.PathAttribute{&origin, bgptype.NewAsPath(true, 65001)}, []*net.IPNet{nw}, []*net.IPNet{})
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	ub, _ := um.ToBytes()
+
+	buf := append(append([]byte{}, ka...), ka[:10]...)
+	msg, rest, err := SplitMessage(buf)
+	if err != nil || !bytes.Equal(msg, ka) || len(rest) != 10 {
+		t.Errorf("Want: %v, Got: %v, %v, %v", ka, msg, rest, err)
+	}
+	// Messageが途中で終わっている
+	if msg, _, err := SplitMessage(rest); msg != nil || err != nil {
+		t.Errorf("Want: nil, Got: %v, %v", msg, err)
+	}
+
+	modify := func(b []byte, i int, v byte) []byte {
+		b = append([]byte{}, b...)
+		b[i] = v
+		return b
+	}
+	tests := []struct {
+		b    []byte
+		opts DecodeOptions
+		// -1の場合は不正な位置がない
+		offset int
+	}{
+		{ka, DecodeOptions{}, -1},
+		{ub, DecodeOptions{}, -1},
+		{modify(ka, 3, 0), DecodeOptions{}, 3},
+		{modify(ka, 18, 9), DecodeOptions{}, 18},
+		{append(append([]byte{}, ka...), 0), DecodeOptions{}, 16},
+		// ORIGINの値
+		{modify(ub, 26, 3), DecodeOptions{}, 26},
+		// AS_PATHのSegment Type
+		{modify(ub, 30, 3), DecodeOptions{}, 30},
+		// 2byteのAS番号を4byteとして検査すると、AS_PATHのSegment Lengthが不正になる
+		{ub, DecodeOptions{AS4: true}, 31},
+		// NLRIのプレフィックス長
+		{modify(ub, len(ub)-3, 33), DecodeOptions{}, len(ub) - 3},
+		// NLRIにPath Identifierが付いていない
+		{ub, DecodeOptions{AddPath: true}, len(ub) - 3},
+	}
+	for _, tt := range tests {
+		err := ValidateMessage(tt.b, tt.opts)
+		var fe *FormatError
+		switch {
+		case tt.offset < 0 && err != nil:
+			t.Errorf("Want: nil, Got: %v", err)
+		case tt.offset >= 0 && (!errors.As(err, &fe) || fe.Offset != tt.offset):
+			t.Errorf("Want: %v, Got: %v", tt.offset, err)
+		}
+	}
+}
+
+// 壊れたMessageを変換した場合に、panicせずにNotificationMessageのError codeを持つエラーを返すことを確認するテスト
+func TestBytesToMessageRejectsMalformedUpdate(t *testing.T) {
+	header := func(l int) []byte {
+		return append(bytes.Repeat([]byte{0xff}, 16), byte(l>>8), byte(l), byte(Update))
+	}
+	tests := []struct {
+		b       []byte
+		code    ErrorCode
+		subcode uint8
+	}{
+		// Withdrawn Routes LengthがMessageの長さを超えている
+		{append(header(23), 0, 100, 0, 0), UpdateMessageError, MalformedAttributeList},
+		// Headerだけで、Withdrawn Routes Lengthがない
+		{header(19), MessageHeaderError, BadMessageLength},
+		// NLRIのプレフィックス長が32を超えている
+		{append(header(25), 0, 0, 0, 0, 33, 10), UpdateMessageError, InvalidNetworkField},
+	}
+	for _, tt := range tests {
+		_, err := BytesToMessage(tt.b)
+		var fe *FormatError
+		if !errors.As(err, &fe) {
+			t.Errorf("Want: %v, Got: %v", tt.code.Show(), err)
+			continue
+		}
+		if fe.Code != tt.code || fe.Subcode != tt.subcode {
+			t.Errorf("Want: %v/%d, Got: %v/%d", tt.code.Show(), tt.subcode, fe.Code.Show(), fe.Subcode)
+		}
+	}
+}
@@ -18,55 +18,117 @@ type UpdateMessage struct {
 	// NLRIのオクテット数はBGP UpdateMessageに含めず、
 	// Headerのサイズを計算することにしか使用しないため
 	// メンバに含めていない。
+
+	// ADD-PATH (RFC7911) が有効な場合はWithdrawnRoutesとNLRIの各経路の前に
+	// 4byteのPath Identifierが付く。
+	// PathIDsはWithdrawnRoutes, NLRIと同じ順序で経路のPath Identifierを持つ
+	AddPath          bool
+	WithdrawnPathIDs []uint32
+	NLRIPathIDs      []uint32
+	// AS_PATHとAGGREGATORのAS番号を4byteでエンコードする (RFC6793)
+	// 2byteで表せないAS番号は、変換するとAS_TRANSになる
+	AS4 bool
 }
 
 func NewUpdateMessage(
 	pas []bgptype.PathAttribute,
 	nlri []*net.IPNet,
 	wr []*net.IPNet) (*UpdateMessage, error) {
-	paLen := uint16(0)
-	for _, pa := range pas {
-		l := pa.BytesLen()
-		paLen += l
-	}
-	nlriLen := uint16(0)
-	for _, n := range nlri {
-		l, err := NetByteLen(n)
-		if err != nil {
-			return nil, err
-		}
-		nlriLen += l
+	return newUpdateMessage(pas, nlri, nil, wr, nil, false)
+}
+
+// ADD-PATHが有効なPeerに送信するUpdateMessageを作成する
+// nlriIDs, wrIDsはnlri, wrと同じ長さである必要がある
+func NewAddPathUpdateMessage(
+	pas []bgptype.PathAttribute,
+	nlri []*net.IPNet,
+	nlriIDs []uint32,
+	wr []*net.IPNet,
+	wrIDs []uint32) (*UpdateMessage, error) {
+	if len(nlri) != len(nlriIDs) || len(wr) != len(wrIDs) {
+		return nil, fmt.Errorf("経路とPath Identifierの数が一致しません。")
 	}
-	wrLen := uint16(0)
-	for _, w := range wr {
-		l, err := NetByteLen(w)
-		if err != nil {
-			return nil, err
+	return newUpdateMessage(pas, nlri, nlriIDs, wr, wrIDs, true)
+}
+
+// RFC4271 4.で定められているMessageの最大長
+// 超える経路はUpdateMessageを分けて送信する
+const MAX_MESSAGE_LENGTH = 4096
+
+// UpdateMessageの経路の部分を除いた長さ
+// +4はpath_attribute_length(u16)とwithdrawn_routes_length(u16)のbytes表現分
+func UpdateMessageOverhead(pas []bgptype.PathAttribute) int {
+	return HEADER_LENGTH + 4 + len(bgptype.PathAttributesToBytes(pas))
+}
+
+// UpdateMessageの中で経路が占めるバイト数
+// addPathがtrueの場合はPath Identifierの4byteを含む
+func RouteByteLen(n *net.IPNet, addPath bool) (int, error) {
+	l, err := NetByteLen(n)
+	if err != nil {
+		return 0, err
+	}
+	if addPath {
+		return int(l) + 4, nil
+	}
+	return int(l), nil
+}
+
+func newUpdateMessage(
+	pas []bgptype.PathAttribute,
+	nlri []*net.IPNet,
+	nlriIDs []uint32,
+	wr []*net.IPNet,
+	wrIDs []uint32,
+	addPath bool) (*UpdateMessage, error) {
+	// AsSequenceとAsSetは1つのAS_PATH属性にまとめるため、
+	// 各PathAttributeのBytesLenの合計とは一致しない場合がある
+	paLen := len(bgptype.PathAttributesToBytes(pas))
+	// uint16のLengthが桁あふれしないように、intで数えてから最大の長さと比べる
+	routesLen := func(ns []*net.IPNet) (int, error) {
+		sum := 0
+		for _, n := range ns {
+			l, err := RouteByteLen(n, addPath)
+			if err != nil {
+				return 0, err
+			}
+			sum += l
 		}
-		wrLen += l
-	}
-	hMinLen := uint16(19)
-	h := NewHeader(
-		// +4はpath_attribute_length(u16)と
-		// withdrawn_routes_length(u16)のbytes表現分
-		hMinLen+paLen+nlriLen+wrLen+4,
-		Update,
-	)
+		return sum, nil
+	}
+	nlriLen, err := routesLen(nlri)
+	if err != nil {
+		return nil, err
+	}
+	wrLen, err := routesLen(wr)
+	if err != nil {
+		return nil, err
+	}
+	// +4はpath_attribute_length(u16)と
+	// withdrawn_routes_length(u16)のbytes表現分
+	total := HEADER_LENGTH + paLen + nlriLen + wrLen + 4
+	if total > MAX_MESSAGE_LENGTH {
+		return nil, fmt.Errorf(
+			"UpdateMessageが最大の長さを超えています。最大: %d, Length: %d",
+			MAX_MESSAGE_LENGTH, total,
+		)
+	}
+	h := NewHeader(uint16(total), Update)
 	return &UpdateMessage{
 		Header:                              *h,
 		WithdrawnRoutes:                     wr,
-		withdrawnRouteLen:                   wrLen,
+		withdrawnRouteLen:                   uint16(wrLen),
 		PathAttributes:                      pas,
-		pathAttributeLen:                    paLen,
+		pathAttributeLen:                    uint16(paLen),
 		NetworkLayerReachabilityInformation: nlri,
+		AddPath:                             addPath,
+		WithdrawnPathIDs:                    wrIDs,
+		NLRIPathIDs:                         nlriIDs,
 	}, nil
 }
 
 func (u *UpdateMessage) Show() string {
-	var pas string
-	for _, pa := range u.PathAttributes {
-		pas += fmt.Sprintf("%v", pa.ToBytes())
-	}
+	pas := bgptype.PathAttributes(u.PathAttributes).String()
 	return fmt.Sprintf(
 		"Header: %v, WithdrawnRoutes: %v, WithdrawnRoutesLen: %v, PathAttributes: %v, pathAttributeLen: %v, NLRI: %v",
 		u.Header,
@@ -93,8 +155,8 @@ func (u *UpdateMessage) ToBytes() ([]byte, error) {
 	wrLen[1] = byte(u.withdrawnRouteLen)
 	b = append(b, wrLen...)
 	// withdrawn_routes
-	for _, wr := range u.WithdrawnRoutes {
-		wrBytes, err := IPNetToBytes(wr)
+	for i, wr := range u.WithdrawnRoutes {
+		wrBytes, err := u.routeToBytes(wr, u.WithdrawnPathIDs, i)
 		if err != nil {
 			return nil, err
 		}
@@ -106,13 +168,10 @@ func (u *UpdateMessage) ToBytes() ([]byte, error) {
 	paLen[1] = byte(u.pathAttributeLen)
 	b = append(b, paLen...)
 	// path_attributes
-	for _, pa := range u.PathAttributes {
-		paBytes := pa.ToBytes()
-		b = append(b, paBytes...)
-	}
+	b = append(b, u.pathAttributesToBytes()...)
 	// NLRI
-	for _, nlri := range u.NetworkLayerReachabilityInformation {
-		nlriBytes, err := IPNetToBytes(nlri)
+	for i, nlri := range u.NetworkLayerReachabilityInformation {
+		nlriBytes, err := u.routeToBytes(nlri, u.NLRIPathIDs, i)
 		if err != nil {
 			return nil, err
 		}
@@ -121,6 +180,33 @@ func (u *UpdateMessage) ToBytes() ([]byte, error) {
 	return b, nil
 }
 
+func (u *UpdateMessage) pathAttributesToBytes() []byte {
+	if u.AS4 {
+		return bgptype.PathAttributesToBytesAS4(u.PathAttributes)
+	}
+	return bgptype.PathAttributesToBytes(u.PathAttributes)
+}
+
+func (u *UpdateMessage) routeToBytes(n *net.IPNet, ids []uint32, i int) ([]byte, error) {
+	if !u.AddPath {
+		return IPNetToBytes(n)
+	}
+	if len(ids) <= i {
+		return nil, fmt.Errorf("Path Identifierがありません。Route: %v", n)
+	}
+	return IPNetWithPathIDToBytes(n, ids[i])
+}
+
+func (u *UpdateMessage) bytesToRoutes(b []byte) ([]*net.IPNet, []uint32, error) {
+	if !u.AddPath {
+		nets, err := BytesToIPNets(b)
+		return nets, nil, err
+	}
+	return BytesToIPNetsWithPathID(b)
+}
+
+// u.AddPathがtrueの場合は、経路にPath Identifierが付いているものとして変換する
+// u.AS4がtrueの場合は、AS番号が4byteでエンコードされているものとして変換する
 func (u *UpdateMessage) ToMessage(b []byte) error {
 	// header
 	h := Header{}
@@ -132,7 +218,7 @@ func (u *UpdateMessage) ToMessage(b []byte) error {
 	// WITHDRAWN ROUTES
 	wrEnd := 21 + wrLen
 	wrBytes := b[21:wrEnd]
-	wrs, err := BytesToIPNets(wrBytes)
+	wrs, wrIDs, err := u.bytesToRoutes(wrBytes)
 	if err != nil {
 		return err
 	}
@@ -142,14 +228,19 @@ func (u *UpdateMessage) ToMessage(b []byte) error {
 	paStart := wrEnd + 2
 	paEnd := paStart + paLen
 	paBytes := b[paStart:paEnd]
-	pas, err := bgptype.BytesToPathAttributes(paBytes)
+	var pas []bgptype.PathAttribute
+	if u.AS4 {
+		pas, err = bgptype.BytesToPathAttributesAS4(paBytes)
+	} else {
+		pas, err = bgptype.BytesToPathAttributes(paBytes)
+	}
 	if err != nil {
 		return err
 	}
 	// NLRI
 	nlriStart := paEnd
 	nlriBytes := b[nlriStart:]
-	nlris, err := BytesToIPNets(nlriBytes)
+	nlris, nlriIDs, err := u.bytesToRoutes(nlriBytes)
 	if err != nil {
 		return err
 	}
@@ -160,6 +251,8 @@ func (u *UpdateMessage) ToMessage(b []byte) error {
 	u.PathAttributes = pas
 	u.pathAttributeLen = paLen
 	u.NetworkLayerReachabilityInformation = nlris
+	u.WithdrawnPathIDs = wrIDs
+	u.NLRIPathIDs = nlriIDs
 	return nil
 }
 
@@ -218,6 +311,40 @@ func IPNetToBytes(n *net.IPNet) ([]byte, error) {
 	return append([]byte{prefixLen}, byteNw...), nil
 }
 
+// Path Identifier付きの経路を[]byteに変換する (RFC7911 3.)
+// {Path Identifier(4byte), Prefix長, ネットワークアドレス}
+func IPNetWithPathIDToBytes(n *net.IPNet, id uint32) ([]byte, error) {
+	b, err := IPNetToBytes(n)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{byte(id >> 24), byte(id >> 16), byte(id >> 8), byte(id)}, b...), nil
+}
+
+// Path Identifier付きの経路のバイト列を[]*net.IPNetとPath Identifierに変換する
+func BytesToIPNetsWithPathID(b []byte) ([]*net.IPNet, []uint32, error) {
+	nets := make([]*net.IPNet, 0)
+	ids := make([]uint32, 0)
+	for i := 0; i < len(b); {
+		if len(b) < i+5 {
+			return nil, nil, fmt.Errorf("Path Identifier付きの経路の長さが不正です。")
+		}
+		id := uint32(b[i])<<24 | uint32(b[i+1])<<16 | uint32(b[i+2])<<8 | uint32(b[i+3])
+		l := 1 + (int(b[i+4])+7)/8
+		if len(b) < i+4+l {
+			return nil, nil, fmt.Errorf("Path Identifier付きの経路の長さが不正です。")
+		}
+		ns, err := BytesToIPNets(b[i+4 : i+4+l])
+		if err != nil {
+			return nil, nil, err
+		}
+		nets = append(nets, ns...)
+		ids = append(ids, id)
+		i += 4 + l
+	}
+	return nets, ids, nil
+}
+
 // []byteを[]*net.IPNetに変換する
 // 可変長のバイト列から、複数のプレフィックス長とネットワークアドレスを取得する
 func BytesToIPNets(b []byte) ([]*net.IPNet, error) {
@@ -0,0 +1,231 @@
+package packets
+
+import (
+	"fmt"
+)
+
+// Messageのバイト列が不正な位置と理由
+// CodeとSubcodeは、Peerに送るNotificationMessageのError codeとError subcode
+type FormatError struct {
+	// Messageの先頭からのオフセット
+	Offset  int
+	Reason  string
+	Code    ErrorCode
+	Subcode uint8
+}
+
+func (e *FormatError) Error() string {
+	return fmt.Sprintf("オフセット%dが不正です。%s", e.Offset, e.Reason)
+}
+
+// 不正な位置で送るNotificationMessageを作成する
+func (e *FormatError) Notification() *NotificationMessage {
+	return NewNotificationMessage(e.Code, e.Subcode, nil)
+}
+
+func formatError(code ErrorCode, subcode uint8, offset int, format string, a ...any) *FormatError {
+	return &FormatError{Offset: offset, Reason: fmt.Sprintf(format, a...), Code: code, Subcode: subcode}
+}
+
+// bufの先頭から1つのBGP Messageを切り出す
+// HeaderのLengthまでのバイト列をmsg、残りをrestとして返す。
+// Messageのバイト列がまだ揃っていない場合は、msgをnilにしてbufをそのままrestとして返す。
+// Markerがすべて1でないか、LengthがHeaderより短い場合は*FormatErrorを返す。
+func SplitMessage(buf []byte) (msg, rest []byte, err error) {
+	for i := 0; i < len(buf) && i < 16; i++ {
+		if buf[i] != 0xff {
+			return nil, buf, formatError(MessageHeaderError, ConnectionNotSynchronized, i, "Markerがすべて1ではありません。")
+		}
+	}
+	if len(buf) < HEADER_LENGTH {
+		return nil, buf, nil
+	}
+	l := int(buf[16])<<8 | int(buf[17])
+	if l < HEADER_LENGTH {
+		return nil, buf, formatError(MessageHeaderError, BadMessageLength, 16, "LengthがHeaderの長さより短いです。Length: %d", l)
+	}
+	if len(buf) < l {
+		return nil, buf, nil
+	}
+	return buf[:l], buf[l:], nil
+}
+
+// Messageのバイト列の構造を検査し、最初に見つけた不正な位置を*FormatErrorで返す
+// BytesToMessageは変換する前にこの検査を行い、範囲外のバイトを読まないようにする。
+// 値の意味までは検査しないため、nilを返してもMessageに変換できるとは限らない。
+func ValidateMessage(b []byte, opts DecodeOptions) error {
+	msg, rest, err := SplitMessage(b)
+	if err != nil {
+		return err
+	}
+	if msg == nil {
+		return formatError(MessageHeaderError, BadMessageLength, len(b), "Messageが途中で終わっています。")
+	}
+	if len(rest) > 0 {
+		return formatError(MessageHeaderError, BadMessageLength, 16, "LengthがMessageの長さと一致しません。Length: %d, Bytes: %d", len(msg), len(b))
+	}
+	switch MessageType(b[18]) {
+	case Open:
+		return validateOpen(b)
+	case Update:
+		return validateUpdate(b, opts)
+	case Notification:
+		if len(b) < NOTIFICATION_MESSAGE_MIN_LENGTH {
+			return formatError(MessageHeaderError, BadMessageLength, 16, "NotificationMessageの最小の長さより短いです。Length: %d", len(b))
+		}
+	case Keepalive:
+		if len(b) != KEEPALIVE_MESSAGE_LENGTH {
+			return formatError(MessageHeaderError, BadMessageLength, 16, "KeepaliveMessageの長さが不正です。Length: %d", len(b))
+		}
+	default:
+		return formatError(MessageHeaderError, BadMessageType, 18, "未知のMessageTypeです。Type: %d", b[18])
+	}
+	return nil
+}
+
+func validateOpen(b []byte) error {
+	if len(b) < OPEN_MESSAGE_LENGTH {
+		return formatError(MessageHeaderError, BadMessageLength, 16, "OpenMessageの最小の長さより短いです。Length: %d", len(b))
+	}
+	if int(b[28]) != len(b)-OPEN_MESSAGE_LENGTH {
+		return formatError(OpenMessageError, 0, 28, "Optional Parameters Lengthが不正です。Length: %d", b[28])
+	}
+	for i := OPEN_MESSAGE_LENGTH; i < len(b); {
+		if len(b) < i+2 {
+			return formatError(OpenMessageError, 0, i, "Optional Parameterが途中で終わっています。")
+		}
+		end := i + 2 + int(b[i+1])
+		if len(b) < end {
+			return formatError(OpenMessageError, 0, i+1, "Optional Parameterの長さが不正です。Length: %d", b[i+1])
+		}
+		if b[i] == CAPABILITIES_OPTIONAL_PARAMETER {
+			for j := i + 2; j < end; {
+				if end < j+2 {
+					return formatError(OpenMessageError, 0, j, "Capabilityが途中で終わっています。")
+				}
+				if end < j+2+int(b[j+1]) {
+					return formatError(OpenMessageError, 0, j+1, "Capabilityの長さが不正です。Length: %d", b[j+1])
+				}
+				j += 2 + int(b[j+1])
+			}
+		}
+		i = end
+	}
+	return nil
+}
+
+func validateUpdate(b []byte, opts DecodeOptions) error {
+	if len(b) < HEADER_LENGTH+4 {
+		return formatError(MessageHeaderError, BadMessageLength, 16, "UpdateMessageの最小の長さより短いです。Length: %d", len(b))
+	}
+	wrStart := HEADER_LENGTH + 2
+	wrEnd := wrStart + (int(b[19])<<8 | int(b[20]))
+	if len(b) < wrEnd+2 {
+		return formatError(UpdateMessageError, MalformedAttributeList, 19, "Withdrawn Routes Lengthが不正です。Length: %d", wrEnd-wrStart)
+	}
+	if err := validateRoutes(b, wrStart, wrEnd, opts.AddPath); err != nil {
+		return err
+	}
+	paStart := wrEnd + 2
+	paEnd := paStart + (int(b[wrEnd])<<8 | int(b[wrEnd+1]))
+	if len(b) < paEnd {
+		return formatError(UpdateMessageError, MalformedAttributeList, wrEnd, "Total Path Attribute Lengthが不正です。Length: %d", paEnd-paStart)
+	}
+	if err := validatePathAttributes(b, paStart, paEnd, opts.AS4); err != nil {
+		return err
+	}
+	return validateRoutes(b, paEnd, len(b), opts.AddPath)
+}
+
+// b[start:end]に並んだ経路を検査する
+func validateRoutes(b []byte, start, end int, addPath bool) error {
+	for i := start; i < end; {
+		if addPath {
+			if end < i+4 {
+				return formatError(UpdateMessageError, InvalidNetworkField, i, "Path Identifierが途中で終わっています。")
+			}
+			i += 4
+		}
+		if end <= i {
+			return formatError(UpdateMessageError, InvalidNetworkField, i, "経路が途中で終わっています。")
+		}
+		ones := int(b[i])
+		if ones > 32 {
+			return formatError(UpdateMessageError, InvalidNetworkField, i, "プレフィックス長が不正です。Prefix Length: %d", ones)
+		}
+		if end < i+1+(ones+7)/8 {
+			return formatError(UpdateMessageError, InvalidNetworkField, i, "プレフィックスが途中で終わっています。Prefix Length: %d", ones)
+		}
+		i += 1 + (ones+7)/8
+	}
+	return nil
+}
+
+// b[start:end]に並んだPathAttributeを検査する
+func validatePathAttributes(b []byte, start, end int, as4 bool) error {
+	for i := start; i < end; {
+		if end < i+3 {
+			return formatError(UpdateMessageError, MalformedAttributeList, i, "PathAttributeが途中で終わっています。")
+		}
+		flags, code := b[i], b[i+1]
+		lenAt, vStart := i+2, i+3
+		l := int(b[i+2])
+		if flags&0b00010000 != 0 {
+			if end < i+4 {
+				return formatError(UpdateMessageError, MalformedAttributeList, i, "PathAttributeが途中で終わっています。")
+			}
+			l = int(b[i+2])<<8 | int(b[i+3])
+			vStart = i + 4
+		}
+		vEnd := vStart + l
+		if end < vEnd {
+			return formatError(UpdateMessageError, AttributeLengthError, lenAt, "Attribute Lengthが不正です。Type: %d, Length: %d", code, l)
+		}
+		want := -1
+		switch code {
+		case 1:
+			want = 1
+			if l == 1 && b[vStart] > 2 {
+				return formatError(UpdateMessageError, InvalidOriginAttribute, vStart, "ORIGINの値が不正です。Value: %d", b[vStart])
+			}
+		case 2:
+			if err := validateAsPath(b, vStart, vEnd, as4); err != nil {
+				return err
+			}
+		case 3:
+			want = 4
+		case 6:
+			want = 0
+		case 7:
+			want = 6
+			if as4 {
+				want = 8
+			}
+		}
+		if want >= 0 && l != want {
+			return formatError(UpdateMessageError, AttributeLengthError, lenAt, "Attribute Lengthが不正です。Type: %d, Length: %d", code, l)
+		}
+		i = vEnd
+	}
+	return nil
+}
+
+func validateAsPath(b []byte, start, end int, as4 bool) error {
+	size := 2
+	if as4 {
+		size = 4
+	}
+	for i := start; i < end; {
+		if end < i+2 {
+			return formatError(UpdateMessageError, MalformedASPath, i, "AS_PATHのセグメントが途中で終わっています。")
+		}
+		if b[i] != 1 && b[i] != 2 {
+			return formatError(UpdateMessageError, MalformedASPath, i, "AS_PATHのSegment Typeが不正です。Type: %d", b[i])
+		}
+		if end < i+2+int(b[i+1])*size {
+			return formatError(UpdateMessageError, MalformedASPath, i+1, "AS_PATHのSegment Lengthが不正です。Length: %d", b[i+1])
+		}
+		i += 2 + int(b[i+1])*size
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package peer
+
+import (
+	"net"
+
+	"github.com/SotaUeda/gobgp/bgptype"
+)
+
+// 集約した経路を作り直す
+// 前回から最適経路が変わったプレフィックスを含む集約した経路だけを評価し直す。
+// 集約元の経路(Prefixに含まれるより長いプレフィックスの最適経路)がなくなった場合は
+// 集約した経路を削除する。
+// lr.muをロックした状態で呼び出す
+func (lr *LocRib) updateAggregates() {
+	if lr.aggregateRoutes == nil {
+		lr.aggregateRoutes = make(map[string]*RibEntry)
+	}
+	if lr.aggregateContributors == nil {
+		lr.aggregateContributors = make(map[string]map[string]*RibEntry)
+	}
+	// 集約した経路を変えると、それを含むより短い集約の最適経路も変わるため、変わらなくなるまで繰り返す
+	for len(lr.aggregateDirty) > 0 {
+		dirty := lr.aggregateDirty
+		lr.aggregateDirty = nil
+		for _, ag := range lr.aggregates {
+			if lr.updateContributors(ag, dirty) {
+				lr.updateAggregate(ag)
+			}
+		}
+	}
+}
+
+// dirtyのうちagに含まれるプレフィックスの集約元の経路を更新し、変わった場合はtrueを返す
+func (lr *LocRib) updateContributors(ag *AggregateConfig, dirty map[string]*net.IPNet) bool {
+	key := ag.Prefix.String()
+	cs := lr.aggregateContributors[key]
+	changed := false
+	for k, nw := range dirty {
+		if !contains(ag.Prefix, nw) {
+			continue
+		}
+		best := lr.best[k]
+		if best == nil || lr.isAggregate(best) {
+			if _, ok := cs[k]; ok {
+				delete(cs, k)
+				changed = true
+			}
+			continue
+		}
+		if cs == nil {
+			cs = make(map[string]*RibEntry)
+			lr.aggregateContributors[key] = cs
+		}
+		if cs[k] != best {
+			cs[k] = best
+			changed = true
+		}
+	}
+	return changed
+}
+
+// agの集約した経路を、集約元の経路から作り直す
+func (lr *LocRib) updateAggregate(ag *AggregateConfig) {
+	key := ag.Prefix.String()
+	cur := lr.aggregateRoutes[key]
+	cs := lr.contributors(ag.Prefix)
+	if len(cs) == 0 {
+		if cur != nil {
+			lr.removePath(cur)
+			delete(lr.aggregateRoutes, key)
+			lr.updateBestPath(key)
+		}
+		return
+	}
+	pas := lr.aggregatePathAttributes(ag, cs)
+	if cur != nil && samePathAttributes(cur.attributes(), pas) {
+		return
+	}
+	if cur != nil {
+		lr.removePath(cur)
+	} else if ag.SummaryOnly {
+		// すでに広告した集約元の経路は、Peerに取り消しを送信する
+		for _, c := range cs {
+			lr.markChanged(c.NwAddr)
+		}
+	}
+	re := NewRibEntry(ag.Prefix, pas...)
+	lr.addPath(re)
+	lr.aggregateRoutes[key] = re
+	lr.updateBestPath(key)
+}
+
+// 経路集約の設定を反映する
+// 設定から外れた集約した経路を削除し、集約元の経路をすべての最適経路から選び直す。
+// lr.muをロックした状態で呼び出す
+func (lr *LocRib) setAggregates(ags []*AggregateConfig) {
+	lr.aggregates = ags
+	keys := make(map[string]struct{})
+	for _, ag := range ags {
+		keys[ag.Prefix.String()] = struct{}{}
+	}
+	for key, re := range lr.aggregateRoutes {
+		if _, ok := keys[key]; !ok {
+			lr.removePath(re)
+			delete(lr.aggregateRoutes, key)
+			lr.updateBestPath(key)
+		}
+	}
+	lr.aggregateContributors = make(map[string]map[string]*RibEntry)
+	for _, best := range lr.best {
+		lr.markAggregateDirty(best.NwAddr)
+	}
+}
+
+// 最適経路が変わったプレフィックスを、集約した経路に反映していないものとして記録する
+func (lr *LocRib) markAggregateDirty(nw *net.IPNet) {
+	if len(lr.aggregates) == 0 {
+		return
+	}
+	if lr.aggregateDirty == nil {
+		lr.aggregateDirty = make(map[string]*net.IPNet)
+	}
+	lr.aggregateDirty[nw.String()] = nw
+}
+
+// summary-onlyの集約した経路がある場合に、集約元のプレフィックスを変わったものとして記録する
+// lr.muをロックした状態で呼び出す
+func (lr *LocRib) markSummarizedChanged() {
+	for _, ag := range lr.aggregates {
+		if !ag.SummaryOnly || lr.aggregateRoutes[ag.Prefix.String()] == nil {
+			continue
+		}
+		for _, c := range lr.contributors(ag.Prefix) {
+			lr.markChanged(c.NwAddr)
+		}
+	}
+}
+
+// nwを集約した経路の集約元の経路を返す
+func (lr *LocRib) contributors(nw *net.IPNet) []*RibEntry {
+	cs := []*RibEntry{}
+	for _, c := range lr.aggregateContributors[nw.String()] {
+		cs = append(cs, c)
+	}
+	return cs
+}
+
+// nwがsubより短いプレフィックスで、subを含む場合にtrueを返す
+func contains(nw, sub *net.IPNet) bool {
+	ones, _ := nw.Mask.Size()
+	subOnes, _ := sub.Mask.Size()
+	return subOnes > ones && nw.Contains(sub.IP)
+}
+
+func (lr *LocRib) isAggregate(re *RibEntry) bool {
+	return lr.aggregateRoutes[re.NwAddr.String()] == re
+}
+
+// 集約した経路のPathAttributeを作成する
+// OriginはRFC4271 9.2.2.2に従い、集約元にINCOMPLETEがあればINCOMPLETE、
+// EGPがあればEGP、それ以外はIGPとする。
+// AsSetを含めない場合はAS Pathの情報が失われるため、ATOMIC_AGGREGATEを付ける。
+func (lr *LocRib) aggregatePathAttributes(ag *AggregateConfig, cs []*RibEntry) []bgptype.PathAttribute {
+	o := bgptype.IGP
+	set := bgptype.AsSet{}
+	for _, c := range cs {
+		if co := origin(c); co > o {
+			o = co
+		}
+		for _, pa := range c.attributes() {
+			ap, ok := pa.(bgptype.AsPath)
+			if !ok {
+				continue
+			}
+			for _, as := range ap.Get() {
+				if !set.Contains(as) {
+					set.Add(as)
+				}
+			}
+		}
+	}
+	// AS Pathは自身で生成した経路と同じく、AdjRibOutに送るときに自分のAS番号を追加する
+	seq := bgptype.AsSequence{}
+	nh := bgptype.NextHop(lr.localIP)
+	pas := []bgptype.PathAttribute{&o, &seq}
+	if ag.AsSet && len(set) > 0 {
+		pas = append(pas, &set)
+	}
+	pas = append(pas, &nh)
+	if !ag.AsSet {
+		pas = append(pas, &bgptype.AtomicAggregate{})
+	}
+	pas = append(pas, &bgptype.Aggregator{AS: lr.LocalASNum, Address: lr.localIP})
+	return pas
+}
+
+// summary-onlyの集約した経路がある場合に、その集約元の経路であればtrueを返す
+// このような経路はAdjRibOutに送らない
+// lr.muをロックした状態で呼び出す
+func (lr *LocRib) isSummarized(re *RibEntry) bool {
+	if lr.isAggregate(re) {
+		return false
+	}
+	for _, ag := range lr.aggregates {
+		if !ag.SummaryOnly || lr.aggregateRoutes[ag.Prefix.String()] == nil {
+			continue
+		}
+		if contains(ag.Prefix, re.NwAddr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,200 @@
+package peer
+
+import (
+	"net"
+	"testing"
+
+	"github.com/SotaUeda/gobgp/bgptype"
+)
+
+func newAggregateLocRib(ag *AggregateConfig) *LocRib {
+	return &LocRib{
+		Rib:        NewRib(),
+		LocalASNum: 64512,
+		localIP:    net.ParseIP("10.200.100.3").To4(),
+		aggregates: []*AggregateConfig{ag},
+	}
+}
+
+// 集約元の経路がある間だけ集約した経路が生成されることを確認するテスト
+func TestLocRibAggregate(t *testing.T) {
+	_, agNw, _ := net.ParseCIDR("10.0.0.0/16")
+	_, nw1, _ := net.ParseCIDR("10.0.1.0/24")
+	_, nw2, _ := net.ParseCIDR("10.0.2.0/24")
+	lr := newAggregateLocRib(&AggregateConfig{Prefix: agNw})
+	lr.updateAggregates()
+	if len(lr.Rib.Lookup(agNw)) != 0 {
+		t.Errorf("aggregate should not exist without contributors")
+	}
+
+	installPaths(t, lr, nw1, map[string][]bgptype.AutonomousSystemNumber{"10.0.0.1": {65001}})
+	installPaths(t, lr, nw2, map[string][]bgptype.AutonomousSystemNumber{"10.0.0.2": {65002, 65003}})
+	ags := lr.Rib.Lookup(agNw)
+	if len(ags) != 1 {
+		t.Fatalf("Want: 1, Got: %d", len(ags))
+	}
+	var atomic, aggregator bool
+	for _, pa := range ags[0].GetPathAttributes() {
+		switch a := pa.(type) {
+		case *bgptype.AtomicAggregate:
+			atomic = true
+		case *bgptype.Aggregator:
+			aggregator = a.AS == 64512 && a.Address.Equal(lr.localIP)
+		}
+	}
+	if !atomic || !aggregator {
+		t.Errorf("Want: ATOMIC_AGGREGATE and AGGREGATOR, Got: %v %v", atomic, aggregator)
+	}
+
+	// 集約元の経路がすべてなくなると、集約した経路も削除される
+	lr.mu.Lock()
+	for _, nw := range []*net.IPNet{nw1, nw2} {
+		for _, p := range lr.paths[nw.String()] {
+			lr.removePath(p)
+		}
+		lr.updateBestPath(nw.String())
+	}
+	lr.updateAggregates()
+	lr.mu.Unlock()
+	if len(lr.Rib.Lookup(agNw)) != 0 {
+		t.Errorf("aggregate should be removed")
+	}
+}
+
+// 最適経路が変わったプレフィックスを含む集約だけが作り直され、
+// 設定から外した集約の経路は削除されることを確認するテスト
+func TestLocRibUpdatesOnlyAffectedAggregates(t *testing.T) {
+	_, agNw1, _ := net.ParseCIDR("10.0.0.0/16")
+	_, agNw2, _ := net.ParseCIDR("10.1.0.0/16")
+	_, nw1, _ := net.ParseCIDR("10.0.1.0/24")
+	_, nw2, _ := net.ParseCIDR("10.0.2.0/24")
+	_, nw3, _ := net.ParseCIDR("10.1.1.0/24")
+	ag1 := &AggregateConfig{Prefix: agNw1, AsSet: true}
+	lr := newAggregateLocRib(ag1)
+	lr.aggregates = append(lr.aggregates, &AggregateConfig{Prefix: agNw2, AsSet: true})
+	installPaths(t, lr, nw1, map[string][]bgptype.AutonomousSystemNumber{"10.0.0.1": {65001}})
+	installPaths(t, lr, nw3, map[string][]bgptype.AutonomousSystemNumber{"10.0.0.3": {65003}})
+	ag2Route := lr.aggregateRoutes[agNw2.String()]
+	if ag2Route == nil {
+		t.Fatalf("Want: aggregate %v, Got: nil", agNw2)
+	}
+
+	installPaths(t, lr, nw2, map[string][]bgptype.AutonomousSystemNumber{"10.0.0.2": {65002}})
+	if got := len(lr.contributors(agNw1)); got != 2 {
+		t.Errorf("Want: %v, Got: %v", 2, got)
+	}
+	if got := lr.aggregateRoutes[agNw2.String()]; got != ag2Route {
+		t.Errorf("aggregate %v should not be rebuilt", agNw2)
+	}
+	if len(lr.aggregateDirty) != 0 {
+		t.Errorf("Want: %v, Got: %v", 0, len(lr.aggregateDirty))
+	}
+
+	lr.mu.Lock()
+	lr.setAggregates([]*AggregateConfig{ag1})
+	lr.updateAggregates()
+	lr.mu.Unlock()
+	if len(lr.Rib.Lookup(agNw2)) != 0 {
+		t.Errorf("aggregate %v should be removed", agNw2)
+	}
+	if len(lr.Rib.Lookup(agNw1)) != 1 {
+		t.Errorf("Want: 1, Got: %d", len(lr.Rib.Lookup(agNw1)))
+	}
+}
+
+// as-setを指定した場合に、集約元の経路のASがAsSetに含まれることを確認するテスト
+func TestLocRibAggregateAsSet(t *testing.T) {
+	_, agNw, _ := net.ParseCIDR("10.0.0.0/16")
+	_, nw1, _ := net.ParseCIDR("10.0.1.0/24")
+	_, nw2, _ := net.ParseCIDR("10.0.2.0/24")
+	lr := newAggregateLocRib(&AggregateConfig{Prefix: agNw, AsSet: true})
+	installPaths(t, lr, nw1, map[string][]bgptype.AutonomousSystemNumber{"10.0.0.1": {65001}})
+	installPaths(t, lr, nw2, map[string][]bgptype.AutonomousSystemNumber{"10.0.0.2": {65002, 65003}})
+	ags := lr.Rib.Lookup(agNw)
+	if len(ags) != 1 {
+		t.Fatalf("Want: 1, Got: %d", len(ags))
+	}
+	var set *bgptype.AsSet
+	for _, pa := range ags[0].GetPathAttributes() {
+		switch a := pa.(type) {
+		case *bgptype.AsSet:
+			set = a
+		case *bgptype.AtomicAggregate:
+			// AsSetでAS Pathの情報を保持しているため付けない
+			t.Errorf("ATOMIC_AGGREGATE should not be set with as-set")
+		}
+	}
+	if set == nil {
+		t.Fatalf("Want: AsSet, Got: nil")
+	}
+	want := []bgptype.AutonomousSystemNumber{65001, 65002, 65003}
+	got := set.Get()
+	if len(got) != len(want) {
+		t.Fatalf("Want: %v, Got: %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Want: %v, Got: %v", want, got)
+		}
+	}
+}
+
+// summary-onlyの場合に、集約元の経路がAdjRibOutに送られないことを確認するテスト
+func TestAdjRibOutSummaryOnly(t *testing.T) {
+	_, agNw, _ := net.ParseCIDR("10.0.0.0/16")
+	_, nw1, _ := net.ParseCIDR("10.0.1.0/24")
+	_, other, _ := net.ParseCIDR("172.16.0.0/16")
+	for _, summaryOnly := range []bool{false, true} {
+		lr := newAggregateLocRib(&AggregateConfig{Prefix: agNw, SummaryOnly: summaryOnly})
+		installPaths(t, lr, nw1, map[string][]bgptype.AutonomousSystemNumber{"10.0.0.1": {65001}})
+		installPaths(t, lr, other, map[string][]bgptype.AutonomousSystemNumber{"10.0.0.1": {65001}})
+		config, _ := ParseConfig("64512 10.200.100.3 65010 10.200.100.2 passive")
+		aro := NewAdjRibOut(NewRib())
+		aro.InstallFromLocRib(lr, config)
+		if len(aro.Rib.Lookup(agNw)) != 1 || len(aro.Rib.Lookup(other)) != 1 {
+			t.Errorf("summaryOnly: %v, aggregate and unrelated route should be advertised", summaryOnly)
+		}
+		if got := len(aro.Rib.Lookup(nw1)) == 1; got == summaryOnly {
+			t.Errorf("summaryOnly: %v, Want: %v, Got: %v", summaryOnly, !summaryOnly, got)
+		}
+	}
+}
+
+// summary-onlyの集約を設定すると、すでに広告した集約元の経路を取り消すことを確認するテスト
+func TestAdjRibOutSummaryOnlyWithdrawsAdvertisedContributors(t *testing.T) {
+	_, agNw, _ := net.ParseCIDR("10.0.0.0/16")
+	_, nw1, _ := net.ParseCIDR("10.0.1.0/24")
+	lrConfig, _ := ParseConfig("64512 10.200.100.3 65010 10.200.100.2 passive")
+	lr := &LocRib{Rib: NewRib()}
+	if err := lr.Reconfigure(lrConfig); err != nil {
+		t.Fatal(err)
+	}
+	config, _ := ParseConfig("64512 10.200.100.3 65010 10.200.100.2 passive")
+	p := NewPeer(config, lr)
+	lr.register(p)
+	installPaths(t, lr, nw1, map[string][]bgptype.AutonomousSystemNumber{"10.0.0.1": {65001}})
+	aro := NewAdjRibOut(NewRib())
+	aro.InstallPrefixes(lr, config, p.takeLocRibChanges())
+	if _, err := aro.ToUpdateMessages(config.LocalIP, config.LocalAS, false); err != nil {
+		t.Fatal(err)
+	}
+	if len(aro.Rib.Lookup(nw1)) != 1 {
+		t.Fatalf("Want: 1, Got: %d", len(aro.Rib.Lookup(nw1)))
+	}
+
+	lrConfig.Aggregates = []*AggregateConfig{{Prefix: agNw, SummaryOnly: true}}
+	if err := lr.Reconfigure(lrConfig); err != nil {
+		t.Fatal(err)
+	}
+	aro.InstallPrefixes(lr, config, p.takeLocRibChanges())
+	if len(aro.Rib.Lookup(nw1)) != 0 || len(aro.Rib.Lookup(agNw)) != 1 {
+		t.Errorf("Want: only aggregate, Got: %v", aro.Rib.Routes())
+	}
+	ums, err := aro.ToUpdateMessages(config.LocalIP, config.LocalAS, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ums) != 2 || len(ums[0].WithdrawnRoutes) != 1 || ums[0].WithdrawnRoutes[0].String() != nw1.String() {
+		t.Errorf("Want: withdrawal of %v and aggregate, Got: %v", nw1, ums)
+	}
+}
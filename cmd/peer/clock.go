@@ -0,0 +1,103 @@
+package peer
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Peerのタイマーで使う時計
+// テストでは時刻を進められるFakeClockを使い、実際に待たずにタイマーを満了させる。
+type Clock interface {
+	Now() time.Time
+	// dが経過した後に、別のgoroutineでfを呼び出す
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// AfterFuncで設定したタイマー
+type Timer interface {
+	// タイマーを止める。既に満了しているか止めていた場合はfalseを返す
+	Stop() bool
+}
+
+// timeパッケージの時刻とタイマーを使う時計
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+// Advanceを呼び出したときだけ時刻が進む時計
+type FakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	clock *FakeClock
+	when  time.Time
+	f     func()
+}
+
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{clock: c, when: c.now.Add(d), f: f}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// 時刻をdだけ進め、満了したタイマーの関数を呼び出す
+// time.AfterFuncと同じく、関数はそれぞれ別のgoroutineで呼び出すため、呼び出される順序は決まらない。
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	end := c.now.Add(d)
+	sort.SliceStable(c.timers, func(i, j int) bool { return c.timers[i].when.Before(c.timers[j].when) })
+	n := 0
+	for _, t := range c.timers {
+		if t.when.After(end) {
+			break
+		}
+		c.now = t.when
+		go t.f()
+		n++
+	}
+	c.timers = c.timers[n:]
+	c.now = end
+}
+
+// 満了していないタイマーの数
+func (c *FakeClock) Timers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+func (t *fakeTimer) Stop() bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, ct := range c.timers {
+		if ct == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -3,10 +3,15 @@ package peer
 import (
 	"fmt"
 	"net"
+	"reflect"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/SotaUeda/gobgp/bgptype"
+	"github.com/SotaUeda/gobgp/fib"
+	"github.com/SotaUeda/gobgp/packets"
+	"github.com/SotaUeda/gobgp/policy"
 )
 
 type Config struct {
@@ -16,7 +21,183 @@ type Config struct {
 	RemoteAS bgptype.AutonomousSystemNumber
 	RemoteIP net.IP
 	Mode     Mode
-	Networks []*net.IPNet
+	// BGP Identifier。nilの場合はLocalIPを使う
+	RouterID net.IP
+	// PassiveモードでListenするアドレスとポート
+	// nil, 0の場合はLocalIPとBGP_PORTを使う
+	ListenAddr net.IP
+	ListenPort int
+	// Activeモードで接続するPeerのポート。0の場合はBGP_PORTを使う
+	RemotePort int
+	// セッションのタイマー
+	Timers Timers
+	// 自身で生成して広告するネットワーク
+	Networks []*NetworkConfig
+	// ルーティングテーブルのルートを再配布する設定
+	// nilの場合は再配布しない
+	Redistribute *RedistributeConfig
+	// 経路集約の設定
+	Aggregates []*AggregateConfig
+	// BGP Multipathの設定
+	// nilの場合は最適経路のみカーネルにインストールする
+	Multipath *MultipathConfig
+	// RPKIキャッシュサーバー(RTR)のアドレス(host:port)
+	// 空の場合は経路を検証しない
+	RPKIServer string
+	// AdjRibIn -> LocRib, LocRib -> AdjRibOut の際に適用するPolicy
+	// nilの場合はすべての経路を受け入れる
+	ImportPolicy *policy.Policy
+	ExportPolicy *policy.Policy
+	// Peerから受信するプレフィックス数の上限
+	// nilの場合は上限を設けない
+	MaxPrefix *MaxPrefixConfig
+	// Route Flap Dampingの設定
+	// nilの場合はDampingしない
+	Damping *DampingConfig
+	// AFI/SAFIごとのADD-PATHの設定
+	AddPath []*AddPathConfig
+}
+
+// セッションのタイマーの設定
+// 0の場合はタイマーを使用しない
+type Timers struct {
+	// OpenMessageで提案するHold Time
+	// Peerが提案したHold Timeと小さい方をセッションで使用する
+	HoldTime time.Duration
+	// KeepaliveMessageを送信する間隔
+	// 0の場合はネゴシエーションしたHold Timeの1/3を使う
+	KeepaliveInterval time.Duration
+	// 接続に失敗したり、セッションが切断されたりした後に再接続するまでの時間
+	ConnectRetryTime time.Duration
+}
+
+func (c *Config) routerID() net.IP {
+	if c.RouterID != nil {
+		return c.RouterID
+	}
+	return c.LocalIP
+}
+
+// iBGPのセッションの場合はtrueを返す
+func (c *Config) isIBGP() bool {
+	return c.RemoteAS == c.LocalAS
+}
+
+// 同じ設定かどうかを返す
+// ConfStrは設定の出どころを表すだけなので比較しない
+func (c *Config) Equal(n *Config) bool {
+	a, b := *c, *n
+	a.ConfStr, b.ConfStr = "", ""
+	return reflect.DeepEqual(a, b)
+}
+
+// 新しい設定を反映するために、セッションを張り直す必要がある場合はtrueを返す
+// PolicyとMaxPrefix、ConnectRetryTime、自身で生成する経路の設定は
+// セッションを維持したまま反映できる。
+func (c *Config) needsRestart(n *Config) bool {
+	return c.LocalAS != n.LocalAS ||
+		c.RemoteAS != n.RemoteAS ||
+		!c.LocalIP.Equal(n.LocalIP) ||
+		!c.RemoteIP.Equal(n.RemoteIP) ||
+		c.Mode != n.Mode ||
+		!c.routerID().Equal(n.routerID()) ||
+		!c.ListenAddr.Equal(n.ListenAddr) ||
+		c.ListenPort != n.ListenPort ||
+		c.RemotePort != n.RemotePort ||
+		c.Timers.HoldTime != n.Timers.HoldTime ||
+		c.Timers.KeepaliveInterval != n.Timers.KeepaliveInterval ||
+		// Dampingの履歴とADD-PATHのネゴシエーション結果は、セッションを張り直さないと変えられない
+		!reflect.DeepEqual(c.Damping, n.Damping) ||
+		!reflect.DeepEqual(c.AddPath, n.AddPath)
+}
+
+// networkステートメントの設定
+type NetworkConfig struct {
+	Prefix *net.IPNet
+	Mode   NetworkMode
+}
+
+type NetworkMode int
+
+const (
+	// ルーティングテーブルに同じプレフィックスのルートがある場合だけ広告する
+	NETWORK_EXACT_MATCH NetworkMode = iota
+	// ルーティングテーブルに関係なく広告する
+	NETWORK_UNCONDITIONAL
+)
+
+// connected / static / kernelのルートを再配布する設定
+type RedistributeConfig struct {
+	// 再配布するルートの生成元
+	Sources []fib.RouteSource
+	// 再配布するルートを絞り込むPolicy
+	// nilの場合はSourcesのルートをすべて再配布する
+	Policy *policy.Policy
+}
+
+func (c *RedistributeConfig) hasSource(src fib.RouteSource) bool {
+	for _, s := range c.Sources {
+		if s == src {
+			return true
+		}
+	}
+	return false
+}
+
+// aggregate-addressの設定
+// Prefixに含まれるより長いプレフィックスの経路が1つでもあれば、Prefixの経路を生成する
+type AggregateConfig struct {
+	Prefix *net.IPNet
+	// 集約元の経路のASをAsSetとしてAS Pathに含める
+	AsSet bool
+	// 集約元の経路を広告せず、集約した経路だけを広告する
+	SummaryOnly bool
+}
+
+// ADD-PATH (RFC7911) の設定
+type AddPathConfig struct {
+	Family packets.Family
+	// Path Identifier付きの経路を受信する
+	Receive bool
+	// Path Identifier付きの経路を送信する
+	Send bool
+	// 送信する経路の選び方
+	SendMode AddPathSendMode
+	// SendModeがADD_PATH_SEND_BEST_Nの場合に送信する経路の数
+	SendMax int
+}
+
+type AddPathSendMode int
+
+const (
+	// 最適経路だけでなく、すべての候補経路を送信する
+	ADD_PATH_SEND_ALL AddPathSendMode = iota
+	// 優先度の高い順にSendMax個の経路を送信する
+	ADD_PATH_SEND_BEST_N
+)
+
+// Familyに対するADD-PATHの設定を返す。設定されていない場合はnilを返す
+func (c *Config) addPathConfig(f packets.Family) *AddPathConfig {
+	for _, ap := range c.AddPath {
+		if ap.Family == f {
+			return ap
+		}
+	}
+	return nil
+}
+
+// Peerごとの受信プレフィックス数の上限設定
+type MaxPrefixConfig struct {
+	// 受信できるプレフィックス数の上限
+	Limit uint32
+	// 上限に対してこの割合(%)を超えた時点で警告のログを出す
+	// 0の場合は警告しない
+	WarningThreshold uint8
+	// trueの場合はセッションを切断せず、上限を超えた経路をログに出して破棄する
+	DropOnly bool
+	// セッションを切断した後、再接続するまでの時間
+	// 0の場合は自動で再接続しない
+	RestartTime time.Duration
 }
 
 type Mode int
@@ -26,7 +207,7 @@ const (
 	Active
 )
 
-func parseMode(s string) (Mode, error) {
+func ParseMode(s string) (Mode, error) {
 	switch s {
 	case "passive":
 		return Passive, nil
@@ -39,6 +220,12 @@ func parseMode(s string) (Mode, error) {
 
 func ParseConfig(s string) (*Config, error) {
 	config := strings.Split(s, " ")
+	if len(config) < 5 {
+		return nil, fmt.Errorf(
+			"config must have at least 5 parts, localAS localIP remoteAS remoteIP mode, and config is %v",
+			s,
+		)
+	}
 	la, err := strconv.ParseUint(config[0], 10, 16)
 	if err != nil {
 		return nil, fmt.Errorf(
@@ -67,14 +254,14 @@ func ParseConfig(s string) (*Config, error) {
 			config[3], s,
 		)
 	}
-	m, err := parseMode(config[4])
+	m, err := ParseMode(config[4])
 	if err != nil {
 		return nil, fmt.Errorf(
 			"cannot parse 5th part of config, %v, as as-number and config is %v",
 			config[4], s,
 		)
 	}
-	nws := []*net.IPNet{}
+	nws := []*NetworkConfig{}
 	if len(config) >= 6 {
 		for num, nw := range config[5:] {
 			_, n, err := net.ParseCIDR(nw)
@@ -84,7 +271,7 @@ func ParseConfig(s string) (*Config, error) {
 					num+5, nw, s,
 				)
 			}
-			nws = append(nws, n)
+			nws = append(nws, &NetworkConfig{Prefix: n, Mode: NETWORK_EXACT_MATCH})
 		}
 	}
 	c := &Config{
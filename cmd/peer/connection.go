@@ -1,8 +1,13 @@
 package peer
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"net"
+	"net/netip"
+	"sync"
+	"time"
 
 	"github.com/SotaUeda/gobgp/packets"
 )
@@ -11,89 +16,379 @@ import (
 // TcpConnectionを張ったり、
 // Messageのデータを送受信したりします。
 type Connection struct {
-	conn *net.TCPConn
+	conn net.Conn
 	buf  []byte // 受信用バッファ
+	log  *slog.Logger
+	// Peerとのネゴシエーション結果に応じたMessageの解釈
+	// 受信するgoroutineとPeerのgoroutineの両方から参照するため排他制御する
+	mu      sync.Mutex
+	options packets.DecodeOptions
+	// 送受信したMessageのバイト列を渡して呼び出す。sentは送信した場合にtrue
+	// 受信したMessageは、Messageに変換できなかった場合も渡す
+	record func(b []byte, sent bool)
+
+	// 送信待ちのMessage
+	// Peerが読み込まなくなってもPeerのgoroutineが止まらないように、書き込みは別のgoroutineで行う。
+	// 両方のPeerが同時にフルルートを送信しても、互いに読み込みを待って止まることがない。
+	sendMu sync.Mutex
+	sendq  [][]byte
+	// sendqにMessageを追加したか、Closeしたときに通知する
+	sendWake chan struct{}
+	// 書き込みに失敗した場合のエラー
+	sendErr error
+	closing bool
+	// 1つのMessageの書き込みを待つ時間。0の場合は待ち続ける
+	writeTimeout time.Duration
+}
+
+// connで送受信するConnectionを作成し、書き込むgoroutineを開始する
+func newConnection(conn net.Conn, log *slog.Logger) *Connection {
+	c := &Connection{conn: conn, log: log, sendWake: make(chan struct{}, 1)}
+	go c.writeLoop()
+	return c
 }
 
 const BGP_PORT = 179 // BGPは179番ポートで固定
 // const BGP_PORT = 8080 // テスト用に8080に変更
 
-func NewConnection(c *Config) (*Connection, error) {
+// Closeした後に、送信待ちのMessageの書き込みを待つ時間
+const CLOSE_TIMEOUT = 3 * time.Second
+
+// tでPeerと接続する。tがnilの場合はTCPで接続する
+// ctxがキャンセルされた場合は、接続や接続の待ち受けをやめてエラーを返す
+func NewConnection(ctx context.Context, t Transport, c *Config) (*Connection, error) {
+	if t == nil {
+		t = TCPTransport{}
+	}
 	var (
-		conn = &net.TCPConn{}
+		conn net.Conn
 		err  error
 	)
 	switch c.Mode {
 	case Active:
-		conn, err = connectRemoteAddress(c)
+		conn, err = connectRemoteAddress(ctx, t, c)
 	case Passive:
-		conn, err = waitRemoteAddress(c)
+		conn, err = waitRemoteAddress(ctx, t, c)
 	default:
 		err = fmt.Errorf("config mode is undefined")
 	}
 	if err != nil {
 		return nil, err
 	}
-	err = conn.SetWriteBuffer(1500)
-	if err != nil {
-		return nil, err
-	}
-	return &Connection{conn, nil}, nil
+	return newConnection(conn, peerLogger(packetLog, c)), nil
 }
 
-func connectRemoteAddress(c *Config) (*net.TCPConn, error) {
+func connectRemoteAddress(ctx context.Context, t Transport, c *Config) (net.Conn, error) {
+	// 複数のPeerに接続できるように、送信元のポートはOSに選ばせる
 	ladd := &net.TCPAddr{
-		IP:   c.LocalIP,
-		Port: BGP_PORT,
+		IP: c.LocalIP,
 	}
 	radd := &net.TCPAddr{
 		IP:   c.RemoteIP,
-		Port: BGP_PORT,
+		Port: portOrDefault(c.RemotePort),
 	}
-	conn, err := net.DialTCP("tcp", ladd, radd)
+	log := peerLogger(fsmLog, c)
+	conn, err := t.Dial(ctx, ladd, radd)
 	if err != nil {
-		fmt.Printf("failed to connect on port %d: %v\n", BGP_PORT, err)
-		return conn, err
+		log.Info("failed to connect", "port", radd.Port, "error", err)
+		return nil, err
 	}
 	// TODO: タイムアウト実装
-	fmt.Print("connected\n")
+	log.Info("connected", "local", conn.LocalAddr().String(), "remote", conn.RemoteAddr().String())
 	return conn, nil
 }
 
-func waitRemoteAddress(c *Config) (*net.TCPConn, error) {
+func waitRemoteAddress(ctx context.Context, t Transport, c *Config) (net.Conn, error) {
 	ladd := &net.TCPAddr{
 		IP:   c.LocalIP,
-		Port: BGP_PORT,
+		Port: portOrDefault(c.ListenPort),
+	}
+	if c.ListenAddr != nil {
+		ladd.IP = c.ListenAddr
 	}
-	listener, err := net.ListenTCP("tcp", ladd)
+	log := peerLogger(fsmLog, c)
+	sl, ch, err := listenShared(t, ladd, c.RemoteIP)
 	if err != nil {
-		fmt.Printf("failed to listen on port %d: %v\n", BGP_PORT, err)
+		log.Warn("failed to listen", "address", ladd.String(), "error", err)
 		return nil, err
 	}
-	conn, err := listener.AcceptTCP()
+	// 再接続時に再びListenできるように、接続を待つPeerがいなくなったらListenerを閉じる
+	defer sl.unregister(c.RemoteIP, ch)
+	select {
+	case conn := <-ch:
+		log.Info("accepted", "local", conn.LocalAddr().String(), "remote", conn.RemoteAddr().String())
+		return conn, nil
+	case <-sl.done:
+		log.Warn("failed to accept", "address", ladd.String(), "error", sl.err)
+		return nil, sl.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// 同じアドレスで待ち受けるPassiveモードのPeerが共有するListener
+// Acceptしたコネクションは、送信元のアドレスをRemoteIPに設定したPeerに渡し、
+// 設定されていないアドレスからのコネクションは閉じる。
+type sharedListener struct {
+	key listenerKey
+	ln  net.Listener
+	// RemoteIPをKeyにした、接続を待っているPeerに渡すチャネル
+	waiters map[string]chan net.Conn
+	// Acceptに失敗して待ち受けをやめたら閉じる
+	done chan struct{}
+	err  error
+}
+
+// TransportとListenするアドレスの組
+// TransportはTCPTransportや*MemoryNetworkのように、比較できる型である必要がある
+type listenerKey struct {
+	t    Transport
+	addr string
+}
+
+// 起動中のPeerが共有するListener
+var sharedListeners = struct {
+	mu sync.Mutex
+	m  map[listenerKey]*sharedListener
+}{m: make(map[listenerKey]*sharedListener)}
+
+// localで待ち受け、remoteからのコネクションを受け取るチャネルを返す
+// 同じアドレスですでに待ち受けている場合は、そのListenerを共有する。
+func listenShared(t Transport, local *net.TCPAddr, remote net.IP) (*sharedListener, chan net.Conn, error) {
+	sharedListeners.mu.Lock()
+	defer sharedListeners.mu.Unlock()
+	key := listenerKey{t, local.String()}
+	sl, ok := sharedListeners.m[key]
+	if !ok {
+		ln, err := t.Listen(local)
+		if err != nil {
+			return nil, nil, err
+		}
+		sl = &sharedListener{
+			key:     key,
+			ln:      ln,
+			waiters: make(map[string]chan net.Conn),
+			done:    make(chan struct{}),
+		}
+		sharedListeners.m[key] = sl
+		go sl.serve()
+	}
+	if _, ok := sl.waiters[remote.String()]; ok {
+		return nil, nil, fmt.Errorf("another peer is waiting for %v on %s", remote, key.addr)
+	}
+	// 受け取る前にunregisterした場合も、serveが止まらないようにバッファを持たせる
+	ch := make(chan net.Conn, 1)
+	sl.waiters[remote.String()] = ch
+	return sl, ch, nil
+}
+
+// remoteの待ち受けをやめる
+// 待っているPeerがいなくなった場合はListenerを閉じる
+func (sl *sharedListener) unregister(remote net.IP, ch chan net.Conn) {
+	sharedListeners.mu.Lock()
+	defer sharedListeners.mu.Unlock()
+	if sl.waiters[remote.String()] == ch {
+		delete(sl.waiters, remote.String())
+	}
+	// 受け取らなかったコネクションは閉じる
+	select {
+	case conn := <-ch:
+		conn.Close()
+	default:
+	}
+	if len(sl.waiters) == 0 && sharedListeners.m[sl.key] == sl {
+		delete(sharedListeners.m, sl.key)
+		sl.ln.Close()
+	}
+}
+
+// Listenerを閉じるまで、Acceptしたコネクションを送信元のPeerに渡す
+func (sl *sharedListener) serve() {
+	for {
+		conn, err := sl.ln.Accept()
+		if err != nil {
+			sharedListeners.mu.Lock()
+			if sharedListeners.m[sl.key] == sl {
+				delete(sharedListeners.m, sl.key)
+			}
+			sl.err = err
+			sharedListeners.mu.Unlock()
+			close(sl.done)
+			return
+		}
+		remote := remoteIP(conn)
+		sharedListeners.mu.Lock()
+		ch, ok := sl.waiters[remote.String()]
+		if ok {
+			select {
+			case ch <- conn:
+			default:
+				// 前に受け取ったコネクションをまだ処理していない
+				ok = false
+			}
+		}
+		sharedListeners.mu.Unlock()
+		if !ok {
+			fsmLog.Warn("connection is rejected", "local", conn.LocalAddr().String(), "remote", conn.RemoteAddr().String())
+			conn.Close()
+		}
+	}
+}
+
+// コネクションの送信元のアドレスを返す
+func remoteIP(conn net.Conn) net.IP {
+	if a, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+		return a.IP
+	}
+	ap, err := netip.ParseAddrPort(conn.RemoteAddr().String())
 	if err != nil {
-		fmt.Printf("failed to accept: %v\n", err)
-		return conn, err
+		return nil
 	}
-	fmt.Print("accepted\n")
-	return conn, nil
+	return net.IP(ap.Addr().AsSlice())
 }
 
-// Writer, Readerを実装した方がよりGoらしい？
+func portOrDefault(port int) int {
+	if port == 0 {
+		return BGP_PORT
+	}
+	return port
+}
+
+// Messageを送信待ちに追加する
+// 書き込みは別のgoroutineで行うため、書き込みを待たずに返る。
+// すでに書き込みに失敗している場合や、Closeした後はエラーを返す。
 func (c *Connection) Send(m packets.Message) error {
 	b, err := m.ToBytes()
 	if err != nil {
-		fmt.Printf("MessageのByte変換に失敗しました: %v\n", err)
+		c.log.Debug("cannot encode message", "type", messageType(m), "error", err)
 		return err
 	}
-	_, err = c.conn.Write(b)
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	if c.sendErr != nil {
+		return c.sendErr
+	}
+	if c.closing {
+		return net.ErrClosed
+	}
+	c.sendq = append(c.sendq, b)
+	notify(c.sendWake)
+	// 送受信した順序を保つため、送信待ちに追加した時点で記録する
+	if c.record != nil {
+		c.record(b, true)
+	}
+	return nil
+}
+
+// 1つのMessageの書き込みを待つ時間を設定する
+// Peerが読み込まなくなった場合は、この時間が経過すると書き込みに失敗する
+func (c *Connection) SetWriteTimeout(d time.Duration) {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	c.writeTimeout = d
+}
+
+// 送信待ちのMessageを順に書き込む
+// Closeされた場合は残りのMessageを書き込んでから、
+// 書き込みに失敗した場合はすぐにコネクションを閉じる。
+// コネクションを閉じると、受信しているgoroutineがTCP_CONNECTION_FAILSを通知する。
+func (c *Connection) writeLoop() {
+	defer c.conn.Close()
+	for {
+		c.sendMu.Lock()
+		q, closing := c.sendq, c.closing
+		c.sendq = nil
+		c.sendMu.Unlock()
+		if len(q) == 0 {
+			if closing {
+				return
+			}
+			<-c.sendWake
+			continue
+		}
+		for _, b := range q {
+			c.setWriteDeadline()
+			if _, err := c.conn.Write(b); err != nil {
+				c.log.Debug("cannot send message", "error", err)
+				c.sendMu.Lock()
+				c.sendErr = err
+				c.sendMu.Unlock()
+				return
+			}
+		}
+	}
+}
+
+// 次のMessageの書き込みの期限を設定する
+// Closeした後は、Closeで設定した期限を使う
+func (c *Connection) setWriteDeadline() {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	if !c.closing && c.writeTimeout > 0 {
+		c.conn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+	}
+}
+
+// 書き込みに失敗した場合のエラーを返す
+func (c *Connection) writeErr() error {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	return c.sendErr
+}
+
+func (c *Connection) LocalAddr() *net.TCPAddr {
+	return tcpAddr(c.conn.LocalAddr())
+}
+
+func (c *Connection) RemoteAddr() *net.TCPAddr {
+	return tcpAddr(c.conn.RemoteAddr())
+}
+
+// TransportのアドレスをTCPのアドレスとして返す
+// IPアドレスとポートで表せない場合はnilを返す
+func tcpAddr(a net.Addr) *net.TCPAddr {
+	if ta, ok := a.(*net.TCPAddr); ok {
+		return ta
+	}
+	ap, err := netip.ParseAddrPort(a.String())
 	if err != nil {
-		fmt.Printf("メッセージの送信に失敗しました: %v\n", err)
-		return err
+		return nil
+	}
+	return net.TCPAddrFromAddrPort(ap)
+}
+
+// 送信待ちのMessageを書き込んだ後に、コネクションを閉じる
+// NotificationMessageを送信してすぐに閉じても、Peerに届くようにするため。
+func (c *Connection) Close() error {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	if !c.closing {
+		c.closing = true
+		c.conn.SetWriteDeadline(time.Now().Add(CLOSE_TIMEOUT))
+		notify(c.sendWake)
 	}
 	return nil
 }
 
+// 送信待ちのMessageを書き込まずに、すぐにコネクションを閉じる
+func (c *Connection) abort() error {
+	return c.conn.Close()
+}
+
+// 以降に受信するMessageの解釈を設定する
+// Recvで受信を待っている間に、別のgoroutineから呼び出してもよい
+func (c *Connection) SetOptions(o packets.DecodeOptions) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.options = o
+}
+
+func (c *Connection) Options() packets.DecodeOptions {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.options
+}
+
 // bgp messageを1つ以上受信していれば
 // 最古に受信したMessageを返す。
 // bgp messageのデータの受信中（半端に受信している）、
@@ -101,54 +396,39 @@ func (c *Connection) Send(m packets.Message) error {
 func (c *Connection) Recv() (packets.Message, error) {
 	tempBuf := make([]byte, 4096)
 	for {
-		n, err := c.conn.Read(tempBuf)
-		if err != nil {
-			fmt.Printf("メッセージの受信に失敗しました: %v\n", err)
-			return nil, err
-		}
-		if n == 0 {
-			continue
-		}
-		c.buf = append(c.buf, tempBuf[:n]...)
+		// 前回の読み込みで、複数のMessageをまとめて受信している場合がある
 		b, err := c.splitMsgSep()
 		if err != nil {
-			fmt.Printf("MessageのByte切り出しに失敗しました: %v\n", err)
+			c.log.Debug("cannot split message", "error", err)
 			return nil, err
 		}
-		if b == nil {
-			continue
+		if b != nil {
+			if c.record != nil {
+				c.record(b, false)
+			}
+			m, err := packets.BytesToMessageWithOptions(b, c.Options())
+			if err != nil {
+				c.log.Debug("cannot decode message", "error", err)
+				return nil, err
+			}
+			return m, nil
 		}
-		m, err := packets.BytesToMessage(b)
+		n, err := c.conn.Read(tempBuf)
 		if err != nil {
-			fmt.Printf("ByteのMessage変換に失敗しました: %v\n", err)
+			c.log.Debug("cannot receive message", "error", err)
 			return nil, err
 		}
-		return m, nil
+		c.buf = append(c.buf, tempBuf[:n]...)
 	}
 }
 
 // *Connection.bufから1つのbgp messageを切り出す
+// まだ1つのMessageのデータがbufferに入っていない場合はnilを返す
 func (c *Connection) splitMsgSep() ([]byte, error) {
-	idx, err := c.getIdxMsgSep()
-	if err != nil {
+	b, rest, err := packets.SplitMessage(c.buf)
+	if err != nil || b == nil {
 		return nil, err
 	}
-	if len(c.buf) < idx {
-		return nil, nil // まだMessageのSeparateorを表すデータがbufferに入っていない
-	}
-	b := c.buf[:idx]
-	c.buf = c.buf[idx:]
+	c.buf = rest
 	return b, nil
 }
-
-// *Connection.bufのうちどこまでが1つのbgp messageを表すbyteであるかを返す
-// BGPヘッダーのLengthフィールドの値を返す
-func (c *Connection) getIdxMsgSep() (int, error) {
-	minMsgLen := 19 // BGP messageの最小長
-	if len(c.buf) < minMsgLen {
-		return 0, fmt.Errorf(
-			"MessageのSeparateorを表すデータまでbufferに入っていません。"+
-				"データの受信が半端であることが想定されます。 buffer: %v", len(c.buf))
-	}
-	return int(c.buf[16])<<8 + int(c.buf[17]), nil
-}
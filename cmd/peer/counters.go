@@ -0,0 +1,150 @@
+package peer
+
+import (
+	"net"
+	"time"
+
+	"github.com/SotaUeda/gobgp/packets"
+)
+
+// 送受信したMessageの数
+type MessageCounters struct {
+	Open         uint64
+	Update       uint64
+	Notification uint64
+	Keepalive    uint64
+	Total        uint64
+	// NotificationMessageのError CodeとError Subcodeごとの数
+	Notifications map[NotificationCode]uint64
+}
+
+type NotificationCode struct {
+	Code    packets.ErrorCode
+	Subcode uint8
+}
+
+func (c *MessageCounters) count(m packets.Message) {
+	switch m := m.(type) {
+	case *packets.OpenMessage:
+		c.Open++
+	case *packets.UpdateMessage:
+		c.Update++
+	case *packets.NotificationMessage:
+		c.Notification++
+		if c.Notifications == nil {
+			c.Notifications = make(map[NotificationCode]uint64)
+		}
+		c.Notifications[NotificationCode{m.ErrorCode, m.ErrorSubcode}]++
+	case *packets.KeepaliveMessage:
+		c.Keepalive++
+	}
+	c.Total++
+}
+
+// MessageTypeごとの数を返す
+func (c *MessageCounters) Get(t packets.MessageType) uint64 {
+	switch t {
+	case packets.Open:
+		return c.Open
+	case packets.Update:
+		return c.Update
+	case packets.Notification:
+		return c.Notification
+	case packets.Keepalive:
+		return c.Keepalive
+	default:
+		return 0
+	}
+}
+
+// Infoで返したあとにPeerが数を更新しても影響しないように、mapを複製する
+func (c MessageCounters) clone() MessageCounters {
+	ns := make(map[NotificationCode]uint64, len(c.Notifications))
+	for k, v := range c.Notifications {
+		ns[k] = v
+	}
+	c.Notifications = ns
+	return c
+}
+
+// ほかのgoroutineから参照するための、Peerの状態のスナップショット
+type PeerInfo struct {
+	Config    *Config
+	State     State
+	AdminDown bool
+	// Establishedに遷移した時刻。Establishedでない場合はゼロ値
+	EstablishedAt time.Time
+	// ネゴシエーションしたHold Time。Establishedでない場合は0
+	HoldTime time.Duration
+	Sent     MessageCounters
+	Received MessageCounters
+	// 各Stateに遷移した回数
+	Transitions        map[State]uint64
+	ReceivedPrefixes   int
+	AcceptedPrefixes   int
+	AdvertisedPrefixes int
+	// 現在のセッションのアドレスと、送受信したOpenMessage
+	// セッションが確立していない場合はnil
+	LocalAddr    *net.TCPAddr
+	RemoteAddr   *net.TCPAddr
+	SentOpen     *packets.OpenMessage
+	ReceivedOpen *packets.OpenMessage
+}
+
+// Peerの状態のスナップショットを返す
+// Peerのgoroutine以外から呼び出してもよい
+func (p *Peer) Info() PeerInfo {
+	p.mu.Lock()
+	ts := make(map[State]uint64, len(p.transitions))
+	for k, v := range p.transitions {
+		ts[k] = v
+	}
+	info := PeerInfo{
+		Config:        p.Config,
+		State:         p.State,
+		AdminDown:     p.disabled,
+		EstablishedAt: p.establishedAt,
+		HoldTime:      p.establishedHoldTime,
+		Sent:          p.sent.clone(),
+		Received:      p.received.clone(),
+		Transitions:   ts,
+		LocalAddr:     p.localAddr,
+		RemoteAddr:    p.remoteAddr,
+		SentOpen:      p.sentOpen,
+		ReceivedOpen:  p.receivedOpen,
+	}
+	ari, aro := p.AdjRibIn, p.AdjRibOut
+	p.mu.Unlock()
+	// 経路数はイベントごとに数えると経路数に比例した時間がかかるため、参照されたときに数える
+	info.ReceivedPrefixes = ari.Rib.Len()
+	info.AdvertisedPrefixes = aro.Rib.Len()
+	info.AcceptedPrefixes = p.LocRib.countBest(ari.Rib.Routes())
+	return info
+}
+
+// AdjRibInの経路を返す
+// Peerのgoroutine以外から呼び出してもよい
+func (p *Peer) AdjRibInRoutes() []*RibEntry {
+	p.mu.Lock()
+	ari := p.AdjRibIn
+	p.mu.Unlock()
+	return ari.Rib.Routes()
+}
+
+// Route Flap Dampingの履歴があるプレフィックスを返す
+// Peerのgoroutine以外から呼び出してもよい
+func (p *Peer) DampedRoutes() []*DampedRoute {
+	p.mu.Lock()
+	ari := p.AdjRibIn
+	p.mu.Unlock()
+	return ari.DampedRoutes()
+}
+
+// AdjRibOutの経路を返す
+// Peerのgoroutine以外から呼び出してもよい
+func (p *Peer) AdjRibOutRoutes() []*RibEntry {
+	p.mu.Lock()
+	aro := p.AdjRibOut
+	p.mu.Unlock()
+	return aro.Rib.Routes()
+}
@@ -0,0 +1,221 @@
+package peer
+
+import (
+	"math"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Route Flap Damping (RFC2439) の設定
+// 既定値は一般的な実装に合わせている
+type DampingConfig struct {
+	// ペナルティが半分になるまでの時間
+	HalfLife time.Duration
+	// ペナルティがこの値を下回ると抑制を解除する
+	ReuseThreshold float64
+	// ペナルティがこの値を超えると経路を抑制する
+	SuppressThreshold float64
+	// 経路を抑制し続ける最大の時間
+	MaxSuppressTime time.Duration
+}
+
+func DefaultDampingConfig() *DampingConfig {
+	return &DampingConfig{
+		HalfLife:          15 * time.Minute,
+		ReuseThreshold:    750,
+		SuppressThreshold: 2000,
+		MaxSuppressTime:   60 * time.Minute,
+	}
+}
+
+// 経路の変化ごとに加算するペナルティ
+const (
+	DAMPING_WITHDRAW_PENALTY         = 1000
+	DAMPING_ATTRIBUTE_CHANGE_PENALTY = 500
+)
+
+// 抑制されていない経路のペナルティがこの値を下回ったら履歴を削除する
+const dampingForgetRatio = 0.5
+
+// プレフィックスごとのフラップの履歴
+type dampingState struct {
+	nw           *net.IPNet
+	penalty      float64
+	updated      time.Time
+	flaps        int
+	suppressed   bool
+	suppressedAt time.Time
+}
+
+// Peerごとに、プレフィックス単位でペナルティを管理する
+// 履歴の一覧はAPIからも参照するため、すべての処理を排他制御する
+type damping struct {
+	config *DampingConfig
+	clock  Clock
+	mu     sync.Mutex
+	states map[string]*dampingState
+}
+
+func newDamping(c *DampingConfig, clock Clock) *damping {
+	return &damping{
+		config: c,
+		clock:  clock,
+		states: make(map[string]*dampingState),
+	}
+}
+
+// ペナルティの上限
+// 上限からReuseThresholdまで減衰する時間がMaxSuppressTimeになるようにする
+func (d *damping) ceiling() float64 {
+	return d.config.ReuseThreshold *
+		math.Pow(2, float64(d.config.MaxSuppressTime)/float64(d.config.HalfLife))
+}
+
+// 経過時間に応じてペナルティを指数的に減衰させる
+// d.muをロックした状態で呼び出す
+func (d *damping) decay(st *dampingState, now time.Time) {
+	elapsed := now.Sub(st.updated)
+	if elapsed <= 0 {
+		return
+	}
+	st.penalty *= math.Pow(2, -float64(elapsed)/float64(d.config.HalfLife))
+	st.updated = now
+}
+
+// 抑制されておらず、ペナルティが十分に減衰した履歴を削除する
+// 削除した場合はtrueを返す
+// d.muをロックした状態で呼び出す
+func (d *damping) forget(key string, st *dampingState) bool {
+	if st.suppressed || st.penalty >= d.forgetThreshold() {
+		return false
+	}
+	delete(d.states, key)
+	return true
+}
+
+func (d *damping) forgetThreshold() float64 {
+	return d.config.ReuseThreshold * dampingForgetRatio
+}
+
+// ペナルティを加算する
+// 加算によって抑制された場合はtrueを返す
+func (d *damping) penalize(nw *net.IPNet, penalty float64) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := d.clock.Now()
+	key := nw.String()
+	st, ok := d.states[key]
+	if !ok {
+		st = &dampingState{nw: nw, updated: now}
+		d.states[key] = st
+	}
+	d.decay(st, now)
+	st.penalty = math.Min(st.penalty+penalty, d.ceiling())
+	st.flaps++
+	if !st.suppressed && st.penalty > d.config.SuppressThreshold {
+		st.suppressed = true
+		st.suppressedAt = now
+		return true
+	}
+	return false
+}
+
+func (d *damping) isSuppressed(nw *net.IPNet) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	st, ok := d.states[nw.String()]
+	return ok && st.suppressed
+}
+
+// ペナルティが減衰した経路の抑制を解除し、十分に減衰した履歴を削除する
+// 抑制を解除したプレフィックスを返す
+func (d *damping) reuse() []*net.IPNet {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := d.clock.Now()
+	reused := []*net.IPNet{}
+	for key, st := range d.states {
+		d.decay(st, now)
+		if st.suppressed &&
+			(st.penalty < d.config.ReuseThreshold ||
+				now.Sub(st.suppressedAt) >= d.config.MaxSuppressTime) {
+			st.suppressed = false
+			reused = append(reused, st.nw)
+		}
+		d.forget(key, st)
+	}
+	return reused
+}
+
+// 次に抑制を解除するか、履歴を削除できる時刻を返す
+// 履歴がない場合はfalseを返す
+func (d *damping) nextReuse() (time.Time, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var next time.Time
+	found := false
+	for _, st := range d.states {
+		var t time.Time
+		if st.suppressed {
+			t = d.decayedAt(st, d.config.ReuseThreshold)
+			if max := st.suppressedAt.Add(d.config.MaxSuppressTime); max.Before(t) {
+				t = max
+			}
+		} else {
+			t = d.decayedAt(st, d.forgetThreshold())
+		}
+		if !found || t.Before(next) {
+			next = t
+			found = true
+		}
+	}
+	return next, found
+}
+
+// ペナルティがthresholdまで減衰する時刻
+// penalty * 2^(-t/HalfLife) = threshold となるtを求める
+func (d *damping) decayedAt(st *dampingState, threshold float64) time.Time {
+	return st.updated.Add(time.Duration(
+		float64(d.config.HalfLife) * math.Log2(st.penalty/threshold),
+	))
+}
+
+// フラップの履歴があるプレフィックス
+type DampedRoute struct {
+	NwAddr     *net.IPNet
+	Penalty    float64
+	Flaps      int
+	Suppressed bool
+	// 抑制が解除されるまでの時間
+	ReuseIn time.Duration
+}
+
+func (d *damping) routes() []*DampedRoute {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := d.clock.Now()
+	drs := []*DampedRoute{}
+	for key, st := range d.states {
+		d.decay(st, now)
+		if d.forget(key, st) {
+			continue
+		}
+		dr := &DampedRoute{
+			NwAddr:     st.nw,
+			Penalty:    st.penalty,
+			Flaps:      st.flaps,
+			Suppressed: st.suppressed,
+		}
+		if st.suppressed {
+			dr.ReuseIn = d.decayedAt(st, d.config.ReuseThreshold).Sub(now)
+			if rest := d.config.MaxSuppressTime - now.Sub(st.suppressedAt); rest < dr.ReuseIn {
+				dr.ReuseIn = rest
+			}
+		}
+		drs = append(drs, dr)
+	}
+	sort.Slice(drs, func(i, j int) bool { return drs[i].NwAddr.String() < drs[j].NwAddr.String() })
+	return drs
+}
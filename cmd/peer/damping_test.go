@@ -0,0 +1,183 @@
+package peer
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/SotaUeda/gobgp/bgptype"
+	"github.com/SotaUeda/gobgp/packets"
+)
+
+// フラップを繰り返した経路が抑制され、ペナルティの減衰後に再利用されることを確認するテスト
+func TestDampingSuppressAndReuse(t *testing.T) {
+	config, _ := ParseConfig("64512 127.0.0.1 65001 127.0.0.2 active")
+	config.Damping = DefaultDampingConfig()
+	lr := &LocRib{Rib: NewRib(), LocalASNum: config.LocalAS}
+	ari := NewAdjRibIn(NewRib())
+	clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	ari.Clock = clock
+
+	igp := bgptype.IGP
+	_, nw, _ := net.ParseCIDR("10.1.0.0/16")
+	announce, _ := packets.NewUpdateMessage(
+		[]bgptype.PathAttribute{&igp, bgptype.NewAsPath(true, 65001)},
+		[]*net.IPNet{nw},
+		[]*net.IPNet{},
+	)
+	withdraw, _ := packets.NewUpdateMessage(
+		[]bgptype.PathAttribute{},
+		[]*net.IPNet{},
+		[]*net.IPNet{nw},
+	)
+	flap := func() {
+		for _, um := range []*packets.UpdateMessage{announce, withdraw, announce} {
+			if err := ari.InstallFromUpdate(um, config); err != nil {
+				t.Errorf("Error: %v", err)
+			}
+		}
+		lr.InstallFromAdjRibIn(ari, config)
+	}
+
+	// 1回目のフラップでは抑制されない
+	flap()
+	if len(lr.Rib.Routes()) != 1 {
+		t.Errorf("route should be installed to LocRib")
+	}
+	// 続けてフラップすると抑制され、LocRibから除外される
+	clock.Advance(time.Minute)
+	flap()
+	clock.Advance(time.Minute)
+	flap()
+	if ari.Rib.Len() != 1 {
+		t.Errorf("suppressed route should stay in AdjRibIn")
+	}
+	if len(lr.Rib.Routes()) != 0 {
+		t.Errorf("suppressed route should be excluded from LocRib")
+	}
+	drs := ari.DampedRoutes()
+	if len(drs) != 1 || !drs[0].Suppressed || drs[0].Flaps != 3 {
+		t.Errorf("Want: 1 suppressed route with 3 flaps, Got: %+v", drs)
+	}
+
+	// 再利用できる時刻の前は抑制されたまま
+	next, ok := ari.NextDampingReuse()
+	if !ok {
+		t.Fatalf("next reuse time should be scheduled")
+	}
+	clock.Advance(next.Add(-time.Second).Sub(clock.Now()))
+	if ari.ReuseDampedRoutes() {
+		t.Errorf("route should not be reused before %v", next)
+	}
+	// 再利用できる時刻を過ぎると抑制が解除され、LocRibに戻る
+	clock.Advance(2 * time.Second)
+	if !ari.ReuseDampedRoutes() {
+		t.Errorf("route should be reused after %v", next)
+	}
+	lr.InstallFromAdjRibIn(ari, config)
+	if len(lr.Rib.Routes()) != 1 {
+		t.Errorf("reused route should be installed to LocRib")
+	}
+}
+
+// ペナルティの上限によって、MaxSuppressTimeを超えて抑制されないことを確認するテスト
+func TestDampingMaxSuppressTime(t *testing.T) {
+	c := DefaultDampingConfig()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	d := newDamping(c, NewFakeClock(now))
+	_, nw, _ := net.ParseCIDR("10.1.0.0/16")
+	for i := 0; i < 100; i++ {
+		d.penalize(nw, DAMPING_WITHDRAW_PENALTY)
+	}
+	next, ok := d.nextReuse()
+	if !ok {
+		t.Fatalf("next reuse time should be scheduled")
+	}
+	if got := next.Sub(now); got > c.MaxSuppressTime {
+		t.Errorf("Want: <= %v, Got: %v", c.MaxSuppressTime, got)
+	}
+}
+
+// 抑制されていない経路の履歴も、ペナルティが十分に減衰すると削除されることを確認するテスト
+func TestDampingForgetsDecayedRoutes(t *testing.T) {
+	c := DefaultDampingConfig()
+	clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	d := newDamping(c, clock)
+	_, nw, _ := net.ParseCIDR("10.1.0.0/16")
+	if d.penalize(nw, DAMPING_WITHDRAW_PENALTY) {
+		t.Fatalf("route should not be suppressed by a single flap")
+	}
+	next, ok := d.nextReuse()
+	if !ok {
+		t.Fatalf("time to forget the history should be scheduled")
+	}
+	clock.Advance(next.Add(-time.Second).Sub(clock.Now()))
+	d.reuse()
+	if got := len(d.routes()); got != 1 {
+		t.Errorf("Want: 1, Got: %d", got)
+	}
+	clock.Advance(2 * time.Second)
+	d.reuse()
+	if got := len(d.states); got != 0 {
+		t.Errorf("Want: 0, Got: %d", got)
+	}
+	if _, ok := d.nextReuse(); ok {
+		t.Errorf("nothing should be scheduled after the history is forgotten")
+	}
+}
+
+// 広告した後に抑制された経路は、Peerに取り消しを送信することを確認するテスト
+func TestDampingWithdrawsAdvertisedRoute(t *testing.T) {
+	config, _ := ParseConfig("64512 127.0.0.1 65001 127.0.0.2 active")
+	config.Damping = DefaultDampingConfig()
+	lr := &LocRib{Rib: NewRib(), LocalASNum: config.LocalAS}
+	outConfig, _ := ParseConfig("64512 127.0.0.1 65002 127.0.0.3 active")
+	p := NewPeer(outConfig, lr)
+	lr.register(p)
+	aro := NewAdjRibOut(NewRib())
+	ari := NewAdjRibIn(NewRib())
+	ari.Clock = NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	igp := bgptype.IGP
+	nh := bgptype.NextHop(net.ParseIP("127.0.0.2").To4())
+	_, nw, _ := net.ParseCIDR("10.1.0.0/16")
+	install := func(um *packets.UpdateMessage) {
+		if err := ari.InstallFromUpdate(um, config); err != nil {
+			t.Fatal(err)
+		}
+		lr.InstallFromAdjRibIn(ari, config)
+		aro.InstallPrefixes(lr, outConfig, p.takeLocRibChanges())
+	}
+	announce := func(as bgptype.AutonomousSystemNumber) *packets.UpdateMessage {
+		um, _ := packets.NewUpdateMessage(
+			[]bgptype.PathAttribute{&igp, bgptype.NewAsPath(true, 65001, as), &nh},
+			[]*net.IPNet{nw},
+			[]*net.IPNet{},
+		)
+		return um
+	}
+	install(announce(65010))
+	if _, err := aro.ToUpdateMessages(outConfig.LocalIP, outConfig.LocalAS, false); err != nil {
+		t.Fatal(err)
+	}
+	if len(aro.Rib.Lookup(nw)) != 1 {
+		t.Fatalf("route should be advertised before it is suppressed")
+	}
+	// PathAttributeの変更を繰り返して抑制させる
+	for i := 0; i < 5 && !ari.IsSuppressed(NewRibEntry(nw)); i++ {
+		install(announce(bgptype.AutonomousSystemNumber(65011 + i)))
+	}
+	if !ari.IsSuppressed(NewRibEntry(nw)) {
+		t.Fatalf("route should be suppressed")
+	}
+	if len(aro.Rib.Lookup(nw)) != 0 {
+		t.Errorf("suppressed route should be removed from AdjRibOut")
+	}
+	ums, err := aro.ToUpdateMessages(outConfig.LocalIP, outConfig.LocalAS, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ums) != 1 || len(ums[0].WithdrawnRoutes) != 1 || ums[0].WithdrawnRoutes[0].String() != nw.String() {
+		t.Errorf("Want: withdrawal of %v, Got: %v", nw, ums)
+	}
+}
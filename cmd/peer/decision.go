@@ -0,0 +1,168 @@
+package peer
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/SotaUeda/gobgp/bgptype"
+	"github.com/SotaUeda/gobgp/policy"
+	"github.com/SotaUeda/gobgp/rpki"
+)
+
+// 候補経路の中から最適経路を選択する
+// 参考: 9.1.2.  Phase 2: Route Selection in RFC4271.
+func selectBestPath(paths []*RibEntry) *RibEntry {
+	var best *RibEntry
+	for _, p := range paths {
+		if best == nil || betterPath(p, best) {
+			best = p
+		}
+	}
+	return best
+}
+
+// aがbより優先される場合にtrueを返す。
+// 9.1.2.2 Breaking Ties (Phase 2) を参考に、本実装で扱う属性を次の順で比較する。
+//  1. 自身で生成した経路
+//  2. RPKIの検証結果 (Valid > NotFound > Invalid)
+//  3. AS Pathが短い経路
+//  4. Originが小さい経路 (IGP < EGP < INCOMPLETE)
+//  5. eBGPで受信した経路 (iBGPより優先)
+//  6. NextHopまでのIGPのコストが小さい経路
+//  7. PeerのIPアドレスが小さい経路
+//  8. Path Identifierが小さい経路 (ADD-PATHで同じPeerから複数の経路を受信した場合)
+func betterPath(a, b *RibEntry) bool {
+	if (a.PeerAddr == nil) != (b.PeerAddr == nil) {
+		return a.PeerAddr == nil
+	}
+	if ra, rb := validationRank(a.Validation), validationRank(b.Validation); ra != rb {
+		return ra > rb
+	}
+	if la, lb := asPathLen(a), asPathLen(b); la != lb {
+		return la < lb
+	}
+	if oa, ob := origin(a), origin(b); oa != ob {
+		return oa < ob
+	}
+	if a.IBGP != b.IBGP {
+		return !a.IBGP
+	}
+	if a.igpMetric != b.igpMetric {
+		return a.igpMetric < b.igpMetric
+	}
+	if c := bytes.Compare(a.PeerAddr.To16(), b.PeerAddr.To16()); c != 0 {
+		return c < 0
+	}
+	return a.PathID < b.PathID
+}
+
+func validationRank(v rpki.ValidationState) int {
+	switch v {
+	case rpki.Valid:
+		return 2
+	case rpki.NotFound:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// AS Pathの長さ
+// AS_SETは含まれるASの数によらず1として数える (9.1.2.2 a)
+func asPathLen(re *RibEntry) int {
+	l := 0
+	for _, pa := range re.attributes() {
+		switch t := pa.(type) {
+		case *bgptype.AsSequence:
+			l += len(*t)
+		case *bgptype.AsSet:
+			if len(*t) > 0 {
+				l++
+			}
+		}
+	}
+	return l
+}
+
+func origin(re *RibEntry) bgptype.Origin {
+	for _, pa := range re.attributes() {
+		if o, ok := pa.(*bgptype.Origin); ok {
+			return *o
+		}
+	}
+	return bgptype.INCOMPLETE
+}
+
+// BGP Multipathの設定
+// 最適経路と等価な経路を、最大でMaximumPaths個まで同時にカーネルにインストールする
+type MultipathConfig struct {
+	// eBGPで受信した経路の最大数。1以下の場合はマルチパスにしない
+	MaximumPaths int
+	// iBGPで受信した経路の最大数。1以下の場合はマルチパスにしない
+	MaximumPathsIBGP int
+	// AS Pathの長さが同じであれば、含まれるASが異なっていても等価とみなす
+	AsPathRelax bool
+}
+
+func (c *MultipathConfig) maximumPaths(ibgp bool) int {
+	if c == nil {
+		return 1
+	}
+	if ibgp {
+		return c.MaximumPathsIBGP
+	}
+	return c.MaximumPaths
+}
+
+// 最適経路と等価な経路を、最適経路を先頭にして返す。
+// 次の条件をすべて満たす経路を等価とみなす。
+//   - 最適経路と同じくeBGP、またはiBGPで受信した経路
+//   - 最適経路とRPKIの検証結果、AS Pathの長さ、Originが等しい経路
+//   - AsPathRelaxでない場合は、AS Pathが最適経路と一致する経路
+//   - 最適経路や、すでに選択した経路とNextHopが異なる経路
+func selectMultipaths(best *RibEntry, paths []*RibEntry, c *MultipathConfig) []*RibEntry {
+	if best == nil {
+		return nil
+	}
+	mps := []*RibEntry{best}
+	max := c.maximumPaths(best.IBGP)
+	// 自身で生成した経路はカーネルにインストールしないためマルチパスにしない
+	if max <= 1 || best.PeerAddr == nil {
+		return mps
+	}
+	ranked := append([]*RibEntry{}, paths...)
+	sort.SliceStable(ranked, func(i, j int) bool { return betterPath(ranked[i], ranked[j]) })
+	for _, p := range ranked {
+		if len(mps) >= max {
+			break
+		}
+		if p == best || p.PeerAddr == nil || !multipathEqual(best, p, c.AsPathRelax) {
+			continue
+		}
+		dup := false
+		for _, mp := range mps {
+			if mp.nextHop().Equal(p.nextHop()) {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			mps = append(mps, p)
+		}
+	}
+	return mps
+}
+
+func multipathEqual(a, b *RibEntry, relax bool) bool {
+	if a.IBGP != b.IBGP ||
+		a.igpMetric != b.igpMetric ||
+		validationRank(a.Validation) != validationRank(b.Validation) ||
+		asPathLen(a) != asPathLen(b) ||
+		origin(a) != origin(b) {
+		return false
+	}
+	if relax {
+		return true
+	}
+	return policy.AsPathString(a.attributes()) == policy.AsPathString(b.attributes())
+}
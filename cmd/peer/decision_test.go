@@ -0,0 +1,201 @@
+package peer
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/SotaUeda/gobgp/bgptype"
+	"github.com/SotaUeda/gobgp/packets"
+)
+
+// 複数のPeerから受信した経路をLocRibにインストールする
+func installPaths(t *testing.T, lr *LocRib, nw *net.IPNet, paths map[string][]bgptype.AutonomousSystemNumber) {
+	t.Helper()
+	for remoteIP, path := range paths {
+		config, _ := ParseConfig("64512 127.0.0.1 " + fmt.Sprint(path[0]) + " " + remoteIP + " active")
+		igp := bgptype.IGP
+		nh := bgptype.NextHop(net.ParseIP(remoteIP).To4())
+		um, _ := packets.NewUpdateMessage(
+			[]bgptype.PathAttribute{&igp, bgptype.NewAsPath(true, path...), &nh},
+			[]*net.IPNet{nw},
+			[]*net.IPNet{},
+		)
+		ari := NewAdjRibIn(NewRib())
+		if err := ari.InstallFromUpdate(um, config); err != nil {
+			t.Errorf("Error: %v", err)
+		}
+		lr.InstallFromAdjRibIn(ari, config)
+	}
+}
+
+// 等価な経路がマルチパスとして選択され、1つのカーネルのルートにまとめられることを確認するテスト
+func TestLocRibMultipath(t *testing.T) {
+	_, nw, _ := net.ParseCIDR("10.1.0.0/16")
+	paths := map[string][]bgptype.AutonomousSystemNumber{
+		"10.0.0.1": {65001, 65010},
+		"10.0.0.2": {65001, 65010},
+		"10.0.0.3": {65002, 65010},
+		"10.0.0.4": {65003, 65004, 65010},
+	}
+	tests := []struct {
+		name      string
+		multipath *MultipathConfig
+		want      int
+	}{
+		{"disabled", nil, 1},
+		{"ebgp", &MultipathConfig{MaximumPaths: 4}, 2},
+		{"ebgp limited", &MultipathConfig{MaximumPaths: 1}, 1},
+		{"as-path relax", &MultipathConfig{MaximumPaths: 4, AsPathRelax: true}, 3},
+		{"ibgp only", &MultipathConfig{MaximumPathsIBGP: 4}, 1},
+	}
+	for _, tt := range tests {
+		lr := &LocRib{Rib: NewRib(), LocalASNum: 64512, Multipath: tt.multipath}
+		installPaths(t, lr, nw, paths)
+		mps := lr.Multipaths(nw)
+		if len(mps) != tt.want {
+			t.Errorf("%s: Want: %d, Got: %d", tt.name, tt.want, len(mps))
+			continue
+		}
+		route := lr.fibRoute(nw.String())
+		if len(route.NextHops) != tt.want {
+			t.Errorf("%s: Want: %d nexthops, Got: %v", tt.name, tt.want, route.Show())
+		}
+	}
+}
+
+// マルチパスの経路が変わった場合に、最適経路が変更されたものとして扱われることを確認するテスト
+func TestLocRibMultipathChangeMarksBestPath(t *testing.T) {
+	_, nw, _ := net.ParseCIDR("10.1.0.0/16")
+	lr := &LocRib{Rib: NewRib(), LocalASNum: 64512, Multipath: &MultipathConfig{MaximumPaths: 4}}
+	config, _ := ParseConfig("64512 127.0.0.1 65002 10.0.0.9 active")
+	p := NewPeer(config, lr)
+	lr.register(p)
+	installPaths(t, lr, nw, map[string][]bgptype.AutonomousSystemNumber{
+		"10.0.0.1": {65001, 65010},
+	})
+	p.takeLocRibChanges()
+	// 最適経路より優先度の低い、等価な経路を追加する
+	installPaths(t, lr, nw, map[string][]bgptype.AutonomousSystemNumber{
+		"10.0.0.2": {65001, 65010},
+	})
+	if got := p.takeLocRibChanges(); len(got) != 1 || got[0].String() != nw.String() {
+		t.Errorf("Want: [%v], Got: %v", nw, got)
+	}
+	if len(lr.Multipaths(nw)) != 2 {
+		t.Errorf("Want: 2, Got: %d", len(lr.Multipaths(nw)))
+	}
+}
+
+// 設定を読み込み直してマルチパスの設定を変えると、受信済みの経路から等価な経路を選び直すことを確認するテスト
+func TestLocRibReconfigureMultipath(t *testing.T) {
+	_, nw, _ := net.ParseCIDR("10.1.0.0/16")
+	config, _ := ParseConfig("64512 127.0.0.1 65002 10.0.0.9 active")
+	lr, err := NewLocRib(config, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	installPaths(t, lr, nw, map[string][]bgptype.AutonomousSystemNumber{
+		"10.0.0.1": {65001, 65010},
+		"10.0.0.2": {65001, 65010},
+	})
+	if got := len(lr.Multipaths(nw)); got != 1 {
+		t.Errorf("Want: 1, Got: %d", got)
+	}
+	for _, tt := range []struct {
+		multipath *MultipathConfig
+		want      int
+	}{
+		{&MultipathConfig{MaximumPaths: 4}, 2},
+		{nil, 1},
+	} {
+		c := *config
+		c.Multipath = tt.multipath
+		if err := lr.Reconfigure(&c); err != nil {
+			t.Fatal(err)
+		}
+		if got := len(lr.Multipaths(nw)); got != tt.want {
+			t.Errorf("Want: %d, Got: %d", tt.want, got)
+		}
+		if len(lr.fibDirty) != 1 {
+			t.Errorf("Want: %v marked to be written to kernel, Got: %v", nw, lr.fibDirty)
+		}
+		lr.fibDirty = nil
+	}
+}
+
+// 最適経路が変わると、変更を起こしたPeer以外の起動中のPeerにも通知されることを確認するテスト
+func TestLocRibNotifiesAllPeers(t *testing.T) {
+	_, nw, _ := net.ParseCIDR("10.1.0.0/16")
+	lr := &LocRib{Rib: NewRib(), LocalASNum: 64512}
+	var ps []*Peer
+	for _, ip := range []string{"10.0.0.8", "10.0.0.9"} {
+		config, _ := ParseConfig("64512 127.0.0.1 65002 " + ip + " active")
+		p := NewPeer(config, lr)
+		lr.register(p)
+		ps = append(ps, p)
+	}
+	installPaths(t, lr, nw, map[string][]bgptype.AutonomousSystemNumber{
+		"10.0.0.1": {65001, 65010},
+	})
+	for _, p := range ps {
+		if e, ok := p.events.pop(); !ok || e.ev != LOC_RIB_CHANGED {
+			t.Errorf("Want: %v, Got: %v", LOC_RIB_CHANGED.Show(), e.ev.Show())
+		}
+		if got := p.takeLocRibChanges(); len(got) != 1 || got[0].String() != nw.String() {
+			t.Errorf("Want: [%v], Got: %v", nw, got)
+		}
+	}
+	// 停止したPeerには通知しない
+	lr.unregister(ps[1])
+	installPaths(t, lr, nw, map[string][]bgptype.AutonomousSystemNumber{
+		"10.0.0.2": {65001},
+	})
+	if _, ok := ps[0].events.pop(); !ok {
+		t.Errorf("Want: %v, Got: none", LOC_RIB_CHANGED.Show())
+	}
+	if e, ok := ps[1].events.pop(); ok {
+		t.Errorf("Want: none, Got: %v", e.ev.Show())
+	}
+}
+
+// 最適経路が変わったプレフィックスだけがカーネルへの反映対象になることを確認するテスト
+func TestLocRibFIBDirtyPrefixes(t *testing.T) {
+	_, nw1, _ := net.ParseCIDR("10.1.0.0/16")
+	_, nw2, _ := net.ParseCIDR("10.2.0.0/16")
+	lr := &LocRib{Rib: NewRib(), LocalASNum: 64512}
+	installPaths(t, lr, nw1, map[string][]bgptype.AutonomousSystemNumber{
+		"10.0.0.1": {65001},
+	})
+	installPaths(t, lr, nw2, map[string][]bgptype.AutonomousSystemNumber{
+		"10.0.0.1": {65001},
+	})
+	if len(lr.fibDirty) != 2 {
+		t.Errorf("Want: 2, Got: %d", len(lr.fibDirty))
+	}
+	// FIBがnilの場合は書き込まずに反映対象を空にする
+	if err := lr.WriteToKernelRoutingTable(); err != nil {
+		t.Fatal(err)
+	}
+	if len(lr.fibDirty) != 0 {
+		t.Errorf("Want: 0, Got: %d", len(lr.fibDirty))
+	}
+	// 最適経路の変わらない、優先度の低い経路を追加しても反映対象にならない
+	installPaths(t, lr, nw1, map[string][]bgptype.AutonomousSystemNumber{
+		"10.0.0.2": {65001, 65002},
+	})
+	if len(lr.fibDirty) != 0 {
+		t.Errorf("Want: 0, Got: %v", lr.fibDirty)
+	}
+	// 最適経路が削除されたプレフィックスは、ルートの削除として反映する
+	for _, p := range lr.Paths(nw2) {
+		lr.removePath(p)
+	}
+	lr.updateBestPath(nw2.String())
+	if _, ok := lr.fibDirty[nw2.String()]; !ok {
+		t.Errorf("%v should be dirty", nw2)
+	}
+	if r := lr.fibRoute(nw2.String()); r != nil {
+		t.Errorf("Want: nil, Got: %v", r.Show())
+	}
+}
@@ -9,13 +9,24 @@ type Event int
 
 const (
 	MANUAL_START Event = iota
+	// RFC内でも同様に定義されている。
+	// 管理者がPeerを無効にしたときに発行する
+	MANUAL_STOP
+	// RFC内でも同様に定義されている。
+	// 再接続のタイマーが満了したときに発行する。無効にされたPeerでは無視する
+	AUTOMATIC_START
 	// 正常系しか実装しない本実装では別のEventとして扱う意味がないため、
 	// TcpConnectionConfirmedはTcpAckedも兼ねている。
+	// 接続に成功したときに発行する
 	TCP_CONNECTION_CONFIRMED
+	// RFC内でも同様に定義されている。
+	// 接続に失敗したときと、確立したコネクションでの受信に失敗したときに発行する
+	TCP_CONNECTION_FAILS
 	BGP_OPEN
 	// MSGはMessageの省略形
 	KEEPALIVE_MSG
 	UPDATE_MSG
+	NOTIFICATION_MSG
 	// StateがEstablishedに遷移したことを表す
 	// 存在する方が実装が楽なため追加したオリジナルイベント
 	ESTABLISHED_STATE_EVENT
@@ -24,20 +35,53 @@ const (
 	LOC_RIB_CHANGED
 	ADJ_RIB_OUT_CHANGED
 	ADJ_RIB_IN_CHANGED
+	// RPKIのVRPが更新されたときのイベント
+	RPKI_TABLE_CHANGED
+	// Route Flap Dampingで抑制した経路を再利用できる時刻になったときのイベント
+	DAMPING_REUSE_TIMER_EXPIRES
+	// NextHopの到達性やIGPのコストが変わったときのイベント
+	NEXTHOP_CHANGED
+	// Hold Timeの間にPeerからMessageを受信しなかったときのイベント
+	// RFC内でも同様に定義されている。
+	HOLD_TIMER_EXPIRES
+	// KeepaliveMessageを送信する時刻になったときのイベント
+	// RFC内でも同様に定義されている。
+	KEEPALIVE_TIMER_EXPIRES
+	// 設定ファイルを読み込み直して、Peerの設定が変わったときのイベント
+	CONFIG_CHANGED
+	// 設定から削除されたPeerを停止するときのイベント
+	PEER_DECONFIGURED
+	// 管理者がセッションの張り直しを指示したときのイベント
+	RESET
+	// 管理者が、セッションを維持したまま経路を評価し直すように指示したときのイベント
+	SOFT_RESET
+	// 受信したMessageのHeader / OpenMessage / UpdateMessageが壊れていたときのイベント
+	// RFC内でも同様に定義されている。
+	BGP_HEADER_ERR
+	BGP_OPEN_MSG_ERR
+	UPDATE_MSG_ERR
 )
 
 func (ev Event) Show() string {
 	switch ev {
 	case MANUAL_START:
 		return "Manual Start"
+	case MANUAL_STOP:
+		return "Manual Stop"
+	case AUTOMATIC_START:
+		return "Automatic Start"
 	case TCP_CONNECTION_CONFIRMED:
 		return "TCP Connection Confirmed"
+	case TCP_CONNECTION_FAILS:
+		return "TCP Connection Fails"
 	case BGP_OPEN:
 		return "BGP Open"
 	case KEEPALIVE_MSG:
 		return "Recieved Keepalive Message"
 	case UPDATE_MSG:
 		return "Recieved Update Message"
+	case NOTIFICATION_MSG:
+		return "Recieved Notification Message"
 	case ESTABLISHED_STATE_EVENT:
 		return "Established"
 	case LOC_RIB_CHANGED:
@@ -46,6 +90,30 @@ func (ev Event) Show() string {
 		return "AdjRibOut Changed"
 	case ADJ_RIB_IN_CHANGED:
 		return "AdjRibIn Changed"
+	case RPKI_TABLE_CHANGED:
+		return "RPKI Table Changed"
+	case DAMPING_REUSE_TIMER_EXPIRES:
+		return "Damping Reuse Timer Expires"
+	case NEXTHOP_CHANGED:
+		return "NextHop Changed"
+	case HOLD_TIMER_EXPIRES:
+		return "Hold Timer Expires"
+	case KEEPALIVE_TIMER_EXPIRES:
+		return "Keepalive Timer Expires"
+	case CONFIG_CHANGED:
+		return "Config Changed"
+	case PEER_DECONFIGURED:
+		return "Peer Deconfigured"
+	case RESET:
+		return "Reset"
+	case SOFT_RESET:
+		return "Soft Reset"
+	case BGP_HEADER_ERR:
+		return "BGP Header Error"
+	case BGP_OPEN_MSG_ERR:
+		return "BGP Open Message Error"
+	case UPDATE_MSG_ERR:
+		return "Update Message Error"
 	default:
 		return fmt.Sprintf("%v", ev)
 	}
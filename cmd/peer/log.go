@@ -0,0 +1,49 @@
+package peer
+
+import (
+	"log/slog"
+
+	"github.com/SotaUeda/gobgp/logging"
+	"github.com/SotaUeda/gobgp/packets"
+)
+
+var (
+	fsmLog    = logging.Logger(logging.FSM)
+	packetLog = logging.Logger(logging.PACKET)
+	ribLog    = logging.Logger(logging.RIB)
+	fibLog    = logging.Logger(logging.FIB)
+)
+
+// 出力するときに文字列に変換する値
+// Debugログを出力しない場合に、Messageや経路を文字列に変換する処理を省く
+type lazy func() string
+
+func (f lazy) LogValue() slog.Value {
+	return slog.StringValue(f())
+}
+
+// どのPeerのログかを表す属性を付けたLoggerを返す
+func peerLogger(l *slog.Logger, c *Config) *slog.Logger {
+	return l.With("peer", c.RemoteIP.String(), "remote_as", c.RemoteAS)
+}
+
+// Peerの現在のStateを表す属性を付けたLoggerを返す
+func (p *Peer) log(l *slog.Logger) *slog.Logger {
+	return peerLogger(l, p.Config).With("state", p.State.Show())
+}
+
+// ログに出力するMessageの種類
+func messageType(m packets.Message) string {
+	switch m.(type) {
+	case *packets.OpenMessage:
+		return packets.Open.Show()
+	case *packets.UpdateMessage:
+		return packets.Update.Show()
+	case *packets.NotificationMessage:
+		return packets.Notification.Show()
+	case *packets.KeepaliveMessage:
+		return packets.Keepalive.Show()
+	default:
+		return "Unknown"
+	}
+}
@@ -0,0 +1,96 @@
+package peer
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"github.com/SotaUeda/gobgp/fib"
+)
+
+// 受信した経路のNextHopをルーティングテーブルから解決し、到達性を追跡する
+//
+// iBGPで受信した経路のNextHopは直接接続されたネットワークにあるとは限らないため、
+// connectedやIGPのルートを使って再帰的に解決する。
+// ルーティングテーブルが変わった場合は解決し直し、結果が変わっていればOnChangeを呼び出す。
+type NextHopTracker struct {
+	FIB fib.FIB
+	// NextHopの解決結果が変わったときに呼び出す
+	OnChange func()
+
+	mu sync.Mutex
+	// NextHopごとの解決結果
+	resolutions map[string]*fib.NextHopResolution
+}
+
+func NewNextHopTracker(f fib.FIB) *NextHopTracker {
+	return &NextHopTracker{
+		FIB:         f,
+		resolutions: make(map[string]*fib.NextHopResolution),
+	}
+}
+
+// NextHopを解決する
+// 一度解決したNextHopはルーティングテーブルが変わるまで結果を使い回す。
+func (t *NextHopTracker) Resolve(nh net.IP) *fib.NextHopResolution {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if r, ok := t.resolutions[nh.String()]; ok {
+		return r
+	}
+	r := t.resolve(nh)
+	t.resolutions[nh.String()] = r
+	return r
+}
+
+func (t *NextHopTracker) resolve(nh net.IP) *fib.NextHopResolution {
+	r, err := t.FIB.ResolveNextHop(nh)
+	if err != nil {
+		// 解決できない場合は到達できないものとして扱う
+		ribLog.Warn("cannot resolve next hop", "nexthop", nh.String(), "error", err)
+		return &fib.NextHopResolution{}
+	}
+	return r
+}
+
+// ルーティングテーブルの変更を監視し、追跡しているNextHopを解決し直す
+// ctxが終了するまで処理を続ける
+func (t *NextHopTracker) Run(ctx context.Context) error {
+	ch, err := t.FIB.Watch(ctx.Done())
+	if err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ch:
+			if t.refresh() && t.OnChange != nil {
+				t.OnChange()
+			}
+		}
+	}
+}
+
+// 追跡しているNextHopを解決し直し、結果が変わったNextHopがあればtrueを返す
+// ルーティングテーブルは一度だけ取得し、すべてのNextHopを同じスナップショットで解決する。
+func (t *NextHopTracker) refresh() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	trs, err := t.FIB.Routes()
+	if err != nil {
+		// 取得できない場合はすべてのNextHopに到達できないものとして扱う
+		ribLog.Warn("cannot get routing table", "error", err)
+		trs = nil
+	}
+	changed := false
+	for key, cur := range t.resolutions {
+		r := fib.ResolveNextHop(trs, net.ParseIP(key))
+		if !r.Equal(cur) {
+			ribLog.Info("next hop is changed", "nexthop", key, "reachable", r.Reachable, "metric", r.Metric)
+			changed = true
+		}
+		t.resolutions[key] = r
+	}
+	return changed
+}
@@ -0,0 +1,214 @@
+package peer
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/SotaUeda/gobgp/bgptype"
+	"github.com/SotaUeda/gobgp/fib"
+	"github.com/SotaUeda/gobgp/packets"
+)
+
+// iBGPのPeerからNextHopを指定した経路を受信し、LocRibにインストールする
+func installIBGPPath(t *testing.T, lr *LocRib, nw *net.IPNet, remoteIP, nextHop string) {
+	t.Helper()
+	config, _ := ParseConfig("64512 127.0.0.1 64512 " + remoteIP + " active")
+	igp := bgptype.IGP
+	nh := bgptype.NextHop(net.ParseIP(nextHop).To4())
+	um, _ := packets.NewUpdateMessage(
+		[]bgptype.PathAttribute{&igp, bgptype.NewAsPath(true, 65001), &nh},
+		[]*net.IPNet{nw},
+		[]*net.IPNet{},
+	)
+	ari := NewAdjRibIn(NewRib())
+	if err := ari.InstallFromUpdate(um, config); err != nil {
+		t.Errorf("Error: %v", err)
+	}
+	lr.InstallFromAdjRibIn(ari, config)
+}
+
+func igpRoute(dst, gw string, metric int) *fib.TableRoute {
+	_, nw, _ := net.ParseCIDR(dst)
+	return &fib.TableRoute{Dst: nw, Source: fib.SOURCE_KERNEL, Gateway: net.ParseIP(gw), Metric: metric}
+}
+
+// NextHopがconnectedのルートとIGPのルートを通して再帰的に解決されることを確認するテスト
+func TestNextHopTrackerResolve(t *testing.T) {
+	_, connected, _ := net.ParseCIDR("10.200.100.0/24")
+	f := fib.NewMemory(connected)
+	f.AddTableRoute(igpRoute("192.168.0.0/16", "10.200.100.1", 100))
+	f.AddTableRoute(igpRoute("192.168.1.0/24", "10.200.100.2", 10))
+	tr := NewNextHopTracker(f)
+	tests := []struct {
+		nh        string
+		reachable bool
+		gateway   string
+		metric    int
+	}{
+		{"10.200.100.5", true, "10.200.100.5", 0},
+		{"192.168.2.1", true, "10.200.100.1", 100},
+		{"192.168.1.1", true, "10.200.100.2", 10},
+		{"172.16.0.1", false, "", 0},
+	}
+	for _, tt := range tests {
+		r := tr.Resolve(net.ParseIP(tt.nh))
+		if r.Reachable != tt.reachable {
+			t.Errorf("%s: Want: %v, Got: %v", tt.nh, tt.reachable, r.Reachable)
+			continue
+		}
+		if !tt.reachable {
+			continue
+		}
+		if !r.Gateway.Equal(net.ParseIP(tt.gateway)) || r.Metric != tt.metric {
+			t.Errorf("%s: Want: %s %d, Got: %v %d", tt.nh, tt.gateway, tt.metric, r.Gateway, r.Metric)
+		}
+	}
+}
+
+// ルーティングテーブルが変わるとNextHopを解決し直し、OnChangeが呼ばれることを確認するテスト
+func TestNextHopTrackerRun(t *testing.T) {
+	f := fib.NewMemory()
+	tr := NewNextHopTracker(f)
+	changed := make(chan struct{}, 1)
+	tr.OnChange = func() { changed <- struct{}{} }
+	nh := net.ParseIP("192.168.1.1")
+	if tr.Resolve(nh).Reachable {
+		t.Fatalf("next hop should be unreachable")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go tr.Run(ctx)
+	// Watchが開始されるまで待つ
+	time.Sleep(10 * time.Millisecond)
+	f.AddTableRoute(igpRoute("192.168.0.0/16", "10.200.100.1", 100))
+	select {
+	case <-changed:
+	case <-time.After(time.Second):
+		t.Fatalf("OnChange is not called")
+	}
+	if !tr.Resolve(nh).Reachable {
+		t.Errorf("next hop should be reachable")
+	}
+}
+
+// Routesを呼び出した回数を数えるFIB
+type countingFIB struct {
+	*fib.Memory
+	routes int
+}
+
+func (f *countingFIB) Routes() ([]*fib.TableRoute, error) {
+	f.routes++
+	return f.Memory.Routes()
+}
+
+// ルーティングテーブルが変わったときに、NextHopの数によらずルーティングテーブルを一度だけ取得することを確認するテスト
+func TestNextHopTrackerRefreshReadsRoutesOnce(t *testing.T) {
+	f := &countingFIB{Memory: fib.NewMemory()}
+	f.AddTableRoute(igpRoute("192.168.0.0/16", "10.200.100.1", 100))
+	tr := NewNextHopTracker(f)
+	for _, nh := range []string{"192.168.1.1", "192.168.2.1", "192.168.3.1"} {
+		tr.Resolve(net.ParseIP(nh))
+	}
+	f.AddTableRoute(igpRoute("192.168.2.0/24", "10.200.100.2", 10))
+	f.routes = 0
+	if !tr.refresh() {
+		t.Errorf("Want: %v, Got: %v", true, false)
+	}
+	if f.routes != 1 {
+		t.Errorf("Want: %v, Got: %v", 1, f.routes)
+	}
+	if r := tr.Resolve(net.ParseIP("192.168.2.1")); r.Metric != 10 {
+		t.Errorf("Want: %v, Got: %v", 10, r.Metric)
+	}
+}
+
+// NextHopに到達できない経路が除外され、IGPのコストが小さい経路が最適経路になることを確認するテスト
+func TestLocRibNextHopTracking(t *testing.T) {
+	_, connected, _ := net.ParseCIDR("10.200.100.0/24")
+	_, nw, _ := net.ParseCIDR("10.1.0.0/16")
+	f := fib.NewMemory(connected)
+	lr := &LocRib{Rib: NewRib(), LocalASNum: 64512, FIB: f, NextHops: NewNextHopTracker(f)}
+
+	installIBGPPath(t, lr, nw, "10.200.100.11", "192.168.1.1")
+	installIBGPPath(t, lr, nw, "10.200.100.12", "192.168.2.1")
+	if len(lr.Rib.Lookup(nw)) != 0 {
+		t.Errorf("route with unreachable next hop should not be installed")
+	}
+
+	// 192.168.2.1のほうがIGPのコストが小さい
+	f.AddTableRoute(igpRoute("192.168.1.0/24", "10.200.100.1", 100))
+	f.AddTableRoute(igpRoute("192.168.2.0/24", "10.200.100.2", 10))
+	lr.NextHops.refresh()
+	installIBGPPath(t, lr, nw, "10.200.100.11", "192.168.1.1")
+	installIBGPPath(t, lr, nw, "10.200.100.12", "192.168.2.1")
+	best := lr.Rib.Lookup(nw)
+	if len(best) != 1 || !best[0].PeerAddr.Equal(net.ParseIP("10.200.100.12")) {
+		t.Fatalf("Want: 10.200.100.12, Got: %v", best)
+	}
+	// カーネルには解決したゲートウェイを書き込む
+	if err := lr.WriteToKernelRoutingTable(); err != nil {
+		t.Fatal(err)
+	}
+	rs := f.Installed()
+	if len(rs) != 1 || !rs[0].NextHops[0].Equal(net.ParseIP("10.200.100.2")) {
+		t.Errorf("Want: 10.200.100.2, Got: %v", rs)
+	}
+}
+
+// NextHopの解決結果はLocRibの経路に設定し、AdjRibInの経路は変更しないことを確認するテスト
+func TestLocRibNextHopResolutionDoesNotModifyAdjRibIn(t *testing.T) {
+	_, connected, _ := net.ParseCIDR("10.200.100.0/24")
+	_, nw, _ := net.ParseCIDR("10.1.0.0/16")
+	f := fib.NewMemory(connected)
+	f.AddTableRoute(igpRoute("192.168.1.0/24", "10.200.100.1", 100))
+	lr := &LocRib{Rib: NewRib(), LocalASNum: 64512, FIB: f, NextHops: NewNextHopTracker(f)}
+	lr.NextHops.refresh()
+
+	config, _ := ParseConfig("64512 127.0.0.1 64512 10.200.100.11 active")
+	igp := bgptype.IGP
+	nh := bgptype.NextHop(net.ParseIP("192.168.1.1").To4())
+	um, _ := packets.NewUpdateMessage(
+		[]bgptype.PathAttribute{&igp, bgptype.NewAsPath(true, 65001), &nh},
+		[]*net.IPNet{nw},
+		[]*net.IPNet{},
+	)
+	ari := NewAdjRibIn(NewRib())
+	if err := ari.InstallFromUpdate(um, config); err != nil {
+		t.Fatal(err)
+	}
+	lr.InstallFromAdjRibIn(ari, config)
+	rt := ari.Rib.Lookup(nw)[0]
+	best := lr.Rib.Lookup(nw)
+	if len(best) != 1 || best[0] == rt {
+		t.Fatalf("Want: copy of %v, Got: %v", rt, best)
+	}
+	if rt.igpMetric != 0 || rt.gateway != nil || rt.localPathID != 0 {
+		t.Errorf("Want: unchanged, Got: %d %v %d", rt.igpMetric, rt.gateway, rt.localPathID)
+	}
+	if best[0].igpMetric != 100 || !best[0].gateway.Equal(net.ParseIP("10.200.100.1")) {
+		t.Errorf("Want: 100 10.200.100.1, Got: %d %v", best[0].igpMetric, best[0].gateway)
+	}
+	if got := lr.countBest([]*RibEntry{rt}); got != 1 {
+		t.Errorf("Want: 1, Got: %d", got)
+	}
+
+	// IGPのコストが変わると、Path Identifierを変えずに経路を置き換える
+	f.AddTableRoute(igpRoute("192.168.1.0/24", "10.200.100.1", 50))
+	lr.NextHops.refresh()
+	ari.Rib.MarkAllChanged()
+	lr.InstallFromAdjRibIn(ari, config)
+	cur := lr.Rib.Lookup(nw)
+	if len(cur) != 1 || cur[0].igpMetric != 50 || cur[0].localPathID != best[0].localPathID {
+		t.Errorf("Want: metric 50 with path id %d, Got: %v", best[0].localPathID, cur)
+	}
+
+	// AdjRibInから削除すると、複製した経路もLocRibから削除される
+	ari.Clear()
+	lr.InstallFromAdjRibIn(ari, config)
+	if got := lr.Rib.Lookup(nw); len(got) != 0 {
+		t.Errorf("Want: none, Got: %v", got)
+	}
+}
@@ -2,8 +2,14 @@ package peer
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
+	"reflect"
+	"sync"
+	"time"
 
+	"github.com/SotaUeda/gobgp/bgptype"
 	"github.com/SotaUeda/gobgp/packets"
 )
 
@@ -12,111 +18,814 @@ import (
 // 1つのPeerを1つのイベント駆動ステートマシンとして実装しています。
 // Peer構造体はRFC内で示されている実装方針に従ったイベント駆動ステートマシンです。
 type Peer struct {
-	State      State
-	EventQueue chan Event
-	// UpdateMessageを処理するため、強引にMessageを埋め込む
-	Msg       packets.Message
-	TCPConn   *Connection
+	State   State
+	TCPConn *Connection
+	// Config, AdjRibOut, AdjRibInはPeerのgoroutineが置き換えるため、
+	// ほかのgoroutineからはInfo, AdjRibInRoutes, AdjRibOutRoutesで参照する
 	Config    *Config
 	LocRib    *LocRib
 	AdjRibOut *AdjRibOut
 	AdjRibIn  *AdjRibIn
+	// Peerとの通信路。nilの場合はTCPを使う
+	Transport Transport
+	// タイマーで使う時計。nilの場合は実際の時刻を使う
+	Clock Clock
+	// 発生した順に処理するイベント
+	events eventQueue
+	// 処理しているイベント
+	event eventEntry
+	// LocRibで変わったプレフィックスのうち、AdjRibOutに反映していないもの
+	// LocRibから通知されるため、ほかのgoroutineからも書き換える
+	changedMu       sync.Mutex
+	changedPrefixes map[string]*net.IPNet
+	// Runに渡されたcontext。接続を試みるgoroutineに引き継ぐ
+	ctx context.Context
+	// 接続を試みている場合は、その試行をやめる関数と試行の番号
+	connectCancel context.CancelFunc
+	connectID     uint64
+	// 接続を試みるgoroutineと、Messageを受信するgoroutine
+	// Runはこれらが終了するまで待ってから返る
+	wg sync.WaitGroup
+	// Runが返るときに閉じる。キューが空くのを待っている受信のgoroutineを終了させる
+	quit chan struct{}
+	// Route Flap Dampingで抑制した経路を再利用するためのタイマー
+	reuseTimer Timer
+	// ネゴシエーションしたHold TimeとKeepaliveMessageの送信間隔
+	// 0の場合はタイマーを使用しない
+	holdTime          time.Duration
+	keepaliveInterval time.Duration
+	holdTimer         Timer
+	keepaliveTimer    Timer
+	// 最後にMessageを受信した時刻
+	lastRecv time.Time
+	// Stateが変わったときに呼び出す
+	// Peerのgoroutineから呼び出すため、処理を止めないようにする
+	OnStateChange func(p *Peer, old, new State)
+	// Messageを送受信したときに呼び出す。sentは送信した場合にtrue
+	// Peerのgoroutineから呼び出すため、処理を止めないようにする
+	OnMessage func(p *Peer, m packets.Message, sent bool)
+	// 送受信したMessageのバイト列を記録する。nilの場合は記録しない
+	Recorder MessageRecorder
+
+	// Infoでほかのgoroutineから参照する値は排他制御する
+	mu sync.Mutex
+	// 管理者によって無効にされている場合はtrue
+	// 無効にされている間は自動で再接続しない
+	disabled bool
+	// Establishedに遷移した時刻と、その時点でネゴシエーションしていたHold Time
+	establishedAt       time.Time
+	establishedHoldTime time.Duration
+	// 送受信したMessageの数
+	sent     MessageCounters
+	received MessageCounters
+	// 各Stateに遷移した回数
+	transitions map[State]uint64
+	// 現在のセッションのアドレスと、送受信したOpenMessage
+	// セッションが確立していない場合はnil
+	localAddr    *net.TCPAddr
+	remoteAddr   *net.TCPAddr
+	sentOpen     *packets.OpenMessage
+	receivedOpen *packets.OpenMessage
 }
 
+// PEER_DECONFIGUREDによってPeerが停止したことを表す
+// Runがこのエラーを返した後は、Peerを再び使うことはできない
+var ErrStopped = errors.New("peer is stopped")
+
 func NewPeer(conf *Config, locRib *LocRib) *Peer {
 	p := &Peer{
-		State:      IDLE,
-		EventQueue: make(chan Event),
-		Config:     conf,
-		LocRib:     locRib,
-		AdjRibOut:  NewAdjRibOut(NewRib()),
-		AdjRibIn:   NewAdjRibIn(NewRib()),
+		State:     IDLE,
+		Config:    conf,
+		LocRib:    locRib,
+		AdjRibOut: NewAdjRibOut(NewRib()),
 	}
+	p.AdjRibIn = p.newAdjRibIn()
 	return p
 }
 
+// 受信した経路を検証するVRPと、Route Flap Dampingの履歴を設定したAdjRibInを作成する
+// Dampingの履歴はAPIからも参照するため、経路を受信する前に作成しておく
+func (p *Peer) newAdjRibIn() *AdjRibIn {
+	ari := NewAdjRibIn(NewRib())
+	ari.Validator = p.LocRib.RPKI
+	ari.Clock = p.Clock
+	ari.enableDamping(p.Config.Damping)
+	return ari
+}
+
+func (p *Peer) clock() Clock {
+	if p.Clock == nil {
+		return systemClock{}
+	}
+	return p.Clock
+}
+
 func (p *Peer) Start() {
-	fmt.Print("peer is started.\n")
-	// channel は受信した場合でも送信されるまで処理が止まる
-	// goroutin で呼び出す必要がある
-	go func() { p.EventQueue <- MANUAL_START }()
+	p.log(fsmLog).Info("peer is started")
+	p.post(MANUAL_START)
+}
+
+// イベントをキューに積む
+// キューは積む側を止めないため、どのgoroutineから呼び出してもよい
+func (p *Peer) post(ev Event) {
+	p.events.push(eventEntry{ev: ev})
+}
+
+// LocRibで変わったプレフィックスをPeerに通知する
+// 反映していないプレフィックスがない場合だけLOC_RIB_CHANGEDを積み、
+// 処理するまでに通知されたプレフィックスはまとめて反映する。
+func (p *Peer) notifyLocRibChanged(nws []*net.IPNet) {
+	p.changedMu.Lock()
+	pending := len(p.changedPrefixes) > 0
+	if p.changedPrefixes == nil {
+		p.changedPrefixes = make(map[string]*net.IPNet)
+	}
+	for _, nw := range nws {
+		p.changedPrefixes[nw.String()] = nw
+	}
+	p.changedMu.Unlock()
+	if !pending {
+		p.post(LOC_RIB_CHANGED)
+	}
+}
+
+// 通知されたプレフィックスを取り出す
+func (p *Peer) takeLocRibChanges() []*net.IPNet {
+	p.changedMu.Lock()
+	defer p.changedMu.Unlock()
+	nws := make([]*net.IPNet, 0, len(p.changedPrefixes))
+	for _, nw := range p.changedPrefixes {
+		nws = append(nws, nw)
+	}
+	p.changedPrefixes = nil
+	return nws
 }
 
-func (p *Peer) Next(ctx context.Context) error {
+// RPKIのVRPが更新されたことをPeerに通知する
+// 受信した経路は次のイベント処理で検証し直される
+func (p *Peer) NotifyRPKIUpdated() {
+	p.post(RPKI_TABLE_CHANGED)
+}
+
+// NextHopの解決結果が変わったことをPeerに通知する
+// 受信した経路は次のイベント処理で最適経路を選択し直される
+func (p *Peer) NotifyNextHopChanged() {
+	p.post(NEXTHOP_CHANGED)
+}
+
+// Peerを停止する
+// セッションが確立している場合はCease NotificationMessageを送信して切断する
+func (p *Peer) Stop() {
+	p.post(PEER_DECONFIGURED)
+}
+
+// Peerを無効にする
+// セッションが確立している場合はCease NotificationMessageを送信して切断し、
+// Enableを呼び出すまで再接続しない。
+func (p *Peer) Disable() {
+	p.post(MANUAL_STOP)
+}
+
+// 無効にしたPeerを再び接続する
+func (p *Peer) Enable() {
+	p.post(MANUAL_START)
+}
+
+// Cease NotificationMessageを送信してセッションを切断し、再接続する
+func (p *Peer) Reset() {
+	p.post(RESET)
+}
+
+// セッションを維持したまま、受信した経路にImportPolicyを適用し直し、
+// 送信する経路をExportPolicyで選び直す
+func (p *Peer) SoftReset() {
+	p.post(SOFT_RESET)
+}
+
+// Peerに新しい設定を反映する
+// セッションを維持したまま反映できない変更の場合は、このPeerのセッションだけを張り直す。
+// 設定が変わっていなくても、自身で生成する経路の変更を反映するためにAdjRibOutの経路を選び直す。
+func (p *Peer) Reconfigure(c *Config) {
+	p.events.push(eventEntry{ev: CONFIG_CHANGED, config: c})
+}
+
+// ctxがキャンセルされるか、Peerが停止するまでイベントを発生した順に処理する
+// 接続とMessageの受信はそれぞれ別のgoroutineで行い、その結果もイベントとしてキューに積む。
+// ctxがキャンセルされた場合はコネクションを閉じてnilを返す。
+// PEER_DECONFIGUREDで停止した場合はErrStoppedを、イベントの処理に失敗した場合はそのエラーを返す。
+// どの場合も、接続と受信のgoroutineが終了するまで待ってから返る。
+func (p *Peer) Run(ctx context.Context) error {
+	p.ctx = ctx
+	defer p.wg.Wait()
+	p.quit = make(chan struct{})
+	defer close(p.quit)
+	// 起動している間は、ほかのPeerによるLocRibの変更も通知を受ける
+	p.LocRib.register(p)
+	defer p.LocRib.unregister(p)
+	// NewPeerの後に設定された時計をRoute Flap Dampingでも使うため、経路を受信する前に作り直す
+	ari := p.newAdjRibIn()
+	p.mu.Lock()
+	p.AdjRibIn = ari
+	p.mu.Unlock()
 	for {
 		select {
-		case ev := <-p.EventQueue:
-			fmt.Printf("event is occured, event=%v.\n", ev.Show())
-			if err := p.handleEvent(ev); err != nil {
+		case <-p.events.wait():
+		case <-ctx.Done():
+			return p.done()
+		}
+		for {
+			e, ok := p.events.pop()
+			if !ok {
+				break
+			}
+			if err := p.processEvent(e); err != nil {
+				p.done()
 				return err
 			}
-			return nil
-		case <-ctx.Done():
-			fmt.Print("func next is done.\n")
-			if p.TCPConn != nil {
-				p.TCPConn.conn.Close()
-				fmt.Print("close connection\n")
+			if ctx.Err() != nil {
+				return p.done()
+			}
+		}
+	}
+}
+
+func (p *Peer) processEvent(e eventEntry) error {
+	switch {
+	case e.connectID != 0:
+		// 接続をやめた後や、接続し直している場合は古い試行の結果を使わない
+		if p.connectCancel == nil || e.connectID != p.connectID {
+			if e.conn != nil {
+				e.conn.Close()
 			}
 			return nil
-		default:
-			if p.TCPConn != nil && p.State != CONNECT {
-				m, err := p.TCPConn.Recv()
-				if err != nil {
-					return err
+		}
+		p.connectCancel()
+		p.connectCancel = nil
+	case e.conn != nil && e.conn != p.TCPConn:
+		// 張り直す前のコネクションのイベントは処理しない
+		return nil
+	}
+	if e.msg != nil {
+		p.receive(e.msg)
+	}
+	p.event = e
+	defer func() { p.event = eventEntry{} }()
+	p.log(fsmLog).Debug("event is occurred", "event", e.ev.Show())
+	return p.handleEvent(e.ev)
+}
+
+// 受信したMessageを数え、ログとOnMessageに渡す
+func (p *Peer) receive(m packets.Message) {
+	p.lastRecv = p.clock().Now()
+	p.mu.Lock()
+	p.received.count(m)
+	if om, ok := m.(*packets.OpenMessage); ok {
+		p.receivedOpen = om
+	}
+	p.mu.Unlock()
+	p.log(packetLog).Debug("message is received", "type", messageType(m), "message", lazy(m.String))
+	if p.OnMessage != nil {
+		p.OnMessage(p, m, false)
+	}
+}
+
+// Stateを変更し、変わった場合はOnStateChangeを呼び出す
+func (p *Peer) setState(s State) {
+	p.mu.Lock()
+	old := p.State
+	p.State = s
+	if old != s {
+		if p.transitions == nil {
+			p.transitions = make(map[State]uint64)
+		}
+		p.transitions[s]++
+	}
+	if s == ESTABLISHED {
+		p.establishedAt = p.clock().Now()
+		p.establishedHoldTime = p.holdTime
+	} else {
+		p.establishedAt = time.Time{}
+		p.establishedHoldTime = 0
+	}
+	p.mu.Unlock()
+	if old != s {
+		peerLogger(fsmLog, p.Config).Info("state is changed", "old", old.Show(), "new", s.Show())
+	}
+	if old != s && p.OnStateChange != nil {
+		p.OnStateChange(p, old, s)
+	}
+}
+
+// Messageを送信し、送信したMessageの数を数える
+func (p *Peer) send(m packets.Message) error {
+	if err := p.TCPConn.Send(m); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	p.sent.count(m)
+	if om, ok := m.(*packets.OpenMessage); ok {
+		p.sentOpen = om
+	}
+	p.mu.Unlock()
+	p.log(packetLog).Debug("message is sent", "type", messageType(m), "message", lazy(m.String))
+	if p.OnMessage != nil {
+		p.OnMessage(p, m, true)
+	}
+	return nil
+}
+
+func (p *Peer) done() error {
+	p.log(fsmLog).Info("peer is done")
+	p.stopConnecting()
+	p.stopTimers()
+	if p.TCPConn != nil {
+		p.TCPConn.Close()
+		p.log(fsmLog).Debug("connection is closed")
+	}
+	return nil
+}
+
+// 別のgoroutineでPeerと接続し、結果をイベントとしてキューに積む
+// PassiveモードではPeerからの接続を待つため、待っている間もほかのイベントを処理できるようにする。
+func (p *Peer) connect() {
+	ctx := p.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	p.connectCancel = cancel
+	p.connectID++
+	id, t, c := p.connectID, p.Transport, p.Config
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		// 参考記事 https://qiita.com/tutuz/items/e875d8ea3c31450195a7
+		conn, err := NewConnection(ctx, t, c)
+		if ctx.Err() != nil {
+			// 接続をやめた場合は、結果を使わない
+			if conn != nil {
+				conn.Close()
+			}
+			return
+		}
+		if err != nil {
+			p.events.push(eventEntry{ev: TCP_CONNECTION_FAILS, connectID: id, err: err})
+			return
+		}
+		p.events.push(eventEntry{ev: TCP_CONNECTION_CONFIRMED, conn: conn, connectID: id})
+	}()
+}
+
+// 接続を試みている場合はやめる
+func (p *Peer) stopConnecting() {
+	if p.connectCancel != nil {
+		p.connectCancel()
+		p.connectCancel = nil
+	}
+}
+
+// 別のgoroutineでconnからMessageを受信し、イベントとしてキューに積む
+// connが閉じられるか受信に失敗すると、TCP_CONNECTION_FAILSを積んで終了する
+// 処理していないMessageが多い場合は、処理が追いつくまで受信を止める。
+func (p *Peer) startReceiving(conn *Connection) {
+	quit := p.quit
+	// 書き込みを待っていても受信をやめられるように、ctxがキャンセルされたらコネクションをすぐに閉じる
+	stop := func() bool { return false }
+	if p.ctx != nil {
+		stop = context.AfterFunc(p.ctx, func() { conn.abort() })
+	}
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		defer stop()
+		for {
+			m, err := conn.Recv()
+			// 壊れたMessageを受信した場合は、NotificationMessageを送ってからセッションを切断する
+			var fe *packets.FormatError
+			if errors.As(err, &fe) {
+				ev := BGP_HEADER_ERR
+				switch fe.Code {
+				case packets.OpenMessageError:
+					ev = BGP_OPEN_MSG_ERR
+				case packets.UpdateMessageError:
+					ev = UPDATE_MSG_ERR
 				}
-				fmt.Printf("message is received, message=%v.\n", m.Show())
-				p.handleMessage(m)
-				return nil
+				p.events.push(eventEntry{ev: ev, conn: conn, err: fe})
+				return
+			}
+			if err != nil {
+				// 書き込みに失敗してコネクションを閉じた場合は、書き込みのエラーを通知する
+				if werr := conn.writeErr(); werr != nil {
+					err = werr
+				}
+				p.events.push(eventEntry{ev: TCP_CONNECTION_FAILS, conn: conn, err: err})
+				return
+			}
+			var ev Event
+			switch m.(type) {
+			case *packets.OpenMessage:
+				ev = BGP_OPEN
+			case *packets.KeepaliveMessage:
+				ev = KEEPALIVE_MSG
+			case *packets.UpdateMessage:
+				ev = UPDATE_MSG
+			case *packets.NotificationMessage:
+				ev = NOTIFICATION_MSG
+			default:
+				continue
+			}
+			if !p.events.pushMessage(eventEntry{ev: ev, msg: m, conn: conn}, quit) {
+				return
+			}
+		}
+	}()
+}
+
+// dが経過した後に、現在のコネクションのイベントとしてevを積むタイマーを設定する
+func (p *Peer) afterConn(d time.Duration, ev Event) Timer {
+	conn := p.TCPConn
+	return p.clock().AfterFunc(d, func() {
+		p.events.push(eventEntry{ev: ev, conn: conn})
+	})
+}
+
+// ネゴシエーションしたHold TimeとKeepaliveの間隔でタイマーを開始する
+func (p *Peer) startTimers() {
+	p.stopTimers()
+	if p.holdTime > 0 {
+		p.holdTimer = p.afterConn(p.holdTime, HOLD_TIMER_EXPIRES)
+	}
+	if p.keepaliveInterval > 0 {
+		p.keepaliveTimer = p.afterConn(p.keepaliveInterval, KEEPALIVE_TIMER_EXPIRES)
+	}
+}
+
+func (p *Peer) stopTimers() {
+	if p.holdTimer != nil {
+		p.holdTimer.Stop()
+		p.holdTimer = nil
+	}
+	if p.keepaliveTimer != nil {
+		p.keepaliveTimer.Stop()
+		p.keepaliveTimer = nil
+	}
+}
+
+// KeepaliveMessageを送信し、次に送信する時刻にタイマーを設定する
+func (p *Peer) sendKeepalive() error {
+	p.keepaliveTimer = p.afterConn(p.keepaliveInterval, KEEPALIVE_TIMER_EXPIRES)
+	if err := p.send(packets.NewKeepaliveMessage()); err != nil {
+		p.connectionFailed(err)
+	}
+	return nil
+}
+
+// 確立したコネクションへの送信に失敗したことを、受信に失敗した場合と同じくTCP_CONNECTION_FAILSで処理する
+func (p *Peer) connectionFailed(err error) {
+	p.events.push(eventEntry{ev: TCP_CONNECTION_FAILS, conn: p.TCPConn, err: err})
+}
+
+// OpenMessageで送信するCapability
+func (p *Peer) capabilities() []packets.Capability {
+	caps := []packets.Capability{}
+	ap := &packets.AddPathCapability{}
+	for _, c := range p.Config.AddPath {
+		var mode packets.AddPathMode
+		if c.Receive {
+			mode |= packets.AddPathReceive
+		}
+		if c.Send {
+			mode |= packets.AddPathSend
+		}
+		if mode != 0 {
+			ap.Families = append(ap.Families, packets.AddPathFamily{Family: c.Family, Mode: mode})
+		}
+	}
+	if len(ap.Families) > 0 {
+		caps = append(caps, ap)
+	}
+	return caps
+}
+
+// Peerから受信したOpenMessageのCapabilityと自身の設定から、
+// セッションで使用する機能を決める。
+// 本実装ではIPv4 Unicastのみ扱う。
+func (p *Peer) negotiate(om *packets.OpenMessage) error {
+	caps, err := om.Capabilities()
+	if err != nil {
+		return err
+	}
+	var remote packets.AddPathMode
+	for _, c := range caps {
+		if ap, ok := c.(*packets.AddPathCapability); ok {
+			remote = ap.Mode(packets.IPv4Unicast)
+		}
+	}
+	p.negotiateTimers(om)
+	// Hold Timeの間に書き込めない場合は、Peerが読み込んでいないとみなしてコネクションを閉じる
+	p.TCPConn.SetWriteTimeout(p.holdTime)
+	p.startTimers()
+	local := p.Config.addPathConfig(packets.IPv4Unicast)
+	// 自身が受信でき、Peerが送信する場合はPath Identifier付きの経路を受信する
+	opts := p.TCPConn.Options()
+	opts.AddPath = local != nil && local.Receive && remote.CanSend()
+	p.TCPConn.SetOptions(opts)
+	// 自身が送信でき、Peerが受信できる場合はPath Identifier付きの経路を送信する
+	if local != nil && local.Send && remote.CanReceive() {
+		p.AdjRibOut.AddPath = local
+	} else {
+		p.AdjRibOut.AddPath = nil
+	}
+	return nil
+}
+
+// OpenMessageを送信したPeerのAS番号を返す
+// 4-octet AS Number Capabilityがある場合は、My Autonomous SystemのAS_TRANSではなくCapabilityの値を使う
+func openAS(om *packets.OpenMessage) uint32 {
+	caps, err := om.Capabilities()
+	if err == nil {
+		for _, c := range caps {
+			if fc, ok := c.(*packets.FourOctetASCapability); ok {
+				return fc.AS
 			}
 		}
 	}
+	return uint32(om.MyAS)
 }
 
-func (p *Peer) handleMessage(m packets.Message) {
-	switch m.(type) {
-	case *packets.OpenMessage:
-		go func() { p.EventQueue <- BGP_OPEN }()
-	case *packets.KeepaliveMessage:
-		go func() { p.EventQueue <- KEEPALIVE_MSG }()
-	case *packets.UpdateMessage:
-		go func() {
-			p.Msg = m
-			p.EventQueue <- UPDATE_MSG
-		}()
+// 自身とPeerが提案したHold Timeの小さい方をセッションで使用する
+// どちらかが0の場合は、Hold TimerとKeepalive Timerを使用しない
+func (p *Peer) negotiateTimers(om *packets.OpenMessage) {
+	local := p.Config.Timers.HoldTime
+	remote := time.Duration(om.HoldTime) * time.Second
+	p.holdTime, p.keepaliveInterval = 0, 0
+	if local == 0 || remote == 0 {
+		return
+	}
+	p.holdTime = min(local, remote)
+	p.keepaliveInterval = p.Config.Timers.KeepaliveInterval
+	if p.keepaliveInterval == 0 || p.keepaliveInterval > p.holdTime/3 {
+		p.keepaliveInterval = p.holdTime / 3
+	}
+	p.lastRecv = p.clock().Now()
+}
+
+// NotificationMessageを送信し、セッションを切断してIdleに戻る。
+// restartが0より大きい場合は、その時間が経過した後に再接続する。
+func (p *Peer) shutdown(nm *packets.NotificationMessage, restart time.Duration) error {
+	if p.TCPConn != nil {
+		if err := p.send(nm); err != nil {
+			p.log(fsmLog).Warn("cannot send notification", "error", err)
+		}
+	}
+	p.release()
+	if restart > 0 {
+		p.log(fsmLog).Info("peer will be restarted", "after", restart)
+		p.clock().AfterFunc(restart, func() { p.post(AUTOMATIC_START) })
+	}
+	return nil
+}
+
+// Reconfigureで渡された設定を反映する
+func (p *Peer) reconfigure() error {
+	c := p.event.config
+	if c == nil {
+		return nil
+	}
+	old := p.Config
+	p.mu.Lock()
+	p.Config = c
+	p.mu.Unlock()
+	if old.needsRestart(c) {
+		p.log(fsmLog).Info("peer is restarted to apply config change")
+		// 接続を試みていないIdleの場合は、次に接続するときに新しい設定を使う
+		connected := p.State != IDLE || p.connectCancel != nil
+		// AS番号などの変更は、セッションを張り直さないと反映できない
+		if err := p.shutdown(
+			packets.NewNotificationMessage(packets.Cease, packets.OtherConfigurationChange, nil),
+			0,
+		); err != nil {
+			return err
+		}
+		// Dampingの履歴は新しい設定で作り直す
+		ari := p.newAdjRibIn()
+		p.mu.Lock()
+		p.AdjRibIn = ari
+		p.mu.Unlock()
+		if connected {
+			p.post(AUTOMATIC_START)
+		}
+		return nil
+	}
+	if p.State != ESTABLISHED {
+		return nil
+	}
+	p.softReset(!reflect.DeepEqual(old.ImportPolicy, c.ImportPolicy))
+	return nil
+}
+
+// AdjRibOutの経路を選び直し、inboundがtrueの場合はAdjRibInの経路をLocRibにインストールし直す
+func (p *Peer) softReset(inbound bool) {
+	// AdjRibInにはPolicyを適用する前の経路を保持しているため、
+	// Peerに経路を送り直してもらわなくてもImportPolicyの変更を反映できる (Soft Reconfiguration Inbound)
+	if inbound {
+		p.AdjRibIn.Rib.MarkAllChanged()
+		p.post(ADJ_RIB_IN_CHANGED)
+	}
+	p.AdjRibOut.InstallFromLocRib(p.LocRib, p.Config)
+	if p.AdjRibOut.HasChanges() {
+		p.post(ADJ_RIB_OUT_CHANGED)
+	}
+}
+
+// Route Flap Dampingで抑制した経路を次に再利用できる時刻にタイマーを設定する
+func (p *Peer) scheduleDampingReuse() {
+	next, ok := p.AdjRibIn.NextDampingReuse()
+	if !ok {
+		return
+	}
+	if p.reuseTimer != nil {
+		p.reuseTimer.Stop()
+	}
+	// 減衰の計算誤差でタイマーが連続して発火しないように、最低1秒は待つ
+	d := max(next.Sub(p.clock().Now()), time.Second)
+	p.reuseTimer = p.clock().AfterFunc(d, func() { p.post(DAMPING_REUSE_TIMER_EXPIRES) })
+}
+
+// コネクションを閉じ、Peerから受信した経路を取り除いてIdleに戻る
+// 接続を試みている場合はやめる
+func (p *Peer) release() {
+	p.stopConnecting()
+	p.stopTimers()
+	if p.TCPConn != nil {
+		p.TCPConn.Close()
+		p.TCPConn = nil
+	}
+	p.AdjRibIn.Clear()
+	p.LocRib.InstallFromAdjRibIn(p.AdjRibIn, p.Config)
+	p.holdTime, p.keepaliveInterval = 0, 0
+	p.mu.Lock()
+	p.AdjRibOut = NewAdjRibOut(NewRib())
+	p.localAddr, p.remoteAddr = nil, nil
+	p.sentOpen, p.receivedOpen = nil, nil
+	p.mu.Unlock()
+	p.setState(IDLE)
+}
+
+// ConnectRetryTimeが設定されている場合は、その時間が経過した後に再接続する
+func (p *Peer) scheduleConnectRetry() {
+	if d := p.Config.Timers.ConnectRetryTime; d > 0 {
+		p.log(fsmLog).Debug("peer will be restarted", "after", d)
+		p.clock().AfterFunc(d, func() { p.post(AUTOMATIC_START) })
 	}
 }
 
 func (p *Peer) handleEvent(ev Event) error {
+	// NotificationMessageを受信した場合は、どのStateでもセッションを切断する
+	if ev == NOTIFICATION_MSG {
+		if nm, ok := p.event.msg.(*packets.NotificationMessage); ok {
+			p.log(fsmLog).Warn(
+				"notification is received",
+				"code", nm.ErrorCode.Show(), "subcode", nm.ErrorSubcode, "data", nm.Data,
+			)
+		}
+		p.release()
+		p.scheduleConnectRetry()
+		return nil
+	}
+	// 壊れたMessageを受信した場合は、どのStateでもエラーの内容を通知してセッションを切断する
+	if ev == BGP_HEADER_ERR || ev == BGP_OPEN_MSG_ERR || ev == UPDATE_MSG_ERR {
+		var fe *packets.FormatError
+		if !errors.As(p.event.err, &fe) {
+			return fmt.Errorf("%sのエラーが不正です。Error: %v", ev.Show(), p.event.err)
+		}
+		p.log(fsmLog).Warn("peer is shut down: malformed message", "error", fe)
+		err := p.shutdown(fe.Notification(), 0)
+		p.scheduleConnectRetry()
+		return err
+	}
+	// 確立したコネクションで受信できなくなった場合は、どのStateでもIdleに戻る
+	if ev == TCP_CONNECTION_FAILS && p.State != IDLE {
+		p.log(fsmLog).Warn("connection is lost", "error", p.event.err)
+		p.release()
+		p.scheduleConnectRetry()
+		return nil
+	}
+	// Hold Timeの間にMessageを受信しなかった場合は、どのStateでもセッションを切断する
+	if ev == HOLD_TIMER_EXPIRES {
+		// 満了するまでにMessageを受信していた場合は、残りの時間でタイマーを設定し直す
+		if rest := p.lastRecv.Add(p.holdTime).Sub(p.clock().Now()); rest > 0 {
+			p.holdTimer = p.afterConn(rest, HOLD_TIMER_EXPIRES)
+			return nil
+		}
+		p.log(fsmLog).Warn("peer is shut down: hold timer expired", "hold_time", p.holdTime)
+		err := p.shutdown(
+			packets.NewNotificationMessage(packets.HoldTimerExpired, 0, nil),
+			0,
+		)
+		p.scheduleConnectRetry()
+		return err
+	}
+	if ev == MANUAL_STOP {
+		p.log(fsmLog).Info("peer is disabled")
+		p.mu.Lock()
+		p.disabled = true
+		p.mu.Unlock()
+		return p.shutdown(
+			packets.NewNotificationMessage(packets.Cease, packets.AdministrativeShutdown, nil),
+			0,
+		)
+	}
+	if ev == PEER_DECONFIGURED {
+		p.log(fsmLog).Info("peer is stopped")
+		if err := p.shutdown(
+			packets.NewNotificationMessage(packets.Cease, packets.PeerDeConfigured, nil),
+			0,
+		); err != nil {
+			return err
+		}
+		return ErrStopped
+	}
+	if ev == CONFIG_CHANGED {
+		return p.reconfigure()
+	}
+	if ev == RESET && p.State != IDLE {
+		p.log(fsmLog).Info("peer is reset")
+		if err := p.shutdown(
+			packets.NewNotificationMessage(packets.Cease, packets.AdministrativeReset, nil),
+			0,
+		); err != nil {
+			return err
+		}
+		p.post(AUTOMATIC_START)
+		return nil
+	}
+	// 通知されたプレフィックスはどのStateでも取り出し、Establishedの場合だけ反映する
+	// Establishedに遷移したときには、LocRibのすべての経路をインストールする
+	if ev == LOC_RIB_CHANGED {
+		nws := p.takeLocRibChanges()
+		if p.State == ESTABLISHED {
+			p.AdjRibOut.InstallPrefixes(p.LocRib, p.Config, nws)
+			if p.AdjRibOut.HasChanges() {
+				p.post(ADJ_RIB_OUT_CHANGED)
+			}
+		}
+		return nil
+	}
+	if ev == SOFT_RESET && p.State == ESTABLISHED {
+		p.softReset(true)
+		return nil
+	}
 	switch p.State {
 	case IDLE:
 		switch ev {
-		case MANUAL_START:
-			// 参考記事 https://qiita.com/tutuz/items/e875d8ea3c31450195a7
-			conn, err := NewConnection(p.Config)
-			if err != nil {
-				return err
+		case MANUAL_START, AUTOMATIC_START:
+			p.mu.Lock()
+			if ev == MANUAL_START {
+				p.disabled = false
 			}
-			p.TCPConn = conn
-			if p.TCPConn == nil {
-				return fmt.Errorf("TCP Connectionが確立できませんでした")
+			disabled := p.disabled
+			p.mu.Unlock()
+			if disabled {
+				return nil
 			}
-			p.State = CONNECT
-			go func() { p.EventQueue <- TCP_CONNECTION_CONFIRMED }()
-		}
-	case CONNECT:
-		switch ev {
+			// 接続を試みている場合は、その結果を待つ
+			if p.connectCancel != nil {
+				return nil
+			}
+			p.connect()
 		case TCP_CONNECTION_CONFIRMED:
-			if p.TCPConn == nil {
-				return fmt.Errorf("TCP Connectionが確立できていません")
+			conn := p.event.conn
+			if conn == nil {
+				return fmt.Errorf("TCP Connectionが確立できませんでした")
 			}
-			err := p.TCPConn.Send(packets.NewOpenMessage(
+			p.TCPConn = conn
+			conn.SetWriteTimeout(p.Config.Timers.HoldTime)
+			if p.Recorder != nil {
+				conn.record = p.messageRecorder(conn)
+			}
+			p.mu.Lock()
+			p.localAddr, p.remoteAddr = conn.LocalAddr(), conn.RemoteAddr()
+			p.mu.Unlock()
+			p.startReceiving(conn)
+			// Connectは、コネクションを確立してからOpenMessageを送信するまでのState
+			p.setState(CONNECT)
+			om := packets.NewOpenMessage(
 				p.Config.LocalAS,
-				p.Config.LocalIP,
-			))
-			if err != nil {
+				p.Config.routerID(),
+				p.capabilities()...,
+			)
+			om.HoldTime = bgptype.HoldTime(p.Config.Timers.HoldTime / time.Second)
+			if err := p.send(om); err != nil {
 				return err
 			}
-			p.State = OPEN_SENT
+			p.setState(OPEN_SENT)
+		case TCP_CONNECTION_FAILS:
+			if p.Config.Timers.ConnectRetryTime > 0 {
+				p.scheduleConnectRetry()
+				return nil
+			}
+			return p.event.err
 		}
 	case OPEN_SENT:
 		switch ev {
@@ -124,31 +833,49 @@ func (p *Peer) handleEvent(ev Event) error {
 			if p.TCPConn == nil {
 				return fmt.Errorf("TCP Connectionが確立できていません")
 			}
-			err := p.TCPConn.Send(packets.NewKeepaliveMessage())
+			if om, ok := p.event.msg.(*packets.OpenMessage); ok {
+				// 設定と異なるAS番号のPeerとはセッションを確立しない
+				if as := openAS(om); as != uint32(p.Config.RemoteAS) {
+					p.log(fsmLog).Warn("peer is shut down: bad peer as", "as", as)
+					err := p.shutdown(
+						packets.NewNotificationMessage(packets.OpenMessageError, packets.BadPeerAS, nil),
+						0,
+					)
+					p.scheduleConnectRetry()
+					return err
+				}
+				if err := p.negotiate(om); err != nil {
+					return err
+				}
+			}
+			err := p.send(packets.NewKeepaliveMessage())
 			if err != nil {
 				return err
 			}
-			p.State = OPEN_CONFIRM
+			p.setState(OPEN_CONFIRM)
 		}
 	case OPEN_CONFIRM:
 		switch ev {
+		case KEEPALIVE_TIMER_EXPIRES:
+			return p.sendKeepalive()
 		case KEEPALIVE_MSG:
-			p.State = ESTABLISHED
-			go func() { p.EventQueue <- ESTABLISHED_STATE_EVENT }()
+			p.setState(ESTABLISHED)
+			p.post(ESTABLISHED_STATE_EVENT)
 		}
 	case ESTABLISHED:
 		switch ev {
-		case ESTABLISHED_STATE_EVENT, LOC_RIB_CHANGED:
-			locRib := p.LocRib
-			p.AdjRibOut.InstallFromLocRib(locRib, p.Config)
-			if p.AdjRibOut.Rib.DoseContainNewRoute() {
-				go func() { p.EventQueue <- ADJ_RIB_OUT_CHANGED }()
-				p.AdjRibOut.Rib.UpsateToAllUnchanged()
+		case KEEPALIVE_TIMER_EXPIRES:
+			return p.sendKeepalive()
+		case ESTABLISHED_STATE_EVENT:
+			p.AdjRibOut.InstallFromLocRib(p.LocRib, p.Config)
+			if p.AdjRibOut.HasChanges() {
+				p.post(ADJ_RIB_OUT_CHANGED)
 			}
 		case ADJ_RIB_OUT_CHANGED:
 			ums, err := p.AdjRibOut.ToUpdateMessages(
 				p.Config.LocalIP,
 				p.Config.LocalAS,
+				p.Config.isIBGP(),
 			)
 			if err != nil {
 				return err
@@ -157,27 +884,55 @@ func (p *Peer) handleEvent(ev Event) error {
 				if p.TCPConn == nil {
 					return fmt.Errorf("TCP Connectionが確立できていません")
 				}
-				p.TCPConn.Send(um)
+				if err := p.send(um); err != nil {
+					p.connectionFailed(err)
+					return nil
+				}
 			}
 		case UPDATE_MSG:
-			if p.Msg == nil {
+			um, ok := p.event.msg.(*packets.UpdateMessage)
+			if !ok {
 				return fmt.Errorf("UpdateMessageがありません")
 			}
-			um := p.Msg.(*packets.UpdateMessage)
-			p.AdjRibIn.InstallFromUpdate(um, p.Config)
-			if p.AdjRibIn.Rib.DoseContainNewRoute() {
-				fmt.Println("adj_rib in is updated.")
-				go func() { p.EventQueue <- ADJ_RIB_IN_CHANGED }()
-				p.AdjRibIn.Rib.UpsateToAllUnchanged()
+			err := p.AdjRibIn.InstallFromUpdate(um, p.Config)
+			var mpErr *MaxPrefixExceededError
+			if errors.As(err, &mpErr) {
+				p.log(fsmLog).Warn("peer is shut down", "error", err)
+				return p.shutdown(
+					// 本実装ではIPv4 Unicastのみ扱う
+					packets.NewMaxPrefixNotificationMessage(
+						packets.AFI_IPV4, packets.SAFI_UNICAST, mpErr.Limit,
+					),
+					p.Config.MaxPrefix.RestartTime,
+				)
+			}
+			if err != nil {
+				return err
+			}
+			p.scheduleDampingReuse()
+			if p.AdjRibIn.Rib.DoseContainNewRoute() || p.AdjRibIn.HasWithdrawnRoute() {
+				p.log(ribLog).Debug("adj_rib_in is updated")
+				p.post(ADJ_RIB_IN_CHANGED)
+			}
+		case DAMPING_REUSE_TIMER_EXPIRES:
+			if p.AdjRibIn.ReuseDampedRoutes() {
+				p.post(ADJ_RIB_IN_CHANGED)
 			}
+			p.scheduleDampingReuse()
+		case RPKI_TABLE_CHANGED:
+			if p.AdjRibIn.Revalidate(p.Config) {
+				p.post(ADJ_RIB_IN_CHANGED)
+			}
+		case NEXTHOP_CHANGED:
+			// AdjRibInの経路をLocRibにインストールし直す際にNextHopを解決し直す
+			p.AdjRibIn.Rib.MarkAllChanged()
+			p.post(ADJ_RIB_IN_CHANGED)
 		case ADJ_RIB_IN_CHANGED:
-			p.LocRib.InstallFromAdjRibIn(p.AdjRibIn)
-			if p.LocRib.Rib.DoseContainNewRoute() {
-				if err := p.LocRib.WriteToKernelRoutingTable(); err != nil {
-					return err
-				}
-				go func() { p.EventQueue <- LOC_RIB_CHANGED }()
-				p.LocRib.Rib.UpsateToAllUnchanged()
+			p.LocRib.InstallFromAdjRibIn(p.AdjRibIn, p.Config)
+			// 一部のルートの書き込みに失敗しても、BGPのセッションは維持する
+			// 最適経路が変わったプレフィックスは、LocRibから各PeerにLOC_RIB_CHANGEDで通知される
+			if err := p.LocRib.WriteToKernelRoutingTable(); err != nil {
+				p.log(fibLog).Error("cannot write routes to kernel routing table", "error", err)
 			}
 		}
 	}
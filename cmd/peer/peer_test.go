@@ -1,157 +1,761 @@
 package peer
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
 	"testing"
 	"time"
+
+	"github.com/SotaUeda/gobgp/bgptype"
+	"github.com/SotaUeda/gobgp/fib"
+	"github.com/SotaUeda/gobgp/packets"
+	"github.com/SotaUeda/gobgp/policy"
 )
 
-func TestPeerCanTransitionToConnectState(t *testing.T) {
-	config, _ := ParseConfig("64512 127.0.0.1 64513 127.0.0.2 active")
-	locRib, err := NewLocRib(config)
+// メモリ上の通信路で接続するPeerを作成する
+func newTestPeer(t *testing.T, conf string, n *MemoryNetwork, clock Clock) *Peer {
+	t.Helper()
+	config, err := ParseConfig(conf)
 	if err != nil {
-		t.Errorf("Error: %v", err)
+		t.Fatalf("Error: %v", err)
 	}
-	peer := NewPeer(config, locRib)
+	locRib, err := NewLocRib(config, fib.NewMemory())
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	p := NewPeer(config, locRib)
+	p.Transport, p.Clock = n, clock
+	return p
+}
+
+// ctxがキャンセルされるか、Peerが停止するまで別のgoroutineでPeerを動かす
+func runTestPeer(ctx context.Context, p *Peer) {
+	go p.Run(ctx)
+}
+
+// condがtrueになるまで待つ
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for i := 0; i < 500; i++ {
+		if cond() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %s", what)
+}
+
+// sに一度でも遷移するまで待つ
+func waitForTransition(t *testing.T, p *Peer, s State) {
+	t.Helper()
+	waitFor(t, s.Show(), func() bool { return p.Info().Transitions[s] > 0 })
+}
+
+// Passiveモードのremote_peerを動かし、Listenするまで待つ
+func startPassivePeer(t *testing.T, ctx context.Context, conf string, n *MemoryNetwork, clock Clock) *Peer {
+	t.Helper()
+	p := newTestPeer(t, conf, n, clock)
+	p.Start()
+	runTestPeer(ctx, p)
+	addr := &net.TCPAddr{IP: p.Config.LocalIP, Port: BGP_PORT}
+	waitFor(t, "listen", func() bool { return waitingFor(n, addr, p.Config.RemoteIP) })
+	return p
+}
+
+// addrで待ち受けるListenerに、remoteからのコネクションを待っているPeerがいるか
+func waitingFor(n *MemoryNetwork, addr *net.TCPAddr, remote net.IP) bool {
+	sharedListeners.mu.Lock()
+	defer sharedListeners.mu.Unlock()
+	sl, ok := sharedListeners.m[listenerKey{n, addr.String()}]
+	if !ok {
+		return false
+	}
+	_, ok = sl.waiters[remote.String()]
+	return ok
+}
+
+func TestPeerCanTransitionToConnectState(t *testing.T) {
+	n := NewMemoryNetwork(nil)
+	peer := newTestPeer(t, "64512 127.0.0.1 64513 127.0.0.2 active", n, nil)
 	peer.Start()
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
-	go func() {
-		remote_config, _ := ParseConfig("64513 127.0.0.2 64512 127.0.0.1 passive")
-		remote_locRib, err := NewLocRib(remote_config)
+	startPassivePeer(t, ctx, "64513 127.0.0.2 64512 127.0.0.1 passive", n, nil)
+	runTestPeer(ctx, peer)
+	waitForTransition(t, peer, CONNECT)
+}
+
+func TestPeerCanTransitionToOpenSentState(t *testing.T) {
+	n := NewMemoryNetwork(nil)
+	peer := newTestPeer(t, "64512 127.0.0.3 64513 127.0.0.4 active", n, nil)
+	peer.Start()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	startPassivePeer(t, ctx, "64513 127.0.0.4 64512 127.0.0.3 passive", n, nil)
+	runTestPeer(ctx, peer)
+	waitForTransition(t, peer, OPEN_SENT)
+}
+
+func TestPeerCanTransitionToOpenConfirmState(t *testing.T) {
+	n := NewMemoryNetwork(nil)
+	peer := newTestPeer(t, "64512 127.0.0.5 64513 127.0.0.6 active", n, nil)
+	peer.Start()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	startPassivePeer(t, ctx, "64513 127.0.0.6 64512 127.0.0.5 passive", n, nil)
+	runTestPeer(ctx, peer)
+	waitForTransition(t, peer, OPEN_CONFIRM)
+}
+
+func TestPeerCanTransitionToEstablishedState(t *testing.T) {
+	n := NewMemoryNetwork(nil)
+	peer := newTestPeer(t, "64512 127.0.0.7 64513 127.0.0.8 active", n, nil)
+	peer.Start()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	remote := startPassivePeer(t, ctx, "64513 127.0.0.8 64512 127.0.0.7 passive", n, nil)
+	runTestPeer(ctx, peer)
+	waitFor(t, "established", func() bool { return peer.Info().State == ESTABLISHED })
+	waitFor(t, "remote established", func() bool { return remote.Info().State == ESTABLISHED })
+}
+
+// ctxをキャンセルすると、Runがコネクションを閉じて返り、
+// remote_peerはコネクションが切れたことを検知してIdleに戻ることを確認するテスト
+func TestPeerRunReturnsWhenContextIsCanceled(t *testing.T) {
+	n := NewMemoryNetwork(nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	remote := startPassivePeer(t, ctx, "64513 127.0.0.2 64512 127.0.0.1 passive", n, nil)
+	p := newTestPeer(t, "64512 127.0.0.1 64513 127.0.0.2 active", n, nil)
+	p.Start()
+	pctx, pcancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- p.Run(pctx) }()
+	waitFor(t, "established", func() bool {
+		return p.Info().State == ESTABLISHED && remote.Info().State == ESTABLISHED
+	})
+
+	pcancel()
+	select {
+	case err := <-done:
 		if err != nil {
-			t.Errorf("Error: %v", err)
+			t.Errorf("Want: nil, Got: %v", err)
 		}
-		remote_peer := NewPeer(remote_config, remote_locRib)
-		remote_peer.Start()
-		remote_peer.Next(ctx)
-	}()
-	// remote_peer側の処理が進むことを保証するためのwait
-	time.Sleep(1 * time.Second)
-	peer.Next(ctx)
-	peer.TCPConn.conn.Close()
-	want := CONNECT
-	if want != peer.State {
-		t.Errorf("Want: %d,  Peer State: %d", want, peer.State)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return")
 	}
+	waitFor(t, "remote idle", func() bool { return remote.Info().State == IDLE })
 }
 
-func TestPeerCanTransitionToOpenSentState(t *testing.T) {
-	config, _ := ParseConfig("64512 127.0.0.3 64513 127.0.0.4 active")
-	locRib, err := NewLocRib(config)
+// Passiveモードで接続を待っている間でも、ctxをキャンセルするとRunが返り、Listenをやめることを確認するテスト
+func TestPeerRunStopsWaitingForConnection(t *testing.T) {
+	n := NewMemoryNetwork(nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	p := newTestPeer(t, "64513 127.0.0.2 64512 127.0.0.1 passive", n, nil)
+	p.Start()
+	done := make(chan error, 1)
+	go func() { done <- p.Run(ctx) }()
+	addr := &net.TCPAddr{IP: net.ParseIP("127.0.0.2"), Port: BGP_PORT}
+	waitFor(t, "listen", func() bool { return n.Listening(addr) })
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Want: nil, Got: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return")
+	}
+	if n.Listening(addr) {
+		t.Errorf("peer should stop listening")
+	}
+}
+
+// 外部から発行したイベントを、発行した順に処理することを確認するテスト
+func TestPeerHandlesEventsInOrder(t *testing.T) {
+	p := newTestPeer(t, "64512 127.0.0.1 64513 127.0.0.2 active", NewMemoryNetwork(nil), nil)
+	p.Config.Timers.ConnectRetryTime = time.Hour
+	// Runを呼び出す前にイベントを積み、Stopで停止するまで処理させる
+	p.Disable()
+	p.Enable()
+	p.Disable()
+	p.Stop()
+	if err := p.Run(context.Background()); err != ErrStopped {
+		t.Fatalf("Want: %v, Got: %v", ErrStopped, err)
+	}
+	if !p.Info().AdminDown {
+		t.Errorf("peer should be admin down")
+	}
+}
+
+// Peerからの受信が途絶えた場合に、Hold Timeが経過した時点で
+// Hold Timer Expired NotificationMessageを送信してIdleに戻ることを確認するテスト
+// FakeClockの時刻を進めるため、実際には待たない
+func TestPeerHoldTimerExpires(t *testing.T) {
+	clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	n := NewMemoryNetwork(clock)
+	p := newTestPeer(t, "64512 127.0.0.1 64513 127.0.0.2 active", n, clock)
+	p.Config.Timers.HoldTime = 90 * time.Second
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// OpenMessageとKeepaliveMessageだけを送信し、その後は何も送信しないPeer
+	ln, err := n.Listen(&net.TCPAddr{IP: net.ParseIP("127.0.0.2"), Port: BGP_PORT})
 	if err != nil {
-		t.Errorf("Error: %v", err)
+		t.Fatal(err)
 	}
-	peer := NewPeer(config, locRib)
-	peer.Start()
+	defer ln.Close()
+	p.Start()
+	runTestPeer(ctx, p)
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote := newConnection(conn, peerLogger(packetLog, p.Config))
+	om := packets.NewOpenMessage(64513, net.ParseIP("127.0.0.2"))
+	om.HoldTime = 30
+	if err := remote.Send(om); err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Send(packets.NewKeepaliveMessage()); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "established", func() bool { return p.Info().State == ESTABLISHED })
+	if got := p.Info().HoldTime; got != 30*time.Second {
+		t.Errorf("Want: %v, Got: %v", 30*time.Second, got)
+	}
+
+	clock.Advance(30 * time.Second)
+	waitFor(t, "idle", func() bool { return p.Info().State == IDLE })
+	var nm *packets.NotificationMessage
+	for nm == nil {
+		m, err := remote.Recv()
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		nm, _ = m.(*packets.NotificationMessage)
+	}
+	if nm.ErrorCode != packets.HoldTimerExpired {
+		t.Errorf("Want: %v, Got: %v", packets.HoldTimerExpired.Show(), nm.ErrorCode.Show())
+	}
+}
+
+// 壊れたUpdateMessageを受信した場合に、UPDATE Message Errorを送信してIdleに戻ることを確認するテスト
+func TestPeerRejectsMalformedUpdate(t *testing.T) {
+	n := NewMemoryNetwork(nil)
+	p := newTestPeer(t, "64512 127.0.0.1 64513 127.0.0.2 active", n, nil)
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
-	go func() {
-		remote_config, _ := ParseConfig("64513 127.0.0.4 64512 127.0.0.3 passive")
-		remote_locRib, err := NewLocRib(remote_config)
+
+	ln, err := n.Listen(&net.TCPAddr{IP: net.ParseIP("127.0.0.2"), Port: BGP_PORT})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	p.Start()
+	runTestPeer(ctx, p)
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote := newConnection(conn, peerLogger(packetLog, p.Config))
+	if err := remote.Send(packets.NewOpenMessage(64513, net.ParseIP("127.0.0.2"))); err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Send(packets.NewKeepaliveMessage()); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "established", func() bool { return p.Info().State == ESTABLISHED })
+
+	// Withdrawn Routes LengthがMessageの長さを超えているUpdateMessage
+	b := append(bytes.Repeat([]byte{0xff}, 16), 0, 23, byte(packets.Update), 0, 100, 0, 0)
+	if _, err := conn.Write(b); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "idle", func() bool { return p.Info().State == IDLE })
+	var nm *packets.NotificationMessage
+	for nm == nil {
+		m, err := remote.Recv()
 		if err != nil {
-			t.Errorf("Error: %v", err)
+			t.Fatalf("Error: %v", err)
 		}
-		remote_peer := NewPeer(remote_config, remote_locRib)
-		remote_peer.Start()
-		remote_peer.Next(ctx)
-		remote_peer.Next(ctx) // イベントをenqueueできていない？
-	}()
-	// remote_peer側の処理が進むことを保証するためのwait
-	time.Sleep(1 * time.Second)
-	peer.Next(ctx)
-	peer.Next(ctx)
-	peer.TCPConn.conn.Close()
-	want := OPEN_SENT
-	if want != peer.State {
-		t.Errorf("Want: %d,  Peer State: %d", want, peer.State)
+		nm, _ = m.(*packets.NotificationMessage)
+	}
+	if nm.ErrorCode != packets.UpdateMessageError || nm.ErrorSubcode != packets.MalformedAttributeList {
+		t.Errorf("Want: %v/%d, Got: %v/%d",
+			packets.UpdateMessageError.Show(), packets.MalformedAttributeList, nm.ErrorCode.Show(), nm.ErrorSubcode)
 	}
 }
 
-func TestPeerCanTransitionToOpenConfirmState(t *testing.T) {
-	config, _ := ParseConfig("64512 127.0.0.5 64513 127.0.0.6 active")
-	locRib, err := NewLocRib(config)
+// 接続に失敗した場合に、ConnectRetryTimeが経過した後に再接続してセッションを確立することを確認するテスト
+// 同じアドレスでListenする複数のPassiveモードのPeerが、それぞれのremote_peerとEstablishedになることを確認するテスト
+func TestPassivePeersShareListener(t *testing.T) {
+	n := NewMemoryNetwork(nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p1 := startPassivePeer(t, ctx, "64512 127.0.3.1 64513 127.0.3.2 passive", n, nil)
+	p2 := startPassivePeer(t, ctx, "64512 127.0.3.1 64514 127.0.3.3 passive", n, nil)
+	r1 := newTestPeer(t, "64513 127.0.3.2 64512 127.0.3.1 active", n, nil)
+	r2 := newTestPeer(t, "64514 127.0.3.3 64512 127.0.3.1 active", n, nil)
+	for _, p := range []*Peer{r1, r2} {
+		p.Start()
+		runTestPeer(ctx, p)
+	}
+	for _, p := range []*Peer{p1, p2, r1, r2} {
+		waitFor(t, p.Config.RemoteIP.String()+" established", func() bool { return p.Info().State == ESTABLISHED })
+	}
+}
+
+// 設定されていないアドレスからのコネクションは閉じられることを確認するテスト
+func TestPassivePeerRejectsUnknownRemote(t *testing.T) {
+	n := NewMemoryNetwork(nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := startPassivePeer(t, ctx, "64512 127.0.3.1 64513 127.0.3.2 passive", n, nil)
+	conn, err := n.Dial(ctx,
+		&net.TCPAddr{IP: net.ParseIP("127.0.3.9")},
+		&net.TCPAddr{IP: net.ParseIP("127.0.3.1"), Port: BGP_PORT},
+	)
 	if err != nil {
-		t.Errorf("Error: %v", err)
+		t.Fatal(err)
 	}
-	peer := NewPeer(config, locRib)
-	peer.Start()
+	defer conn.Close()
+	if _, err := conn.Read(make([]byte, packets.HEADER_LENGTH)); err == nil {
+		t.Errorf("Want: error, Got: %v", err)
+	}
+	if s := p.Info().State; s != IDLE {
+		t.Errorf("Want: %v, Got: %v", IDLE.Show(), s.Show())
+	}
+}
+
+// 設定と異なるAS番号のOpenMessageにはBad Peer ASのNotificationMessageを返すことを確認するテスト
+func TestPeerRejectsBadPeerAS(t *testing.T) {
+	n := NewMemoryNetwork(nil)
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
-	go func() {
-		remote_config, _ := ParseConfig("64513 127.0.0.6 64512 127.0.0.5 passive")
-		remote_locRib, err := NewLocRib(remote_config)
+	p := startPassivePeer(t, ctx, "64512 127.0.3.1 64513 127.0.3.2 passive", n, nil)
+	conn, err := n.Dial(ctx,
+		&net.TCPAddr{IP: net.ParseIP("127.0.3.2")},
+		&net.TCPAddr{IP: net.ParseIP("127.0.3.1"), Port: BGP_PORT},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	remote := newConnection(conn, peerLogger(packetLog, p.Config))
+	if err := remote.Send(packets.NewOpenMessage(64599, net.ParseIP("127.0.3.2"))); err != nil {
+		t.Fatal(err)
+	}
+	var nm *packets.NotificationMessage
+	for nm == nil {
+		m, err := remote.Recv()
 		if err != nil {
-			t.Errorf("Error: %v", err)
-		}
-		remote_peer := NewPeer(remote_config, remote_locRib)
-		remote_peer.Start()
-		maxStep := 50
-		for i := 0; i < maxStep; i++ {
-			remote_peer.Next(ctx)
-			if remote_peer.State == OPEN_CONFIRM {
-				break
-			}
-			time.Sleep(1 * time.Millisecond)
+			t.Fatalf("Error: %v", err)
 		}
-	}()
-	// remote_peer側の処理が進むことを保証するためのwait
-	time.Sleep(1 * time.Second)
-	maxStep := 50
-	for i := 0; i < maxStep; i++ {
-		peer.Next(ctx)
-		if peer.State == OPEN_CONFIRM {
-			break
+		nm, _ = m.(*packets.NotificationMessage)
+	}
+	if nm.ErrorCode != packets.OpenMessageError || nm.ErrorSubcode != packets.BadPeerAS {
+		t.Errorf("Want: %v/%d, Got: %v/%d",
+			packets.OpenMessageError.Show(), packets.BadPeerAS, nm.ErrorCode.Show(), nm.ErrorSubcode)
+	}
+	waitFor(t, "idle", func() bool { return p.Info().State == IDLE })
+}
+
+func TestPeerConnectRetry(t *testing.T) {
+	clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	n := NewMemoryNetwork(clock)
+	p := newTestPeer(t, "64512 127.0.0.1 64513 127.0.0.2 active", n, clock)
+	p.Config.Timers.ConnectRetryTime = 120 * time.Second
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p.Start()
+	runTestPeer(ctx, p)
+	// remote_peerがListenしていないため接続に失敗し、再接続のタイマーを設定する
+	waitFor(t, "connect retry timer", func() bool { return clock.Timers() == 1 })
+	if got := p.Info().State; got != IDLE {
+		t.Errorf("Want: %v, Got: %v", IDLE.Show(), got.Show())
+	}
+
+	remote := startPassivePeer(t, ctx, "64513 127.0.0.2 64512 127.0.0.1 passive", n, clock)
+	clock.Advance(120 * time.Second)
+	waitFor(t, "established", func() bool {
+		return p.Info().State == ESTABLISHED && remote.Info().State == ESTABLISHED
+	})
+}
+
+// Hold TimeとKeepaliveの間隔が、Peerと交渉した値になることを確認するテスト
+func TestPeerNegotiateTimers(t *testing.T) {
+	tests := []struct {
+		local, keepalive        time.Duration
+		remote                  bgptype.HoldTime
+		wantHold, wantKeepalive time.Duration
+	}{
+		{90 * time.Second, 0, 180, 90 * time.Second, 30 * time.Second},
+		{90 * time.Second, 0, 30, 30 * time.Second, 10 * time.Second},
+		{90 * time.Second, 10 * time.Second, 90, 90 * time.Second, 10 * time.Second},
+		{90 * time.Second, 60 * time.Second, 90, 90 * time.Second, 30 * time.Second},
+		{0, 0, 90, 0, 0},
+		{90 * time.Second, 0, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		p := &Peer{Config: &Config{Timers: Timers{HoldTime: tt.local, KeepaliveInterval: tt.keepalive}}}
+		p.negotiateTimers(&packets.OpenMessage{HoldTime: tt.remote})
+		if p.holdTime != tt.wantHold || p.keepaliveInterval != tt.wantKeepalive {
+			t.Errorf("Want: %v %v, Got: %v %v", tt.wantHold, tt.wantKeepalive, p.holdTime, p.keepaliveInterval)
 		}
-		time.Sleep(1 * time.Millisecond)
 	}
-	peer.TCPConn.conn.Close()
-	want := OPEN_CONFIRM
-	if want != peer.State {
-		t.Errorf("Want: %d,  Peer State: %d", want, peer.State)
+}
+
+// セッションを維持したまま反映できる設定の変更と、張り直しが必要な変更を区別できることを確認するテスト
+func TestConfigNeedsRestart(t *testing.T) {
+	base, _ := ParseConfig("64512 10.200.100.2 64513 10.200.100.3 active")
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		want   bool
+	}{
+		{"same", func(c *Config) {}, false},
+		{"import policy", func(c *Config) { c.ImportPolicy = &policy.Policy{Name: "p"} }, false},
+		{"max prefix", func(c *Config) { c.MaxPrefix = &MaxPrefixConfig{Limit: 10} }, false},
+		{"connect retry", func(c *Config) { c.Timers.ConnectRetryTime = time.Second }, false},
+		{"remote as", func(c *Config) { c.RemoteAS = 64514 }, true},
+		{"mode", func(c *Config) { c.Mode = Passive }, true},
+		{"hold time", func(c *Config) { c.Timers.HoldTime = 30 * time.Second }, true},
+		{"add-path", func(c *Config) { c.AddPath = []*AddPathConfig{{Family: packets.IPv4Unicast, Send: true}} }, true},
+	}
+	for _, tt := range tests {
+		c, _ := ParseConfig("64512 10.200.100.2 64513 10.200.100.3 active")
+		tt.modify(c)
+		if got := base.needsRestart(c); got != tt.want {
+			t.Errorf("%s: Want: %v, Got: %v", tt.name, tt.want, got)
+		}
+		if got := base.Equal(c); got != (tt.name == "same") {
+			t.Errorf("%s: Equal Want: %v, Got: %v", tt.name, tt.name == "same", got)
+		}
 	}
 }
 
-func TestPeerCanTransitionToEstablishedState(t *testing.T) {
-	config, _ := ParseConfig("64512 127.0.0.7 64513 127.0.0.8 active")
-	locRib, err := NewLocRib(config)
-	if err != nil {
-		t.Errorf("Error: %v", err)
+// 無効にしたPeerは自動で再接続せず、Enableで再び接続を始めることを確認するテスト
+func TestPeerDisableSuppressesAutomaticStart(t *testing.T) {
+	// Listenしていないアドレスに接続し、失敗したら再接続を待つ
+	clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	p := newTestPeer(t, "64512 127.0.0.1 64513 127.0.0.2 active", NewMemoryNetwork(clock), clock)
+	p.Config.Timers.ConnectRetryTime = time.Hour
+	states := []State{}
+	p.OnStateChange = func(_ *Peer, _, new State) { states = append(states, new) }
+
+	if err := p.handleEvent(MANUAL_STOP); err != nil {
+		t.Fatal(err)
 	}
-	peer := NewPeer(config, locRib)
-	peer.Start()
+	if !p.Info().AdminDown {
+		t.Errorf("peer should be admin down")
+	}
+	if err := p.handleEvent(AUTOMATIC_START); err != nil {
+		t.Fatal(err)
+	}
+	if p.Info().AdminDown != true || p.State != IDLE {
+		t.Errorf("Want: admin down and Idle, Got: %v %v", p.Info().AdminDown, p.State.Show())
+	}
+	if err := p.handleEvent(MANUAL_START); err != nil {
+		t.Fatal(err)
+	}
+	if p.Info().AdminDown {
+		t.Errorf("peer should be enabled")
+	}
+	// 接続に失敗してIdleのままの場合、Stateが変わっていないため通知しない
+	if len(states) != 0 {
+		t.Errorf("Want: [], Got: %v", states)
+	}
+}
+
+type recorder []*RawMessage
+
+func (r *recorder) RecordMessage(m *RawMessage) {
+	c := *m
+	c.Bytes = append([]byte{}, m.Bytes...)
+	*r = append(*r, &c)
+}
+
+// Connectionで送受信したMessageのバイト列を、向きとアドレスとともに記録することを確認するテスト
+func TestPeerRecordsMessages(t *testing.T) {
+	config, _ := ParseConfig("64512 127.0.0.1 64513 127.0.0.2 active")
+	lr, _ := NewLocRib(config, fib.NewMemory())
+	p := NewPeer(config, lr)
+	rec := &recorder{}
+	p.Recorder = rec
+
+	local, remote := newMemoryPipe(systemClock{},
+		&net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 49152},
+		&net.TCPAddr{IP: net.ParseIP("127.0.0.2"), Port: BGP_PORT},
+	)
+	defer local.Close()
+	defer remote.Close()
+	p.TCPConn = newConnection(local, peerLogger(packetLog, config))
+	p.TCPConn.record = p.messageRecorder(p.TCPConn)
+
+	if err := p.send(packets.NewKeepaliveMessage()); err != nil {
+		t.Fatal(err)
+	}
+	nm, _ := packets.NewNotificationMessage(packets.Cease, packets.AdministrativeReset, nil).ToBytes()
+	if _, err := remote.Write(nm); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.TCPConn.Recv(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(*rec) != 2 {
+		t.Fatalf("Want: 2, Got: %d", len(*rec))
+	}
+	ka, _ := packets.NewKeepaliveMessage().ToBytes()
+	sent, received := (*rec)[0], (*rec)[1]
+	if !sent.Sent || !bytes.Equal(ka, sent.Bytes) || sent.LocalAS != 64512 || sent.RemoteAS != 64513 {
+		t.Errorf("Want: sent keepalive, Got: %+v", sent)
+	}
+	if received.Sent || !bytes.Equal(nm, received.Bytes) || received.RemoteAddr.String() != remote.LocalAddr().String() {
+		t.Errorf("Want: received notification, Got: %+v", received)
+	}
+}
+
+// 1つのLocRibを共有する複数のPeerが同時に経路を交換しても、データ競合が起きないことを確認するテスト
+// go test -raceで実行したときに、Peerのgoroutine間とAPIなどのほかのgoroutineからの参照で競合を検出する。
+// 各spokeが生成した経路をhubが受信し、ほかのspokeに広告する。
+func TestPeersExchangeRoutesConcurrently(t *testing.T) {
+	const spokes = 4
+	n := NewMemoryNetwork(nil)
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
-	go func() {
-		remote_config, _ := ParseConfig("64513 127.0.0.8 64512 127.0.0.9 passive")
-		remote_locRib, err := NewLocRib(remote_config)
+
+	hubConf, _ := ParseConfig("64512 127.0.1.1 64600 127.0.1.10 active")
+	hubRib, err := NewLocRib(hubConf, fib.NewMemory())
+	if err != nil {
+		t.Fatal(err)
+	}
+	hubs := []*Peer{}
+	spokeRibs := []*LocRib{}
+	for i := 0; i < spokes; i++ {
+		ip := fmt.Sprintf("127.0.1.%d", 10+i)
+		as := 64600 + i
+		sc, _ := ParseConfig(fmt.Sprintf("%d %s 64512 127.0.1.1 passive", as, ip))
+		_, nw, _ := net.ParseCIDR(fmt.Sprintf("10.%d.0.0/16", i))
+		sc.Networks = []*NetworkConfig{{Prefix: nw, Mode: NETWORK_UNCONDITIONAL}}
+		sr, err := NewLocRib(sc, fib.NewMemory())
 		if err != nil {
-			t.Errorf("Error: %v", err)
-		}
-		remote_peer := NewPeer(remote_config, remote_locRib)
-		remote_peer.Start()
-		maxStep := 50
-		for i := 0; i < maxStep; i++ {
-			remote_peer.Next(ctx)
-			if remote_peer.State == ESTABLISHED {
-				break
+			t.Fatal(err)
+		}
+		spokeRibs = append(spokeRibs, sr)
+		sp := NewPeer(sc, sr)
+		sp.Transport = n
+		sp.Start()
+		runTestPeer(ctx, sp)
+		addr := &net.TCPAddr{IP: sc.LocalIP, Port: BGP_PORT}
+		waitFor(t, "listen", func() bool { return n.Listening(addr) })
+
+		hc, _ := ParseConfig(fmt.Sprintf("64512 127.0.1.1 %d %s active", as, ip))
+		hp := NewPeer(hc, hubRib)
+		hp.Transport = n
+		hp.Start()
+		runTestPeer(ctx, hp)
+		hubs = append(hubs, hp)
+	}
+
+	// hubが受信した経路をほかのPeerに広告し直している間に、ほかのgoroutineからPeerとRibを参照する
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+			for _, hp := range hubs {
+				hp.NotifyRPKIUpdated()
+				hp.Info()
+				for _, re := range hp.AdjRibInRoutes() {
+					re.GetPathAttributes()
+				}
+				for _, re := range hp.AdjRibOutRoutes() {
+					re.GetPathAttributes()
+				}
+			}
+			for _, ps := range hubRib.RankedPaths() {
+				for _, re := range ps {
+					re.GetPathAttributes()
+				}
 			}
-			time.Sleep(1 * time.Millisecond)
 		}
 	}()
-	// remote_peer側の処理が進むことを保証するためのwait
-	time.Sleep(1 * time.Second)
-	maxStep := 50
-	for i := 0; i < maxStep; i++ {
-		peer.Next(ctx)
-		if peer.State == ESTABLISHED {
-			break
+	waitFor(t, "routes", func() bool {
+		for _, sr := range spokeRibs {
+			if prefixes, _ := sr.Size(); prefixes != spokes {
+				return false
+			}
+		}
+		return true
+	})
+	close(done)
+	<-stopped
+
+	// hubのLocRibの経路は、ほかのPeerに広告するために変更されていない
+	for _, re := range hubRib.Rib.Routes() {
+		if !re.nextHop().Equal(re.PeerAddr) {
+			t.Errorf("Want: %v, Got: %v", re.PeerAddr, re.nextHop())
+		}
+		if l := asPathLen(re); l != 1 {
+			t.Errorf("Want: 1, Got: %d", l)
+		}
+	}
+}
+
+// iBGPで受信した経路がLocRibにインストールされ、eBGPのPeerにだけ広告されることを確認するテスト
+// routerはspeakerからiBGPで経路を受信し、iBGPのclientには送信せず、eBGPのexternalに送信する。
+func TestPeersExchangeRoutesOverIBGP(t *testing.T) {
+	n := NewMemoryNetwork(nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	_, nw, _ := net.ParseCIDR("10.1.0.0/16")
+	ribs := make(map[string]*LocRib)
+	for _, conf := range []string{
+		"64512 127.0.2.1 64512 127.0.2.2 passive",
+		"64512 127.0.2.3 64512 127.0.2.2 passive",
+		"64600 127.0.2.4 64512 127.0.2.2 passive",
+	} {
+		p := newTestPeer(t, conf, n, nil)
+		if p.Config.LocalIP.Equal(net.ParseIP("127.0.2.1")) {
+			p.Config.Networks = []*NetworkConfig{{Prefix: nw, Mode: NETWORK_UNCONDITIONAL}}
+			if err := p.LocRib.Reconfigure(p.Config); err != nil {
+				t.Fatal(err)
+			}
+		}
+		ribs[p.Config.LocalIP.String()] = p.LocRib
+		p.Start()
+		runTestPeer(ctx, p)
+		addr := &net.TCPAddr{IP: p.Config.LocalIP, Port: BGP_PORT}
+		waitFor(t, "listen", func() bool { return n.Listening(addr) })
+	}
+	rc, _ := ParseConfig("64512 127.0.2.2 64512 127.0.2.1 active")
+	router, err := NewLocRib(rc, fib.NewMemory())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ps []*Peer
+	for _, ip := range []string{"127.0.2.1", "127.0.2.3", "127.0.2.4"} {
+		c := *rc
+		c.RemoteIP = net.ParseIP(ip).To4()
+		if ip == "127.0.2.4" {
+			c.RemoteAS = 64600
+		}
+		p := NewPeer(&c, router)
+		p.Transport = n
+		p.Start()
+		runTestPeer(ctx, p)
+		ps = append(ps, p)
+	}
+	for _, p := range ps {
+		waitForTransition(t, p, ESTABLISHED)
+	}
+
+	external := ribs["127.0.2.4"]
+	waitFor(t, "route from router", func() bool { return len(external.Rib.Lookup(nw)) == 1 })
+	// AS Pathを変更せず、NextHopもspeakerのアドレスのままインストールする
+	res := router.Rib.Lookup(nw)
+	if len(res) != 1 || !res[0].IBGP {
+		t.Fatalf("Want: 1 route learned over ibgp, Got: %v", res)
+	}
+	if nh := res[0].nextHop(); !nh.Equal(net.ParseIP("127.0.2.1")) {
+		t.Errorf("Want: 127.0.2.1, Got: %v", nh)
+	}
+	if l := asPathLen(res[0]); l != 0 {
+		t.Errorf("Want: 0, Got: %d", l)
+	}
+	// eBGPのPeerには、自身のAS番号とアドレスを付けて広告する
+	re := external.Rib.Lookup(nw)[0]
+	if nh := re.nextHop(); !nh.Equal(net.ParseIP("127.0.2.2")) {
+		t.Errorf("Want: 127.0.2.2, Got: %v", nh)
+	}
+	if got := policy.AsPathString(re.attributes()); got != "64512" {
+		t.Errorf("Want: 64512, Got: %v", got)
+	}
+	// iBGPで受信した経路は、ほかのiBGPのPeerに広告しない
+	if got := ribs["127.0.2.3"].Rib.Lookup(nw); len(got) != 0 {
+		t.Errorf("Want: [], Got: %v", got)
+	}
+	if got := ps[1].Info().AdvertisedPrefixes; got != 0 {
+		t.Errorf("Want: 0, Got: %d", got)
+	}
+}
+
+// 処理していないMessageが上限に達すると、取り出されるまで受信したMessageを積まないことを確認するテスト
+func TestEventQueueBlocksMessagesWhenFull(t *testing.T) {
+	var q eventQueue
+	quit := make(chan struct{})
+	m := packets.NewKeepaliveMessage()
+	for i := 0; i < maxQueuedMessages; i++ {
+		q.pushMessage(eventEntry{ev: KEEPALIVE_MSG, msg: m}, quit)
+	}
+	// Message以外のイベントは上限に関係なく積める
+	q.push(eventEntry{ev: LOC_RIB_CHANGED})
+	pushed := make(chan bool)
+	go func() { pushed <- q.pushMessage(eventEntry{ev: KEEPALIVE_MSG, msg: m}, quit) }()
+	select {
+	case <-pushed:
+		t.Fatalf("message should not be pushed while the queue is full")
+	case <-time.After(50 * time.Millisecond):
+	}
+	q.pop()
+	if ok := <-pushed; !ok {
+		t.Errorf("Want: true, Got: %v", ok)
+	}
+	go func() { pushed <- q.pushMessage(eventEntry{ev: KEEPALIVE_MSG, msg: m}, quit) }()
+	close(quit)
+	if ok := <-pushed; ok {
+		t.Errorf("Want: false, Got: %v", ok)
+	}
+}
+
+// Peerが読み込まなくなっても送信で止まらず、書き込みの期限を過ぎるとTCP_CONNECTION_FAILSとして処理することを確認するテスト
+func TestSendFailureRaisesConnectionFails(t *testing.T) {
+	config, _ := ParseConfig("64512 127.0.0.1 65413 127.0.0.2 active")
+	p := NewPeer(config, &LocRib{Rib: NewRib(), LocalASNum: config.LocalAS})
+	p.quit = make(chan struct{})
+	defer close(p.quit)
+	// net.Pipeは相手が読み込むまでWriteが返らない
+	c1, c2 := net.Pipe()
+	defer c2.Close()
+	p.TCPConn = newConnection(c1, peerLogger(packetLog, config))
+	p.TCPConn.SetWriteTimeout(50 * time.Millisecond)
+	p.startReceiving(p.TCPConn)
+	p.State = ESTABLISHED
+	igp := bgptype.IGP
+	nh := bgptype.NextHop(config.LocalIP)
+	_, nw, _ := net.ParseCIDR("10.1.0.0/16")
+	p.AdjRibOut.Insert(NewRibEntry(nw, &igp, bgptype.NewAsPath(true), &nh))
+	handled := make(chan error, 1)
+	go func() { handled <- p.handleEvent(ADJ_RIB_OUT_CHANGED) }()
+	select {
+	case err := <-handled:
+		if err != nil {
+			t.Fatalf("Want: nil, Got: %v", err)
 		}
-		time.Sleep(1 * time.Millisecond)
+	case <-time.After(time.Second):
+		t.Fatal("peer should not wait for the remote peer to read")
 	}
-	peer.TCPConn.conn.Close()
-	want := ESTABLISHED
-	if want != peer.State {
-		t.Errorf("Want: %d,  Peer State: %d", want, peer.State)
+	var e eventEntry
+	waitFor(t, "connection fails", func() bool {
+		var ok bool
+		e, ok = p.events.pop()
+		return ok
+	})
+	if e.ev != TCP_CONNECTION_FAILS || e.conn != p.TCPConn || !errors.Is(e.err, os.ErrDeadlineExceeded) {
+		t.Errorf("Want: %v with %v, Got: %+v", TCP_CONNECTION_FAILS.Show(), os.ErrDeadlineExceeded, e)
 	}
+	p.wg.Wait()
 }
@@ -0,0 +1,57 @@
+package peer
+
+import (
+	"net"
+
+	"github.com/SotaUeda/gobgp/policy"
+)
+
+// Ribの中身を絞り込むための条件
+type RouteFilter func(*RibEntry) bool
+
+// AS Pathが正規表現にマッチする経路に絞り込む
+func MatchAsPath(re *policy.AsPathRegexp) RouteFilter {
+	return func(e *RibEntry) bool {
+		return re.Match(e.attributes())
+	}
+}
+
+// プレフィックスが一致する経路に絞り込む。
+// longerがtrueの場合はnwに含まれるより長いプレフィックスの経路も対象にする。
+func MatchPrefix(nw *net.IPNet, longer bool) RouteFilter {
+	ones, _ := nw.Mask.Size()
+	return func(e *RibEntry) bool {
+		eOnes, _ := e.NwAddr.Mask.Size()
+		if !longer {
+			return eOnes == ones && e.NwAddr.IP.Equal(nw.IP)
+		}
+		return eOnes >= ones && nw.Contains(e.NwAddr.IP)
+	}
+}
+
+// すべてのfilterにマッチするRibEntryを返す
+func (rib *Rib) Filter(filters ...RouteFilter) []*RibEntry {
+	rts := []*RibEntry{}
+	for _, e := range rib.Routes() {
+		matched := true
+		for _, f := range filters {
+			if !f(e) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			rts = append(rts, e)
+		}
+	}
+	return rts
+}
+
+// LocRibの経路をfilterで絞り込んで返す
+//
+// 例: AS174が生成した経路の一覧
+//
+//	locRib.Query(MatchAsPath(policy.MustCompileAsPathRegexp("_174$")))
+func (lr *LocRib) Query(filters ...RouteFilter) []*RibEntry {
+	return lr.Rib.Filter(filters...)
+}
@@ -0,0 +1,130 @@
+package peer
+
+import (
+	"sync"
+
+	"github.com/SotaUeda/gobgp/packets"
+)
+
+// キューに積むイベントと、イベントに付随する値
+type eventEntry struct {
+	ev Event
+	// BGP_OPEN, KEEPALIVE_MSG, UPDATE_MSG, NOTIFICATION_MSGで受信したMessage
+	msg packets.Message
+	// CONFIG_CHANGEDで反映する設定
+	config *Config
+	// コネクションに関するイベントの場合は、そのコネクション
+	// 張り直す前のコネクションのイベントは処理しない
+	conn *Connection
+	// 接続を試みた結果のイベントの場合は、その試行の番号
+	connectID uint64
+	// TCP_CONNECTION_FAILSの原因
+	err error
+}
+
+// 処理していない受信したMessageのイベントの上限
+const maxQueuedMessages = 1024
+
+// Peerのイベントを発生した順に保持するキュー
+// pushは上限を設けず、積む側を止めない。
+// イベントを処理しているgoroutine自身が、続けて処理するイベントを積めるようにするため。
+// 受信したMessageはpushMessageで積み、処理が追いつくまで受信するgoroutineを止める。
+// ゼロ値で使用できる。
+type eventQueue struct {
+	mu      sync.Mutex
+	entries []eventEntry
+	// entriesのうち、受信したMessageのイベントの数
+	messages int
+	// イベントが積まれたときに値を送る
+	// 容量を1にして、取り出す前に積まれた複数のイベントの通知を1つにまとめる
+	ready chan struct{}
+	// Messageのイベントを取り出して、積めるようになったときに値を送る
+	space chan struct{}
+}
+
+func (q *eventQueue) push(e eventEntry) {
+	q.mu.Lock()
+	q.entries = append(q.entries, e)
+	ready := q.readyLocked()
+	q.mu.Unlock()
+	notify(ready)
+}
+
+// 受信したMessageのイベントを積む
+// 処理していないMessageがmaxQueuedMessages個ある場合は、取り出されるまで待つ。
+// 待っている間にquitが閉じられた場合は、積まずにfalseを返す。
+func (q *eventQueue) pushMessage(e eventEntry, quit <-chan struct{}) bool {
+	for {
+		q.mu.Lock()
+		if q.messages < maxQueuedMessages {
+			q.entries = append(q.entries, e)
+			q.messages++
+			ready := q.readyLocked()
+			// ほかに待っているgoroutineがあれば、続けて積めるようにする
+			var space chan struct{}
+			if q.messages < maxQueuedMessages {
+				space = q.spaceLocked()
+			}
+			q.mu.Unlock()
+			notify(ready)
+			if space != nil {
+				notify(space)
+			}
+			return true
+		}
+		space := q.spaceLocked()
+		q.mu.Unlock()
+		select {
+		case <-space:
+		case <-quit:
+			return false
+		}
+	}
+}
+
+// 最も古いイベントを取り出す。キューが空の場合はfalseを返す
+func (q *eventQueue) pop() (eventEntry, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.entries) == 0 {
+		return eventEntry{}, false
+	}
+	e := q.entries[0]
+	q.entries[0] = eventEntry{}
+	q.entries = q.entries[1:]
+	if e.msg != nil {
+		q.messages--
+		notify(q.spaceLocked())
+	}
+	return e, true
+}
+
+// イベントが積まれたときに値を受信できるchannel
+// 受信した後は、popがfalseを返すまで取り出す
+func (q *eventQueue) wait() <-chan struct{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.readyLocked()
+}
+
+func (q *eventQueue) readyLocked() chan struct{} {
+	if q.ready == nil {
+		q.ready = make(chan struct{}, 1)
+	}
+	return q.ready
+}
+
+func (q *eventQueue) spaceLocked() chan struct{} {
+	if q.space == nil {
+		q.space = make(chan struct{}, 1)
+	}
+	return q.space
+}
+
+// 容量1のchannelに、すでに値がある場合は送らずに値を送る
+func notify(c chan struct{}) {
+	select {
+	case c <- struct{}{}:
+	default:
+	}
+}
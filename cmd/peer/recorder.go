@@ -0,0 +1,56 @@
+package peer
+
+import (
+	"net"
+	"time"
+
+	"github.com/SotaUeda/gobgp/bgptype"
+)
+
+// Connectionで送受信したMessageのバイト列
+type RawMessage struct {
+	Time       time.Time
+	LocalAS    bgptype.AutonomousSystemNumber
+	RemoteAS   bgptype.AutonomousSystemNumber
+	LocalAddr  *net.TCPAddr
+	RemoteAddr *net.TCPAddr
+	// 自身が送信した場合にtrue
+	Sent bool
+	// UpdateMessageの経路にPath Identifierが付いている場合にtrue (ADD-PATH)
+	AddPath bool
+	// ヘッダーを含むMessage全体
+	// 呼び出しの後に書き換えられることがあるため、保持する場合は複製する
+	Bytes []byte
+}
+
+// 送受信したMessageを記録する
+// Peerのgoroutineから呼び出すため、処理を止めないようにする
+type MessageRecorder interface {
+	RecordMessage(m *RawMessage)
+}
+
+// connで送受信したMessageをRecorderに渡す関数を返す
+// 受信したMessageは受信するgoroutineから渡されるため、接続したときの値を使い、Peerの値は参照しない
+func (p *Peer) messageRecorder(conn *Connection) func(b []byte, sent bool) {
+	clock, rec := p.clock(), p.Recorder
+	localAS, remoteAS := p.Config.LocalAS, p.Config.RemoteAS
+	local, remote := conn.LocalAddr(), conn.RemoteAddr()
+	return func(b []byte, sent bool) {
+		m := &RawMessage{
+			Time:       clock.Now(),
+			LocalAS:    localAS,
+			RemoteAS:   remoteAS,
+			LocalAddr:  local,
+			RemoteAddr: remote,
+			Sent:       sent,
+			Bytes:      b,
+		}
+		if sent {
+			// 送信はPeerのgoroutineからのみ行う
+			m.AddPath = p.AdjRibOut.AddPath != nil
+		} else {
+			m.AddPath = conn.Options().AddPath
+		}
+		rec.RecordMessage(m)
+	}
+}
@@ -1,70 +1,409 @@
 package peer
 
 import (
+	"bytes"
+	"cmp"
+	"context"
 	"fmt"
 	"net"
+	"reflect"
+	"slices"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/SotaUeda/gobgp/bgptype"
+	"github.com/SotaUeda/gobgp/fib"
 	"github.com/SotaUeda/gobgp/packets"
-	"github.com/vishvananda/netlink"
+	"github.com/SotaUeda/gobgp/policy"
+	"github.com/SotaUeda/gobgp/rpki"
 )
 
 type LocRib struct {
+	// 最適経路として選択された経路
 	Rib        *Rib
 	LocalASNum bgptype.AutonomousSystemNumber
+	// RPKIのVRP。nilの場合は経路を検証しない
+	RPKI *rpki.Table
+	// BGP Multipathの設定。nilの場合は最適経路のみカーネルにインストールする
+	Multipath *MultipathConfig
+	// NextHopの追跡。nilの場合はすべてのNextHopに到達できるものとして扱う
+	NextHops *NextHopTracker
+	// 経路を書き込む転送プレーン
+	FIB fib.FIB
+
+	// LocRibはすべてのPeerで共有するため、最適経路の選択は排他制御する
+	mu sync.Mutex
+	// プレフィックスごとの候補経路と、その中から選択された最適経路
+	paths map[string][]*RibEntry
+	best  map[string]*RibEntry
+	// 最適経路と等価で、同時にカーネルにインストールする経路
+	multipaths map[string][]*RibEntry
+	// AdjRibInの経路と、その経路として候補経路に追加した経路
+	// NextHopを解決した経路は、解決結果を設定した複製を追加する
+	installed map[*RibEntry]*RibEntry
+	// ADD-PATHで送信するPath Identifierの最後に割り当てた値
+	lastPathID uint32
+	// 最適経路かマルチパスの経路が変わり、カーネルに反映していないプレフィックス
+	fibDirty map[string]*net.IPNet
+	// カーネルへの書き込みを1つずつ行う
+	fibMu sync.Mutex
+	// 経路集約の設定と、プレフィックスごとの集約した経路
+	aggregates      []*AggregateConfig
+	aggregateRoutes map[string]*RibEntry
+	// 集約した経路のプレフィックスごとの、集約元の経路
+	aggregateContributors map[string]map[string]*RibEntry
+	// 最適経路が変わり、集約した経路に反映していないプレフィックス
+	aggregateDirty map[string]*net.IPNet
+	// 自身で生成する経路のNextHop
+	localIP net.IP
+	// networkステートメントと再配布によって自身で生成した経路
+	localRoutes map[string]*RibEntry
+	// 自身で生成する経路を作成した設定
+	// ルーティングテーブルが変わったときに、同じ設定で作り直す
+	originConf *Config
+	// APIから追加した、自身で生成する経路
+	// 設定ファイルを読み込み直しても保持する
+	apiRoutes map[string]*RibEntry
+	// 最適経路が変わったときに呼び出す。最適経路がなくなった場合、bestはnil
+	// LocRibをロックした状態で呼び出すため、処理を止めたりLocRibを参照したりしないようにする
+	OnBestPathChange func(nw *net.IPNet, best *RibEntry)
+	// カーネルのルーティングテーブルに書き込むたびに、変更したルートの数と
+	// 書き込みにかかった時間、書き込みのエラーを渡して呼び出す
+	OnFIBApply func(changes int, d time.Duration, err error)
+	// 前回Peerに通知してから、候補経路か最適経路が変わったプレフィックス
+	changed map[string]*net.IPNet
+
+	// LocRibの変更を通知する、起動中のPeer
+	peersMu sync.Mutex
+	peers   map[*Peer]struct{}
+}
+
+// networkステートメントと再配布の設定から、自身で生成する経路を作成する
+// ルーティングテーブルはfから参照する
+func NewLocRib(c *Config, f fib.FIB) (*LocRib, error) {
+	locRib := &LocRib{
+		Rib: NewRib(),
+		FIB: f,
+	}
+	if err := locRib.Reconfigure(c); err != nil {
+		return nil, err
+	}
+	return locRib, nil
+}
+
+// 自身で生成する経路と経路集約、マルチパスの設定を反映する
+// 設定から外れた自身の経路は取り消し、新たに設定された経路を追加する。
+// 起動中に設定を読み込み直した場合も、Peerから受信した経路はそのまま保持する。
+func (lr *LocRib) Reconfigure(c *Config) error {
+	nws, err := lr.originatedNetworks(c)
+	if err != nil {
+		return err
+	}
+	lr.mu.Lock()
+	defer lr.unlock()
+	lr.LocalASNum = c.LocalAS
+	lr.localIP = c.LocalIP
+	// summary-onlyの設定が変わると集約元の経路を広告するかどうかが変わるため、
+	// 変更の前後で集約元のプレフィックスをPeerに通知する
+	lr.markSummarizedChanged()
+	defer lr.markSummarizedChanged()
+	if !reflect.DeepEqual(lr.aggregates, c.Aggregates) {
+		lr.setAggregates(c.Aggregates)
+	}
+	// マルチパスの設定が変わった場合は、すべてのプレフィックスで等価な経路を選び直す
+	if !reflect.DeepEqual(lr.Multipath, c.Multipath) {
+		lr.Multipath = c.Multipath
+		for key := range lr.paths {
+			lr.updateBestPath(key)
+		}
+	}
+	lr.originConf = c
+	lr.setLocalRoutes(nws)
+	return nil
+}
+
+// ルーティングテーブルが変わったときに、自身で生成する経路を作り直す
+// exact-matchのnetworkステートメントと再配布は、ルーティングテーブルのルートによって経路が変わる
+func (lr *LocRib) RefreshLocalRoutes() error {
+	lr.mu.Lock()
+	c := lr.originConf
+	lr.mu.Unlock()
+	if c == nil {
+		return nil
+	}
+	nws, err := lr.originatedNetworks(c)
+	if err != nil {
+		return err
+	}
+	lr.mu.Lock()
+	defer lr.unlock()
+	// 作り直している間にReconfigureで新しい設定を反映した場合は、そちらの結果を使う
+	if lr.originConf != c {
+		return nil
+	}
+	lr.setLocalRoutes(nws)
+	return nil
+}
+
+// ルーティングテーブルの変更を監視し、自身で生成する経路を作り直す
+// ctxが終了するまで処理を続ける
+func (lr *LocRib) WatchRoutingTable(ctx context.Context) error {
+	ch, err := lr.FIB.Watch(ctx.Done())
+	if err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ch:
+			if err := lr.RefreshLocalRoutes(); err != nil {
+				ribLog.Warn("cannot refresh local routes", "error", err)
+			}
+		}
+	}
+}
+
+// nwsを自身で生成する経路にする
+// nwsにない以前の経路は取り消す。lr.muをロックした状態で呼び出す
+func (lr *LocRib) setLocalRoutes(nws []*net.IPNet) {
+	pas := localPathAttributes(lr.localIP)
+	routes := make(map[string]*RibEntry)
+	for _, nw := range nws {
+		key := nw.String()
+		// NextHopが変わっていない経路は、Peerに広告し直さないようにそのまま使う
+		if re, ok := lr.localRoutes[key]; ok && samePathAttributes(re.attributes(), pas) {
+			routes[key] = re
+			continue
+		}
+		re := NewRibEntry(nw, pas...)
+		routes[key] = re
+		lr.addPath(re)
+		lr.updateBestPath(key)
+	}
+	for key, re := range lr.localRoutes {
+		if routes[key] != re {
+			lr.removePath(re)
+			lr.updateBestPath(key)
+		}
+	}
+	lr.localRoutes = routes
+	lr.updateAggregates()
+}
+
+// 自身で生成する経路を追加する
+func (lr *LocRib) AddLocalPath(nw *net.IPNet) error {
+	lr.mu.Lock()
+	defer lr.unlock()
+	key := nw.String()
+	if _, ok := lr.apiRoutes[key]; ok {
+		return fmt.Errorf("path %v already exists", key)
+	}
+	if lr.apiRoutes == nil {
+		lr.apiRoutes = make(map[string]*RibEntry)
+	}
+	re := NewRibEntry(nw, localPathAttributes(lr.localIP)...)
+	lr.apiRoutes[key] = re
+	lr.addPath(re)
+	lr.updateBestPath(key)
+	lr.updateAggregates()
+	return nil
+}
+
+// AddLocalPathで追加した経路を削除する
+func (lr *LocRib) DeleteLocalPath(nw *net.IPNet) error {
+	lr.mu.Lock()
+	defer lr.unlock()
+	key := nw.String()
+	re, ok := lr.apiRoutes[key]
+	if !ok {
+		return fmt.Errorf("path %v does not exist", key)
+	}
+	delete(lr.apiRoutes, key)
+	lr.removePath(re)
+	lr.updateBestPath(key)
+	lr.updateAggregates()
+	return nil
 }
 
-func NewLocRib(c *Config) (*LocRib, error) {
+// PathAttributeを持つ自身で生成する経路をまとめて追加する
+// MRTのダンプから読み込んだ経路のように、大量の経路を一度に追加するために使う。
+// 同じプレフィックスの経路をすでに追加している場合は置き換える。
+func (lr *LocRib) AddLocalPaths(res []*RibEntry) {
+	lr.mu.Lock()
+	defer lr.unlock()
+	if lr.apiRoutes == nil {
+		lr.apiRoutes = make(map[string]*RibEntry)
+	}
+	for _, re := range res {
+		key := re.NwAddr.String()
+		if old, ok := lr.apiRoutes[key]; ok {
+			lr.removePath(old)
+		}
+		lr.apiRoutes[key] = re
+		lr.addPath(re)
+		lr.updateBestPath(key)
+	}
+	lr.updateAggregates()
+}
+
+// AddLocalPath, AddLocalPathsで追加した経路をまとめて削除する
+// 追加していないプレフィックスは無視する。
+func (lr *LocRib) DeleteLocalPaths(nws []*net.IPNet) {
+	lr.mu.Lock()
+	defer lr.unlock()
+	for _, nw := range nws {
+		key := nw.String()
+		re, ok := lr.apiRoutes[key]
+		if !ok {
+			continue
+		}
+		delete(lr.apiRoutes, key)
+		lr.removePath(re)
+		lr.updateBestPath(key)
+	}
+	lr.updateAggregates()
+}
+
+// 自身で生成する経路のPathAttribute
+func localPathAttributes(localIP net.IP) []bgptype.PathAttribute {
 	igp := bgptype.IGP
 	// AS Pathは、ほかのピアから受信したルートと統一的に扱うために、
 	// LocRib -> AdjRibOutにルートを送るときに、自分のAS番号を
 	// 追加するので、ここでは空にしておく。
 	seq := bgptype.AsSequence{}
-	nh := bgptype.NextHop(c.LocalIP)
-	pas := []bgptype.PathAttribute{
+	nh := bgptype.NextHop(localIP)
+	return []bgptype.PathAttribute{
 		&igp,
 		&seq,
 		&nh,
 	}
+}
 
-	rib := NewRib()
-	locRib := &LocRib{}
-	for _, nw := range c.Networks {
-		rts, err := locRib.LookupRoutingTable(nw)
-		if err != nil {
-			return nil, err
+// 自身で生成して広告するプレフィックスを返す
+// ルーティングテーブルのルートではなく、設定されたプレフィックスをそのまま広告する。
+func (lr *LocRib) originatedNetworks(c *Config) ([]*net.IPNet, error) {
+	nws := []*net.IPNet{}
+	seen := make(map[string]struct{})
+	add := func(nw *net.IPNet) {
+		if _, ok := seen[nw.String()]; ok {
+			return
 		}
-		if len(rts) == 0 {
+		seen[nw.String()] = struct{}{}
+		nws = append(nws, nw)
+	}
+	for _, n := range c.Networks {
+		switch n.Mode {
+		case NETWORK_UNCONDITIONAL:
+			add(n.Prefix)
+		case NETWORK_EXACT_MATCH:
+			rts, err := lr.LookupRoutingTable(n.Prefix)
+			if err != nil {
+				return nil, err
+			}
+			for _, rt := range rts {
+				if rt.String() == n.Prefix.String() {
+					add(n.Prefix)
+					break
+				}
+			}
+		}
+	}
+	if c.Redistribute == nil {
+		return nws, nil
+	}
+	trs, err := lr.FIB.Routes()
+	if err != nil {
+		return nil, err
+	}
+	for _, tr := range trs {
+		if !c.Redistribute.hasSource(tr.Source) {
 			continue
 		}
-		for _, rt := range rts {
-			rib.Insert(NewRibEntry(rt, pas...))
+		if !c.Redistribute.Policy.Accept(&policy.Path{Prefix: tr.Dst}) {
+			continue
 		}
+		add(tr.Dst)
 	}
-	locRib.Rib = rib
-	locRib.LocalASNum = c.LocalAS
-	return locRib, nil
+	return nws, nil
 }
 
+// 前回書き込んでから最適経路が変わったプレフィックスだけを
+// カーネルのルーティングテーブルに反映する
+// マルチパスの経路がある場合は、1つのルートに複数のNextHopを持たせる。
+// 最適経路がなくなったプレフィックスはルートを削除する。
+// 書き込みに失敗したプレフィックスは反映していないものとして残し、次に呼び出したときに書き込み直す。
 func (lr *LocRib) WriteToKernelRoutingTable() error {
-	for _, e := range lr.Rib.Routes() {
-		for _, p := range *e.GetPathAttributes() {
-			switch t := p.(type) {
-			case *bgptype.NextHop:
-				dest := e.NwAddr
-				route := &netlink.Route{
-					Dst: dest,
-					Gw:  net.IP([]byte(*t)),
-				}
-				if err := netlink.RouteAdd(route); err != nil {
-					return err
-				}
-				fmt.Printf("Add Route: %v\n", route)
-			}
+	// 複数のPeerから同時に呼び出された場合に、古い変更を後から書き込まないようにする
+	lr.fibMu.Lock()
+	defer lr.fibMu.Unlock()
+	lr.mu.Lock()
+	changes := make([]*fib.Change, 0, len(lr.fibDirty))
+	for key, nw := range lr.fibDirty {
+		changes = append(changes, &fib.Change{Dst: nw, Route: lr.fibRoute(key)})
+	}
+	lr.fibDirty = nil
+	lr.mu.Unlock()
+	if lr.FIB == nil || len(changes) == 0 {
+		return nil
+	}
+	start := time.Now()
+	err := lr.FIB.Apply(changes)
+	fibLog.Debug("route changes are applied", "changes", len(changes), "duration", time.Since(start))
+	if lr.OnFIBApply != nil {
+		lr.OnFIBApply(len(changes), time.Since(start), err)
+	}
+	if failed := fib.FailedPrefixes(err); len(failed) > 0 {
+		lr.mu.Lock()
+		if lr.fibDirty == nil {
+			lr.fibDirty = make(map[string]*net.IPNet)
+		}
+		for _, nw := range failed {
+			lr.fibDirty[nw.String()] = nw
 		}
+		lr.mu.Unlock()
 	}
-	return nil
+	return err
+}
+
+// カーネルに反映していないプレフィックスがあればtrueを返す
+func (lr *LocRib) HasFIBChanges() bool {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	return len(lr.fibDirty) > 0
+}
+
+// 最適経路とマルチパスの経路からカーネルに書き込むルートを作成する
+// 最適経路がない場合や、自身で生成した経路の場合はnilを返す。
+// 自身で生成した経路はカーネルのルーティングテーブルから生成しているため書き込まない
+func (lr *LocRib) fibRoute(key string) *fib.Route {
+	best := lr.best[key]
+	if best == nil || best.PeerAddr == nil {
+		return nil
+	}
+	mps := lr.multipaths[key]
+	if len(mps) == 0 {
+		mps = []*RibEntry{best}
+	}
+	r := &fib.Route{Dst: best.NwAddr}
+	seen := make(map[string]struct{})
+	for _, mp := range mps {
+		nh := mp.forwardingAddr()
+		if nh == nil {
+			continue
+		}
+		// 異なるNextHopが同じゲートウェイに解決される場合は1つにまとめる
+		if _, ok := seen[nh.String()]; ok {
+			continue
+		}
+		seen[nh.String()] = struct{}{}
+		r.NextHops = append(r.NextHops, nh)
+	}
+	if len(r.NextHops) == 0 {
+		return nil
+	}
+	return r
 }
 
 // 各種Ribの処理の際、以前に処理したエントリは再処理する必要がない。
@@ -80,41 +419,146 @@ const (
 // NLRIを付けて送信する。このように
 // PathAttributeは複数のルートに対して同じことがあるが、
 // その全てでCloneをすることは避けたいため、
-// 参考書ではArc<Vec<PathAttribute>>にしているが、
-// ここでは sync.Mutex を使って排他制御を行うことで
-// この問題を解決する。 <- これでいいのか？
+// 参考書ではArc<Vec<PathAttribute>>にしている。
+// ここでは、作成したPathAttributeを変更しないことで、複製せずに複数の経路やPeerで共有する。
+// 変更が必要な場合は、複製してから変更する (copy-on-write)。
+//
+// RibEntryもすべてのPeerのgoroutineから参照するため、Ribにインストールした後は変更しない。
+// 値を変える場合は、cloneで複製した経路に置き換える。
+// NextHopの解決結果は、LocRibがAdjRibInの経路を複製して設定する。
+// LocRibが割り当てるPath Identifierだけは、候補経路に追加する前にLocRibをロックした状態で設定する。
 type RibEntry struct {
 	mu             sync.Mutex
 	NwAddr         *net.IPNet
-	pathAttributes []bgptype.PathAttribute // 排他制御のため、ローカル変数にする
+	pathAttributes []bgptype.PathAttribute // 共有するため、スライスごと置き換えて変更する
+	// 経路を受信したPeerのIPアドレス
+	// 自身で生成した経路の場合はnil
+	PeerAddr net.IP
+	// RPKIによる経路の検証結果
+	Validation rpki.ValidationState
+	// iBGPで受信した経路の場合はtrue
+	IBGP bool
+	// Peerから受信したPath Identifier (ADD-PATH)
+	// ADD-PATHが有効でない場合は0
+	PathID uint32
+	// LocRibで割り当てる、ADD-PATHでPeerに送信するときのPath Identifier
+	localPathID uint32
+	// NextHopまでのIGPのコスト
+	igpMetric int
+	// NextHopを再帰的に解決した結果、実際にパケットを送るゲートウェイ
+	// NextHopを解決していない場合はnil
+	gateway net.IP
+	// 経路を受信、または生成した時刻
+	CreatedAt time.Time
 }
 
 func NewRibEntry(nw *net.IPNet, pas ...bgptype.PathAttribute) *RibEntry {
 	return &RibEntry{
-		NwAddr:         nw,
-		pathAttributes: pas,
+		NwAddr: nw,
+		// 呼び出し元がスライスを書き換えても影響を受けないように複製する
+		pathAttributes: slices.Clone(pas),
+		CreatedAt:      time.Now(),
+	}
+}
+
+// 経路を複製する
+// PathAttributeは変更しないため、複製せずに共有する
+func (re *RibEntry) clone() *RibEntry {
+	return &RibEntry{
+		NwAddr:         re.NwAddr,
+		pathAttributes: re.attributes(),
+		PeerAddr:       re.PeerAddr,
+		Validation:     re.Validation,
+		IBGP:           re.IBGP,
+		PathID:         re.PathID,
+		localPathID:    re.localPathID,
+		igpMetric:      re.igpMetric,
+		gateway:        re.gateway,
+		CreatedAt:      re.CreatedAt,
 	}
 }
 
+// PathAttributeを追加する
+// 以前にGetPathAttributesで返したスライスを参照している場合に影響しないように、新しいスライスに置き換える
 func (re *RibEntry) AddPathAttributes(pas ...bgptype.PathAttribute) {
 	re.mu.Lock()
 	defer re.mu.Unlock()
-	re.pathAttributes = append(re.pathAttributes, pas...)
+	re.pathAttributes = append(slices.Clip(re.pathAttributes), pas...)
+}
+
+// PathAttributeのスライスの複製を返す
+// 返したスライスは書き換えてもよいが、各PathAttributeはほかの経路と共有しているため変更しない
+func (re *RibEntry) GetPathAttributes() []bgptype.PathAttribute {
+	return slices.Clone(re.attributes())
 }
 
-func (re *RibEntry) GetPathAttributes() *[]bgptype.PathAttribute {
+// 複製せずにPathAttributeのスライスを返す
+// パッケージ内で参照するためだけに使い、書き換えない
+func (re *RibEntry) attributes() []bgptype.PathAttribute {
 	re.mu.Lock()
 	defer re.mu.Unlock()
-	return &re.pathAttributes
+	return re.pathAttributes
+}
+
+// Policyで評価するためのPathに変換する
+func (re *RibEntry) toPolicyPath() *policy.Path {
+	return &policy.Path{
+		Prefix:         re.NwAddr,
+		PathAttributes: re.attributes(),
+		Validation:     re.Validation,
+	}
+}
+
+// AS Pathの最後のASを返す。
+// AS Pathが空の場合は同じAS内で生成された経路のためlocalASを返し、
+// 最後のセグメントがAS_SETの場合は生成元を特定できないためfalseを返す。
+func (re *RibEntry) originAS(localAS bgptype.AutonomousSystemNumber) (bgptype.AutonomousSystemNumber, bool) {
+	var last bgptype.AsPath
+	for _, pa := range re.attributes() {
+		if ap, ok := pa.(bgptype.AsPath); ok && len(ap.Get()) > 0 {
+			last = ap
+		}
+	}
+	switch t := last.(type) {
+	case nil:
+		return localAS, true
+	case *bgptype.AsSequence:
+		return (*t)[len(*t)-1], true
+	default:
+		return 0, false
+	}
+}
+
+// NextHopを返す。NextHopが含まれていない場合はnilを返す
+func (re *RibEntry) nextHop() net.IP {
+	for _, pa := range re.attributes() {
+		if nh, ok := pa.(*bgptype.NextHop); ok {
+			return net.IP(*nh)
+		}
+	}
+	return nil
+}
+
+// カーネルのルートに書き込むゲートウェイ
+func (re *RibEntry) forwardingAddr() net.IP {
+	if re.gateway != nil {
+		return re.gateway
+	}
+	return re.nextHop()
 }
 
 func (re *RibEntry) containAS(as bgptype.AutonomousSystemNumber) bool {
-	for _, pa := range re.pathAttributes {
+	// AS Pathは複数のセグメントに分かれている場合があるため、すべてのセグメントを調べる
+	for _, pa := range re.attributes() {
 		switch t := pa.(type) {
 		case *bgptype.AsSequence:
-			return t.Contains(as)
+			if t.Contains(as) {
+				return true
+			}
 		case *bgptype.AsSet:
-			return t.Contains(as)
+			if t.Contains(as) {
+				return true
+			}
 		}
 	}
 	return false
@@ -127,14 +571,24 @@ func (re *RibEntry) containAS(as bgptype.AutonomousSystemNumber) bool {
 // Rib間で受け渡すときにCloneを避けたいため、参考書ではHashMapのKeyを
 // Arc<RibEntry>にしている。
 // ここでは、sync.Mutexを使って排他制御を行うことでこの問題を解決する。
+//
+// フルルートを受信しても1つのUpdateMessageの処理が経路数に比例しないように、
+// プレフィックスごとのentryと、変更されたentryを別に持つ。
 type Rib struct {
 	mu      sync.Mutex
 	entries map[*RibEntry]RibEntryStatus
+	// プレフィックスをKeyにしたentry
+	// 同じプレフィックスのentryはADD-PATHで受信した経路のように少ないため、スライスで持つ
+	prefixes map[string][]*RibEntry
+	// ステータスがNEW_RIB_ENTのentry
+	news map[*RibEntry]struct{}
 }
 
 func NewRib() *Rib {
 	return &Rib{
-		entries: make(map[*RibEntry]RibEntryStatus),
+		entries:  make(map[*RibEntry]RibEntryStatus),
+		prefixes: make(map[string][]*RibEntry),
+		news:     make(map[*RibEntry]struct{}),
 	}
 }
 
@@ -144,10 +598,102 @@ func (rib *Rib) Insert(re *RibEntry) {
 	defer rib.mu.Unlock()
 	if _, ok := rib.entries[re]; !ok {
 		rib.entries[re] = NEW_RIB_ENT
-		fmt.Printf("Insert: %v\n", re.NwAddr)
+		rib.news[re] = struct{}{}
+		key := re.NwAddr.String()
+		rib.prefixes[key] = append(rib.prefixes[key], re)
+		// フルルートを受信するとエントリの数だけ出力されるため、Debugでのみ出力する
+		ribLog.Debug("rib entry is inserted", "prefix", lazy(re.NwAddr.String))
+	}
+}
+
+// Rib内のentryを変更されたものとして扱う
+func (rib *Rib) MarkChanged(re *RibEntry) {
+	rib.mu.Lock()
+	defer rib.mu.Unlock()
+	if _, ok := rib.entries[re]; ok {
+		rib.entries[re] = NEW_RIB_ENT
+		rib.news[re] = struct{}{}
+	}
+}
+
+// Rib内のすべてのentryを変更されたものとして扱う
+func (rib *Rib) MarkAllChanged() {
+	rib.mu.Lock()
+	defer rib.mu.Unlock()
+	for re := range rib.entries {
+		rib.entries[re] = NEW_RIB_ENT
+		rib.news[re] = struct{}{}
 	}
 }
 
+// Rib内にentryが存在すれば削除する
+// 削除した場合はtrueを返す
+func (rib *Rib) Remove(re *RibEntry) bool {
+	rib.mu.Lock()
+	defer rib.mu.Unlock()
+	if _, ok := rib.entries[re]; !ok {
+		return false
+	}
+	delete(rib.entries, re)
+	delete(rib.news, re)
+	key := re.NwAddr.String()
+	ps := rib.prefixes[key]
+	for i, p := range ps {
+		if p == re {
+			ps = append(ps[:i:i], ps[i+1:]...)
+			break
+		}
+	}
+	if len(ps) == 0 {
+		delete(rib.prefixes, key)
+	} else {
+		rib.prefixes[key] = ps
+	}
+	return true
+}
+
+// ネットワークアドレスが一致するentryを返す
+func (rib *Rib) Lookup(nw *net.IPNet) []*RibEntry {
+	rib.mu.Lock()
+	defer rib.mu.Unlock()
+	return append([]*RibEntry{}, rib.prefixes[nw.String()]...)
+}
+
+// ネットワークアドレスとPath Identifierが一致するentryを返す
+func (rib *Rib) LookupPath(nw *net.IPNet, id uint32) []*RibEntry {
+	rib.mu.Lock()
+	defer rib.mu.Unlock()
+	rts := []*RibEntry{}
+	for _, rt := range rib.prefixes[nw.String()] {
+		if rt.PathID == id {
+			rts = append(rts, rt)
+		}
+	}
+	return rts
+}
+
+// entryがRib内に存在すればtrueを返す
+func (rib *Rib) Contains(re *RibEntry) bool {
+	rib.mu.Lock()
+	defer rib.mu.Unlock()
+	_, ok := rib.entries[re]
+	return ok
+}
+
+func (rib *Rib) Len() int {
+	rib.mu.Lock()
+	defer rib.mu.Unlock()
+	return len(rib.entries)
+}
+
+// entryのプレフィックスの数を返す
+// ADD-PATHで同じプレフィックスの経路を複数持つ場合も1つと数える
+func (rib *Rib) PrefixLen() int {
+	rib.mu.Lock()
+	defer rib.mu.Unlock()
+	return len(rib.prefixes)
+}
+
 func (rib *Rib) Routes() []*RibEntry {
 	rib.mu.Lock()
 	defer rib.mu.Unlock()
@@ -158,145 +704,931 @@ func (rib *Rib) Routes() []*RibEntry {
 	return rts
 }
 
+// ステータスがNEW_RIB_ENTのentryを返す
+func (rib *Rib) NewRoutes() []*RibEntry {
+	rib.mu.Lock()
+	defer rib.mu.Unlock()
+	rts := make([]*RibEntry, 0, len(rib.news))
+	for rt := range rib.news {
+		rts = append(rts, rt)
+	}
+	return rts
+}
+
+// すべてのentryを削除する
+func (rib *Rib) Clear() {
+	rib.mu.Lock()
+	defer rib.mu.Unlock()
+	rib.entries = make(map[*RibEntry]RibEntryStatus)
+	rib.prefixes = make(map[string][]*RibEntry)
+	rib.news = make(map[*RibEntry]struct{})
+}
+
 func (rib *Rib) UpsateToAllUnchanged() {
 	rib.mu.Lock()
 	defer rib.mu.Unlock()
-	for rt := range rib.entries {
+	for rt := range rib.news {
 		rib.entries[rt] = UN_CHANGED_RIB_ENT
 	}
+	rib.news = make(map[*RibEntry]struct{})
 }
 
 func (rib *Rib) DoseContainNewRoute() bool {
 	rib.mu.Lock()
 	defer rib.mu.Unlock()
-	for _, status := range rib.entries {
-		if status == NEW_RIB_ENT {
-			return true
-		}
-	}
-	return false
+	return len(rib.news) > 0
 }
 
 // AdjRibOut
 type AdjRibOut struct {
 	Rib *Rib
+	// Peerとのネゴシエーションの結果、ADD-PATHで経路を送信する場合の設定
+	// nilの場合は最適経路のみ送信する
+	AddPath *AddPathConfig
+	// Peerに送信していない経路の変更
+	// Peerから見た経路の識別子をKeyにして、同じ経路の変更は最後のものだけを送信する
+	changes map[string]*routeChange
+}
+
+// Peerに送信する経路の変更
+type routeChange struct {
+	re *RibEntry
+	// 経路を取り消す場合はtrue
+	withdraw bool
 }
 
 func NewAdjRibOut(rib *Rib) *AdjRibOut {
-	return &AdjRibOut{Rib: rib}
+	return &AdjRibOut{Rib: rib, changes: make(map[string]*routeChange)}
 }
 
+// 経路をインストールし、Peerに広告する
 func (aro *AdjRibOut) Insert(re *RibEntry) {
 	aro.Rib.Insert(re)
+	aro.changes[aro.key(re)] = &routeChange{re: re}
 }
 
-// LocRibから必要なルートをインストールする
-// この時、Rremote AS番号が含まれているルートはインストールしない。
+// LocRibのすべてのプレフィックスと、AdjRibOutにインストールしているプレフィックスの経路を
+// インストールし直す。
+// ExportPolicyや自身で生成する経路の設定が変わった場合と、セッションを確立した場合に使う。
 func (aro *AdjRibOut) InstallFromLocRib(locRib *LocRib, config *Config) {
-	rts := locRib.Rib.Routes()
-	for _, rt := range rts {
+	nws := make(map[string]*net.IPNet)
+	for _, nw := range locRib.Prefixes() {
+		nws[nw.String()] = nw
+	}
+	for _, re := range aro.Rib.Routes() {
+		nws[re.NwAddr.String()] = re.NwAddr
+	}
+	for _, nw := range nws {
+		aro.installPrefix(locRib, config, nw)
+	}
+}
+
+// LocRibで変わったプレフィックスの経路だけをインストールし直す
+func (aro *AdjRibOut) InstallPrefixes(locRib *LocRib, config *Config, nws []*net.IPNet) {
+	for _, nw := range nws {
+		aro.installPrefix(locRib, config, nw)
+	}
+}
+
+// LocRibからプレフィックスの経路をインストールし直す
+// この時、Rremote AS番号が含まれているルートと、
+// ExportPolicyで拒否されたルート、iBGPのPeerへのiBGPで受信したルートはインストールしない。
+// ADD-PATHで送信する場合は、AddPath.SendModeに従って最適経路以外の経路もインストールする。
+// 最適経路が変わった場合は、インストールしている経路を置き換える。
+// インストールされなくなった経路はAdjRibOutから削除し、Peerに取り消しを送信する。
+func (aro *AdjRibOut) installPrefix(locRib *LocRib, config *Config, nw *net.IPNet) {
+	n := 1
+	if aro.AddPath != nil {
+		n = 0
+		if aro.AddPath.SendMode == ADD_PATH_SEND_BEST_N {
+			n = aro.AddPath.SendMax
+		}
+	}
+	installs := make(map[string]*RibEntry)
+	for _, rt := range locRib.exportPaths(nw, n) {
 		if rt.containAS(config.RemoteAS) {
 			continue
 		}
+		// iBGPで受信した経路は、ほかのiBGPのPeerに送信しない (RFC4271 9.2)
+		if rt.IBGP && config.isIBGP() {
+			continue
+		}
+		if !config.ExportPolicy.Accept(rt.toPolicyPath()) {
+			continue
+		}
+		installs[aro.key(rt)] = rt
+	}
+	for _, re := range aro.Rib.Lookup(nw) {
+		key := aro.key(re)
+		if installs[key] == re {
+			// 変わっていない経路は送信し直さない
+			delete(installs, key)
+			continue
+		}
+		aro.Rib.Remove(re)
+		// 同じ識別子で別の経路を広告する場合は、広告によって置き換わるため取り消さない
+		if _, ok := installs[key]; !ok {
+			aro.changes[key] = &routeChange{re: re, withdraw: true}
+		}
+	}
+	for _, re := range installs {
 		// ここでAdjRibOutにルートをインストールする
-		aro.Insert(rt)
+		aro.Insert(re)
+	}
+}
+
+// Peerから見た経路の識別子
+// ADD-PATHで送信する場合は、プレフィックスとPath Identifierで経路を識別する
+func (aro *AdjRibOut) key(re *RibEntry) string {
+	if aro.AddPath != nil {
+		return fmt.Sprintf("%v#%d", re.NwAddr, re.localPathID)
 	}
+	return re.NwAddr.String()
+}
+
+// Peerに送信していない経路の変更があればtrueを返す
+func (aro *AdjRibOut) HasChanges() bool {
+	return len(aro.changes) > 0
 }
 
-// AdjRibOutからUpdateMessageを生成する。
+// 送信していない経路の変更からUpdateMessageを生成する。
 // PathAttributeごとにUpdateMessageが分かれるため
 // []*UpdateMessageの戻り値にしている。
+// Peerが受け取る順序が変わらないように、取り消す経路を先にして、プレフィックスの順に並べる。
+// ibgpはiBGPのセッションの場合にtrueにする。
 func (aro *AdjRibOut) ToUpdateMessages(
 	lIP net.IP,
 	lAS bgptype.AutonomousSystemNumber,
+	ibgp bool,
 ) ([]*packets.UpdateMessage, error) {
+	cs := make([]*routeChange, 0, len(aro.changes))
+	for _, c := range aro.changes {
+		cs = append(cs, c)
+	}
+	sort.Slice(cs, func(i, j int) bool {
+		if cs[i].withdraw != cs[j].withdraw {
+			return cs[i].withdraw
+		}
+		return comparePaths(cs[i].re, cs[j].re) < 0
+	})
+
 	// PathAttributeをKeyに、[]net.IPNetをValueに持つmapを使って、
 	// 同じPathAttributeのNLRIは同じ[]net.IPNetにまとめる。
 	// ここで、同じPathAttributeとされた経路は1つのUpdateMessageにまとめる。
 	// GoではmapのKeyにスライスを使うことができないため、
-	// []PathAttributeのポインタをKeyにする。
-	maps := make(map[*[]bgptype.PathAttribute][]*net.IPNet)
-	for _, ent := range aro.Rib.Routes() {
-		pas := ent.GetPathAttributes()
-		if routes, ok := maps[pas]; ok {
-			maps[pas] = append(routes, ent.NwAddr)
-		} else {
-			maps[pas] = []*net.IPNet{ent.NwAddr}
+	// []PathAttributeをバイト列にした文字列をKeyにする。
+	keys := []string{}
+	attrs := make(map[string][]bgptype.PathAttribute)
+	maps := make(map[string][]*net.IPNet)
+	// ADD-PATHで送信する場合に経路に付けるPath Identifier
+	ids := make(map[string][]uint32)
+	// 取り消す経路
+	wrs := []*net.IPNet{}
+	wids := []uint32{}
+	for _, c := range cs {
+		if c.withdraw {
+			wrs = append(wrs, c.re.NwAddr)
+			wids = append(wids, c.re.localPathID)
+			continue
+		}
+		pas := c.re.attributes()
+		// iBGPでは自身で生成した経路だけNextHopを変更するため、ほかの経路とは分ける
+		self := !ibgp || c.re.PeerAddr == nil
+		key := fmt.Sprint(self) + string(bgptype.PathAttributesToBytes(pas))
+		if _, ok := attrs[key]; !ok {
+			keys = append(keys, key)
+			attrs[key] = exportPathAttributes(pas, lIP, lAS, ibgp, self)
 		}
+		maps[key] = append(maps[key], c.re.NwAddr)
+		ids[key] = append(ids[key], c.re.localPathID)
 	}
 
+	// 取り消す経路は、PathAttributeを持たないUpdateMessageにまとめる
+	ums, err := aro.splitUpdateMessages([]bgptype.PathAttribute{}, wrs, wids, true)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		us, err := aro.splitUpdateMessages(attrs[key], maps[key], ids[key], false)
+		if err != nil {
+			return nil, err
+		}
+		ums = append(ums, us...)
+	}
+	aro.changes = make(map[string]*routeChange)
+	return ums, nil
+}
+
+// Messageの最大長を超えないように、経路を複数のUpdateMessageに分ける
+// withdrawがtrueの場合は、routesを取り消す経路として入れる
+func (aro *AdjRibOut) splitUpdateMessages(
+	pas []bgptype.PathAttribute,
+	routes []*net.IPNet,
+	ids []uint32,
+	withdraw bool,
+) ([]*packets.UpdateMessage, error) {
 	ums := []*packets.UpdateMessage{}
-	for pas, routes := range maps {
-		// PathAttributeの2つを変更する。
-		// NextHopはLocalIPに変更
-		// ASPathにはLocalASを追加
-		for _, pa := range *pas {
-			switch t := pa.(type) {
-			case *bgptype.NextHop:
-				*t = bgptype.NextHop([]byte(lIP.To4()))
-			case *bgptype.AsSequence:
-				t.Add(lAS)
-			}
+	room := packets.MAX_MESSAGE_LENGTH - packets.UpdateMessageOverhead(pas)
+	flush := func(start, end int) error {
+		var um *packets.UpdateMessage
+		var err error
+		if withdraw {
+			um, err = aro.newUpdateMessage(pas, nil, nil, routes[start:end], ids[start:end])
+		} else {
+			um, err = aro.newUpdateMessage(pas, routes[start:end], ids[start:end], nil, nil)
 		}
-		um, err := packets.NewUpdateMessage(
-			*pas,
-			routes,
-			[]*net.IPNet{},
-		)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		ums = append(ums, um)
+		return nil
+	}
+	start, size := 0, 0
+	for i, n := range routes {
+		l, err := packets.RouteByteLen(n, aro.AddPath != nil)
+		if err != nil {
+			return nil, err
+		}
+		if size+l > room && i > start {
+			if err := flush(start, i); err != nil {
+				return nil, err
+			}
+			start, size = i, 0
+		}
+		size += l
+	}
+	if start < len(routes) {
+		if err := flush(start, len(routes)); err != nil {
+			return nil, err
+		}
 	}
-
 	return ums, nil
 }
 
+// ADD-PATHで送信する場合はPath Identifierを付けたUpdateMessageを生成する
+func (aro *AdjRibOut) newUpdateMessage(
+	pas []bgptype.PathAttribute,
+	routes []*net.IPNet,
+	ids []uint32,
+	wrs []*net.IPNet,
+	wids []uint32,
+) (*packets.UpdateMessage, error) {
+	if routes == nil {
+		routes, ids = []*net.IPNet{}, []uint32{}
+	}
+	if wrs == nil {
+		wrs, wids = []*net.IPNet{}, []uint32{}
+	}
+	if aro.AddPath != nil {
+		return packets.NewAddPathUpdateMessage(pas, routes, ids, wrs, wids)
+	}
+	return packets.NewUpdateMessage(pas, routes, wrs)
+}
+
+// 経路をプレフィックス、Path Identifierの順に比較する
+func comparePaths(a, b *RibEntry) int {
+	if c := bytes.Compare(a.NwAddr.IP.To16(), b.NwAddr.IP.To16()); c != 0 {
+		return c
+	}
+	aOnes, _ := a.NwAddr.Mask.Size()
+	bOnes, _ := b.NwAddr.Mask.Size()
+	if c := cmp.Compare(aOnes, bOnes); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.localPathID, b.localPathID)
+}
+
+// Peerに送信するPathAttributeを返す。次の2つを変更する。
+// nextHopSelfがtrueの場合は、NextHopをLocalIPに変更
+// eBGPのセッションの場合は、ASPathにLocalASを追加
+// iBGPのセッションでは、ほかのPeerから受信した経路のNextHopとASPathをそのまま送信する (RFC4271 5.1.2, 5.1.3)。
+// PathAttributeはLocRibやほかのPeerのAdjRibOutと共有しているため、変更するものは複製する。
+func exportPathAttributes(
+	pas []bgptype.PathAttribute,
+	lIP net.IP,
+	lAS bgptype.AutonomousSystemNumber,
+	ibgp bool,
+	nextHopSelf bool,
+) []bgptype.PathAttribute {
+	out := make([]bgptype.PathAttribute, len(pas))
+	for i, pa := range pas {
+		switch t := pa.(type) {
+		case *bgptype.NextHop:
+			if !nextHopSelf {
+				out[i] = pa
+				continue
+			}
+			nh := bgptype.NextHop([]byte(lIP.To4()))
+			out[i] = &nh
+		case *bgptype.AsSequence:
+			if ibgp {
+				out[i] = pa
+				continue
+			}
+			seq := slices.Clone(*t)
+			seq.Add(lAS)
+			out[i] = &seq
+		default:
+			out[i] = pa
+		}
+	}
+	return out
+}
+
 type AdjRibIn struct {
 	Rib *Rib
+	// 受信した経路をRPKIで検証するためのVRP
+	// nilの場合は検証しない
+	Validator *rpki.Table
+	// WithdrawnRoutesや同じプレフィックスの経路の受信によって
+	// AdjRibInから削除されたentry
+	// LocRibからも削除する必要があるため保持しておく
+	withdrawn []*RibEntry
+	// 受信プレフィックス数の警告を出したかどうか
+	maxPrefixWarned bool
+	// Route Flap Dampingで使う時計。nilの場合は実際の時刻を使う
+	Clock Clock
+	// Route Flap Dampingの履歴
+	// Config.Dampingが設定されている場合に作成する
+	damping *damping
 }
 
 func NewAdjRibIn(rib *Rib) *AdjRibIn {
 	return &AdjRibIn{Rib: rib}
 }
 
+// 受信プレフィックス数が上限を超えたことを表すエラー
+type MaxPrefixExceededError struct {
+	Limit uint32
+}
+
+func (e *MaxPrefixExceededError) Error() string {
+	return fmt.Sprintf("maximum number of prefixes reached, limit: %d", e.Limit)
+}
+
+// UpdateMessageの経路をインストールする。
+// WithdrawnRoutesの経路と、NLRIと同じプレフィックスの既存の経路は削除する。
+// Config.MaxPrefixの上限を超えた場合、DropOnlyでなければ
+// *MaxPrefixExceededErrorを返す。
 func (ari *AdjRibIn) InstallFromUpdate(
 	um *packets.UpdateMessage,
 	config *Config,
-) {
-	// TODO: withDrawnに対応する
+) error {
+	ari.enableDamping(config.Damping)
+	for i, wr := range um.WithdrawnRoutes {
+		olds := ari.Rib.LookupPath(wr, pathIDAt(um.WithdrawnPathIDs, i))
+		for _, re := range olds {
+			ari.remove(re)
+		}
+		if len(olds) > 0 {
+			ari.penalize(wr, DAMPING_WITHDRAW_PENALTY, config)
+		}
+	}
 	pa := um.PathAttributes
-	for _, nw := range um.NetworkLayerReachabilityInformation {
+	for i, nw := range um.NetworkLayerReachabilityInformation {
+		id := pathIDAt(um.NLRIPathIDs, i)
+		olds := ari.Rib.LookupPath(nw, id)
+		for _, re := range olds {
+			if !samePathAttributes(re.attributes(), pa) {
+				ari.penalize(nw, DAMPING_ATTRIBUTE_CHANGE_PENALTY, config)
+				break
+			}
+		}
+		if len(olds) == 0 {
+			ok, err := ari.acceptPrefix(nw, config)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+		}
+		// PathAttributeが変わっている可能性があるため、古い経路は削除する
+		for _, re := range olds {
+			ari.remove(re)
+		}
 		re := NewRibEntry(nw, pa...)
-		// PathAttributeが変わっていたらインストールする必要がある
+		re.PeerAddr = config.RemoteIP
+		re.IBGP = config.isIBGP()
+		re.PathID = id
+		re.Validation = ari.validate(re, config)
 		ari.Rib.Insert(re)
 	}
+	return nil
+}
+
+// RFC6811に従って、AS Pathの最後のASを経路の生成元として検証する
+func (ari *AdjRibIn) validate(re *RibEntry, config *Config) rpki.ValidationState {
+	if ari.Validator == nil {
+		return rpki.NotFound
+	}
+	origin, ok := re.originAS(config.LocalAS)
+	return ari.Validator.Validate(re.NwAddr, uint32(origin), ok)
+}
+
+// VRPが更新されたときに、すべての経路を検証し直す。
+// 検証結果が変わった経路があればtrueを返す。
+func (ari *AdjRibIn) Revalidate(config *Config) bool {
+	changed := false
+	for _, re := range ari.Rib.Routes() {
+		v := ari.validate(re, config)
+		if v == re.Validation {
+			continue
+		}
+		// LocRibに登録した経路はほかのPeerのgoroutineも参照するため、
+		// 変更せずに検証結果を変えた経路に置き換える
+		nre := re.clone()
+		nre.Validation = v
+		ari.remove(re)
+		ari.Rib.Insert(nre)
+		changed = true
+	}
+	return changed
+}
+
+// 新しいプレフィックスを受け入れられるかを確認する。
+// ADD-PATHで受信した経路のように、すでにあるプレフィックスの経路は数えずに受け入れる。
+// 上限を超える場合、DropOnlyであればログを出してfalseを返し、
+// そうでなければ*MaxPrefixExceededErrorを返す。
+// プレフィックス数が警告のしきい値を下回っている場合は、再び警告できるようにする。
+func (ari *AdjRibIn) acceptPrefix(nw *net.IPNet, config *Config) (bool, error) {
+	mp := config.MaxPrefix
+	if mp == nil || len(ari.Rib.Lookup(nw)) > 0 {
+		return true, nil
+	}
+	cur := uint32(ari.Rib.PrefixLen())
+	if uint64(cur)*100 < uint64(mp.Limit)*uint64(mp.WarningThreshold) {
+		ari.maxPrefixWarned = false
+	}
+	n := cur + 1
+	if n > mp.Limit {
+		if mp.DropOnly {
+			peerLogger(ribLog, config).Warn(
+				"maximum number of prefixes reached, prefix is dropped",
+				"limit", mp.Limit, "prefix", nw.String(),
+			)
+			return false, nil
+		}
+		return false, &MaxPrefixExceededError{Limit: mp.Limit}
+	}
+	if mp.WarningThreshold > 0 && !ari.maxPrefixWarned &&
+		uint64(n)*100 >= uint64(mp.Limit)*uint64(mp.WarningThreshold) {
+		peerLogger(ribLog, config).Warn(
+			"number of prefixes reached warning threshold",
+			"threshold_percent", mp.WarningThreshold, "prefixes", n, "limit", mp.Limit,
+		)
+		ari.maxPrefixWarned = true
+	}
+	return true, nil
+}
+
+// Route Flap Dampingの履歴を作成する
+// cがnilの場合と、作成済みの場合は何もしない
+func (ari *AdjRibIn) enableDamping(c *DampingConfig) {
+	if c == nil || ari.damping != nil {
+		return
+	}
+	clock := ari.Clock
+	if clock == nil {
+		clock = systemClock{}
+	}
+	ari.damping = newDamping(c, clock)
+}
+
+func (ari *AdjRibIn) penalize(nw *net.IPNet, penalty float64, config *Config) {
+	if ari.damping == nil {
+		return
+	}
+	if ari.damping.penalize(nw, penalty) {
+		peerLogger(ribLog, config).Info("route is suppressed by damping", "prefix", nw.String())
+		// 同じプレフィックスのほかのPath Identifierの経路もLocRibから除外する
+		for _, re := range ari.Rib.Lookup(nw) {
+			ari.Rib.MarkChanged(re)
+		}
+	}
+}
+
+// Route Flap Dampingで抑制されている経路かどうかを返す
+// 抑制されている経路はAdjRibInに残すが、LocRibの最適経路の選択からは除外する
+func (ari *AdjRibIn) IsSuppressed(re *RibEntry) bool {
+	return ari.damping != nil && ari.damping.isSuppressed(re.NwAddr)
+}
+
+// ペナルティが減衰した経路の抑制を解除する
+// 解除した経路はLocRibにインストールし直すため、変更されたものとして扱う
+// 解除した経路があればtrueを返す
+func (ari *AdjRibIn) ReuseDampedRoutes() bool {
+	if ari.damping == nil {
+		return false
+	}
+	nws := ari.damping.reuse()
+	for _, nw := range nws {
+		for _, re := range ari.Rib.Lookup(nw) {
+			ari.Rib.MarkChanged(re)
+		}
+	}
+	return len(nws) > 0
+}
+
+// 次に抑制を解除するか、十分に減衰した履歴を削除できる時刻を返す
+func (ari *AdjRibIn) NextDampingReuse() (time.Time, bool) {
+	if ari.damping == nil {
+		return time.Time{}, false
+	}
+	return ari.damping.nextReuse()
+}
+
+// フラップの履歴があるプレフィックスの一覧を返す
+// Peerのgoroutine以外から呼び出してもよい
+func (ari *AdjRibIn) DampedRoutes() []*DampedRoute {
+	if ari.damping == nil {
+		return []*DampedRoute{}
+	}
+	return ari.damping.routes()
+}
+
+// ADD-PATHが有効でない場合、Path Identifierは0として扱う
+func pathIDAt(ids []uint32, i int) uint32 {
+	if i < len(ids) {
+		return ids[i]
+	}
+	return 0
 }
 
-// AdjRibInからLocRibに必要なルートをインストールする。
-// この時、自ASが含まれているルートはインストールしない。
+func samePathAttributes(a, b []bgptype.PathAttribute) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i].ToBytes(), b[i].ToBytes()) {
+			return false
+		}
+	}
+	return true
+}
+
+func (ari *AdjRibIn) remove(re *RibEntry) {
+	if ari.Rib.Remove(re) {
+		ari.withdrawn = append(ari.withdrawn, re)
+	}
+}
+
+// AdjRibInから削除されたがLocRibに反映されていないentryがあるかを返す
+func (ari *AdjRibIn) HasWithdrawnRoute() bool {
+	return len(ari.withdrawn) > 0
+}
+
+// AdjRibInのすべての経路を削除する
+// セッションが切断されたときに使用する
+func (ari *AdjRibIn) Clear() {
+	for _, re := range ari.Rib.Routes() {
+		ari.remove(re)
+	}
+	ari.maxPrefixWarned = false
+}
+
+// AdjRibInからLocRibに必要なルートをインストールし、最適経路を選択し直す。
+// この時、自ASが含まれているルートと、
+// ImportPolicyで拒否されたルートはインストールしない。
 // 参考: 9.1.2.  Phase 2: Route Selection in RFC4271.
-func (lr *LocRib) InstallFromAdjRibIn(ari *AdjRibIn) {
-	rts := ari.Rib.Routes()
+func (lr *LocRib) InstallFromAdjRibIn(ari *AdjRibIn, config *Config) {
+	lr.mu.Lock()
+	defer lr.unlock()
+	nws := make(map[string]struct{})
+	// AdjRibInから削除された経路はLocRibからも削除する
+	for _, re := range ari.withdrawn {
+		lr.uninstallPath(re)
+		nws[re.NwAddr.String()] = struct{}{}
+	}
+	ari.withdrawn = nil
+	// 前回インストールした後に受信した経路と、評価し直す必要がある経路だけを処理する
+	// RPKIの検証結果が変わった経路は置き換えられるため、Policyも評価し直される
+	rts := ari.Rib.NewRoutes()
+	ari.Rib.UpsateToAllUnchanged()
 	for _, rt := range rts {
-		if rt.containAS(lr.LocalASNum) {
-			continue
+		// Route Flap Dampingで抑制されている経路と、NextHopに到達できない経路も除外する
+		if rt.containAS(lr.LocalASNum) || ari.IsSuppressed(rt) ||
+			!config.ImportPolicy.Accept(rt.toPolicyPath()) || !lr.installPath(rt) {
+			lr.uninstallPath(rt)
 		}
-		lr.Rib.Insert(rt)
+		nws[rt.NwAddr.String()] = struct{}{}
 	}
+	for nw := range nws {
+		lr.updateBestPath(nw)
+	}
+	lr.updateAggregates()
 }
 
-func (rib *LocRib) LookupRoutingTable(dst *net.IPNet) ([]*net.IPNet, error) {
-	// ルーティングテーブルの取得
-	routes, err := netlink.RouteList(nil, netlink.FAMILY_V4)
-	if err != nil {
-		return nil, err
+// AdjRibInの経路を候補経路に追加する
+// AdjRibInの経路はほかのgoroutineからも参照するため変更せず、
+// NextHopを解決した場合は解決結果を設定した複製を追加する。
+// NextHopに到達できない場合は追加せずにfalseを返す
+func (lr *LocRib) installPath(rt *RibEntry) bool {
+	metric, gw, ok := lr.resolveNextHop(rt)
+	if !ok {
+		return false
+	}
+	old := lr.installed[rt]
+	if old != nil && old.igpMetric == metric && old.gateway.Equal(gw) {
+		return true
+	}
+	re := rt
+	if metric != 0 || gw != nil {
+		re = rt.clone()
+		re.igpMetric = metric
+		re.gateway = gw
+	}
+	if old != nil {
+		// 解決結果だけが変わった場合は、Peerに送信するPath Identifierを変えない
+		if re.localPathID == 0 {
+			re.localPathID = old.localPathID
+		}
+		lr.removePath(old)
+	}
+	if lr.installed == nil {
+		lr.installed = make(map[*RibEntry]*RibEntry)
+	}
+	lr.installed[rt] = re
+	lr.addPath(re)
+	return true
+}
+
+// AdjRibInの経路として追加した経路を候補経路から削除する
+func (lr *LocRib) uninstallPath(rt *RibEntry) {
+	re, ok := lr.installed[rt]
+	if !ok {
+		return
 	}
-	dsts := []*net.IPNet{}
-	for _, route := range routes {
-		if route.Dst != nil && route.Dst.Contains(dst.IP) {
-			dsts = append(dsts, route.Dst)
+	delete(lr.installed, rt)
+	lr.removePath(re)
+}
+
+// 経路のNextHopを解決し、IGPのコストと実際にパケットを送るゲートウェイを返す
+// NextHopに到達できない場合はfalseを返す
+// 解決したゲートウェイとIGPのコストは最適経路の選択とカーネルへの書き込みに使用する
+func (lr *LocRib) resolveNextHop(re *RibEntry) (int, net.IP, bool) {
+	if lr.NextHops == nil || re.PeerAddr == nil {
+		return 0, nil, true
+	}
+	nh := re.nextHop()
+	if nh == nil {
+		return 0, nil, false
+	}
+	r := lr.NextHops.Resolve(nh)
+	if !r.Reachable {
+		return 0, nil, false
+	}
+	if r.Gateway.Equal(nh) {
+		return r.Metric, nil, true
+	}
+	return r.Metric, r.Gateway, true
+}
+
+// AdjRibInの経路のうち、最適経路として選択されている経路の数を返す
+func (lr *LocRib) countBest(rts []*RibEntry) int {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	n := 0
+	for _, rt := range rts {
+		re, ok := lr.installed[rt]
+		if ok && lr.best[re.NwAddr.String()] == re {
+			n++
+		}
+	}
+	return n
+}
+
+// 候補経路に追加する
+func (lr *LocRib) addPath(re *RibEntry) {
+	if lr.paths == nil {
+		lr.paths = make(map[string][]*RibEntry)
+		lr.best = make(map[string]*RibEntry)
+		lr.multipaths = make(map[string][]*RibEntry)
+	}
+	key := re.NwAddr.String()
+	for _, p := range lr.paths[key] {
+		if p == re {
+			return
 		}
 	}
-	return dsts, nil
+	if re.localPathID == 0 {
+		lr.lastPathID++
+		re.localPathID = lr.lastPathID
+	}
+	lr.paths[key] = append(lr.paths[key], re)
+	lr.markChanged(re.NwAddr)
+}
+
+// 候補経路から削除する
+func (lr *LocRib) removePath(re *RibEntry) {
+	key := re.NwAddr.String()
+	ps := lr.paths[key]
+	for i, p := range ps {
+		if p == re {
+			lr.paths[key] = append(ps[:i:i], ps[i+1:]...)
+			lr.markChanged(re.NwAddr)
+			break
+		}
+	}
+	if len(lr.paths[key]) == 0 {
+		delete(lr.paths, key)
+	}
+}
+
+// プレフィックスの最適経路を選択し直し、変わっていればRibを更新する
+// 最適経路が変わらなくても、マルチパスの経路が変わった場合は
+// カーネルのルートを更新するために最適経路を変更されたものとして扱う。
+func (lr *LocRib) updateBestPath(key string) {
+	best := selectBestPath(lr.paths[key])
+	cur := lr.best[key]
+	mps := selectMultipaths(best, lr.paths[key], lr.Multipath)
+	mpsChanged := !sameEntries(mps, lr.multipaths[key])
+	if len(mps) == 0 {
+		delete(lr.multipaths, key)
+	} else {
+		lr.multipaths[key] = mps
+	}
+	if best != cur || mpsChanged {
+		lr.markFIBDirty(key, best, cur)
+		if best != nil {
+			lr.markChanged(best.NwAddr)
+		} else if cur != nil {
+			lr.markChanged(cur.NwAddr)
+		}
+	}
+	if best == cur {
+		return
+	}
+	if best != nil {
+		lr.markAggregateDirty(best.NwAddr)
+	} else {
+		lr.markAggregateDirty(cur.NwAddr)
+	}
+	if cur != nil {
+		lr.Rib.Remove(cur)
+		delete(lr.best, key)
+	}
+	if best != nil {
+		lr.Rib.Insert(best)
+		lr.best[key] = best
+	}
+	if lr.OnBestPathChange != nil {
+		if best != nil {
+			lr.OnBestPathChange(best.NwAddr, best)
+		} else {
+			lr.OnBestPathChange(cur.NwAddr, nil)
+		}
+	}
+}
+
+// Peerに送信する経路が変わった可能性のあるプレフィックスとして記録する
+func (lr *LocRib) markChanged(nw *net.IPNet) {
+	if lr.changed == nil {
+		lr.changed = make(map[string]*net.IPNet)
+	}
+	lr.changed[nw.String()] = nw
+}
+
+// lr.muのロックを解除し、変わったプレフィックスを起動中のすべてのPeerに通知する
+// 通知を受けたPeerは、自身のgoroutineでAdjRibOutの経路を選び直す。
+// Peerのイベントの処理を待たないため、Peerのgoroutineから呼び出してもよい。
+func (lr *LocRib) unlock() {
+	nws := make([]*net.IPNet, 0, len(lr.changed))
+	for _, nw := range lr.changed {
+		nws = append(nws, nw)
+	}
+	lr.changed = nil
+	// 最適経路の変更はPeerに通知したので、Ribの状態は使わない
+	lr.Rib.UpsateToAllUnchanged()
+	lr.mu.Unlock()
+	if len(nws) == 0 {
+		return
+	}
+	lr.peersMu.Lock()
+	defer lr.peersMu.Unlock()
+	for p := range lr.peers {
+		p.notifyLocRibChanged(nws)
+	}
+}
+
+// LocRibの変更を通知するPeerとして登録する
+func (lr *LocRib) register(p *Peer) {
+	lr.peersMu.Lock()
+	defer lr.peersMu.Unlock()
+	if lr.peers == nil {
+		lr.peers = make(map[*Peer]struct{})
+	}
+	lr.peers[p] = struct{}{}
+}
+
+func (lr *LocRib) unregister(p *Peer) {
+	lr.peersMu.Lock()
+	defer lr.peersMu.Unlock()
+	delete(lr.peers, p)
+}
+
+func (lr *LocRib) markFIBDirty(key string, best, cur *RibEntry) {
+	if lr.fibDirty == nil {
+		lr.fibDirty = make(map[string]*net.IPNet)
+	}
+	if best != nil {
+		lr.fibDirty[key] = best.NwAddr
+	} else if cur != nil {
+		lr.fibDirty[key] = cur.NwAddr
+	}
+}
+
+// 最適経路と等価で、同時にカーネルにインストールする経路を返す
+// 先頭は最適経路
+func (lr *LocRib) Multipaths(nw *net.IPNet) []*RibEntry {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	return append([]*RibEntry{}, lr.multipaths[nw.String()]...)
+}
+
+func sameEntries(a, b []*RibEntry) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// プレフィックスごとに、候補経路を優先度の高い順に並べて返す
+// ADD-PATHで最適経路以外の経路も送信するために使用する
+func (lr *LocRib) RankedPaths() [][]*RibEntry {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	rps := make([][]*RibEntry, 0, len(lr.paths))
+	for _, ps := range lr.paths {
+		ps = append([]*RibEntry{}, ps...)
+		sort.SliceStable(ps, func(i, j int) bool { return betterPath(ps[i], ps[j]) })
+		rps = append(rps, ps)
+	}
+	return rps
+}
+
+// 候補経路のあるプレフィックスを返す
+func (lr *LocRib) Prefixes() []*net.IPNet {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	nws := make([]*net.IPNet, 0, len(lr.paths))
+	for _, ps := range lr.paths {
+		nws = append(nws, ps[0].NwAddr)
+	}
+	return nws
+}
+
+// プレフィックスの候補経路のうち、Peerに送信する経路を優先度の高い順に最大n個返す
+// nが1の場合は最適経路を、0の場合はすべての候補経路を返す。
+// summary-onlyの集約した経路の集約元の経路は返さない。
+func (lr *LocRib) exportPaths(nw *net.IPNet, n int) []*RibEntry {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	key := nw.String()
+	var ps []*RibEntry
+	if n == 1 {
+		if best := lr.best[key]; best != nil {
+			ps = []*RibEntry{best}
+		}
+	} else {
+		ps = append([]*RibEntry{}, lr.paths[key]...)
+		sort.SliceStable(ps, func(i, j int) bool { return betterPath(ps[i], ps[j]) })
+		if n > 0 && len(ps) > n {
+			ps = ps[:n]
+		}
+	}
+	rts := make([]*RibEntry, 0, len(ps))
+	for _, p := range ps {
+		if !lr.isSummarized(p) {
+			rts = append(rts, p)
+		}
+	}
+	return rts
+}
+
+// 最適経路のあるプレフィックスの数と、候補経路の数を返す
+func (lr *LocRib) Size() (prefixes, paths int) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	for _, ps := range lr.paths {
+		paths += len(ps)
+	}
+	return lr.Rib.Len(), paths
+}
+
+// プレフィックスの候補経路をすべて返す
+func (lr *LocRib) Paths(nw *net.IPNet) []*RibEntry {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	return append([]*RibEntry{}, lr.paths[nw.String()]...)
+}
+
+// dstのアドレスを含むネットワークをFIBのルーティングテーブルから探す
+func (lr *LocRib) LookupRoutingTable(dst *net.IPNet) ([]*net.IPNet, error) {
+	return lr.FIB.Lookup(dst)
 }
@@ -1,24 +1,31 @@
 package peer
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net"
+	"net/netip"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/SotaUeda/gobgp/bgptype"
+	"github.com/SotaUeda/gobgp/fib"
 	"github.com/SotaUeda/gobgp/packets"
+	"github.com/SotaUeda/gobgp/policy"
+	"github.com/SotaUeda/gobgp/rpki"
 )
 
 // LocRibのLookupRoutingTableメソッドが正しく動作することを確認するテスト
 // LookupRoutingTableメソッドは引数で指定されたネットワークアドレスに対応する
 // ローカルのルーティングテーブル上のroute(*net.IPNet)のスライスを返す
 func TestLocRibCanLookupRoutingTable(t *testing.T) {
-	// 本テストの値は環境によって異なる
-	// 本実装では開発機、テスト実施機に
-	// 10.200.100.0/24に属するIPが付与されていることを仮定している
+	// 10.200.100.0/24に属するIPが付与されている環境を
+	// メモリ上のFIBで再現する
 	network := "10.200.100.0/24"
 	_, dst, _ := net.ParseCIDR(network)
-	rib := &LocRib{}
+	rib := &LocRib{FIB: fib.NewMemory(dst)}
 	routes, err := rib.LookupRoutingTable(dst)
 	if err != nil {
 		t.Errorf("Route not found")
@@ -38,14 +45,13 @@ func TestLocRibCanLookupRoutingTable(t *testing.T) {
 
 // AdjRibOutへルートをインストールする機能のテスト
 func TestLocRibToAdjRibOut(t *testing.T) {
-	// 本テストの値は環境によって異なる
-	// 本実装では開発機、テスト実施機に
-	// 10.200.100.0/24に属するIPが付与されていることを仮定している
-	// docker-composeした環境のhost2で実行することを仮定している
+	// docker-composeした環境のhost2のルーティングテーブルを
+	// メモリ上のFIBで再現する
 	config, _ := ParseConfig(
 		"64513 10.200.100.3 64512 10.200.100.2 passive 10.100.220.0/24",
 	)
-	lr, err := NewLocRib(config)
+	_, connected, _ := net.ParseCIDR("10.100.220.0/24")
+	lr, err := NewLocRib(config, fib.NewMemory(connected))
 	if err != nil {
 		t.Errorf("Error: %v", err)
 	}
@@ -80,7 +86,7 @@ func diffAdjRibOut(a, e *AdjRibOut) bool {
 	}
 	var aps, eps string
 	for are, ast := range a.Rib.entries {
-		for _, pa := range *are.GetPathAttributes() {
+		for _, pa := range are.GetPathAttributes() {
 			aps += fmt.Sprintf("%v", pa.ToBytes())
 		}
 		for ere, est := range e.Rib.entries {
@@ -90,7 +96,7 @@ func diffAdjRibOut(a, e *AdjRibOut) bool {
 			if ast != est {
 				return false
 			}
-			for _, pa := range *ere.GetPathAttributes() {
+			for _, pa := range ere.GetPathAttributes() {
 				eps += fmt.Sprintf("%v", pa.ToBytes())
 			}
 			if aps != eps {
@@ -157,7 +163,7 @@ func TestUpdateMessageFromAdjRibOut(t *testing.T) {
 		t.Errorf("Error: %v", err)
 	}
 	expectedMsgs := []*packets.UpdateMessage{expectedUpdateMsg}
-	acctualUpdateMsg, err := adjRibOut.ToUpdateMessages(localIP, localAS)
+	acctualUpdateMsg, err := adjRibOut.ToUpdateMessages(localIP, localAS, false)
 	if err != nil {
 		t.Errorf("Error: %v", err)
 	}
@@ -171,3 +177,828 @@ func TestUpdateMessageFromAdjRibOut(t *testing.T) {
 		t.Errorf("Want: %v, \nGot: %v", want, get)
 	}
 }
+
+// Peerから取り消された経路が、AdjRibOutから削除されて取り消しを送信することを確認するテスト
+func TestAdjRibOutWithdrawsRouteRemovedFromLocRib(t *testing.T) {
+	config, _ := ParseConfig("64512 127.0.0.1 65001 127.0.0.2 active")
+	outConfig, _ := ParseConfig("64512 127.0.0.1 65002 127.0.0.3 active")
+	lr := &LocRib{Rib: NewRib(), LocalASNum: config.LocalAS}
+	ari := NewAdjRibIn(NewRib())
+	aro := NewAdjRibOut(NewRib())
+	igp := bgptype.IGP
+	nh := bgptype.NextHop(net.ParseIP("10.0.0.1").To4())
+	_, nw, _ := net.ParseCIDR("10.1.0.0/16")
+	announce, _ := packets.NewUpdateMessage(
+		[]bgptype.PathAttribute{&igp, bgptype.NewAsPath(true, 65001), &nh},
+		[]*net.IPNet{nw},
+		[]*net.IPNet{},
+	)
+	withdraw, _ := packets.NewUpdateMessage([]bgptype.PathAttribute{}, []*net.IPNet{}, []*net.IPNet{nw})
+	for _, um := range []*packets.UpdateMessage{announce, withdraw} {
+		if err := ari.InstallFromUpdate(um, config); err != nil {
+			t.Fatal(err)
+		}
+		lr.InstallFromAdjRibIn(ari, config)
+		aro.InstallFromLocRib(lr, outConfig)
+	}
+	if lr.Rib.Len() != 0 || aro.Rib.Len() != 0 {
+		t.Errorf("Want: 0 and 0, Got: %d and %d", lr.Rib.Len(), aro.Rib.Len())
+	}
+	ums, err := aro.ToUpdateMessages(outConfig.LocalIP, outConfig.LocalAS, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var withdrawn []*net.IPNet
+	for _, um := range ums {
+		withdrawn = append(withdrawn, um.WithdrawnRoutes...)
+	}
+	if len(withdrawn) != 1 || withdrawn[0].String() != nw.String() {
+		t.Errorf("Want: [%v], Got: %v", nw, withdrawn)
+	}
+}
+
+// AS Pathが複数のセグメントに分かれている場合も、すべてのセグメントからAS番号を探すことを確認するテスト
+func TestRibEntryContainASInAllSegments(t *testing.T) {
+	_, nw, _ := net.ParseCIDR("10.1.0.0/16")
+	re := NewRibEntry(nw,
+		bgptype.NewAsPath(true, 65001),
+		bgptype.NewAsPath(false, 65003, 65002),
+		bgptype.NewAsPath(true, 65004),
+	)
+	for _, as := range []bgptype.AutonomousSystemNumber{65001, 65002, 65004} {
+		if !re.containAS(as) {
+			t.Errorf("Want: %v is contained, Got: not contained", as)
+		}
+	}
+	if re.containAS(65005) {
+		t.Errorf("Want: 65005 is not contained, Got: contained")
+	}
+}
+
+// 最適経路が変わると、AdjRibOutの経路を置き換えて新しい経路だけを広告することを確認するテスト
+func TestAdjRibOutReplacesBestPath(t *testing.T) {
+	outConfig, _ := ParseConfig("64512 127.0.0.1 65002 127.0.0.3 active")
+	lr := &LocRib{Rib: NewRib(), LocalASNum: outConfig.LocalAS}
+	aro := NewAdjRibOut(NewRib())
+	_, nw, _ := net.ParseCIDR("10.1.0.0/16")
+	installPaths(t, lr, nw, map[string][]bgptype.AutonomousSystemNumber{
+		"10.0.0.1": {65001, 65010, 65020},
+	})
+	aro.InstallFromLocRib(lr, outConfig)
+	if _, err := aro.ToUpdateMessages(outConfig.LocalIP, outConfig.LocalAS, false); err != nil {
+		t.Fatal(err)
+	}
+
+	installPaths(t, lr, nw, map[string][]bgptype.AutonomousSystemNumber{
+		"10.0.0.3": {65003},
+	})
+	aro.InstallFromLocRib(lr, outConfig)
+	best := lr.Rib.Lookup(nw)
+	if rts := aro.Rib.Lookup(nw); len(rts) != 1 || rts[0] != best[0] {
+		t.Fatalf("Want: %v, Got: %v", best, rts)
+	}
+	ums, err := aro.ToUpdateMessages(outConfig.LocalIP, outConfig.LocalAS, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ums) != 1 || len(ums[0].WithdrawnRoutes) != 0 {
+		t.Fatalf("Want: 1 announcement, Got: %v", ums)
+	}
+	if got := policy.AsPathString(ums[0].PathAttributes); !strings.Contains(got, "65003") || strings.Contains(got, "65010") {
+		t.Errorf("Want: path via 65003, Got: %v", got)
+	}
+}
+
+// AdjRibOutの変更は、取り消しを先にしてプレフィックスの順に送信することを確認するテスト
+func TestAdjRibOutSendsChangesInOrder(t *testing.T) {
+	igp := bgptype.IGP
+	nh := bgptype.NextHop(net.ParseIP("10.0.100.3").To4())
+	pas := []bgptype.PathAttribute{&igp, bgptype.NewAsPath(true, 64513), &nh}
+	aro := NewAdjRibOut(NewRib())
+	for _, s := range []string{"10.3.0.0/16", "10.1.0.0/16", "10.1.0.0/24", "10.2.0.0/16"} {
+		_, nw, _ := net.ParseCIDR(s)
+		aro.Insert(NewRibEntry(nw, pas...))
+	}
+	_, wd, _ := net.ParseCIDR("10.9.0.0/16")
+	aro.changes[wd.String()] = &routeChange{re: NewRibEntry(wd), withdraw: true}
+	for i := 0; i < 3; i++ {
+		// mapの順序によらず、毎回同じ順序になる
+		c := make(map[string]*routeChange)
+		for k, v := range aro.changes {
+			c[k] = v
+		}
+		ums, err := aro.ToUpdateMessages(net.ParseIP("10.200.100.3").To4(), 64514, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		aro.changes = c
+		if len(ums) != 2 || len(ums[0].WithdrawnRoutes) != 1 {
+			t.Fatalf("Want: withdrawal and announcement, Got: %v", ums)
+		}
+		got := fmt.Sprint(ums[1].NetworkLayerReachabilityInformation)
+		if want := "[10.1.0.0/16 10.1.0.0/24 10.2.0.0/16 10.3.0.0/16]"; got != want {
+			t.Errorf("Want: %v, Got: %v", want, got)
+		}
+	}
+}
+
+// 経路が多い場合は、Messageの最大長を超えないようにUpdateMessageを分けることを確認するテスト
+func TestToUpdateMessagesSplitsLargeUpdates(t *testing.T) {
+	igp := bgptype.IGP
+	nh := bgptype.NextHop(net.ParseIP("10.0.100.3").To4())
+	pas := []bgptype.PathAttribute{&igp, bgptype.NewAsPath(true, 64513), &nh}
+	aro := NewAdjRibOut(NewRib())
+	for i := 0; i < 2000; i++ {
+		_, nw, _ := net.ParseCIDR(fmt.Sprintf("10.%d.%d.0/24", i/256, i%256))
+		aro.Insert(NewRibEntry(nw, pas...))
+		_, wd, _ := net.ParseCIDR(fmt.Sprintf("172.%d.%d.0/24", 16+i/256, i%256))
+		aro.changes[wd.String()] = &routeChange{re: NewRibEntry(wd), withdraw: true}
+	}
+	ums, err := aro.ToUpdateMessages(net.ParseIP("10.200.100.3").To4(), 64514, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nlris, wrs := 0, 0
+	for _, um := range ums {
+		b, err := um.ToBytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(b) > packets.MAX_MESSAGE_LENGTH {
+			t.Errorf("Want: <= %d, Got: %d", packets.MAX_MESSAGE_LENGTH, len(b))
+		}
+		// 分けたUpdateMessageも、受信側で元の経路に変換できる
+		m, err := packets.BytesToMessage(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nlris += len(m.(*packets.UpdateMessage).NetworkLayerReachabilityInformation)
+		wrs += len(m.(*packets.UpdateMessage).WithdrawnRoutes)
+	}
+	if nlris != 2000 || wrs != 2000 {
+		t.Errorf("Want: 2000 and 2000, Got: %d and %d", nlris, wrs)
+	}
+}
+
+// UpdateMessageを生成しても、共有している経路のPathAttributeは変更しないことを確認するテスト
+// 変更するNextHopとAS Pathは複製してから変更するため、何度生成しても同じUpdateMessageになる
+func TestToUpdateMessagesDoesNotModifySharedPathAttributes(t *testing.T) {
+	igp := bgptype.IGP
+	nh := bgptype.NextHop(net.ParseIP("10.0.100.3").To4())
+	_, nw1, _ := net.ParseCIDR("10.1.0.0/16")
+	_, nw2, _ := net.ParseCIDR("10.2.0.0/16")
+	pas := []bgptype.PathAttribute{&igp, bgptype.NewAsPath(true, 64513), &nh}
+	// 1つのUpdateMessageで受信した経路のように、同じPathAttributeを共有する2つの経路
+	re1, re2 := NewRibEntry(nw1, pas...), NewRibEntry(nw2, pas...)
+	aro := NewAdjRibOut(NewRib())
+
+	want := ""
+	for i := 0; i < 2; i++ {
+		aro.Insert(re1)
+		aro.Insert(re2)
+		ums, err := aro.ToUpdateMessages(net.ParseIP("10.200.100.3").To4(), 64514, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 同じPathAttributeの経路は1つのUpdateMessageにまとめる
+		if len(ums) != 1 || len(ums[0].NetworkLayerReachabilityInformation) != 2 {
+			t.Fatalf("Want: 1 message with 2 routes, Got: %v", ums)
+		}
+		got := bgptype.PathAttributes(ums[0].PathAttributes).String()
+		if i == 0 {
+			want = got
+		} else if got != want {
+			t.Errorf("Want: %v, Got: %v", want, got)
+		}
+	}
+	for _, re := range []*RibEntry{re1, re2} {
+		if got := re.nextHop().String(); got != "10.0.100.3" {
+			t.Errorf("Want: 10.0.100.3, Got: %v", got)
+		}
+		if got := policy.AsPathString(re.GetPathAttributes()); got != "64513" {
+			t.Errorf("Want: 64513, Got: %v", got)
+		}
+	}
+}
+
+// GetPathAttributesで返したスライスを書き換えても、経路のPathAttributeは変わらないことを確認するテスト
+func TestGetPathAttributesReturnsCopy(t *testing.T) {
+	igp := bgptype.IGP
+	_, nw, _ := net.ParseCIDR("10.1.0.0/16")
+	re := NewRibEntry(nw, &igp)
+	pas := re.GetPathAttributes()
+	pas[0] = bgptype.NewAsPath(true, 64513)
+	re.AddPathAttributes(&bgptype.AtomicAggregate{})
+	if got := re.GetPathAttributes(); len(got) != 2 || got[0] != bgptype.PathAttribute(&igp) {
+		t.Errorf("Want: [%v ATOMIC_AGGREGATE], Got: %v", &igp, got)
+	}
+}
+
+// LocRibの経路をAS Pathの正規表現とプレフィックスで絞り込めることを確認するテスト
+func TestLocRibQuery(t *testing.T) {
+	lr := &LocRib{Rib: NewRib(), LocalASNum: 64512}
+	igp := bgptype.IGP
+	_, nw1, _ := net.ParseCIDR("10.1.0.0/16")
+	_, nw2, _ := net.ParseCIDR("10.1.2.0/24")
+	_, nw3, _ := net.ParseCIDR("10.2.0.0/16")
+	lr.Rib.Insert(NewRibEntry(nw1, &igp, bgptype.NewAsPath(true, 65001, 174)))
+	lr.Rib.Insert(NewRibEntry(nw2, &igp, bgptype.NewAsPath(true, 65001, 65010)))
+	lr.Rib.Insert(NewRibEntry(nw3, &igp, bgptype.NewAsPath(true, 65002, 174)))
+
+	tests := []struct {
+		name    string
+		filters []RouteFilter
+		want    int
+	}{
+		{"from 65001", []RouteFilter{MatchAsPath(policy.MustCompileAsPathRegexp("^65001_"))}, 2},
+		{"origin 174", []RouteFilter{MatchAsPath(policy.MustCompileAsPathRegexp("_174$"))}, 2},
+		{"exact prefix", []RouteFilter{MatchPrefix(nw1, false)}, 1},
+		{"longer prefixes", []RouteFilter{MatchPrefix(nw1, true)}, 2},
+		{
+			"from 65001 and origin 174",
+			[]RouteFilter{
+				MatchAsPath(policy.MustCompileAsPathRegexp("^65001_")),
+				MatchAsPath(policy.MustCompileAsPathRegexp("_174$")),
+			},
+			1,
+		},
+	}
+	for _, tt := range tests {
+		if got := len(lr.Query(tt.filters...)); got != tt.want {
+			t.Errorf("%s: Want: %d, Got: %d", tt.name, tt.want, got)
+		}
+	}
+}
+
+// ImportPolicyで拒否された経路がLocRibにインストールされないことを確認するテスト
+func TestLocRibInstallFromAdjRibInWithImportPolicy(t *testing.T) {
+	config, _ := ParseConfig("64512 127.0.0.1 65001 127.0.0.2 active")
+	config.ImportPolicy = &policy.Policy{
+		Statements: []*policy.Statement{
+			{
+				Conditions: []policy.Condition{
+					&policy.AsPathCondition{Regexp: policy.MustCompileAsPathRegexp("_174_")},
+				},
+				Action: policy.Reject,
+			},
+		},
+		DefaultAction: policy.Accept,
+	}
+	lr := &LocRib{Rib: NewRib(), LocalASNum: config.LocalAS}
+	ari := NewAdjRibIn(NewRib())
+	igp := bgptype.IGP
+	_, nw1, _ := net.ParseCIDR("10.1.0.0/16")
+	_, nw2, _ := net.ParseCIDR("10.2.0.0/16")
+	ari.Rib.Insert(NewRibEntry(nw1, &igp, bgptype.NewAsPath(true, 65001)))
+	ari.Rib.Insert(NewRibEntry(nw2, &igp, bgptype.NewAsPath(true, 65001, 174)))
+
+	lr.InstallFromAdjRibIn(ari, config)
+	rts := lr.Rib.Routes()
+	if len(rts) != 1 || rts[0].NwAddr.String() != nw1.String() {
+		t.Errorf("Want: [%v], Got: %v", nw1, rts)
+	}
+}
+
+// Ribがプレフィックスごとのentryと、変更されたentryを正しく保持することを確認するテスト
+func TestRibLookupByPrefix(t *testing.T) {
+	_, nw1, _ := net.ParseCIDR("10.1.0.0/16")
+	_, nw2, _ := net.ParseCIDR("10.2.0.0/16")
+	rib := NewRib()
+	re1, re2, re3 := NewRibEntry(nw1), NewRibEntry(nw1), NewRibEntry(nw2)
+	re1.PathID, re2.PathID = 1, 2
+	for _, re := range []*RibEntry{re1, re2, re3} {
+		rib.Insert(re)
+	}
+	if got := rib.LookupPath(nw1, 2); len(got) != 1 || got[0] != re2 {
+		t.Errorf("Want: [%v], Got: %v", re2, got)
+	}
+	rib.UpsateToAllUnchanged()
+	if rib.DoseContainNewRoute() {
+		t.Errorf("rib should not contain new route")
+	}
+	rib.Remove(re1)
+	rib.MarkChanged(re3)
+	if got := rib.Lookup(nw1); len(got) != 1 || got[0] != re2 {
+		t.Errorf("Want: [%v], Got: %v", re2, got)
+	}
+	if got := rib.NewRoutes(); len(got) != 1 || got[0] != re3 {
+		t.Errorf("Want: [%v], Got: %v", re3, got)
+	}
+	rib.Remove(re3)
+	if got := rib.Lookup(nw2); len(got) != 0 || rib.DoseContainNewRoute() {
+		t.Errorf("Want: [], Got: %v", got)
+	}
+}
+
+// AdjRibInが受信プレフィックス数の上限を超えた場合の動作を確認するテスト
+func TestAdjRibInMaxPrefix(t *testing.T) {
+	igp := bgptype.IGP
+	nh := bgptype.NextHop(net.ParseIP("10.0.0.1").To4())
+	pas := []bgptype.PathAttribute{&igp, bgptype.NewAsPath(true, 65001), &nh}
+	nws := []*net.IPNet{}
+	for _, s := range []string{"10.1.0.0/16", "10.2.0.0/16", "10.3.0.0/16"} {
+		_, nw, _ := net.ParseCIDR(s)
+		nws = append(nws, nw)
+	}
+	um, _ := packets.NewUpdateMessage(pas, nws, []*net.IPNet{})
+
+	// 上限を超えた場合はMaxPrefixExceededErrorを返す
+	config, _ := ParseConfig("64512 127.0.0.1 65001 127.0.0.2 active")
+	config.MaxPrefix = &MaxPrefixConfig{Limit: 2}
+	ari := NewAdjRibIn(NewRib())
+	err := ari.InstallFromUpdate(um, config)
+	if _, ok := err.(*MaxPrefixExceededError); !ok {
+		t.Errorf("Want: *MaxPrefixExceededError, Got: %v", err)
+	}
+
+	// DropOnlyの場合は上限を超えた経路だけを破棄する
+	config.MaxPrefix.DropOnly = true
+	ari = NewAdjRibIn(NewRib())
+	if err := ari.InstallFromUpdate(um, config); err != nil {
+		t.Errorf("Error: %v", err)
+	}
+	if ari.Rib.Len() != 2 {
+		t.Errorf("Want: 2, Got: %d", ari.Rib.Len())
+	}
+
+	// 同じプレフィックスの再受信や取り消しは上限に影響しない
+	config.MaxPrefix.DropOnly = false
+	ari = NewAdjRibIn(NewRib())
+	um2, _ := packets.NewUpdateMessage(pas, nws[:2], []*net.IPNet{})
+	for i := 0; i < 3; i++ {
+		if err := ari.InstallFromUpdate(um2, config); err != nil {
+			t.Errorf("Error: %v", err)
+		}
+	}
+	wd, _ := packets.NewUpdateMessage([]bgptype.PathAttribute{}, []*net.IPNet{}, nws[:1])
+	if err := ari.InstallFromUpdate(wd, config); err != nil {
+		t.Errorf("Error: %v", err)
+	}
+	um3, _ := packets.NewUpdateMessage(pas, nws[2:], []*net.IPNet{})
+	if err := ari.InstallFromUpdate(um3, config); err != nil {
+		t.Errorf("Error: %v", err)
+	}
+	if ari.Rib.Len() != 2 {
+		t.Errorf("Want: 2, Got: %d", ari.Rib.Len())
+	}
+}
+
+// ADD-PATHで受信した同じプレフィックスの経路は1つと数え、
+// プレフィックス数が警告のしきい値を下回ると再び警告できることを確認するテスト
+func TestAdjRibInMaxPrefixCountsPrefixes(t *testing.T) {
+	igp := bgptype.IGP
+	nh := bgptype.NextHop(net.ParseIP("10.0.0.1").To4())
+	pas := []bgptype.PathAttribute{&igp, bgptype.NewAsPath(true, 65001), &nh}
+	nws := []*net.IPNet{}
+	for _, s := range []string{"10.1.0.0/16", "10.2.0.0/16", "10.3.0.0/16"} {
+		_, nw, _ := net.ParseCIDR(s)
+		nws = append(nws, nw)
+	}
+	config, _ := ParseConfig("64512 127.0.0.1 65001 127.0.0.2 active")
+	config.MaxPrefix = &MaxPrefixConfig{Limit: 1}
+	ari := NewAdjRibIn(NewRib())
+	for _, id := range []uint32{1, 2} {
+		um, _ := packets.NewUpdateMessage(pas, nws[:1], []*net.IPNet{})
+		um.NLRIPathIDs = []uint32{id}
+		if err := ari.InstallFromUpdate(um, config); err != nil {
+			t.Errorf("Error: %v", err)
+		}
+	}
+	if ari.Rib.Len() != 2 || ari.Rib.PrefixLen() != 1 {
+		t.Errorf("Want: 2 paths, 1 prefix, Got: %d paths, %d prefixes", ari.Rib.Len(), ari.Rib.PrefixLen())
+	}
+
+	config.MaxPrefix = &MaxPrefixConfig{Limit: 4, WarningThreshold: 50}
+	ari = NewAdjRibIn(NewRib())
+	um, _ := packets.NewUpdateMessage(pas, nws[:2], []*net.IPNet{})
+	if err := ari.InstallFromUpdate(um, config); err != nil {
+		t.Errorf("Error: %v", err)
+	}
+	if !ari.maxPrefixWarned {
+		t.Errorf("Want: %v, Got: %v", true, ari.maxPrefixWarned)
+	}
+	wd, _ := packets.NewUpdateMessage([]bgptype.PathAttribute{}, []*net.IPNet{}, nws[:2])
+	if err := ari.InstallFromUpdate(wd, config); err != nil {
+		t.Errorf("Error: %v", err)
+	}
+	um, _ = packets.NewUpdateMessage(pas, nws[2:], []*net.IPNet{})
+	if err := ari.InstallFromUpdate(um, config); err != nil {
+		t.Errorf("Error: %v", err)
+	}
+	if ari.maxPrefixWarned {
+		t.Errorf("Want: %v, Got: %v", false, ari.maxPrefixWarned)
+	}
+}
+
+// 受信した経路がRPKIで検証され、検証結果が最適経路の選択に使われることを確認するテスト
+func TestLocRibSelectsBestPathWithRPKI(t *testing.T) {
+	roa := rpki.NewTable()
+	roa.Add(rpki.VRP{Prefix: netip.MustParsePrefix("10.1.0.0/16"), MaxLength: 24, ASN: 65010})
+	lr := &LocRib{Rib: NewRib(), LocalASNum: 64512, RPKI: roa}
+
+	igp := bgptype.IGP
+	nh := bgptype.NextHop(net.ParseIP("10.0.0.1").To4())
+	_, nw, _ := net.ParseCIDR("10.1.0.0/16")
+	install := func(remoteIP string, as ...bgptype.AutonomousSystemNumber) *AdjRibIn {
+		config, _ := ParseConfig("64512 127.0.0.1 " + fmt.Sprint(as[0]) + " " + remoteIP + " active")
+		ari := NewAdjRibIn(NewRib())
+		ari.Validator = roa
+		um, _ := packets.NewUpdateMessage(
+			[]bgptype.PathAttribute{&igp, bgptype.NewAsPath(true, as...), &nh},
+			[]*net.IPNet{nw},
+			[]*net.IPNet{},
+		)
+		if err := ari.InstallFromUpdate(um, config); err != nil {
+			t.Errorf("Error: %v", err)
+		}
+		lr.InstallFromAdjRibIn(ari, config)
+		return ari
+	}
+	// AS Pathは短いがRPKI Invalid
+	install("127.0.0.2", 65020)
+	// AS Pathは長いがRPKI Valid
+	install("127.0.0.3", 65001, 65002, 65010)
+
+	rts := lr.Rib.Routes()
+	if len(rts) != 1 {
+		t.Fatalf("Want: 1, Got: %d", len(rts))
+	}
+	if rts[0].Validation != rpki.Valid || rts[0].PeerAddr.String() != "127.0.0.3" {
+		t.Errorf("Want: valid route from 127.0.0.3, Got: %s route from %v",
+			rts[0].Validation.Show(), rts[0].PeerAddr)
+	}
+	if len(lr.Paths(nw)) != 2 {
+		t.Errorf("Want: 2, Got: %d", len(lr.Paths(nw)))
+	}
+}
+
+// VRPの更新後に経路を検証し直せることを確認するテスト
+func TestAdjRibInRevalidate(t *testing.T) {
+	roa := rpki.NewTable()
+	config, _ := ParseConfig("64512 127.0.0.1 65001 127.0.0.2 active")
+	ari := NewAdjRibIn(NewRib())
+	ari.Validator = roa
+	igp := bgptype.IGP
+	_, nw, _ := net.ParseCIDR("10.1.0.0/16")
+	um, _ := packets.NewUpdateMessage(
+		[]bgptype.PathAttribute{&igp, bgptype.NewAsPath(true, 65001)},
+		[]*net.IPNet{nw},
+		[]*net.IPNet{},
+	)
+	ari.InstallFromUpdate(um, config)
+	if ari.Revalidate(config) {
+		t.Errorf("validation state should not be changed")
+	}
+	roa.Add(rpki.VRP{Prefix: netip.MustParsePrefix("10.0.0.0/8"), MaxLength: 8, ASN: 65001})
+	if !ari.Revalidate(config) {
+		t.Errorf("validation state should be changed")
+	}
+	if v := ari.Rib.Routes()[0].Validation; v != rpki.Invalid {
+		t.Errorf("Want: %s, Got: %s", rpki.Invalid.Show(), v.Show())
+	}
+}
+
+// ADD-PATHでPath Identifierごとに経路を保持し、AdjRibOutから複数の経路を送信できることを確認するテスト
+func TestAddPathInstallAndAdvertise(t *testing.T) {
+	config, _ := ParseConfig("64512 127.0.0.1 65001 127.0.0.2 active")
+	lr := &LocRib{Rib: NewRib(), LocalASNum: config.LocalAS}
+	ari := NewAdjRibIn(NewRib())
+	igp := bgptype.IGP
+	nh := bgptype.NextHop(net.ParseIP("10.0.0.1").To4())
+	_, nw, _ := net.ParseCIDR("10.1.0.0/16")
+	for i, path := range [][]bgptype.AutonomousSystemNumber{
+		{65001, 65010, 65020},
+		{65001, 65020},
+		{65001, 65030, 65040, 65020},
+	} {
+		um, _ := packets.NewAddPathUpdateMessage(
+			[]bgptype.PathAttribute{&igp, bgptype.NewAsPath(true, path...), &nh},
+			[]*net.IPNet{nw},
+			[]uint32{uint32(i + 1)},
+			[]*net.IPNet{},
+			[]uint32{},
+		)
+		if err := ari.InstallFromUpdate(um, config); err != nil {
+			t.Errorf("Error: %v", err)
+		}
+	}
+	// Path Identifierが異なる経路はすべて保持し、同じものは置き換える
+	wd, _ := packets.NewAddPathUpdateMessage(
+		[]bgptype.PathAttribute{}, []*net.IPNet{}, []uint32{}, []*net.IPNet{nw}, []uint32{3},
+	)
+	ari.InstallFromUpdate(wd, config)
+	if ari.Rib.Len() != 2 {
+		t.Fatalf("Want: 2, Got: %d", ari.Rib.Len())
+	}
+	lr.InstallFromAdjRibIn(ari, config)
+	best := lr.Rib.Routes()
+	if len(best) != 1 || best[0].PathID != 2 {
+		t.Errorf("best path should be path id 2, Got: %v", best)
+	}
+
+	outConfig, _ := ParseConfig("64512 127.0.0.1 65002 127.0.0.3 active")
+	tests := []struct {
+		addPath *AddPathConfig
+		want    int
+	}{
+		{nil, 1},
+		{&AddPathConfig{Family: packets.IPv4Unicast, Send: true, SendMode: ADD_PATH_SEND_ALL}, 2},
+		{&AddPathConfig{Family: packets.IPv4Unicast, Send: true, SendMode: ADD_PATH_SEND_BEST_N, SendMax: 1}, 1},
+	}
+	for _, tt := range tests {
+		aro := NewAdjRibOut(NewRib())
+		aro.AddPath = tt.addPath
+		aro.InstallFromLocRib(lr, outConfig)
+		if aro.Rib.Len() != tt.want {
+			t.Errorf("Want: %d, Got: %d", tt.want, aro.Rib.Len())
+		}
+	}
+}
+
+// ADD-PATHで最適なN個の経路を送信する場合に、より良い経路を受信すると
+// N個から外れた経路のPath Identifierを取り消すことを確認するテスト
+func TestAddPathBestNWithdrawsDroppedPath(t *testing.T) {
+	config, _ := ParseConfig("64512 127.0.0.1 65001 127.0.0.2 active")
+	outConfig, _ := ParseConfig("64512 127.0.0.1 65002 127.0.0.3 active")
+	lr := &LocRib{Rib: NewRib(), LocalASNum: config.LocalAS}
+	ari := NewAdjRibIn(NewRib())
+	aro := NewAdjRibOut(NewRib())
+	aro.AddPath = &AddPathConfig{Family: packets.IPv4Unicast, Send: true, SendMode: ADD_PATH_SEND_BEST_N, SendMax: 1}
+	igp := bgptype.IGP
+	nh := bgptype.NextHop(net.ParseIP("10.0.0.1").To4())
+	_, nw, _ := net.ParseCIDR("10.1.0.0/16")
+	for i, path := range [][]bgptype.AutonomousSystemNumber{
+		{65001, 65010, 65020},
+		{65001, 65020},
+	} {
+		um, _ := packets.NewAddPathUpdateMessage(
+			[]bgptype.PathAttribute{&igp, bgptype.NewAsPath(true, path...), &nh},
+			[]*net.IPNet{nw},
+			[]uint32{uint32(i + 1)},
+			[]*net.IPNet{},
+			[]uint32{},
+		)
+		if err := ari.InstallFromUpdate(um, config); err != nil {
+			t.Fatal(err)
+		}
+		lr.InstallFromAdjRibIn(ari, config)
+		aro.InstallFromLocRib(lr, outConfig)
+	}
+	rts := aro.Rib.Routes()
+	if len(rts) != 1 || rts[0].PathID != 2 {
+		t.Fatalf("Want: path id 2, Got: %v", rts)
+	}
+	ums, err := aro.ToUpdateMessages(outConfig.LocalIP, outConfig.LocalAS, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wids []uint32
+	for _, um := range ums {
+		wids = append(wids, um.WithdrawnPathIDs...)
+	}
+	dropped := ari.Rib.LookupPath(nw, 1)[0]
+	if len(wids) != 1 || wids[0] != dropped.localPathID {
+		t.Errorf("Want: [%d], Got: %v", dropped.localPathID, wids)
+	}
+}
+
+// 最適経路の変更がFIBに書き込まれ、経路がなくなると削除されることを確認するテスト
+func TestLocRibWriteToFIB(t *testing.T) {
+	_, nw, _ := net.ParseCIDR("10.1.0.0/16")
+	f := fib.NewMemory()
+	lr := &LocRib{Rib: NewRib(), LocalASNum: 64512, FIB: f}
+	installPaths(t, lr, nw, map[string][]bgptype.AutonomousSystemNumber{
+		"10.0.0.1": {65001},
+	})
+	if err := lr.WriteToKernelRoutingTable(); err != nil {
+		t.Fatal(err)
+	}
+	want := &fib.Route{Dst: nw, NextHops: []net.IP{net.ParseIP("10.0.0.1").To4()}}
+	if rs := f.Installed(); len(rs) != 1 || !rs[0].Equal(want) {
+		t.Errorf("Want: %v, Got: %v", want.Show(), rs)
+	}
+
+	for _, p := range lr.Paths(nw) {
+		lr.removePath(p)
+	}
+	lr.updateBestPath(nw.String())
+	if err := lr.WriteToKernelRoutingTable(); err != nil {
+		t.Fatal(err)
+	}
+	if rs := f.Installed(); len(rs) != 0 {
+		t.Errorf("Want: 0, Got: %d", len(rs))
+	}
+}
+
+// 書き込みに1度だけ失敗するFIB
+type failingFIB struct {
+	*fib.Memory
+	failed bool
+}
+
+func (f *failingFIB) Apply(changes []*fib.Change) error {
+	if !f.failed {
+		f.failed = true
+		return errors.Join(&fib.ChangeError{Dst: changes[0].Dst, Err: errors.New("cannot replace route")})
+	}
+	return f.Memory.Apply(changes)
+}
+
+// FIBへの書き込みに失敗したプレフィックスは、次に書き込むときに書き込み直すことを確認するテスト
+func TestLocRibRetriesFailedFIBChanges(t *testing.T) {
+	_, nw, _ := net.ParseCIDR("10.1.0.0/16")
+	f := &failingFIB{Memory: fib.NewMemory()}
+	lr := &LocRib{Rib: NewRib(), LocalASNum: 64512, FIB: f}
+	installPaths(t, lr, nw, map[string][]bgptype.AutonomousSystemNumber{
+		"10.0.0.1": {65001},
+	})
+	if err := lr.WriteToKernelRoutingTable(); err == nil {
+		t.Fatalf("Want: error, Got: nil")
+	}
+	if !lr.HasFIBChanges() {
+		t.Fatalf("Want: pending changes, Got: none")
+	}
+	if err := lr.WriteToKernelRoutingTable(); err != nil {
+		t.Fatal(err)
+	}
+	if rs := f.Installed(); len(rs) != 1 || rs[0].Dst.String() != nw.String() {
+		t.Errorf("Want: [%v], Got: %v", nw, rs)
+	}
+	if lr.HasFIBChanges() {
+		t.Errorf("Want: no pending changes, Got: pending")
+	}
+}
+
+// networkステートメントの設定に応じて、設定されたプレフィックスそのものを広告することを確認するテスト
+func TestLocRibOriginateNetworks(t *testing.T) {
+	_, kernel, _ := net.ParseCIDR("10.0.0.0/8")
+	_, exact, _ := net.ParseCIDR("10.100.220.0/24")
+	_, narrow, _ := net.ParseCIDR("10.1.0.0/16")
+	_, uncond, _ := net.ParseCIDR("192.168.0.0/16")
+	f := fib.NewMemory(kernel, exact)
+	config, _ := ParseConfig("64513 10.200.100.3 64512 10.200.100.2 passive")
+	config.Networks = []*NetworkConfig{
+		{Prefix: exact, Mode: NETWORK_EXACT_MATCH},
+		// 10.0.0.0/8に含まれるが、完全一致するルートはないため広告しない
+		{Prefix: narrow, Mode: NETWORK_EXACT_MATCH},
+		{Prefix: uncond, Mode: NETWORK_UNCONDITIONAL},
+	}
+	lr, err := NewLocRib(config, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{exact.String(), uncond.String()}
+	if lr.Rib.Len() != len(want) {
+		t.Errorf("Want: %v, Got: %d routes", want, lr.Rib.Len())
+	}
+	for _, w := range want {
+		_, nw, _ := net.ParseCIDR(w)
+		if len(lr.Rib.Lookup(nw)) == 0 {
+			t.Errorf("Want: %v, Got: nil", w)
+		}
+	}
+	if len(lr.Rib.Lookup(kernel)) != 0 {
+		t.Errorf("%v should not be originated", kernel)
+	}
+}
+
+// ルーティングテーブルが変わると、exact-matchのnetworkステートメントと再配布の経路を作り直すことを確認するテスト
+func TestLocRibWatchRoutingTable(t *testing.T) {
+	_, exact, _ := net.ParseCIDR("10.100.220.0/24")
+	_, static, _ := net.ParseCIDR("172.16.1.0/24")
+	f := fib.NewMemory()
+	config, _ := ParseConfig("64513 10.200.100.3 64512 10.200.100.2 passive")
+	config.Networks = []*NetworkConfig{{Prefix: exact, Mode: NETWORK_EXACT_MATCH}}
+	config.Redistribute = &RedistributeConfig{Sources: []fib.RouteSource{fib.SOURCE_STATIC}}
+	lr, err := NewLocRib(config, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lr.Rib.Len() != 0 {
+		t.Fatalf("Want: 0, Got: %d", lr.Rib.Len())
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go lr.WatchRoutingTable(ctx)
+	// Watchが開始されるまで待つ
+	time.Sleep(10 * time.Millisecond)
+
+	f.AddTableRoute(&fib.TableRoute{Dst: exact, Source: fib.SOURCE_CONNECTED})
+	f.AddTableRoute(&fib.TableRoute{Dst: static, Source: fib.SOURCE_STATIC})
+	waitFor(t, "originated routes", func() bool { return len(lr.Prefixes()) == 2 })
+
+	f.RemoveTableRoute(static)
+	waitFor(t, "withdrawn route", func() bool { return len(lr.Prefixes()) == 1 })
+	if len(lr.Rib.Lookup(exact)) == 0 {
+		t.Errorf("Want: %v, Got: nil", exact)
+	}
+}
+
+// ルーティングテーブルのルートを生成元とPolicyで絞り込んで再配布することを確認するテスト
+func TestLocRibRedistribute(t *testing.T) {
+	_, connected, _ := net.ParseCIDR("10.100.220.0/24")
+	_, static1, _ := net.ParseCIDR("172.16.1.0/24")
+	_, static2, _ := net.ParseCIDR("172.17.0.0/16")
+	_, kernel, _ := net.ParseCIDR("192.168.0.0/16")
+	_, allowed, _ := net.ParseCIDR("172.16.0.0/12")
+	_, denied, _ := net.ParseCIDR("172.17.0.0/16")
+	f := fib.NewMemory(connected)
+	f.AddTableRoute(&fib.TableRoute{Dst: static1, Source: fib.SOURCE_STATIC})
+	f.AddTableRoute(&fib.TableRoute{Dst: static2, Source: fib.SOURCE_STATIC})
+	f.AddTableRoute(&fib.TableRoute{Dst: kernel, Source: fib.SOURCE_KERNEL})
+	config, _ := ParseConfig("64513 10.200.100.3 64512 10.200.100.2 passive")
+	config.Redistribute = &RedistributeConfig{
+		Sources: []fib.RouteSource{fib.SOURCE_CONNECTED, fib.SOURCE_STATIC},
+		Policy: &policy.Policy{
+			Statements: []*policy.Statement{
+				{
+					Conditions: []policy.Condition{&policy.PrefixCondition{Prefixes: []*net.IPNet{denied}}},
+					Action:     policy.Reject,
+				},
+				{
+					Conditions: []policy.Condition{&policy.PrefixCondition{Prefixes: []*net.IPNet{allowed, connected}, OrLonger: true}},
+					Action:     policy.Accept,
+				},
+			},
+			DefaultAction: policy.Reject,
+		},
+	}
+	lr, err := NewLocRib(config, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, nw := range []*net.IPNet{connected, static1} {
+		if len(lr.Rib.Lookup(nw)) == 0 {
+			t.Errorf("Want: %v, Got: nil", nw)
+		}
+	}
+	for _, nw := range []*net.IPNet{static2, kernel} {
+		if len(lr.Rib.Lookup(nw)) != 0 {
+			t.Errorf("%v should not be redistributed", nw)
+		}
+	}
+}
+
+// 設定を読み込み直したときに、自身で生成する経路の追加と取り消しが
+// LocRibとAdjRibOutに反映されることを確認するテスト
+func TestLocRibReconfigure(t *testing.T) {
+	_, nw1, _ := net.ParseCIDR("10.100.210.0/24")
+	_, nw2, _ := net.ParseCIDR("10.100.220.0/24")
+	_, nw3, _ := net.ParseCIDR("10.100.230.0/24")
+	config, _ := ParseConfig("64513 10.200.100.3 64512 10.200.100.2 passive")
+	config.Networks = []*NetworkConfig{
+		{Prefix: nw1, Mode: NETWORK_UNCONDITIONAL},
+		{Prefix: nw2, Mode: NETWORK_UNCONDITIONAL},
+	}
+	lr, err := NewLocRib(config, fib.NewMemory())
+	if err != nil {
+		t.Fatal(err)
+	}
+	aro := NewAdjRibOut(NewRib())
+	aro.InstallFromLocRib(lr, config)
+	kept := lr.Rib.Lookup(nw2)[0]
+
+	newConfig, _ := ParseConfig("64513 10.200.100.3 64512 10.200.100.2 passive")
+	newConfig.Networks = []*NetworkConfig{
+		{Prefix: nw2, Mode: NETWORK_UNCONDITIONAL},
+		{Prefix: nw3, Mode: NETWORK_UNCONDITIONAL},
+	}
+	if err := lr.Reconfigure(newConfig); err != nil {
+		t.Fatal(err)
+	}
+	if len(lr.Rib.Lookup(nw1)) != 0 {
+		t.Errorf("%v should be withdrawn", nw1)
+	}
+	if len(lr.Rib.Lookup(nw3)) != 1 {
+		t.Errorf("Want: %v, Got: nil", nw3)
+	}
+	// 設定が変わらない経路はそのまま保持する
+	if rts := lr.Rib.Lookup(nw2); len(rts) != 1 || rts[0] != kept {
+		t.Errorf("%v should be kept", nw2)
+	}
+
+	aro.ToUpdateMessages(config.LocalIP, config.LocalAS, false)
+	aro.InstallFromLocRib(lr, newConfig)
+	if aro.Rib.Len() != 2 || !aro.HasChanges() {
+		t.Fatalf("Want: 2 routes and changes, Got: %d, %v", aro.Rib.Len(), aro.changes)
+	}
+	ums, err := aro.ToUpdateMessages(newConfig.LocalIP, newConfig.LocalAS, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var withdrawn []*net.IPNet
+	for _, um := range ums {
+		withdrawn = append(withdrawn, um.WithdrawnRoutes...)
+	}
+	if len(withdrawn) != 1 || withdrawn[0].String() != nw1.String() {
+		t.Errorf("Want: [%v], Got: %v", nw1, withdrawn)
+	}
+	// 変わっていない経路は広告し直さない
+	if len(ums) != 2 || len(ums[1].NetworkLayerReachabilityInformation) != 1 ||
+		ums[1].NetworkLayerReachabilityInformation[0].String() != nw3.String() {
+		t.Errorf("Want: withdrawal of %v and announcement of %v, Got: %v", nw1, nw3, ums)
+	}
+	if aro.HasChanges() {
+		t.Errorf("changes should be cleared after sending")
+	}
+}
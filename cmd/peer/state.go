@@ -1,5 +1,7 @@
 package peer
 
+import "fmt"
+
 type State int
 
 const (
@@ -9,3 +11,20 @@ const (
 	OPEN_CONFIRM
 	ESTABLISHED
 )
+
+func (s State) Show() string {
+	switch s {
+	case IDLE:
+		return "Idle"
+	case CONNECT:
+		return "Connect"
+	case OPEN_SENT:
+		return "OpenSent"
+	case OPEN_CONFIRM:
+		return "OpenConfirm"
+	case ESTABLISHED:
+		return "Established"
+	default:
+		return fmt.Sprintf("%d", s)
+	}
+}
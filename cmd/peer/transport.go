@@ -0,0 +1,331 @@
+package peer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Peerとの通信路
+// 実際のTCPのほかに、テストではメモリ上の通信路を使い、
+// 特権や実際のアドレスがなくても2つのPeerのセッションを張れるようにする。
+type Transport interface {
+	// ActiveモードでPeerに接続する。ctxがキャンセルされた場合は接続をやめる
+	Dial(ctx context.Context, local, remote *net.TCPAddr) (net.Conn, error)
+	// PassiveモードでPeerからの接続を待つ
+	Listen(local *net.TCPAddr) (net.Listener, error)
+}
+
+// TCPでPeerと通信する
+type TCPTransport struct{}
+
+func (TCPTransport) Dial(ctx context.Context, local, remote *net.TCPAddr) (net.Conn, error) {
+	d := net.Dialer{LocalAddr: local}
+	c, err := d.DialContext(ctx, "tcp", remote.String())
+	if err != nil {
+		return nil, err
+	}
+	conn := c.(*net.TCPConn)
+	if err := conn.SetWriteBuffer(1500); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (TCPTransport) Listen(local *net.TCPAddr) (net.Listener, error) {
+	l, err := net.ListenTCP("tcp", local)
+	if err != nil {
+		return nil, err
+	}
+	return tcpListener{l}, nil
+}
+
+// Acceptしたコネクションの送信バッファの大きさをDialと揃える
+type tcpListener struct {
+	*net.TCPListener
+}
+
+func (l tcpListener) Accept() (net.Conn, error) {
+	conn, err := l.AcceptTCP()
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.SetWriteBuffer(1500); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// メモリ上でPeer同士を接続する通信路
+// 同じMemoryNetworkでListenしているアドレスにDialすると、メモリ上のバッファでつながったコネクションを返す。
+// 読み込みのタイムアウトはClockの時刻で判断するため、FakeClockと組み合わせるとタイマーも実際に待たずに試せる。
+type MemoryNetwork struct {
+	Clock Clock
+
+	mu        sync.Mutex
+	listeners map[string]*memoryListener
+	// 送信元のポートを指定せずにDialした場合に割り当てるポート
+	nextPort int
+}
+
+// 割り当てる送信元のポートの最初の値
+const MEMORY_EPHEMERAL_PORT = 49152
+
+func NewMemoryNetwork(clock Clock) *MemoryNetwork {
+	if clock == nil {
+		clock = systemClock{}
+	}
+	return &MemoryNetwork{
+		Clock:     clock,
+		listeners: make(map[string]*memoryListener),
+		nextPort:  MEMORY_EPHEMERAL_PORT,
+	}
+}
+
+// Listenしていないアドレスに接続しようとした場合のエラー
+type memoryRefusedError struct {
+	addr *net.TCPAddr
+}
+
+func (e *memoryRefusedError) Error() string {
+	return fmt.Sprintf("connection to %s is refused", e.addr)
+}
+
+func (n *MemoryNetwork) Dial(ctx context.Context, local, remote *net.TCPAddr) (net.Conn, error) {
+	n.mu.Lock()
+	l, ok := n.listeners[remote.String()]
+	laddr := &net.TCPAddr{IP: local.IP, Port: local.Port}
+	if laddr.Port == 0 {
+		laddr.Port = n.nextPort
+		n.nextPort++
+	}
+	n.mu.Unlock()
+	if !ok {
+		return nil, &memoryRefusedError{remote}
+	}
+	raddr := &net.TCPAddr{IP: remote.IP, Port: remote.Port}
+	a, b := newMemoryPipe(n.Clock, laddr, raddr)
+	select {
+	case l.conns <- b:
+		return a, nil
+	case <-l.done:
+		return nil, &memoryRefusedError{remote}
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (n *MemoryNetwork) Listen(local *net.TCPAddr) (net.Listener, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	key := local.String()
+	if _, ok := n.listeners[key]; ok {
+		return nil, fmt.Errorf("address %s is already in use", key)
+	}
+	l := &memoryListener{
+		network: n,
+		addr:    &net.TCPAddr{IP: local.IP, Port: local.Port},
+		conns:   make(chan net.Conn),
+		done:    make(chan struct{}),
+	}
+	n.listeners[key] = l
+	return l, nil
+}
+
+// addrでListenしている場合はtrueを返す
+func (n *MemoryNetwork) Listening(addr *net.TCPAddr) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	_, ok := n.listeners[addr.String()]
+	return ok
+}
+
+type memoryListener struct {
+	network *MemoryNetwork
+	addr    *net.TCPAddr
+	conns   chan net.Conn
+	done    chan struct{}
+	once    sync.Once
+}
+
+func (l *memoryListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *memoryListener) Close() error {
+	l.once.Do(func() {
+		l.network.mu.Lock()
+		delete(l.network.listeners, l.addr.String())
+		l.network.mu.Unlock()
+		close(l.done)
+	})
+	return nil
+}
+
+func (l *memoryListener) Addr() net.Addr {
+	return l.addr
+}
+
+// 一方向のデータを保持するバッファ
+// net.Pipeと違い、Writeは相手がReadするまで待たない。
+// 両方のPeerが同時にMessageを送信しても止まらないようにするため。
+type memoryBuffer struct {
+	mu     sync.Mutex
+	data   []byte
+	closed bool
+	// データが追加されたか閉じられたときにcloseし、作り直す
+	changed chan struct{}
+}
+
+func newMemoryBuffer() *memoryBuffer {
+	return &memoryBuffer{changed: make(chan struct{})}
+}
+
+func (b *memoryBuffer) notify() {
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+func (b *memoryBuffer) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.closed {
+		b.closed = true
+		b.notify()
+	}
+}
+
+// メモリ上のバッファでつながったコネクションの一端
+type memoryConn struct {
+	clock         Clock
+	laddr, raddr  *net.TCPAddr
+	in, out       *memoryBuffer
+	mu            sync.Mutex
+	closed        bool
+	deadline      chan struct{}
+	deadlineTimer Timer
+}
+
+// 互いに接続したコネクションの組を返す
+func newMemoryPipe(clock Clock, a, b *net.TCPAddr) (*memoryConn, *memoryConn) {
+	ab, ba := newMemoryBuffer(), newMemoryBuffer()
+	return &memoryConn{clock: clock, laddr: a, raddr: b, in: ba, out: ab, deadline: make(chan struct{})},
+		&memoryConn{clock: clock, laddr: b, raddr: a, in: ab, out: ba, deadline: make(chan struct{})}
+}
+
+// バッファにデータがあれば読み込む
+// 相手が閉じ、バッファが空の場合はio.EOFを返す
+// 読み込みのデッドラインを過ぎた場合はos.ErrDeadlineExceededを返す
+func (c *memoryConn) Read(p []byte) (int, error) {
+	for {
+		c.mu.Lock()
+		closed, deadline := c.closed, c.deadline
+		c.mu.Unlock()
+		if closed {
+			return 0, net.ErrClosed
+		}
+		c.in.mu.Lock()
+		if len(c.in.data) > 0 {
+			n := copy(p, c.in.data)
+			c.in.data = c.in.data[n:]
+			c.in.mu.Unlock()
+			return n, nil
+		}
+		if c.in.closed {
+			c.in.mu.Unlock()
+			return 0, io.EOF
+		}
+		changed := c.in.changed
+		c.in.mu.Unlock()
+		select {
+		case <-changed:
+		case <-deadline:
+			return 0, os.ErrDeadlineExceeded
+		}
+	}
+}
+
+func (c *memoryConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	closed := c.closed
+	c.mu.Unlock()
+	if closed {
+		return 0, net.ErrClosed
+	}
+	c.out.mu.Lock()
+	defer c.out.mu.Unlock()
+	if c.out.closed {
+		return 0, io.ErrClosedPipe
+	}
+	c.out.data = append(c.out.data, p...)
+	c.out.notify()
+	return len(p), nil
+}
+
+// 両方向のバッファを閉じる
+// 相手は残りのデータを読み込んだ後にio.EOFを受け取る
+func (c *memoryConn) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	if c.deadlineTimer != nil {
+		c.deadlineTimer.Stop()
+	}
+	c.mu.Unlock()
+	c.in.close()
+	c.out.close()
+	return nil
+}
+
+func (c *memoryConn) LocalAddr() net.Addr {
+	return c.laddr
+}
+
+func (c *memoryConn) RemoteAddr() net.Addr {
+	return c.raddr
+}
+
+func (c *memoryConn) SetDeadline(t time.Time) error {
+	return c.SetReadDeadline(t)
+}
+
+// tをClockの時刻として、Readのデッドラインを設定する
+func (c *memoryConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.deadlineTimer != nil {
+		c.deadlineTimer.Stop()
+		c.deadlineTimer = nil
+	}
+	deadline := make(chan struct{})
+	c.deadline = deadline
+	if t.IsZero() {
+		return nil
+	}
+	d := t.Sub(c.clock.Now())
+	if d <= 0 {
+		close(deadline)
+		return nil
+	}
+	c.deadlineTimer = c.clock.AfterFunc(d, func() { close(deadline) })
+	return nil
+}
+
+// Writeはバッファに追加するだけで待たないため、デッドラインは使わない
+func (c *memoryConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
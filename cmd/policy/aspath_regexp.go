@@ -0,0 +1,145 @@
+package policy
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/SotaUeda/gobgp/bgptype"
+)
+
+// AS Pathに対する正規表現
+//
+// Cisco / Juniperで一般的な記法に従い、`_`はASの区切りを表すトークンとして扱う。
+// `_`は次のいずれかにマッチする。
+//
+//	文字列の先頭, 文字列の末尾, 空白, カンマ, `{`, `}`
+//
+// AS Pathは次のような文字列として評価する。
+// AsSequenceは空白区切り、AsSetは`{}`で囲んだカンマ区切り(昇順)で表す。
+//
+//	AsSequence(65001, 65002) + AsSet(65004, 65003) => "65001 65002 {65003,65004}"
+//
+// 例:
+//
+//	^65001_   => 65001から受信した経路
+//	_174$     => AS174が生成した経路
+//	_65003_   => AS_SETを含め、65003を経由した経路
+//	^$        => 自ASで生成した経路
+type AsPathRegexp struct {
+	expr string
+	re   *regexp.Regexp
+}
+
+// `_`を置き換える正規表現
+const asPathDelimiter = `(?:^|[ ,{}]|$)`
+
+// 正規表現をコンパイルする。
+// 経路ごとにコンパイルするのを避けるため、Policyの作成時に1度だけ呼び出す。
+func CompileAsPathRegexp(expr string) (*AsPathRegexp, error) {
+	re, err := regexp.Compile(translateAsPathRegexp(expr))
+	if err != nil {
+		return nil, fmt.Errorf("cannot compile as-path regexp %q: %w", expr, err)
+	}
+	return &AsPathRegexp{expr: expr, re: re}, nil
+}
+
+// CompileAsPathRegexpと同じだが、コンパイルに失敗した場合はpanicする。
+// テストや固定の正規表現の初期化に使用する。
+func MustCompileAsPathRegexp(expr string) *AsPathRegexp {
+	r, err := CompileAsPathRegexp(expr)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// `_`をASの区切りにマッチする表現に置き換える。
+// `\_`のようにエスケープされたものや、`[]`の中にあるものは置き換えない。
+func translateAsPathRegexp(expr string) string {
+	var sb strings.Builder
+	inClass := false
+	for i := 0; i < len(expr); i++ {
+		c := expr[i]
+		switch {
+		case c == '\\' && i+1 < len(expr):
+			sb.WriteByte(c)
+			sb.WriteByte(expr[i+1])
+			i++
+		case c == '[':
+			inClass = true
+			sb.WriteByte(c)
+		case c == ']':
+			inClass = false
+			sb.WriteByte(c)
+		case c == '_' && !inClass:
+			sb.WriteString(asPathDelimiter)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+func (r *AsPathRegexp) String() string {
+	return r.expr
+}
+
+// PathAttributeに含まれるAS Pathが正規表現にマッチするかを返す。
+// AS Pathが含まれていない場合は空のAS Pathとして評価する。
+func (r *AsPathRegexp) Match(pas []bgptype.PathAttribute) bool {
+	return r.re.MatchString(AsPathString(pas))
+}
+
+// AS Pathが正規表現にマッチするかを返す。
+func (r *AsPathRegexp) MatchAsPath(ap bgptype.AsPath) bool {
+	var sb strings.Builder
+	writeAsPath(&sb, ap)
+	return r.re.MatchString(sb.String())
+}
+
+// PathAttributeに含まれるAS Pathを正規表現で評価する文字列に変換する。
+// AS Pathのセグメントが複数ある場合は、PathAttributeの順に空白で連結する。
+func AsPathString(pas []bgptype.PathAttribute) string {
+	var sb strings.Builder
+	for _, pa := range pas {
+		ap, ok := pa.(bgptype.AsPath)
+		if !ok {
+			continue
+		}
+		if sb.Len() > 0 && len(ap.Get()) > 0 {
+			sb.WriteByte(' ')
+		}
+		writeAsPath(&sb, ap)
+	}
+	return sb.String()
+}
+
+func writeAsPath(sb *strings.Builder, ap bgptype.AsPath) {
+	switch t := ap.(type) {
+	case *bgptype.AsSet:
+		ases := t.Get()
+		if len(ases) == 0 {
+			return
+		}
+		// mapの順序は不定なので、結果が安定するように並べ替える
+		sort.Slice(ases, func(i, j int) bool { return ases[i] < ases[j] })
+		sb.WriteByte('{')
+		for i, as := range ases {
+			if i > 0 {
+				sb.WriteByte(',')
+			}
+			sb.WriteString(strconv.FormatUint(uint64(as), 10))
+		}
+		sb.WriteByte('}')
+	default:
+		for i, as := range ap.Get() {
+			if i > 0 {
+				sb.WriteByte(' ')
+			}
+			sb.WriteString(strconv.FormatUint(uint64(as), 10))
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package policy
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/SotaUeda/gobgp/bgptype"
+	"github.com/SotaUeda/gobgp/rpki"
+)
+
+// Policyで評価する経路
+// RibEntryをそのまま扱うとpeerパッケージとの循環参照になるため、
+// 評価に必要な情報だけを持たせる。
+type Path struct {
+	Prefix         *net.IPNet
+	PathAttributes []bgptype.PathAttribute
+	// RPKIによる経路の検証結果
+	Validation rpki.ValidationState
+}
+
+// 経路に対する処理
+type Action int
+
+const (
+	Accept Action = iota
+	Reject
+)
+
+func (a Action) Show() string {
+	switch a {
+	case Accept:
+		return "accept"
+	case Reject:
+		return "reject"
+	default:
+		return fmt.Sprintf("%d", a)
+	}
+}
+
+func ParseAction(s string) (Action, error) {
+	switch s {
+	case "accept":
+		return Accept, nil
+	case "reject":
+		return Reject, nil
+	default:
+		return 0, fmt.Errorf("string is not action: %s", s)
+	}
+}
+
+// Statementのマッチ条件
+type Condition interface {
+	Match(p *Path) bool
+}
+
+// AS Pathが正規表現にマッチする経路にマッチする
+type AsPathCondition struct {
+	Regexp *AsPathRegexp
+}
+
+func NewAsPathCondition(expr string) (*AsPathCondition, error) {
+	re, err := CompileAsPathRegexp(expr)
+	if err != nil {
+		return nil, err
+	}
+	return &AsPathCondition{Regexp: re}, nil
+}
+
+func (c *AsPathCondition) Match(p *Path) bool {
+	return c.Regexp.Match(p.PathAttributes)
+}
+
+// RPKIの検証結果が一致する経路にマッチする
+//
+// 例: RPKI Invalidの経路を拒否する
+//
+//	&Statement{Conditions: []Condition{&ValidationCondition{rpki.Invalid}}, Action: Reject}
+type ValidationCondition struct {
+	State rpki.ValidationState
+}
+
+func (c *ValidationCondition) Match(p *Path) bool {
+	return p.Validation == c.State
+}
+
+// プレフィックスがPrefixesのいずれかと一致する経路にマッチする
+// OrLongerがtrueの場合はPrefixesに含まれるより長いプレフィックスにもマッチする
+type PrefixCondition struct {
+	Prefixes []*net.IPNet
+	OrLonger bool
+}
+
+func (c *PrefixCondition) Match(p *Path) bool {
+	ones, _ := p.Prefix.Mask.Size()
+	for _, nw := range c.Prefixes {
+		nwOnes, _ := nw.Mask.Size()
+		if ones == nwOnes && nw.IP.Equal(p.Prefix.IP) {
+			return true
+		}
+		if c.OrLonger && ones >= nwOnes && nw.Contains(p.Prefix.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// Conditionがすべてマッチした場合にActionを適用する
+type Statement struct {
+	Name       string
+	Conditions []Condition
+	Action     Action
+}
+
+func (s *Statement) Match(p *Path) bool {
+	for _, c := range s.Conditions {
+		if !c.Match(p) {
+			return false
+		}
+	}
+	return true
+}
+
+// Statementを先頭から順に評価し、最初にマッチしたStatementのActionを適用する。
+// どのStatementにもマッチしない場合はDefaultActionを適用する。
+type Policy struct {
+	Name          string
+	Statements    []*Statement
+	DefaultAction Action
+}
+
+func (pol *Policy) Evaluate(p *Path) Action {
+	for _, s := range pol.Statements {
+		if s.Match(p) {
+			return s.Action
+		}
+	}
+	return pol.DefaultAction
+}
+
+// Policyが設定されていない(nil)場合はすべての経路を受け入れる
+func (pol *Policy) Accept(p *Path) bool {
+	if pol == nil {
+		return true
+	}
+	return pol.Evaluate(p) == Accept
+}
@@ -0,0 +1,138 @@
+package policy
+
+import (
+	"net"
+	"testing"
+
+	"github.com/SotaUeda/gobgp/bgptype"
+	"github.com/SotaUeda/gobgp/rpki"
+)
+
+// AS Pathの正規表現が`_`をASの区切りとして扱うことを確認するテスト
+func TestAsPathRegexpMatch(t *testing.T) {
+	set := bgptype.AsSet{}
+	set.Add(65004)
+	set.Add(65003)
+	tests := []struct {
+		expr string
+		pas  []bgptype.PathAttribute
+		want bool
+	}{
+		{"^65001_", []bgptype.PathAttribute{bgptype.NewAsPath(true, 65001, 65002)}, true},
+		{"^6500_", []bgptype.PathAttribute{bgptype.NewAsPath(true, 65001, 65002)}, false},
+		{"^65001_", []bgptype.PathAttribute{bgptype.NewAsPath(true, 65002, 65001)}, false},
+		{"_174$", []bgptype.PathAttribute{bgptype.NewAsPath(true, 65001, 174)}, true},
+		{"_174$", []bgptype.PathAttribute{bgptype.NewAsPath(true, 174)}, true},
+		{"_174$", []bgptype.PathAttribute{bgptype.NewAsPath(true, 1174)}, false},
+		{"_65003_", []bgptype.PathAttribute{bgptype.NewAsPath(true, 65001), &set}, true},
+		{"_65004_", []bgptype.PathAttribute{bgptype.NewAsPath(true, 65001), &set}, true},
+		{`^65001 \{65003,65004\}$`, []bgptype.PathAttribute{bgptype.NewAsPath(true, 65001), &set}, true},
+		{"^$", []bgptype.PathAttribute{&bgptype.AsSequence{}}, true},
+		{"^$", []bgptype.PathAttribute{}, true},
+		{"_65001_65002_", []bgptype.PathAttribute{bgptype.NewAsPath(true, 1, 65001, 65002, 3)}, true},
+	}
+	for _, tt := range tests {
+		re, err := CompileAsPathRegexp(tt.expr)
+		if err != nil {
+			t.Errorf("Error: %v", err)
+			continue
+		}
+		if got := re.Match(tt.pas); got != tt.want {
+			t.Errorf("expr: %s, path: %q, Want: %v, Got: %v",
+				tt.expr, AsPathString(tt.pas), tt.want, got)
+		}
+	}
+}
+
+func TestCompileAsPathRegexpError(t *testing.T) {
+	if _, err := CompileAsPathRegexp("^(65001"); err == nil {
+		t.Errorf("Want: error, Got: nil")
+	}
+}
+
+// Policyが最初にマッチしたStatementのActionを返すことを確認するテスト
+func TestPolicyEvaluate(t *testing.T) {
+	pol := &Policy{
+		Name: "customer-in",
+		Statements: []*Statement{
+			{
+				Name:       "reject-transit",
+				Conditions: []Condition{&AsPathCondition{MustCompileAsPathRegexp("_174_")}},
+				Action:     Reject,
+			},
+			{
+				Name:       "accept-customer",
+				Conditions: []Condition{&AsPathCondition{MustCompileAsPathRegexp("^65001_")}},
+				Action:     Accept,
+			},
+		},
+		DefaultAction: Reject,
+	}
+	_, nw, _ := net.ParseCIDR("10.1.0.0/16")
+	tests := []struct {
+		path []bgptype.AutonomousSystemNumber
+		want Action
+	}{
+		{[]bgptype.AutonomousSystemNumber{65001}, Accept},
+		{[]bgptype.AutonomousSystemNumber{65001, 174}, Reject},
+		{[]bgptype.AutonomousSystemNumber{65002}, Reject},
+	}
+	for _, tt := range tests {
+		p := &Path{
+			Prefix:         nw,
+			PathAttributes: []bgptype.PathAttribute{bgptype.NewAsPath(true, tt.path...)},
+		}
+		if got := pol.Evaluate(p); got != tt.want {
+			t.Errorf("path: %v, Want: %v, Got: %v", tt.path, tt.want.Show(), got.Show())
+		}
+	}
+	var nilPol *Policy
+	if !nilPol.Accept(&Path{Prefix: nw}) {
+		t.Errorf("nil policy should accept all routes")
+	}
+}
+
+// RPKIの検証結果でInvalidな経路を拒否できることを確認するテスト
+func TestPolicyRejectRPKIInvalid(t *testing.T) {
+	pol := &Policy{
+		Statements: []*Statement{
+			{
+				Conditions: []Condition{&ValidationCondition{rpki.Invalid}},
+				Action:     Reject,
+			},
+		},
+		DefaultAction: Accept,
+	}
+	_, nw, _ := net.ParseCIDR("10.1.0.0/16")
+	for _, v := range []rpki.ValidationState{rpki.Valid, rpki.NotFound} {
+		if !pol.Accept(&Path{Prefix: nw, Validation: v}) {
+			t.Errorf("%s route should be accepted", v.Show())
+		}
+	}
+	if pol.Accept(&Path{Prefix: nw, Validation: rpki.Invalid}) {
+		t.Errorf("invalid route should be rejected")
+	}
+}
+
+// プレフィックスの一致と、より長いプレフィックスへのマッチを確認するテスト
+func TestPrefixConditionMatch(t *testing.T) {
+	_, nw, _ := net.ParseCIDR("10.0.0.0/8")
+	tests := []struct {
+		prefix   string
+		orLonger bool
+		want     bool
+	}{
+		{"10.0.0.0/8", false, true},
+		{"10.1.0.0/16", false, false},
+		{"10.1.0.0/16", true, true},
+		{"0.0.0.0/0", true, false},
+		{"192.168.0.0/16", true, false},
+	}
+	for _, tt := range tests {
+		_, p, _ := net.ParseCIDR(tt.prefix)
+		c := &PrefixCondition{Prefixes: []*net.IPNet{nw}, OrLonger: tt.orLonger}
+		if got := c.Match(&Path{Prefix: p}); got != tt.want {
+			t.Errorf("prefix: %v, orLonger: %v, Want: %v, Got: %v", tt.prefix, tt.orLonger, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,300 @@
+package rpki
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/SotaUeda/gobgp/logging"
+)
+
+var log = logging.Logger(logging.RPKI)
+
+// RFC8210 6. で推奨されているタイマーの既定値
+const (
+	DEFAULT_REFRESH_INTERVAL = 3600 * time.Second
+	DEFAULT_RETRY_INTERVAL   = 600 * time.Second
+	DEFAULT_EXPIRE_INTERVAL  = 7200 * time.Second
+)
+
+// RTRのキャッシュサーバーからVRPを受信し、Tableに反映するクライアント
+//
+// 接続後はReset Queryですべての VRPを取得し、
+// 以降はRefresh Intervalごと、またはSerial Notifyを受信したときに
+// Serial Queryで差分を取得する。
+// キャッシュサーバーから応答がないままExpire Intervalが経過した場合は
+// 古いVRPを使い続けないようにTableを空にする。
+type Client struct {
+	Addr  string
+	Table *Table
+	// Tableが更新されたときに呼ばれる
+	OnUpdate func()
+
+	mu              sync.Mutex
+	version         uint8
+	sessionID       uint16
+	serial          uint32
+	hasSerial       bool
+	refreshInterval time.Duration
+	retryInterval   time.Duration
+	expireInterval  time.Duration
+	lastUpdate      time.Time
+	// 最後の更新からExpire Intervalが経過したときに発火するタイマー
+	// Runのgoroutineだけが使い、End of Dataを受信するたびに設定し直す
+	expiry *time.Timer
+
+	// Cache ResponseからEnd of Dataまでに受信したVRP
+	reset    bool
+	announce []VRP
+	withdraw []VRP
+}
+
+func NewClient(addr string, table *Table) *Client {
+	return &Client{
+		Addr:            addr,
+		Table:           table,
+		version:         PROTOCOL_VERSION_1,
+		refreshInterval: DEFAULT_REFRESH_INTERVAL,
+		retryInterval:   DEFAULT_RETRY_INTERVAL,
+		expireInterval:  DEFAULT_EXPIRE_INTERVAL,
+	}
+}
+
+// 最後にVRPを受信したSerialを返す
+func (c *Client) Serial() (uint32, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.serial, c.hasSerial
+}
+
+// ctxがキャンセルされるまでキャッシュサーバーとの接続を維持する。
+// 接続が切れた場合はRetry Intervalの経過後に再接続する。
+func (c *Client) Run(ctx context.Context) error {
+	defer c.stopExpireTimer()
+	for {
+		err := c.session(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		c.mu.Lock()
+		retry := c.retryInterval
+		c.mu.Unlock()
+		log.Warn("rtr session is closed", "cache", c.Addr, "error", err, "retry", retry)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(retry):
+		case <-c.expireTimer():
+			c.expire()
+		}
+	}
+}
+
+func (c *Client) session(ctx context.Context) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", c.Addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	// 受信を待っている間にctxがキャンセルされても、すぐに終了できるようにする
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	pdus := make(chan PDU)
+	errs := make(chan error, 1)
+	// セッションを終了した後に、受信したPDUを渡そうとして止まらないようにする
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			p, err := ReadPDU(conn)
+			if err != nil {
+				errs <- err
+				return
+			}
+			select {
+			case pdus <- p:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	if err := c.sendQuery(conn); err != nil {
+		return err
+	}
+	refresh := time.NewTimer(c.refresh())
+	defer refresh.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-errs:
+			return err
+		case <-refresh.C:
+			if err := c.sendQuery(conn); err != nil {
+				return err
+			}
+			refresh.Reset(c.refresh())
+		case <-c.expireTimer():
+			c.expire()
+		case p := <-pdus:
+			updated, err := c.handlePDU(conn, p)
+			if err != nil {
+				return err
+			}
+			if updated {
+				refresh.Reset(c.refresh())
+				c.resetExpireTimer()
+			}
+		}
+	}
+}
+
+func (c *Client) refresh() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.refreshInterval
+}
+
+// 最後の更新からExpire Intervalが経過したときに値を受信するchannel
+// VRPを受信していない場合はnilを返すため、selectで待っても発火しない
+func (c *Client) expireTimer() <-chan time.Time {
+	if c.expiry == nil {
+		return nil
+	}
+	return c.expiry.C
+}
+
+// 最後の更新からExpire Intervalが経過したときに発火するように、タイマーを設定し直す
+func (c *Client) resetExpireTimer() {
+	c.mu.Lock()
+	d := time.Until(c.lastUpdate.Add(c.expireInterval))
+	c.mu.Unlock()
+	if c.expiry == nil {
+		c.expiry = time.NewTimer(d)
+		return
+	}
+	c.stopExpireTimer()
+	c.expiry.Reset(d)
+}
+
+// タイマーを止め、発火していた場合はchannelの値を捨てる
+func (c *Client) stopExpireTimer() {
+	if c.expiry != nil && !c.expiry.Stop() {
+		select {
+		case <-c.expiry.C:
+		default:
+		}
+	}
+}
+
+func (c *Client) expire() {
+	c.mu.Lock()
+	c.hasSerial = false
+	c.mu.Unlock()
+	log.Warn("vrps are expired", "cache", c.Addr)
+	c.Table.Clear()
+	c.notify()
+}
+
+func (c *Client) notify() {
+	if c.OnUpdate != nil {
+		c.OnUpdate()
+	}
+}
+
+// SessionとSerialを持っていればSerial Query、持っていなければReset Queryを送信する
+func (c *Client) sendQuery(conn net.Conn) error {
+	c.mu.Lock()
+	var p PDU
+	if c.hasSerial {
+		p = &SerialQueryPDU{Version: c.version, SessionID: c.sessionID, Serial: c.serial}
+	} else {
+		p = &ResetQueryPDU{Version: c.version}
+	}
+	c.mu.Unlock()
+	_, err := conn.Write(p.ToBytes())
+	return err
+}
+
+func (c *Client) sendResetQuery(conn net.Conn) error {
+	c.mu.Lock()
+	c.hasSerial = false
+	c.mu.Unlock()
+	return c.sendQuery(conn)
+}
+
+// 受信したPDUを処理する。
+// End of Dataを受信してTableを更新した場合はtrueを返す。
+func (c *Client) handlePDU(conn net.Conn, p PDU) (bool, error) {
+	switch t := p.(type) {
+	case *SerialNotifyPDU:
+		return false, c.sendQuery(conn)
+	case *CacheResponsePDU:
+		c.mu.Lock()
+		if c.hasSerial && c.sessionID != t.SessionID {
+			// Session IDが変わった場合はキャッシュサーバーが再起動しているため、すべて取り直す
+			c.mu.Unlock()
+			return false, c.sendResetQuery(conn)
+		}
+		c.reset = !c.hasSerial
+		c.sessionID = t.SessionID
+		c.announce = nil
+		c.withdraw = nil
+		c.mu.Unlock()
+	case *PrefixPDU:
+		c.mu.Lock()
+		if t.Announce {
+			c.announce = append(c.announce, t.VRP)
+		} else {
+			c.withdraw = append(c.withdraw, t.VRP)
+		}
+		c.mu.Unlock()
+	case *EndOfDataPDU:
+		c.mu.Lock()
+		if c.reset {
+			c.Table.Replace(c.announce)
+		} else {
+			c.Table.Apply(c.announce, c.withdraw)
+		}
+		c.announce = nil
+		c.withdraw = nil
+		c.serial = t.Serial
+		c.hasSerial = true
+		c.lastUpdate = time.Now()
+		if t.Version >= PROTOCOL_VERSION_1 {
+			c.refreshInterval = time.Duration(t.RefreshInterval) * time.Second
+			c.retryInterval = time.Duration(t.RetryInterval) * time.Second
+			c.expireInterval = time.Duration(t.ExpireInterval) * time.Second
+		}
+		c.mu.Unlock()
+		c.notify()
+		return true, nil
+	case *CacheResetPDU:
+		return false, c.sendResetQuery(conn)
+	case *RouterKeyPDU:
+		// BGPsecは対応していないため無視する
+	case *ErrorReportPDU:
+		switch t.Code {
+		case NoDataAvailable:
+			// キャッシュサーバーの準備ができていないため、Retry Intervalの後に再接続する
+			return false, fmt.Errorf("rtr error report: no data available")
+		case UnsupportedProtocolVersion:
+			c.mu.Lock()
+			if c.version > PROTOCOL_VERSION_0 {
+				c.version--
+			}
+			c.mu.Unlock()
+			return false, fmt.Errorf("rtr error report: unsupported protocol version")
+		default:
+			return false, fmt.Errorf("rtr error report: code: %d, text: %s", t.Code, t.Text)
+		}
+	default:
+		return false, fmt.Errorf("unexpected pdu: %T", p)
+	}
+	return false, nil
+}
@@ -0,0 +1,315 @@
+package rpki
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/netip"
+)
+
+// RPKI to Router ProtocolのPDU (RFC8210)
+//
+// すべてのPDUは次のHeaderから始まる
+// Protocol Version: 1byte: RFC6810は0, RFC8210は1
+// PDU Type: 1byte
+// Session ID / Error Code / zero: 2byte: PDU Typeによって異なる
+// Length: 4byte: Headerを含めたPDU全体のバイト数
+const PDU_HEADER_LENGTH = 8
+
+// 1つのPDUとして受け入れる最大のバイト数
+// Error Reportには任意の長さのテキストが含まれるため、上限を設けておく
+const PDU_MAX_LENGTH = 64 * 1024
+
+const (
+	PROTOCOL_VERSION_0 uint8 = 0
+	PROTOCOL_VERSION_1 uint8 = 1
+)
+
+type PDUType uint8
+
+const (
+	SerialNotify  PDUType = 0
+	SerialQuery   PDUType = 1
+	ResetQuery    PDUType = 2
+	CacheResponse PDUType = 3
+	IPv4Prefix    PDUType = 4
+	IPv6Prefix    PDUType = 6
+	EndOfData     PDUType = 7
+	CacheReset    PDUType = 8
+	RouterKey     PDUType = 9
+	ErrorReport   PDUType = 10
+)
+
+// Error ReportのError Code (RFC8210 12.)
+type ErrorCode uint16
+
+const (
+	CorruptData                ErrorCode = 0
+	InternalError              ErrorCode = 1
+	NoDataAvailable            ErrorCode = 2
+	InvalidRequest             ErrorCode = 3
+	UnsupportedProtocolVersion ErrorCode = 4
+	UnsupportedPDUType         ErrorCode = 5
+	WithdrawalOfUnknownRecord  ErrorCode = 6
+	DuplicateAnnouncement      ErrorCode = 7
+	UnexpectedProtocolVersion  ErrorCode = 8
+)
+
+type PDU interface {
+	ToBytes() []byte
+}
+
+type SerialNotifyPDU struct {
+	Version   uint8
+	SessionID uint16
+	Serial    uint32
+}
+
+type SerialQueryPDU struct {
+	Version   uint8
+	SessionID uint16
+	Serial    uint32
+}
+
+type ResetQueryPDU struct {
+	Version uint8
+}
+
+type CacheResponsePDU struct {
+	Version   uint8
+	SessionID uint16
+}
+
+// IPv4 Prefix PDUとIPv6 Prefix PDU
+type PrefixPDU struct {
+	Version uint8
+	// Flagsの最下位bitが1の場合はannounce、0の場合はwithdraw
+	Announce bool
+	VRP      VRP
+}
+
+type EndOfDataPDU struct {
+	Version   uint8
+	SessionID uint16
+	Serial    uint32
+	// Version 1のみ。秒単位
+	RefreshInterval uint32
+	RetryInterval   uint32
+	ExpireInterval  uint32
+}
+
+type CacheResetPDU struct {
+	Version uint8
+}
+
+// Router Key PDUはBGPsec用のため内容は解釈しない
+type RouterKeyPDU struct {
+	Version uint8
+	Data    []byte
+}
+
+type ErrorReportPDU struct {
+	Version uint8
+	Code    ErrorCode
+	PDU     []byte
+	Text    string
+}
+
+func header(version uint8, t PDUType, field uint16, length int) []byte {
+	b := make([]byte, PDU_HEADER_LENGTH, length)
+	b[0] = version
+	b[1] = byte(t)
+	binary.BigEndian.PutUint16(b[2:4], field)
+	binary.BigEndian.PutUint32(b[4:8], uint32(length))
+	return b
+}
+
+func (p *SerialNotifyPDU) ToBytes() []byte {
+	b := header(p.Version, SerialNotify, p.SessionID, 12)
+	return binary.BigEndian.AppendUint32(b, p.Serial)
+}
+
+func (p *SerialQueryPDU) ToBytes() []byte {
+	b := header(p.Version, SerialQuery, p.SessionID, 12)
+	return binary.BigEndian.AppendUint32(b, p.Serial)
+}
+
+func (p *ResetQueryPDU) ToBytes() []byte {
+	return header(p.Version, ResetQuery, 0, 8)
+}
+
+func (p *CacheResponsePDU) ToBytes() []byte {
+	return header(p.Version, CacheResponse, p.SessionID, 8)
+}
+
+func (p *PrefixPDU) ToBytes() []byte {
+	addr := p.VRP.Prefix.Addr()
+	t, l := IPv4Prefix, 20
+	if addr.Is6() {
+		t, l = IPv6Prefix, 32
+	}
+	b := header(p.Version, t, 0, l)
+	flags := byte(0)
+	if p.Announce {
+		flags = 1
+	}
+	b = append(b, flags, byte(p.VRP.Prefix.Bits()), p.VRP.MaxLength, 0)
+	b = append(b, addr.AsSlice()...)
+	return binary.BigEndian.AppendUint32(b, p.VRP.ASN)
+}
+
+func (p *EndOfDataPDU) ToBytes() []byte {
+	if p.Version == PROTOCOL_VERSION_0 {
+		b := header(p.Version, EndOfData, p.SessionID, 12)
+		return binary.BigEndian.AppendUint32(b, p.Serial)
+	}
+	b := header(p.Version, EndOfData, p.SessionID, 24)
+	b = binary.BigEndian.AppendUint32(b, p.Serial)
+	b = binary.BigEndian.AppendUint32(b, p.RefreshInterval)
+	b = binary.BigEndian.AppendUint32(b, p.RetryInterval)
+	return binary.BigEndian.AppendUint32(b, p.ExpireInterval)
+}
+
+func (p *CacheResetPDU) ToBytes() []byte {
+	return header(p.Version, CacheReset, 0, 8)
+}
+
+func (p *RouterKeyPDU) ToBytes() []byte {
+	b := header(p.Version, RouterKey, 0, PDU_HEADER_LENGTH+len(p.Data))
+	return append(b, p.Data...)
+}
+
+func (p *ErrorReportPDU) ToBytes() []byte {
+	l := PDU_HEADER_LENGTH + 4 + len(p.PDU) + 4 + len(p.Text)
+	b := header(p.Version, ErrorReport, uint16(p.Code), l)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(p.PDU)))
+	b = append(b, p.PDU...)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(p.Text)))
+	return append(b, p.Text...)
+}
+
+// 1つのPDUを読み込む
+func ReadPDU(r io.Reader) (PDU, error) {
+	h := make([]byte, PDU_HEADER_LENGTH)
+	if _, err := io.ReadFull(r, h); err != nil {
+		return nil, err
+	}
+	l := binary.BigEndian.Uint32(h[4:8])
+	if l < PDU_HEADER_LENGTH || l > PDU_MAX_LENGTH {
+		return nil, fmt.Errorf("invalid pdu length: %d", l)
+	}
+	b := make([]byte, l)
+	copy(b, h)
+	if _, err := io.ReadFull(r, b[PDU_HEADER_LENGTH:]); err != nil {
+		return nil, err
+	}
+	return BytesToPDU(b)
+}
+
+func BytesToPDU(b []byte) (PDU, error) {
+	if len(b) < PDU_HEADER_LENGTH {
+		return nil, fmt.Errorf("pdu is too short: %d", len(b))
+	}
+	v := b[0]
+	t := PDUType(b[1])
+	field := binary.BigEndian.Uint16(b[2:4])
+	body := b[PDU_HEADER_LENGTH:]
+	wantLen := func(l int) error {
+		if len(b) != l {
+			return fmt.Errorf("invalid length of pdu type %d: %d", t, len(b))
+		}
+		return nil
+	}
+	switch t {
+	case SerialNotify:
+		if err := wantLen(12); err != nil {
+			return nil, err
+		}
+		return &SerialNotifyPDU{v, field, binary.BigEndian.Uint32(body)}, nil
+	case SerialQuery:
+		if err := wantLen(12); err != nil {
+			return nil, err
+		}
+		return &SerialQueryPDU{v, field, binary.BigEndian.Uint32(body)}, nil
+	case ResetQuery:
+		if err := wantLen(8); err != nil {
+			return nil, err
+		}
+		return &ResetQueryPDU{v}, nil
+	case CacheResponse:
+		if err := wantLen(8); err != nil {
+			return nil, err
+		}
+		return &CacheResponsePDU{v, field}, nil
+	case IPv4Prefix, IPv6Prefix:
+		addrLen := 4
+		if t == IPv6Prefix {
+			addrLen = 16
+		}
+		if err := wantLen(PDU_HEADER_LENGTH + 4 + addrLen + 4); err != nil {
+			return nil, err
+		}
+		addr, _ := netip.AddrFromSlice(body[4 : 4+addrLen])
+		prefix, err := addr.Prefix(int(body[1]))
+		if err != nil {
+			return nil, err
+		}
+		if int(body[2]) < prefix.Bits() || int(body[2]) > addr.BitLen() {
+			return nil, fmt.Errorf("invalid max length: %d, prefix: %s", body[2], prefix)
+		}
+		return &PrefixPDU{
+			Version:  v,
+			Announce: body[0]&1 == 1,
+			VRP: VRP{
+				Prefix:    prefix,
+				MaxLength: body[2],
+				ASN:       binary.BigEndian.Uint32(body[4+addrLen:]),
+			},
+		}, nil
+	case EndOfData:
+		if v == PROTOCOL_VERSION_0 {
+			if err := wantLen(12); err != nil {
+				return nil, err
+			}
+			return &EndOfDataPDU{Version: v, SessionID: field, Serial: binary.BigEndian.Uint32(body)}, nil
+		}
+		if err := wantLen(24); err != nil {
+			return nil, err
+		}
+		return &EndOfDataPDU{
+			Version:         v,
+			SessionID:       field,
+			Serial:          binary.BigEndian.Uint32(body[0:4]),
+			RefreshInterval: binary.BigEndian.Uint32(body[4:8]),
+			RetryInterval:   binary.BigEndian.Uint32(body[8:12]),
+			ExpireInterval:  binary.BigEndian.Uint32(body[12:16]),
+		}, nil
+	case CacheReset:
+		if err := wantLen(8); err != nil {
+			return nil, err
+		}
+		return &CacheResetPDU{v}, nil
+	case RouterKey:
+		return &RouterKeyPDU{v, body}, nil
+	case ErrorReport:
+		if len(body) < 4 {
+			return nil, fmt.Errorf("error report is too short: %d", len(b))
+		}
+		pl := int(binary.BigEndian.Uint32(body[0:4]))
+		if len(body) < 4+pl+4 {
+			return nil, fmt.Errorf("error report is too short: %d", len(b))
+		}
+		tl := int(binary.BigEndian.Uint32(body[4+pl : 8+pl]))
+		if len(body) < 8+pl+tl {
+			return nil, fmt.Errorf("error report is too short: %d", len(b))
+		}
+		return &ErrorReportPDU{
+			Version: v,
+			Code:    ErrorCode(field),
+			PDU:     body[4 : 4+pl],
+			Text:    string(body[8+pl : 8+pl+tl]),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown pdu type: %d", t)
+	}
+}
@@ -0,0 +1,216 @@
+package rpki
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func mustVRP(prefix string, maxLen uint8, asn uint32) VRP {
+	return VRP{Prefix: netip.MustParsePrefix(prefix), MaxLength: maxLen, ASN: asn}
+}
+
+// RFC6811の検証結果が正しく計算されることを確認するテスト
+func TestTableValidate(t *testing.T) {
+	table := NewTable()
+	table.Add(mustVRP("10.0.0.0/16", 24, 65001))
+	table.Add(mustVRP("10.1.0.0/16", 16, 65002))
+	table.Add(mustVRP("10.2.0.0/16", 24, 0))
+
+	tests := []struct {
+		prefix    string
+		origin    uint32
+		hasOrigin bool
+		want      ValidationState
+	}{
+		{"10.0.0.0/16", 65001, true, Valid},
+		{"10.0.1.0/24", 65001, true, Valid},
+		{"10.0.1.0/25", 65001, true, Invalid}, // MaxLengthより長い
+		{"10.0.1.0/24", 65003, true, Invalid}, // Originが異なる
+		{"10.0.1.0/24", 65001, false, Invalid},
+		{"10.1.0.0/16", 65002, true, Valid},
+		{"10.1.1.0/24", 65002, true, Invalid},
+		{"10.2.0.0/24", 0, true, Invalid}, // AS0はどの経路にもマッチしない
+		{"10.3.0.0/16", 65001, true, NotFound},
+		{"10.0.0.0/8", 65001, true, NotFound}, // VRPより短いプレフィックスはカバーされない
+	}
+	for _, tt := range tests {
+		_, nw, _ := net.ParseCIDR(tt.prefix)
+		if got := table.Validate(nw, tt.origin, tt.hasOrigin); got != tt.want {
+			t.Errorf("%s AS%d: Want: %s, Got: %s", tt.prefix, tt.origin, tt.want.Show(), got.Show())
+		}
+	}
+
+	table.Remove(mustVRP("10.0.0.0/16", 24, 65001))
+	_, nw, _ := net.ParseCIDR("10.0.1.0/24")
+	if got := table.Validate(nw, 65001, true); got != NotFound {
+		t.Errorf("Want: %s, Got: %s", NotFound.Show(), got.Show())
+	}
+	if table.Len() != 2 {
+		t.Errorf("Want: 2, Got: %d", table.Len())
+	}
+}
+
+// PDUをバイト列に変換し、元に戻せることを確認するテスト
+func TestConvertPDUToBytesAndBytesToPDU(t *testing.T) {
+	pdus := []PDU{
+		&SerialNotifyPDU{1, 10, 100},
+		&SerialQueryPDU{1, 10, 100},
+		&ResetQueryPDU{1},
+		&CacheResponsePDU{1, 10},
+		&PrefixPDU{1, true, mustVRP("10.0.0.0/16", 24, 65001)},
+		&PrefixPDU{1, false, mustVRP("2001:db8::/32", 48, 65001)},
+		&EndOfDataPDU{1, 10, 100, 3600, 600, 7200},
+		&EndOfDataPDU{Version: 0, SessionID: 10, Serial: 100},
+		&CacheResetPDU{1},
+		&ErrorReportPDU{1, NoDataAvailable, []byte{1, 2, 0, 0, 0, 0, 0, 8}, "no data"},
+	}
+	for _, p := range pdus {
+		b := p.ToBytes()
+		got, err := BytesToPDU(b)
+		if err != nil {
+			t.Errorf("Error: %v", err)
+			continue
+		}
+		if string(got.ToBytes()) != string(b) {
+			t.Errorf("Want: %v, Got: %v", b, got.ToBytes())
+		}
+	}
+}
+
+// テスト用のRTRキャッシュサーバー
+// Reset QueryにはvrpsをSerial 1として返し、
+// Serial QueryにはwithdrawをSerial 2の差分として返す。
+func serveRTR(t *testing.T, l net.Listener, vrps, withdraw []VRP) {
+	conn, err := l.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	const session = 42
+	for {
+		p, err := ReadPDU(conn)
+		if err != nil {
+			return
+		}
+		var resp []PDU
+		switch q := p.(type) {
+		case *ResetQueryPDU:
+			resp = append(resp, &CacheResponsePDU{1, session})
+			for _, v := range vrps {
+				resp = append(resp, &PrefixPDU{1, true, v})
+			}
+			resp = append(resp, &EndOfDataPDU{1, session, 1, 3600, 1, 7200})
+			// 差分があることを通知する
+			resp = append(resp, &SerialNotifyPDU{1, session, 2})
+		case *SerialQueryPDU:
+			if q.SessionID != session || q.Serial != 1 {
+				t.Errorf("unexpected serial query: %+v", q)
+			}
+			resp = append(resp, &CacheResponsePDU{1, session})
+			for _, v := range withdraw {
+				resp = append(resp, &PrefixPDU{1, false, v})
+			}
+			resp = append(resp, &EndOfDataPDU{1, session, 2, 3600, 1, 7200})
+		}
+		for _, r := range resp {
+			if _, err := conn.Write(r.ToBytes()); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// ClientがReset QueryとSerial QueryでTableを更新することを確認するテスト
+func TestClientSyncsWithCacheServer(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	defer l.Close()
+	v1 := mustVRP("10.0.0.0/16", 24, 65001)
+	v2 := mustVRP("10.1.0.0/16", 16, 65002)
+	go serveRTR(t, l, []VRP{v1, v2}, []VRP{v2})
+
+	table := NewTable()
+	client := NewClient(l.Addr().String(), table)
+	updated := make(chan struct{}, 10)
+	client.OnUpdate = func() { updated <- struct{}{} }
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go client.Run(ctx)
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-updated:
+		case <-time.After(3 * time.Second):
+			t.Fatalf("table is not updated")
+		}
+	}
+	if serial, ok := client.Serial(); !ok || serial != 2 {
+		t.Errorf("Want: 2, Got: %d", serial)
+	}
+	vrps := table.VRPs()
+	if len(vrps) != 1 || vrps[0] != v1 {
+		t.Errorf("Want: [%s], Got: %v", v1.Show(), vrps)
+	}
+}
+
+// キャッシュサーバーが応答しないままExpire Intervalが経過した場合に、Tableを空にすることを確認するテスト
+func TestClientExpiresVRPs(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	defer l.Close()
+	v := mustVRP("10.0.0.0/16", 24, 65001)
+	// Reset Queryに1度だけ応答し、その後は何も送信しないキャッシュサーバー
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if _, err := ReadPDU(conn); err != nil {
+			return
+		}
+		for _, r := range []PDU{
+			&CacheResponsePDU{1, 42},
+			&PrefixPDU{1, true, v},
+			&EndOfDataPDU{1, 42, 1, 3600, 600, 1},
+		} {
+			if _, err := conn.Write(r.ToBytes()); err != nil {
+				return
+			}
+		}
+		for {
+			if _, err := ReadPDU(conn); err != nil {
+				return
+			}
+		}
+	}()
+
+	table := NewTable()
+	client := NewClient(l.Addr().String(), table)
+	updated := make(chan struct{}, 10)
+	client.OnUpdate = func() { updated <- struct{}{} }
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go client.Run(ctx)
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-updated:
+		case <-time.After(3 * time.Second):
+			t.Fatalf("table is not updated")
+		}
+	}
+	if _, ok := client.Serial(); ok {
+		t.Errorf("Want: no serial, Got: serial")
+	}
+	if vrps := table.VRPs(); len(vrps) != 0 {
+		t.Errorf("Want: [], Got: %v", vrps)
+	}
+}
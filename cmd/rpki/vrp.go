@@ -0,0 +1,205 @@
+package rpki
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"sync"
+)
+
+// RFC6811で定義されている経路の検証結果
+type ValidationState int
+
+const (
+	// VRPにカバーされていない経路。RPKIを使用しない場合もこの値になる
+	NotFound ValidationState = iota
+	Valid
+	Invalid
+)
+
+func (v ValidationState) Show() string {
+	switch v {
+	case NotFound:
+		return "not-found"
+	case Valid:
+		return "valid"
+	case Invalid:
+		return "invalid"
+	default:
+		return fmt.Sprintf("%d", v)
+	}
+}
+
+func ParseValidationState(s string) (ValidationState, error) {
+	switch s {
+	case "not-found":
+		return NotFound, nil
+	case "valid":
+		return Valid, nil
+	case "invalid":
+		return Invalid, nil
+	default:
+		return 0, fmt.Errorf("string is not validation state: %s", s)
+	}
+}
+
+// Validated ROA Payload
+// Prefixから、MaxLengthまでの長さのプレフィックスをASNが生成してよいことを表す
+type VRP struct {
+	Prefix    netip.Prefix
+	MaxLength uint8
+	ASN       uint32
+}
+
+func (v VRP) Show() string {
+	return fmt.Sprintf("%s-%d AS%d", v.Prefix, v.MaxLength, v.ASN)
+}
+
+// VRPの集合
+// 経路の検証では検証対象のプレフィックスを含むすべてのVRPを調べる必要があるため、
+// VRPのPrefixをKeyにして保持し、検証対象のプレフィックスを1bitずつ短くしながら検索する。
+type Table struct {
+	mu   sync.RWMutex
+	vrps map[netip.Prefix]map[VRP]struct{}
+	size int
+}
+
+func NewTable() *Table {
+	return &Table{
+		vrps: make(map[netip.Prefix]map[VRP]struct{}),
+	}
+}
+
+func (t *Table) Len() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.size
+}
+
+// VRPを追加する
+func (t *Table) Add(v VRP) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.add(v)
+}
+
+// VRPを削除する
+func (t *Table) Remove(v VRP) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.remove(v)
+}
+
+// announceのVRPを追加し、withdrawのVRPを削除する。
+// RTRのEnd of Dataまでに受信した差分をまとめて反映するために使用する。
+func (t *Table) Apply(announce, withdraw []VRP) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, v := range withdraw {
+		t.remove(v)
+	}
+	for _, v := range announce {
+		t.add(v)
+	}
+}
+
+// すべてのVRPをvrpsに置き換える
+func (t *Table) Replace(vrps []VRP) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.vrps = make(map[netip.Prefix]map[VRP]struct{})
+	t.size = 0
+	for _, v := range vrps {
+		t.add(v)
+	}
+}
+
+func (t *Table) Clear() {
+	t.Replace(nil)
+}
+
+// すべてのVRPを返す
+func (t *Table) VRPs() []VRP {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	vrps := make([]VRP, 0, t.size)
+	for _, vs := range t.vrps {
+		for v := range vs {
+			vrps = append(vrps, v)
+		}
+	}
+	return vrps
+}
+
+func (t *Table) add(v VRP) {
+	v.Prefix = v.Prefix.Masked()
+	vs, ok := t.vrps[v.Prefix]
+	if !ok {
+		vs = make(map[VRP]struct{})
+		t.vrps[v.Prefix] = vs
+	}
+	if _, ok := vs[v]; !ok {
+		vs[v] = struct{}{}
+		t.size++
+	}
+}
+
+func (t *Table) remove(v VRP) {
+	v.Prefix = v.Prefix.Masked()
+	vs, ok := t.vrps[v.Prefix]
+	if !ok {
+		return
+	}
+	if _, ok := vs[v]; ok {
+		delete(vs, v)
+		t.size--
+	}
+	if len(vs) == 0 {
+		delete(t.vrps, v.Prefix)
+	}
+}
+
+// RFC6811 2. に従って経路を検証する。
+// hasOriginがfalseの場合(AS Pathの最後がAS_SETの場合)はどのVRPにもマッチしない。
+func (t *Table) Validate(nw *net.IPNet, origin uint32, hasOrigin bool) ValidationState {
+	p, ok := toPrefix(nw)
+	if !ok {
+		return NotFound
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	covered := false
+	for l := p.Bits(); l >= 0; l-- {
+		cp, err := p.Addr().Prefix(l)
+		if err != nil {
+			break
+		}
+		for v := range t.vrps[cp] {
+			covered = true
+			// AS0のVRPはどの経路にもマッチしない (RFC6483 4.)
+			if hasOrigin && v.ASN != 0 && v.ASN == origin && p.Bits() <= int(v.MaxLength) {
+				return Valid
+			}
+		}
+	}
+	if covered {
+		return Invalid
+	}
+	return NotFound
+}
+
+func toPrefix(nw *net.IPNet) (netip.Prefix, bool) {
+	ip := nw.IP
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return netip.Prefix{}, false
+	}
+	ones, bits := nw.Mask.Size()
+	if bits != addr.BitLen() {
+		return netip.Prefix{}, false
+	}
+	return netip.PrefixFrom(addr, ones).Masked(), true
+}
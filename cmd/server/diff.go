@@ -0,0 +1,78 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/SotaUeda/gobgp/peer"
+)
+
+// 読み込み直した設定と現在の設定の差分
+// PeerはRemoteIPで識別する
+type Diff struct {
+	Added   []*peer.Config
+	Removed []*peer.Config
+	// 設定が変わったPeerの新しい設定
+	Changed []*peer.Config
+	// 自身で生成する経路など、LocRibの設定が変わったかどうか
+	Global bool
+}
+
+func NewDiff(oldLocRib *peer.Config, olds []*peer.Config, newLocRib *peer.Config, news []*peer.Config) *Diff {
+	d := &Diff{Global: !oldLocRib.Equal(newLocRib)}
+	oldPeers := make(map[string]*peer.Config)
+	for _, c := range olds {
+		oldPeers[c.RemoteIP.String()] = c
+	}
+	newPeers := make(map[string]struct{})
+	for _, c := range news {
+		key := c.RemoteIP.String()
+		newPeers[key] = struct{}{}
+		old, ok := oldPeers[key]
+		switch {
+		case !ok:
+			d.Added = append(d.Added, c)
+		case !old.Equal(c):
+			d.Changed = append(d.Changed, c)
+		}
+	}
+	for _, c := range olds {
+		if _, ok := newPeers[c.RemoteIP.String()]; !ok {
+			d.Removed = append(d.Removed, c)
+		}
+	}
+	return d
+}
+
+// 差分がない場合はtrueを返す
+func (d *Diff) Empty() bool {
+	return !d.Global && len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+func (d *Diff) Show() string {
+	if d.Empty() {
+		return "no changes"
+	}
+	parts := []string{}
+	if d.Global {
+		parts = append(parts, "global changed")
+	}
+	for _, set := range []struct {
+		name  string
+		confs []*peer.Config
+	}{
+		{"added", d.Added},
+		{"removed", d.Removed},
+		{"changed", d.Changed},
+	} {
+		if len(set.confs) == 0 {
+			continue
+		}
+		addrs := make([]string, 0, len(set.confs))
+		for _, c := range set.confs {
+			addrs = append(addrs, c.RemoteIP.String())
+		}
+		parts = append(parts, fmt.Sprintf("%s: %s", set.name, strings.Join(addrs, ", ")))
+	}
+	return strings.Join(parts, "; ")
+}
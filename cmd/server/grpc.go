@@ -0,0 +1,432 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/SotaUeda/gobgp/api"
+	"github.com/SotaUeda/gobgp/bgptype"
+	"github.com/SotaUeda/gobgp/packets"
+	"github.com/SotaUeda/gobgp/peer"
+	"github.com/SotaUeda/gobgp/policy"
+)
+
+// gRPCのAPIを提供する
+// addrは "127.0.0.1:50051" のようなTCPのアドレスか、"unix:/var/run/gobgp.sock" のようなUnixドメインソケット
+// ctxがキャンセルされるまで処理を続ける。
+func (s *Server) Serve(ctx context.Context, addr string) error {
+	l, err := listen(addr)
+	if err != nil {
+		return err
+	}
+	gs := grpc.NewServer()
+	api.RegisterGobgpApiServer(gs, &apiServer{s: s})
+	go func() {
+		<-ctx.Done()
+		// WatchEventのストリームは終わらないため、GracefulStopではなくStopで止める
+		gs.Stop()
+	}()
+	return gs.Serve(l)
+}
+
+func listen(addr string) (net.Listener, error) {
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		// 前回の起動時のソケットファイルが残っていると、Listenできないため削除する
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		return net.Listen("unix", path)
+	}
+	return net.Listen("tcp", addr)
+}
+
+type apiServer struct {
+	api.UnimplementedGobgpApiServer
+	s *Server
+}
+
+// Serverのエラーを、gRPCのステータスコードに変換する
+func toStatus(err error) error {
+	switch {
+	case errors.Is(err, ErrNeighborNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, ErrNeighborExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+func parseAddress(s string) (net.IP, error) {
+	ip := net.ParseIP(s).To4()
+	if ip == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid ipv4 address %q", s)
+	}
+	return ip, nil
+}
+
+func parsePrefix(s string) (*net.IPNet, error) {
+	_, nw, err := net.ParseCIDR(s)
+	if err != nil || nw.IP.To4() == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid ipv4 prefix %q", s)
+	}
+	return nw, nil
+}
+
+func (a *apiServer) ListNeighbors(ctx context.Context, req *api.ListNeighborsRequest) (*api.ListNeighborsResponse, error) {
+	res := &api.ListNeighborsResponse{}
+	for _, p := range a.s.Peers() {
+		res.Neighbors = append(res.Neighbors, toNeighbor(p.Info()))
+	}
+	return res, nil
+}
+
+func (a *apiServer) GetNeighbor(ctx context.Context, req *api.GetNeighborRequest) (*api.Neighbor, error) {
+	addr, err := parseAddress(req.Address)
+	if err != nil {
+		return nil, err
+	}
+	p, err := a.s.Peer(addr)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toNeighbor(p.Info()), nil
+}
+
+func (a *apiServer) AddNeighbor(ctx context.Context, req *api.AddNeighborRequest) (*api.AddNeighborResponse, error) {
+	if req.Neighbor == nil {
+		return nil, status.Error(codes.InvalidArgument, "neighbor is required")
+	}
+	c, err := a.neighborConfig(req.Neighbor)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.s.AddNeighbor(c); err != nil {
+		return nil, toStatus(err)
+	}
+	return &api.AddNeighborResponse{}, nil
+}
+
+// APIで指定されなかった値は、LocRibの設定(設定ファイルのglobal)を使う
+func (a *apiServer) neighborConfig(n *api.NeighborConfig) (*peer.Config, error) {
+	g := a.s.LocRibConfig()
+	c := &peer.Config{
+		LocalAS:    g.LocalAS,
+		LocalIP:    g.LocalIP,
+		RouterID:   g.RouterID,
+		ListenAddr: g.ListenAddr,
+		ListenPort: g.ListenPort,
+		Mode:       peer.Mode(n.Mode),
+		RemotePort: int(n.Port),
+		Timers: peer.Timers{
+			HoldTime:          time.Duration(n.HoldTime) * time.Second,
+			KeepaliveInterval: time.Duration(n.KeepaliveInterval) * time.Second,
+			ConnectRetryTime:  time.Duration(n.ConnectRetry) * time.Second,
+		},
+	}
+	ip, err := parseAddress(n.Address)
+	if err != nil {
+		return nil, err
+	}
+	c.RemoteIP = ip
+	if n.RemoteAs == 0 || n.RemoteAs > 65535 {
+		return nil, status.Errorf(codes.InvalidArgument, "remote as must be 1-65535, got %d", n.RemoteAs)
+	}
+	c.RemoteAS = bgptype.AutonomousSystemNumber(n.RemoteAs)
+	if n.LocalAddress != "" {
+		if c.LocalIP, err = parseAddress(n.LocalAddress); err != nil {
+			return nil, err
+		}
+	}
+	if c.Timers.HoldTime != 0 && c.Timers.HoldTime < 3*time.Second {
+		return nil, status.Errorf(codes.InvalidArgument, "hold time must be 0 or at least 3s, got %v", c.Timers.HoldTime)
+	}
+	c.ConfStr = "api"
+	return c, nil
+}
+
+func (a *apiServer) DeleteNeighbor(ctx context.Context, req *api.DeleteNeighborRequest) (*api.DeleteNeighborResponse, error) {
+	addr, err := parseAddress(req.Address)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.s.DeleteNeighbor(addr); err != nil {
+		return nil, toStatus(err)
+	}
+	return &api.DeleteNeighborResponse{}, nil
+}
+
+func (a *apiServer) EnableNeighbor(ctx context.Context, req *api.EnableNeighborRequest) (*api.EnableNeighborResponse, error) {
+	addr, err := parseAddress(req.Address)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.s.EnableNeighbor(addr); err != nil {
+		return nil, toStatus(err)
+	}
+	return &api.EnableNeighborResponse{}, nil
+}
+
+func (a *apiServer) DisableNeighbor(ctx context.Context, req *api.DisableNeighborRequest) (*api.DisableNeighborResponse, error) {
+	addr, err := parseAddress(req.Address)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.s.DisableNeighbor(addr); err != nil {
+		return nil, toStatus(err)
+	}
+	return &api.DisableNeighborResponse{}, nil
+}
+
+func (a *apiServer) ResetNeighbor(ctx context.Context, req *api.ResetNeighborRequest) (*api.ResetNeighborResponse, error) {
+	addr, err := parseAddress(req.Address)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.s.ResetNeighbor(addr, req.Soft); err != nil {
+		return nil, toStatus(err)
+	}
+	return &api.ResetNeighborResponse{}, nil
+}
+
+func (a *apiServer) ListPath(ctx context.Context, req *api.ListPathRequest) (*api.ListPathResponse, error) {
+	// 本実装ではIPv4 Unicastのみ扱う
+	if f := req.Family; f != nil && (f.Afi != uint32(packets.AFI_IPV4) || f.Safi != uint32(packets.SAFI_UNICAST)) {
+		return nil, status.Errorf(codes.InvalidArgument, "unsupported family afi=%d safi=%d", f.Afi, f.Safi)
+	}
+	filters := []peer.RouteFilter{}
+	if len(req.Prefixes) > 0 {
+		pfs := []peer.RouteFilter{}
+		for _, s := range req.Prefixes {
+			nw, err := parsePrefix(s)
+			if err != nil {
+				return nil, err
+			}
+			pfs = append(pfs, peer.MatchPrefix(nw, req.OrLonger))
+		}
+		filters = append(filters, matchAny(pfs))
+	}
+	if req.AsPath != "" {
+		re, err := policy.CompileAsPathRegexp(req.AsPath)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		filters = append(filters, peer.MatchAsPath(re))
+	}
+
+	var rts []*peer.RibEntry
+	switch req.TableType {
+	case api.TableType_LOC_RIB:
+		for _, ps := range a.s.LocRib.RankedPaths() {
+			rts = append(rts, ps...)
+		}
+	case api.TableType_ADJ_RIB_IN, api.TableType_ADJ_RIB_OUT:
+		addr, err := parseAddress(req.Neighbor)
+		if err != nil {
+			return nil, err
+		}
+		p, err := a.s.Peer(addr)
+		if err != nil {
+			return nil, toStatus(err)
+		}
+		if req.TableType == api.TableType_ADJ_RIB_IN {
+			rts = p.AdjRibInRoutes()
+		} else {
+			rts = p.AdjRibOutRoutes()
+		}
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unknown table type %v", req.TableType)
+	}
+
+	res := &api.ListPathResponse{}
+	validated := a.s.rpkiEnabled()
+	for _, re := range rts {
+		if !matchAll(filters, re) {
+			continue
+		}
+		path := toPath(re)
+		if req.TableType == api.TableType_LOC_RIB {
+			path.Best = a.s.LocRib.Rib.Contains(re)
+		}
+		if validated {
+			path.Validation = re.Validation.Show()
+		}
+		res.Paths = append(res.Paths, path)
+	}
+	sort.SliceStable(res.Paths, func(i, j int) bool {
+		if res.Paths[i].Prefix != res.Paths[j].Prefix {
+			return res.Paths[i].Prefix < res.Paths[j].Prefix
+		}
+		// 同じプレフィックスの場合は最適経路を先にする
+		return res.Paths[i].Best && !res.Paths[j].Best
+	})
+	return res, nil
+}
+
+func matchAny(filters []peer.RouteFilter) peer.RouteFilter {
+	return func(re *peer.RibEntry) bool {
+		for _, f := range filters {
+			if f(re) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func matchAll(filters []peer.RouteFilter, re *peer.RibEntry) bool {
+	for _, f := range filters {
+		if !f(re) {
+			return false
+		}
+	}
+	return true
+}
+
+func (a *apiServer) AddPath(ctx context.Context, req *api.AddPathRequest) (*api.AddPathResponse, error) {
+	nw, err := parsePrefix(req.Prefix)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.s.AddLocalPath(nw); err != nil {
+		return nil, status.Error(codes.AlreadyExists, err.Error())
+	}
+	return &api.AddPathResponse{}, nil
+}
+
+func (a *apiServer) DeletePath(ctx context.Context, req *api.DeletePathRequest) (*api.DeletePathResponse, error) {
+	nw, err := parsePrefix(req.Prefix)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.s.DeleteLocalPath(nw); err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	return &api.DeletePathResponse{}, nil
+}
+
+func (a *apiServer) ReloadConfig(ctx context.Context, req *api.ReloadConfigRequest) (*api.ReloadConfigResponse, error) {
+	d, err := a.s.ReloadFile()
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	res := &api.ReloadConfigResponse{Global: d.Global}
+	for _, c := range d.Added {
+		res.Added = append(res.Added, c.RemoteIP.String())
+	}
+	for _, c := range d.Removed {
+		res.Removed = append(res.Removed, c.RemoteIP.String())
+	}
+	for _, c := range d.Changed {
+		res.Changed = append(res.Changed, c.RemoteIP.String())
+	}
+	return res, nil
+}
+
+func (a *apiServer) WatchEvent(req *api.WatchEventRequest, stream api.GobgpApi_WatchEventServer) error {
+	ch := a.s.Watch(stream.Context(), PEER_STATE_EVENT, BEST_PATH_EVENT)
+	// イベントの登録が済んだことをクライアントが確認できるように、先にヘッダーを送信する
+	if err := stream.SendHeader(metadata.MD{}); err != nil {
+		return err
+	}
+	for ev := range ch {
+		var res *api.WatchEventResponse
+		switch {
+		case ev.Type == PEER_STATE_EVENT && req.Peer:
+			res = &api.WatchEventResponse{Event: &api.WatchEventResponse_Peer{Peer: &api.PeerEvent{
+				Address:  ev.Address.String(),
+				OldState: api.SessionState(ev.OldState),
+				NewState: api.SessionState(ev.NewState),
+			}}}
+		case ev.Type == BEST_PATH_EVENT && req.BestPath:
+			be := &api.BestPathEvent{}
+			if ev.Best != nil {
+				be.Path = toPath(ev.Best)
+				be.Path.Best = true
+			} else {
+				be.Path = &api.Path{Prefix: ev.Prefix.String()}
+				be.Withdrawn = true
+			}
+			res = &api.WatchEventResponse{Event: &api.WatchEventResponse_BestPath{BestPath: be}}
+		default:
+			continue
+		}
+		if err := stream.Send(res); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func toNeighbor(info peer.PeerInfo) *api.Neighbor {
+	c := info.Config
+	n := &api.Neighbor{
+		Config: &api.NeighborConfig{
+			Address:           c.RemoteIP.String(),
+			RemoteAs:          uint32(c.RemoteAS),
+			LocalAddress:      c.LocalIP.String(),
+			Mode:              api.Mode(c.Mode),
+			Port:              uint32(c.RemotePort),
+			HoldTime:          uint32(c.Timers.HoldTime / time.Second),
+			KeepaliveInterval: uint32(c.Timers.KeepaliveInterval / time.Second),
+			ConnectRetry:      uint32(c.Timers.ConnectRetryTime / time.Second),
+		},
+		State: &api.NeighborState{
+			SessionState:       api.SessionState(info.State),
+			AdminDown:          info.AdminDown,
+			NegotiatedHoldTime: uint32(info.HoldTime / time.Second),
+			Sent:               toCounters(info.Sent),
+			Received:           toCounters(info.Received),
+			ReceivedPrefixes:   uint64(info.ReceivedPrefixes),
+			AcceptedPrefixes:   uint64(info.AcceptedPrefixes),
+			AdvertisedPrefixes: uint64(info.AdvertisedPrefixes),
+		},
+	}
+	if !info.EstablishedAt.IsZero() {
+		n.State.Uptime = info.EstablishedAt.Unix()
+	}
+	return n
+}
+
+func toCounters(c peer.MessageCounters) *api.MessageCounters {
+	return &api.MessageCounters{
+		Open:         c.Open,
+		Update:       c.Update,
+		Notification: c.Notification,
+		Keepalive:    c.Keepalive,
+		Total:        c.Total,
+	}
+}
+
+func toPath(re *peer.RibEntry) *api.Path {
+	p := &api.Path{
+		Prefix: re.NwAddr.String(),
+		PathId: re.PathID,
+	}
+	if re.PeerAddr != nil {
+		p.Neighbor = re.PeerAddr.String()
+	}
+	for _, pa := range re.GetPathAttributes() {
+		switch a := pa.(type) {
+		case *bgptype.Origin:
+			p.Origin = a.Show()
+		case *bgptype.AsSequence:
+			for _, as := range a.Get() {
+				p.AsPath = append(p.AsPath, uint32(as))
+			}
+		case *bgptype.NextHop:
+			p.NextHop = net.IP(*a).String()
+		}
+	}
+	return p
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	"github.com/SotaUeda/gobgp/api"
+	"github.com/SotaUeda/gobgp/fib"
+	"github.com/SotaUeda/gobgp/peer"
+)
+
+// Unixドメインソケットで起動したAPIに接続する
+func startAPI(t *testing.T) (*Server, api.GobgpApiClient) {
+	t.Helper()
+	c := parse(t, baseConfig)
+	lr, err := peer.NewLocRib(c.LocRibConfig(), fib.NewMemory())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := New(lr, c.LocRibConfig())
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	s.Start(ctx, c.PeerConfigs())
+	path := filepath.Join(t.TempDir(), "gobgp.sock")
+	addr := "unix:" + path
+	go s.Serve(ctx, addr)
+	// ソケットが作成される前に接続すると失敗するため、作成されるまで待つ
+	for i := 0; ; i++ {
+		if _, err := os.Stat(path); err == nil {
+			break
+		}
+		if i == 500 {
+			t.Fatalf("timed out waiting for %s", path)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return s, api.NewGobgpApiClient(conn)
+}
+
+// APIからPeerの一覧の取得と、Peerの追加・削除ができることを確認するテスト
+func TestAPINeighbors(t *testing.T) {
+	_, client := startAPI(t)
+	ctx := context.Background()
+
+	res, err := client.ListNeighbors(ctx, &api.ListNeighborsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Neighbors) != 2 || res.Neighbors[0].Config.Address != "127.0.0.2" {
+		t.Fatalf("Want: 127.0.0.2, 127.0.0.3, Got: %v", res.Neighbors)
+	}
+	if n := res.Neighbors[0]; n.Config.RemoteAs != 64513 || n.State.SessionState != api.SessionState_IDLE {
+		t.Errorf("Want: 64513 IDLE, Got: %v %v", n.Config.RemoteAs, n.State.SessionState)
+	}
+
+	_, err = client.AddNeighbor(ctx, &api.AddNeighborRequest{Neighbor: &api.NeighborConfig{
+		Address: "127.0.0.4", RemoteAs: 64515, Mode: api.Mode_ACTIVE, Port: 1, ConnectRetry: 3600,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := client.GetNeighbor(ctx, &api.GetNeighborRequest{Address: "127.0.0.4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 指定しなかった値はglobalの設定を使う
+	if n.Config.LocalAddress != "127.0.0.1" {
+		t.Errorf("Want: 127.0.0.1, Got: %v", n.Config.LocalAddress)
+	}
+	_, err = client.AddNeighbor(ctx, &api.AddNeighborRequest{Neighbor: &api.NeighborConfig{Address: "127.0.0.4", RemoteAs: 64515}})
+	if status.Code(err) != codes.AlreadyExists {
+		t.Errorf("Want: AlreadyExists, Got: %v", err)
+	}
+
+	if _, err := client.DeleteNeighbor(ctx, &api.DeleteNeighborRequest{Address: "127.0.0.4"}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.GetNeighbor(ctx, &api.GetNeighborRequest{Address: "127.0.0.4"})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("Want: NotFound, Got: %v", err)
+	}
+	_, err = client.DisableNeighbor(ctx, &api.DisableNeighborRequest{Address: "127.0.0.2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.ResetNeighbor(ctx, &api.ResetNeighborRequest{Address: "127.0.0.4", Soft: true})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("Want: NotFound, Got: %v", err)
+	}
+}
+
+// APIから追加した経路がLocRibに表示され、最適経路の変化が通知されることを確認するテスト
+func TestAPIPathAndWatchEvent(t *testing.T) {
+	_, client := startAPI(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.WatchEvent(ctx, &api.WatchEventRequest{BestPath: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// イベントが登録されるまで待つ
+	if _, err := stream.Header(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.AddPath(ctx, &api.AddPathRequest{Prefix: "10.100.210.0/24"}); err != nil {
+		t.Fatal(err)
+	}
+	ev, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if be := ev.GetBestPath(); be == nil || be.Path.Prefix != "10.100.210.0/24" || be.Withdrawn {
+		t.Errorf("Want: 10.100.210.0/24, Got: %v", ev)
+	}
+
+	res, err := client.ListPath(ctx, &api.ListPathRequest{
+		TableType: api.TableType_LOC_RIB,
+		Prefixes:  []string{"10.100.0.0/16"},
+		OrLonger:  true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Paths) != 1 || !res.Paths[0].Best || res.Paths[0].NextHop != "127.0.0.1" {
+		t.Errorf("Want: 10.100.210.0/24 via 127.0.0.1, Got: %v", res.Paths)
+	}
+
+	if _, err := client.DeletePath(ctx, &api.DeletePathRequest{Prefix: "10.100.210.0/24"}); err != nil {
+		t.Fatal(err)
+	}
+	ev, err = stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if be := ev.GetBestPath(); be == nil || !be.Withdrawn {
+		t.Errorf("Want: withdrawn, Got: %v", ev)
+	}
+	_, err = client.ListPath(ctx, &api.ListPathRequest{TableType: api.TableType_ADJ_RIB_IN, Neighbor: "127.0.0.9"})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("Want: NotFound, Got: %v", err)
+	}
+}
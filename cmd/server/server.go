@@ -0,0 +1,366 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/SotaUeda/gobgp/config"
+	"github.com/SotaUeda/gobgp/logging"
+	"github.com/SotaUeda/gobgp/peer"
+	"github.com/SotaUeda/gobgp/rpki"
+)
+
+var log = logging.Logger(logging.SERVER)
+
+// 設定されたPeerを起動し、設定の読み込み直しを反映する
+// LocRibはすべてのPeerで共有する。
+type Server struct {
+	LocRib *peer.LocRib
+	// 設定ファイルのパス。空の場合は設定ファイルを読み込み直せない
+	ConfigPath string
+	// すべてのPeerが送受信したMessageを記録する。Startの前に設定する
+	Recorder peer.MessageRecorder
+	// カーネルへの書き込みに失敗したルートを書き込み直す間隔。Startの前に設定する
+	FIBRetryInterval time.Duration
+
+	mu         sync.Mutex
+	ctx        context.Context
+	locRibConf *peer.Config
+	// RemoteIPをKeyにした、起動中のPeer
+	peers map[string]*runningPeer
+
+	// LocRibの設定のRPKIServerに接続しているクライアント
+	// VRPの更新を通知する際にs.muをロックするため、s.muとは別のロックで保護する
+	rpkiMu sync.Mutex
+	rpki   *rpkiClient
+
+	// Watchで返したチャネル
+	watchMu  sync.Mutex
+	watchers map[chan *Event]*watcher
+}
+
+// カーネルへの書き込みに失敗したルートを書き込み直す間隔の既定値
+const DEFAULT_FIB_RETRY_INTERVAL = 5 * time.Second
+
+var (
+	ErrNeighborNotFound = errors.New("neighbor is not found")
+	ErrNeighborExists   = errors.New("neighbor already exists")
+)
+
+type rpkiClient struct {
+	addr   string
+	cancel context.CancelFunc
+	// Runが終了したら閉じる
+	done chan struct{}
+}
+
+type runningPeer struct {
+	peer *peer.Peer
+	// Peerに最後に渡した設定
+	// Peer.ConfigはPeerのgoroutineが書き換えるため、Serverではこちらを参照する
+	conf *peer.Config
+}
+
+func New(locRib *peer.LocRib, locRibConf *peer.Config) *Server {
+	s := &Server{
+		LocRib:           locRib,
+		FIBRetryInterval: DEFAULT_FIB_RETRY_INTERVAL,
+		locRibConf:       locRibConf,
+		peers:            make(map[string]*runningPeer),
+		watchers:         make(map[chan *Event]*watcher),
+	}
+	locRib.OnBestPathChange = s.onBestPathChange
+	return s
+}
+
+// confsのPeerを起動する
+// ctxがキャンセルされるとすべてのPeerが停止する
+func (s *Server) Start(ctx context.Context, confs []*peer.Config) {
+	s.mu.Lock()
+	s.ctx = ctx
+	for _, c := range confs {
+		s.startPeer(c)
+	}
+	s.mu.Unlock()
+	s.updateRPKI()
+	if s.FIBRetryInterval > 0 {
+		go s.retryFIB(ctx)
+	}
+}
+
+// 経路が変わらなくても、書き込みに失敗したルートを一定の間隔で書き込み直す
+func (s *Server) retryFIB(ctx context.Context) {
+	t := time.NewTicker(s.FIBRetryInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if !s.LocRib.HasFIBChanges() {
+				continue
+			}
+			if err := s.LocRib.WriteToKernelRoutingTable(); err != nil {
+				log.Error("cannot write routes to kernel routing table", "error", err)
+			}
+		}
+	}
+}
+
+// 起動中のPeerを、RemoteIPの順に返す
+func (s *Server) Peers() []*peer.Peer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]string, 0, len(s.peers))
+	for k := range s.peers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	ps := make([]*peer.Peer, 0, len(keys))
+	for _, k := range keys {
+		ps = append(ps, s.peers[k].peer)
+	}
+	return ps
+}
+
+// 設定ファイルを読み込み直し、現在の設定との差分を反映する
+// 設定に誤りがある場合は何も変更せずにエラーを返す
+func (s *Server) ReloadFile() (*Diff, error) {
+	if s.ConfigPath == "" {
+		return nil, fmt.Errorf("config file is not specified")
+	}
+	c, err := config.Load(s.ConfigPath)
+	if err != nil {
+		return nil, err
+	}
+	return s.Reload(c.LocRibConfig(), c.PeerConfigs())
+}
+
+// LocRibの設定。APIからPeerを追加する場合のデフォルト値に使う
+func (s *Server) LocRibConfig() *peer.Config {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.locRibConf
+}
+
+// RemoteIPがaddrのPeerを返す
+func (s *Server) Peer(addr net.IP) (*peer.Peer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rp, ok := s.peers[addr.String()]
+	if !ok {
+		return nil, fmt.Errorf("%v: %w", addr, ErrNeighborNotFound)
+	}
+	return rp.peer, nil
+}
+
+// Peerを追加して起動する
+func (s *Server) AddNeighbor(c *peer.Config) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ctx == nil {
+		return fmt.Errorf("server is not started")
+	}
+	if _, ok := s.peers[c.RemoteIP.String()]; ok {
+		return fmt.Errorf("%v: %w", c.RemoteIP, ErrNeighborExists)
+	}
+	s.startPeer(c)
+	return nil
+}
+
+// Peerを停止して削除する
+func (s *Server) DeleteNeighbor(addr net.IP) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rp, ok := s.peers[addr.String()]
+	if !ok {
+		return fmt.Errorf("%v: %w", addr, ErrNeighborNotFound)
+	}
+	rp.peer.Stop()
+	delete(s.peers, addr.String())
+	return nil
+}
+
+// 無効にしたPeerを再び接続する
+func (s *Server) EnableNeighbor(addr net.IP) error {
+	p, err := s.Peer(addr)
+	if err != nil {
+		return err
+	}
+	p.Enable()
+	return nil
+}
+
+// Peerを無効にする
+func (s *Server) DisableNeighbor(addr net.IP) error {
+	p, err := s.Peer(addr)
+	if err != nil {
+		return err
+	}
+	p.Disable()
+	return nil
+}
+
+// Peerのセッションを張り直す
+// softがtrueの場合は、セッションを維持したまま経路を評価し直す
+func (s *Server) ResetNeighbor(addr net.IP, soft bool) error {
+	p, err := s.Peer(addr)
+	if err != nil {
+		return err
+	}
+	if soft {
+		p.SoftReset()
+	} else {
+		p.Reset()
+	}
+	return nil
+}
+
+// 自身で生成する経路を追加し、すべてのPeerに広告する
+// LocRibの変更は、LocRibから起動中のすべてのPeerに通知される
+func (s *Server) AddLocalPath(nw *net.IPNet) error {
+	return s.LocRib.AddLocalPath(nw)
+}
+
+// AddLocalPathで追加した経路を削除し、すべてのPeerに取り消しを送信する
+func (s *Server) DeleteLocalPath(nw *net.IPNet) error {
+	return s.LocRib.DeleteLocalPath(nw)
+}
+
+// 自身で生成する経路をまとめて追加し、すべてのPeerに広告する
+func (s *Server) AddLocalPaths(res []*peer.RibEntry) {
+	s.LocRib.AddLocalPaths(res)
+}
+
+// 自身で生成した経路をまとめて削除し、すべてのPeerに取り消しを送信する
+func (s *Server) DeleteLocalPaths(nws []*net.IPNet) {
+	s.LocRib.DeleteLocalPaths(nws)
+}
+
+// 読み込み直した設定と現在の設定の差分を反映する
+//   - 追加されたPeerは起動する
+//   - 削除されたPeerはCease NotificationMessageを送信して停止する
+//   - 設定が変わったPeerは、可能であればセッションを維持したまま反映する
+func (s *Server) Reload(locRibConf *peer.Config, confs []*peer.Config) (*Diff, error) {
+	d, err := s.reload(locRibConf, confs)
+	if err != nil {
+		return nil, err
+	}
+	s.updateRPKI()
+	return d, nil
+}
+
+func (s *Server) reload(locRibConf *peer.Config, confs []*peer.Config) (*Diff, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ctx == nil {
+		return nil, fmt.Errorf("server is not started")
+	}
+	olds := make([]*peer.Config, 0, len(s.peers))
+	for _, rp := range s.peers {
+		olds = append(olds, rp.conf)
+	}
+	d := NewDiff(s.locRibConf, olds, locRibConf, confs)
+	if d.Global {
+		if err := s.LocRib.Reconfigure(locRibConf); err != nil {
+			return nil, err
+		}
+		s.locRibConf = locRibConf
+		// マルチパスの設定が変わると、カーネルにインストールする経路が変わる
+		if err := s.LocRib.WriteToKernelRoutingTable(); err != nil {
+			log.Error("cannot write routes to kernel routing table", "error", err)
+		}
+	}
+	for _, c := range d.Removed {
+		key := c.RemoteIP.String()
+		s.peers[key].peer.Stop()
+		delete(s.peers, key)
+	}
+	// 自身で生成する経路の変更は、LocRibから起動中のすべてのPeerに通知される
+	for _, c := range d.Changed {
+		key := c.RemoteIP.String()
+		s.peers[key].conf = c
+		s.peers[key].peer.Reconfigure(c)
+	}
+	for _, c := range d.Added {
+		s.startPeer(c)
+	}
+	return d, nil
+}
+
+// LocRibの設定のRPKIキャッシュサーバーに接続する
+// 接続先が変わった場合は、前のキャッシュサーバーから受信したVRPを削除してから接続し直す
+func (s *Server) updateRPKI() {
+	s.rpkiMu.Lock()
+	defer s.rpkiMu.Unlock()
+	s.mu.Lock()
+	ctx, addr := s.ctx, s.locRibConf.RPKIServer
+	s.mu.Unlock()
+	if s.rpki != nil && s.rpki.addr == addr {
+		return
+	}
+	if s.rpki != nil {
+		// 停止したクライアントがVRPを書き換えないように、終了を待ってから削除する
+		s.rpki.cancel()
+		<-s.rpki.done
+		s.rpki = nil
+		s.LocRib.RPKI.Clear()
+		s.notifyRPKIUpdated()
+		log.Info("rpki cache server is removed")
+	}
+	if addr == "" {
+		return
+	}
+	if s.LocRib.RPKI == nil {
+		log.Warn("rpki is not enabled on loc_rib", "cache", addr)
+		return
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	rc := &rpkiClient{addr: addr, cancel: cancel, done: make(chan struct{})}
+	client := rpki.NewClient(addr, s.LocRib.RPKI)
+	client.OnUpdate = s.notifyRPKIUpdated
+	go func() {
+		defer close(rc.done)
+		client.Run(ctx)
+	}()
+	s.rpki = rc
+	log.Info("rpki cache server is configured", "cache", addr)
+}
+
+// RPKIキャッシュサーバーに接続している場合はtrueを返す
+func (s *Server) rpkiEnabled() bool {
+	s.rpkiMu.Lock()
+	defer s.rpkiMu.Unlock()
+	return s.rpki != nil
+}
+
+// VRPが更新されたことを起動中のPeerに通知する
+func (s *Server) notifyRPKIUpdated() {
+	for _, p := range s.Peers() {
+		p.NotifyRPKIUpdated()
+	}
+}
+
+// s.muをロックした状態で呼び出す
+func (s *Server) startPeer(c *peer.Config) {
+	rp := &runningPeer{peer: peer.NewPeer(c, s.LocRib), conf: c}
+	rp.peer.OnStateChange = s.onStateChange
+	rp.peer.OnMessage = s.onMessage
+	rp.peer.Recorder = s.Recorder
+	s.peers[c.RemoteIP.String()] = rp
+	rp.peer.Start()
+	go s.run(rp.peer)
+}
+
+func (s *Server) run(p *peer.Peer) {
+	// 停止したPeerから受信した経路をLocRibから削除した変更は、ほかのPeerに通知される
+	err := p.Run(s.ctx)
+	if err != nil && !errors.Is(err, peer.ErrStopped) {
+		log.Error("peer is stopped by error", "peer", p.Config.RemoteIP.String(), "error", err)
+	}
+}
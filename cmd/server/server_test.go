@@ -0,0 +1,176 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SotaUeda/gobgp/config"
+	"github.com/SotaUeda/gobgp/fib"
+	"github.com/SotaUeda/gobgp/peer"
+	"github.com/SotaUeda/gobgp/rpki"
+)
+
+const baseConfig = `
+[global]
+as = 64512
+router-id = "127.0.0.1"
+
+[[neighbors]]
+address = "127.0.0.2"
+remote-as = 64513
+mode = "active"
+port = 1
+import-policy = "reject-private"
+
+[neighbors.timers]
+connect-retry = "1h"
+
+[[neighbors]]
+address = "127.0.0.3"
+remote-as = 64514
+mode = "active"
+port = 1
+
+[neighbors.timers]
+connect-retry = "1h"
+
+[[policies]]
+name = "reject-private"
+
+[[policies.statements]]
+action = "reject"
+as-path = "_6451[0-9]$"
+`
+
+func parse(t *testing.T, data string) *config.Config {
+	t.Helper()
+	c, err := config.Parse("test.toml", []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func addrs(confs []*peer.Config) string {
+	s := []string{}
+	for _, c := range confs {
+		s = append(s, c.RemoteIP.String())
+	}
+	return strings.Join(s, ",")
+}
+
+// 設定ファイルの変更から、追加・削除・変更されたPeerを求められることを確認するテスト
+func TestNewDiff(t *testing.T) {
+	old := parse(t, baseConfig)
+	// 同じ内容の設定ファイルを読み込み直しても差分はない
+	same := parse(t, baseConfig)
+	if d := NewDiff(old.LocRibConfig(), old.PeerConfigs(), same.LocRibConfig(), same.PeerConfigs()); !d.Empty() {
+		t.Errorf("Want: no changes, Got: %v", d.Show())
+	}
+
+	modified := strings.Replace(baseConfig, `"_6451[0-9]$"`, `"_65000$"`, 1)
+	modified = strings.Replace(modified, `address = "127.0.0.3"`, `address = "127.0.0.4"`, 1)
+	modified = strings.Replace(modified, "router-id = \"127.0.0.1\"\n", "router-id = \"127.0.0.1\"\n\n[[global.networks]]\nprefix = \"10.0.0.0/24\"\nmode = \"unconditional\"\n", 1)
+	c := parse(t, modified)
+	d := NewDiff(old.LocRibConfig(), old.PeerConfigs(), c.LocRibConfig(), c.PeerConfigs())
+	if !d.Global {
+		t.Errorf("Want: global changed, Got: %v", d.Show())
+	}
+	if got := addrs(d.Added); got != "127.0.0.4" {
+		t.Errorf("Want: 127.0.0.4, Got: %v", got)
+	}
+	if got := addrs(d.Removed); got != "127.0.0.3" {
+		t.Errorf("Want: 127.0.0.3, Got: %v", got)
+	}
+	if got := addrs(d.Changed); got != "127.0.0.2" {
+		t.Errorf("Want: 127.0.0.2, Got: %v", got)
+	}
+}
+
+// 設定を読み込み直すと、削除されたPeerが停止し、追加されたPeerが起動することを確認するテスト
+func TestServerReload(t *testing.T) {
+	old := parse(t, baseConfig)
+	lr, err := peer.NewLocRib(old.LocRibConfig(), fib.NewMemory())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := New(lr, old.LocRibConfig())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.Start(ctx, old.PeerConfigs())
+	if len(s.Peers()) != 2 {
+		t.Fatalf("Want: 2, Got: %d", len(s.Peers()))
+	}
+
+	c := parse(t, strings.Replace(baseConfig, `address = "127.0.0.3"`, `address = "127.0.0.4"`, 1))
+	d, err := s.Reload(c.LocRibConfig(), c.PeerConfigs())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Show() != "added: 127.0.0.4; removed: 127.0.0.3" {
+		t.Errorf("Want: added: 127.0.0.4; removed: 127.0.0.3, Got: %v", d.Show())
+	}
+	ps := s.Peers()
+	if len(ps) != 2 || !ps[1].Config.RemoteIP.Equal(c.PeerConfigs()[1].RemoteIP) {
+		t.Errorf("Want: 127.0.0.2, 127.0.0.4, Got: %v", ps)
+	}
+
+	// 設定ファイルを指定していない場合は読み込み直せない
+	if _, err := s.ReloadFile(); err == nil {
+		t.Errorf("Want: error, Got: nil")
+	}
+}
+
+// 設定を読み込み直すとRPKIキャッシュサーバーに接続し、削除すると受信したVRPを削除することを確認するテスト
+func TestServerReloadRPKI(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	old := parse(t, baseConfig)
+	lr, err := peer.NewLocRib(old.LocRibConfig(), fib.NewMemory())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lr.RPKI = rpki.NewTable()
+	s := New(lr, old.LocRibConfig())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.Start(ctx, old.PeerConfigs())
+	if s.rpkiEnabled() {
+		t.Errorf("rpki should not be enabled before it is configured")
+	}
+
+	port := ln.Addr().(*net.TCPAddr).Port
+	c := parse(t, baseConfig+fmt.Sprintf("\n[global.rpki]\naddress = \"127.0.0.1\"\nport = %d\n", port))
+	d, err := s.Reload(c.LocRibConfig(), c.PeerConfigs())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !d.Global || !s.rpkiEnabled() {
+		t.Errorf("Want: rpki is enabled by global change, Got: %v", d.Show())
+	}
+	ln.(*net.TCPListener).SetDeadline(time.Now().Add(5 * time.Second))
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatalf("rpki client should connect to cache server: %v", err)
+	}
+	defer conn.Close()
+	lr.RPKI.Add(rpki.VRP{Prefix: netip.MustParsePrefix("10.0.0.0/8"), MaxLength: 24, ASN: 64513})
+
+	if _, err := s.Reload(old.LocRibConfig(), old.PeerConfigs()); err != nil {
+		t.Fatal(err)
+	}
+	if s.rpkiEnabled() {
+		t.Errorf("rpki should be disabled after cache server is removed")
+	}
+	if lr.RPKI.Len() != 0 {
+		t.Errorf("Want: 0, Got: %d", lr.RPKI.Len())
+	}
+}
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"context"
+	"net"
+
+	"github.com/SotaUeda/gobgp/packets"
+	"github.com/SotaUeda/gobgp/peer"
+)
+
+type EventType int
+
+const (
+	// Peerの状態が変わった
+	PEER_STATE_EVENT EventType = iota
+	// 最適経路が変わった
+	BEST_PATH_EVENT
+	// PeerとMessageを送受信した
+	MESSAGE_EVENT
+)
+
+// Watchで通知するイベント
+type Event struct {
+	Type EventType
+	// PEER_STATE_EVENT, MESSAGE_EVENTの場合に設定する
+	Address  net.IP
+	OldState peer.State
+	NewState peer.State
+	// PEER_STATE_EVENTの場合に設定する、遷移した直後のPeerの状態
+	Info *peer.PeerInfo
+	// BEST_PATH_EVENTの場合に設定する
+	// 最適経路がなくなった場合、Bestはnil
+	Prefix *net.IPNet
+	Best   *peer.RibEntry
+	// MESSAGE_EVENTの場合に設定する
+	// Sentは自身が送信した場合にtrue
+	Message packets.Message
+	Sent    bool
+	// 送受信した時点でMessageをバイト列に変換したもの
+	// 受信した経路のPathAttributeは後から書き換えられることがあるため、こちらを使う
+	Raw []byte
+	// このイベントの前に、バッファが一杯で破棄したイベントの数
+	Dropped uint64
+}
+
+// 通知が追いつかない場合に、Watchのチャネルに溜めておくイベントの数
+const WATCH_BUFFER_SIZE = 256
+
+type watcher struct {
+	// 通知するイベントの種類。nilの場合はすべて通知する
+	types map[EventType]bool
+	// 次に通知するイベントまでに破棄したイベントの数
+	dropped uint64
+}
+
+// イベントを通知するチャネルを返す
+// typesを指定した場合は、その種類のイベントだけを通知する。
+// ctxがキャンセルされるとチャネルを閉じる。
+// 受信が追いつかずにバッファが一杯になった場合、イベントは破棄し、
+// 次に通知するイベントのDroppedに破棄した数を設定する。
+func (s *Server) Watch(ctx context.Context, types ...EventType) <-chan *Event {
+	return s.WatchWithBuffer(ctx, WATCH_BUFFER_SIZE, types...)
+}
+
+// チャネルのバッファの大きさを指定してWatchする
+// MESSAGE_EVENTのように頻繁に発生するイベントを受け取る場合に使う
+func (s *Server) WatchWithBuffer(ctx context.Context, size int, types ...EventType) <-chan *Event {
+	ch := make(chan *Event, size)
+	w := &watcher{}
+	if len(types) > 0 {
+		w.types = make(map[EventType]bool)
+		for _, t := range types {
+			w.types[t] = true
+		}
+	}
+	s.watchMu.Lock()
+	s.watchers[ch] = w
+	s.watchMu.Unlock()
+	go func() {
+		<-ctx.Done()
+		s.watchMu.Lock()
+		delete(s.watchers, ch)
+		close(ch)
+		s.watchMu.Unlock()
+	}()
+	return ch
+}
+
+// Peerのgoroutineやロック中のLocRibから呼び出されるため、送信で処理を止めない
+func (s *Server) publish(ev *Event) {
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+	for ch, w := range s.watchers {
+		if w.types != nil && !w.types[ev.Type] {
+			continue
+		}
+		e := ev
+		if w.dropped > 0 {
+			c := *ev
+			c.Dropped = w.dropped
+			e = &c
+		}
+		select {
+		case ch <- e:
+			w.dropped = 0
+		default:
+			if w.dropped == 0 {
+				log.Warn("watch event is dropped: buffer is full")
+			}
+			w.dropped++
+		}
+	}
+}
+
+func (s *Server) onStateChange(p *peer.Peer, old, new peer.State) {
+	info := p.Info()
+	s.publish(&Event{
+		Type:     PEER_STATE_EVENT,
+		Address:  p.Config.RemoteIP,
+		OldState: old,
+		NewState: new,
+		Info:     &info,
+	})
+}
+
+func (s *Server) onMessage(p *peer.Peer, m packets.Message, sent bool) {
+	// Messageをバイト列に変換する処理を省くため、通知先がない場合は何もしない
+	if !s.watched(MESSAGE_EVENT) {
+		return
+	}
+	raw, err := m.ToBytes()
+	if err != nil {
+		log.Warn("cannot encode message for watchers", "peer", p.Config.RemoteIP.String(), "error", err)
+		return
+	}
+	s.publish(&Event{
+		Type:    MESSAGE_EVENT,
+		Address: p.Config.RemoteIP,
+		Message: m,
+		Sent:    sent,
+		Raw:     raw,
+	})
+}
+
+func (s *Server) watched(t EventType) bool {
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+	for _, w := range s.watchers {
+		if w.types == nil || w.types[t] {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) onBestPathChange(nw *net.IPNet, best *peer.RibEntry) {
+	s.publish(&Event{Type: BEST_PATH_EVENT, Prefix: nw, Best: best})
+}